@@ -7,6 +7,8 @@ type Config struct {
 	Storage     StorageConfig `toml:"storage"`
 	PprofEnable bool          `toml:"pprof_enable"`
 	AllowWrite  bool          `toml:"allow_write"`
+	// Namespace is the configuration of the individual namespaces, by their name.
+	Namespace map[string]NamespaceConfig `toml:"namespace"`
 }
 
 type GrpcConfig struct {
@@ -22,4 +24,57 @@ type StorageConfig struct {
 	SegmentSize    string   `toml:"segment_size"`
 	ValueThreshold string   `toml:"value_threshold"`
 	ArenaSize      string   `toml:"arena_size"`
+	// DBEngine is the name of the registered kvdrivers driver, default is LMDB.
+	DBEngine string `toml:"db_engine"`
+	// DriverConfig is decoded by the DBEngine driver.
+	DriverConfig map[string]any `toml:"driver_config"`
+	// HistoryIndex keeps an on disk index of the changes of every key, served by the History rpc.
+	HistoryIndex bool `toml:"history_index"`
+}
+
+type NamespaceConfig struct {
+	Replication ReplicationConfig `toml:"replication"`
+	// Raft replicates the namespace among its nodes with Raft when set, the writes are only taken by the leader.
+	Raft *RaftConfig `toml:"raft"`
+	// MultiLeader takes the writes of the namespace on every node when set, they are exchanged with the peers.
+	MultiLeader *MultiLeaderConfig `toml:"multi_leader"`
+	// Upstream makes the namespace a read only replica of its primary when set, the WAL of the primary
+	// is streamed into it till the namespace is promoted.
+	Upstream *UpstreamConfig `toml:"upstream"`
+}
+
+type UpstreamConfig struct {
+	// Address is the grpc address of the primary of the namespace.
+	Address string `toml:"address"`
+	// ReplicaID is the id of the server among the followers of the primary, its progress is acknowledged with it.
+	ReplicaID string `toml:"replica_id"`
+}
+
+type MultiLeaderConfig struct {
+	// NodeID is the id of the server among the nodes of the namespace, the writes taken on it are tagged with it.
+	NodeID string `toml:"node_id"`
+	// Peers are the grpc addresses of the other nodes by their id, the WAL of every peer is streamed from it.
+	Peers map[string]string `toml:"peers"`
+}
+
+type RaftConfig struct {
+	// NodeID is the id of the server among the nodes of the namespace.
+	NodeID string `toml:"node_id"`
+	// Members are the grpc addresses of the nodes by their id, a new namespace is bootstrapped with them.
+	// A node that joins a running namespace has no members, it's added on the leader.
+	Members map[string]string `toml:"members"`
+	// ElectionTimeout is the time a follower waits for the leader before it campaigns, like "1s".
+	ElectionTimeout string `toml:"election_timeout"`
+	// HeartbeatInterval is the interval of the heartbeats of the leader, like "100ms".
+	HeartbeatInterval string `toml:"heartbeat_interval"`
+}
+
+type ReplicationConfig struct {
+	// SyncReplicas is the number of followers that must acknowledge a write as durable before it returns.
+	SyncReplicas int `toml:"sync_replicas"`
+	// SyncTimeout is the time a write waits for the sync replicas, like "5s".
+	SyncTimeout string `toml:"sync_timeout"`
+	// OnTimeout is "fail" to return the write that times out with an error, or "degrade" to stop waiting
+	// for the sync replicas till they catch up. Default is "fail".
+	OnTimeout string `toml:"on_timeout"`
 }
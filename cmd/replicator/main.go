@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -18,6 +19,7 @@ import (
 	"github.com/ankur-anand/unisondb/dbkernel"
 	"github.com/ankur-anand/unisondb/internal/middleware"
 	"github.com/ankur-anand/unisondb/internal/services/kvstore"
+	"github.com/ankur-anand/unisondb/internal/services/raft"
 	"github.com/ankur-anand/unisondb/internal/services/streamer"
 	v1 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
 	"github.com/hashicorp/go-metrics"
@@ -28,6 +30,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
 )
 
@@ -50,7 +53,11 @@ func main() {
 	setupFunc := []func(context.Context) error{
 		server.init,
 		server.initTelemetry,
+		server.setupStorageConfig,
 		server.setupStorage,
+		server.setupRaft,
+		server.setupPeers,
+		server.setupUpstreams,
 		server.setupGrpcServer,
 		server.setupHTTPServer,
 	}
@@ -88,8 +95,11 @@ func main() {
 type mainServer struct {
 	cfg           config.Config
 	engines       map[string]*dbkernel.Engine
+	raftNodes     map[string]*raft.Node
+	upstreams     map[string]*upstream
 	grpcServer    *grpc.Server
 	httpServer    *http.Server
+	streamer      *streamer.GrpcStreamer
 	storageConfig *dbkernel.EngineConfig
 
 	// callbacks when shutdown.
@@ -108,6 +118,8 @@ func (ms *mainServer) init(ctx context.Context) error {
 	err = toml.Unmarshal(cfgBytes, &ms.cfg)
 	fatalIfErr(err)
 	ms.engines = make(map[string]*dbkernel.Engine)
+	ms.raftNodes = make(map[string]*raft.Node)
+	ms.upstreams = make(map[string]*upstream)
 	return nil
 }
 
@@ -128,6 +140,11 @@ func (ms *mainServer) initTelemetry(ctx context.Context) error {
 
 func (ms *mainServer) setupStorageConfig(ctx context.Context) error {
 	storeConfig := dbkernel.NewDefaultEngineConfig()
+	if ms.cfg.Storage.DBEngine != "" {
+		storeConfig.DBEngine = dbkernel.DBEngine(ms.cfg.Storage.DBEngine)
+	}
+	storeConfig.DriverConfig = ms.cfg.Storage.DriverConfig
+	storeConfig.HistoryIndex = ms.cfg.Storage.HistoryIndex
 
 	ms.storageConfig = storeConfig
 	return nil
@@ -135,7 +152,17 @@ func (ms *mainServer) setupStorageConfig(ctx context.Context) error {
 
 func (ms *mainServer) setupStorage(ctx context.Context) error {
 	for _, namespace := range ms.cfg.Storage.Namespaces {
-		store, err := dbkernel.NewStorageEngine(ms.cfg.Storage.BaseDir, namespace, ms.storageConfig)
+		storeConfig := *ms.storageConfig
+		replication, err := replicationConfig(ms.cfg.Namespace[namespace].Replication)
+		fatalIfErr(err)
+		storeConfig.Replication = replication
+		storeConfig.Consensus = ms.cfg.Namespace[namespace].Raft != nil
+		if multiLeader := ms.cfg.Namespace[namespace].MultiLeader; multiLeader != nil {
+			storeConfig.MultiLeader = true
+			storeConfig.NodeID = multiLeader.NodeID
+		}
+		storeConfig.Replica = ms.cfg.Namespace[namespace].Upstream != nil
+		store, err := dbkernel.NewStorageEngine(ms.cfg.Storage.BaseDir, namespace, &storeConfig)
 		fatalIfErr(err)
 		ms.engines[namespace] = store
 		ms.deferCallback = append(ms.deferCallback, func(ctx context.Context) {
@@ -148,9 +175,165 @@ func (ms *mainServer) setupStorage(ctx context.Context) error {
 	return nil
 }
 
+// setupRaft starts the raft node of every namespace with the raft config.
+func (ms *mainServer) setupRaft(ctx context.Context) error {
+	dial := func(address string) (*grpc.ClientConn, error) {
+		return grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	for _, namespace := range ms.cfg.Storage.Namespaces {
+		cfg := ms.cfg.Namespace[namespace].Raft
+		if cfg == nil {
+			continue
+		}
+		raftConfig, err := newRaftConfig(cfg)
+		fatalIfErr(err)
+		raftConfig.Dial = dial
+		node, err := raft.NewNode(ms.engines[namespace], raftConfig)
+		fatalIfErr(err)
+		ms.raftNodes[namespace] = node
+		// the node stops before the engine of the namespace is closed.
+		ms.deferCallback = append([]func(ctx context.Context){func(ctx context.Context) {
+			if err := node.Close(); err != nil {
+				slog.Error("[main] mainServer.setupRaft: close raft node failed", "error", err)
+			}
+		}}, ms.deferCallback...)
+	}
+	return nil
+}
+
+// setupPeers streams the WAL of every peer of the multi leader namespaces into their engine.
+func (ms *mainServer) setupPeers(ctx context.Context) error {
+	peerCtx, cancel := context.WithCancel(context.Background())
+	var conns []*grpc.ClientConn
+	for _, namespace := range ms.cfg.Storage.Namespaces {
+		cfg := ms.cfg.Namespace[namespace].MultiLeader
+		if cfg == nil {
+			continue
+		}
+		for peerID, address := range cfg.Peers {
+			if peerID == cfg.NodeID {
+				continue
+			}
+			conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			fatalIfErr(err)
+			conns = append(conns, conn)
+			client, err := streamer.NewPeerStreamerClient(conn, ms.engines[namespace], peerID)
+			fatalIfErr(err)
+			go func() {
+				err := client.StreamWAL(peerCtx)
+				if err != nil && !errors.Is(err, context.Canceled) {
+					slog.Error("[main] mainServer.setupPeers: stream wal from peer failed",
+						"namespace", namespace, "peer", peerID, "error", err)
+				}
+			}()
+		}
+	}
+	// the peer streams stop before the engine of the namespace is closed.
+	ms.deferCallback = append([]func(ctx context.Context){func(ctx context.Context) {
+		cancel()
+		for _, conn := range conns {
+			if err := conn.Close(); err != nil {
+				slog.Error("[main] mainServer.setupPeers: close peer connection failed", "error", err)
+			}
+		}
+	}}, ms.deferCallback...)
+	return nil
+}
+
+// upstream is the stream of the WAL of the primary into a replica namespace.
+type upstream struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// stop stops the stream and waits for it to return, no record is applied from the primary after it.
+func (u *upstream) stop() {
+	u.cancel()
+	<-u.done
+}
+
+// setupUpstreams streams the WAL of the primary of every replica namespace into their engine.
+func (ms *mainServer) setupUpstreams(ctx context.Context) error {
+	var conns []*grpc.ClientConn
+	for _, namespace := range ms.cfg.Storage.Namespaces {
+		cfg := ms.cfg.Namespace[namespace].Upstream
+		if cfg == nil {
+			continue
+		}
+		conn, err := grpc.NewClient(cfg.Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		fatalIfErr(err)
+		conns = append(conns, conn)
+		client := streamer.NewReplicaStreamerClient(conn, ms.engines[namespace])
+		client.SetReplicaID(cfg.ReplicaID)
+
+		upstreamCtx, cancel := context.WithCancel(context.Background())
+		u := &upstream{cancel: cancel, done: make(chan struct{})}
+		ms.upstreams[namespace] = u
+		go func() {
+			defer close(u.done)
+			err := client.ReplicateWAL(upstreamCtx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				slog.Error("[main] mainServer.setupUpstreams: replicate wal from upstream failed",
+					"namespace", namespace, "upstream", cfg.Address, "error", err)
+			}
+		}()
+	}
+	// the upstream streams stop before the engine of the namespace is closed.
+	ms.deferCallback = append([]func(ctx context.Context){func(ctx context.Context) {
+		for _, u := range ms.upstreams {
+			u.stop()
+		}
+		for _, conn := range conns {
+			if err := conn.Close(); err != nil {
+				slog.Error("[main] mainServer.setupUpstreams: close upstream connection failed", "error", err)
+			}
+		}
+	}}, ms.deferCallback...)
+	return nil
+}
+
+func newRaftConfig(cfg *config.RaftConfig) (raft.Config, error) {
+	raftConfig := raft.Config{ID: cfg.NodeID, Members: cfg.Members}
+	if cfg.ElectionTimeout != "" {
+		timeout, err := time.ParseDuration(cfg.ElectionTimeout)
+		if err != nil {
+			return raftConfig, fmt.Errorf("invalid election_timeout %q: %w", cfg.ElectionTimeout, err)
+		}
+		raftConfig.ElectionTimeout = timeout
+	}
+	if cfg.HeartbeatInterval != "" {
+		interval, err := time.ParseDuration(cfg.HeartbeatInterval)
+		if err != nil {
+			return raftConfig, fmt.Errorf("invalid heartbeat_interval %q: %w", cfg.HeartbeatInterval, err)
+		}
+		raftConfig.HeartbeatInterval = interval
+	}
+	return raftConfig, nil
+}
+
+func replicationConfig(cfg config.ReplicationConfig) (dbkernel.ReplicationConfig, error) {
+	replication := dbkernel.ReplicationConfig{SyncReplicas: cfg.SyncReplicas}
+	if cfg.SyncTimeout != "" {
+		timeout, err := time.ParseDuration(cfg.SyncTimeout)
+		if err != nil {
+			return replication, fmt.Errorf("invalid sync_timeout %q: %w", cfg.SyncTimeout, err)
+		}
+		replication.SyncTimeout = timeout
+	}
+	switch cfg.OnTimeout {
+	case "", "fail":
+	case "degrade":
+		replication.DegradeOnTimeout = true
+	default:
+		return replication, fmt.Errorf("invalid on_timeout %q, expected fail or degrade", cfg.OnTimeout)
+	}
+	return replication, nil
+}
+
 func (ms *mainServer) setupGrpcServer(ctx context.Context) error {
 	errGroup, _ := errgroup.WithContext(ctx)
 	rep := streamer.NewGrpcStreamer(errGroup, ms.engines, 2*time.Minute)
+	ms.streamer = rep
 	kvr := kvstore.NewKVReaderService(ms.engines)
 	kvw := kvstore.NewKVWriterService(ms.engines)
 
@@ -170,6 +353,9 @@ func (ms *mainServer) setupGrpcServer(ctx context.Context) error {
 
 	v1.RegisterWALReplicationServiceServer(gS, rep)
 	v1.RegisterKVStoreReadServiceServer(gS, kvr)
+	if len(ms.raftNodes) > 0 {
+		v1.RegisterRaftServiceServer(gS, raft.NewService(ms.raftNodes))
+	}
 	// only register write server if allowed
 	if ms.cfg.AllowWrite {
 		v1.RegisterKVStoreWriteServiceServer(gS, kvw)
@@ -178,6 +364,9 @@ func (ms *mainServer) setupGrpcServer(ctx context.Context) error {
 	ms.grpcServer = gS
 	ms.deferCallback = append(ms.deferCallback, func(ctx context.Context) {
 		gS.GracefulStop()
+		if err := rep.Close(); err != nil {
+			slog.Error("[main] mainServer.setupGrpcServer: remove snapshots failed", "error", err)
+		}
 	})
 	return nil
 }
@@ -185,6 +374,9 @@ func (ms *mainServer) setupGrpcServer(ctx context.Context) error {
 func (ms *mainServer) setupHTTPServer(ctx context.Context) error {
 	mux := http.NewServeMux()
 	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /admin/replicas", ms.listReplicas)
+	mux.HandleFunc("GET /admin/raft", ms.raftStatus)
+	mux.HandleFunc("POST /admin/namespaces/{namespace}/promote", ms.promote)
 
 	ms.httpServer = &http.Server{
 		WriteTimeout: time.Second * 15,
@@ -201,6 +393,63 @@ func (ms *mainServer) setupHTTPServer(ctx context.Context) error {
 	return nil
 }
 
+// listReplicas lists the followers connected over ReplicateWAL with their lag.
+func (ms *mainServer) listReplicas(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ms.streamer.Replicas()); err != nil {
+		slog.Error("[main] mainServer.listReplicas: encode replicas failed", "error", err)
+	}
+}
+
+// promoteResponse is the new epoch of a promoted namespace.
+type promoteResponse struct {
+	Namespace string `json:"namespace"`
+	Epoch     uint64 `json:"epoch"`
+}
+
+// promote makes the replica namespace the primary in a new epoch, and stops the stream from its upstream.
+// The upstream of the namespace should be removed from the config, it's opened as a replica again otherwise.
+func (ms *mainServer) promote(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+	engine, ok := ms.engines[namespace]
+	if !ok {
+		http.Error(w, "namespace not found", http.StatusNotFound)
+		return
+	}
+
+	epoch, err := engine.Promote()
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, dbkernel.ErrNotReplica) {
+			code = http.StatusConflict
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	// the records still streamed from the old primary are rejected by the engine from the new epoch.
+	if u, ok := ms.upstreams[namespace]; ok {
+		u.stop()
+	}
+	slog.Info("[main] namespace promoted", "namespace", namespace, "epoch", epoch)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(promoteResponse{Namespace: namespace, Epoch: epoch}); err != nil {
+		slog.Error("[main] mainServer.promote: encode epoch failed", "error", err)
+	}
+}
+
+// raftStatus lists the state of the raft node of every consensus namespace.
+func (ms *mainServer) raftStatus(w http.ResponseWriter, r *http.Request) {
+	status := make(map[string]raft.Status, len(ms.raftNodes))
+	for namespace, node := range ms.raftNodes {
+		status[namespace] = node.Status()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		slog.Error("[main] mainServer.raftStatus: encode raft status failed", "error", err)
+	}
+}
+
 func (ms *mainServer) RunGrpc(ctx context.Context) error {
 	go func() {
 		var lis net.ListenConfig
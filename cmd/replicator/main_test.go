@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ankur-anand/unisondb/cmd/replicator/config"
+	"github.com/ankur-anand/unisondb/dbkernel"
+	"github.com/ankur-anand/unisondb/internal/middleware"
+	"github.com/ankur-anand/unisondb/internal/services/raft"
+	"github.com/ankur-anand/unisondb/internal/services/streamer"
+	v1 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+)
+
+// startPrimary serves the WAL of the primary engine of the namespace over grpc, and returns its address.
+func startPrimary(t *testing.T, primary *dbkernel.Engine) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	errGroup, _ := errgroup.WithContext(context.Background())
+	rep := streamer.NewGrpcStreamer(errGroup, map[string]*dbkernel.Engine{primary.Namespace(): primary}, 2*time.Second)
+	gS := grpc.NewServer(grpc.ChainStreamInterceptor(middleware.RequireNamespaceInterceptor,
+		middleware.RequestIDStreamInterceptor,
+		middleware.CorrelationIDStreamInterceptor,
+		middleware.MethodInterceptor))
+	v1.RegisterWALReplicationServiceServer(gS, rep)
+	go func() {
+		_ = gS.Serve(listener)
+	}()
+	t.Cleanup(gS.Stop)
+	return listener.Addr().String()
+}
+
+func TestMainServer_Promote(t *testing.T) {
+	namespace := "promote"
+	primary, err := dbkernel.NewStorageEngine(t.TempDir(), namespace, dbkernel.NewDefaultEngineConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, primary.Close(context.Background()))
+	})
+	for i := 0; i < 10; i++ {
+		require.NoError(t, primary.Put([]byte(fmt.Sprintf("key-%d", i)), []byte("value")))
+	}
+
+	ms := &mainServer{
+		cfg: config.Config{
+			Storage: config.StorageConfig{BaseDir: t.TempDir(), Namespaces: []string{namespace}},
+			Namespace: map[string]config.NamespaceConfig{
+				namespace: {Upstream: &config.UpstreamConfig{Address: startPrimary(t, primary), ReplicaID: "replica-1"}},
+			},
+		},
+		engines:   make(map[string]*dbkernel.Engine),
+		raftNodes: make(map[string]*raft.Node),
+		upstreams: make(map[string]*upstream),
+	}
+	ctx := context.Background()
+	for _, fn := range []func(context.Context) error{ms.setupStorageConfig, ms.setupStorage, ms.setupUpstreams, ms.setupHTTPServer} {
+		require.NoError(t, fn(ctx))
+	}
+	t.Cleanup(func() {
+		for _, fn := range ms.deferCallback {
+			fn(ctx)
+		}
+	})
+	server := httptest.NewServer(ms.httpServer.Handler)
+	defer server.Close()
+
+	replica := ms.engines[namespace]
+	assert.Eventually(t, func() bool {
+		return replica.LastLSN() == primary.LastLSN()
+	}, 10*time.Second, 10*time.Millisecond)
+
+	promote := func(namespace string) *http.Response {
+		resp, err := http.Post(server.URL+"/admin/namespaces/"+namespace+"/promote", "", nil)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = resp.Body.Close()
+		})
+		return resp
+	}
+
+	resp := promote(namespace)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var promoted promoteResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&promoted))
+	assert.Equal(t, promoteResponse{Namespace: namespace, Epoch: 1}, promoted)
+	assert.Equal(t, uint64(1), replica.Epoch())
+
+	select {
+	case <-ms.upstreams[namespace].done:
+	default:
+		t.Fatal("upstream stream should be stopped once the namespace is promoted")
+	}
+	lastLSN := replica.LastLSN()
+	require.NoError(t, primary.Put([]byte("stale"), []byte("value")))
+	require.NoError(t, replica.Put([]byte("key"), []byte("value")))
+	assert.Equal(t, lastLSN+1, replica.LastLSN())
+
+	t.Run("not_replica", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, promote(namespace).StatusCode)
+	})
+
+	t.Run("unknown_namespace", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, promote("unknown").StatusCode)
+	})
+}
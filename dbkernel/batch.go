@@ -0,0 +1,217 @@
+package dbkernel
+
+import (
+	"errors"
+	"hash/crc32"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal"
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
+	"github.com/dgraph-io/badger/v4/skl"
+	"github.com/dgraph-io/badger/v4/y"
+	"github.com/hashicorp/go-metrics"
+)
+
+var (
+	ErrEmptyBatch = errors.New("empty batch")
+	// ErrBatchTooLarge is returned when the operations of a batch don't fit together in a mem table.
+	ErrBatchTooLarge = errors.New("batch exceeds the mem table capacity")
+)
+
+var (
+	mKeyWriteBatchTotal    = append(packageKey, "write", "batch", "total")
+	mKeyWriteBatchOpsTotal = append(packageKey, "write", "batch", "ops", "total")
+	mKeyWriteBatchDuration = append(packageKey, "write", "batch", "durations", "seconds")
+)
+
+type batchOp struct {
+	key       []byte
+	value     []byte
+	columns   map[string][]byte
+	operation walrecord.LogOperation
+	entryType walrecord.EntryType
+}
+
+// Batch is a set of puts, deletes and row column operations that are written atomically by Engine.WriteBatch.
+// Operations are applied in the order they were added. The key, value and columns are not copied,
+// they should not be modified until the batch is written.
+type Batch struct {
+	ops []batchOp
+}
+
+// NewBatch returns an empty Batch.
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Put adds the insert of the key-value pair to the batch.
+func (b *Batch) Put(key, value []byte) {
+	b.ops = append(b.ops, batchOp{key: key, value: value, operation: walrecord.LogOperationInsert, entryType: walrecord.EntryTypeKV})
+}
+
+// Delete adds the delete of the key to the batch.
+func (b *Batch) Delete(key []byte) {
+	b.ops = append(b.ops, batchOp{key: key, operation: walrecord.LogOperationDelete, entryType: walrecord.EntryTypeKV})
+}
+
+// SetColumnsInRow adds the upsert of the column entries of the row to the batch.
+func (b *Batch) SetColumnsInRow(rowKey string, columnEntries map[string][]byte) {
+	b.ops = append(b.ops, batchOp{key: []byte(rowKey), columns: columnEntries, operation: walrecord.LogOperationInsert, entryType: walrecord.EntryTypeRow})
+}
+
+// DeleteColumnsFromRow adds the delete of the columns of the row to the batch.
+func (b *Batch) DeleteColumnsFromRow(rowKey string, columnEntries map[string][]byte) {
+	b.ops = append(b.ops, batchOp{key: []byte(rowKey), columns: columnEntries, operation: walrecord.LogOperationDelete, entryType: walrecord.EntryTypeRow})
+}
+
+// DeleteRow adds the delete of the entire row to the batch.
+func (b *Batch) DeleteRow(rowKey string) {
+	b.ops = append(b.ops, batchOp{key: []byte(rowKey), operation: walrecord.LogOperationDeleteRow, entryType: walrecord.EntryTypeRow})
+}
+
+// Len returns the number of operations in the batch.
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Reset removes all the operations from the batch, so it can be reused.
+func (b *Batch) Reset() {
+	clear(b.ops)
+	b.ops = b.ops[:0]
+}
+
+// record returns the record of the operation, it's written to the WAL as part of the batch record.
+func (op batchOp) record(lsn, hlc uint64) logcodec.LogRecord {
+	record := logcodec.LogRecord{
+		LSN:           lsn,
+		HLC:           hlc,
+		OperationType: logrecord.LogOperationType(op.operation),
+		TxnState:      logrecord.TransactionStateNone,
+		EntryType:     logrecord.LogEntryType(op.entryType),
+	}
+	if op.entryType == walrecord.EntryTypeRow {
+		record.Payload = rowPayload(op.key, op.columns)
+		return record
+	}
+	record.CRC32Checksum = crc32.ChecksumIEEE(op.value)
+	record.Payload = kvPayload(op.key, op.value)
+	return record
+}
+
+// valueSize is the size compared with the value threshold, to keep the operation in the mem table
+// or a reference to the WAL.
+func (op batchOp) valueSize() int {
+	if op.entryType != walrecord.EntryTypeRow {
+		return len(op.value)
+	}
+	size := 0
+	for k, v := range op.columns {
+		size += len(k) + len(v)
+	}
+	return size
+}
+
+// WriteBatch writes all the operations of the batch as a single record in the WAL, so either all of them
+// are applied or none, by the reads, the mem table flush, the recovery and the replication of the WAL.
+// Operations of the batch share the same lsn and hybrid logical clock timestamp.
+// ErrBatchTooLarge is returned if the operations don't fit together in an empty mem table.
+func (e *Engine) WriteBatch(batch *Batch) error {
+	if err := e.writable(); err != nil {
+		return err
+	}
+	if batch == nil || batch.Len() == 0 {
+		return ErrEmptyBatch
+	}
+	for _, op := range batch.ops {
+		if op.entryType == walrecord.EntryTypeRow && op.operation != walrecord.LogOperationDeleteRow && len(op.columns) == 0 {
+			return ErrEmptyColumns
+		}
+	}
+
+	metrics.IncrCounterWithLabels(mKeyWriteBatchTotal, 1, e.metricsLabel)
+	metrics.IncrCounterWithLabels(mKeyWriteBatchOpsTotal, float32(batch.Len()), e.metricsLabel)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mKeyWriteBatchDuration, startTime, e.metricsLabel)
+	}()
+
+	lsn, err := e.persistBatch(batch)
+	if err != nil {
+		return err
+	}
+	return e.waitForSyncReplicas(lsn)
+}
+
+// persistBatch writes the batch record to the WAL and the operations to the same mem-table,
+// and returns the lsn of the batch record.
+func (e *Engine) persistBatch(batch *Batch) (uint64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.proposable(); err != nil {
+		return 0, err
+	}
+	lsn, _, err := e.appendBatch(batch, e.hlc.Now(), e.origin())
+	return lsn, err
+}
+
+// appendBatch writes the batch record with the hlc and origin to the WAL and the operations to the same mem-table,
+// and returns the lsn and the offset of the batch record. It's called with the e.mu held.
+func (e *Engine) appendBatch(batch *Batch, hlc uint64, origin string) (uint64, *wal.Offset, error) {
+	lsn := e.nextLSN()
+	records := make([]logcodec.LogRecord, len(batch.ops))
+	for i, op := range batch.ops {
+		records[i] = op.record(lsn, hlc)
+	}
+
+	record := logcodec.LogRecord{
+		LSN:           lsn,
+		HLC:           hlc,
+		Epoch:         e.epoch.Load(),
+		Origin:        origin,
+		OperationType: logrecord.LogOperationTypeBatch,
+		TxnState:      logrecord.TransactionStateNone,
+		EntryType:     logrecord.LogEntryTypeKV,
+		Payload:       logcodec.BatchPayload(records),
+	}
+
+	// the mem table entries are built before the append, so a batch that can't be applied is never written.
+	entries := make([]txMemTableEntry, len(batch.ops))
+	for i, op := range batch.ops {
+		var memValue y.ValueStruct
+		if int64(op.valueSize()) <= e.config.ValueThreshold {
+			memValue = getValueStruct(byte(op.operation), true, record.Payload.KeyValueBatchEntries.Entries[i].Value)
+		} else {
+			// offset is filled once the record is appended.
+			memValue = getValueStruct(byte(op.operation), false, encodeBatchReference(&wal.Offset{}, i))
+		}
+		if op.entryType == walrecord.EntryTypeRow {
+			memValue.UserMeta = entryTypeRow
+		}
+		entries[i] = txMemTableEntry{key: op.key, value: memValue}
+	}
+	if int64(skl.MaxNodeSize)+arenaSize(entries)+arenaSafetyMargin > e.config.ArenaSize {
+		return 0, nil, ErrBatchTooLarge
+	}
+
+	e.writeSeenCounter.Add(uint64(len(batch.ops)))
+	offset, err := e.walIO.Append(record.Encode())
+	if err != nil {
+		return 0, nil, err
+	}
+	e.appendedLSN(lsn, offset)
+	if e.consensus != nil {
+		e.proposed(lsn, offset)
+		return lsn, offset, nil
+	}
+
+	for i := range entries {
+		entries[i].offset = offset
+		if entries[i].value.Value[0] == walReferencePrefix {
+			copy(entries[i].value.Value[1:], offset.EncodeFixedSize())
+		}
+	}
+	e.noteVersions(batch.ops, hlc, origin, offset)
+	return lsn, offset, e.memTableWriteBatch(entries, offset)
+}
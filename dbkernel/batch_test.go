@@ -0,0 +1,217 @@
+package dbkernel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestBatch returns a batch with every kind of operation, and a value above the value threshold.
+func newTestBatch(large []byte) *Batch {
+	batch := NewBatch()
+	batch.Put([]byte("key_1"), []byte("value_1"))
+	batch.Put([]byte("key_2"), []byte("value_2"))
+	batch.Put([]byte("large"), large)
+	batch.Delete([]byte("removed_key"))
+	batch.SetColumnsInRow("user_row_key", map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3")})
+	batch.DeleteColumnsFromRow("user_row_key", map[string][]byte{"b": nil})
+	batch.DeleteRow("deleted_row_key")
+	return batch
+}
+
+func assertTestBatch(t *testing.T, e *Engine, large []byte) {
+	t.Helper()
+	value, err := e.Get([]byte("key_1"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value_1"), value)
+	value, err = e.Get([]byte("key_2"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value_2"), value)
+	value, err = e.Get([]byte("large"))
+	require.NoError(t, err)
+	assert.Equal(t, large, value)
+
+	_, err = e.Get([]byte("removed_key"))
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	columns, err := e.GetRowColumns("user_row_key", nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "c": []byte("3")}, columns)
+	columns, err = e.GetRowColumns("deleted_row_key", nil)
+	if !errors.Is(err, ErrKeyNotFound) {
+		require.NoError(t, err)
+	}
+	assert.Empty(t, columns)
+}
+
+func TestEngine_WriteBatch(t *testing.T) {
+	baseDir := t.TempDir()
+	namespace := "test_write_batch"
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+	config.HistoryIndex = true
+	engine, err := NewStorageEngine(baseDir, namespace, config)
+	require.NoError(t, err)
+
+	require.NoError(t, engine.Put([]byte("removed_key"), []byte("value")))
+	require.NoError(t, engine.SetColumnsInRow("deleted_row_key", map[string][]byte{"a": []byte("1")}))
+	lsn := engine.LastLSN()
+
+	large := []byte(gofakeit.LetterN(uint(config.ValueThreshold) + 1))
+	batch := newTestBatch(large)
+	require.NoError(t, engine.WriteBatch(batch))
+	assert.Equal(t, lsn+1, engine.LastLSN(), "batch should be a single record")
+	assertTestBatch(t, engine, large)
+
+	reader, err := engine.NewReaderFromLSN(lsn + 1)
+	require.NoError(t, err)
+	data, _, err := reader.Next()
+	require.NoError(t, err)
+	record, err := logcodec.DecodeRecord(data)
+	require.NoError(t, err)
+	assert.True(t, record.IsBatch())
+	assert.Equal(t, batch.Len(), record.BatchLen())
+	_, _, err = reader.Next()
+	assert.ErrorIs(t, err, io.EOF)
+
+	events, err := engine.History([]byte("user_row_key"), 0, math.MaxUint64, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 1, "only the last change of the row in the batch is an event")
+	assert.Equal(t, walrecord.LogOperationDelete, events[0].Operation)
+	assert.Equal(t, record.HLC, events[0].HLC)
+	assert.Equal(t, record.LSN, events[0].Index)
+	events, err = engine.History([]byte("large"), 0, math.MaxUint64, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, large, events[0].Value)
+
+	require.NoError(t, engine.Put([]byte("key_1"), []byte("after")))
+	value, err := engine.GetAt([]byte("key_1"), record.HLC)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value_1"), value)
+	columns, err := engine.GetRowColumnsAt("user_row_key", record.HLC, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "c": []byte("3")}, columns)
+	require.NoError(t, engine.Put([]byte("key_1"), []byte("value_1")))
+
+	require.NoError(t, engine.Close(context.Background()))
+
+	// the batch is recovered from the wal.
+	engine, err = NewStorageEngine(baseDir, namespace, config)
+	require.NoError(t, err)
+	assertTestBatch(t, engine, large)
+
+	// and flushed from the mem table.
+	batch.Reset()
+	assert.Equal(t, 0, batch.Len())
+	batch.Put([]byte("key_3"), []byte("value_3"))
+	batch.Put([]byte("large_2"), large)
+	require.NoError(t, engine.WriteBatch(batch))
+	flushActiveMemTable(t, engine)
+	value, err = engine.dataStore.Get([]byte("key_3"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value_3"), value)
+	value, err = engine.dataStore.Get([]byte("large_2"))
+	require.NoError(t, err)
+	assert.Equal(t, large, value)
+
+	// history of the flushed batch is read from the index.
+	events, err = engine.History([]byte("large_2"), 0, math.MaxUint64, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, large, events[0].Value)
+	require.NoError(t, engine.Close(context.Background()))
+}
+
+func BenchmarkEngine_WriteBatch(b *testing.B) {
+	for _, size := range []int{4, 16, 64} {
+		values := make([][]byte, size)
+		for i := range values {
+			values[i] = []byte(gofakeit.LetterN(128))
+		}
+
+		b.Run(fmt.Sprintf("txn_%d", size), func(b *testing.B) {
+			engine, err := NewStorageEngine(b.TempDir(), "bench_txn", NewDefaultEngineConfig())
+			require.NoError(b, err)
+			b.Cleanup(func() {
+				_ = engine.Close(context.Background())
+			})
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				txn, err := engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+				require.NoError(b, err)
+				for i, value := range values {
+					require.NoError(b, txn.AppendKVTxn([]byte(fmt.Sprintf("key_%d_%d", n, i)), value))
+				}
+				require.NoError(b, txn.Commit())
+			}
+		})
+
+		b.Run(fmt.Sprintf("batch_%d", size), func(b *testing.B) {
+			engine, err := NewStorageEngine(b.TempDir(), "bench_batch", NewDefaultEngineConfig())
+			require.NoError(b, err)
+			b.Cleanup(func() {
+				_ = engine.Close(context.Background())
+			})
+			batch := NewBatch()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				batch.Reset()
+				for i, value := range values {
+					batch.Put([]byte(fmt.Sprintf("key_%d_%d", n, i)), value)
+				}
+				require.NoError(b, engine.WriteBatch(batch))
+			}
+		})
+	}
+}
+
+func TestEngine_WriteBatch_Errors(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	config.ArenaSize = minArenaSize
+	engine, err := NewStorageEngine(t.TempDir(), "test_write_batch_errors", config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+
+	assert.ErrorIs(t, engine.WriteBatch(NewBatch()), ErrEmptyBatch)
+	assert.ErrorIs(t, engine.WriteBatch(nil), ErrEmptyBatch)
+
+	batch := NewBatch()
+	batch.Put([]byte("key"), []byte("value"))
+	batch.SetColumnsInRow("user_row_key", nil)
+	assert.ErrorIs(t, engine.WriteBatch(batch), ErrEmptyColumns)
+
+	batch = NewBatch()
+	for i := 0; int64(i)*config.ValueThreshold < config.ArenaSize; i++ {
+		batch.Put([]byte(gofakeit.UUID()), []byte(gofakeit.LetterN(uint(config.ValueThreshold))))
+	}
+	assert.ErrorIs(t, engine.WriteBatch(batch), ErrBatchTooLarge)
+	assert.Equal(t, uint64(0), engine.LastLSN(), "failed batch should not be written")
+
+	// batch that doesn't fit in the rest of the active mem table is written to the next one.
+	for i := 0; i < 60; i++ {
+		require.NoError(t, engine.Put([]byte(gofakeit.UUID()), []byte(gofakeit.LetterN(uint(config.ValueThreshold)))))
+	}
+	batch = NewBatch()
+	keys := make([][]byte, 40)
+	for i := range keys {
+		keys[i] = []byte(gofakeit.UUID())
+		batch.Put(keys[i], []byte(gofakeit.LetterN(uint(config.ValueThreshold))))
+	}
+	require.NoError(t, engine.WriteBatch(batch))
+	for _, key := range keys {
+		assert.Equal(t, logOperationInsert, engine.activeMemTable.get(key).Meta, "batch should be in the same mem table")
+		_, err := engine.Get(key)
+		require.NoError(t, err)
+	}
+}
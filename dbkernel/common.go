@@ -3,8 +3,10 @@ package dbkernel
 import (
 	"errors"
 	"io"
+	"time"
 
 	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
+	"github.com/ankur-anand/unisondb/dbkernel/vfs"
 	"github.com/ankur-anand/unisondb/dbkernel/wal"
 	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
 )
@@ -21,25 +23,43 @@ var (
 	ErrBucketNotFound  = kvdrivers.ErrBucketNotFound
 	ErrRecordCorrupted = kvdrivers.ErrRecordCorrupted
 	ErrUseGetColumnAPI = kvdrivers.ErrUseGetColumnAPI
+	ErrInvalidRange    = kvdrivers.ErrInvalidRange
+	ErrValueChanged    = kvdrivers.ErrValueChanged
+	// ErrWalCorrupted is returned when the WAL is corrupted at a place other than the tail,
+	// see wal.RecoveryMode to salvage the WAL till the corruption point.
+	ErrWalCorrupted = wal.ErrCorrupted
 
 	ErrInCloseProcess   = errors.New("in-Close process")
 	ErrDatabaseDirInUse = errors.New("pid.lock is held by another process")
 	ErrInternalError    = errors.New("internal error")
 	ErrInvalidOffset    = errors.New("invalid offset")
+	// ErrBeforeRetentionHorizon is returned when a read at a past time needs changes the WAL doesn't have anymore.
+	ErrBeforeRetentionHorizon = errors.New("requested time is before the wal retention horizon")
+	ErrInvalidLSN             = errors.New("invalid lsn")
+	// ErrLSNNotRetained is returned when the record of the lsn is not present in the WAL anymore.
+	ErrLSNNotRetained = errors.New("lsn is before the first record retained in the wal")
 )
 
 var (
 	// Marks values stored directly in memory.
-	directValuePrefix  byte = 254
-	walReferencePrefix byte = 255 // Marks values stored as a reference in WAL
-	logOperationDelete      = byte(walrecord.LogOperationDelete)
-	logOperationInsert      = byte(walrecord.LogOperationInsert)
-	entryTypeRow            = byte(walrecord.EntryTypeRow)
+	directValuePrefix     byte = 254
+	walReferencePrefix    byte = 255 // Marks values stored as a reference in WAL
+	logOperationDelete         = byte(walrecord.LogOperationDelete)
+	logOperationInsert         = byte(walrecord.LogOperationInsert)
+	logOperationDeleteRow      = byte(walrecord.LogOperationDeleteRow)
+	entryTypeRow               = byte(walrecord.EntryTypeRow)
 )
 
 var (
 	sysKeyWalCheckPoint = []byte("sys.kv.alchemy.key.wal.checkpoint")
 	sysKeyBloomFilter   = []byte("sys.kv.alchemy.key.bloom-filter")
+	sysKeyHLC           = []byte("sys.kv.alchemy.key.hlc")
+	// sysKeyUpstreamOffset is the offset in the upstream wal a replica resumes the stream from.
+	sysKeyUpstreamOffset = []byte("sys.kv.alchemy.key.replica.upstream-offset")
+	// sysKeySnapshotLSN is the lsn of the last record in the snapshot a replica is restored from.
+	sysKeySnapshotLSN = []byte("sys.kv.alchemy.key.replica.snapshot-lsn")
+	// sysKeyEpochs is the first lsn of every epoch of the namespace.
+	sysKeyEpochs = []byte("sys.kv.alchemy.key.epochs")
 )
 
 var (
@@ -73,6 +93,10 @@ type BtreeWriter interface {
 type BtreeReader interface {
 	// Get retrieves a value associated with a key.
 	Get(key []byte) ([]byte, error)
+	// GetValueReader returns a reader that streams the value associated with a key.
+	GetValueReader(key []byte) (*kvdrivers.ChunkReader, error)
+	// GetRange retrieves length bytes of the value associated with a key starting at offset.
+	GetRange(key []byte, offset, length int64) ([]byte, error)
 	GetRowColumns(rowKey []byte, filter func([]byte) bool) (map[string][]byte, error)
 	// Snapshot writes the complete database to the provided io writer.
 	Snapshot(w io.Writer) error
@@ -87,11 +111,15 @@ type BTreeStore interface {
 }
 
 // DBEngine : which bTreeStore engine to use for the underlying persistence storage.
+// It's the name of a driver registered with kvdrivers.Register.
 type DBEngine string
 
 const (
-	BoltDBEngine DBEngine = "BOLT"
-	LMDBEngine   DBEngine = "LMDB"
+	BoltDBEngine DBEngine = kvdrivers.DriverBolt
+	LMDBEngine   DBEngine = kvdrivers.DriverLMDB
+	// InMemoryEngine keeps the materialized view of the WAL only in memory,
+	// it's rebuilt by replaying the complete WAL on every start.
+	InMemoryEngine DBEngine = kvdrivers.DriverMemory
 )
 
 // EngineConfig embeds all the config needed for Engine.
@@ -101,6 +129,36 @@ type EngineConfig struct {
 	WalConfig      wal.Config       `toml:"wal_config"`
 	BtreeConfig    kvdrivers.Config `toml:"btree_config"`
 	DBEngine       DBEngine         `toml:"db_engine"`
+	// DriverConfig is decoded by the DBEngine driver for its specific configuration.
+	DriverConfig kvdrivers.DriverConfig `toml:"driver_config"`
+	// FS is the filesystem the wal and the btree store are kept on, vfs.Default if nil.
+	// The wal is kept on WalConfig.FS instead, if it's set.
+	FS vfs.FS `toml:"-"`
+	// HistoryIndex keeps an on disk index of the changes of every key, used by Engine.History and Engine.GetAt.
+	// Without it the index is built in memory from the WAL on the first call, keeping only the VersionRetention.
+	HistoryIndex bool `toml:"history_index"`
+	// Replica makes the engine a read only follower of an upstream engine, it's only written
+	// by Engine.ApplyReplicated with the records streamed from the upstream WAL.
+	Replica bool `toml:"replica"`
+	// Replication is the replication config of the namespace, when the engine is the primary.
+	Replication ReplicationConfig `toml:"replication"`
+	// Consensus replicates the namespace by consensus, the WAL is the log whose records are applied
+	// once a majority of the nodes have them. The writes are only taken by the leader, see Engine.Lead,
+	// and wait for their commit till Replication.SyncTimeout. Txns are not supported.
+	Consensus bool `toml:"consensus"`
+	// MultiLeader takes the writes on every node of the namespace, they are exchanged with the peers
+	// by Engine.ApplyPeer and resolved per key, and per column of the rows, by the last writer wins.
+	// Txns are not supported.
+	MultiLeader bool `toml:"multi_leader"`
+	// NodeID is the unique id of the node in the multi leader namespace, the records written on it
+	// are tagged with it as their origin.
+	NodeID string `toml:"node_id"`
+	// MaxClockDrift is how far ahead of the wall clock a timestamp received from another node can be,
+	// DefaultMaxClockDrift if zero. Replicated records ahead of it still move the clock, peer records are rejected.
+	MaxClockDrift time.Duration `toml:"max_clock_drift"`
+	// VersionRetention is how far back Engine.GetAt and Engine.GetRowColumnsAt can read without the HistoryIndex,
+	// DefaultVersionRetention if zero, every change is kept in memory if negative.
+	VersionRetention time.Duration `toml:"version_retention"`
 }
 
 // NewDefaultEngineConfig returns an initialized default config for engine.
@@ -117,3 +175,11 @@ func NewDefaultEngineConfig() *EngineConfig {
 		DBEngine: LMDBEngine,
 	}
 }
+
+// walFS returns the filesystem the wal is kept on.
+func (c *EngineConfig) walFS() vfs.FS {
+	if c.WalConfig.FS != nil {
+		return c.WalConfig.FS
+	}
+	return vfs.OrDefault(c.FS)
+}
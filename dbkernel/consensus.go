@@ -0,0 +1,907 @@
+package dbkernel
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
+	"github.com/ankur-anand/unisondb/dbkernel/wal"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
+	"github.com/hashicorp/go-metrics"
+)
+
+var (
+	// ErrNotLeader is returned for the writes to a consensus namespace on a node that doesn't lead it.
+	ErrNotLeader = errors.New("node is not the leader of the namespace")
+	// ErrNotConsensus is returned by the consensus methods of an engine not opened in the consensus mode.
+	ErrNotConsensus = errors.New("namespace is not in consensus mode")
+	// ErrEntryDropped is returned for the writes removed from the log by a new leader before they were committed.
+	ErrEntryDropped = errors.New("write was dropped by a new leader before it was committed")
+	// ErrCommitTimeout is returned for the writes not committed by a majority of the nodes in time,
+	// the write may still be committed later.
+	ErrCommitTimeout = errors.New("timed out waiting for the write to be committed")
+	// ErrConsensusTxn is returned by NewTxn of a consensus namespace, only the single record writes
+	// and the batches are replicated by the consensus.
+	ErrConsensusTxn = errors.New("txns are not supported by the consensus namespaces")
+	// ErrLogNotEmpty is returned when a membership is bootstrapped or a snapshot is installed on a node
+	// that already has records in its log.
+	ErrLogNotEmpty = errors.New("consensus log is not empty")
+)
+
+var (
+	mKeyConsensusCommitTotal   = append(packageKey, "consensus", "commit", "total")
+	mKeyConsensusTruncateTotal = append(packageKey, "consensus", "truncate", "total")
+	mKeyConsensusSnapshotTotal = append(packageKey, "consensus", "snapshot", "install", "total")
+)
+
+var (
+	// sysKeyCommitLSN is the lsn of the last committed record of a consensus namespace.
+	sysKeyCommitLSN = []byte("sys.kv.alchemy.key.consensus.commit")
+	// sysKeyConsensusState is the state saved by the consensus of the node, like its term and vote.
+	sysKeyConsensusState = []byte("sys.kv.alchemy.key.consensus.state")
+	// sysKeyMemberships are the membership records of the log of a consensus namespace.
+	sysKeyMemberships = []byte("sys.kv.alchemy.key.consensus.memberships")
+)
+
+// Membership is the set of the nodes of a consensus namespace, the address of every node by its id.
+type Membership map[string]string
+
+// membershipEntry is the membership appended to the log at the lsn.
+type membershipEntry struct {
+	lsn     uint64
+	members Membership
+}
+
+// consensusState tracks the log of a namespace replicated by consensus. The WAL is the log, a record is
+// appended by the leader, replicated to the followers and written to the mem table once a majority
+// of the nodes have it.
+type consensusState struct {
+	// leading is set while the node leads the namespace, only the leader appends the writes.
+	leading atomic.Bool
+	// commitLSN is the lsn of the last committed record, every record till it is applied to the mem table.
+	commitLSN atomic.Uint64
+	// commitOffset is the offset of the committed record in the wal, nil if the wal doesn't have it.
+	commitOffset atomic.Pointer[wal.Offset]
+	// memberships are the membership records of the log with the latest last, guarded by the e.mu.
+	memberships []membershipEntry
+	timeout     time.Duration
+
+	mu sync.Mutex
+	// waiters are the writes waiting for their record to be committed, by the lsn.
+	waiters map[uint64]chan error
+	// applied is closed and replaced every time the commit moves.
+	applied chan struct{}
+}
+
+func newConsensusState(timeout time.Duration) *consensusState {
+	if timeout == 0 {
+		timeout = defaultSyncReplicasTimeout
+	}
+	return &consensusState{
+		timeout: timeout,
+		waiters: make(map[uint64]chan error),
+		applied: make(chan struct{}),
+	}
+}
+
+// register adds the waiter of the write with the lsn, before the e.mu is released.
+func (c *consensusState) register(lsn uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waiters[lsn] = make(chan error, 1)
+}
+
+// wait blocks till the write with the lsn is committed, dropped or the timeout.
+func (c *consensusState) wait(lsn uint64) error {
+	c.mu.Lock()
+	ch, ok := c.waiters[lsn]
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case err := <-ch:
+		return err
+	case <-timer.C:
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.waiters, lsn)
+	// resolved while taking the lock.
+	select {
+	case err := <-ch:
+		return err
+	default:
+	}
+	return ErrCommitTimeout
+}
+
+// committed returns the writes till the lsn and notifies the waiters of the commit.
+func (c *consensusState) committed(lsn uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for waiting, ch := range c.waiters {
+		if waiting <= lsn {
+			ch <- nil
+			delete(c.waiters, waiting)
+		}
+	}
+	close(c.applied)
+	c.applied = make(chan struct{})
+}
+
+// drop returns the writes from the lsn, once their records are truncated from the log.
+func (c *consensusState) drop(lsn uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for waiting, ch := range c.waiters {
+		if waiting >= lsn {
+			ch <- ErrEntryDropped
+			delete(c.waiters, waiting)
+		}
+	}
+}
+
+// watch returns a channel that is closed once the commit moves.
+func (c *consensusState) watch() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.applied
+}
+
+// latest returns the latest membership of the log, it's called with the e.mu held.
+func (c *consensusState) latest() membershipEntry {
+	if len(c.memberships) == 0 {
+		return membershipEntry{}
+	}
+	return c.memberships[len(c.memberships)-1]
+}
+
+// loadConsensusState loads the commit and the memberships of the namespace saved in the btree store.
+func (e *Engine) loadConsensusState() (*consensusState, error) {
+	state := newConsensusState(e.config.Replication.SyncTimeout)
+	data, err := e.dataStore.RetrieveMetadata(sysKeyCommitLSN)
+	if err != nil && !errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		if len(data) != 8 {
+			return nil, fmt.Errorf("invalid commit metadata of %d bytes: %w", len(data), ErrRecordCorrupted)
+		}
+		state.commitLSN.Store(binary.LittleEndian.Uint64(data))
+	}
+	state.memberships, err = retrieveMemberships(e.dataStore)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// saveCommitLSN saves the commit of the namespace, the records after it are not applied by the recovery.
+func (e *Engine) saveCommitLSN() error {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], e.consensus.commitLSN.Load())
+	return e.dataStore.StoreMetadata(sysKeyCommitLSN, buf[:])
+}
+
+// recoveredConsensus moves the log to the commit, for the records of the log only in the btree store, and
+// finds the offset of the committed record, it's called once the wal is recovered.
+func (e *Engine) recoveredConsensus() error {
+	commit := e.consensus.commitLSN.Load()
+	if commit > e.lastLSN.Load() {
+		e.lastLSN.Store(commit)
+	}
+	if commit == 0 {
+		return nil
+	}
+	offset, err := e.lsnIndex.lookup(commit)
+	if errors.Is(err, ErrLSNNotRetained) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	e.consensus.commitOffset.Store(offset)
+	return nil
+}
+
+// proposable returns ErrNotLeader for the writes to a consensus namespace on a node that doesn't lead it,
+// it's called with the e.mu held.
+func (e *Engine) proposable() error {
+	if e.consensus != nil && !e.consensus.leading.Load() {
+		return ErrNotLeader
+	}
+	return nil
+}
+
+// proposed registers the write appended at the offset to wait for its commit, its record is written to
+// the mem table once a majority of the nodes have it. It's called with the e.mu held.
+func (e *Engine) proposed(lsn uint64, offset *wal.Offset) {
+	e.consensus.register(lsn)
+	e.appendWatch.advance(offset)
+}
+
+// visibleOffset returns the offset of the last wal record the reads of the changes see, nil for every record.
+// The records of a consensus namespace after its commit are not visible yet.
+func (e *Engine) visibleOffset() *wal.Offset {
+	if e.consensus == nil {
+		return nil
+	}
+	if offset := e.consensus.commitOffset.Load(); offset != nil {
+		return offset
+	}
+	return &wal.Offset{}
+}
+
+// Leading reports if the node leads the consensus namespace.
+func (e *Engine) Leading() bool {
+	return e.consensus != nil && e.consensus.leading.Load()
+}
+
+// CommitLSN returns the lsn of the last committed record of the consensus namespace.
+func (e *Engine) CommitLSN() uint64 {
+	if e.consensus == nil {
+		return 0
+	}
+	return e.consensus.commitLSN.Load()
+}
+
+// Lead makes the node the leader of the consensus namespace for the term, the writes are appended with
+// the term as their epoch. A no-op record is appended first, the records of the earlier terms are committed with it.
+func (e *Engine) Lead(term uint64) error {
+	if e.shutdown.Load() {
+		return ErrInCloseProcess
+	}
+	if e.consensus == nil {
+		return ErrNotConsensus
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if term <= e.epoch.Load() {
+		return fmt.Errorf("%w: term %d, epoch %d", ErrStaleEpoch, term, e.epoch.Load())
+	}
+
+	lsn := e.nextLSN()
+	if err := e.startEpoch(term, lsn); err != nil {
+		return err
+	}
+	record := logcodec.LogRecord{
+		LSN:           lsn,
+		HLC:           e.hlc.Now(),
+		Epoch:         term,
+		OperationType: logrecord.LogOperationTypeNoOperation,
+		TxnState:      logrecord.TransactionStateNone,
+		EntryType:     logrecord.LogEntryTypeKV,
+	}
+	offset, err := e.walIO.Append(record.Encode())
+	if err != nil {
+		return errors.Join(err, e.trimEpochs(lsn))
+	}
+	e.appendedLSN(lsn, offset)
+	e.appendWatch.advance(offset)
+	e.consensus.leading.Store(true)
+	slog.Info("[kvalchemy.dbengine] leading the namespace", "namespace", e.namespace, "term", term, "lsn", lsn)
+	return nil
+}
+
+// StepDown stops the node from leading the consensus namespace, the writes waiting for their commit
+// still return once their records are committed or dropped.
+func (e *Engine) StepDown() {
+	if e.consensus == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.consensus.leading.CompareAndSwap(true, false) {
+		slog.Info("[kvalchemy.dbengine] stepped down from leading the namespace", "namespace", e.namespace,
+			"epoch", e.epoch.Load())
+	}
+}
+
+// AppendEntries appends the records sent by the leader after the record with the prevLSN and prevEpoch,
+// and syncs them. The node stops leading the namespace, if it does.
+//
+// If the log doesn't have the prev record, nothing is appended and retryLSN is the lsn the leader should
+// send the records from. The records of the log that conflict with the ones sent are truncated,
+// the committed records are never truncated.
+func (e *Engine) AppendEntries(prevLSN, prevEpoch uint64, entries [][]byte) (matched bool, retryLSN uint64, err error) {
+	if e.shutdown.Load() {
+		return false, 0, ErrInCloseProcess
+	}
+	if e.consensus == nil {
+		return false, 0, ErrNotConsensus
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.consensus.leading.Store(false)
+
+	commit := e.consensus.commitLSN.Load()
+	if last := e.lastLSN.Load(); prevLSN > last {
+		return false, last + 1, nil
+	}
+	// committed records are the same on every node.
+	if prevLSN > commit {
+		if start := e.epochAt(prevLSN); start.epoch != prevEpoch {
+			return false, max(start.lsn, commit+1), nil
+		}
+	}
+
+	appended := false
+	for i, data := range entries {
+		lsn := prevLSN + uint64(i) + 1
+		record, err := logcodec.DecodeRecord(data)
+		if err != nil {
+			return false, 0, err
+		}
+		if record.LSN != lsn {
+			return false, 0, fmt.Errorf("%w: entry lsn %d, expected %d", ErrInvalidLSN, record.LSN, lsn)
+		}
+		if lsn <= commit {
+			continue
+		}
+		if lsn <= e.lastLSN.Load() {
+			if e.epochAt(lsn).epoch == record.Epoch {
+				continue
+			}
+			if err := e.truncateLog(lsn); err != nil {
+				return false, 0, err
+			}
+		}
+		if err := e.appendEntry(record, data); err != nil {
+			return false, 0, err
+		}
+		appended = true
+	}
+
+	if appended {
+		if err := e.walIO.Sync(); err != nil {
+			return false, 0, err
+		}
+		e.appendWatch.advance(e.currentOffset.Load())
+	}
+	return true, 0, nil
+}
+
+// appendEntry appends the record sent by the leader to the log, it's called with the e.mu held.
+func (e *Engine) appendEntry(record *logcodec.LogRecord, data []byte) error {
+	if record.TxnState != logrecord.TransactionStateNone {
+		return fmt.Errorf("%w: lsn %d", ErrConsensusTxn, record.LSN)
+	}
+	switch current := e.epoch.Load(); {
+	case record.Epoch < current:
+		return fmt.Errorf("%w: record epoch %d at lsn %d, namespace epoch %d", ErrStaleEpoch, record.Epoch, record.LSN, current)
+	case record.Epoch > current:
+		if err := e.startEpoch(record.Epoch, record.LSN); err != nil {
+			return err
+		}
+	}
+	if record.OperationType == logrecord.LogOperationTypeMembership {
+		if err := e.addMembership(record.LSN, membershipOf(record)); err != nil {
+			return err
+		}
+	}
+
+	e.observeHLC(record.HLC)
+	e.writeSeenCounter.Add(uint64(max(1, record.BatchLen())))
+	offset, err := e.walIO.Append(data)
+	if err != nil {
+		return err
+	}
+	e.appendedLSN(record.LSN, offset)
+	return nil
+}
+
+// truncateLog removes the records from the lsn, that conflict with the log of the leader. It's called with the e.mu held.
+// The epochs and the memberships of the removed records are removed first, the recovery adds back the epochs
+// of the records still in the wal.
+func (e *Engine) truncateLog(lsn uint64) error {
+	if lsn <= e.consensus.commitLSN.Load() {
+		return fmt.Errorf("%w: truncate from committed lsn %d", ErrInvalidLSN, lsn)
+	}
+	offset, err := e.lsnIndex.lookup(lsn)
+	if err != nil {
+		return err
+	}
+	if err := e.trimEpochs(lsn); err != nil {
+		return err
+	}
+	if err := e.trimMemberships(lsn); err != nil {
+		return err
+	}
+	if err := e.walIO.Truncate(offset); err != nil {
+		return err
+	}
+	e.lsnIndex.truncate(lsn)
+
+	var current *wal.Offset
+	if lsn > 1 {
+		current, err = e.lsnIndex.lookup(lsn - 1)
+		if err != nil && !errors.Is(err, ErrLSNNotRetained) {
+			return err
+		}
+	}
+	slog.Warn("[kvalchemy.dbengine] truncated conflicting log records", "namespace", e.namespace,
+		"from_lsn", lsn, "last_lsn", e.lastLSN.Load())
+	metrics.IncrCounterWithLabels(mKeyConsensusTruncateTotal, 1, e.metricsLabel)
+	e.currentOffset.Store(current)
+	e.lastLSN.Store(lsn - 1)
+	e.consensus.drop(lsn)
+	return nil
+}
+
+// trimEpochs removes the epochs that start from the lsn, it's called with the e.mu held.
+func (e *Engine) trimEpochs(lsn uint64) error {
+	i := len(e.epochs)
+	for i > 0 && e.epochs[i-1].lsn >= lsn {
+		i--
+	}
+	if i == len(e.epochs) {
+		return nil
+	}
+	e.epochs = e.epochs[:i]
+	e.epoch.Store(0)
+	if i > 0 {
+		e.epoch.Store(e.epochs[i-1].epoch)
+	}
+	return e.saveEpochs()
+}
+
+// epochAt returns the start of the epoch the record with the lsn was appended in, it's called with the e.mu held.
+func (e *Engine) epochAt(lsn uint64) epochStart {
+	i := sort.Search(len(e.epochs), func(i int) bool {
+		return e.epochs[i].lsn > lsn
+	})
+	if i == 0 {
+		return epochStart{}
+	}
+	return e.epochs[i-1]
+}
+
+// EpochAt returns the epoch of the record with the lsn, the term of the leader that appended it.
+func (e *Engine) EpochAt(lsn uint64) uint64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.epochAt(lsn).epoch
+}
+
+// CommitTo commits the records till the lsn once a majority of the nodes have them, and applies
+// them to the mem table. The lsn past the last record of the log is moved back to it.
+func (e *Engine) CommitTo(lsn uint64) error {
+	if e.shutdown.Load() {
+		return ErrInCloseProcess
+	}
+	if e.consensus == nil {
+		return ErrNotConsensus
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	lsn = min(lsn, e.lastLSN.Load())
+	commit := e.consensus.commitLSN.Load()
+	if lsn <= commit {
+		return nil
+	}
+	offset, err := e.lsnIndex.lookup(commit + 1)
+	if err != nil {
+		return err
+	}
+	reader, err := e.walIO.NewReaderWithStart(offset)
+	if err != nil {
+		return err
+	}
+
+	for commit < lsn {
+		data, pos, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("commit lsn %d: %w", lsn, io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return err
+		}
+		record, err := logcodec.DecodeRecord(data)
+		if err != nil {
+			return err
+		}
+		if err := e.applyCommitted(record, data, pos); err != nil {
+			return err
+		}
+		commit = record.LSN
+		e.consensus.commitLSN.Store(commit)
+		e.consensus.commitOffset.Store(pos)
+		metrics.IncrCounterWithLabels(mKeyConsensusCommitTotal, 1, e.metricsLabel)
+	}
+	e.consensus.committed(commit)
+	return nil
+}
+
+// applyCommitted writes the committed record at the offset to the mem table, it's called with the e.mu held.
+func (e *Engine) applyCommitted(record *logcodec.LogRecord, data []byte, offset *wal.Offset) error {
+	switch {
+	case record.IsBatch():
+		entries, err := e.replicatedBatchEntries(record, offset)
+		if err != nil {
+			return err
+		}
+		return e.memTableWriteBatch(entries, offset)
+	case isDataOperation(record):
+		memValue := replicatedMemValue(record, data, offset.Encode(), e.config.ValueThreshold)
+		return e.memTableWrite(record.Key(), memValue, offset)
+	}
+	return nil
+}
+
+// Bootstrap writes the first membership of a new consensus namespace, every node of it is bootstrapped with
+// the same membership. The record is committed right away, as it's the record at lsn 1 in the epoch 1 on every node.
+func (e *Engine) Bootstrap(members Membership) error {
+	if e.shutdown.Load() {
+		return ErrInCloseProcess
+	}
+	if e.consensus == nil {
+		return ErrNotConsensus
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lastLSN.Load() != 0 {
+		return ErrLogNotEmpty
+	}
+
+	const lsn, epoch = 1, 1
+	if err := e.startEpoch(epoch, lsn); err != nil {
+		return err
+	}
+	if err := e.addMembership(lsn, members); err != nil {
+		return err
+	}
+	record := membershipRecord(lsn, e.hlc.Now(), epoch, members)
+	offset, err := e.walIO.Append(record.Encode())
+	if err != nil {
+		return err
+	}
+	e.appendedLSN(lsn, offset)
+	if err := e.walIO.Sync(); err != nil {
+		return err
+	}
+
+	e.consensus.commitLSN.Store(lsn)
+	e.consensus.commitOffset.Store(offset)
+	if err := e.saveCommitLSN(); err != nil {
+		return err
+	}
+	e.consensus.committed(lsn)
+	e.appendWatch.advance(offset)
+	return e.dataStore.FSync()
+}
+
+// Membership returns the latest membership of the consensus namespace in its log and the lsn of its record,
+// it's used by the nodes as soon as it's appended, before it's committed.
+func (e *Engine) Membership() (Membership, uint64) {
+	if e.consensus == nil {
+		return nil, 0
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	latest := e.consensus.latest()
+	return maps.Clone(latest.members), latest.lsn
+}
+
+// ChangeMembership appends the new membership of the consensus namespace on its leader,
+// and waits till it's committed.
+func (e *Engine) ChangeMembership(members Membership) (uint64, error) {
+	if e.consensus == nil {
+		return 0, ErrNotConsensus
+	}
+	if err := e.writable(); err != nil {
+		return 0, err
+	}
+	if len(members) == 0 {
+		return 0, errors.New("membership has no member")
+	}
+	lsn, err := e.writeMembership(members)
+	if err != nil {
+		return 0, err
+	}
+	return lsn, e.consensus.wait(lsn)
+}
+
+func (e *Engine) writeMembership(members Membership) (uint64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.proposable(); err != nil {
+		return 0, err
+	}
+
+	lsn := e.nextLSN()
+	if err := e.addMembership(lsn, members); err != nil {
+		return 0, err
+	}
+	record := membershipRecord(lsn, e.hlc.Now(), e.epoch.Load(), members)
+	offset, err := e.walIO.Append(record.Encode())
+	if err != nil {
+		return 0, errors.Join(err, e.trimMemberships(lsn))
+	}
+	e.appendedLSN(lsn, offset)
+	e.proposed(lsn, offset)
+	return lsn, nil
+}
+
+// addMembership saves the membership appended at the lsn, before its record is appended. It's called with the e.mu held.
+func (e *Engine) addMembership(lsn uint64, members Membership) error {
+	e.consensus.memberships = append(e.consensus.memberships, membershipEntry{lsn: lsn, members: members})
+	if err := e.saveMemberships(); err != nil {
+		e.consensus.memberships = e.consensus.memberships[:len(e.consensus.memberships)-1]
+		return err
+	}
+	return nil
+}
+
+// trimMemberships removes the memberships appended from the lsn, it's called with the e.mu held.
+func (e *Engine) trimMemberships(lsn uint64) error {
+	memberships := e.consensus.memberships
+	i := len(memberships)
+	for i > 0 && memberships[i-1].lsn >= lsn {
+		i--
+	}
+	if i == len(memberships) {
+		return nil
+	}
+	e.consensus.memberships = memberships[:i]
+	return e.saveMemberships()
+}
+
+func (e *Engine) saveMemberships() error {
+	if err := e.dataStore.StoreMetadata(sysKeyMemberships, encodeMemberships(e.consensus.memberships)); err != nil {
+		return err
+	}
+	return e.dataStore.FSync()
+}
+
+// membershipRecord returns the record of the membership, an entry for every node sorted by its id.
+func membershipRecord(lsn, hlc, epoch uint64, members Membership) logcodec.LogRecord {
+	entries := make([]logcodec.KeyValueEntry, 0, len(members))
+	for _, id := range slices.Sorted(maps.Keys(members)) {
+		entries = append(entries, logcodec.KeyValueEntry{Key: []byte(id), Value: []byte(members[id])})
+	}
+	return logcodec.LogRecord{
+		LSN:           lsn,
+		HLC:           hlc,
+		Epoch:         epoch,
+		OperationType: logrecord.LogOperationTypeMembership,
+		TxnState:      logrecord.TransactionStateNone,
+		EntryType:     logrecord.LogEntryTypeKV,
+		Payload: logcodec.LogOperationData{
+			KeyValueBatchEntries: &logcodec.KeyValueBatchEntries{Entries: entries},
+		},
+	}
+}
+
+// membershipOf returns the membership of the membership record.
+func membershipOf(record *logcodec.LogRecord) Membership {
+	members := make(Membership)
+	if record.Payload.KeyValueBatchEntries == nil {
+		return members
+	}
+	for _, entry := range record.Payload.KeyValueBatchEntries.Entries {
+		members[string(entry.Key)] = string(entry.Value)
+	}
+	return members
+}
+
+// encodeMemberships encodes every membership as its lsn, the number of its members and
+// the length prefixed id and address of every member.
+func encodeMemberships(memberships []membershipEntry) []byte {
+	var buf []byte
+	for _, entry := range memberships {
+		buf = binary.LittleEndian.AppendUint64(buf, entry.lsn)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(entry.members)))
+		for _, id := range slices.Sorted(maps.Keys(entry.members)) {
+			buf = appendLengthPrefixed(buf, id)
+			buf = appendLengthPrefixed(buf, entry.members[id])
+		}
+	}
+	return buf
+}
+
+func appendLengthPrefixed(buf []byte, s string) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(s)))
+	return append(buf, s...)
+}
+
+func decodeMemberships(data []byte) ([]membershipEntry, error) {
+	var memberships []membershipEntry
+	corrupted := fmt.Errorf("invalid memberships metadata of %d bytes: %w", len(data), ErrRecordCorrupted)
+	next := func(n int) ([]byte, bool) {
+		if len(data) < n {
+			return nil, false
+		}
+		b := data[:n]
+		data = data[n:]
+		return b, true
+	}
+	nextString := func() (string, bool) {
+		size, ok := next(4)
+		if !ok {
+			return "", false
+		}
+		b, ok := next(int(binary.LittleEndian.Uint32(size)))
+		return string(b), ok
+	}
+
+	for len(data) > 0 {
+		header, ok := next(12)
+		if !ok {
+			return nil, corrupted
+		}
+		entry := membershipEntry{
+			lsn:     binary.LittleEndian.Uint64(header),
+			members: make(Membership),
+		}
+		for range binary.LittleEndian.Uint32(header[8:]) {
+			id, ok := nextString()
+			if !ok {
+				return nil, corrupted
+			}
+			address, ok := nextString()
+			if !ok {
+				return nil, corrupted
+			}
+			entry.members[id] = address
+		}
+		memberships = append(memberships, entry)
+	}
+	return memberships, nil
+}
+
+func retrieveMemberships(store BTreeStore) ([]membershipEntry, error) {
+	data, err := store.RetrieveMetadata(sysKeyMemberships)
+	if errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeMemberships(data)
+}
+
+// ConsensusState returns the state saved with SaveConsensusState, nil if it's never saved.
+func (e *Engine) ConsensusState() ([]byte, error) {
+	data, err := e.dataStore.RetrieveMetadata(sysKeyConsensusState)
+	if errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// SaveConsensusState durably saves the state of the consensus of the node, like its term and its vote.
+func (e *Engine) SaveConsensusState(state []byte) error {
+	if err := e.dataStore.StoreMetadata(sysKeyConsensusState, state); err != nil {
+		return err
+	}
+	return e.dataStore.FSync()
+}
+
+// SnapshotLSN returns the lsn of the last committed record the btree store has, a snapshot of the btree store
+// taken after the call has every record till it. Zero if the btree store has no record yet.
+func (e *Engine) SnapshotLSN() (uint64, error) {
+	value, err := e.dataStore.RetrieveMetadata(sysKeyWalCheckPoint)
+	if err != nil && !errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return 0, err
+	}
+	if len(value) != 0 {
+		if checkpoint := UnmarshalMetadata(value).Pos; checkpoint.SegmentId != 0 {
+			data, err := e.walIO.Read(checkpoint)
+			if err != nil {
+				return 0, err
+			}
+			return logcodec.DecodeLSN(data)
+		}
+	}
+
+	// the btree store has every record before the first one in the wal.
+	reader, err := e.walIO.NewReader()
+	if err != nil {
+		return 0, err
+	}
+	data, _, err := reader.Next()
+	if errors.Is(err, io.EOF) {
+		return e.CommitLSN(), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	first, err := logcodec.DecodeLSN(data)
+	if err != nil {
+		return 0, err
+	}
+	return first - 1, nil
+}
+
+// InstallSnapshot replaces the btree store of a follower with the snapshot of the btree store of the leader,
+// that has every record till the lsn, see SnapshotLSN. The record at the lsn was appended in the epoch, and
+// the log continues after it. It's only allowed before the follower has any record in its log.
+func (e *Engine) InstallSnapshot(r io.Reader, lsn, epoch uint64) error {
+	if e.shutdown.Load() {
+		return ErrInCloseProcess
+	}
+	if e.consensus == nil {
+		return ErrNotConsensus
+	}
+	restorer, ok := e.dataStore.(btreeRestorer)
+	if !ok {
+		return ErrRestoreNotSupported
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lastLSN.Load() != 0 {
+		return ErrLogNotEmpty
+	}
+	// the term and the vote of the node are its own.
+	state, err := e.ConsensusState()
+	if err != nil {
+		return err
+	}
+	if err := restorer.Restore(r); err != nil {
+		return err
+	}
+
+	// checkpoint of the leader is not of the local wal, every record of the local wal is yet to be recovered.
+	if err := SaveMetadata(e.dataStore, &wal.Offset{}, 0); err != nil {
+		return err
+	}
+	if state != nil {
+		if err := e.dataStore.StoreMetadata(sysKeyConsensusState, state); err != nil {
+			return err
+		}
+	}
+	if err := e.loadHLC(); err != nil {
+		return err
+	}
+	if err := e.loadEpochs(); err != nil {
+		return err
+	}
+	if err := e.trimEpochs(lsn + 1); err != nil {
+		return err
+	}
+	if e.epoch.Load() < epoch {
+		if err := e.startEpoch(epoch, lsn); err != nil {
+			return err
+		}
+	}
+	if e.consensus.memberships, err = retrieveMemberships(e.dataStore); err != nil {
+		return err
+	}
+	if err := e.trimMemberships(lsn + 1); err != nil {
+		return err
+	}
+	if err := e.loadBloomFilter(); err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return err
+	}
+
+	e.lastLSN.Store(lsn)
+	e.consensus.commitLSN.Store(lsn)
+	e.consensus.commitOffset.Store(nil)
+	if err := e.saveCommitLSN(); err != nil {
+		return err
+	}
+	if err := e.dataStore.FSync(); err != nil {
+		return err
+	}
+	e.consensus.committed(lsn)
+	metrics.IncrCounterWithLabels(mKeyConsensusSnapshotTotal, 1, e.metricsLabel)
+	slog.Info("[kvalchemy.dbengine] snapshot installed", "namespace", e.namespace, "lsn", lsn, "epoch", epoch)
+	return nil
+}
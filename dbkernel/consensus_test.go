@@ -0,0 +1,346 @@
+package dbkernel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMembership = Membership{"a": "node-a:4001", "b": "node-b:4001", "c": "node-c:4001"}
+
+func newConsensusEngine(t *testing.T, dir string) *Engine {
+	t.Helper()
+	config := NewDefaultEngineConfig()
+	config.Consensus = true
+	config.Replication.SyncTimeout = 2 * time.Second
+	engine, err := NewStorageEngine(dir, "test_consensus", config)
+	require.NoError(t, err)
+	return engine
+}
+
+func closeEngine(t *testing.T, engine *Engine) {
+	t.Helper()
+	assert.NoError(t, engine.Close(context.Background()))
+}
+
+// logEntries returns the records of the log of the engine from the lsn.
+func logEntries(t *testing.T, engine *Engine, lsn uint64) [][]byte {
+	t.Helper()
+	reader, err := engine.NewReaderFromLSN(lsn)
+	require.NoError(t, err)
+	var entries [][]byte
+	for {
+		data, _, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		require.NoError(t, err)
+		entries = append(entries, data)
+	}
+}
+
+// putAsync puts the key on the leader, the returned channel has the error once the write returns.
+func putAsync(engine *Engine, key string) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- engine.Put([]byte(key), []byte("value"))
+	}()
+	return done
+}
+
+func waitLastLSN(t *testing.T, engine *Engine, lsn uint64) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		return engine.LastLSN() == lsn
+	}, 2*time.Second, 5*time.Millisecond)
+}
+
+func TestEngine_ConsensusCommit(t *testing.T) {
+	leader := newConsensusEngine(t, t.TempDir())
+	follower := newConsensusEngine(t, t.TempDir())
+	t.Cleanup(func() {
+		closeEngine(t, leader)
+		closeEngine(t, follower)
+	})
+
+	_, err := leader.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+	assert.ErrorIs(t, err, ErrConsensusTxn)
+	require.NoError(t, leader.Bootstrap(testMembership))
+	require.NoError(t, follower.Bootstrap(testMembership))
+	assert.ErrorIs(t, leader.Bootstrap(testMembership), ErrLogNotEmpty)
+	members, lsn := follower.Membership()
+	assert.Equal(t, testMembership, members)
+	assert.Equal(t, uint64(1), lsn)
+	assert.Equal(t, uint64(1), leader.CommitLSN())
+
+	assert.ErrorIs(t, leader.Put([]byte("key"), []byte("value")), ErrNotLeader)
+	require.NoError(t, leader.Lead(2))
+	assert.ErrorIs(t, leader.Lead(2), ErrStaleEpoch)
+	assert.True(t, leader.Leading())
+
+	done := putAsync(leader, "key")
+	waitLastLSN(t, leader, 3)
+	_, err = leader.Get([]byte("key"))
+	assert.ErrorIs(t, err, ErrKeyNotFound, "write is not visible before its commit")
+
+	matched, _, err := follower.AppendEntries(1, 1, logEntries(t, leader, 2))
+	require.NoError(t, err)
+	assert.True(t, matched)
+	assert.Equal(t, uint64(3), follower.LastLSN())
+	assert.Equal(t, uint64(2), follower.EpochAt(3))
+
+	require.NoError(t, leader.CommitTo(3))
+	require.NoError(t, <-done)
+	value, err := leader.Get([]byte("key"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	_, err = follower.Get([]byte("key"))
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	require.NoError(t, follower.WaitForLSN(context.Background(), time.Millisecond, 1))
+	assert.ErrorIs(t, follower.WaitForLSN(context.Background(), 10*time.Millisecond, 3), ErrWaitTimeoutExceeded)
+	require.NoError(t, follower.CommitTo(10))
+	assert.Equal(t, uint64(3), follower.CommitLSN())
+	require.NoError(t, follower.WaitForLSN(context.Background(), time.Millisecond, 3))
+	value, err = follower.Get([]byte("key"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	t.Run("batch", func(t *testing.T) {
+		batch := NewBatch()
+		batch.Put([]byte("batch_1"), []byte("value"))
+		batch.Delete([]byte("key"))
+		done := make(chan error, 1)
+		go func() {
+			done <- leader.WriteBatch(batch)
+		}()
+		waitLastLSN(t, leader, 4)
+		matched, _, err := follower.AppendEntries(3, 2, logEntries(t, leader, 4))
+		require.NoError(t, err)
+		assert.True(t, matched)
+		require.NoError(t, leader.CommitTo(4))
+		require.NoError(t, <-done)
+		require.NoError(t, follower.CommitTo(4))
+
+		for _, engine := range []*Engine{leader, follower} {
+			_, err := engine.Get([]byte("key"))
+			assert.ErrorIs(t, err, ErrKeyNotFound)
+			value, err := engine.Get([]byte("batch_1"))
+			require.NoError(t, err)
+			assert.Equal(t, []byte("value"), value)
+		}
+	})
+
+	t.Run("commit_timeout", func(t *testing.T) {
+		leader.consensus.timeout = 20 * time.Millisecond
+		defer func() {
+			leader.consensus.timeout = 2 * time.Second
+		}()
+		assert.ErrorIs(t, leader.Put([]byte("late"), []byte("value")), ErrCommitTimeout)
+	})
+}
+
+func TestEngine_ConsensusConflict(t *testing.T) {
+	nodeA := newConsensusEngine(t, t.TempDir())
+	nodeB := newConsensusEngine(t, t.TempDir())
+	nodeC := newConsensusEngine(t, t.TempDir())
+	t.Cleanup(func() {
+		closeEngine(t, nodeA)
+		closeEngine(t, nodeB)
+		closeEngine(t, nodeC)
+	})
+	for _, engine := range []*Engine{nodeA, nodeB, nodeC} {
+		require.NoError(t, engine.Bootstrap(testMembership))
+	}
+
+	// a leads the term 2, but its writes never reach the other nodes.
+	require.NoError(t, nodeA.Lead(2))
+	dropped := putAsync(nodeA, "dropped")
+	waitLastLSN(t, nodeA, 3)
+	changed := make(chan error, 1)
+	go func() {
+		_, err := nodeA.ChangeMembership(Membership{"a": "node-a:4001"})
+		changed <- err
+	}()
+	waitLastLSN(t, nodeA, 4)
+	members, lsn := nodeA.Membership()
+	assert.Len(t, members, 1)
+	assert.Equal(t, uint64(4), lsn)
+
+	require.NoError(t, nodeB.Lead(3))
+	committed := putAsync(nodeB, "committed")
+	waitLastLSN(t, nodeB, 3)
+
+	matched, retry, err := nodeC.AppendEntries(3, 3, nil)
+	require.NoError(t, err)
+	assert.False(t, matched)
+	assert.Equal(t, uint64(2), retry, "c has no record after the bootstrap")
+
+	matched, retry, err = nodeA.AppendEntries(3, 3, nil)
+	require.NoError(t, err)
+	assert.False(t, matched)
+	assert.Equal(t, uint64(2), retry, "a has the record at lsn 3 from the epoch 2")
+	assert.False(t, nodeA.Leading(), "a stops leading once it hears from b")
+
+	entries := logEntries(t, nodeB, 2)
+	for _, engine := range []*Engine{nodeA, nodeC} {
+		matched, _, err := engine.AppendEntries(1, 1, entries)
+		require.NoError(t, err)
+		assert.True(t, matched)
+		assert.Equal(t, uint64(3), engine.LastLSN())
+		assert.Equal(t, uint64(3), engine.EpochAt(3))
+		assert.Equal(t, uint64(3), engine.Epoch())
+	}
+	assert.ErrorIs(t, <-dropped, ErrEntryDropped)
+	assert.ErrorIs(t, <-changed, ErrEntryDropped)
+	members, lsn = nodeA.Membership()
+	assert.Equal(t, testMembership, members, "membership of the truncated record is removed")
+	assert.Equal(t, uint64(1), lsn)
+
+	require.NoError(t, nodeB.CommitTo(3))
+	require.NoError(t, <-committed)
+	require.NoError(t, nodeA.CommitTo(3))
+	value, err := nodeA.Get([]byte("committed"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+	_, err = nodeA.Get([]byte("dropped"))
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	// the records already in the log are skipped, and the committed ones are never truncated.
+	matched, _, err = nodeA.AppendEntries(1, 1, entries)
+	require.NoError(t, err)
+	assert.True(t, matched)
+	assert.Equal(t, uint64(3), nodeA.LastLSN())
+	assert.ErrorIs(t, nodeA.truncateLog(3), ErrInvalidLSN)
+}
+
+func TestEngine_ConsensusRecovery(t *testing.T) {
+	leader := newConsensusEngine(t, t.TempDir())
+	t.Cleanup(func() {
+		closeEngine(t, leader)
+	})
+	require.NoError(t, leader.Bootstrap(testMembership))
+	require.NoError(t, leader.Lead(2))
+
+	followerDir := t.TempDir()
+	follower := newConsensusEngine(t, followerDir)
+	require.NoError(t, follower.Bootstrap(testMembership))
+
+	for i, key := range []string{"committed", "uncommitted"} {
+		done := putAsync(leader, key)
+		waitLastLSN(t, leader, uint64(i+3))
+		require.NoError(t, leader.CommitTo(uint64(i+3)))
+		require.NoError(t, <-done)
+	}
+	matched, _, err := follower.AppendEntries(1, 1, logEntries(t, leader, 2))
+	require.NoError(t, err)
+	assert.True(t, matched)
+	require.NoError(t, follower.CommitTo(3))
+	require.NoError(t, follower.SaveConsensusState([]byte("term=2")))
+	closeEngine(t, follower)
+
+	follower = newConsensusEngine(t, followerDir)
+	t.Cleanup(func() {
+		closeEngine(t, follower)
+	})
+	assert.Equal(t, uint64(4), follower.LastLSN())
+	assert.Equal(t, uint64(3), follower.CommitLSN())
+	assert.Equal(t, uint64(2), follower.Epoch())
+	state, err := follower.ConsensusState()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("term=2"), state)
+	members, _ := follower.Membership()
+	assert.Equal(t, testMembership, members)
+
+	_, err = follower.Get([]byte("committed"))
+	require.NoError(t, err)
+	_, err = follower.Get([]byte("uncommitted"))
+	assert.ErrorIs(t, err, ErrKeyNotFound, "records after the commit are not recovered")
+	events, err := follower.History([]byte("uncommitted"), 0, follower.Now(), 0)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	require.NoError(t, follower.CommitTo(4))
+	_, err = follower.Get([]byte("uncommitted"))
+	require.NoError(t, err)
+	events, err = follower.History([]byte("uncommitted"), 0, follower.Now(), 0)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+}
+
+func TestEngine_ConsensusInstallSnapshot(t *testing.T) {
+	leader := newConsensusEngine(t, t.TempDir())
+	t.Cleanup(func() {
+		closeEngine(t, leader)
+	})
+	require.NoError(t, leader.Bootstrap(testMembership))
+	require.NoError(t, leader.Lead(2))
+	for i, key := range []string{"key_1", "key_2", "key_3"} {
+		done := putAsync(leader, key)
+		waitLastLSN(t, leader, uint64(i+3))
+		require.NoError(t, leader.CommitTo(uint64(i+3)))
+		require.NoError(t, <-done)
+	}
+	require.NoError(t, leader.SaveConsensusState([]byte("leader")))
+
+	snapshotLSN, err := leader.SnapshotLSN()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), snapshotLSN)
+	flushMemTable(t, leader)
+	snapshotLSN, err = leader.SnapshotLSN()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), snapshotLSN)
+
+	followerDir := t.TempDir()
+	follower := newConsensusEngine(t, followerDir)
+	require.NoError(t, follower.SaveConsensusState([]byte("follower")))
+	r, w := io.Pipe()
+	go func() {
+		_, err := leader.BtreeSnapshot(w)
+		_ = w.CloseWithError(err)
+	}()
+	require.NoError(t, follower.InstallSnapshot(r, snapshotLSN, leader.EpochAt(snapshotLSN)))
+	assert.ErrorIs(t, follower.InstallSnapshot(nil, snapshotLSN, 2), ErrLogNotEmpty)
+
+	assert.Equal(t, snapshotLSN, follower.LastLSN())
+	assert.Equal(t, snapshotLSN, follower.CommitLSN())
+	assert.Equal(t, uint64(2), follower.EpochAt(snapshotLSN))
+	members, _ := follower.Membership()
+	assert.Equal(t, testMembership, members)
+	state, err := follower.ConsensusState()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("follower"), state)
+	value, err := follower.Get([]byte("key_3"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	done := putAsync(leader, "key_4")
+	waitLastLSN(t, leader, 6)
+	matched, _, err := follower.AppendEntries(snapshotLSN, leader.EpochAt(snapshotLSN), logEntries(t, leader, 6))
+	require.NoError(t, err)
+	assert.True(t, matched)
+	require.NoError(t, leader.CommitTo(6))
+	require.NoError(t, <-done)
+	require.NoError(t, follower.CommitTo(6))
+	closeEngine(t, follower)
+
+	follower = newConsensusEngine(t, followerDir)
+	t.Cleanup(func() {
+		closeEngine(t, follower)
+	})
+	assert.Equal(t, uint64(6), follower.LastLSN())
+	for _, key := range []string{"key_1", "key_4"} {
+		_, err := follower.Get([]byte(key))
+		require.NoError(t, err)
+	}
+	// the recovery applied the committed record to the btree store.
+	snapshotLSN, err = follower.SnapshotLSN()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), snapshotLSN)
+}
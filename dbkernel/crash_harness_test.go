@@ -0,0 +1,247 @@
+package dbkernel
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/vfs/faultfs"
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// crashHarness runs the engine with its wal on a faultfs.FS and tracks every write the engine acknowledged,
+// so once the engine is reopened after a simulated crash, what it exposes can be verified.
+type crashHarness struct {
+	t      *testing.T
+	rnd    *rand.Rand
+	fs     *faultfs.FS
+	dir    string
+	config *EngineConfig
+	engine *Engine
+
+	// acked is the value of the key as of the last acknowledged write, nil if it was deleted.
+	acked map[string][]byte
+	// ambiguous are the values of writes that failed, after a crash the key can have any of them.
+	ambiguous map[string][][]byte
+	keys      []string
+}
+
+func newCrashHarness(t *testing.T, seed uint64, dbEngine DBEngine) *crashHarness {
+	t.Helper()
+	config := NewDefaultEngineConfig()
+	// btree stores need the os filesystem, they are on the disk and only lose what the process didn't write.
+	// the wal loses every write it didn't sync. In memory btree store is recovered from the wal completely.
+	config.WalConfig.FS = faultfs.New()
+	config.DBEngine = dbEngine
+	// small arena, so the mem table gets flushed while the writes are going on.
+	config.ArenaSize = minArenaSize
+	config.ValueThreshold = 256
+	// every acknowledged write should be on the disk.
+	config.WalConfig.FSync = true
+	config.WalConfig.SegmentSize = 256 * 1024
+
+	h := &crashHarness{
+		t:         t,
+		rnd:       rand.New(rand.NewPCG(seed, seed)),
+		fs:        config.WalConfig.FS.(*faultfs.FS),
+		dir:       t.TempDir(),
+		config:    config,
+		acked:     make(map[string][]byte),
+		ambiguous: make(map[string][][]byte),
+	}
+	for i := range 30 {
+		h.keys = append(h.keys, fmt.Sprintf("key_%d", i))
+	}
+	h.open()
+	t.Cleanup(func() {
+		assert.NoError(t, h.engine.Close(context.Background()))
+	})
+	return h
+}
+
+func (h *crashHarness) open() {
+	h.t.Helper()
+	engine, err := NewStorageEngine(h.dir, "crash_harness", h.config)
+	require.NoError(h.t, err, "engine should open after a crash")
+	h.engine = engine
+}
+
+func (h *crashHarness) key() string {
+	return h.keys[h.rnd.IntN(len(h.keys))]
+}
+
+func (h *crashHarness) value() []byte {
+	// some values are larger than the ValueThreshold and are read back from the wal.
+	value := make([]byte, 1+h.rnd.IntN(600))
+	for i := range value {
+		value[i] = byte('a' + h.rnd.IntN(26))
+	}
+	return value
+}
+
+// record updates the model with the result of a write of the value to the key.
+func (h *crashHarness) record(key string, value []byte, err error) {
+	if err != nil {
+		h.ambiguous[key] = append(h.ambiguous[key], value)
+		return
+	}
+	h.acked[key] = value
+	delete(h.ambiguous, key)
+}
+
+// step performs one random operation on the engine.
+func (h *crashHarness) step() {
+	h.t.Helper()
+	switch op := h.rnd.IntN(10); {
+	case op < 5:
+		key, value := h.key(), h.value()
+		h.record(key, value, h.engine.Put([]byte(key), value))
+	case op < 7:
+		key := h.key()
+		h.record(key, nil, h.engine.Delete([]byte(key)))
+	default:
+		h.txn(op < 9)
+	}
+}
+
+// txn writes few keys in a transaction, which is only committed if commit is true.
+func (h *crashHarness) txn(commit bool) {
+	h.t.Helper()
+	txn, err := h.engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+	if err != nil {
+		return
+	}
+
+	writes := make(map[string][]byte)
+	for range 1 + h.rnd.IntN(4) {
+		key, value := h.key(), h.value()
+		if err := txn.AppendKVTxn([]byte(key), value); err != nil {
+			return
+		}
+		writes[key] = value
+	}
+	// uncommitted transaction should never be visible, so the model is not touched.
+	if !commit {
+		return
+	}
+
+	err = txn.Commit()
+	for key, value := range writes {
+		h.record(key, value, err)
+	}
+}
+
+// crash simulates a machine crash and reopens the engine.
+// The engine is stopped the way a killed process would be, nothing is flushed or synced.
+func (h *crashHarness) crash(torn bool) {
+	h.t.Helper()
+	if torn {
+		h.fs.CrashTorn(h.rnd)
+	} else {
+		h.fs.Crash()
+	}
+
+	e := h.engine
+	e.shutdown.Store(true)
+	e.cancel()
+	require.True(h.t, waitWithTimeout(e.wg, 10*time.Second), "background routine should stop")
+	// handles were invalidated by the crash, only the resources are released.
+	_ = e.walIO.Close()
+	_ = e.dataStore.Close()
+	e.unlockFile()
+
+	h.open()
+}
+
+// verify checks every acknowledged write is present, and nothing else is.
+func (h *crashHarness) verify() {
+	h.t.Helper()
+	for _, key := range h.keys {
+		value, err := h.engine.Get([]byte(key))
+		if err != nil {
+			require.ErrorIs(h.t, err, ErrKeyNotFound, "key %s", key)
+			value = nil
+		}
+
+		expected := append([][]byte{h.acked[key]}, h.ambiguous[key]...)
+		found := slices.ContainsFunc(expected, func(v []byte) bool {
+			return string(v) == string(value)
+		})
+		require.True(h.t, found, "key %s has value %q, that was never acknowledged", key, value)
+
+		// whatever survived is the new acknowledged state.
+		h.acked[key] = value
+		delete(h.ambiguous, key)
+	}
+}
+
+var crashHarnessEngines = []DBEngine{InMemoryEngine, BoltDBEngine, LMDBEngine}
+
+func TestCrashHarness_CleanCrash(t *testing.T) {
+	for _, dbEngine := range crashHarnessEngines {
+		for seed := range uint64(5) {
+			t.Run(fmt.Sprintf("%s/seed_%d", dbEngine, seed), func(t *testing.T) {
+				h := newCrashHarness(t, seed, dbEngine)
+				for range 5 {
+					for range 50 + h.rnd.IntN(100) {
+						h.step()
+					}
+					h.crash(false)
+					h.verify()
+				}
+			})
+		}
+	}
+}
+
+func TestCrashHarness_TornWrite(t *testing.T) {
+	for _, dbEngine := range crashHarnessEngines {
+		for seed := range uint64(10) {
+			t.Run(fmt.Sprintf("%s/seed_%d", dbEngine, seed), func(t *testing.T) {
+				h := newCrashHarness(t, seed, dbEngine)
+				for range 5 {
+					for range 50 + h.rnd.IntN(100) {
+						h.step()
+					}
+
+					// writes that can't be synced are never acknowledged,
+					// and are torn at a random byte by the crash.
+					h.fs.FailSync(faultfs.ErrInjected)
+					for range 1 + h.rnd.IntN(5) {
+						h.step()
+					}
+					h.crash(true)
+					h.verify()
+				}
+			})
+		}
+	}
+}
+
+func TestCrashHarness_FailedSync(t *testing.T) {
+	h := newCrashHarness(t, 42, BoltDBEngine)
+	for range 50 {
+		h.step()
+	}
+
+	h.fs.FailSync(faultfs.ErrInjected)
+	err := h.engine.Put([]byte("failed_key"), []byte("failed_value"))
+	assert.ErrorIs(t, err, faultfs.ErrInjected, "fsync failure should be returned to the writer")
+	_, err = h.engine.Get([]byte("failed_key"))
+	assert.ErrorIs(t, err, ErrKeyNotFound, "unacknowledged write should not be visible")
+
+	h.fs.FailSync(nil)
+	for range 50 {
+		h.step()
+	}
+	h.crash(false)
+	assert.FileExists(t, filepath.Join(h.dir, "crash_harness", dbFileName))
+	assert.Less(t, h.engine.RecoveredWALCount(), int(h.engine.LastLSN()), "only the wal after the checkpoint should be recovered")
+	h.verify()
+}
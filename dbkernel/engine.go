@@ -3,8 +3,10 @@ package dbkernel
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"log"
 	"log/slog"
 	"os"
@@ -15,8 +17,11 @@ import (
 	"time"
 
 	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
+	"github.com/ankur-anand/unisondb/dbkernel/vfs"
 	"github.com/ankur-anand/unisondb/dbkernel/wal"
 	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
 	"github.com/bits-and-blooms/bloom/v3"
 	"github.com/dgraph-io/badger/v4/y"
 	"github.com/dustin/go-humanize"
@@ -41,6 +46,7 @@ var (
 	mKeySealedMemFlushRecordTotal = append(packageKey, "sealed", "mem", "table", "flush", "record", "total")
 	mKeyWalRecoveryDuration       = append(packageKey, "wal", "recovery", "durations", "seconds")
 	mKeyWalRecoveryRecordTotal    = append(packageKey, "wal", "recovery", "record", "total")
+	mKeyHLCDriftTotal             = append(packageKey, "hlc", "drift", "total")
 )
 
 var (
@@ -53,6 +59,7 @@ type Engine struct {
 	mu                sync.RWMutex
 	writeSeenCounter  atomic.Uint64
 	opsFlushedCounter atomic.Uint64
+	lastLSN           atomic.Uint64
 	currentOffset     atomic.Pointer[wal.Offset]
 	namespace         string
 	dataStore         BTreeStore
@@ -62,6 +69,10 @@ type Engine struct {
 	fileLock          *flock.Flock
 	wg                *sync.WaitGroup
 	bloom             *bloom.BloomFilter
+	hlc               *HLC
+	versions          *versionIndex
+	history           *historyIndex
+	lsnIndex          *lsnIndex
 	activeMemTable    *memTable
 	sealedMemTables   []*memTable
 	flushReqSignal    chan struct{}
@@ -72,9 +83,25 @@ type Engine struct {
 	startMetadata         Metadata
 	shutdown              atomic.Bool
 
-	// uses a cond broadcast for notification.
-	notifierMu sync.RWMutex
-	notifier   *sync.Cond
+	// notifies the waiters of the new appends.
+	appendWatch *offsetWatch
+
+	// replica is set for the engines that follow an upstream engine.
+	replica *replicaState
+	// following is set while the replica is set, so it's checked without the e.mu.
+	following atomic.Bool
+	// epoch is the current epoch of the namespace, the records are written with it.
+	epoch atomic.Uint64
+	// epochs are the start of every epoch of the namespace, guarded by the e.mu.
+	epochs []epochStart
+	// openTxns has the lsn of the begin record of the txns not committed yet, by the txn id.
+	openTxns map[string]uint64
+	// syncReplicas is set for the engines whose writes wait for the followers to make them durable.
+	syncReplicas *syncReplicas
+	// consensus is set for the namespaces replicated by consensus, the writes are applied once committed.
+	consensus *consensusState
+	// lww is set for the multi leader namespaces, it has the versions the peer writes are resolved against.
+	lww *lwwIndex
 
 	// used only during testing
 	callback func()
@@ -84,7 +111,6 @@ type Engine struct {
 
 // NewStorageEngine initializes WAL, MemTable, and BtreeStore and returns an initialized Engine for a namespace.
 func NewStorageEngine(dataDir, namespace string, conf *EngineConfig) (*Engine, error) {
-	initMonotonic()
 	label := []metrics.Label{{Name: "namespace", Value: namespace}}
 	signal := make(chan struct{}, 2)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -92,6 +118,8 @@ func NewStorageEngine(dataDir, namespace string, conf *EngineConfig) (*Engine, e
 		namespace:       namespace,
 		config:          conf,
 		wg:              &sync.WaitGroup{},
+		hlc:             NewHLC(),
+		versions:        newVersionIndex(DefaultVersionRetention),
 		metricsLabel:    label,
 		flushReqSignal:  signal,
 		pendingMetadata: &pendingMetadata{pendingMetadataWrites: make([]*flushedMetadata, 0)},
@@ -99,6 +127,26 @@ func NewStorageEngine(dataDir, namespace string, conf *EngineConfig) (*Engine, e
 		cancel:          cancel,
 		callback:        func() {},
 		fsyncReqSignal:  make(chan struct{}, 1),
+		openTxns:        make(map[string]uint64),
+	}
+
+	if conf.Consensus && conf.Replica {
+		return nil, errors.New("consensus namespace can't be a replica")
+	}
+	if conf.Consensus && conf.DBEngine == InMemoryEngine {
+		return nil, errors.New("consensus namespace needs a persistent btree store")
+	}
+	if conf.MultiLeader && (conf.Replica || conf.Consensus) {
+		return nil, errors.New("multi leader namespace can't be a replica or replicated by consensus")
+	}
+	if conf.MultiLeader && conf.NodeID == "" {
+		return nil, errors.New("multi leader namespace needs a node id")
+	}
+	if conf.MaxClockDrift > 0 {
+		engine.hlc.SetMaxDrift(conf.MaxClockDrift)
+	}
+	if conf.VersionRetention != 0 {
+		engine.versions = newVersionIndex(conf.VersionRetention)
 	}
 
 	if err := engine.initStorage(dataDir, namespace, conf); err != nil {
@@ -112,11 +160,36 @@ func NewStorageEngine(dataDir, namespace string, conf *EngineConfig) (*Engine, e
 		return nil, err
 	}
 
+	if conf.Consensus {
+		consensus, err := engine.loadConsensusState()
+		if err != nil {
+			return nil, err
+		}
+		engine.consensus = consensus
+	}
+
 	if err := engine.recoverWAL(); err != nil {
 		return nil, err
 	}
+	if engine.consensus != nil {
+		if err := engine.recoveredConsensus(); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.Replica {
+		replica, err := engine.loadReplicaState()
+		if err != nil {
+			return nil, err
+		}
+		engine.replica = replica
+		engine.following.Store(true)
+	}
+	if conf.Replication.SyncReplicas > 0 && !conf.Replica && !conf.Consensus {
+		engine.syncReplicas = newSyncReplicas(conf.Replication, label)
+	}
 
-	engine.notifier = sync.NewCond(&engine.notifierMu)
+	engine.appendWatch = newOffsetWatch(&engine.currentOffset)
 
 	return engine, nil
 }
@@ -127,48 +200,87 @@ func (e *Engine) initStorage(dataDir, namespace string, conf *EngineConfig) erro
 	walDir := filepath.Join(nsDir, walDirName)
 	dbFile := filepath.Join(nsDir, dbFileName)
 
-	if _, err := os.Stat(nsDir); err != nil {
-		if err := os.MkdirAll(nsDir, os.ModePerm); err != nil {
+	fs := vfs.OrDefault(conf.FS)
+	if _, err := fs.Stat(nsDir); err != nil {
+		if err := fs.MkdirAll(nsDir, os.ModePerm); err != nil {
 			return err
 		}
 	}
 
-	if _, err := os.Stat(walDir); err != nil {
-		if err := os.MkdirAll(walDir, os.ModePerm); err != nil {
+	walFS := conf.walFS()
+	if _, err := walFS.Stat(walDir); err != nil {
+		if err := walFS.MkdirAll(walDir, os.ModePerm); err != nil {
 			return err
 		}
 	}
 
-	fileLock := flock.New(filepath.Join(nsDir, pidLockName))
-	if err := tryFileLock(fileLock); err != nil {
-		return err
+	// the lock file can only be held on the os filesystem.
+	if vfs.IsDefault(fs) {
+		fileLock := flock.New(filepath.Join(nsDir, pidLockName))
+		if err := tryFileLock(fileLock); err != nil {
+			return err
+		}
+		e.fileLock = fileLock
 	}
-	e.fileLock = fileLock
 
-	walIO, err := wal.NewWalIO(walDir, namespace, &conf.WalConfig, metrics.Default())
+	walConfig := conf.WalConfig
+	walConfig.FS = walFS
+	walIO, err := wal.NewWalIO(walDir, namespace, &walConfig, metrics.Default())
 	if err != nil {
+		// a corrupted wal can be reopened with another recovery mode.
+		e.unlockFile()
 		return err
 	}
 	e.walIO = walIO
+	e.lsnIndex = newLSNIndex(walIO)
+
+	bTreeStore, err := kvdrivers.Open(string(conf.DBEngine), kvdrivers.Options{
+		Path:         dbFile,
+		Config:       conf.BtreeConfig,
+		DriverConfig: conf.DriverConfig,
+		FS:           fs,
+	})
+	if errors.Is(err, kvdrivers.ErrDriverNotRegistered) {
+		return fmt.Errorf("unsupported database engine %s: %w", conf.DBEngine, err)
+	}
+	if err != nil {
+		return err
+	}
+	e.dataStore = bTreeStore
 
-	var bTreeStore BTreeStore
-	switch conf.DBEngine {
-	case BoltDBEngine:
-		db, err := kvdrivers.NewBoltdb(dbFile, conf.BtreeConfig)
+	if conf.HistoryIndex {
+		historyStore, err := kvdrivers.Open(string(conf.DBEngine), kvdrivers.Options{
+			Path:         filepath.Join(nsDir, historyFileName),
+			Config:       conf.BtreeConfig,
+			DriverConfig: conf.DriverConfig,
+			FS:           fs,
+		})
 		if err != nil {
-			return err
+			return fmt.Errorf("open history index failed: %w", err)
 		}
-		bTreeStore = db
-	case LMDBEngine:
-		db, err := kvdrivers.NewLmdb(dbFile, conf.BtreeConfig)
+		history, err := openHistoryIndex(historyStore, walIO)
 		if err != nil {
-			return err
+			return fmt.Errorf("open history index failed: %w", err)
 		}
-		bTreeStore = db
-	default:
-		return fmt.Errorf("unsupported database engine %s", conf.DBEngine)
+		e.history = history
+	}
+
+	if conf.MultiLeader {
+		lwwStore, err := kvdrivers.Open(string(conf.DBEngine), kvdrivers.Options{
+			Path:         filepath.Join(nsDir, lwwFileName),
+			Config:       conf.BtreeConfig,
+			DriverConfig: conf.DriverConfig,
+			FS:           fs,
+		})
+		if err != nil {
+			return fmt.Errorf("open lww index failed: %w", err)
+		}
+		lww, err := openLWWIndex(lwwStore, walIO)
+		if err != nil {
+			return fmt.Errorf("open lww index failed: %w", err)
+		}
+		e.lww = lww
 	}
-	e.dataStore = bTreeStore
 
 	// skip list itself needs few bytes for initialization
 	// and, we don't want to keep on trashing writing to btreeStore often.
@@ -183,6 +295,12 @@ func (e *Engine) initStorage(dataDir, namespace string, conf *EngineConfig) erro
 	return nil
 }
 
+func (e *Engine) unlockFile() {
+	if e.fileLock != nil {
+		_ = e.fileLock.Unlock()
+	}
+}
+
 func tryFileLock(fileLock *flock.Flock) error {
 	locked, err := fileLock.TryLock()
 	if err != nil {
@@ -197,6 +315,13 @@ func tryFileLock(fileLock *flock.Flock) error {
 
 // loadMetaValues loads meta value that the engine stores.
 func (e *Engine) loadMetaValues() error {
+	if err := e.loadHLC(); err != nil {
+		return err
+	}
+	if err := e.loadEpochs(); err != nil {
+		return err
+	}
+
 	data, err := e.dataStore.RetrieveMetadata(sysKeyWalCheckPoint)
 	if err != nil && !errors.Is(err, kvdrivers.ErrKeyNotFound) {
 		return err
@@ -221,6 +346,39 @@ func (e *Engine) loadMetaValues() error {
 	return nil
 }
 
+// loadHLC moves the clock past the last timestamp saved with the checkpoint,
+// so timestamps issued after the restart are still after the ones already in the store.
+func (e *Engine) loadHLC() error {
+	data, err := e.dataStore.RetrieveMetadata(sysKeyHLC)
+	if errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(data) != 8 {
+		return fmt.Errorf("invalid hlc metadata of %d bytes: %w", len(data), ErrRecordCorrupted)
+	}
+	e.hlc.Restore(binary.LittleEndian.Uint64(data))
+	return nil
+}
+
+// observeHLC merges the timestamp of a record received from the upstream into the clock.
+// The record is already in the log of the upstream, so the clock moves past it even if it is too far ahead
+// of the wall clock, else the timestamps issued once promoted would be before it. The drift is only counted.
+func (e *Engine) observeHLC(remote uint64) {
+	if _, err := e.hlc.Update(remote); err != nil {
+		metrics.IncrCounterWithLabels(mKeyHLCDriftTotal, 1, e.metricsLabel)
+		e.hlc.Restore(remote)
+	}
+}
+
+func (e *Engine) saveHLC() error {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], e.hlc.Last())
+	return e.dataStore.StoreMetadata(sysKeyHLC, buf[:])
+}
+
 func (e *Engine) loadBloomFilter() error {
 	result, err := e.dataStore.RetrieveMetadata(sysKeyBloomFilter)
 
@@ -239,9 +397,15 @@ func (e *Engine) loadBloomFilter() error {
 // recoverWAL recovers the wal if any pending writes are still not visible.
 func (e *Engine) recoverWAL() error {
 	recovery := &walRecovery{
-		store: e.dataStore,
-		walIO: e.walIO,
-		bloom: e.bloom,
+		store:  e.dataStore,
+		walIO:  e.walIO,
+		bloom:  e.bloom,
+		epochs: e.epochs,
+	}
+	// records of a consensus namespace after its commit are applied once they are committed.
+	if e.consensus != nil {
+		recovery.commitLSN = e.consensus.commitLSN.Load()
+		recovery.consensus = true
 	}
 
 	startTime := time.Now()
@@ -261,24 +425,33 @@ func (e *Engine) recoverWAL() error {
 	e.writeSeenCounter.Add(uint64(recovery.recoveredCount))
 	e.recoveredEntriesCount = recovery.recoveredCount
 	e.opsFlushedCounter.Add(uint64(recovery.recoveredCount))
-	e.currentOffset.Store(recovery.lastRecoveredPos)
+	e.currentOffset.Store(recovery.lastPos)
+	e.lastLSN.Store(recovery.lastLSN)
+	// timestamps of the legacy records are decoded as zero, so only the ones this node wrote are restored.
+	e.hlc.Restore(recovery.lastHLC)
+	if recovery.epochsChanged {
+		e.epochs = recovery.epochs
+		e.epoch.Store(e.epochs[len(e.epochs)-1].epoch)
+		if err := e.saveEpochs(); err != nil {
+			return err
+		}
+	}
 
-	if recovery.recoveredCount > 0 {
+	if recovery.recoveredCount > 0 || recovery.checkpointReset {
 		// once recovered update the metadata table again.
-		e.pendingMetadata.queueMetadata(&flushedMetadata{
-			metadata: &Metadata{
-				RecordProcessed: uint64(recovery.recoveredCount),
-				Pos:             recovery.lastRecoveredPos,
-			},
-			recordProcessed: recovery.recoveredCount,
-		})
-
-		select {
-		case e.fsyncReqSignal <- struct{}{}:
-		default:
-			// this path should not happen in normal code base.
-			slog.Error("[kvalchemy.dbengine] fsync req signal channel is full while recovering itself")
-			panic("[kvalchemy.dbengine] fsync req signal channel is full while recovering itself")
+		// it's saved before the engine is returned, a checkpoint queued for the async fsync
+		// could be lost if the engine is closed right away, and the same records get recovered again.
+		if err := SaveMetadata(e.dataStore, recovery.lastRecoveredPos, uint64(recovery.recoveredCount)); err != nil {
+			return err
+		}
+		if err := e.saveBloomFilter(); err != nil {
+			return err
+		}
+		if err := e.saveHLC(); err != nil {
+			return err
+		}
+		if err := e.dataStore.FSync(); err != nil {
+			return err
 		}
 	}
 
@@ -297,31 +470,48 @@ func (e *Engine) recoverWAL() error {
 //   - Updates the Bloom filter for quick existence checks.
 //
 // 5. Store the current Chunk Position in the variable.
-func (e *Engine) persistKeyValue(key []byte, value []byte, op walrecord.LogOperation) error {
+//
+// It returns the lsn of the record.
+func (e *Engine) persistKeyValue(key []byte, value []byte, op walrecord.LogOperation) (uint64, error) {
+	lsn, err := e.writeKeyValue(key, value, op)
+	if err != nil {
+		return 0, err
+	}
+	return lsn, e.waitForSyncReplicas(lsn)
+}
+
+// writeKeyValue writes a key-value pair to WAL and MemTable, and returns the lsn of its record.
+func (e *Engine) writeKeyValue(key []byte, value []byte, op walrecord.LogOperation) (uint64, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	index := e.writeSeenCounter.Add(1)
-	hlc := HLCNow(index)
-	record := walrecord.Record{
-		Index:        index,
-		Hlc:          hlc,
-		Key:          key,
-		Value:        value,
-		LogOperation: op,
-		TxnStatus:    walrecord.TxnStatusTxnNone,
-		EntryType:    walrecord.EntryTypeKV,
+	if err := e.proposable(); err != nil {
+		return 0, err
 	}
-
-	encoded, err := record.FBEncode()
-
-	if err != nil {
-		return err
+	e.writeSeenCounter.Add(1)
+	lsn := e.nextLSN()
+	record := logcodec.LogRecord{
+		LSN:           lsn,
+		HLC:           e.hlc.Now(),
+		Epoch:         e.epoch.Load(),
+		Origin:        e.origin(),
+		CRC32Checksum: crc32.ChecksumIEEE(value),
+		OperationType: logrecord.LogOperationType(op),
+		TxnState:      logrecord.TransactionStateNone,
+		EntryType:     logrecord.LogEntryTypeKV,
+		Payload:       kvPayload(key, value),
 	}
 
+	encoded := record.Encode()
+
 	// Write to WAL
 	offset, err := e.walIO.Append(encoded)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	e.appendedLSN(lsn, offset)
+	if e.consensus != nil {
+		e.proposed(lsn, offset)
+		return lsn, nil
 	}
 
 	var memValue y.ValueStruct
@@ -331,47 +521,62 @@ func (e *Engine) persistKeyValue(key []byte, value []byte, op walrecord.LogOpera
 		memValue = getValueStruct(byte(op), false, offset.Encode())
 	}
 
+	e.noteVersions([]batchOp{{key: key, operation: op, entryType: walrecord.EntryTypeKV}}, record.HLC, record.Origin, offset)
 	err = e.memTableWrite(key, memValue, offset)
 
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	return lsn, nil
 }
 
 // persistRowColumnAction writes the columnEntries for the given rowKey in the wal and mem-table.
 func (e *Engine) persistRowColumnAction(op walrecord.LogOperation, rowKey []byte, columnEntries map[string][]byte) error {
+	lsn, err := e.writeRowColumnAction(op, rowKey, columnEntries)
+	if err != nil {
+		return err
+	}
+	return e.waitForSyncReplicas(lsn)
+}
+
+// writeRowColumnAction writes the columnEntries for the given rowKey in the wal and mem-table,
+// and returns the lsn of its record.
+func (e *Engine) writeRowColumnAction(op walrecord.LogOperation, rowKey []byte, columnEntries map[string][]byte) (uint64, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	if err := e.proposable(); err != nil {
+		return 0, err
+	}
 
-	index := e.writeSeenCounter.Add(1)
-	hlc := HLCNow(index)
+	e.writeSeenCounter.Add(1)
+	lsn := e.nextLSN()
 	valueSize := 0
 	for k, v := range columnEntries {
 		valueSize += len(k) + len(v)
 	}
 
-	record := walrecord.Record{
-		Index:         index,
-		Hlc:           hlc,
-		Key:           rowKey,
-		Value:         nil,
-		LogOperation:  op,
-		TxnStatus:     walrecord.TxnStatusTxnNone,
-		EntryType:     walrecord.EntryTypeRow,
-		ColumnEntries: columnEntries,
+	record := logcodec.LogRecord{
+		LSN:           lsn,
+		HLC:           e.hlc.Now(),
+		Epoch:         e.epoch.Load(),
+		Origin:        e.origin(),
+		OperationType: logrecord.LogOperationType(op),
+		TxnState:      logrecord.TransactionStateNone,
+		EntryType:     logrecord.LogEntryTypeRow,
+		Payload:       rowPayload(rowKey, columnEntries),
 	}
 
-	encoded, err := record.FBEncode()
-
-	if err != nil {
-		return err
-	}
+	encoded := record.Encode()
 
 	// Write to WAL
 	offset, err := e.walIO.Append(encoded)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	e.appendedLSN(lsn, offset)
+	if e.consensus != nil {
+		e.proposed(lsn, offset)
+		return lsn, nil
 	}
 
 	var memValue y.ValueStruct
@@ -382,23 +587,20 @@ func (e *Engine) persistRowColumnAction(op walrecord.LogOperation, rowKey []byte
 	}
 
 	memValue.UserMeta = entryTypeRow
+	e.noteVersions([]batchOp{{key: rowKey, columns: columnEntries, operation: op, entryType: walrecord.EntryTypeRow}}, record.HLC, record.Origin, offset)
 	err = e.memTableWrite(rowKey, memValue, offset)
 
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	return lsn, nil
 }
 
 // memTableWrite will write the provided key and value to the memTable.
 func (e *Engine) memTableWrite(key []byte, v y.ValueStruct, offset *wal.Offset) error {
 	defer func() {
-		// Signal all waiting routines that a new append has happened
-		// Atomically update lastChunkPosition
-		e.currentOffset.Store(offset)
-		e.notifierMu.Lock()
-		e.notifier.Broadcast()
-		e.notifierMu.Unlock()
+		// notify all waiting routines that a new append has happened.
+		e.appendWatch.advance(e.currentOffset.Load())
 	}()
 	var err error
 	err = e.activeMemTable.put(key, v, offset)
@@ -423,6 +625,27 @@ func (e *Engine) memTableWrite(key []byte, v y.ValueStruct, offset *wal.Offset)
 	return err
 }
 
+// memTableWriteBatch writes the entries of the batch record at the offset to the same mem-table,
+// so a flush of the mem-table never has only a part of the batch.
+func (e *Engine) memTableWriteBatch(entries []txMemTableEntry, offset *wal.Offset) error {
+	defer func() {
+		e.appendWatch.advance(e.currentOffset.Load())
+	}()
+
+	if !e.activeMemTable.canPutAll(entries) {
+		metrics.IncrCounterWithLabels(mKeyMemTableRotationTotal, 1, e.metricsLabel)
+		e.rotateMemTable()
+	}
+
+	for _, entry := range entries {
+		if err := e.activeMemTable.put(entry.key, entry.value, offset); err != nil {
+			return err
+		}
+		e.bloom.Add(entry.key)
+	}
+	return nil
+}
+
 // used for testing purposes.
 func (e *Engine) rotateMemTableNoFlush() {
 	// put the old table in the queue
@@ -498,6 +721,10 @@ func (e *Engine) handleFlush(ctx context.Context) {
 	if mt != nil && !mt.skipList.Empty() {
 		startTime := time.Now()
 		recordProcessed, err := mt.flush(ctx)
+		// engine is closing, the records not flushed are recovered from the wal on the next open.
+		if errors.Is(err, context.Canceled) && e.shutdown.Load() {
+			return
+		}
 		if err != nil {
 			log.Fatal("Failed to flushMemTable MemTable:", "namespace", e.namespace, "err", err)
 		}
@@ -509,8 +736,21 @@ func (e *Engine) handleFlush(ctx context.Context) {
 			},
 			recordProcessed: recordProcessed,
 			bytesFlushed:    uint64(mt.bytesStored),
+			upstreamOffset:  mt.upstreamOffset,
 		}
 		e.pendingMetadata.queueMetadata(fm)
+
+		// the history index catches up on the next flush, if it fails now.
+		if e.history != nil {
+			if err := e.history.indexTill(mt.lastOffset); err != nil {
+				slog.Error("[kvalchemy.dbengine]: history index error", "namespace", e.namespace, "error", err)
+			}
+		}
+		if e.lww != nil {
+			if err := e.lww.persist(mt.lastOffset); err != nil {
+				slog.Error("[kvalchemy.dbengine]: lww index error", "namespace", e.namespace, "error", err)
+			}
+		}
 		e.mu.Lock()
 		// Remove it from the list
 		e.sealedMemTables = e.sealedMemTables[1:]
@@ -536,6 +776,8 @@ type flushedMetadata struct {
 	metadata        *Metadata
 	recordProcessed int
 	bytesFlushed    uint64
+	// upstreamOffset is the offset the replica resumes the stream from, once the mem table is flushed.
+	upstreamOffset []byte
 }
 
 type pendingMetadata struct {
@@ -573,14 +815,31 @@ func (e *Engine) asyncFSync() {
 			metrics.IncrCounterWithLabels(mKeyPendingFsyncTotal, float32(n), e.metricsLabel)
 			startTime := time.Now()
 			metrics.IncrCounterWithLabels(mKeyFSyncTotal, 1, e.metricsLabel)
-			err := SaveMetadata(e.dataStore, fm.metadata.Pos, fm.metadata.RecordProcessed)
+			// bloom filter is saved first, a snapshot of the btree store with the checkpoint has every key till it.
+			err := e.saveBloomFilter()
 			if err != nil {
 				log.Fatal("[kvalchemy.dbengine] Failed to Create WAL checkpoint:", "namespace", e.namespace, "err", err)
 			}
-			err = e.saveBloomFilter()
+			err = SaveMetadata(e.dataStore, fm.metadata.Pos, fm.metadata.RecordProcessed)
 			if err != nil {
 				log.Fatal("[kvalchemy.dbengine] Failed to Create WAL checkpoint:", "namespace", e.namespace, "err", err)
 			}
+			err = e.saveHLC()
+			if err != nil {
+				log.Fatal("[kvalchemy.dbengine] Failed to save hlc:", "namespace", e.namespace, "err", err)
+			}
+			if e.consensus != nil {
+				err = e.saveCommitLSN()
+				if err != nil {
+					log.Fatal("[kvalchemy.dbengine] Failed to save commit lsn:", "namespace", e.namespace, "err", err)
+				}
+			}
+			if len(fm.upstreamOffset) > 0 {
+				err = e.dataStore.StoreMetadata(sysKeyUpstreamOffset, fm.upstreamOffset)
+				if err != nil {
+					log.Fatal("[kvalchemy.dbengine] Failed to save upstream offset:", "namespace", e.namespace, "err", err)
+				}
+			}
 			err = e.dataStore.FSync()
 			if err != nil {
 				metrics.IncrCounterWithLabels(mKeyFSyncErrorsTotal, 1, e.metricsLabel)
@@ -626,6 +885,29 @@ func (e *Engine) close(ctx context.Context) error {
 		slog.Error("[kvalchemy.dbengine]: wal Fsync error", "error", err)
 	}
 
+	// the records applied from the upstream are synced in the wal, the stream resumes after them.
+	if err == nil && e.following.Load() {
+		if err := e.saveUpstreamOffset(); err != nil {
+			errs.WriteString(err.Error())
+			errs.WriteString("|")
+			slog.Error("[kvalchemy.dbengine]: upstream offset save error", "error", err)
+		}
+	}
+
+	// the peer records are synced in the wal, the peer streams resume after them.
+	if err == nil && e.lww != nil {
+		if err := e.lww.persist(e.currentOffset.Load()); err != nil {
+			errs.WriteString(err.Error())
+			errs.WriteString("|")
+			slog.Error("[kvalchemy.dbengine]: lww index save error", "error", err)
+		}
+		if err := e.lww.savePeers(); err != nil {
+			errs.WriteString(err.Error())
+			errs.WriteString("|")
+			slog.Error("[kvalchemy.dbengine]: peer offsets save error", "error", err)
+		}
+	}
+
 	err = e.walIO.Close()
 	if err != nil {
 		errs.WriteString(err.Error())
@@ -633,6 +915,23 @@ func (e *Engine) close(ctx context.Context) error {
 		slog.Error("[kvalchemy.dbengine]: wal close error", "error", err)
 	}
 
+	// timestamps observed from the other nodes are not in the wal, so the clock is saved as well.
+	err = e.saveHLC()
+	if err != nil {
+		errs.WriteString(err.Error())
+		errs.WriteString("|")
+		slog.Error("[kvalchemy.dbengine]: hlc save error", "error", err)
+	}
+
+	// the records till the commit are synced in the wal, the recovery applies them.
+	if e.consensus != nil {
+		if err := e.saveCommitLSN(); err != nil {
+			errs.WriteString(err.Error())
+			errs.WriteString("|")
+			slog.Error("[kvalchemy.dbengine]: commit save error", "error", err)
+		}
+	}
+
 	err = e.dataStore.FSync()
 	if err != nil {
 		errs.WriteString(err.Error())
@@ -647,10 +946,30 @@ func (e *Engine) close(ctx context.Context) error {
 		slog.Error("[kvalchemy.dbengine]: Btree close error", "error", err)
 	}
 
+	if e.history != nil {
+		err = e.history.store.Close()
+		if err != nil {
+			errs.WriteString(err.Error())
+			errs.WriteString("|")
+			slog.Error("[kvalchemy.dbengine]: history index close error", "error", err)
+		}
+	}
+
+	if e.lww != nil {
+		err = e.lww.store.Close()
+		if err != nil {
+			errs.WriteString(err.Error())
+			errs.WriteString("|")
+			slog.Error("[kvalchemy.dbengine]: lww index close error", "error", err)
+		}
+	}
+
 	// release the lock file.
-	if err := e.fileLock.Unlock(); err != nil {
-		errs.WriteString(err.Error())
-		errs.WriteString("|")
+	if e.fileLock != nil {
+		if err := e.fileLock.Unlock(); err != nil {
+			errs.WriteString(err.Error())
+			errs.WriteString("|")
+		}
 	}
 
 	if errs.Len() > 0 {
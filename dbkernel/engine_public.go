@@ -13,6 +13,8 @@ import (
 	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
 	"github.com/ankur-anand/unisondb/dbkernel/wal"
 	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
 	"github.com/dgraph-io/badger/v4/y"
 	"github.com/hashicorp/go-metrics"
 )
@@ -20,6 +22,8 @@ import (
 var (
 	mKeyPutTotal           = append(packageKey, "put", "total")
 	mKeyGetTotal           = append(packageKey, "get", "total")
+	mKeyGetReaderTotal     = append(packageKey, "get", "reader", "total")
+	mKeyGetRangeTotal      = append(packageKey, "get", "range", "total")
 	mKeyDeleteTotal        = append(packageKey, "delete", "total")
 	mKeyPutDuration        = append(packageKey, "put", "durations", "seconds")
 	mKeyGetDuration        = append(packageKey, "get", "durations", "seconds")
@@ -100,6 +104,25 @@ func (e *Engine) CurrentOffset() *Offset {
 	return e.currentOffset.Load()
 }
 
+// LastLSN returns the log sequence number of the last record appended to the WAL, zero if the WAL is empty.
+// Every record of the WAL carries its lsn as the index, they are assigned without gaps starting from one.
+func (e *Engine) LastLSN() uint64 {
+	return e.lastLSN.Load()
+}
+
+// Now returns a new hybrid logical clock timestamp from the clock of the engine.
+// It's after the timestamp of every record written, recovered or observed by the engine.
+func (e *Engine) Now() uint64 {
+	return e.hlc.Now()
+}
+
+// ObserveHLC merges the hybrid logical clock timestamp of a record received from another node
+// into the clock of the engine, it should be called for every replicated record received.
+// It returns ErrClockDrift if the timestamp is ahead of the wall clock by more than the max clock drift.
+func (e *Engine) ObserveHLC(remote uint64) (uint64, error) {
+	return e.hlc.Update(remote)
+}
+
 // GetWalCheckPoint returns the last checkpoint metadata saved in the database.
 func (e *Engine) GetWalCheckPoint() (*Metadata, error) {
 	data, err := e.dataStore.RetrieveMetadata(sysKeyWalCheckPoint)
@@ -112,8 +135,14 @@ func (e *Engine) GetWalCheckPoint() (*Metadata, error) {
 
 // Put inserts a key-value pair.
 func (e *Engine) Put(key, value []byte) error {
-	if e.shutdown.Load() {
-		return ErrInCloseProcess
+	_, err := e.PutLSN(key, value)
+	return err
+}
+
+// PutLSN inserts a key-value pair like Put, and returns the lsn of its record.
+func (e *Engine) PutLSN(key, value []byte) (uint64, error) {
+	if err := e.writable(); err != nil {
+		return 0, err
 	}
 	metrics.IncrCounterWithLabels(mKeyPutTotal, 1, e.metricsLabel)
 	startTime := time.Now()
@@ -126,8 +155,14 @@ func (e *Engine) Put(key, value []byte) error {
 
 // Delete removes a key and its value pair from WAL and MemTable.
 func (e *Engine) Delete(key []byte) error {
-	if e.shutdown.Load() {
-		return ErrInCloseProcess
+	_, err := e.DeleteLSN(key)
+	return err
+}
+
+// DeleteLSN removes a key like Delete, and returns the lsn of its record.
+func (e *Engine) DeleteLSN(key []byte) (uint64, error) {
+	if err := e.writable(); err != nil {
+		return 0, err
 	}
 
 	metrics.IncrCounterWithLabels(mKeyDeleteTotal, 1, e.metricsLabel)
@@ -147,29 +182,75 @@ func (e *Engine) WaitForAppend(ctx context.Context, timeout time.Duration, lastS
 		metrics.MeasureSinceWithLabels(mKeyWaitForAppendDuration, startTime, e.metricsLabel)
 	}()
 
-	currentPos := e.currentOffset.Load()
-	if currentPos != nil && isNewChunkPosition(currentPos, lastSeen) {
-		return nil
-	}
+	appended, cancel := e.WatchOffset(lastSeen)
+	defer cancel()
 
-	done := make(chan struct{})
-	go func() {
-		e.notifierMu.Lock()
-		defer e.notifierMu.Unlock()
-		e.notifier.Wait()
-		close(done)
-	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
-	case <-done:
+	case <-appended:
 		return nil
-	case <-time.After(timeout):
+	case <-timer.C:
 		return ErrWaitTimeoutExceeded
 	}
 }
 
+// WaitForLSN blocks until the record with the lsn is applied to the engine and visible to the reads,
+// or timeout happens or context cancelled is done.
+func (e *Engine) WaitForLSN(ctx context.Context, timeout time.Duration, lsn uint64) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		// watched before checking the lsn, so the record applied after the check closes the channel.
+		appended, cancel := e.watchApplied()
+		if e.appliedLSN() >= lsn {
+			cancel()
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			cancel()
+			return ctx.Err()
+		case <-appended:
+			cancel()
+		case <-timer.C:
+			cancel()
+			return ErrWaitTimeoutExceeded
+		}
+	}
+}
+
+// appliedLSN returns the lsn of the last record the reads see, the writes hold the lock till
+// the record is in the mem table. The records of a consensus namespace are seen once committed.
+func (e *Engine) appliedLSN() uint64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.consensus != nil {
+		return e.consensus.commitLSN.Load()
+	}
+	return e.lastLSN.Load()
+}
+
+// watchApplied returns a channel that is closed once a new record is seen by the reads.
+func (e *Engine) watchApplied() (<-chan struct{}, func()) {
+	if e.consensus != nil {
+		return e.consensus.watch(), func() {}
+	}
+	return e.WatchOffset(e.currentOffset.Load())
+}
+
+// WatchOffset returns a channel that is closed once an append moves the current offset past the lastSeen offset,
+// it's closed by the next append if lastSeen is nil.
+// The returned cancel func must be called once the caller stops waiting on the channel.
+func (e *Engine) WatchOffset(lastSeen *Offset) (<-chan struct{}, func()) {
+	return e.appendWatch.watch(lastSeen)
+}
+
 func isNewChunkPosition(current, lastSeen *Offset) bool {
 	if lastSeen == nil {
 		return true
@@ -193,27 +274,7 @@ func (e *Engine) Get(key []byte) ([]byte, error) {
 		metrics.MeasureSinceWithLabels(mKeyGetDuration, startTime, e.metricsLabel)
 	}()
 
-	checkFunc := func() (y.ValueStruct, error) {
-		// Retrieve entry from MemTable
-		e.mu.RLock()
-		defer e.mu.RUnlock()
-		// fast negative check
-		if !e.bloom.Test(key) {
-			return y.ValueStruct{}, ErrKeyNotFound
-		}
-		it := e.activeMemTable.get(key)
-		if it.Meta == byte(walrecord.LogOperationNoop) {
-			// first latest value
-			for i := len(e.sealedMemTables) - 1; i >= 0; i-- {
-				if val := e.sealedMemTables[i].get(key); val.Meta != byte(walrecord.LogOperationNoop) {
-					return val, nil
-				}
-			}
-		}
-		return it, nil
-	}
-
-	it, err := checkFunc()
+	it, err := e.getMemTableEntry(key)
 	if err != nil {
 		return nil, err
 	}
@@ -235,15 +296,137 @@ func (e *Engine) Get(key []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	if record.EntryType() == walrecord.EntryTypeChunked {
+	if record.EntryType == logrecord.LogEntryTypeChunked {
 		return e.reconstructBatchValue(record)
 	}
 
-	if crc32.ChecksumIEEE(record.ValueBytes()) != record.Crc32Checksum() {
+	if crc32.ChecksumIEEE(record.Value()) != record.CRC32Checksum {
 		return nil, ErrRecordCorrupted
 	}
 
-	return record.ValueBytes(), nil
+	return record.Value(), nil
+}
+
+// ValueReader streams a value chunk by chunk and verifies its checksum at EOF.
+// It should be closed once the value is read.
+type ValueReader interface {
+	io.ReadCloser
+	// Checksum returns the expected CRC32 checksum of the complete value.
+	Checksum() uint32
+	// ChunkCount returns the number of chunks the value is streamed in.
+	ChunkCount() int
+}
+
+// GetReader returns a reader that streams the value associated with the given key.
+// Chunked values are read one chunk at a time from the WAL or the BtreeStore, so the complete
+// value is never held in memory. Reader returns ErrRecordCorrupted at EOF if the checksum
+// of the value doesn't match, and ErrValueChanged if the value in the BtreeStore is overwritten
+// before all of its chunks are read.
+func (e *Engine) GetReader(key []byte) (ValueReader, error) {
+	if e.shutdown.Load() {
+		return nil, ErrInCloseProcess
+	}
+
+	metrics.IncrCounterWithLabels(mKeyGetReaderTotal, 1, e.metricsLabel)
+
+	it, err := e.getMemTableEntry(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if it.Meta == byte(walrecord.LogOperationNoop) {
+		reader, err := e.dataStore.GetValueReader(key)
+		if err != nil {
+			return nil, err
+		}
+		return reader, nil
+	}
+
+	if it.Meta == byte(walrecord.LogOperationDelete) {
+		return nil, ErrKeyNotFound
+	}
+
+	record, err := getWalRecord(it, e.walIO)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.EntryType == logrecord.LogEntryTypeChunked {
+		reader, err := e.newChunkedValueReader(record)
+		if err != nil {
+			return nil, err
+		}
+		return reader, nil
+	}
+
+	if record.EntryType == logrecord.LogEntryTypeRow {
+		return nil, ErrUseGetColumnAPI
+	}
+
+	value := record.Value()
+	return kvdrivers.NewChunkReader(1, record.CRC32Checksum, func(int) ([]byte, error) {
+		return value, nil
+	}), nil
+}
+
+// GetRange returns length bytes of the value associated with the given key, starting at offset.
+// A non-positive length reads till the end of the value, and ErrInvalidRange is returned if
+// the offset is not within the value. For chunked values only the chunks overlapping the range are read,
+// plain values are sliced in place. The checksum of the complete value is not verified for range reads.
+func (e *Engine) GetRange(key []byte, offset, length int64) ([]byte, error) {
+	if e.shutdown.Load() {
+		return nil, ErrInCloseProcess
+	}
+
+	metrics.IncrCounterWithLabels(mKeyGetRangeTotal, 1, e.metricsLabel)
+
+	it, err := e.getMemTableEntry(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if it.Meta == byte(walrecord.LogOperationNoop) {
+		return e.dataStore.GetRange(key, offset, length)
+	}
+
+	if it.Meta == byte(walrecord.LogOperationDelete) {
+		return nil, ErrKeyNotFound
+	}
+
+	record, err := getWalRecord(it, e.walIO)
+	if err != nil {
+		return nil, err
+	}
+
+	switch record.EntryType {
+	case logrecord.LogEntryTypeChunked:
+		return e.readChunkedRange(record, offset, length)
+	case logrecord.LogEntryTypeRow:
+		return nil, ErrUseGetColumnAPI
+	}
+
+	return kvdrivers.SliceRange(record.Value(), offset, length)
+}
+
+// getMemTableEntry returns the latest entry for the key across the active and sealed mem-table.
+// LogOperationNoop meta denotes that the key has no entry in any of the mem-table.
+func (e *Engine) getMemTableEntry(key []byte) (y.ValueStruct, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	// fast negative check
+	if !e.bloom.Test(key) {
+		return y.ValueStruct{}, ErrKeyNotFound
+	}
+	it := e.activeMemTable.get(key)
+	if it.Meta == byte(walrecord.LogOperationNoop) {
+		// first latest value
+		for i := len(e.sealedMemTables) - 1; i >= 0; i-- {
+			if val := e.sealedMemTables[i].get(key); val.Meta != byte(walrecord.LogOperationNoop) {
+				return val, nil
+			}
+		}
+	}
+	return it, nil
 }
 
 // SetColumnsInRow inserts or updates the provided column entries.
@@ -252,8 +435,8 @@ func (e *Engine) Get(key []byte) ([]byte, error) {
 // - existing column value will get updated to newer value, else a new column entry will be created for the
 // given row.
 func (e *Engine) SetColumnsInRow(rowKey string, columnEntries map[string][]byte) error {
-	if e.shutdown.Load() {
-		return ErrInCloseProcess
+	if err := e.writable(); err != nil {
+		return err
 	}
 	metrics.IncrCounterWithLabels(mKeyRowSetTotal, 1, e.metricsLabel)
 	startTime := time.Now()
@@ -266,8 +449,8 @@ func (e *Engine) SetColumnsInRow(rowKey string, columnEntries map[string][]byte)
 
 // DeleteColumnsFromRow removes the specified columns from the given row key.
 func (e *Engine) DeleteColumnsFromRow(rowKey string, columnEntries map[string][]byte) error {
-	if e.shutdown.Load() {
-		return ErrInCloseProcess
+	if err := e.writable(); err != nil {
+		return err
 	}
 	metrics.IncrCounterWithLabels(mKeyRowDeleteTotal, 1, e.metricsLabel)
 	startTime := time.Now()
@@ -280,8 +463,8 @@ func (e *Engine) DeleteColumnsFromRow(rowKey string, columnEntries map[string][]
 
 // DeleteRow removes an entire row and all its associated column entries.
 func (e *Engine) DeleteRow(rowKey string) error {
-	if e.shutdown.Load() {
-		return ErrInCloseProcess
+	if err := e.writable(); err != nil {
+		return err
 	}
 	metrics.IncrCounterWithLabels(mKeyRowDeleteTotal, 1, e.metricsLabel)
 	startTime := time.Now()
@@ -366,24 +549,24 @@ func (e *Engine) GetRowColumns(rowKey string, predicate func(columnKey string) b
 	return columnsValue, nil
 }
 
-func (e *Engine) reconstructBatchValue(record *walrecord.WalRecord) ([]byte, error) {
-	records, err := e.walIO.GetTransactionRecords(wal.DecodeOffset(record.PrevTxnWalIndexBytes()))
+func (e *Engine) reconstructBatchValue(record *logcodec.LogRecord) ([]byte, error) {
+	records, err := e.walIO.GetTransactionRecords(wal.DecodeOffset(record.PrevTxnWalIndex))
 	if err != nil {
 		return nil, fmt.Errorf("failed to reconstruct batch value: %w", err)
 	}
-	checksum := unmarshalChecksum(record.ValueBytes())
+	checksum := unmarshalChecksum(record.Value())
 
 	// remove the begins part from the
 	preparedRecords := records[1:]
 
 	var estimatedSize int
 	for _, rec := range preparedRecords {
-		estimatedSize += len(rec.ValueBytes())
+		estimatedSize += len(rec.Value())
 	}
 
 	fullValue := bytes.NewBuffer(make([]byte, 0, estimatedSize))
 	for _, record := range preparedRecords {
-		fullValue.Write(record.ValueBytes())
+		fullValue.Write(record.Value())
 	}
 
 	value := fullValue.Bytes()
@@ -395,6 +578,67 @@ func (e *Engine) reconstructBatchValue(record *walrecord.WalRecord) ([]byte, err
 	return value, nil
 }
 
+// newChunkedValueReader returns a reader that streams the chunks of the committed chunked txn
+// from the WAL, one record at a time.
+func (e *Engine) newChunkedValueReader(record *logcodec.LogRecord) (*kvdrivers.ChunkReader, error) {
+	offsets, err := e.walIO.GetTransactionOffsets(wal.DecodeOffset(record.PrevTxnWalIndex))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconstruct batch value: %w", err)
+	}
+	checksum := unmarshalChecksum(record.Value())
+
+	// remove the begins part from the
+	preparedOffsets := offsets[1:]
+
+	return kvdrivers.NewChunkReader(len(preparedOffsets), checksum, func(i int) ([]byte, error) {
+		return e.readRecordValue(preparedOffsets[i])
+	}), nil
+}
+
+// readChunkedRange reads the range of the committed chunked txn value from the WAL,
+// only the prepared records overlapping the range are read.
+func (e *Engine) readChunkedRange(record *logcodec.LogRecord, offset, length int64) ([]byte, error) {
+	offsets, err := e.walIO.GetTransactionOffsets(wal.DecodeOffset(record.PrevTxnWalIndex))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconstruct batch value: %w", err)
+	}
+
+	// remove the begins part from the
+	preparedOffsets := offsets[1:]
+	layout := unmarshalChunkedCommit(record.Value(), len(preparedOffsets))
+
+	return layout.ReadRange(offset, length, func(i int) ([]byte, error) {
+		return e.readRecordValue(preparedOffsets[i])
+	})
+}
+
+// readRecord reads and decodes the record at the offset.
+func (e *Engine) readRecord(offset *Offset) (*logcodec.LogRecord, error) {
+	data, err := e.walIO.Read(offset)
+	if err != nil {
+		return nil, err
+	}
+	return logcodec.DecodeRecord(data)
+}
+
+// readVersionRecord reads the record of the change, the record of the operation if the change was made by a batch.
+func (e *Engine) readVersionRecord(version keyVersion) (*logcodec.LogRecord, error) {
+	record, err := e.readRecord(version.offset)
+	if err != nil || !record.IsBatch() {
+		return record, err
+	}
+	return record.BatchRecord(version.entry)
+}
+
+// readRecordValue returns the value of the record at the offset.
+func (e *Engine) readRecordValue(offset *Offset) ([]byte, error) {
+	record, err := e.readRecord(offset)
+	if err != nil {
+		return nil, err
+	}
+	return record.Value(), nil
+}
+
 // Close all the associated resource.
 func (e *Engine) Close(ctx context.Context) error {
 	if e.shutdown.Load() {
@@ -446,3 +690,58 @@ func (e *Engine) NewReaderWithStart(startPos *Offset) (*Reader, error) {
 
 	return e.walIO.NewReaderWithStart(startPos)
 }
+
+// OffsetForLSN returns the offset of the record with the log sequence number in the WAL.
+func (e *Engine) OffsetForLSN(lsn uint64) (*Offset, error) {
+	if lsn == 0 || lsn > e.lastLSN.Load() {
+		return nil, ErrInvalidLSN
+	}
+	return e.lsnIndex.lookup(lsn)
+}
+
+// NewReaderFromLSN return a reader that reads from the record with the log sequence number, until EOF is encountered.
+// Unlike the offset the lsn of a record is the same on every node that has it, so it can be used to
+// resume reading on a node whose WAL has a different layout.
+// It returns io.EOF when it reaches end of file.
+func (e *Engine) NewReaderFromLSN(lsn uint64) (*Reader, error) {
+	offset, err := e.OffsetForLSN(lsn)
+	if err != nil {
+		return nil, err
+	}
+	return e.walIO.NewReaderWithStart(offset)
+}
+
+// RecordHLC returns the hybrid logical clock timestamp of the record with the log sequence number.
+func (e *Engine) RecordHLC(lsn uint64) (uint64, error) {
+	offset, err := e.OffsetForLSN(lsn)
+	if err != nil {
+		return 0, err
+	}
+	record, err := e.readRecord(offset)
+	if err != nil {
+		return 0, err
+	}
+	return record.HLC, nil
+}
+
+// TxnOpen reports if the txn with the id has begun and is not committed yet.
+// The txns left open by a restart are not reported, they are never committed.
+func (e *Engine) TxnOpen(txnID []byte) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	_, ok := e.openTxns[string(txnID)]
+	return ok
+}
+
+// SyncWAL syncs the records appended to the WAL to the disk, they are recovered after a crash once it returns.
+func (e *Engine) SyncWAL() error {
+	if e.shutdown.Load() {
+		return ErrInCloseProcess
+	}
+	return e.walIO.Sync()
+}
+
+// WALBytesAfter returns the bytes appended to the WAL after the record at the offset, every byte of the WAL if nil.
+func (e *Engine) WALBytesAfter(offset *Offset) int64 {
+	return e.walIO.BytesAfter(offset)
+}
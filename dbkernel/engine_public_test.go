@@ -12,7 +12,8 @@ import (
 
 	"github.com/anishathalye/porcupine"
 	"github.com/ankur-anand/unisondb/dbkernel"
-	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -74,6 +75,63 @@ func TestPutGet_Delete(t *testing.T) {
 	})
 }
 
+func TestNewStorageEngine_UnregisteredDriver(t *testing.T) {
+	conf := dbkernel.NewDefaultEngineConfig()
+	conf.DBEngine = "NOT_REGISTERED"
+
+	engine, err := dbkernel.NewStorageEngine(t.TempDir(), "test_unregistered", conf)
+	assert.ErrorIs(t, err, kvdrivers.ErrDriverNotRegistered)
+	assert.Nil(t, engine)
+}
+
+func TestInMemoryEngine_RebuildFromWAL(t *testing.T) {
+	baseDir := t.TempDir()
+	namespace := "test_in_memory"
+
+	conf := dbkernel.NewDefaultEngineConfig()
+	conf.DBEngine = dbkernel.InMemoryEngine
+	conf.BtreeConfig.Namespace = namespace
+
+	engine, err := dbkernel.NewStorageEngine(baseDir, namespace, conf)
+	assert.NoError(t, err)
+
+	kv := make(map[string][]byte)
+	for i := 0; i < 50; i++ {
+		key := gofakeit.UUID()
+		kv[key] = []byte(gofakeit.Sentence(10))
+		assert.NoError(t, engine.Put([]byte(key), kv[key]), "Put operation should succeed")
+	}
+
+	deleted := make(map[string]struct{})
+	for key := range kv {
+		if len(deleted) == 10 {
+			break
+		}
+		deleted[key] = struct{}{}
+		assert.NoError(t, engine.Delete([]byte(key)), "Delete operation should succeed")
+	}
+
+	// nothing is persisted apart from the WAL, so every restart replays the complete WAL.
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, engine.Close(context.Background()))
+		engine, err = dbkernel.NewStorageEngine(baseDir, namespace, conf)
+		assert.NoError(t, err)
+		assert.Equal(t, 60, engine.RecoveredWALCount(), "complete wal should be recovered")
+
+		for key, value := range kv {
+			got, err := engine.Get([]byte(key))
+			if _, ok := deleted[key]; ok {
+				assert.ErrorIs(t, err, dbkernel.ErrKeyNotFound)
+				continue
+			}
+			assert.NoError(t, err, "Get operation should succeed")
+			assert.Equal(t, value, got, "Retrieved value should match the inserted value")
+		}
+	}
+
+	assert.NoError(t, engine.Close(context.Background()))
+}
+
 func TestConcurrentWrites(t *testing.T) {
 	baseDir := t.TempDir()
 	namespace := "test_concurrent"
@@ -292,8 +350,9 @@ func TestEngine_WaitForAppend_And_Reader(t *testing.T) {
 				}
 				assert.NoError(t, err, "Reader should only return EOF Error")
 			}
-			record := walrecord.GetRootAsWalRecord(value, 0)
-			assert.Equal(t, putKV[string(record.KeyBytes())], record.ValueBytes(), "decompressed value should be equal to put value")
+			record, err := logcodec.DecodeRecord(value)
+			assert.NoError(t, err)
+			assert.Equal(t, putKV[string(record.Key())], record.Value(), "decompressed value should be equal to put value")
 		}
 	}
 
@@ -6,13 +6,16 @@ import (
 	"fmt"
 	"hash/crc32"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/ankur-anand/unisondb/dbkernel/wal"
 	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
 	"github.com/bits-and-blooms/bloom/v3"
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.etcd.io/bbolt"
 )
 
@@ -45,7 +48,7 @@ func TestStorageEngine_Suite(t *testing.T) {
 			key := gofakeit.UUID()
 			value := gofakeit.LetterN(100)
 			insertedKV[key] = value
-			err := engine.persistKeyValue([]byte(key), []byte(value), walrecord.LogOperationInsert)
+			_, err := engine.persistKeyValue([]byte(key), []byte(value), walrecord.LogOperationInsert)
 			assert.NoError(t, err, "persistKeyValue should not error")
 		}
 	})
@@ -98,7 +101,7 @@ func TestStorageEngine_Suite(t *testing.T) {
 			key := gofakeit.UUID()
 			value := gofakeit.LetterN(1024)
 			insertedKV[key] = value
-			err := engine.persistKeyValue([]byte(key), []byte(value), walrecord.LogOperationInsert)
+			_, err := engine.persistKeyValue([]byte(key), []byte(value), walrecord.LogOperationInsert)
 			assert.NoError(t, err, "persistKeyValue should not error")
 		}
 
@@ -254,7 +257,7 @@ func TestEngine_RecoveredWalShouldNotRecoverAgain(t *testing.T) {
 			key := gofakeit.UUID()
 			value := gofakeit.LetterN(100)
 			insertedKV[key] = value
-			err := engine.persistKeyValue([]byte(key), []byte(value), walrecord.LogOperationInsert)
+			_, err := engine.persistKeyValue([]byte(key), []byte(value), walrecord.LogOperationInsert)
 			assert.NoError(t, err, "persistKeyValue should not error")
 		}
 	})
@@ -556,3 +559,272 @@ func TestEngine_GetRowColumns_WithMemTableRotate(t *testing.T) {
 	})
 
 }
+
+// crashEngine stops the engine the way a killed process would, the mem table is never flushed
+// and nothing is synced, only the handles and the lock are released so the namespace can be reopened.
+func crashEngine(t *testing.T, e *Engine) {
+	t.Helper()
+	e.shutdown.Store(true)
+	e.cancel()
+	assert.True(t, waitWithTimeout(e.wg, 10*time.Second), "background routine should stop")
+	assert.NoError(t, e.walIO.Close())
+	assert.NoError(t, e.dataStore.Close())
+	assert.NoError(t, e.fileLock.Unlock())
+}
+
+func TestEngine_CrashBeforeFlush_Recovery(t *testing.T) {
+	for _, dbEngine := range []DBEngine{BoltDBEngine, LMDBEngine} {
+		t.Run(string(dbEngine), func(t *testing.T) {
+			baseDir := t.TempDir()
+			namespace := "test_crash_recovery"
+
+			config := NewDefaultEngineConfig()
+			config.ArenaSize = 1 << 30
+			config.DBEngine = dbEngine
+			config.BtreeConfig.Namespace = namespace
+
+			engine, err := NewStorageEngine(baseDir, namespace, config)
+			assert.NoError(t, err)
+
+			// put and delete
+			assert.NoError(t, engine.Put([]byte("put_key"), []byte("put_value")))
+			assert.NoError(t, engine.Put([]byte("deleted_key"), []byte("deleted_value")))
+			assert.NoError(t, engine.Delete([]byte("deleted_key")))
+
+			// row column upsert, column delete and row delete.
+			assert.NoError(t, engine.SetColumnsInRow("row_1", map[string][]byte{
+				"col_a": []byte("a"), "col_b": []byte("b"), "col_c": []byte("c"),
+			}))
+			assert.NoError(t, engine.SetColumnsInRow("row_1", map[string][]byte{"col_a": []byte("a_updated")}))
+			assert.NoError(t, engine.DeleteColumnsFromRow("row_1", map[string][]byte{"col_b": nil}))
+			assert.NoError(t, engine.SetColumnsInRow("row_deleted", map[string][]byte{"col_a": []byte("a")}))
+			assert.NoError(t, engine.DeleteRow("row_deleted"))
+
+			// kv txn insert and delete.
+			txn, err := engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+			assert.NoError(t, err)
+			assert.NoError(t, txn.AppendKVTxn([]byte("txn_key_1"), []byte("txn_value_1")))
+			assert.NoError(t, txn.AppendKVTxn([]byte("txn_key_2"), []byte("txn_value_2")))
+			assert.NoError(t, txn.Commit())
+
+			txn, err = engine.NewTxn(walrecord.LogOperationDelete, walrecord.EntryTypeKV)
+			assert.NoError(t, err)
+			assert.NoError(t, txn.AppendKVTxn([]byte("txn_key_2"), nil))
+			assert.NoError(t, txn.Commit())
+
+			// chunked txn
+			var chunked bytes.Buffer
+			txn, err = engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeChunked)
+			assert.NoError(t, err)
+			for i := 0; i < 5; i++ {
+				chunk := []byte(gofakeit.LetterN(100))
+				chunked.Write(chunk)
+				assert.NoError(t, txn.AppendKVTxn([]byte("chunked_key"), chunk))
+			}
+			assert.NoError(t, txn.Commit())
+
+			// row txn upsert and column delete.
+			txn, err = engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeRow)
+			assert.NoError(t, err)
+			assert.NoError(t, txn.AppendColumnTxn([]byte("txn_row_1"), map[string][]byte{
+				"col_x": []byte("x"), "col_y": []byte("y"),
+			}))
+			assert.NoError(t, txn.AppendColumnTxn([]byte("txn_row_2"), map[string][]byte{"col_z": []byte("z")}))
+			assert.NoError(t, txn.Commit())
+
+			txn, err = engine.NewTxn(walrecord.LogOperationDelete, walrecord.EntryTypeRow)
+			assert.NoError(t, err)
+			assert.NoError(t, txn.AppendColumnTxn([]byte("txn_row_1"), map[string][]byte{"col_y": nil}))
+			assert.NoError(t, txn.Commit())
+
+			// uncommited txn should never be recovered.
+			txn, err = engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeRow)
+			assert.NoError(t, err)
+			assert.NoError(t, txn.AppendColumnTxn([]byte("uncommited_row"), map[string][]byte{"col": []byte("v")}))
+
+			// nothing should have reached the btree store before the crash.
+			_, err = engine.dataStore.Get([]byte("put_key"))
+			assert.ErrorIs(t, err, ErrKeyNotFound)
+			_, err = engine.dataStore.GetRowColumns([]byte("row_1"), nil)
+			assert.ErrorIs(t, err, ErrKeyNotFound)
+
+			crashEngine(t, engine)
+
+			engine, err = NewStorageEngine(baseDir, namespace, config)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				assert.NoError(t, engine.Close(context.Background()))
+			})
+			assert.Greater(t, engine.RecoveredWALCount(), 0)
+
+			value, err := engine.Get([]byte("put_key"))
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("put_value"), value)
+
+			_, err = engine.Get([]byte("deleted_key"))
+			assert.ErrorIs(t, err, ErrKeyNotFound)
+
+			columns, err := engine.GetRowColumns("row_1", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, map[string][]byte{"col_a": []byte("a_updated"), "col_c": []byte("c")}, columns)
+
+			// rows missing in the btree store are returned without any columns.
+			columns, err = engine.GetRowColumns("row_deleted", nil)
+			assert.NoError(t, err)
+			assert.Empty(t, columns)
+
+			value, err = engine.Get([]byte("txn_key_1"))
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("txn_value_1"), value)
+
+			_, err = engine.Get([]byte("txn_key_2"))
+			assert.ErrorIs(t, err, ErrKeyNotFound)
+
+			value, err = engine.Get([]byte("chunked_key"))
+			assert.NoError(t, err)
+			assert.Equal(t, chunked.Bytes(), value)
+
+			columns, err = engine.GetRowColumns("txn_row_1", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, map[string][]byte{"col_x": []byte("x")}, columns)
+
+			columns, err = engine.GetRowColumns("txn_row_2", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, map[string][]byte{"col_z": []byte("z")}, columns)
+
+			_, err = engine.GetRowColumns("uncommited_row", nil)
+			assert.ErrorIs(t, err, ErrKeyNotFound)
+		})
+	}
+}
+
+func TestEngine_WalRepairOnOpen(t *testing.T) {
+	baseDir := t.TempDir()
+	namespace := "test_wal_repair"
+	segment := filepath.Join(baseDir, namespace, walDirName, "000000001.seg.wal")
+
+	config := NewDefaultEngineConfig()
+	config.BtreeConfig.Namespace = namespace
+
+	engine, err := NewStorageEngine(baseDir, namespace, config)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, engine.Put([]byte(fmt.Sprintf("key_%d", i)), []byte(gofakeit.LetterN(100))))
+	}
+	assert.NoError(t, engine.Close(context.Background()))
+
+	t.Run("torn_tail_truncated", func(t *testing.T) {
+		info, err := os.Stat(segment)
+		require.NoError(t, err)
+		require.NoError(t, os.Truncate(segment, info.Size()-20))
+
+		engine, err := NewStorageEngine(baseDir, namespace, config)
+		require.NoError(t, err, "torn tail should not fail the open")
+		assert.Equal(t, 9, engine.RecoveredWALCount())
+		_, err = engine.Get([]byte("key_9"))
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+		_, err = engine.Get([]byte("key_8"))
+		assert.NoError(t, err)
+		assert.NoError(t, engine.Put([]byte("key_9"), []byte("after_repair")))
+		assert.NoError(t, engine.Close(context.Background()))
+
+		entries, err := os.ReadDir(filepath.Join(baseDir, namespace, walDirName, "quarantine"))
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("mid_log_corruption", func(t *testing.T) {
+		f, err := os.OpenFile(segment, os.O_RDWR, 0644)
+		require.NoError(t, err)
+		_, err = f.WriteAt([]byte("#"), 20)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		_, err = NewStorageEngine(baseDir, namespace, config)
+		assert.ErrorIs(t, err, ErrWalCorrupted)
+
+		config.WalConfig.RecoveryMode = wal.RecoveryModeSalvage
+		engine, err := NewStorageEngine(baseDir, namespace, config)
+		require.NoError(t, err, "salvage mode should open the corrupted wal")
+
+		// records flushed to the btree store by the previous recovery are still present,
+		// key_9 was only in the wal and was lost with the salvaged tail.
+		value, err := engine.Get([]byte("key_8"))
+		assert.NoError(t, err)
+		assert.NotEmpty(t, value)
+		_, err = engine.Get([]byte("key_9"))
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+		assert.NoError(t, engine.Put([]byte("key_10"), []byte("after_salvage")))
+		assert.NoError(t, engine.Close(context.Background()))
+
+		// checkpoint was moved back, so records appended after the salvage are recovered.
+		engine, err = NewStorageEngine(baseDir, namespace, config)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			assert.NoError(t, engine.Close(context.Background()))
+		})
+		assert.Equal(t, 1, engine.RecoveredWALCount())
+		value, err = engine.Get([]byte("key_10"))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("after_salvage"), value)
+	})
+}
+
+func TestEngine_CheckpointInOlderSegment(t *testing.T) {
+	namespace := "test_wal_checkpoint"
+	config := NewDefaultEngineConfig()
+	config.BtreeConfig.Namespace = namespace
+	config.WalConfig.SegmentSize = 64 * 1024
+
+	// setup leaves the checkpoint in the second of the three or more segments, that are not validated on open.
+	setup := func(t *testing.T) (string, *wal.Offset) {
+		baseDir := t.TempDir()
+		engine, err := NewStorageEngine(baseDir, namespace, config)
+		require.NoError(t, err)
+		fill := func(prefix string) {
+			for i := range 20 {
+				require.NoError(t, engine.Put([]byte(fmt.Sprintf("%s_%d", prefix, i)), []byte(gofakeit.LetterN(4096))))
+			}
+		}
+		fill("before")
+		for i := 0; i < 5; i++ {
+			require.NoError(t, engine.Put([]byte(fmt.Sprintf("key_%d", i)), []byte(gofakeit.LetterN(100))))
+		}
+		flushActiveMemTable(t, engine)
+
+		var value []byte
+		require.Eventually(t, func() bool {
+			value, err = engine.dataStore.RetrieveMetadata(sysKeyWalCheckPoint)
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond, "checkpoint should be saved")
+		checkpoint := UnmarshalMetadata(value).Pos
+		require.Equal(t, uint32(2), checkpoint.SegmentId)
+
+		fill("after")
+		require.Greater(t, len(engine.walIO.SegmentIDs()), 2)
+		require.NoError(t, engine.Close(context.Background()))
+		return baseDir, checkpoint
+	}
+
+	t.Run("corrupted_record", func(t *testing.T) {
+		baseDir, checkpoint := setup(t)
+		segment := filepath.Join(baseDir, namespace, walDirName, "000000002.seg.wal")
+		f, err := os.OpenFile(segment, os.O_RDWR, 0644)
+		require.NoError(t, err)
+		// first byte of the data of the checkpoint record, after its 7 bytes chunk header.
+		_, err = f.WriteAt([]byte("#"), int64(checkpoint.BlockNumber)*32*1024+checkpoint.ChunkOffset+7)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		_, err = NewStorageEngine(baseDir, namespace, config)
+		assert.ErrorIs(t, err, ErrWalCorrupted, "corrupted checkpoint record should fail the recovery")
+	})
+
+	t.Run("missing_segment", func(t *testing.T) {
+		baseDir, _ := setup(t)
+		require.NoError(t, os.Remove(filepath.Join(baseDir, namespace, walDirName, "000000002.seg.wal")))
+
+		_, err := NewStorageEngine(baseDir, namespace, config)
+		assert.ErrorIs(t, err, ErrWalCorrupted, "checkpoint in a missing segment should fail the recovery")
+	})
+}
@@ -0,0 +1,356 @@
+package dbkernel
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
+	"github.com/ankur-anand/unisondb/dbkernel/vfs"
+	"github.com/ankur-anand/unisondb/dbkernel/wal"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/gofrs/flock"
+	"github.com/hashicorp/go-metrics"
+)
+
+var (
+	// ErrStaleEpoch is returned for the records written by a primary that was replaced by the promotion of another node.
+	ErrStaleEpoch = errors.New("record is from a stale epoch of the namespace")
+	// ErrIncompleteWAL is returned when the btree store has to be rebuilt from the wal, but the wal
+	// doesn't have every record of the namespace anymore.
+	ErrIncompleteWAL = errors.New("wal doesn't have every record of the namespace")
+)
+
+var mKeyEpochStartTotal = append(packageKey, "epoch", "start", "total")
+
+// epochEntrySize is the size of an encoded epochStart, the epoch and its first lsn.
+const epochEntrySize = 16
+
+// epochStart is the first lsn written in the epoch of the namespace.
+// The epoch is bumped every time a replica is promoted to be the primary of the namespace.
+type epochStart struct {
+	epoch uint64
+	lsn   uint64
+}
+
+func encodeEpochs(epochs []epochStart) []byte {
+	buf := make([]byte, 0, len(epochs)*epochEntrySize)
+	for _, e := range epochs {
+		buf = binary.LittleEndian.AppendUint64(buf, e.epoch)
+		buf = binary.LittleEndian.AppendUint64(buf, e.lsn)
+	}
+	return buf
+}
+
+func decodeEpochs(data []byte) ([]epochStart, error) {
+	if len(data)%epochEntrySize != 0 {
+		return nil, fmt.Errorf("invalid epochs metadata of %d bytes: %w", len(data), ErrRecordCorrupted)
+	}
+	epochs := make([]epochStart, 0, len(data)/epochEntrySize)
+	for i := 0; i < len(data); i += epochEntrySize {
+		epochs = append(epochs, epochStart{
+			epoch: binary.LittleEndian.Uint64(data[i:]),
+			lsn:   binary.LittleEndian.Uint64(data[i+8:]),
+		})
+	}
+	return epochs, nil
+}
+
+func retrieveEpochs(store BTreeStore) ([]epochStart, error) {
+	data, err := store.RetrieveMetadata(sysKeyEpochs)
+	if errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeEpochs(data)
+}
+
+// loadEpochs loads the epochs of the namespace saved in the btree store.
+func (e *Engine) loadEpochs() error {
+	epochs, err := retrieveEpochs(e.dataStore)
+	if err != nil {
+		return err
+	}
+	e.epochs = epochs
+	e.epoch.Store(0)
+	if len(epochs) > 0 {
+		e.epoch.Store(epochs[len(epochs)-1].epoch)
+	}
+	return nil
+}
+
+// saveEpochs saves the epochs of the namespace and syncs them, the records of an epoch
+// are never appended to the wal before its start is durable.
+func (e *Engine) saveEpochs() error {
+	if err := e.dataStore.StoreMetadata(sysKeyEpochs, encodeEpochs(e.epochs)); err != nil {
+		return err
+	}
+	return e.dataStore.FSync()
+}
+
+// startEpoch starts the epoch from the lsn, it's called with the e.mu held.
+func (e *Engine) startEpoch(epoch, lsn uint64) error {
+	e.epochs = append(e.epochs, epochStart{epoch: epoch, lsn: lsn})
+	if err := e.saveEpochs(); err != nil {
+		e.epochs = e.epochs[:len(e.epochs)-1]
+		return err
+	}
+	e.epoch.Store(epoch)
+	metrics.IncrCounterWithLabels(mKeyEpochStartTotal, 1, e.metricsLabel)
+	slog.Info("[kvalchemy.dbengine] epoch started", "namespace", e.namespace, "epoch", epoch, "lsn", lsn)
+	return nil
+}
+
+// replicatedEpoch checks the epoch of the record received from the upstream against the epoch of the replica.
+// The records of a newer epoch start it on the replica, it's called with the e.mu held.
+func (e *Engine) replicatedEpoch(record *logcodec.LogRecord) error {
+	current := e.epoch.Load()
+	switch {
+	case record.Epoch < current:
+		return fmt.Errorf("%w: record epoch %d at lsn %d, namespace epoch %d", ErrStaleEpoch, record.Epoch, record.LSN, current)
+	case record.Epoch > current:
+		return e.startEpoch(record.Epoch, record.LSN)
+	}
+	return nil
+}
+
+// Epoch returns the current epoch of the namespace, zero till a replica of it is promoted.
+func (e *Engine) Epoch() uint64 {
+	return e.epoch.Load()
+}
+
+// EpochEnd returns the lsn of the last record written in the epoch or an epoch before it.
+// A node that rejoins from the epoch has diverged if it has records after the lsn.
+func (e *Engine) EpochEnd(epoch uint64) uint64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, start := range e.epochs {
+		if start.epoch > epoch {
+			return start.lsn - 1
+		}
+	}
+	return e.lastLSN.Load()
+}
+
+// Promote makes the replica the primary of the namespace, in a new epoch that starts after its last record.
+// The records still streamed from the old primary are rejected from then on, and the txns it left open
+// are never committed. The engine should be opened as a primary from then on.
+func (e *Engine) Promote() (uint64, error) {
+	if e.shutdown.Load() {
+		return 0, ErrInCloseProcess
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.replica == nil {
+		return 0, ErrNotReplica
+	}
+
+	epoch := e.epoch.Load() + 1
+	if err := e.startEpoch(epoch, e.lastLSN.Load()+1); err != nil {
+		return 0, err
+	}
+	e.replica = nil
+	e.following.Store(false)
+	if e.config.Replication.SyncReplicas > 0 {
+		e.syncReplicas = newSyncReplicas(e.config.Replication, e.metricsLabel)
+	}
+	slog.Info("[kvalchemy.dbengine] replica promoted", "namespace", e.namespace, "epoch", epoch,
+		"last_lsn", e.lastLSN.Load())
+	return epoch, nil
+}
+
+// trackEpoch moves the recovered epochs to the epoch of the record, for the epochs not saved in the btree store.
+func (wr *walRecovery) trackEpoch(record *logcodec.LogRecord) {
+	if len(wr.epochs) > 0 && record.Epoch <= wr.epochs[len(wr.epochs)-1].epoch {
+		return
+	}
+	if len(wr.epochs) == 0 && record.Epoch == 0 {
+		return
+	}
+	wr.epochs = append(wr.epochs, epochStart{epoch: record.Epoch, lsn: record.LSN})
+	wr.epochsChanged = true
+}
+
+// TruncateWAL removes every record after the lsn from the wal of the closed namespace, it's used by
+// a demoted primary to drop the records the new primary never received before it follows it.
+// The removed records are quarantined. If the btree store already has any of the removed records,
+// it's moved aside and rebuilt from the wal on the next open, that needs every record of the namespace
+// in the wal, else ErrIncompleteWAL is returned and the namespace must be restored from a snapshot.
+func TruncateWAL(dataDir, namespace string, conf *EngineConfig, lsn uint64) error {
+	nsDir := filepath.Join(dataDir, namespace)
+	walDir := filepath.Join(nsDir, walDirName)
+	fs := vfs.OrDefault(conf.FS)
+
+	if vfs.IsDefault(fs) {
+		fileLock := flock.New(filepath.Join(nsDir, pidLockName))
+		if err := tryFileLock(fileLock); err != nil {
+			return err
+		}
+		defer fileLock.Unlock()
+	}
+
+	walConfig := conf.WalConfig
+	walConfig.FS = conf.walFS()
+	cut, complete, err := findWALCut(walDir, namespace, &walConfig, lsn)
+	if err != nil || cut == nil {
+		return err
+	}
+
+	dbFile := filepath.Join(nsDir, dbFileName)
+	applied, err := truncateStore(conf, fs, dbFile, cut, lsn)
+	if err != nil {
+		return err
+	}
+	if applied && !complete {
+		return fmt.Errorf("%w: btree store has records after lsn %d", ErrIncompleteWAL, lsn)
+	}
+	if applied {
+		if err := moveAside(fs, dbFile); err != nil {
+			return err
+		}
+	}
+	historyFile := filepath.Join(nsDir, historyFileName)
+	indexed, err := historyIndexed(conf, fs, historyFile, cut)
+	if err != nil {
+		return err
+	}
+	if indexed {
+		if err := moveAside(fs, historyFile); err != nil {
+			return err
+		}
+	}
+
+	slog.Warn("[kvalchemy.dbengine] truncating divergent wal tail", "namespace", namespace, "after_lsn", lsn,
+		"segment", cut.SegmentId, "block", cut.BlockNumber, "offset", cut.ChunkOffset, "btree_rebuilt", applied)
+	return wal.TruncateFrom(walDir, namespace, &walConfig, cut, metrics.Default())
+}
+
+// findWALCut returns the offset of the first record after the lsn, nil if there is none.
+// complete is true if the wal has every record from the first lsn.
+func findWALCut(walDir, namespace string, config *wal.Config, lsn uint64) (cut *wal.Offset, complete bool, err error) {
+	walIO, err := wal.NewWalIO(walDir, namespace, config, metrics.Default())
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		err = errors.Join(err, walIO.Close())
+	}()
+
+	reader, err := walIO.NewReader()
+	if err != nil {
+		return nil, false, err
+	}
+	first := true
+	for {
+		value, pos, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, complete, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		recordLSN, err := logcodec.DecodeLSN(value)
+		if err != nil {
+			return nil, false, err
+		}
+		if first {
+			complete = recordLSN == 1
+			first = false
+		}
+		if recordLSN > lsn {
+			return pos, complete, nil
+		}
+	}
+}
+
+// truncateStore reports if the btree store has applied the wal from the cut. If it hasn't,
+// the epochs started after the lsn are removed from it.
+func truncateStore(conf *EngineConfig, fs vfs.FS, path string, cut *wal.Offset, lsn uint64) (applied bool, err error) {
+	store, err := openExistingStore(conf, fs, path)
+	if err != nil || store == nil {
+		return false, err
+	}
+	defer func() {
+		err = errors.Join(err, store.Close())
+	}()
+
+	data, err := store.RetrieveMetadata(sysKeyWalCheckPoint)
+	if err != nil && !errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return false, err
+	}
+	if len(data) != 0 {
+		checkpoint := UnmarshalMetadata(data).Pos
+		if checkpoint.SegmentId != 0 && !isNewChunkPosition(cut, checkpoint) {
+			return true, nil
+		}
+	}
+
+	epochs, err := retrieveEpochs(store)
+	if err != nil {
+		return false, err
+	}
+	kept := epochs[:0]
+	for _, start := range epochs {
+		if start.lsn <= lsn {
+			kept = append(kept, start)
+		}
+	}
+	if len(kept) == len(epochs) {
+		return false, nil
+	}
+	if err := store.StoreMetadata(sysKeyEpochs, encodeEpochs(kept)); err != nil {
+		return false, err
+	}
+	return false, store.FSync()
+}
+
+// historyIndexed reports if the history index has indexed the wal from the cut.
+func historyIndexed(conf *EngineConfig, fs vfs.FS, path string, cut *wal.Offset) (indexed bool, err error) {
+	store, err := openExistingStore(conf, fs, path)
+	if err != nil || store == nil {
+		return false, err
+	}
+	defer func() {
+		err = errors.Join(err, store.Close())
+	}()
+
+	data, err := store.RetrieveMetadata(sysKeyHistoryCursor)
+	if errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !isNewChunkPosition(cut, wal.DecodeOffset(data)), nil
+}
+
+// openExistingStore opens the btree store at the path, nil if it doesn't exist.
+func openExistingStore(conf *EngineConfig, fs vfs.FS, path string) (BTreeStore, error) {
+	if _, err := fs.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return kvdrivers.Open(string(conf.DBEngine), kvdrivers.Options{
+		Path:         path,
+		Config:       conf.BtreeConfig,
+		DriverConfig: conf.DriverConfig,
+		FS:           fs,
+	})
+}
+
+// moveAside renames the btree store, so it's rebuilt from the wal on the next open.
+// It's kept for inspection like the quarantined wal records.
+func moveAside(fs vfs.FS, path string) error {
+	aside := fmt.Sprintf("%s.%d.diverged", path, time.Now().UnixNano())
+	if err := fs.Rename(path, aside); err != nil {
+		return err
+	}
+	slog.Warn("[kvalchemy.dbengine] btree store moved aside to be rebuilt from the wal", "path", path, "moved_to", aside)
+	return fs.SyncDir(filepath.Dir(path))
+}
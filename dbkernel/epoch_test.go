@@ -0,0 +1,188 @@
+package dbkernel
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_Promote(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+	primaryDir := t.TempDir()
+	primary, err := NewStorageEngine(primaryDir, "test_replica", config)
+	require.NoError(t, err)
+	for _, key := range []string{"key_1", "key_2", "key_3"} {
+		require.NoError(t, primary.Put([]byte(key), []byte("value")))
+	}
+
+	replicaDir := t.TempDir()
+	replica := newReplicaEngine(t, replicaDir)
+	replicate(t, primary, replica, nil)
+	_, err = primary.Promote()
+	assert.ErrorIs(t, err, ErrNotReplica)
+
+	epoch, err := replica.Promote()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), epoch)
+	assert.Equal(t, uint64(1), replica.Epoch())
+	assert.Equal(t, uint64(3), replica.EpochEnd(0))
+	require.NoError(t, replica.Put([]byte("key_4"), []byte("value")))
+	assert.Equal(t, uint64(4), replica.EpochEnd(1))
+
+	// the old primary keeps on writing till it learns about the promotion.
+	require.NoError(t, primary.Put([]byte("divergent"), []byte("value")))
+	stale := lastRecord(t, primary)
+	assert.ErrorIs(t, replica.ApplyReplicated(stale, stale, crc32.ChecksumIEEE(stale)), ErrNotReplica)
+
+	t.Run("follower", func(t *testing.T) {
+		follower := newReplicaEngine(t, t.TempDir())
+		t.Cleanup(func() {
+			assert.NoError(t, follower.Close(context.Background()))
+		})
+		replicate(t, replica, follower, nil)
+		assert.Equal(t, uint64(1), follower.Epoch())
+		assert.Equal(t, uint64(3), follower.EpochEnd(0))
+
+		require.NoError(t, primary.Put([]byte("divergent_2"), []byte("value")))
+		stale := lastRecord(t, primary)
+		err := follower.ApplyReplicated([]byte("offset"), stale, crc32.ChecksumIEEE(stale))
+		assert.ErrorIs(t, err, ErrStaleEpoch)
+	})
+
+	t.Run("demoted_rejoins", func(t *testing.T) {
+		// the divergent records are in the btree store as well, it's rebuilt from the wal.
+		flushMemTable(t, primary)
+		require.NoError(t, primary.Close(context.Background()))
+		require.NoError(t, TruncateWAL(primaryDir, "test_replica", config, replica.EpochEnd(primary.Epoch())))
+		quarantined, err := os.ReadDir(filepath.Join(primaryDir, "test_replica", walDirName, "quarantine"))
+		require.NoError(t, err)
+		require.Len(t, quarantined, 1)
+		assert.True(t, strings.HasSuffix(quarantined[0].Name(), ".divergent"))
+
+		demoted := newReplicaEngine(t, primaryDir)
+		t.Cleanup(func() {
+			assert.NoError(t, demoted.Close(context.Background()))
+		})
+		assert.Equal(t, uint64(3), demoted.LastLSN())
+		_, err = demoted.Get([]byte("divergent"))
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+
+		replicate(t, replica, demoted, nil)
+		assert.Equal(t, uint64(1), demoted.Epoch())
+		value, err := demoted.Get([]byte("key_4"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("value"), value)
+	})
+
+	// the epoch is saved with the promotion.
+	require.NoError(t, replica.Close(context.Background()))
+	reopened, err := NewStorageEngine(replicaDir, "test_replica", config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, reopened.Close(context.Background()))
+	})
+	assert.Equal(t, uint64(1), reopened.Epoch())
+	assert.Equal(t, uint64(3), reopened.EpochEnd(0))
+	require.NoError(t, reopened.Put([]byte("key_5"), []byte("value")))
+}
+
+func TestEngine_Promote_ClockAheadOfMaxDrift(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+	primary, err := NewStorageEngine(t.TempDir(), "test_replica", config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, primary.Close(context.Background()))
+	})
+	// clock of the primary is ahead of the replica by more than the max drift.
+	ahead := uint64(time.Now().Add(10 * time.Minute).UnixMilli())
+	primary.hlc = newHLC(func() uint64 {
+		return ahead
+	})
+	require.NoError(t, primary.Put([]byte("key"), []byte("value")))
+	written := primary.hlc.Last()
+
+	replica := newReplicaEngine(t, t.TempDir())
+	t.Cleanup(func() {
+		assert.NoError(t, replica.Close(context.Background()))
+	})
+	replicate(t, primary, replica, nil)
+	assert.GreaterOrEqual(t, replica.hlc.Last(), written, "clock should move past every applied record")
+
+	_, err = replica.Promote()
+	require.NoError(t, err)
+	assert.Greater(t, replica.Now(), written, "promoted replica should never issue a timestamp before its log")
+}
+
+func TestTruncateWAL_IncompleteWAL(t *testing.T) {
+	leader, err := NewStorageEngine(t.TempDir(), "test_replica", NewDefaultEngineConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, leader.Close(context.Background()))
+	})
+	for _, key := range []string{"key_1", "key_2", "key_3"} {
+		require.NoError(t, leader.Put([]byte(key), []byte("value")))
+	}
+	offset, err := leader.OffsetForLSN(1)
+	require.NoError(t, err)
+
+	// the replica only has the records after the first one of the namespace.
+	dir := t.TempDir()
+	replica := newReplicaEngine(t, dir)
+	reader, err := leader.NewReaderWithStart(offset)
+	require.NoError(t, err)
+	_, _, err = reader.Next()
+	require.NoError(t, err)
+	for {
+		data, pos, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.NoError(t, replica.ApplyFilteredReplicated(pos.Encode(), data, crc32.ChecksumIEEE(data)))
+	}
+	flushMemTable(t, replica)
+	require.NoError(t, replica.Close(context.Background()))
+
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+	err = TruncateWAL(dir, "test_replica", config, 2)
+	assert.ErrorIs(t, err, ErrIncompleteWAL)
+}
+
+// lastRecord returns the last record in the wal of the engine.
+func lastRecord(t *testing.T, engine *Engine) []byte {
+	t.Helper()
+	offset, err := engine.OffsetForLSN(engine.LastLSN())
+	require.NoError(t, err)
+	data, err := engine.walIO.Read(offset)
+	require.NoError(t, err)
+	return data
+}
+
+// flushMemTable flushes the records in the mem table of the engine to its btree store.
+func flushMemTable(t *testing.T, engine *Engine) {
+	t.Helper()
+	flushed := make(chan struct{}, 1)
+	engine.callback = func() {
+		flushed <- struct{}{}
+	}
+	engine.mu.Lock()
+	engine.rotateMemTable()
+	engine.mu.Unlock()
+	select {
+	case <-flushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the mem table flush")
+	}
+}
@@ -0,0 +1,397 @@
+package dbkernel
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
+	"github.com/ankur-anand/unisondb/dbkernel/wal"
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/hashicorp/go-metrics"
+)
+
+var (
+	mKeyHistoryTotal    = append(packageKey, "history", "total")
+	mKeyHistoryDuration = append(packageKey, "history", "durations", "seconds")
+)
+
+// ChangeEvent is a single logical change of a key, as present in the WAL.
+type ChangeEvent struct {
+	Key       []byte
+	Operation walrecord.LogOperation
+	EntryType walrecord.EntryType
+	// Value is the value put to the key, the chunked value is reassembled.
+	Value []byte
+	// Columns are the columns set or deleted from the row.
+	Columns map[string][]byte
+	HLC     uint64
+	// Index is the WAL index of the record, for txn it's of the commit record as the change is visible from there.
+	Index uint64
+	// TxnID is empty if the change was not part of a txn.
+	TxnID []byte
+}
+
+// History returns the changes of the key made between the from and to hybrid logical clock timestamp, both included,
+// in the order they were made. At most limit changes are returned, if limit is positive.
+// Only the changes still present in the WAL are returned, see HistoryHorizon.
+func (e *Engine) History(key []byte, from, to uint64, limit int) ([]ChangeEvent, error) {
+	if e.shutdown.Load() {
+		return nil, ErrInCloseProcess
+	}
+
+	metrics.IncrCounterWithLabels(mKeyHistoryTotal, 1, e.metricsLabel)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mKeyHistoryDuration, startTime, e.metricsLabel)
+	}()
+
+	horizon, err := e.HistoryHorizon()
+	if err != nil {
+		return nil, err
+	}
+	from = max(from, horizon)
+
+	var versions []keyVersion
+	if e.history != nil {
+		versions, err = e.history.versions(key, from, to, e.visibleOffset())
+	} else {
+		versions, err = e.indexedVersions(key, from, to)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && len(versions) > limit {
+		versions = versions[:limit]
+	}
+
+	events := make([]ChangeEvent, 0, len(versions))
+	for _, version := range versions {
+		event, err := e.changeEvent(key, version)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// HistoryHorizon returns the hybrid logical clock timestamp from which the WAL has every change,
+// zero if it has the complete history of the namespace. Without the HistoryIndex, changes older
+// than the VersionRetention are not kept, so the horizon is never before that.
+func (e *Engine) HistoryHorizon() (uint64, error) {
+	horizon, err := e.walHorizon()
+	if err != nil || e.history != nil {
+		return horizon, err
+	}
+	return max(horizon, e.versions.prunedHorizon()), nil
+}
+
+// walHorizon returns the hybrid logical clock timestamp from which the WAL has every change,
+// zero if it has the complete history of the namespace.
+func (e *Engine) walHorizon() (uint64, error) {
+	reader, err := e.walIO.NewReader()
+	if err != nil {
+		return 0, err
+	}
+	value, _, err := reader.Next()
+	if errors.Is(err, io.EOF) {
+		return e.emptyWalHorizon(), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	record, err := logcodec.DecodeRecord(value)
+	if err != nil {
+		return 0, err
+	}
+	if record.LSN == 1 {
+		return 0, nil
+	}
+	return record.HLC, nil
+}
+
+// indexedVersions returns the changes of the key between the hlc, from the in memory version index.
+func (e *Engine) indexedVersions(key []byte, from, to uint64) ([]keyVersion, error) {
+	lookup, err := e.versions.lookup(e.walIO, key, to, e.visibleOffset(), e.emptyWalHorizon)
+	if err != nil {
+		return nil, err
+	}
+	i := sortSearchVersions(lookup.versions, from)
+	return dedupeVersions(lookup.versions[i:]), nil
+}
+
+func (e *Engine) changeEvent(key []byte, version keyVersion) (ChangeEvent, error) {
+	event := ChangeEvent{
+		Key:       key,
+		Operation: version.operation,
+		EntryType: version.entryType,
+		HLC:       version.hlc,
+		Index:     version.index,
+	}
+
+	record, err := e.readVersionRecord(version)
+	if err != nil {
+		return event, err
+	}
+	event.TxnID = record.TxnID
+
+	switch {
+	case version.operation == walrecord.LogOperationDeleteRow:
+	case version.entryType == walrecord.EntryTypeRow:
+		event.Columns = make(map[string][]byte)
+		for _, merged := range version.merged {
+			mergedRecord, err := e.readVersionRecord(merged)
+			if err != nil {
+				return event, err
+			}
+			maps.Copy(event.Columns, getColumnsValue(mergedRecord))
+		}
+		maps.Copy(event.Columns, getColumnsValue(record))
+	case version.operation == walrecord.LogOperationDelete:
+	case version.entryType == walrecord.EntryTypeChunked:
+		event.Value, err = e.reconstructBatchValue(record)
+	default:
+		if crc32.ChecksumIEEE(record.Value()) != record.CRC32Checksum {
+			return event, ErrRecordCorrupted
+		}
+		event.Value = record.Value()
+	}
+	return event, err
+}
+
+const (
+	historyFileName = "history.db"
+	// historyColumnKeyLen is the length of the column key of a change, hlc and index followed by
+	// the position of the change in the batch record and the position of its prepared record in the txn.
+	historyColumnKeyLen = 24
+	// legacyHistoryColumnKeyLen is the length of the column key without the positions.
+	legacyHistoryColumnKeyLen = 16
+)
+
+var sysKeyHistoryCursor = []byte("sys.kv.alchemy.key.history.cursor")
+
+// historyIndex is the on disk index of every change of the keys to its offset in the WAL.
+// Every key is stored as a row in its own BTreeStore, with a column for each change, the columns key
+// is the hlc, index and the positions of the change, so they sort in the order they were made.
+//
+// It's maintained when the mem tables are flushed, the changes of the records in the WAL after the
+// cursor are read from the WAL itself.
+type historyIndex struct {
+	mu    sync.Mutex
+	store BTreeStore
+	walIO *wal.WalIO
+	// cursor is the offset of the last record indexed, nil if nothing is indexed yet.
+	cursor *wal.Offset
+}
+
+func openHistoryIndex(store BTreeStore, walIO *wal.WalIO) (*historyIndex, error) {
+	h := &historyIndex{store: store, walIO: walIO}
+	data, err := store.RetrieveMetadata(sysKeyHistoryCursor)
+	if errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.cursor = wal.DecodeOffset(data)
+	return h, nil
+}
+
+// tail returns a version index positioned at the cursor, that indexes the records after it.
+func (h *historyIndex) tail() *versionIndex {
+	vi := newVersionIndex(0)
+	vi.lastOffset = h.cursor
+	// horizon is never used.
+	vi.scanned = true
+	return vi
+}
+
+// indexTill indexes every change made till the record at the offset.
+func (h *historyIndex) indexTill(till *wal.Offset) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if till == nil || (h.cursor != nil && !isNewChunkPosition(till, h.cursor)) {
+		return nil
+	}
+
+	vi := h.tail()
+	if err := vi.catchUp(h.walIO, till, nil); err != nil {
+		return err
+	}
+	if vi.lastOffset == h.cursor {
+		return nil
+	}
+
+	rowKeys := make([][]byte, 0, len(vi.versions))
+	columnEntries := make([]map[string][]byte, 0, len(vi.versions))
+	for key, versions := range vi.versions {
+		columns := make(map[string][]byte, len(versions))
+		for _, version := range versions {
+			columns[string(historyColumnKey(version))] = marshalHistoryVersion(version)
+		}
+		rowKeys = append(rowKeys, []byte(key))
+		columnEntries = append(columnEntries, columns)
+	}
+
+	if err := h.store.SetManyRowColumns(rowKeys, columnEntries); err != nil {
+		return fmt.Errorf("index history failed: %w", err)
+	}
+	if err := h.store.StoreMetadata(sysKeyHistoryCursor, vi.lastOffset.Encode()); err != nil {
+		return fmt.Errorf("index history failed: %w", err)
+	}
+	if err := h.store.FSync(); err != nil {
+		return fmt.Errorf("index history failed: %w", err)
+	}
+	h.cursor = vi.lastOffset
+	return nil
+}
+
+// versions returns the changes of the key between the hlc, both from the store and the WAL after the cursor,
+// till the record at the offset, if provided.
+func (h *historyIndex) versions(key []byte, from, to uint64, till *wal.Offset) ([]keyVersion, error) {
+	versions, err := h.allVersions(key, from, to, till)
+	if err != nil {
+		return nil, err
+	}
+	return dedupeVersions(versions), nil
+}
+
+// lookup returns the changes of the key till the hlc, like versionIndex.lookup. The horizon is left to the caller.
+func (h *historyIndex) lookup(key []byte, hlc uint64, till *wal.Offset) (versionLookup, error) {
+	versions, err := h.allVersions(key, 0, math.MaxUint64, till)
+	if err != nil {
+		return versionLookup{}, err
+	}
+	i := sort.Search(len(versions), func(i int) bool {
+		return versions[i].hlc > hlc
+	})
+	return versionLookup{
+		versions:     versions[:i:i],
+		changedAfter: i < len(versions),
+	}, nil
+}
+
+// allVersions returns every change of the key between the hlc, without deduping the ones made by the same
+// batch record or txn.
+func (h *historyIndex) allVersions(key []byte, from, to uint64, till *wal.Offset) ([]keyVersion, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	columns, err := h.store.GetRowColumns(key, func(columnKey []byte) bool {
+		if len(columnKey) != historyColumnKeyLen && len(columnKey) != legacyHistoryColumnKeyLen {
+			return false
+		}
+		hlc := binary.BigEndian.Uint64(columnKey)
+		return hlc >= from && hlc <= to
+	})
+	if err != nil && !errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return nil, err
+	}
+
+	columnKeys := slices.Sorted(maps.Keys(columns))
+	versions := make([]keyVersion, 0, len(columnKeys))
+	for _, columnKey := range columnKeys {
+		version, err := unmarshalHistoryVersion([]byte(columnKey), columns[columnKey])
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	vi := h.tail()
+	if err := vi.catchUp(h.walIO, till, nil); err != nil {
+		return nil, err
+	}
+	tail := vi.versions[string(key)]
+	for _, version := range tail[sortSearchVersions(tail, from):] {
+		if version.hlc > to {
+			break
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// historyColumnKey returns the column key of the change, unique for every change of the key
+// even if made by the same batch record or txn.
+func historyColumnKey(version keyVersion) []byte {
+	buf := make([]byte, historyColumnKeyLen)
+	binary.BigEndian.PutUint64(buf, version.hlc)
+	binary.BigEndian.PutUint64(buf[8:], version.index)
+	binary.BigEndian.PutUint32(buf[16:], uint32(version.entry))
+	binary.BigEndian.PutUint32(buf[20:], uint32(version.prepared))
+	return buf
+}
+
+// marshalHistoryVersion encodes the operation, entry type and the offset of the change,
+// followed by its position in the batch record if it was made by a batch.
+func marshalHistoryVersion(version keyVersion) []byte {
+	data := append([]byte{byte(version.operation), byte(version.entryType)}, version.offset.Encode()...)
+	if version.entry > 0 {
+		data = binary.AppendUvarint(data, uint64(version.entry))
+	}
+	return data
+}
+
+func unmarshalHistoryVersion(columnKey, data []byte) (keyVersion, error) {
+	if len(data) < 3 {
+		return keyVersion{}, fmt.Errorf("invalid history entry of %d bytes: %w", len(data), ErrRecordCorrupted)
+	}
+	version := keyVersion{
+		hlc:       binary.BigEndian.Uint64(columnKey),
+		index:     binary.BigEndian.Uint64(columnKey[8:]),
+		operation: walrecord.LogOperation(data[0]),
+		entryType: walrecord.EntryType(data[1]),
+		offset:    wal.DecodeOffset(data[2:]),
+	}
+	if len(columnKey) == historyColumnKeyLen {
+		version.prepared = int(binary.BigEndian.Uint32(columnKey[20:]))
+	}
+	// offset is encoded as uvarints, so it's re-encoded to the same length.
+	if n := 2 + len(version.offset.Encode()); n < len(data) {
+		entry, _ := binary.Uvarint(data[n:])
+		version.entry = int(entry)
+	}
+	return version, nil
+}
+
+// sortSearchVersions returns the position of the first change at or after the hlc.
+func sortSearchVersions(versions []keyVersion, hlc uint64) int {
+	return sort.Search(len(versions), func(i int) bool {
+		return versions[i].hlc >= hlc
+	})
+}
+
+// dedupeVersions keeps the last change of the key made by a batch record or txn, as only that one is visible.
+// Every prepared record of a row txn sets or deletes its own columns, so they are merged into the last one instead.
+func dedupeVersions(versions []keyVersion) []keyVersion {
+	result := versions[:0:0]
+	for _, version := range versions {
+		n := len(result)
+		if n == 0 || result[n-1].hlc != version.hlc || result[n-1].index != version.index {
+			result = append(result, version)
+			continue
+		}
+
+		if version.prepared > 0 && version.entryType == walrecord.EntryTypeRow &&
+			version.operation != walrecord.LogOperationDeleteRow {
+			last := result[n-1]
+			version.merged = append(slices.Clip(last.merged), last)
+			version.merged[len(version.merged)-1].merged = nil
+		}
+		result[n-1] = version
+	}
+	return result
+}
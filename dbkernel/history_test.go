@@ -0,0 +1,198 @@
+package dbkernel
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flushActiveMemTable seals the active mem table and flushes it to the btree store.
+func flushActiveMemTable(t *testing.T, e *Engine) {
+	t.Helper()
+	e.mu.Lock()
+	e.rotateMemTableNoFlush()
+	e.mu.Unlock()
+	e.handleFlush(context.Background())
+}
+
+func TestEngine_History(t *testing.T) {
+	for _, historyIndex := range []bool{false, true} {
+		t.Run(map[bool]string{false: "in_memory", true: "on_disk"}[historyIndex], func(t *testing.T) {
+			config := NewDefaultEngineConfig()
+			config.DBEngine = BoltDBEngine
+			config.HistoryIndex = historyIndex
+			engine, err := NewStorageEngine(t.TempDir(), "test_history", config)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				assert.NoError(t, engine.Close(context.Background()))
+			})
+
+			key := []byte("key")
+			require.NoError(t, engine.Put(key, []byte("v1")))
+			require.NoError(t, engine.Put([]byte("other"), []byte("other")))
+			require.NoError(t, engine.Put(key, []byte("v2")))
+			afterV2 := engine.Now()
+			// some of the changes are in the index, rest are still only in the wal.
+			flushActiveMemTable(t, engine)
+			require.NoError(t, engine.Delete(key))
+
+			txn, err := engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+			require.NoError(t, err)
+			require.NoError(t, txn.AppendKVTxn(key, []byte("txn_first")))
+			require.NoError(t, txn.AppendKVTxn(key, []byte("txn_last")))
+			require.NoError(t, txn.Commit())
+
+			var chunked string
+			txn, err = engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeChunked)
+			require.NoError(t, err)
+			for range 3 {
+				chunk := gofakeit.LetterN(1024)
+				chunked += chunk
+				require.NoError(t, txn.AppendKVTxn(key, []byte(chunk)))
+			}
+			require.NoError(t, txn.Commit())
+
+			events, err := engine.History(key, 0, math.MaxUint64, 0)
+			require.NoError(t, err)
+			require.Len(t, events, 5)
+
+			assert.Equal(t, walrecord.LogOperationInsert, events[0].Operation)
+			assert.Equal(t, []byte("v1"), events[0].Value)
+			assert.Empty(t, events[0].TxnID)
+			assert.Equal(t, []byte("v2"), events[1].Value)
+			assert.Equal(t, walrecord.LogOperationDelete, events[2].Operation)
+			assert.Equal(t, []byte("txn_last"), events[3].Value, "only the visible change of the txn is an event")
+			assert.NotEmpty(t, events[3].TxnID)
+			assert.Equal(t, walrecord.EntryTypeChunked, events[4].EntryType)
+			assert.Equal(t, chunked, string(events[4].Value), "chunked value should be a single event")
+
+			for i := 1; i < len(events); i++ {
+				assert.Greater(t, events[i].HLC, events[i-1].HLC)
+				assert.Greater(t, events[i].Index, events[i-1].Index)
+			}
+
+			events, err = engine.History(key, afterV2, math.MaxUint64, 2)
+			require.NoError(t, err)
+			require.Len(t, events, 2)
+			assert.Equal(t, walrecord.LogOperationDelete, events[0].Operation)
+			assert.Equal(t, []byte("txn_last"), events[1].Value)
+
+			events, err = engine.History(key, 0, afterV2, 0)
+			require.NoError(t, err)
+			assert.Len(t, events, 2)
+
+			events, err = engine.History([]byte("unknown"), 0, math.MaxUint64, 0)
+			require.NoError(t, err)
+			assert.Empty(t, events)
+		})
+	}
+}
+
+func TestEngine_History_Row(t *testing.T) {
+	engine, err := NewStorageEngine(t.TempDir(), "test_history_row", NewDefaultEngineConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+
+	row := "row"
+	require.NoError(t, engine.SetColumnsInRow(row, map[string][]byte{"a": []byte("a1"), "b": []byte("b1")}))
+	require.NoError(t, engine.DeleteColumnsFromRow(row, map[string][]byte{"b": nil}))
+	require.NoError(t, engine.DeleteRow(row))
+
+	events, err := engine.History([]byte(row), 0, math.MaxUint64, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, map[string][]byte{"a": []byte("a1"), "b": []byte("b1")}, events[0].Columns)
+	assert.Equal(t, walrecord.LogOperationDelete, events[1].Operation)
+	assert.Contains(t, events[1].Columns, "b")
+	assert.Equal(t, walrecord.LogOperationDeleteRow, events[2].Operation)
+
+	// every prepared record of a row txn is a part of the same change.
+	txn, err := engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeRow)
+	require.NoError(t, err)
+	require.NoError(t, txn.AppendColumnTxn([]byte(row), map[string][]byte{"a": []byte("a2")}))
+	require.NoError(t, txn.AppendColumnTxn([]byte(row), map[string][]byte{"b": []byte("b2")}))
+	require.NoError(t, txn.Commit())
+
+	columns, err := engine.GetRowColumns(row, nil)
+	require.NoError(t, err)
+	events, err = engine.History([]byte(row), 0, math.MaxUint64, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	assert.Equal(t, columns, events[3].Columns)
+	assert.NotEmpty(t, events[3].TxnID)
+}
+
+func TestEngine_HistoryIndex_RowTxn(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+	config.HistoryIndex = true
+	engine, err := NewStorageEngine(t.TempDir(), "test_history_row_txn", config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+
+	row := "row"
+	txn, err := engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeRow)
+	require.NoError(t, err)
+	require.NoError(t, txn.AppendColumnTxn([]byte(row), map[string][]byte{"a": []byte("a1")}))
+	require.NoError(t, txn.AppendColumnTxn([]byte(row), map[string][]byte{"b": []byte("b1")}))
+	require.NoError(t, txn.Commit())
+	// commit record is indexed by the flush of a write after it.
+	require.NoError(t, engine.Put([]byte("key"), []byte("value")))
+	flushActiveMemTable(t, engine)
+
+	columns, err := engine.history.store.GetRowColumns([]byte(row), nil)
+	require.NoError(t, err)
+	assert.Len(t, columns, 2, "every prepared record should be indexed")
+
+	events, err := engine.History([]byte(row), 0, math.MaxUint64, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, map[string][]byte{"a": []byte("a1"), "b": []byte("b1")}, events[0].Columns)
+}
+
+func TestEngine_HistoryIndex_TxnAcrossFlush(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+	config.HistoryIndex = true
+	baseDir := t.TempDir()
+	engine, err := NewStorageEngine(baseDir, "test_history_txn", config)
+	require.NoError(t, err)
+
+	txn, err := engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+	require.NoError(t, err)
+	require.NoError(t, txn.AppendKVTxn([]byte("txn_key"), []byte("txn_value")))
+	require.NoError(t, engine.Put([]byte("key"), []byte("value")))
+	// index moves past the prepared record, before the txn commits.
+	flushActiveMemTable(t, engine)
+	require.NotNil(t, engine.history.cursor)
+	require.NoError(t, txn.Commit())
+	// commit record is indexed by the flush of a write after it.
+	require.NoError(t, engine.Put([]byte("key"), []byte("value")))
+	flushActiveMemTable(t, engine)
+	require.NoError(t, engine.Close(context.Background()))
+
+	engine, err = NewStorageEngine(baseDir, "test_history_txn", config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+
+	events, err := engine.History([]byte("txn_key"), 0, math.MaxUint64, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, []byte("txn_value"), events[0].Value)
+	assert.NotEmpty(t, events[0].TxnID)
+
+	columns, err := engine.history.store.GetRowColumns([]byte("txn_key"), nil)
+	assert.NoError(t, err)
+	assert.Len(t, columns, 1, "change should be read from the on disk index")
+}
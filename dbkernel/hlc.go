@@ -1,26 +1,47 @@
 package dbkernel
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
 	"time"
 )
 
-// wall clock can jump forward or backward by the ntp.
-// monotonic time don't.
-// the process get monotonic time at the start of the process, so during it's life-time
-// https://github.com/golang/go/blob/889abb17e125bb0f5d8de61bb80ef15fbe2a130d/src/runtime/time_nofake.go#L19
-var startTime = time.Now()
+// hybrid logical clock timestamp is encoded in an uint64,
+// 48 bits are the physical time in milliseconds since unix epoch, good till the year 10889.
+// 16 bits are the logical counter, that orders the events happening in the same millisecond.
+const (
+	hlcLogicalBits = 16
+	hlcLogicalMask = 1<<hlcLogicalBits - 1
+)
+
+// DefaultMaxClockDrift is how far ahead of the wall clock a timestamp observed by the HLC can be.
+const DefaultMaxClockDrift = time.Minute
 
-var monotonic time.Duration
+// ErrClockDrift is returned when an observed timestamp is ahead of the wall clock by more than the max drift.
+var ErrClockDrift = errors.New("hlc timestamp is ahead of the wall clock by more than the max drift")
 
-func initMonotonic() {
-	monotonic = time.Since(startTime)
+// EncodeHLC returns the hybrid logical clock timestamp of the physical time in milliseconds and the logical counter.
+func EncodeHLC(physical uint64, logical uint16) uint64 {
+	return physical<<hlcLogicalBits | uint64(logical)
 }
 
-// DecodeHLC Extract lastTime and counter.
+// DecodeHLC Extract physical time in milliseconds and logical counter.
 func DecodeHLC(encodedHLC uint64) (uint64, uint16) {
-	lastTime := encodedHLC >> 16           // Extract upper 48 bits
-	counter := uint16(encodedHLC & 0xFFFF) // Extract lower 16 bits
-	return lastTime, counter
+	return encodedHLC >> hlcLogicalBits, uint16(encodedHLC & hlcLogicalMask)
+}
+
+// HLCTime returns the physical time of the hybrid logical clock timestamp.
+func HLCTime(hlc uint64) time.Time {
+	physical, _ := DecodeHLC(hlc)
+	return time.UnixMilli(int64(physical))
+}
+
+// HLCFromTime returns the latest hybrid logical clock timestamp of the physical time,
+// reads at it see every change made till the time.
+func HLCFromTime(t time.Time) uint64 {
+	return EncodeHLC(uint64(t.UnixMilli()), math.MaxUint16)
 }
 
 // IsConcurrent return true if the two hybrid logical clock have happened at the same time
@@ -31,19 +52,107 @@ func IsConcurrent(hlc1, hlc2 uint64) bool {
 	return lastTime1 == lastTime2 && counter1 != counter2
 }
 
-// HLCNow return an encoded hybrid logical lock.
-// 48 bits are current timestamp
-// 16 bits are counter value. can track 65,536 (2^16) unique events per timestamp(nanosecond).
-func HLCNow(counter uint64) uint64 {
-	now := time.Now()
-	adjustment := monotonic - now.Sub(startTime)
+// HLC is a hybrid logical clock.
+// Timestamps issued by it are unique and always increasing, even if the wall clock goes backward,
+// and stay ahead of every timestamp it has observed from the other nodes with Update.
+type HLC struct {
+	mu sync.Mutex
+	// last is the latest timestamp issued or observed.
+	last uint64
+	// physicalNow returns the wall clock time in milliseconds.
+	physicalNow func() uint64
+	// maxDrift is how far ahead of the wall clock in milliseconds an observed timestamp can be,
+	// a node with a skewed clock, or a corrupt timestamp, would else move the clock ahead for good.
+	maxDrift uint64
+}
+
+// NewHLC returns a HLC driven by the wall clock, that observes timestamps till DefaultMaxClockDrift ahead of it.
+func NewHLC() *HLC {
+	return newHLC(func() uint64 {
+		return uint64(time.Now().UnixMilli())
+	})
+}
+
+func newHLC(physicalNow func() uint64) *HLC {
+	return &HLC{physicalNow: physicalNow, maxDrift: uint64(DefaultMaxClockDrift.Milliseconds())}
+}
+
+// SetMaxDrift sets how far ahead of the wall clock an observed timestamp can be.
+func (h *HLC) SetMaxDrift(maxDrift time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxDrift = uint64(maxDrift.Milliseconds())
+}
+
+// Now returns a new timestamp for a local event.
+func (h *HLC) Now() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	// prevents backward time shifts
-	if adjustment < 0 {
-		adjustment = 0
+	pt := h.physicalNow()
+	lastPT, lastLogical := DecodeHLC(h.last)
+	if pt > lastPT {
+		h.last = EncodeHLC(pt, 0)
+	} else {
+		h.last = tickHLC(lastPT, lastLogical)
 	}
+	return h.last
+}
+
+// Update merges the timestamp received from another node, or read back from the storage, into the clock.
+// It returns the new timestamp of the receive event, that is after both the remote and the local timestamps.
+// The timestamp ahead of the wall clock by more than the max drift is rejected with ErrClockDrift,
+// and the clock is left as is.
+func (h *HLC) Update(remote uint64) (uint64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	pt := h.physicalNow()
+	remotePT, _ := DecodeHLC(remote)
+	if remotePT > pt && remotePT-pt > h.maxDrift {
+		return h.last, fmt.Errorf("%w: %dms ahead", ErrClockDrift, remotePT-pt)
+	}
+	return h.merge(pt, remote), nil
+}
+
+// Restore merges a timestamp the node issued or accepted before, read back from the storage, into the clock.
+// It's never rejected, as that would let the clock go back once the wall clock steps back by more than the max drift.
+func (h *HLC) Restore(saved uint64) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.merge(h.physicalNow(), saved)
+}
 
-	ct := uint64(time.Now().Add(adjustment).UnixNano())
-	// (physical time << 16) | counter
-	return (ct << 16) | counter
+// merge moves the clock past both the remote timestamp and the physical time, it must be called with the mu held.
+func (h *HLC) merge(pt, remote uint64) uint64 {
+	lastPT, lastLogical := DecodeHLC(h.last)
+	remotePT, remoteLogical := DecodeHLC(remote)
+	switch maxPT := max(pt, lastPT, remotePT); {
+	case maxPT == lastPT && maxPT == remotePT:
+		h.last = tickHLC(maxPT, max(lastLogical, remoteLogical))
+	case maxPT == lastPT:
+		h.last = tickHLC(lastPT, lastLogical)
+	case maxPT == remotePT:
+		h.last = tickHLC(remotePT, remoteLogical)
+	default:
+		h.last = EncodeHLC(pt, 0)
+	}
+	return h.last
+}
+
+// Last returns the latest timestamp issued or observed by the clock.
+func (h *HLC) Last() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.last
+}
+
+// tickHLC returns the timestamp after the provided one.
+// once the logical counter is exhausted the physical time is moved ahead by a millisecond,
+// so the timestamps never collide.
+func tickHLC(physical uint64, logical uint16) uint64 {
+	if logical == math.MaxUint16 {
+		return EncodeHLC(physical+1, 0)
+	}
+	return EncodeHLC(physical, logical+1)
 }
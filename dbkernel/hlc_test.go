@@ -0,0 +1,236 @@
+package dbkernel
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// manualClock is a physical clock for the HLC that only moves when told.
+type manualClock struct {
+	now uint64
+}
+
+func (m *manualClock) millis() uint64 {
+	return m.now
+}
+
+func TestHLC_EncodeDecode(t *testing.T) {
+	now := time.Now()
+	hlc := EncodeHLC(uint64(now.UnixMilli()), 42)
+	physical, logical := DecodeHLC(hlc)
+	assert.Equal(t, uint64(now.UnixMilli()), physical)
+	assert.Equal(t, uint16(42), logical)
+	assert.Equal(t, now.UnixMilli(), HLCTime(hlc).UnixMilli())
+
+	assert.True(t, IsConcurrent(EncodeHLC(10, 1), EncodeHLC(10, 2)))
+	assert.False(t, IsConcurrent(EncodeHLC(10, 1), EncodeHLC(11, 1)))
+}
+
+func TestHLC_Now_Monotonic(t *testing.T) {
+	clock := &manualClock{now: 1000}
+	h := newHLC(clock.millis)
+
+	first := h.Now()
+	assert.Equal(t, EncodeHLC(1000, 0), first)
+
+	// wall clock going backward should not move the hlc backward.
+	clock.now = 900
+	second := h.Now()
+	assert.Greater(t, second, first)
+	assert.Equal(t, EncodeHLC(1000, 1), second)
+
+	clock.now = 1001
+	assert.Equal(t, EncodeHLC(1001, 0), h.Now())
+}
+
+func TestHLC_Now_LogicalOverflow(t *testing.T) {
+	clock := &manualClock{now: 1000}
+	h := newHLC(clock.millis)
+
+	// more events than the logical counter can hold in the same millisecond.
+	last := h.Now()
+	for range math.MaxUint16 + 10 {
+		next := h.Now()
+		require.Greater(t, next, last, "timestamps should never collide")
+		last = next
+	}
+	physical, _ := DecodeHLC(last)
+	assert.Equal(t, uint64(1001), physical, "physical time should be borrowed from the future")
+}
+
+func TestHLC_Update(t *testing.T) {
+	tests := []struct {
+		name     string
+		local    uint64
+		wall     uint64
+		remote   uint64
+		expected uint64
+	}{
+		{
+			name:     "wall_clock_ahead",
+			local:    EncodeHLC(100, 5),
+			wall:     200,
+			remote:   EncodeHLC(150, 7),
+			expected: EncodeHLC(200, 0),
+		},
+		{
+			name:     "remote_ahead",
+			local:    EncodeHLC(100, 5),
+			wall:     100,
+			remote:   EncodeHLC(300, 7),
+			expected: EncodeHLC(300, 8),
+		},
+		{
+			name:     "local_ahead",
+			local:    EncodeHLC(300, 5),
+			wall:     100,
+			remote:   EncodeHLC(200, 7),
+			expected: EncodeHLC(300, 6),
+		},
+		{
+			name:     "local_and_remote_same_physical",
+			local:    EncodeHLC(300, 5),
+			wall:     100,
+			remote:   EncodeHLC(300, 9),
+			expected: EncodeHLC(300, 10),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := &manualClock{now: tc.wall}
+			h := newHLC(clock.millis)
+			h.last = tc.local
+
+			got, err := h.Update(tc.remote)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+			assert.Greater(t, got, tc.remote)
+			assert.Greater(t, got, tc.local)
+			assert.Greater(t, h.Now(), got)
+		})
+	}
+}
+
+func TestHLC_Update_MaxDrift(t *testing.T) {
+	clock := &manualClock{now: 1000}
+	h := newHLC(clock.millis)
+	h.SetMaxDrift(100 * time.Millisecond)
+	local := h.Now()
+
+	got, err := h.Update(EncodeHLC(1100, 3))
+	require.NoError(t, err)
+	assert.Equal(t, EncodeHLC(1100, 4), got)
+
+	got, err = h.Update(EncodeHLC(1101, 0))
+	assert.ErrorIs(t, err, ErrClockDrift)
+	assert.Equal(t, EncodeHLC(1100, 4), got, "clock should not move to the rejected timestamp")
+
+	// timestamp of the older releases, the nanoseconds shifted by 16 bits.
+	legacy := uint64(time.Now().UnixNano()) << 16
+	_, err = h.Update(legacy)
+	assert.ErrorIs(t, err, ErrClockDrift)
+	assert.Greater(t, h.Now(), local)
+	physical, _ := DecodeHLC(h.Last())
+	assert.Equal(t, uint64(1100), physical)
+
+	// own timestamps read back from the storage are always merged.
+	assert.Equal(t, EncodeHLC(5000, 1), h.Restore(EncodeHLC(5000, 0)))
+	assert.Equal(t, EncodeHLC(5000, 2), h.Now())
+}
+
+func TestEngine_HLC_PersistedAcrossRestart(t *testing.T) {
+	baseDir := t.TempDir()
+	namespace := "test_hlc"
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+
+	engine, err := NewStorageEngine(baseDir, namespace, config)
+	require.NoError(t, err)
+
+	// timestamp from a node whose clock is ahead, within the max drift.
+	remote := EncodeHLC(uint64(time.Now().Add(DefaultMaxClockDrift/2).UnixMilli()), 10)
+	observed, err := engine.ObserveHLC(remote)
+	require.NoError(t, err)
+	assert.Greater(t, observed, remote)
+
+	_, err = engine.ObserveHLC(EncodeHLC(uint64(time.Now().Add(time.Hour).UnixMilli()), 0))
+	assert.ErrorIs(t, err, ErrClockDrift)
+	assert.NoError(t, engine.Put([]byte("key"), []byte("value")))
+	written := engine.Now()
+	assert.Greater(t, written, observed)
+	require.NoError(t, engine.Close(context.Background()))
+
+	engine, err = NewStorageEngine(baseDir, namespace, config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+	assert.Greater(t, engine.Now(), written, "clock should not go backward after restart")
+}
+
+func TestEngine_HLC_RecoveredFromWAL(t *testing.T) {
+	baseDir := t.TempDir()
+	namespace := "test_hlc_recovery"
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+
+	engine, err := NewStorageEngine(baseDir, namespace, config)
+	require.NoError(t, err)
+	_, err = engine.ObserveHLC(EncodeHLC(uint64(time.Now().Add(DefaultMaxClockDrift/2).UnixMilli()), 0))
+	require.NoError(t, err)
+	assert.NoError(t, engine.Put([]byte("key"), []byte("value")))
+	written := engine.hlc.Last()
+	// the clock is never saved, only the written record has it.
+	crashEngine(t, engine)
+
+	engine, err = NewStorageEngine(baseDir, namespace, config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+	assert.Equal(t, 1, engine.RecoveredWALCount())
+	assert.Greater(t, engine.Now(), written)
+}
+
+func TestEngine_HLC_RestoredAheadOfMaxDrift(t *testing.T) {
+	for _, saved := range []bool{false, true} {
+		t.Run(map[bool]string{false: "recovered", true: "saved"}[saved], func(t *testing.T) {
+			baseDir := t.TempDir()
+			namespace := "test_hlc_restore"
+			config := NewDefaultEngineConfig()
+			config.DBEngine = BoltDBEngine
+
+			// wall clock stepped back by more than the max drift since the timestamps were issued.
+			ahead := uint64(time.Now().Add(10 * time.Minute).UnixMilli())
+			engine, err := NewStorageEngine(baseDir, namespace, config)
+			require.NoError(t, err)
+			engine.hlc = newHLC(func() uint64 {
+				return ahead
+			})
+			require.NoError(t, engine.Put([]byte("key"), []byte("value")))
+			written := engine.hlc.Last()
+			if saved {
+				var buf [8]byte
+				binary.LittleEndian.PutUint64(buf[:], written+1)
+				require.NoError(t, engine.dataStore.StoreMetadata(sysKeyHLC, buf[:]))
+				require.NoError(t, engine.dataStore.FSync())
+				written++
+			}
+			crashEngine(t, engine)
+
+			engine, err = NewStorageEngine(baseDir, namespace, config)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				assert.NoError(t, engine.Close(context.Background()))
+			})
+			assert.Greater(t, engine.Now(), written, "clock should never go back across a restart")
+		})
+	}
+}
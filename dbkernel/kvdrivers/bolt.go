@@ -9,6 +9,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/ankur-anand/unisondb/dbkernel/vfs"
 	"github.com/hashicorp/go-metrics"
 	"go.etcd.io/bbolt"
 )
@@ -22,6 +23,20 @@ type BoltDBEmbed struct {
 	path      string
 }
 
+func init() {
+	Register(DriverBolt, func(opts Options) (Store, error) {
+		// bolt works on the files directly, it can't be run on a virtual filesystem.
+		if !vfs.IsDefault(opts.FS) {
+			return nil, fmt.Errorf("kvdrivers: bolt driver: %w", vfs.ErrUnsupportedFS)
+		}
+		db, err := NewBoltdb(opts.Path, opts.Config)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	})
+}
+
 func NewBoltdb(path string, conf Config) (*BoltDBEmbed, error) {
 	db, err := bbolt.Open(path, 0600, nil)
 	if err != nil {
@@ -134,12 +149,8 @@ func (b *BoltDBEmbed) SetChunks(key []byte, chunks [][]byte, checksum uint32) er
 			}
 		}
 
-		chunkCount := uint32(len(chunks))
-		// Metadata: 1 byte flag + 4 bytes chunk count + 4 bytes checksum
-		metaData := make([]byte, 9)
-		metaData[0] = chunkedValue
-		binary.LittleEndian.PutUint32(metaData[1:], chunkCount)
-		binary.LittleEndian.PutUint32(metaData[5:], checksum)
+		// Metadata: 1 byte flag + 4 bytes chunk count + 4 bytes checksum + 4 bytes chunk size + 8 bytes value size
+		metaData := encodeChunkMetadata(NewChunkLayout(chunks, checksum))
 
 		// chunk metadata
 		if err := bucket.Put(key, metaData); err != nil {
@@ -473,6 +484,148 @@ func (b *BoltDBEmbed) Get(key []byte) ([]byte, error) {
 	return value, err
 }
 
+// GetValueReader returns a ChunkReader for the value associated with the key. Chunked values
+// are streamed chunk by chunk, each chunk is read in its own read transaction, so a slow reader
+// never holds one open. The reader returns ErrValueChanged once the value is overwritten or deleted.
+func (b *BoltDBEmbed) GetValueReader(key []byte) (*ChunkReader, error) {
+	rowKey := []byte(string(key) + rowKeySeperator)
+
+	metrics.IncrCounterWithLabels(mGetTotal, 1, b.label)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mGetLatency, startTime, b.label)
+	}()
+
+	var reader *ChunkReader
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		reader, err = b.valueReader(tx.Bucket(b.namespace), key, rowKey)
+		return err
+	})
+	return reader, err
+}
+
+// valueReader returns a ChunkReader for the value associated with the key in the bucket.
+func (b *BoltDBEmbed) valueReader(bucket *bbolt.Bucket, key, rowKey []byte) (*ChunkReader, error) {
+	if bucket == nil {
+		return nil, ErrBucketNotFound
+	}
+
+	storedValue := bucket.Get(key)
+	if storedValue == nil {
+		if bucket.Get(rowKey) != nil {
+			return nil, ErrUseGetColumnAPI
+		}
+		return nil, ErrKeyNotFound
+	}
+
+	flag := storedValue[0]
+	switch flag {
+	case kvValue:
+		value := make([]byte, len(storedValue[1:]))
+		copy(value, storedValue[1:])
+		return newFullValueReader(value), nil
+	case chunkedValue:
+		layout, err := decodeChunkMetadata(storedValue)
+		if err != nil {
+			return nil, err
+		}
+		return NewChunkReader(int(layout.ChunkCount), layout.Checksum, func(i int) ([]byte, error) {
+			return b.getChunk(key, i, layout)
+		}), nil
+	case rowColumnValue:
+		return nil, ErrUseGetColumnAPI
+	default:
+		return nil, fmt.Errorf("invalid data format for key %s: %w", string(key), ErrInvalidOpsForValueType)
+	}
+}
+
+// getChunk returns a copy of the i-th chunk of the chunked value associated with the key,
+// if the value still has the layout.
+func (b *BoltDBEmbed) getChunk(key []byte, i int, layout ChunkLayout) ([]byte, error) {
+	var chunk []byte
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(b.namespace)
+		if bucket == nil {
+			return ErrBucketNotFound
+		}
+		if err := layout.checkVersion(bucket.Get(key)); err != nil {
+			return fmt.Errorf("key %s: %w", string(key), err)
+		}
+
+		var err error
+		chunk, err = readBoltChunk(bucket, key, i)
+		return err
+	})
+	return chunk, err
+}
+
+// readBoltChunk returns a copy of the i-th chunk of the chunked value associated with the key from the bucket.
+func readBoltChunk(bucket *bbolt.Bucket, key []byte, i int) ([]byte, error) {
+	chunkKey := fmt.Sprintf("%s_chunk_%d", key, i)
+	chunkData := bucket.Get([]byte(chunkKey))
+	if chunkData == nil {
+		return nil, fmt.Errorf("chunk %d missing for key %s: %w", i, string(key), ErrRecordCorrupted)
+	}
+	chunk := make([]byte, len(chunkData))
+	copy(chunk, chunkData)
+	return chunk, nil
+}
+
+// GetRange returns length bytes of the value associated with the key starting at offset.
+// Full values are sliced in place, for chunked values only the chunks overlapping the range are read.
+// A non-positive length reads till the end of the value.
+func (b *BoltDBEmbed) GetRange(key []byte, offset, length int64) ([]byte, error) {
+	rowKey := []byte(string(key) + rowKeySeperator)
+
+	metrics.IncrCounterWithLabels(mGetTotal, 1, b.label)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mGetLatency, startTime, b.label)
+	}()
+
+	var value []byte
+	var layout ChunkLayout
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(b.namespace)
+		if bucket == nil {
+			return ErrBucketNotFound
+		}
+
+		storedValue := bucket.Get(key)
+		if storedValue == nil {
+			if bucket.Get(rowKey) != nil {
+				return ErrUseGetColumnAPI
+			}
+			return ErrKeyNotFound
+		}
+
+		flag := storedValue[0]
+		switch flag {
+		case kvValue:
+			var err error
+			value, err = SliceRange(storedValue[1:], offset, length)
+			return err
+		case chunkedValue:
+			var err error
+			layout, err = decodeChunkMetadata(storedValue)
+			return err
+		case rowColumnValue:
+			return ErrUseGetColumnAPI
+		default:
+			return fmt.Errorf("invalid data format for key %s: %w", string(key), ErrInvalidOpsForValueType)
+		}
+	})
+
+	if err != nil || value != nil {
+		return value, err
+	}
+
+	return layout.ReadRange(offset, length, func(i int) ([]byte, error) {
+		return b.getChunk(key, i, layout)
+	})
+}
+
 // GetRowColumns returns all the columns for the given row. If a ColumnPredicate predicate func is provided, it will only
 // return those columns for which the ColumnFilterFunc func returns true.
 func (b *BoltDBEmbed) GetRowColumns(rowKey []byte, filter func(columnKey []byte) bool) (map[string][]byte, error) {
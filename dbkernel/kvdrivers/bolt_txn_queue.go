@@ -147,12 +147,8 @@ func (bq *BoltTxnQueue) SetChunks(key []byte, chunks [][]byte, checksum uint32)
 			}
 		}
 
-		chunkCount := uint32(len(chunks))
-		// Metadata: 1 byte flag + 4 bytes chunk count + 4 bytes checksum
-		metaData := make([]byte, 9)
-		metaData[0] = chunkedValue
-		binary.LittleEndian.PutUint32(metaData[1:], chunkCount)
-		binary.LittleEndian.PutUint32(metaData[5:], checksum)
+		// Metadata: 1 byte flag + 4 bytes chunk count + 4 bytes checksum + 4 bytes chunk size + 8 bytes value size
+		metaData := encodeChunkMetadata(NewChunkLayout(chunks, checksum))
 
 		// chunk metadata
 		if err := txn.Put(key, metaData); err != nil {
@@ -0,0 +1,195 @@
+package kvdrivers
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrInvalidRange = errors.New("requested range not satisfiable")
+)
+
+const (
+	// legacyChunkMetadataSize: 1 byte flag + 4 bytes chunk count + 4 bytes checksum
+	legacyChunkMetadataSize = 9
+	// chunkMetadataSize: legacy metadata + 4 bytes chunk size + 8 bytes value size
+	chunkMetadataSize = legacyChunkMetadataSize + 12
+)
+
+// ChunkLayout describes how a chunked value is split across its chunks.
+type ChunkLayout struct {
+	ChunkCount uint32
+	Checksum   uint32
+	// ChunkSize is the size of every chunk except the last one.
+	// Zero if the chunks are not uniformly sized, or the layout predates chunk size metadata.
+	ChunkSize uint32
+	// ValueSize is the size of the complete value, valid only when ChunkSize is non-zero.
+	ValueSize uint64
+}
+
+// NewChunkLayout returns the ChunkLayout of the provided chunks.
+func NewChunkLayout(chunks [][]byte, checksum uint32) ChunkLayout {
+	layout := ChunkLayout{
+		ChunkCount: uint32(len(chunks)),
+		Checksum:   checksum,
+	}
+
+	for i, chunk := range chunks {
+		layout.ValueSize += uint64(len(chunk))
+		if i == 0 {
+			layout.ChunkSize = uint32(len(chunk))
+			continue
+		}
+		// all the chunk except the last should be of the same size as first one.
+		if len(chunks[i-1]) != int(layout.ChunkSize) {
+			layout.ChunkSize = 0
+		}
+	}
+
+	if layout.ChunkSize == 0 {
+		layout.ValueSize = 0
+	}
+	return layout
+}
+
+// encodeChunkMetadata encodes the layout as the metadata value stored against the key of a chunked value.
+func encodeChunkMetadata(layout ChunkLayout) []byte {
+	metaData := make([]byte, chunkMetadataSize)
+	metaData[0] = chunkedValue
+	binary.LittleEndian.PutUint32(metaData[1:], layout.ChunkCount)
+	binary.LittleEndian.PutUint32(metaData[5:], layout.Checksum)
+	binary.LittleEndian.PutUint32(metaData[9:], layout.ChunkSize)
+	binary.LittleEndian.PutUint64(metaData[13:], layout.ValueSize)
+	return metaData
+}
+
+// decodeChunkMetadata decodes the metadata value of a chunked value.
+// Metadata written before chunk size was recorded decodes with zero ChunkSize.
+func decodeChunkMetadata(metaData []byte) (ChunkLayout, error) {
+	if len(metaData) < legacyChunkMetadataSize {
+		return ChunkLayout{}, ErrInvalidChunkMetadata
+	}
+
+	layout := ChunkLayout{
+		ChunkCount: binary.LittleEndian.Uint32(metaData[1:5]),
+		Checksum:   binary.LittleEndian.Uint32(metaData[5:9]),
+	}
+
+	if len(metaData) >= chunkMetadataSize {
+		layout.ChunkSize = binary.LittleEndian.Uint32(metaData[9:13])
+		layout.ValueSize = binary.LittleEndian.Uint64(metaData[13:21])
+	}
+	return layout, nil
+}
+
+// checkVersion returns ErrValueChanged if the metadata stored against the key of the value is no longer
+// the one of the layout. Every chunk is read in its own read txn, so a chunk read after the value is
+// overwritten would belong to another version of it.
+func (c ChunkLayout) checkVersion(metaData []byte) error {
+	if len(metaData) == 0 || metaData[0] != chunkedValue {
+		return ErrValueChanged
+	}
+	stored, err := decodeChunkMetadata(metaData)
+	if err != nil || stored.ChunkCount != c.ChunkCount || stored.Checksum != c.Checksum {
+		return ErrValueChanged
+	}
+	return nil
+}
+
+// ReadRange returns a copy of length bytes of the value starting at offset, where fetch returns the chunk
+// at the provided index. If the chunk size is known only the chunks overlapping the range are fetched,
+// else chunks are fetched in order until the range is covered.
+// A non-positive length reads till the end of the value.
+// The checksum of the complete value is not verified for range reads.
+func (c ChunkLayout) ReadRange(offset, length int64, fetch func(i int) ([]byte, error)) ([]byte, error) {
+	if offset < 0 {
+		return nil, ErrInvalidRange
+	}
+
+	if c.ChunkSize == 0 {
+		return c.scanRange(offset, length, fetch)
+	}
+
+	valueSize := int64(c.ValueSize)
+	if offset >= valueSize {
+		return nil, ErrInvalidRange
+	}
+
+	end := valueSize
+	// compared against the remaining size, offset+length could overflow.
+	if length > 0 && length < valueSize-offset {
+		end = offset + length
+	}
+
+	chunkSize := int64(c.ChunkSize)
+	// last chunk could be larger than the chunk size, the range could start and end in it.
+	first := min(offset/chunkSize, int64(c.ChunkCount)-1)
+	last := min((end-1)/chunkSize, int64(c.ChunkCount)-1)
+
+	value := make([]byte, 0, end-offset)
+	for i := first; i <= last; i++ {
+		chunk, err := fetch(int(i))
+		if err != nil {
+			return nil, err
+		}
+		chunkStart := i * chunkSize
+		lo := max(offset-chunkStart, 0)
+		hi := min(end-chunkStart, int64(len(chunk)))
+		if lo > hi {
+			return nil, ErrRecordCorrupted
+		}
+		value = append(value, chunk[lo:hi]...)
+	}
+
+	if int64(len(value)) != end-offset {
+		return nil, ErrRecordCorrupted
+	}
+	return value, nil
+}
+
+// scanRange reads the range when the chunk size is unknown, by walking the chunks from the start.
+func (c ChunkLayout) scanRange(offset, length int64, fetch func(i int) ([]byte, error)) ([]byte, error) {
+	var value []byte
+	var chunkStart int64
+	for i := 0; i < int(c.ChunkCount); i++ {
+		if length > 0 && chunkStart-offset >= length {
+			break
+		}
+
+		chunk, err := fetch(i)
+		if err != nil {
+			return nil, err
+		}
+
+		chunkEnd := chunkStart + int64(len(chunk))
+		if chunkEnd > offset {
+			lo := max(offset-chunkStart, 0)
+			hi := int64(len(chunk))
+			if length > 0 && length < chunkEnd-offset {
+				hi = offset + length - chunkStart
+			}
+			value = append(value, chunk[lo:hi]...)
+		}
+		chunkStart = chunkEnd
+	}
+
+	if offset >= chunkStart {
+		return nil, ErrInvalidRange
+	}
+	return value, nil
+}
+
+// SliceRange returns a copy of length bytes of the value starting at offset.
+// A non-positive length reads till the end of the value.
+func SliceRange(value []byte, offset, length int64) ([]byte, error) {
+	if offset < 0 || offset >= int64(len(value)) {
+		return nil, ErrInvalidRange
+	}
+
+	end := int64(len(value))
+	if length > 0 && length < end-offset {
+		end = offset + length
+	}
+
+	return append([]byte(nil), value[offset:end]...), nil
+}
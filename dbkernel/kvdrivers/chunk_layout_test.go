@@ -0,0 +1,105 @@
+package kvdrivers
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkLayout_ReadRange(t *testing.T) {
+	chunks := [][]byte{
+		bytes.Repeat([]byte("a"), 10),
+		bytes.Repeat([]byte("b"), 10),
+		bytes.Repeat([]byte("c"), 10),
+		bytes.Repeat([]byte("d"), 4),
+	}
+	fullValue := bytes.Join(chunks, nil)
+
+	var fetched []int
+	fetch := func(i int) ([]byte, error) {
+		fetched = append(fetched, i)
+		return chunks[i], nil
+	}
+
+	layout := NewChunkLayout(chunks, 1)
+	assert.Equal(t, uint32(10), layout.ChunkSize)
+	assert.Equal(t, uint64(len(fullValue)), layout.ValueSize)
+
+	t.Run("overlapping_chunks_only", func(t *testing.T) {
+		fetched = nil
+		got, err := layout.ReadRange(12, 10, fetch)
+		assert.NoError(t, err)
+		assert.Equal(t, fullValue[12:22], got)
+		assert.Equal(t, []int{1, 2}, fetched)
+	})
+
+	t.Run("tail", func(t *testing.T) {
+		fetched = nil
+		got, err := layout.ReadRange(31, 0, fetch)
+		assert.NoError(t, err)
+		assert.Equal(t, fullValue[31:], got)
+		assert.Equal(t, []int{3}, fetched)
+	})
+
+	t.Run("out_of_range", func(t *testing.T) {
+		_, err := layout.ReadRange(int64(len(fullValue)), 1, fetch)
+		assert.ErrorIs(t, err, ErrInvalidRange)
+	})
+
+	t.Run("overflowing_length", func(t *testing.T) {
+		got, err := layout.ReadRange(1, math.MaxInt64, fetch)
+		assert.NoError(t, err)
+		assert.Equal(t, fullValue[1:], got)
+
+		legacy := layout
+		legacy.ChunkSize = 0
+		got, err = legacy.ReadRange(1, math.MaxInt64, fetch)
+		assert.NoError(t, err)
+		assert.Equal(t, fullValue[1:], got)
+
+		got, err = SliceRange(fullValue, 1, math.MaxInt64)
+		assert.NoError(t, err)
+		assert.Equal(t, fullValue[1:], got)
+	})
+
+	t.Run("oversized_last_chunk", func(t *testing.T) {
+		oversized := [][]byte{chunks[0], bytes.Repeat([]byte("e"), 30)}
+		value := bytes.Join(oversized, nil)
+		layout := NewChunkLayout(oversized, 1)
+		assert.Equal(t, uint32(10), layout.ChunkSize)
+
+		fetched = nil
+		got, err := layout.ReadRange(25, 5, func(i int) ([]byte, error) {
+			fetched = append(fetched, i)
+			return oversized[i], nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, value[25:30], got)
+		assert.Equal(t, []int{1}, fetched)
+	})
+
+	t.Run("non_uniform_chunks", func(t *testing.T) {
+		nonUniform := NewChunkLayout([][]byte{chunks[0], chunks[3], chunks[1]}, 1)
+		assert.Equal(t, uint32(0), nonUniform.ChunkSize)
+	})
+
+	t.Run("legacy_metadata", func(t *testing.T) {
+		metaData := encodeChunkMetadata(layout)
+		legacy, err := decodeChunkMetadata(metaData[:legacyChunkMetadataSize])
+		assert.NoError(t, err)
+		assert.Equal(t, layout.ChunkCount, legacy.ChunkCount)
+		assert.Equal(t, layout.Checksum, legacy.Checksum)
+		assert.Equal(t, uint32(0), legacy.ChunkSize)
+
+		fetched = nil
+		got, err := legacy.ReadRange(12, 10, fetch)
+		assert.NoError(t, err)
+		assert.Equal(t, fullValue[12:22], got)
+		assert.Equal(t, []int{0, 1, 2}, fetched, "chunks are scanned till the range is covered")
+
+		_, err = legacy.ReadRange(int64(len(fullValue)), 1, fetch)
+		assert.ErrorIs(t, err, ErrInvalidRange)
+	})
+}
@@ -0,0 +1,90 @@
+package kvdrivers
+
+import (
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+var (
+	ErrReaderClosed = errors.New("value reader already closed")
+	// ErrValueChanged is returned when the chunked value being read is overwritten or deleted before
+	// all of its chunks are read.
+	ErrValueChanged = errors.New("value changed while it was read")
+)
+
+// ChunkReader streams a value that is stored as one or more chunks. Only the chunk being
+// read is kept in memory, the next chunk is fetched lazily once the current one is consumed.
+// The rolling CRC32 of everything read is verified against the stored checksum at EOF.
+type ChunkReader struct {
+	fetch      func(i int) ([]byte, error)
+	chunkCount int
+	checksum   uint32
+	rolling    uint32
+	next       int
+	current    []byte
+	closed     bool
+}
+
+// NewChunkReader returns a ChunkReader over chunkCount chunks, where fetch returns the chunk
+// at the provided index and checksum is the expected CRC32 of the complete value.
+func NewChunkReader(chunkCount int, checksum uint32, fetch func(i int) ([]byte, error)) *ChunkReader {
+	return &ChunkReader{
+		fetch:      fetch,
+		chunkCount: chunkCount,
+		checksum:   checksum,
+	}
+}
+
+// newFullValueReader returns a ChunkReader over a value that is stored as a whole.
+func newFullValueReader(value []byte) *ChunkReader {
+	return NewChunkReader(1, crc32.ChecksumIEEE(value), func(int) ([]byte, error) {
+		return value, nil
+	})
+}
+
+// Read reads up to len(p) bytes of the value into p.
+// It returns ErrRecordCorrupted instead of io.EOF, if the checksum of the value doesn't match.
+func (r *ChunkReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, ErrReaderClosed
+	}
+
+	for len(r.current) == 0 {
+		if r.next == r.chunkCount {
+			if r.rolling != r.checksum {
+				return 0, ErrRecordCorrupted
+			}
+			return 0, io.EOF
+		}
+
+		chunk, err := r.fetch(r.next)
+		if err != nil {
+			return 0, err
+		}
+		r.next++
+		r.rolling = crc32.Update(r.rolling, crc32.IEEETable, chunk)
+		r.current = chunk
+	}
+
+	n := copy(p, r.current)
+	r.current = r.current[n:]
+	return n, nil
+}
+
+// Checksum returns the expected CRC32 checksum of the complete value.
+func (r *ChunkReader) Checksum() uint32 {
+	return r.checksum
+}
+
+// ChunkCount returns the number of chunks the value is stored in.
+func (r *ChunkReader) ChunkCount() int {
+	return r.chunkCount
+}
+
+// Close releases the chunk currently held by the reader.
+func (r *ChunkReader) Close() error {
+	r.closed = true
+	r.current = nil
+	return nil
+}
@@ -1,4 +1,6 @@
-package kvdrivers_test
+// Package kvdriverstest provides the conformance test suite for the kvdrivers.Store implementations,
+// drivers registered with kvdrivers.Register should pass it.
+package kvdriverstest
 
 import (
 	"bytes"
@@ -13,28 +15,14 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-// btreeWriter defines the interface for interacting with a B-tree based storage
-// for setting individual values, chunks and many value at once.
-type btreeWriter interface {
-	// Put associates a value with a key.
-	Set(key []byte, value []byte) error
-	// SetMany associates multiple values with corresponding keys.
-	SetMany(keys [][]byte, values [][]byte) error
-	// SetChunks stores a value that has been split into chunks, associating them with a single key.
-	SetChunks(key []byte, chunks [][]byte, checksum uint32) error
-	// Delete deletes a value with a key.
-	Delete(key []byte) error
-	// DeleteMany delete multiple values with corresponding keys.
-	DeleteMany(keys [][]byte) error
-	SetManyRowColumns(rowKeys [][]byte, columnEntriesPerRow []map[string][]byte) error
-	DeleteManyRowColumns(rowKeys [][]byte, columnEntriesPerRow []map[string][]byte) error
-	DeleteEntireRows(rowKeys [][]byte) (int, error)
-	StoreMetadata(key []byte, value []byte) error
-	FSync() error
+// Store is the kvdrivers.Store under test, it should also support restoring from its own Snapshot.
+type Store interface {
+	kvdrivers.Store
 	Restore(reader io.Reader) error
 }
 
-type TxnBatcher interface {
+// TxnQueue queues the batch operations that are executed in order on Commit.
+type TxnQueue interface {
 	BatchPut(keys, values [][]byte) error
 	BatchDelete(keys [][]byte) error
 	SetChunks(key []byte, chunks [][]byte, checksum uint32) error
@@ -45,29 +33,45 @@ type TxnBatcher interface {
 	Stats() kvdrivers.TxnStats
 }
 
-// btreeReader defines the interface for interacting with a B-tree based storage
-// for getting individual values, chunks and many value at once.
-type btreeReader interface {
-	// Get retrieves a value associated with a key.
-	Get(key []byte) ([]byte, error)
-	GetRowColumns(rowKey []byte, filter func([]byte) bool) (map[string][]byte, error)
-	// SnapShot writes the complete database to the provided io writer.
-	Snapshot(w io.Writer) error
-	RetrieveMetadata(key []byte) ([]byte, error)
+// Harness describes the driver under test.
+type Harness struct {
+	// Open returns a Store at the provided path, every call is made with a new path.
+	Open func(path string, config kvdrivers.Config) (Store, error)
+	// NewTxnQueue returns a TxnQueue for the provided Store.
+	NewTxnQueue func(store Store, maxBatchSize int) TxnQueue
 }
 
-// bTreeStore combines the btreeWriter and btreeReader interfaces.
-type bTreeStore interface {
-	btreeWriter
-	btreeReader
-	Close() error
+// Run runs the complete conformance suite against a Store opened with the Harness.
+func Run(t *testing.T, h Harness) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "suite.db")
+	store, err := h.Open(path, kvdrivers.Config{
+		Namespace: "test",
+		NoSync:    true,
+		MmapSize:  1 << 30,
+	})
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+
+	ts := &testSuite{
+		harness: h,
+		store:   store,
+	}
+
+	for _, tc := range getTestSuites(ts) {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.runFunc(t)
+		})
+	}
+
+	assert.NoError(t, store.Close(), "failed to close store")
 }
 
-// testSuite defines all the test cases that is common in both the lmdb and boltdb.
+// testSuite defines all the test cases that every driver should pass.
 type testSuite struct {
-	dbConstructor         func(path string, config kvdrivers.Config) (bTreeStore, error)
-	txnBatcherConstructor func(maxBatchSize int) TxnBatcher
-	store                 bTreeStore
+	harness Harness
+	store   Store
 }
 
 type suite struct {
@@ -93,6 +97,14 @@ func getTestSuites(factory *testSuite) []suite {
 			name:    "chunk_set_get_and_delete",
 			runFunc: factory.TestChunkSetAndDelete,
 		},
+		{
+			name:    "chunk_value_reader",
+			runFunc: factory.TestGetValueReader,
+		},
+		{
+			name:    "chunk_value_range",
+			runFunc: factory.TestGetRange,
+		},
 		{
 			name:    "snapshot_and_retrieve",
 			runFunc: factory.TestSnapshotAndRetrieve,
@@ -129,6 +141,10 @@ func getTestSuites(factory *testSuite) []suite {
 			name:    "store_set_columns_nm_row_column",
 			runFunc: factory.TestSetGetDelete_NMRowColumns,
 		},
+		{
+			name:    "store_get_delete_rows_at_end",
+			runFunc: factory.TestGetDeleteRows_LastKeys,
+		},
 		{
 			name:    "txn_batch_put_get",
 			runFunc: factory.TestTxnQueue_BatchPutGetDelete,
@@ -270,6 +286,140 @@ func (s *testSuite) TestChunkSetAndDelete(t *testing.T) {
 	}
 }
 
+func (s *testSuite) TestGetValueReader(t *testing.T) {
+	key := []byte("reader_chunked_key")
+	var chunks [][]byte
+	var checksum uint32
+	for i := 0; i < 5; i++ {
+		chunk := []byte(gofakeit.LetterN(uint(100 + i)))
+		chunks = append(chunks, chunk)
+		checksum = crc32.Update(checksum, crc32.IEEETable, chunk)
+	}
+
+	err := s.store.SetChunks(key, chunks, checksum)
+	assert.NoError(t, err, "Failed to insert chunked value")
+
+	reader, err := s.store.GetValueReader(key)
+	assert.NoError(t, err, "Failed to get value reader")
+	assert.Equal(t, len(chunks), reader.ChunkCount())
+	assert.Equal(t, checksum, reader.Checksum())
+	got, err := io.ReadAll(reader)
+	assert.NoError(t, err, "Failed to read chunked value")
+	assert.Equal(t, bytes.Join(chunks, nil), got, "streamed value should match")
+	assert.NoError(t, reader.Close())
+	_, err = reader.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, kvdrivers.ErrReaderClosed)
+
+	fullKey := []byte("reader_full_key")
+	value := []byte(gofakeit.LetterN(100))
+	assert.NoError(t, s.store.Set(fullKey, value))
+	reader, err = s.store.GetValueReader(fullKey)
+	assert.NoError(t, err, "Failed to get value reader")
+	got, err = io.ReadAll(reader)
+	assert.NoError(t, err, "Failed to read full value")
+	assert.Equal(t, value, got, "streamed value should match")
+	assert.NoError(t, reader.Close())
+
+	// an open reader never mixes the chunks of two versions of the value, it either keeps streaming
+	// the version it was opened on or fails once the value is overwritten.
+	reader, err = s.store.GetValueReader(key)
+	assert.NoError(t, err, "Failed to get value reader")
+	first := make([]byte, len(chunks[0]))
+	_, err = io.ReadFull(reader, first)
+	assert.NoError(t, err)
+	var newChunks [][]byte
+	var newChecksum uint32
+	for i := 0; i < 3; i++ {
+		chunk := []byte(gofakeit.LetterN(uint(200 + i)))
+		newChunks = append(newChunks, chunk)
+		newChecksum = crc32.Update(newChecksum, crc32.IEEETable, chunk)
+	}
+	assert.NoError(t, s.store.SetChunks(key, newChunks, newChecksum))
+	rest, err := io.ReadAll(reader)
+	if err == nil {
+		assert.Equal(t, bytes.Join(chunks, nil), append(first, rest...), "streamed value should match")
+	} else {
+		assert.ErrorIs(t, err, kvdrivers.ErrValueChanged)
+	}
+	assert.NoError(t, reader.Close())
+
+	reader, err = s.store.GetValueReader(key)
+	assert.NoError(t, err, "Failed to get value reader")
+	got, err = io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, bytes.Join(newChunks, nil), got, "streamed value should match")
+	assert.NoError(t, reader.Close())
+
+	// wrong checksum is only reported at EOF.
+	assert.NoError(t, s.store.SetChunks(key, chunks, checksum+1))
+	reader, err = s.store.GetValueReader(key)
+	assert.NoError(t, err, "Failed to get value reader")
+	_, err = io.ReadAll(reader)
+	assert.ErrorIs(t, err, kvdrivers.ErrRecordCorrupted)
+	assert.NoError(t, reader.Close())
+
+	_, err = s.store.GetValueReader([]byte("reader_non_existent_key"))
+	assert.ErrorIs(t, err, kvdrivers.ErrKeyNotFound)
+
+	rowKey := []byte("reader_row_key")
+	err = s.store.SetManyRowColumns([][]byte{rowKey}, []map[string][]byte{{"column": []byte("value")}})
+	assert.NoError(t, err)
+	_, err = s.store.GetValueReader(rowKey)
+	assert.ErrorIs(t, err, kvdrivers.ErrUseGetColumnAPI)
+}
+
+func (s *testSuite) TestGetRange(t *testing.T) {
+	key := []byte("range_chunked_key")
+	var chunks [][]byte
+	var checksum uint32
+	for i := 0; i < 5; i++ {
+		chunk := []byte(gofakeit.LetterN(100))
+		chunks = append(chunks, chunk)
+		checksum = crc32.Update(checksum, crc32.IEEETable, chunk)
+	}
+	// last chunk can be of any size.
+	lastChunk := []byte(gofakeit.LetterN(30))
+	chunks = append(chunks, lastChunk)
+	checksum = crc32.Update(checksum, crc32.IEEETable, lastChunk)
+	fullValue := bytes.Join(chunks, nil)
+
+	assert.NoError(t, s.store.SetChunks(key, chunks, checksum), "Failed to insert chunked value")
+
+	got, err := s.store.GetRange(key, 150, 200)
+	assert.NoError(t, err, "Failed to get range")
+	assert.Equal(t, fullValue[150:350], got, "range should match")
+
+	got, err = s.store.GetRange(key, 480, 0)
+	assert.NoError(t, err, "Failed to get range")
+	assert.Equal(t, fullValue[480:], got, "open range should read till the end")
+
+	got, err = s.store.GetRange(key, 520, 100)
+	assert.NoError(t, err, "Failed to get range")
+	assert.Equal(t, fullValue[520:], got, "range should be truncated at the end")
+
+	_, err = s.store.GetRange(key, int64(len(fullValue)), 10)
+	assert.ErrorIs(t, err, kvdrivers.ErrInvalidRange)
+
+	fullKey := []byte("range_full_key")
+	value := []byte(gofakeit.LetterN(100))
+	assert.NoError(t, s.store.Set(fullKey, value))
+	got, err = s.store.GetRange(fullKey, 10, 20)
+	assert.NoError(t, err, "Failed to get range")
+	assert.Equal(t, value[10:30], got, "range should match")
+
+	_, err = s.store.GetRange(fullKey, -1, 20)
+	assert.ErrorIs(t, err, kvdrivers.ErrInvalidRange)
+
+	_, err = s.store.GetRange([]byte("range_non_existent_key"), 0, 1)
+	assert.ErrorIs(t, err, kvdrivers.ErrKeyNotFound)
+
+	rowKey := []byte("range_row_key")
+	err = s.store.SetManyRowColumns([][]byte{rowKey}, []map[string][]byte{{"column": []byte("value")}})
+	assert.NoError(t, err)
+	_, err = s.store.GetRange(rowKey, 0, 1)
+	assert.ErrorIs(t, err, kvdrivers.ErrUseGetColumnAPI)
+}
+
 func (s *testSuite) TestSnapshotAndRetrieve(t *testing.T) {
 	testData := make(map[string][]byte)
 	for i := 0; i < 100; i++ {
@@ -294,7 +444,7 @@ func (s *testSuite) TestSnapshotAndRetrieve(t *testing.T) {
 		NoSync:    true,
 		MmapSize:  1 << 30,
 	}
-	restoreDB, err := s.dbConstructor(path, conf)
+	restoreDB, err := s.harness.Open(path, conf)
 	assert.NoError(t, err, "Failed to create db constructor")
 	err = restoreDB.Restore(bytes.NewReader(buf.Bytes()))
 	assert.NoError(t, err, "Failed to restore")
@@ -563,6 +713,33 @@ func (s *testSuite) TestSetGetDelete_RowColumns_Filter(t *testing.T) {
 	})
 }
 
+// TestGetDeleteRows_LastKeys validates rows that are stored as the last keys, where cursor moves past the end.
+func (s *testSuite) TestGetDeleteRows_LastKeys(t *testing.T) {
+	rowKeys := [][]byte{[]byte("\xff\xfe_row"), []byte("\xff\xff_row")}
+	columns := []map[string][]byte{
+		{"col_1": []byte("value_1"), "col_2": []byte("value_2")},
+		{"col_1": []byte("value_1")},
+	}
+
+	err := s.store.SetManyRowColumns(rowKeys, columns)
+	assert.NoError(t, err)
+
+	for i, rowKey := range rowKeys {
+		value, err := s.store.GetRowColumns(rowKey, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, columns[i], value)
+	}
+
+	deleted, err := s.store.DeleteEntireRows(rowKeys)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+
+	for _, rowKey := range rowKeys {
+		_, err := s.store.GetRowColumns(rowKey, nil)
+		assert.ErrorIs(t, err, kvdrivers.ErrKeyNotFound)
+	}
+}
+
 func (s *testSuite) TestSetGetDelete_NMRowColumns(t *testing.T) {
 	rowKey1 := "rows_key_nm_1"
 	columnsRow1 := make(map[string][]byte)
@@ -691,7 +868,7 @@ func (s *testSuite) TestSetGetDelete_NMRowColumns(t *testing.T) {
 func (s *testSuite) TestTxnQueue_BatchPutGetDelete(t *testing.T) {
 	key := []byte("test_key_txn")
 	value := []byte("hello world_txn")
-	txn := s.txnBatcherConstructor(10)
+	txn := s.harness.NewTxnQueue(s.store, 10)
 	keys := [][]byte{key}
 	values := [][]byte{value}
 
@@ -723,7 +900,7 @@ func (s *testSuite) TestTxnSetGetAndDeleteMany(t *testing.T) {
 		values = append(values, []byte(fmt.Sprintf("value_%d", i)))
 	}
 
-	txn := s.txnBatcherConstructor(10)
+	txn := s.harness.NewTxnQueue(s.store, 10)
 
 	err := txn.BatchPut(keys, values)
 	assert.NoError(t, err, "Failed to insert values")
@@ -760,7 +937,7 @@ func (s *testSuite) TestTxn_ChunkSetAndDelete(t *testing.T) {
 		checksum = crc32.Update(checksum, crc32.IEEETable, chunks[i])
 	}
 
-	txn := s.txnBatcherConstructor(10)
+	txn := s.harness.NewTxnQueue(s.store, 10)
 
 	err := txn.SetChunks(key, chunks, checksum)
 	assert.NoError(t, err, "Failed to insert chunked value")
@@ -829,7 +1006,7 @@ func (s *testSuite) TestTxn_SetGetDelete_RowColumns(t *testing.T) {
 	columEntriesPerRow := make([]map[string][]byte, 0)
 	columEntriesPerRow = append(columEntriesPerRow, entries)
 
-	txn := s.txnBatcherConstructor(10)
+	txn := s.harness.NewTxnQueue(s.store, 10)
 
 	t.Run("invalid-arguments", func(t *testing.T) {
 		err := txn.BatchPutRowColumns(rowKeys, nil)
@@ -838,7 +1015,7 @@ func (s *testSuite) TestTxn_SetGetDelete_RowColumns(t *testing.T) {
 		assert.ErrorIs(t, err, kvdrivers.ErrInvalidArguments)
 	})
 
-	txn = s.txnBatcherConstructor(10)
+	txn = s.harness.NewTxnQueue(s.store, 10)
 
 	t.Run("set", func(t *testing.T) {
 		err := txn.BatchPutRowColumns(rowKeys, columEntriesPerRow)
@@ -915,7 +1092,7 @@ func (s *testSuite) TestTxn_SetGetDelete_RowColumns(t *testing.T) {
 		}
 	})
 
-	txn = s.txnBatcherConstructor(10)
+	txn = s.harness.NewTxnQueue(s.store, 10)
 	t.Run("delete_row", func(t *testing.T) {
 		err := txn.BatchDeleteRows(rowKeys)
 		assert.NoError(t, err, "Failed to delete row")
@@ -937,7 +1114,7 @@ func (s *testSuite) TestTxn_SetGetAndDeleteMany_Combined(t *testing.T) {
 		checksum = crc32.Update(checksum, crc32.IEEETable, chunks[i])
 	}
 
-	txn := s.txnBatcherConstructor(10)
+	txn := s.harness.NewTxnQueue(s.store, 10)
 	keys := make([][]byte, len(chunks))
 	values := make([][]byte, len(chunks))
 	for i := 0; i < len(chunks); i++ {
@@ -990,7 +1167,7 @@ func (s *testSuite) TestTxn_SetGetDelete_NMRowColumns(t *testing.T) {
 	columEntriesPerRow = append(columEntriesPerRow, columnsRow1, columnsRow2)
 
 	t.Run("set", func(t *testing.T) {
-		txn := s.txnBatcherConstructor(2)
+		txn := s.harness.NewTxnQueue(s.store, 2)
 		err := txn.BatchPutRowColumns(rowKeys, columEntriesPerRow)
 		assert.NoError(t, err, "Failed to set row columns")
 		_, err = s.store.Get([]byte(rowKey1))
@@ -1032,7 +1209,7 @@ func (s *testSuite) TestTxn_SetGetDelete_NMRowColumns(t *testing.T) {
 	deleteColumnPerRow = append(deleteColumnPerRow, deleteColumn)
 
 	t.Run("delete_column_1", func(t *testing.T) {
-		txn := s.txnBatcherConstructor(2)
+		txn := s.harness.NewTxnQueue(s.store, 2)
 		err := txn.BatchDeleteRowColumns([][]byte{[]byte(rowKey1)}, deleteColumnPerRow)
 		assert.NoError(t, err, "Failed to delete row columns")
 		assert.NoError(t, txn.Commit(), "Failed to commit")
@@ -1052,7 +1229,7 @@ func (s *testSuite) TestTxn_SetGetDelete_NMRowColumns(t *testing.T) {
 		}
 	})
 
-	txn := s.txnBatcherConstructor(1)
+	txn := s.harness.NewTxnQueue(s.store, 1)
 	updateColumnPerRow := make([]map[string][]byte, 0)
 	updateColumn := make(map[string][]byte)
 	t.Run("put_more_columns_1", func(t *testing.T) {
@@ -1081,7 +1258,7 @@ func (s *testSuite) TestTxn_SetGetDelete_NMRowColumns(t *testing.T) {
 
 	totalDeleted := len(columnsRow1) + len(columnsRow2) - len(deleteColumn) + 2 // row keys
 
-	txn = s.txnBatcherConstructor(1)
+	txn = s.harness.NewTxnQueue(s.store, 1)
 	t.Run("delete_row", func(t *testing.T) {
 		err := txn.BatchDeleteRows(rowKeys)
 		assert.NoError(t, err, "Failed to delete row")
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/PowerDNS/lmdb-go/lmdb"
+	"github.com/ankur-anand/unisondb/dbkernel/vfs"
 	"github.com/hashicorp/go-metrics"
 )
 
@@ -30,6 +31,20 @@ func (l *LmdbEmbed) FSync() error {
 	return l.env.Sync(true)
 }
 
+func init() {
+	Register(DriverLMDB, func(opts Options) (Store, error) {
+		// lmdb works on the files directly, it can't be run on a virtual filesystem.
+		if !vfs.IsDefault(opts.FS) {
+			return nil, fmt.Errorf("kvdrivers: lmdb driver: %w", vfs.ErrUnsupportedFS)
+		}
+		db, err := NewLmdb(opts.Path, opts.Config)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	})
+}
+
 // NewLmdb returns an initialized Lmdb Env with the provided configuration Parameter.
 func NewLmdb(path string, conf Config) (*LmdbEmbed, error) {
 	// Create directory if it doesn't exist
@@ -169,10 +184,7 @@ func (l *LmdbEmbed) SetChunks(key []byte, chunks [][]byte, checksum uint32) erro
 		metrics.MeasureSinceWithLabels(mSetLatency, startTime, l.label)
 	}()
 
-	metaData := make([]byte, 9)
-	metaData[0] = chunkedValue
-	binary.LittleEndian.PutUint32(metaData[1:], uint32(len(chunks)))
-	binary.LittleEndian.PutUint32(metaData[5:], checksum)
+	metaData := encodeChunkMetadata(NewChunkLayout(chunks, checksum))
 
 	return l.env.Update(func(txn *lmdb.Txn) error {
 		// existing chunks and delete them
@@ -331,10 +343,8 @@ func (l *LmdbEmbed) DeleteEntireRows(rowKeys [][]byte) (int, error) {
 				k, _, err = c.Get(nil, nil, lmdb.Next)
 			}
 
-			if err != nil {
-				if errors.Is(err, lmdb.NotFound) {
-					return nil
-				}
+			// cursor moved past the last key, continue with the next row.
+			if err != nil && !lmdb.IsNotFound(err) {
 				return err
 			}
 		}
@@ -543,6 +553,155 @@ func (l *LmdbEmbed) getChunked(txn *lmdb.Txn, key []byte, storedValue []byte) (*
 	return fullValue, nil
 }
 
+// GetValueReader returns a ChunkReader for the value associated with the key. Chunked values
+// are streamed chunk by chunk, each chunk is read in its own read transaction, so a slow reader
+// never holds one open. The reader returns ErrValueChanged once the value is overwritten or deleted.
+func (l *LmdbEmbed) GetValueReader(key []byte) (*ChunkReader, error) {
+	rowKey := []byte(string(key) + rowKeySeperator)
+	metrics.IncrCounterWithLabels(mGetTotal, 1, l.label)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mGetLatency, startTime, l.label)
+	}()
+
+	var reader *ChunkReader
+	err := l.env.View(func(txn *lmdb.Txn) error {
+		var err error
+		reader, err = l.valueReader(txn, key, rowKey)
+		return err
+	})
+	return reader, err
+}
+
+// valueReader returns a ChunkReader for the value associated with the key from the provided read transaction.
+func (l *LmdbEmbed) valueReader(txn *lmdb.Txn, key, rowKey []byte) (*ChunkReader, error) {
+	storedValue, err := txn.Get(l.db, key)
+	if err != nil {
+		if lmdb.IsNotFound(err) {
+			if _, err = txn.Get(l.db, rowKey); err == nil {
+				return nil, ErrUseGetColumnAPI
+			}
+			if lmdb.IsNotFound(err) {
+				return nil, ErrKeyNotFound
+			}
+		}
+		return nil, fmt.Errorf("failed to get key %s: %w", string(key), err)
+	}
+
+	if len(storedValue) == 0 {
+		return nil, ErrRecordCorrupted
+	}
+
+	flag := storedValue[0]
+	switch flag {
+	case kvValue:
+		value := make([]byte, len(storedValue[1:]))
+		copy(value, storedValue[1:])
+		return newFullValueReader(value), nil
+	case chunkedValue:
+		layout, err := decodeChunkMetadata(storedValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunk metadata for key %s: %w", string(key), err)
+		}
+		return NewChunkReader(int(layout.ChunkCount), layout.Checksum, func(i int) ([]byte, error) {
+			return l.getChunk(key, i, layout)
+		}), nil
+	case rowColumnValue:
+		return nil, ErrUseGetColumnAPI
+	default:
+		return nil, fmt.Errorf("invalid data format for key %s: %w", string(key), ErrInvalidOpsForValueType)
+	}
+}
+
+// getChunk returns a copy of the i-th chunk of the chunked value associated with the key,
+// if the value still has the layout.
+func (l *LmdbEmbed) getChunk(key []byte, i int, layout ChunkLayout) ([]byte, error) {
+	var chunk []byte
+	err := l.env.View(func(txn *lmdb.Txn) error {
+		metaData, err := txn.Get(l.db, key)
+		if err != nil && !lmdb.IsNotFound(err) {
+			return err
+		}
+		if err := layout.checkVersion(metaData); err != nil {
+			return fmt.Errorf("key %s: %w", string(key), err)
+		}
+		chunk, err = l.readChunk(txn, key, i)
+		return err
+	})
+	return chunk, err
+}
+
+// readChunk returns a copy of the i-th chunk of the chunked value associated with the key from the txn.
+func (l *LmdbEmbed) readChunk(txn *lmdb.Txn, key []byte, i int) ([]byte, error) {
+	chunkKey := fmt.Sprintf("%s_chunk_%d", key, i)
+	chunkData, err := txn.Get(l.db, []byte(chunkKey))
+	if err != nil {
+		if lmdb.IsNotFound(err) {
+			return nil, fmt.Errorf("chunk %d missing for key %s: %w", i, string(key), ErrRecordCorrupted)
+		}
+		return nil, err
+	}
+	return append([]byte(nil), chunkData...), nil
+}
+
+// GetRange returns length bytes of the value associated with the key starting at offset.
+// Full values are sliced in place, for chunked values only the chunks overlapping the range are read.
+// A non-positive length reads till the end of the value.
+func (l *LmdbEmbed) GetRange(key []byte, offset, length int64) ([]byte, error) {
+	rowKey := []byte(string(key) + rowKeySeperator)
+	metrics.IncrCounterWithLabels(mGetTotal, 1, l.label)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mGetLatency, startTime, l.label)
+	}()
+
+	var value []byte
+	var layout ChunkLayout
+	err := l.env.View(func(txn *lmdb.Txn) error {
+		storedValue, err := txn.Get(l.db, key)
+		if err != nil {
+			if lmdb.IsNotFound(err) {
+				if _, err = txn.Get(l.db, rowKey); err == nil {
+					return ErrUseGetColumnAPI
+				}
+				if lmdb.IsNotFound(err) {
+					return ErrKeyNotFound
+				}
+			}
+			return fmt.Errorf("failed to get key %s: %w", string(key), err)
+		}
+
+		if len(storedValue) == 0 {
+			return ErrRecordCorrupted
+		}
+
+		flag := storedValue[0]
+		switch flag {
+		case kvValue:
+			value, err = SliceRange(storedValue[1:], offset, length)
+			return err
+		case chunkedValue:
+			layout, err = decodeChunkMetadata(storedValue)
+			if err != nil {
+				return fmt.Errorf("invalid chunk metadata for key %s: %w", string(key), err)
+			}
+			return nil
+		case rowColumnValue:
+			return ErrUseGetColumnAPI
+		default:
+			return fmt.Errorf("invalid data format for key %s: %w", string(key), ErrInvalidOpsForValueType)
+		}
+	})
+
+	if err != nil || value != nil {
+		return value, err
+	}
+
+	return layout.ReadRange(offset, length, func(i int) ([]byte, error) {
+		return l.getChunk(key, i, layout)
+	})
+}
+
 // GetRowColumns returns all the columns for the given row. If a ColumnPredicate predicate func is provided, it will only
 // return those columns for which the ColumnFilterFunc func returns true.
 func (l *LmdbEmbed) GetRowColumns(rowKey []byte, filter func(columnKey []byte) bool) (map[string][]byte, error) {
@@ -579,7 +738,7 @@ func (l *LmdbEmbed) GetRowColumns(rowKey []byte, filter func(columnKey []byte) b
 		case rowColumnValue:
 			err := l.getColumns(txn, rowKey, filter, entries)
 			if err != nil {
-				if errors.Is(err, lmdb.NotFound) {
+				if lmdb.IsNotFound(err) {
 					return nil
 				}
 				return err
@@ -638,21 +797,23 @@ func (l *LmdbEmbed) Snapshot(w io.Writer) error {
 	defer bw.Flush()
 
 	return l.env.View(func(txn *lmdb.Txn) error {
-		stat, err := l.env.Stat()
-		if err != nil {
-			return fmt.Errorf("failed to get database stats: %w", err)
-		}
-
 		cursor, err := txn.OpenCursor(l.db)
 		if err != nil {
 			return fmt.Errorf("failed to open cursor: %w", err)
 		}
 		defer cursor.Close()
 
-		buffer := make([]byte, stat.PSize)
-		var bytesWritten int
+		// values can be larger than a page, so every pair is written as it's read.
+		var header [4]byte
+		write := func(b []byte) error {
+			binary.LittleEndian.PutUint32(header[:], uint32(len(b)))
+			if _, err := bw.Write(header[:]); err != nil {
+				return err
+			}
+			_, err := bw.Write(b)
+			return err
+		}
 
-		// Iterate through all pages
 		for {
 			key, val, err := cursor.Get(nil, nil, lmdb.Next)
 			if lmdb.IsNotFound(err) {
@@ -662,17 +823,10 @@ func (l *LmdbEmbed) Snapshot(w io.Writer) error {
 				return fmt.Errorf("cursor iteration failed: %w", err)
 			}
 
-			binary.LittleEndian.PutUint32(buffer[0:4], uint32(len(key)))
-			bytesWritten = 4
-			copy(buffer[bytesWritten:], key)
-			bytesWritten += len(key)
-
-			binary.LittleEndian.PutUint32(buffer[bytesWritten:bytesWritten+4], uint32(len(val)))
-			bytesWritten += 4
-			copy(buffer[bytesWritten:], val)
-			bytesWritten += len(val)
-
-			if _, err := bw.Write(buffer[:bytesWritten]); err != nil {
+			if err := write(key); err != nil {
+				return fmt.Errorf("failed to write to snapshot: %w", err)
+			}
+			if err := write(val); err != nil {
 				return fmt.Errorf("failed to write to snapshot: %w", err)
 			}
 		}
@@ -3,7 +3,6 @@ package kvdrivers
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"runtime"
 	"time"
@@ -132,10 +131,7 @@ func (lq *LMDBTxnQueue) SetChunks(key []byte, chunks [][]byte, checksum uint32)
 		return lq.err
 	}
 
-	metaData := make([]byte, 9)
-	metaData[0] = chunkedValue
-	binary.LittleEndian.PutUint32(metaData[1:], uint32(len(chunks)))
-	binary.LittleEndian.PutUint32(metaData[5:], checksum)
+	metaData := encodeChunkMetadata(NewChunkLayout(chunks, checksum))
 
 	lq.opsQueue = append(lq.opsQueue, func(txn *lmdb.Txn) error {
 		storedValue, err := txn.Get(lq.db, key)
@@ -307,10 +303,8 @@ func (lq *LMDBTxnQueue) BatchDeleteRows(rowKeys [][]byte) error {
 				k, _, err = c.Get(nil, nil, lmdb.Next)
 			}
 
-			if err != nil {
-				if errors.Is(err, lmdb.NotFound) {
-					return nil
-				}
+			// cursor moved past the last key.
+			if err != nil && !lmdb.IsNotFound(err) {
 				return err
 			}
 
@@ -0,0 +1,656 @@
+package kvdrivers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-immutable-radix"
+	"github.com/hashicorp/go-metrics"
+)
+
+// MemoryEmbed is a pure Go, ordered and in-memory BTreeStore backed by an immutable radix tree.
+// It uses the same key layout as BoltDB and LMDB for full, chunked and row column values.
+// Writers are serialized and publish a new version of the tree once all of its operation succeed,
+// readers work lock free on the version of the tree loaded when the call started.
+type MemoryEmbed struct {
+	mu        sync.Mutex
+	tree      atomic.Pointer[iradix.Tree]
+	metadata  atomic.Pointer[iradix.Tree]
+	namespace []byte
+	label     []metrics.Label
+}
+
+func init() {
+	Register(DriverMemory, func(opts Options) (Store, error) {
+		db, err := NewMemory(opts.Config)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	})
+}
+
+// NewMemory returns an initialized empty MemoryEmbed.
+func NewMemory(conf Config) (*MemoryEmbed, error) {
+	l := []metrics.Label{{Name: "namespace", Value: conf.Namespace},
+		{Name: "db", Value: "memory"}}
+	m := &MemoryEmbed{namespace: []byte(conf.Namespace), label: l}
+	m.tree.Store(iradix.New())
+	m.metadata.Store(iradix.New())
+	return m, nil
+}
+
+// FSync is a no-op, as there is nothing to persist.
+func (m *MemoryEmbed) FSync() error {
+	return nil
+}
+
+// Close releases all the data held by the store.
+func (m *MemoryEmbed) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tree.Store(iradix.New())
+	m.metadata.Store(iradix.New())
+	return nil
+}
+
+// update runs fn inside a write txn over the latest version of the tree.
+// The new version is published only if fn doesn't return any error.
+func (m *MemoryEmbed) update(fn func(txn *iradix.Txn) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	txn := m.tree.Load().Txn()
+	if err := fn(txn); err != nil {
+		return err
+	}
+	m.tree.Store(txn.Commit())
+	return nil
+}
+
+// Set associates a value with a key within a specific namespace.
+func (m *MemoryEmbed) Set(key []byte, value []byte) error {
+	metrics.IncrCounterWithLabels(mSetTotal, 1, m.label)
+	metrics.IncrCounterWithLabels(mTxnEntriesModifiedTotal, 1, m.label)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mSetLatency, startTime, m.label)
+	}()
+
+	return m.update(func(txn *iradix.Txn) error {
+		memPutValue(txn, key, value)
+		return nil
+	})
+}
+
+// SetMany associates multiple values with corresponding keys.
+func (m *MemoryEmbed) SetMany(keys [][]byte, values [][]byte) error {
+	if len(keys) != len(values) {
+		return fmt.Errorf("keys and values length mismatch: keys=%d values=%d", len(keys), len(values))
+	}
+
+	metrics.IncrCounterWithLabels(mSetTotal, 1, m.label)
+	metrics.IncrCounterWithLabels(mTxnEntriesModifiedTotal, float32(len(keys)), m.label)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mSetLatency, startTime, m.label)
+	}()
+
+	return m.update(func(txn *iradix.Txn) error {
+		for i, key := range keys {
+			memPutValue(txn, key, values[i])
+		}
+		return nil
+	})
+}
+
+// SetChunks stores a value that has been split into chunks, associating them with a single key.
+func (m *MemoryEmbed) SetChunks(key []byte, chunks [][]byte, checksum uint32) error {
+	metrics.IncrCounterWithLabels(mSetTotal, 1, m.label)
+	metrics.IncrCounterWithLabels(mTxnEntriesModifiedTotal, float32(len(chunks))+1, m.label)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mSetLatency, startTime, m.label)
+	}()
+
+	return m.update(func(txn *iradix.Txn) error {
+		return memSetChunks(txn, key, chunks, checksum)
+	})
+}
+
+// SetManyRowColumns update/insert multiple rows and the provided columnEntries to the row.
+func (m *MemoryEmbed) SetManyRowColumns(rowKeys [][]byte, columnEntriesPerRow []map[string][]byte) error {
+	if len(rowKeys) != len(columnEntriesPerRow) {
+		return ErrInvalidArguments
+	}
+
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mSetLatency, startTime, m.label)
+	}()
+
+	totalColumns := 0
+	err := m.update(func(txn *iradix.Txn) error {
+		for i, rowKey := range rowKeys {
+			totalColumns += memPutRowColumns(txn, rowKey, columnEntriesPerRow[i])
+		}
+		return nil
+	})
+
+	metrics.IncrCounterWithLabels(mSetTotal, 1, m.label)
+	metrics.IncrCounterWithLabels(mTxnEntriesModifiedTotal, float32(totalColumns+len(rowKeys)), m.label)
+	return err
+}
+
+// DeleteManyRowColumns delete the provided columnEntries from the associated row.
+func (m *MemoryEmbed) DeleteManyRowColumns(rowKeys [][]byte, columnEntriesPerRow []map[string][]byte) error {
+	if len(rowKeys) != len(columnEntriesPerRow) {
+		return ErrInvalidArguments
+	}
+
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mDelLatency, startTime, m.label)
+	}()
+
+	totalColumns := 0
+	err := m.update(func(txn *iradix.Txn) error {
+		for i, rowKey := range rowKeys {
+			totalColumns += memDeleteRowColumns(txn, rowKey, columnEntriesPerRow[i])
+		}
+		return nil
+	})
+
+	metrics.IncrCounterWithLabels(mDelTotal, 1, m.label)
+	metrics.IncrCounterWithLabels(mTxnEntriesModifiedTotal, float32(totalColumns+len(rowKeys)), m.label)
+	return err
+}
+
+// DeleteEntireRows deletes all the columns associated with all the rowKeys.
+func (m *MemoryEmbed) DeleteEntireRows(rowKeys [][]byte) (int, error) {
+	if len(rowKeys) == 0 {
+		return 0, nil
+	}
+
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mDelLatency, startTime, m.label)
+	}()
+
+	columnsDeleted := -len(rowKeys)
+	err := m.update(func(txn *iradix.Txn) error {
+		for _, rowKey := range rowKeys {
+			columnsDeleted += memDeleteRow(txn, rowKey)
+		}
+		return nil
+	})
+
+	metrics.IncrCounterWithLabels(mDelTotal, 1, m.label)
+	metrics.IncrCounterWithLabels(mTxnEntriesModifiedTotal, float32(columnsDeleted+len(rowKeys)), m.label)
+	return columnsDeleted, err
+}
+
+// Delete deletes a value with a key within a specific namespace.
+func (m *MemoryEmbed) Delete(key []byte) error {
+	metrics.IncrCounterWithLabels(mDelTotal, 1, m.label)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mDelLatency, startTime, m.label)
+	}()
+
+	return m.update(func(txn *iradix.Txn) error {
+		modified, err := memDeleteValue(txn, key)
+		metrics.IncrCounterWithLabels(mTxnEntriesModifiedTotal, float32(modified), m.label)
+		return err
+	})
+}
+
+// DeleteMany deletes multiple values with corresponding keys within a namespace.
+func (m *MemoryEmbed) DeleteMany(keys [][]byte) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	metrics.IncrCounterWithLabels(mDelTotal, 1, m.label)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mDelLatency, startTime, m.label)
+	}()
+
+	modified := 0
+	err := m.update(func(txn *iradix.Txn) error {
+		for _, key := range keys {
+			n, err := memDeleteValue(txn, key)
+			if err != nil {
+				return err
+			}
+			modified += n
+		}
+		return nil
+	})
+
+	metrics.IncrCounterWithLabels(mTxnEntriesModifiedTotal, float32(modified), m.label)
+	return err
+}
+
+// Get retrieves a value associated with a key within a specific namespace.
+func (m *MemoryEmbed) Get(key []byte) ([]byte, error) {
+	metrics.IncrCounterWithLabels(mGetTotal, 1, m.label)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mGetLatency, startTime, m.label)
+	}()
+
+	tree := m.tree.Load()
+	storedValue, ok := memGet(tree, key)
+	if !ok {
+		// check if Column Value type
+		storedValue, ok = memGet(tree, []byte(string(key)+rowKeySeperator))
+		if !ok {
+			return nil, ErrKeyNotFound
+		}
+	}
+
+	if len(storedValue) == 0 {
+		return nil, ErrRecordCorrupted
+	}
+
+	flag := storedValue[0]
+	switch flag {
+	case kvValue:
+		value := make([]byte, len(storedValue[1:]))
+		copy(value, storedValue[1:])
+		return value, nil
+	case chunkedValue:
+		layout, err := decodeChunkMetadata(storedValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunk metadata for key %s: %w", string(key), err)
+		}
+
+		var calculatedChecksum uint32
+		fullValue := new(bytes.Buffer)
+		for i := 0; i < int(layout.ChunkCount); i++ {
+			chunk, err := memGetChunk(tree, key, i)
+			if err != nil {
+				return nil, err
+			}
+			calculatedChecksum = crc32.Update(calculatedChecksum, crc32.IEEETable, chunk)
+			fullValue.Write(chunk)
+		}
+
+		if calculatedChecksum != layout.Checksum {
+			return nil, fmt.Errorf("checksum mismatch for key %s: %w", string(key), ErrRecordCorrupted)
+		}
+		return fullValue.Bytes(), nil
+	case rowColumnValue:
+		return nil, ErrUseGetColumnAPI
+	default:
+		// we don't know how to deal with this return the data and error.
+		return append([]byte(nil), storedValue...), fmt.Errorf("invalid data format for key %s: %w", string(key), ErrInvalidOpsForValueType)
+	}
+}
+
+// GetValueReader returns a ChunkReader for the value associated with the key.
+// Chunks are streamed from the version of the tree loaded when the reader was created.
+func (m *MemoryEmbed) GetValueReader(key []byte) (*ChunkReader, error) {
+	metrics.IncrCounterWithLabels(mGetTotal, 1, m.label)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mGetLatency, startTime, m.label)
+	}()
+
+	tree := m.tree.Load()
+	layout, value, err := memGetValueOrLayout(tree, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if value != nil {
+		return newFullValueReader(value), nil
+	}
+
+	return NewChunkReader(int(layout.ChunkCount), layout.Checksum, func(i int) ([]byte, error) {
+		return memGetChunk(tree, key, i)
+	}), nil
+}
+
+// GetRange returns length bytes of the value associated with the key starting at offset.
+// Full values are sliced in place, for chunked values only the chunks overlapping the range are read.
+// A non-positive length reads till the end of the value.
+func (m *MemoryEmbed) GetRange(key []byte, offset, length int64) ([]byte, error) {
+	metrics.IncrCounterWithLabels(mGetTotal, 1, m.label)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mGetLatency, startTime, m.label)
+	}()
+
+	tree := m.tree.Load()
+	layout, value, err := memGetValueOrLayout(tree, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if value != nil {
+		return SliceRange(value, offset, length)
+	}
+
+	return layout.ReadRange(offset, length, func(i int) ([]byte, error) {
+		return memGetChunk(tree, key, i)
+	})
+}
+
+// GetRowColumns returns all the columns for the given row. If a ColumnPredicate predicate func is provided, it will only
+// return those columns for which the ColumnFilterFunc func returns true.
+func (m *MemoryEmbed) GetRowColumns(rowKey []byte, filter func(columnKey []byte) bool) (map[string][]byte, error) {
+	rowKey = []byte(string(rowKey) + rowKeySeperator)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mRowGetLatency, startTime, m.label)
+	}()
+
+	if filter == nil {
+		filter = func(columnKey []byte) bool {
+			return true
+		}
+	}
+
+	tree := m.tree.Load()
+	storedValue, ok := memGet(tree, rowKey)
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	if len(storedValue) == 0 || storedValue[0] != rowColumnValue {
+		return nil, fmt.Errorf("invalid data format for Row key %s: %w", string(rowKey), ErrInvalidOpsForValueType)
+	}
+
+	entries := make(map[string][]byte)
+	it := tree.Root().Iterator()
+	it.SeekPrefix(rowKey)
+	for k, v, ok := it.Next(); ok; k, v, ok = it.Next() {
+		// skip the row marker itself.
+		if len(k) == len(rowKey) {
+			continue
+		}
+
+		trimmedKey := k[len(rowKey):]
+		if filter(trimmedKey) {
+			entries[string(trimmedKey)] = append([]byte(nil), v.([]byte)...)
+		}
+	}
+
+	metrics.IncrCounterWithLabels(mRowGetTotal, float32(len(entries)), m.label)
+	return entries, nil
+}
+
+// StoreMetadata stores the system metadata, outside the namespace.
+func (m *MemoryEmbed) StoreMetadata(key []byte, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tree, _, _ := m.metadata.Load().Insert(bytes.Clone(key), bytes.Clone(value))
+	m.metadata.Store(tree)
+	return nil
+}
+
+// RetrieveMetadata returns the system metadata associated with the key.
+func (m *MemoryEmbed) RetrieveMetadata(key []byte) ([]byte, error) {
+	value, ok := memGet(m.metadata.Load(), key)
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return append([]byte(nil), value...), nil
+}
+
+// Snapshot writes every key value pair of the namespace as length prefixed entries, same as LMDB.
+func (m *MemoryEmbed) Snapshot(w io.Writer) error {
+	startTime := time.Now()
+	metrics.IncrCounterWithLabels(mSnapshotTotal, 1, m.label)
+	defer func() {
+		metrics.MeasureSinceWithLabels(mSnapshotLatency, startTime, m.label)
+	}()
+
+	bw := bufio.NewWriter(w)
+	var lenBuf [4]byte
+	var err error
+	m.tree.Load().Root().Walk(func(k []byte, v interface{}) bool {
+		value := v.([]byte)
+		binary.LittleEndian.PutUint32(lenBuf[:], uint32(len(k)))
+		if _, err = bw.Write(lenBuf[:]); err != nil {
+			return true
+		}
+		if _, err = bw.Write(k); err != nil {
+			return true
+		}
+		binary.LittleEndian.PutUint32(lenBuf[:], uint32(len(value)))
+		if _, err = bw.Write(lenBuf[:]); err != nil {
+			return true
+		}
+		_, err = bw.Write(value)
+		return err != nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to write to snapshot: %w", err)
+	}
+	return bw.Flush()
+}
+
+// Restore loads the key value pairs written by Snapshot, on top of the current data.
+func (m *MemoryEmbed) Restore(r io.Reader) error {
+	br := bufio.NewReader(r)
+	return m.update(func(txn *iradix.Txn) error {
+		for {
+			var keyLen uint32
+			if err := binary.Read(br, binary.LittleEndian, &keyLen); err != nil {
+				if err == io.EOF {
+					break // End of file
+				}
+				return fmt.Errorf("failed to read key length: %w", err)
+			}
+
+			key := make([]byte, keyLen)
+			if _, err := io.ReadFull(br, key); err != nil {
+				return fmt.Errorf("failed to read key: %w", err)
+			}
+
+			var valLen uint32
+			if err := binary.Read(br, binary.LittleEndian, &valLen); err != nil {
+				return fmt.Errorf("failed to read value length: %w", err)
+			}
+
+			value := make([]byte, valLen)
+			if _, err := io.ReadFull(br, value); err != nil {
+				return fmt.Errorf("failed to read value: %w", err)
+			}
+
+			txn.Insert(key, value)
+		}
+
+		return nil
+	})
+}
+
+// memGetValueOrLayout returns a copy of the full value, or the layout if the value is chunked.
+func memGetValueOrLayout(tree *iradix.Tree, key []byte) (ChunkLayout, []byte, error) {
+	storedValue, ok := memGet(tree, key)
+	if !ok {
+		if _, ok := memGet(tree, []byte(string(key)+rowKeySeperator)); ok {
+			return ChunkLayout{}, nil, ErrUseGetColumnAPI
+		}
+		return ChunkLayout{}, nil, ErrKeyNotFound
+	}
+
+	if len(storedValue) == 0 {
+		return ChunkLayout{}, nil, ErrRecordCorrupted
+	}
+
+	flag := storedValue[0]
+	switch flag {
+	case kvValue:
+		value := make([]byte, len(storedValue[1:]))
+		copy(value, storedValue[1:])
+		return ChunkLayout{}, value, nil
+	case chunkedValue:
+		layout, err := decodeChunkMetadata(storedValue)
+		if err != nil {
+			return ChunkLayout{}, nil, fmt.Errorf("invalid chunk metadata for key %s: %w", string(key), err)
+		}
+		return layout, nil, nil
+	case rowColumnValue:
+		return ChunkLayout{}, nil, ErrUseGetColumnAPI
+	default:
+		return ChunkLayout{}, nil, fmt.Errorf("invalid data format for key %s: %w", string(key), ErrInvalidOpsForValueType)
+	}
+}
+
+func memGet(tree *iradix.Tree, key []byte) ([]byte, bool) {
+	v, ok := tree.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return v.([]byte), true
+}
+
+// memGetChunk returns the i-th chunk of the chunked value associated with the key.
+// Values in the tree are never mutated, so the chunk is returned without a copy.
+func memGetChunk(tree *iradix.Tree, key []byte, i int) ([]byte, error) {
+	chunk, ok := memGet(tree, []byte(fmt.Sprintf("%s_chunk_%d", key, i)))
+	if !ok {
+		return nil, fmt.Errorf("chunk %d missing for key %s: %w", i, string(key), ErrRecordCorrupted)
+	}
+	return chunk, nil
+}
+
+func memTxnGet(txn *iradix.Txn, key []byte) ([]byte, bool) {
+	v, ok := txn.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return v.([]byte), true
+}
+
+// memPutValue stores the copy of the value as a full value.
+func memPutValue(txn *iradix.Txn, key, value []byte) {
+	storedValue := make([]byte, 1+len(value))
+	storedValue[0] = kvValue
+	copy(storedValue[1:], value)
+	txn.Insert(bytes.Clone(key), storedValue)
+}
+
+// memSetChunks stores the chunks and the chunk metadata, removing the chunks of any older chunked value.
+func memSetChunks(txn *iradix.Txn, key []byte, chunks [][]byte, checksum uint32) error {
+	storedValue, ok := memTxnGet(txn, key)
+	if ok && len(storedValue) > 0 && storedValue[0] == chunkedValue {
+		if _, err := memDeleteChunks(txn, key, storedValue); err != nil {
+			return err
+		}
+	}
+
+	txn.Insert(bytes.Clone(key), encodeChunkMetadata(NewChunkLayout(chunks, checksum)))
+	for i, chunk := range chunks {
+		chunkKey := fmt.Sprintf("%s_chunk_%d", key, i)
+		txn.Insert([]byte(chunkKey), bytes.Clone(chunk))
+	}
+	return nil
+}
+
+// memDeleteValue deletes the full or chunked value associated with the key,
+// and returns the number of entries deleted.
+func memDeleteValue(txn *iradix.Txn, key []byte) (int, error) {
+	storedValue, ok := memTxnGet(txn, key)
+	if !ok {
+		return 0, nil
+	}
+
+	if len(storedValue) == 0 {
+		return 0, ErrRecordCorrupted
+	}
+
+	flag := storedValue[0]
+	switch flag {
+	case kvValue:
+		txn.Delete(key)
+		return 1, nil
+	case chunkedValue:
+		n, err := memDeleteChunks(txn, key, storedValue)
+		if err != nil {
+			return 0, err
+		}
+		txn.Delete(key)
+		return n + 1, nil
+	}
+	return 0, fmt.Errorf("invalid data format for key %s: %w", string(key), ErrInvalidOpsForValueType)
+}
+
+func memDeleteChunks(txn *iradix.Txn, key []byte, metaData []byte) (int, error) {
+	layout, err := decodeChunkMetadata(metaData)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chunk metadata for key %s: %w", string(key), err)
+	}
+
+	for i := 0; i < int(layout.ChunkCount); i++ {
+		chunkKey := fmt.Sprintf("%s_chunk_%d", key, i)
+		txn.Delete([]byte(chunkKey))
+	}
+	return int(layout.ChunkCount), nil
+}
+
+// memPutRowColumns upserts the columns of the row, and returns the number of columns put.
+func memPutRowColumns(txn *iradix.Txn, rowKey []byte, columnEntries map[string][]byte) int {
+	if len(columnEntries) == 0 {
+		return 0
+	}
+
+	pKey := append(append([]byte(nil), rowKey...), rowKeySeperator...)
+	entries := appendRowKeyToColumnKey(pKey, columnEntries)
+	for entryKey, entry := range entries {
+		txn.Insert([]byte(entryKey), bytes.Clone(entry))
+	}
+
+	txn.Insert(pKey, []byte{rowColumnValue})
+	return len(entries)
+}
+
+// memDeleteRowColumns deletes the columns of the row, and returns the number of columns that existed.
+func memDeleteRowColumns(txn *iradix.Txn, rowKey []byte, columnEntries map[string][]byte) int {
+	if len(columnEntries) == 0 {
+		return 0
+	}
+
+	deleted := 0
+	pKey := append(append([]byte(nil), rowKey...), rowKeySeperator...)
+	entries := appendRowKeyToColumnKey(pKey, columnEntries)
+	for entryKey := range entries {
+		if _, ok := txn.Delete([]byte(entryKey)); ok {
+			deleted++
+		}
+	}
+
+	txn.Insert(pKey, []byte{rowColumnValue})
+	return deleted
+}
+
+// memDeleteRow deletes the row and all of its column, and returns the number of entries deleted.
+func memDeleteRow(txn *iradix.Txn, rowKey []byte) int {
+	pKey := append(append([]byte(nil), rowKey...), rowKeySeperator...)
+
+	// collect first, the nodes of the txn could be modified in place while deleting.
+	keys := make([][]byte, 0)
+	it := txn.Root().Iterator()
+	it.SeekPrefix(pKey)
+	for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+		keys = append(keys, k)
+	}
+
+	for _, k := range keys {
+		txn.Delete(k)
+	}
+	return len(keys)
+}
@@ -0,0 +1,44 @@
+package kvdrivers_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	kv2 "github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
+	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers/kvdriverstest"
+	"github.com/ankur-anand/unisondb/internal/etc"
+	"github.com/hashicorp/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory_Suite(t *testing.T) {
+	inm := metrics.NewInmemSink(1*time.Millisecond, time.Minute)
+	cfg := metrics.DefaultConfig("memory_test")
+	cfg.TimerGranularity = time.Second
+	cfg.EnableHostname = false
+	cfg.EnableRuntimeMetrics = true
+	_, err := metrics.NewGlobal(cfg, inm)
+	if err != nil {
+		panic(err)
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		kvdriverstest.Run(t, kvdriverstest.Harness{
+			Open: func(path string, config kv2.Config) (kvdriverstest.Store, error) {
+				return kv2.NewMemory(config)
+			},
+			NewTxnQueue: func(store kvdriverstest.Store, maxBatchSize int) kvdriverstest.TxnQueue {
+				return store.(*kv2.MemoryEmbed).NewTxnQueue(maxBatchSize)
+			},
+		})
+	})
+
+	buf := new(bytes.Buffer)
+	err = etc.DumpStats(inm, buf)
+	assert.NoError(t, err, "failed to dump stats")
+	output := buf.String()
+	assert.Contains(t, output, "set.total")
+	assert.Contains(t, output, "get.total")
+	assert.Contains(t, output, "delete.total")
+}
@@ -0,0 +1,244 @@
+package kvdrivers
+
+import (
+	"time"
+
+	"github.com/hashicorp/go-immutable-radix"
+	"github.com/hashicorp/go-metrics"
+)
+
+// MemoryTxnQueue encapsulates MemoryEmbed Transactions and provides an API that allow multiple
+// operation to be put in queue that is supposed to be Executed in the same orders.
+// It flushes the batch automatically if configured max batch Size threshold is reached.
+// Caller should call Commit in the end to finish any pending Txn not flushed via maxBatchSize.
+// Single instance of Txn are not concurrent safe.
+type MemoryTxnQueue struct {
+	label           []metrics.Label
+	store           *MemoryEmbed
+	err             error
+	opsQueue        []func(*iradix.Txn) error
+	maxBatchSize    int
+	entriesModified float32
+	putOps          float32
+	deleteOps       float32
+	stats           *TxnStats
+}
+
+// NewTxnQueue returns an initialized MemoryTxnQueue for Batch API queuing and commit.
+func (m *MemoryEmbed) NewTxnQueue(maxBatchSize int) *MemoryTxnQueue {
+	return &MemoryTxnQueue{
+		store:        m,
+		label:        m.label,
+		maxBatchSize: maxBatchSize,
+		opsQueue:     make([]func(*iradix.Txn) error, 0, maxBatchSize),
+		stats:        &TxnStats{},
+	}
+}
+
+// BatchPut queue one or more key-value pairs inside a transaction that will be commited upon Commit or max batch size
+// threshold breach. If the value exists, it replaces the existing value, else sets a new value associated with the key.
+// Caller need to take care to not upsert the row column value, else there is no guarantee for consistency in storage.
+func (mq *MemoryTxnQueue) BatchPut(keys, values [][]byte) error {
+	if mq.err != nil {
+		return mq.err
+	}
+
+	for i, key := range keys {
+		mq.opsQueue = append(mq.opsQueue, func(txn *iradix.Txn) error {
+			memPutValue(txn, key, values[i])
+			mq.putOps++
+			mq.entriesModified++
+			return nil
+		})
+	}
+
+	if len(mq.opsQueue) >= mq.maxBatchSize {
+		mq.err = mq.flushBatch()
+	}
+
+	return mq.err
+}
+
+// BatchDelete queue one or more key inside a transaction for deletion. It doesn't delete rows or columns.
+// Deletion happens either when Commit is called or max batch size threshold is reached.
+// Caller need to call BatchDeleteRows or BatchDeleteRowsColumns to work with rows and columns type value.
+func (mq *MemoryTxnQueue) BatchDelete(keys [][]byte) error {
+	if mq.err != nil {
+		return mq.err
+	}
+
+	for _, key := range keys {
+		mq.opsQueue = append(mq.opsQueue, func(txn *iradix.Txn) error {
+			n, err := memDeleteValue(txn, key)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				mq.deleteOps++
+				mq.entriesModified += float32(n)
+			}
+			return nil
+		})
+	}
+
+	if len(mq.opsQueue) >= mq.maxBatchSize {
+		mq.err = mq.flushBatch()
+	}
+
+	return mq.err
+}
+
+// SetChunks stores a value that has been split into chunks, associating them with a single key.
+// It queues the operation in Txn, which is flushed when either max size threshold is reached or during commit call.
+func (mq *MemoryTxnQueue) SetChunks(key []byte, chunks [][]byte, checksum uint32) error {
+	if mq.err != nil {
+		return mq.err
+	}
+
+	mq.opsQueue = append(mq.opsQueue, func(txn *iradix.Txn) error {
+		mq.putOps++
+		mq.entriesModified += float32(len(chunks)) + 1
+		return memSetChunks(txn, key, chunks, checksum)
+	})
+
+	if len(mq.opsQueue) >= mq.maxBatchSize {
+		mq.err = mq.flushBatch()
+	}
+
+	return mq.err
+}
+
+// BatchPutRowColumns queues updates or inserts of multiple rows with the provided column entries.
+// Each row in `rowKeys` maps to a set of columns in `columnEntriesPerRow`.
+func (mq *MemoryTxnQueue) BatchPutRowColumns(rowKeys [][]byte, columnEntriesPerRow []map[string][]byte) error {
+	if mq.err != nil {
+		return mq.err
+	}
+
+	if len(rowKeys) != len(columnEntriesPerRow) {
+		mq.err = ErrInvalidArguments
+		return mq.err
+	}
+
+	for i, rowKey := range rowKeys {
+		mq.opsQueue = append(mq.opsQueue, func(txn *iradix.Txn) error {
+			n := memPutRowColumns(txn, rowKey, columnEntriesPerRow[i])
+			if n > 0 {
+				mq.putOps++
+				mq.entriesModified += float32(n) + 1
+			}
+			return nil
+		})
+	}
+
+	if len(mq.opsQueue) >= mq.maxBatchSize {
+		mq.err = mq.flushBatch()
+	}
+
+	return mq.err
+}
+
+// BatchDeleteRowColumns queues deletes of multiple rows with the provided column entries.
+// Each row in `rowKeys` maps to a set of columns in `columnEntriesPerRow`.
+func (mq *MemoryTxnQueue) BatchDeleteRowColumns(rowKeys [][]byte, columnEntriesPerRow []map[string][]byte) error {
+	if mq.err != nil {
+		return mq.err
+	}
+
+	if len(rowKeys) != len(columnEntriesPerRow) {
+		mq.err = ErrInvalidArguments
+		return mq.err
+	}
+
+	for i, rowKey := range rowKeys {
+		mq.opsQueue = append(mq.opsQueue, func(txn *iradix.Txn) error {
+			if len(columnEntriesPerRow[i]) == 0 {
+				return nil
+			}
+			mq.deleteOps++
+			mq.entriesModified += float32(memDeleteRowColumns(txn, rowKey, columnEntriesPerRow[i])) + 1
+			return nil
+		})
+	}
+
+	if len(mq.opsQueue) >= mq.maxBatchSize {
+		mq.err = mq.flushBatch()
+	}
+
+	return mq.err
+}
+
+// BatchDeleteRows  queue deletes of the row and all it's associated Columns from the database.
+func (mq *MemoryTxnQueue) BatchDeleteRows(rowKeys [][]byte) error {
+	if mq.err != nil {
+		return mq.err
+	}
+
+	for _, rowKey := range rowKeys {
+		mq.opsQueue = append(mq.opsQueue, func(txn *iradix.Txn) error {
+			mq.deleteOps++
+			mq.entriesModified += float32(memDeleteRow(txn, rowKey))
+			return nil
+		})
+	}
+
+	if len(mq.opsQueue) >= mq.maxBatchSize {
+		mq.err = mq.flushBatch()
+	}
+
+	return mq.err
+}
+
+func (mq *MemoryTxnQueue) flushBatch() error {
+	if mq.err != nil || len(mq.opsQueue) == 0 {
+		return mq.err
+	}
+
+	startTime := time.Now()
+
+	metrics.IncrCounterWithLabels(mTxnFlushTotal, 1, mq.label)
+	defer func() {
+		metrics.MeasureSinceWithLabels(mTxnFlushLatency, startTime, mq.label)
+	}()
+
+	// a failed op discards the whole txn, none of the ops in the batch gets published.
+	err := mq.store.update(func(txn *iradix.Txn) error {
+		for _, op := range mq.opsQueue {
+			if err := op(txn); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		mq.err = err
+		return mq.err
+	}
+
+	metrics.IncrCounterWithLabels(mTxnFlushBatchSize, float32(len(mq.opsQueue)), mq.label)
+	metrics.IncrCounterWithLabels(mSetTotal, mq.putOps, mq.label)
+	metrics.IncrCounterWithLabels(mDelTotal, mq.deleteOps, mq.label)
+	metrics.IncrCounterWithLabels(mTxnEntriesModifiedTotal, mq.entriesModified, mq.label)
+	mq.stats.EntriesModified += mq.entriesModified
+	mq.stats.DeleteOps += mq.deleteOps
+	mq.stats.PutOps += mq.putOps
+	mq.opsQueue = nil
+	mq.putOps = 0
+	mq.deleteOps = 0
+	mq.entriesModified = 0
+	return nil
+}
+
+func (mq *MemoryTxnQueue) Stats() TxnStats {
+	return *mq.stats
+}
+
+// Commit all the pending operation in queue.
+func (mq *MemoryTxnQueue) Commit() error {
+	if mq.err != nil {
+		return mq.err
+	}
+
+	return mq.flushBatch()
+}
@@ -0,0 +1,117 @@
+package kvdrivers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/ankur-anand/unisondb/dbkernel/vfs"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Names of the drivers registered by this package.
+const (
+	DriverBolt   = "BOLT"
+	DriverLMDB   = "LMDB"
+	DriverMemory = "MEMORY"
+)
+
+var (
+	ErrDriverNotRegistered = errors.New("driver not registered")
+)
+
+// Store is the B-tree based storage a driver provides for the materialized view of the WAL.
+type Store interface {
+	Set(key []byte, value []byte) error
+	SetMany(keys [][]byte, values [][]byte) error
+	SetChunks(key []byte, chunks [][]byte, checksum uint32) error
+	Delete(key []byte) error
+	DeleteMany(keys [][]byte) error
+	SetManyRowColumns(rowKeys [][]byte, columnEntriesPerRow []map[string][]byte) error
+	DeleteManyRowColumns(rowKeys [][]byte, columnEntriesPerRow []map[string][]byte) error
+	DeleteEntireRows(rowKeys [][]byte) (int, error)
+	StoreMetadata(key []byte, value []byte) error
+	FSync() error
+
+	Get(key []byte) ([]byte, error)
+	GetValueReader(key []byte) (*ChunkReader, error)
+	GetRange(key []byte, offset, length int64) ([]byte, error)
+	GetRowColumns(rowKey []byte, filter func([]byte) bool) (map[string][]byte, error)
+	Snapshot(w io.Writer) error
+	RetrieveMetadata(key []byte) ([]byte, error)
+
+	Close() error
+}
+
+// DriverConfig holds the driver specific configuration, as present in the TOML section of the engine.
+type DriverConfig map[string]any
+
+// Decode decodes the driver config into v, which should be a pointer to a struct with toml tags.
+func (d DriverConfig) Decode(v any) error {
+	data, err := toml.Marshal(map[string]any(d))
+	if err != nil {
+		return fmt.Errorf("kvdrivers: marshal driver config: %w", err)
+	}
+	if err := toml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("kvdrivers: decode driver config: %w", err)
+	}
+	return nil
+}
+
+// Options are provided to the Factory while opening a Store.
+type Options struct {
+	// Path of the database file inside the namespace directory.
+	Path         string
+	Config       Config
+	DriverConfig DriverConfig
+	// FS is the filesystem the store should keep its files on, vfs.Default if nil.
+	// Drivers that can only work on the os filesystem return vfs.ErrUnsupportedFS for any other.
+	FS vfs.FS
+}
+
+// Factory opens a Store with the provided Options.
+type Factory func(opts Options) (Store, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Factory)
+)
+
+// Register makes a Store driver available by the provided name.
+// If Register is called twice with the same name or if factory is nil, it panics.
+func Register(name string, factory Factory) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if factory == nil {
+		panic("kvdrivers: Register factory is nil")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("kvdrivers: Register called twice for driver " + name)
+	}
+	drivers[name] = factory
+}
+
+// Drivers returns a sorted list of the names of the registered drivers.
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Open opens a Store using the driver registered with the provided name.
+func Open(name string, opts Options) (Store, error) {
+	driversMu.RLock()
+	factory, ok := drivers[name]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("kvdrivers: %w: %q", ErrDriverNotRegistered, name)
+	}
+	return factory(opts)
+}
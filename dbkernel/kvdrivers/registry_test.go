@@ -0,0 +1,121 @@
+package kvdrivers_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	kv2 "github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
+	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers/kvdriverstest"
+	"github.com/ankur-anand/unisondb/dbkernel/vfs"
+	"github.com/ankur-anand/unisondb/dbkernel/vfs/faultfs"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type customDriverConfig struct {
+	Label    string `toml:"label"`
+	MaxItems int    `toml:"max_items"`
+}
+
+// customDecoded holds the driver config decoded by the last open of custom_memory driver.
+var customDecoded customDriverConfig
+
+func init() {
+	kv2.Register("custom_memory", func(opts kv2.Options) (kv2.Store, error) {
+		customDecoded = customDriverConfig{}
+		if err := opts.DriverConfig.Decode(&customDecoded); err != nil {
+			return nil, err
+		}
+		return kv2.NewMemory(opts.Config)
+	})
+}
+
+func TestRegistry_Drivers(t *testing.T) {
+	drivers := kv2.Drivers()
+	assert.Contains(t, drivers, kv2.DriverBolt)
+	assert.Contains(t, drivers, kv2.DriverLMDB)
+	assert.Contains(t, drivers, kv2.DriverMemory)
+
+	for _, name := range []string{kv2.DriverBolt, kv2.DriverLMDB, kv2.DriverMemory} {
+		t.Run(name, func(t *testing.T) {
+			store, err := kv2.Open(name, kv2.Options{
+				Path:   filepath.Join(t.TempDir(), "registry.db"),
+				Config: kv2.Config{Namespace: "test", NoSync: true, MmapSize: 1 << 30},
+			})
+			assert.NoError(t, err)
+			assert.NoError(t, store.Set([]byte("key"), []byte("value")))
+			value, err := store.Get([]byte("key"))
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("value"), value)
+			assert.NoError(t, store.Close())
+		})
+	}
+}
+
+func TestRegistry_Open_NotRegistered(t *testing.T) {
+	store, err := kv2.Open("not_registered", kv2.Options{})
+	assert.ErrorIs(t, err, kv2.ErrDriverNotRegistered)
+	assert.Nil(t, store)
+}
+
+func TestRegistry_Register_Panics(t *testing.T) {
+	assert.Panics(t, func() {
+		kv2.Register("nil_factory", nil)
+	})
+
+	assert.Panics(t, func() {
+		kv2.Register(kv2.DriverBolt, func(opts kv2.Options) (kv2.Store, error) {
+			return nil, nil
+		})
+	})
+}
+
+func TestRegistry_CustomDriver(t *testing.T) {
+	var section struct {
+		DriverConfig kv2.DriverConfig `toml:"driver_config"`
+	}
+	err := toml.Unmarshal([]byte("[driver_config]\nlabel = \"custom\"\nmax_items = 42\n"), &section)
+	assert.NoError(t, err)
+
+	store, err := kv2.Open("custom_memory", kv2.Options{
+		Config:       kv2.Config{Namespace: "test"},
+		DriverConfig: section.DriverConfig,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, customDriverConfig{Label: "custom", MaxItems: 42}, customDecoded)
+	assert.NoError(t, store.Close())
+
+	// drivers registered outside the package are verified with the same suite.
+	kvdriverstest.Run(t, kvdriverstest.Harness{
+		Open: func(path string, config kv2.Config) (kvdriverstest.Store, error) {
+			store, err := kv2.Open("custom_memory", kv2.Options{Path: path, Config: config})
+			if err != nil {
+				return nil, err
+			}
+			return store.(kvdriverstest.Store), nil
+		},
+		NewTxnQueue: func(store kvdriverstest.Store, maxBatchSize int) kvdriverstest.TxnQueue {
+			return store.(*kv2.MemoryEmbed).NewTxnQueue(maxBatchSize)
+		},
+	})
+}
+
+func TestDriverConfig_Decode_Invalid(t *testing.T) {
+	var decoded customDriverConfig
+	err := kv2.DriverConfig{"max_items": "not a number"}.Decode(&decoded)
+	assert.Error(t, err)
+}
+
+func TestRegistry_Open_UnsupportedFS(t *testing.T) {
+	for _, name := range []string{kv2.DriverBolt, kv2.DriverLMDB} {
+		t.Run(name, func(t *testing.T) {
+			store, err := kv2.Open(name, kv2.Options{
+				Path:   filepath.Join(t.TempDir(), "registry.db"),
+				Config: kv2.Config{Namespace: "test", NoSync: true, MmapSize: 1 << 30},
+				FS:     faultfs.New(),
+			})
+			assert.ErrorIs(t, err, vfs.ErrUnsupportedFS)
+			assert.Nil(t, store)
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package dbkernel
+
+import (
+	"cmp"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+)
+
+// lsnIndexInterval is the number of records between the entries of a segment in the lsn index.
+const lsnIndexInterval = 1024
+
+// lsnIndex is the sparse index from the log sequence number of the records to their offset in the WAL.
+// Every segment keeps the lsn of its first record and an entry for every lsnIndexInterval record,
+// a lookup finds the segment by its first lsn and reads the segment from the closest entry before the lsn.
+//
+// Entries of the appends are added as they are written, the ones of the records written before the
+// engine was opened are added as the lookups read them.
+type lsnIndex struct {
+	mu       sync.Mutex
+	walIO    *wal.WalIO
+	segments map[uint32]*segmentLSNs
+}
+
+type segmentLSNs struct {
+	// first is the lsn of the first record of the segment, zero if not known yet.
+	first uint64
+	// entries are sorted by the lsn.
+	entries []lsnEntry
+}
+
+type lsnEntry struct {
+	lsn    uint64
+	offset *wal.Offset
+}
+
+func newLSNIndex(walIO *wal.WalIO) *lsnIndex {
+	return &lsnIndex{
+		walIO:    walIO,
+		segments: make(map[uint32]*segmentLSNs),
+	}
+}
+
+// add indexes the record appended at the offset.
+func (li *lsnIndex) add(lsn uint64, offset *wal.Offset) {
+	first := offset.BlockNumber == 0 && offset.ChunkOffset == 0
+	if !first && lsn%lsnIndexInterval != 0 {
+		return
+	}
+
+	li.mu.Lock()
+	defer li.mu.Unlock()
+	segment := li.segment(offset.SegmentId)
+	if first {
+		segment.first = lsn
+	}
+	segment.insert(lsn, offset)
+}
+
+// lookup returns the offset of the record with the lsn.
+func (li *lsnIndex) lookup(lsn uint64) (*wal.Offset, error) {
+	ids := li.walIO.SegmentIDs()
+
+	// the record is in the last segment that starts at or before it.
+	var searchErr error
+	i := sort.Search(len(ids), func(i int) bool {
+		first, err := li.firstLSN(ids[i])
+		if err != nil {
+			searchErr = err
+		}
+		return first > lsn
+	})
+	if searchErr != nil {
+		return nil, searchErr
+	}
+	if i == 0 {
+		return nil, ErrLSNNotRetained
+	}
+
+	id := ids[i-1]
+	start := &wal.Offset{SegmentId: id}
+	li.mu.Lock()
+	entries := li.segment(id).entries
+	if j := sort.Search(len(entries), func(j int) bool { return entries[j].lsn > lsn }); j > 0 {
+		start = entries[j-1].offset
+	}
+	li.mu.Unlock()
+
+	reader, err := li.walIO.NewReaderWithStart(start)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []lsnEntry
+	defer func() {
+		li.mu.Lock()
+		defer li.mu.Unlock()
+		segment := li.segment(id)
+		for _, entry := range found {
+			segment.insert(entry.lsn, entry.offset)
+		}
+	}()
+
+	for {
+		value, pos, err := reader.Next()
+		if errors.Is(err, io.EOF) || (err == nil && pos.SegmentId != id) {
+			return nil, ErrInvalidLSN
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		recordLSN, err := logcodec.DecodeLSN(value)
+		if err != nil {
+			return nil, err
+		}
+		if recordLSN%lsnIndexInterval == 0 {
+			found = append(found, lsnEntry{lsn: recordLSN, offset: pos})
+		}
+		switch {
+		case recordLSN == lsn:
+			return pos, nil
+		case recordLSN > lsn:
+			return nil, ErrInvalidLSN
+		}
+	}
+}
+
+// firstLSN returns the lsn of the first record of the segment, math.MaxUint64 if the segment is empty.
+func (li *lsnIndex) firstLSN(id uint32) (uint64, error) {
+	li.mu.Lock()
+	first := li.segment(id).first
+	li.mu.Unlock()
+	if first != 0 {
+		return first, nil
+	}
+
+	reader, err := li.walIO.NewReaderWithStart(&wal.Offset{SegmentId: id})
+	if err != nil {
+		return 0, err
+	}
+	value, pos, err := reader.Next()
+	if errors.Is(err, io.EOF) || (err == nil && pos.SegmentId != id) {
+		return math.MaxUint64, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	first, err = logcodec.DecodeLSN(value)
+	if err != nil {
+		return 0, err
+	}
+	li.mu.Lock()
+	defer li.mu.Unlock()
+	segment := li.segment(id)
+	segment.first = first
+	segment.insert(first, pos)
+	return first, nil
+}
+
+// truncate removes the entries of the records from the lsn, once they are truncated from the WAL.
+func (li *lsnIndex) truncate(lsn uint64) {
+	li.mu.Lock()
+	defer li.mu.Unlock()
+	for id, segment := range li.segments {
+		if segment.first >= lsn {
+			delete(li.segments, id)
+			continue
+		}
+		i := sort.Search(len(segment.entries), func(i int) bool {
+			return segment.entries[i].lsn >= lsn
+		})
+		segment.entries = segment.entries[:i]
+	}
+}
+
+// segment returns the entries of the segment, it's called with the li.mu held.
+func (li *lsnIndex) segment(id uint32) *segmentLSNs {
+	segment, ok := li.segments[id]
+	if !ok {
+		segment = &segmentLSNs{}
+		li.segments[id] = segment
+	}
+	return segment
+}
+
+func (s *segmentLSNs) insert(lsn uint64, offset *wal.Offset) {
+	i, found := slices.BinarySearchFunc(s.entries, lsn, func(entry lsnEntry, lsn uint64) int {
+		return cmp.Compare(entry.lsn, lsn)
+	})
+	if found {
+		return
+	}
+	s.entries = slices.Insert(s.entries, i, lsnEntry{lsn: lsn, offset: offset})
+}
+
+// nextLSN returns the lsn of the next record appended to the WAL, it's called with the e.mu held.
+// The lsn is only taken once the append succeeds, so a failed append doesn't leave a gap.
+func (e *Engine) nextLSN() uint64 {
+	return e.lastLSN.Load() + 1
+}
+
+// appendedLSN records the lsn of the record appended at the offset, it's called with the e.mu held.
+// The offset is the current offset even for the records that are not written to the mem table,
+// like the txn begin, so the readers can start from any record of the wal.
+func (e *Engine) appendedLSN(lsn uint64, offset *wal.Offset) {
+	e.currentOffset.Store(offset)
+	e.lastLSN.Store(lsn)
+	e.lsnIndex.add(lsn, offset)
+}
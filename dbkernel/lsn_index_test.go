@@ -0,0 +1,130 @@
+package dbkernel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// walLSNs returns the lsn of every record in the wal of the engine.
+func walLSNs(t *testing.T, e *Engine) []uint64 {
+	t.Helper()
+	reader, err := e.NewReader()
+	require.NoError(t, err)
+	var lsns []uint64
+	for {
+		value, _, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return lsns
+		}
+		require.NoError(t, err)
+		lsn, err := logcodec.DecodeLSN(value)
+		require.NoError(t, err)
+		lsns = append(lsns, lsn)
+	}
+}
+
+func assertReaderFromLSN(t *testing.T, e *Engine, lsn uint64) {
+	t.Helper()
+	reader, err := e.NewReaderFromLSN(lsn)
+	require.NoError(t, err)
+	value, _, err := reader.Next()
+	require.NoError(t, err)
+	record, err := logcodec.DecodeRecord(value)
+	require.NoError(t, err)
+	assert.Equal(t, lsn, record.LSN)
+}
+
+func TestEngine_LSN(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	config.WalConfig.SegmentSize = 64 * 1024
+	baseDir := t.TempDir()
+	namespace := "test_lsn"
+	engine, err := NewStorageEngine(baseDir, namespace, config)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(0), engine.LastLSN())
+	_, err = engine.NewReaderFromLSN(1)
+	assert.ErrorIs(t, err, ErrInvalidLSN)
+
+	// spans many segments and more than one index interval.
+	for i := range 2 * lsnIndexInterval {
+		require.NoError(t, engine.Put([]byte(fmt.Sprintf("key_%d", i)), []byte(gofakeit.LetterN(64))))
+	}
+	txn, err := engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+	require.NoError(t, err)
+	require.NoError(t, txn.AppendKVTxn([]byte("txn_key"), []byte("txn_value")))
+	require.NoError(t, txn.Commit())
+	require.NoError(t, engine.SetColumnsInRow("row", map[string][]byte{"column": []byte("value")}))
+
+	total := uint64(2*lsnIndexInterval + 4)
+	assert.Equal(t, total, engine.LastLSN())
+	lsns := walLSNs(t, engine)
+	require.Len(t, lsns, int(total))
+	for i, lsn := range lsns {
+		require.Equal(t, uint64(i+1), lsn, "lsn should be gap free")
+	}
+	assert.Greater(t, len(engine.walIO.SegmentIDs()), 2)
+
+	for _, lsn := range []uint64{1, 2, lsnIndexInterval - 1, lsnIndexInterval, lsnIndexInterval + 1, 1500, total - 1, total} {
+		assertReaderFromLSN(t, engine, lsn)
+	}
+	_, err = engine.NewReaderFromLSN(0)
+	assert.ErrorIs(t, err, ErrInvalidLSN)
+	_, err = engine.NewReaderFromLSN(total + 1)
+	assert.ErrorIs(t, err, ErrInvalidLSN)
+	require.NoError(t, engine.Close(context.Background()))
+
+	engine, err = NewStorageEngine(baseDir, namespace, config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+
+	assert.Equal(t, total, engine.LastLSN(), "lsn should continue from the wal after restart")
+	require.NoError(t, engine.Put([]byte("key"), []byte("value")))
+	assert.Equal(t, total+1, engine.LastLSN())
+	for _, lsn := range []uint64{1, 777, lsnIndexInterval, 2000, total + 1} {
+		assertReaderFromLSN(t, engine, lsn)
+	}
+}
+
+func TestEngine_LSN_NotRetained(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	config.WalConfig.SegmentSize = 64 * 1024
+	baseDir := t.TempDir()
+	namespace := "test_lsn_retained"
+	engine, err := NewStorageEngine(baseDir, namespace, config)
+	require.NoError(t, err)
+
+	for i := range 64 {
+		require.NoError(t, engine.Put([]byte(fmt.Sprintf("filler_%d", i)), []byte(gofakeit.LetterN(4096))))
+	}
+	require.NoError(t, engine.Close(context.Background()))
+
+	require.NoError(t, os.Remove(filepath.Join(baseDir, namespace, walDirName, "000000001.seg.wal")))
+
+	engine, err = NewStorageEngine(baseDir, namespace, config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+
+	lsns := walLSNs(t, engine)
+	require.NotEmpty(t, lsns)
+	assert.Greater(t, lsns[0], uint64(1))
+	_, err = engine.NewReaderFromLSN(1)
+	assert.ErrorIs(t, err, ErrLSNNotRetained)
+	assertReaderFromLSN(t, engine, lsns[0])
+	assertReaderFromLSN(t, engine, engine.LastLSN())
+}
@@ -6,11 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"slices"
 	"time"
 
 	"github.com/ankur-anand/unisondb/dbkernel/wal"
 	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
 	"github.com/dgraph-io/badger/v4/skl"
 	"github.com/dgraph-io/badger/v4/y"
 )
@@ -33,6 +36,8 @@ type memTable struct {
 	skipList    *skl.Skiplist
 	lastOffset  *wal.Offset
 	firstOffset *wal.Offset
+	// upstreamOffset is the offset the replica resumes the stream from, once the mem table is flushed.
+	upstreamOffset []byte
 
 	capacity    int64
 	opCount     int
@@ -61,6 +66,20 @@ func (table *memTable) canPut(key []byte, val y.ValueStruct) bool {
 		int64(val.EncodedSize())+arenaSafetyMargin <= table.capacity
 }
 
+// canPutAll reports if all the entries fit together in the mem-table.
+func (table *memTable) canPutAll(entries []txMemTableEntry) bool {
+	return table.skipList.MemSize()+arenaSize(entries)+arenaSafetyMargin <= table.capacity
+}
+
+// arenaSize returns the size the entries take in the skip list arena, including their nodes.
+func arenaSize(entries []txMemTableEntry) int64 {
+	var size int64
+	for _, entry := range entries {
+		size += int64(skl.MaxNodeSize) + int64(len(entry.key)+8) + int64(entry.value.EncodedSize())
+	}
+	return size
+}
+
 // put the Key and its Value at the given offset in the mem-table.
 // for rowKey Put
 // It uses MVCC as there can be multiple update ops for different columns for the same Row.
@@ -94,9 +113,9 @@ func (table *memTable) get(key []byte) y.ValueStruct {
 func (table *memTable) getRowYValue(rowKey []byte) []y.ValueStruct {
 	var result []y.ValueStruct
 	it := table.skipList.NewIterator()
-	for it.Seek(rowKey); it.Valid(); it.Next() {
-		key := it.Key()
-		if !bytes.HasPrefix(key, rowKey) {
+	// the entries of the row sort from the newest, seek to the one with the highest timestamp.
+	for it.Seek(y.KeyWithTs(rowKey, math.MaxUint64)); it.Valid(); it.Next() {
+		if !bytes.Equal(y.ParseKey(it.Key()), rowKey) {
 			break
 		}
 
@@ -163,7 +182,7 @@ func (table *memTable) processEntry(key []byte, entry y.ValueStruct, flushMan *f
 		return err
 	}
 
-	if record.TxnStatus() == walrecord.TxnStatusCommit && record.EntryType() == walrecord.EntryTypeChunked {
+	if record.TxnState == logrecord.TransactionStateCommit && record.EntryType == logrecord.LogEntryTypeChunked {
 		n, err := table.flushChunkedTxnCommit(record)
 		if err != nil {
 			return err
@@ -173,22 +192,22 @@ func (table *memTable) processEntry(key []byte, entry y.ValueStruct, flushMan *f
 	}
 
 	// column operations
-	if record.EntryType() == walrecord.EntryTypeRow {
-		switch record.Operation() {
-		case walrecord.LogOperationInsert:
-			flushMan.columnWriteBuffer.add(record.KeyBytes(), getColumnsValue(record))
-		case walrecord.LogOperationDelete:
-			flushMan.columnDeleteBuffer.add(record.KeyBytes(), getColumnsValue(record))
+	if record.EntryType == logrecord.LogEntryTypeRow {
+		switch record.OperationType {
+		case logrecord.LogOperationTypeInsert:
+			flushMan.columnWriteBuffer.add(record.Key(), getColumnsValue(record))
+		case logrecord.LogOperationTypeDelete:
+			flushMan.columnDeleteBuffer.add(record.Key(), getColumnsValue(record))
 		}
 		return nil
 	}
 
-	flushMan.kvWriteBuffer.add(record.KeyBytes(), record.ValueBytes())
+	flushMan.kvWriteBuffer.add(record.Key(), record.Value())
 	return nil
 }
 
 // flushChunkedTxnCommit returns the number of batch record that was inserted.
-func (table *memTable) flushChunkedTxnCommit(record *walrecord.WalRecord) (int, error) {
+func (table *memTable) flushChunkedTxnCommit(record *logcodec.LogRecord) (int, error) {
 	return handleChunkedValuesTxn(record, table.wIO, table.db)
 }
 
@@ -354,13 +373,11 @@ func (b *columnDeleteBuffer) reset() {
 	b.vals = b.vals[:0]
 }
 
-func getColumnsValue(record *walrecord.WalRecord) map[string][]byte {
-	columnLen := record.ColumnsLength()
-	columnEntries := make(map[string][]byte, columnLen)
-	for i := 0; i < columnLen; i++ {
-		var columnEntry walrecord.ColumnEntry
-		record.Columns(&columnEntry, i)
-		columnEntries[string(columnEntry.ColumnName())] = columnEntry.ColumnValueBytes()
+func getColumnsValue(record *logcodec.LogRecord) map[string][]byte {
+	columns := record.Columns()
+	columnEntries := make(map[string][]byte, len(columns))
+	for _, column := range columns {
+		columnEntries[column.Name] = column.Value
 	}
 	return columnEntries
 }
@@ -479,7 +479,7 @@ func TestRow_KeysPut(t *testing.T) {
 
 			record := &walrecord.Record{
 				Index:         uint64(j),
-				Hlc:           HLCNow(uint64(j)),
+				Hlc:           NewHLC().Now(),
 				Key:           []byte(rowKey),
 				Value:         nil,
 				LogOperation:  walrecord.LogOperationInsert,
@@ -519,7 +519,7 @@ func TestRow_KeysPut(t *testing.T) {
 
 	record := &walrecord.Record{
 		Index:         uint64(50),
-		Hlc:           HLCNow(uint64(50)),
+		Hlc:           NewHLC().Now(),
 		Key:           []byte(randomRow),
 		Value:         nil,
 		LogOperation:  walrecord.LogOperationDelete,
@@ -545,3 +545,38 @@ func TestRow_KeysPut(t *testing.T) {
 	assert.Equal(t, len(buildColumns), len(columnMap)-len(deleteEntries), "unexpected number of column values")
 	assert.NotContains(t, buildColumns, deleteEntries, "unexpected column values")
 }
+
+func TestRow_KeysPut_PrefixCollision(t *testing.T) {
+	mmTable := setupMemTableWithBoltDB(t, 1<<20)
+
+	// row2 and row_x sort right after row, they must not be read as part of it.
+	rows := []string{"ro", "row", "row2", "row_x"}
+	for i, rowKey := range rows {
+		record := &walrecord.Record{
+			Index:         uint64(i),
+			Hlc:           NewHLC().Now(),
+			Key:           []byte(rowKey),
+			LogOperation:  walrecord.LogOperationInsert,
+			TxnStatus:     walrecord.TxnStatusPrepare,
+			EntryType:     walrecord.EntryTypeRow,
+			ColumnEntries: map[string][]byte{rowKey + "_column": []byte(rowKey)},
+		}
+
+		encoded, err := record.FBEncode()
+		assert.NoError(t, err)
+
+		v := getValueStruct(logOperationInsert, true, encoded)
+		v.UserMeta = entryTypeRow
+		err = mmTable.put([]byte(rowKey), v, nil)
+		assert.NoError(t, err)
+	}
+
+	for _, rowKey := range rows {
+		rowEntries := mmTable.getRowYValue([]byte(rowKey))
+		assert.Len(t, rowEntries, 1, "only the entries of the row should be returned")
+		buildColumns := make(map[string][]byte)
+		err := buildColumnMap(buildColumns, rowEntries, mmTable.wIO)
+		assert.NoError(t, err, "failed to build column map")
+		assert.Equal(t, map[string][]byte{rowKey + "_column": []byte(rowKey)}, buildColumns)
+	}
+}
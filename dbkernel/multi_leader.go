@@ -0,0 +1,567 @@
+package dbkernel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
+	"github.com/ankur-anand/unisondb/dbkernel/wal"
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
+	"github.com/hashicorp/go-metrics"
+)
+
+var (
+	// ErrNotMultiLeader is returned by the peer methods of an engine not opened in the multi leader mode.
+	ErrNotMultiLeader = errors.New("namespace is not in multi leader mode")
+	// ErrMultiLeaderTxn is returned by NewTxn of a multi leader namespace, the writes of a txn can't be
+	// resolved against the concurrent writes of the peers.
+	ErrMultiLeaderTxn = errors.New("txns are not supported by the multi leader namespaces")
+)
+
+var (
+	mKeyPeerApplyTotal    = append(packageKey, "peer", "apply", "total")
+	mKeyPeerSkipTotal     = append(packageKey, "peer", "apply", "skip", "total")
+	mKeyPeerConflictTotal = append(packageKey, "peer", "apply", "conflict", "total")
+	mKeyPeerApplyDuration = append(packageKey, "peer", "apply", "durations", "seconds")
+)
+
+const (
+	lwwFileName = "lww.db"
+	// lwwKeyColumn is the column of the version of the key, and of the last delete of the entire row.
+	lwwKeyColumn = "\x00"
+	// lwwColumnPrefix is followed by the name of the column of the row, for the version of the column.
+	lwwColumnPrefix = "\x01"
+)
+
+var (
+	sysKeyLWWCursor = []byte("sys.kv.alchemy.key.lww.cursor")
+	// sysKeyPeerOffsetPrefix is followed by the id of the peer, for the offset the stream from it resumes after.
+	sysKeyPeerOffsetPrefix = "sys.kv.alchemy.key.peer.offset."
+)
+
+// lwwVersion is the version of a change in a multi leader namespace, the hybrid logical clock timestamp
+// of the change and the node it was written on. The changes made at the same time are ordered by the node.
+type lwwVersion struct {
+	hlc    uint64
+	origin string
+}
+
+// newer reports if the version wins over the other one.
+func (v lwwVersion) newer(other lwwVersion) bool {
+	return v.hlc > other.hlc || (v.hlc == other.hlc && v.origin > other.origin)
+}
+
+// encode returns the hlc in big endian followed by the origin.
+func (v lwwVersion) encode() []byte {
+	return append(binary.BigEndian.AppendUint64(nil, v.hlc), v.origin...)
+}
+
+func decodeLWWVersion(data []byte) (lwwVersion, error) {
+	if len(data) < 8 {
+		return lwwVersion{}, fmt.Errorf("invalid lww version of %d bytes: %w", len(data), ErrRecordCorrupted)
+	}
+	return lwwVersion{hlc: binary.BigEndian.Uint64(data), origin: string(data[8:])}, nil
+}
+
+// lwwEntry is a version not persisted yet, with the offset of the record that made it.
+type lwwEntry struct {
+	version lwwVersion
+	offset  *wal.Offset
+}
+
+// lwwIndex has the last version of every key, row tombstone and column of a multi leader namespace.
+// Every key is stored as a row in its own BTreeStore, with the lwwKeyColumn and a column for every column
+// of the row.
+//
+// It's persisted when the mem tables are flushed, the versions of the records in the WAL after the
+// cursor are read from the WAL itself when the engine opens.
+type lwwIndex struct {
+	mu    sync.Mutex
+	store BTreeStore
+	// cursor is the offset of the last record whose versions are persisted, nil if none is yet.
+	cursor *wal.Offset
+	// pending are the versions not persisted yet, by the key and the version column.
+	pending map[string]map[string]lwwEntry
+	// peers are the offsets in the wal of the peers the streams from them resume after, by the peer id.
+	peers map[string][]byte
+}
+
+func openLWWIndex(store BTreeStore, walIO *wal.WalIO) (*lwwIndex, error) {
+	l := &lwwIndex{
+		store:   store,
+		pending: make(map[string]map[string]lwwEntry),
+		peers:   make(map[string][]byte),
+	}
+	data, err := store.RetrieveMetadata(sysKeyLWWCursor)
+	if err != nil && !errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		l.cursor = wal.DecodeOffset(data)
+	}
+	if err := l.recover(walIO); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// recover notes the versions of the records in the WAL after the cursor.
+func (l *lwwIndex) recover(walIO *wal.WalIO) error {
+	var reader *wal.Reader
+	var err error
+	if l.cursor == nil {
+		reader, err = walIO.NewReader()
+	} else {
+		reader, err = walIO.NewReaderWithStart(l.cursor)
+	}
+	if err != nil {
+		return err
+	}
+
+	if l.cursor != nil {
+		// first value is the last persisted record.
+		if _, _, err := reader.Next(); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
+
+	for {
+		value, pos, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("recover lww versions failed: %w", err)
+		}
+		record, err := logcodec.DecodeRecord(value)
+		if err != nil {
+			return fmt.Errorf("recover lww versions failed: %w", err)
+		}
+		if record.TxnState != logrecord.TransactionStateNone {
+			continue
+		}
+		ops, err := recordOps(record)
+		if err != nil {
+			return fmt.Errorf("recover lww versions failed: %w", err)
+		}
+		l.note(ops, lwwVersion{hlc: record.HLC, origin: record.Origin}, pos)
+	}
+}
+
+// note sets the version of everything changed by the operations, made by the record at the offset.
+func (l *lwwIndex) note(ops []batchOp, version lwwVersion, offset *wal.Offset) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, op := range ops {
+		key := string(op.key)
+		entries := l.pending[key]
+		if entries == nil {
+			entries = make(map[string]lwwEntry)
+			l.pending[key] = entries
+		}
+		for _, column := range versionColumns(op) {
+			entries[column] = lwwEntry{version: version, offset: offset}
+		}
+	}
+}
+
+// versionColumns returns the version columns of what's changed by the operation.
+func versionColumns(op batchOp) []string {
+	if op.entryType != walrecord.EntryTypeRow || op.operation == walrecord.LogOperationDeleteRow {
+		return []string{lwwKeyColumn}
+	}
+	columns := make([]string, 0, len(op.columns))
+	for name := range op.columns {
+		columns = append(columns, lwwColumnPrefix+name)
+	}
+	return columns
+}
+
+// row returns the versions of the key, by the version column.
+func (l *lwwIndex) row(key string) (map[string]lwwVersion, error) {
+	columns, err := l.store.GetRowColumns([]byte(key), nil)
+	if err != nil && !errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return nil, err
+	}
+	versions := make(map[string]lwwVersion, len(columns))
+	for column, data := range columns {
+		version, err := decodeLWWVersion(data)
+		if err != nil {
+			return nil, err
+		}
+		versions[column] = version
+	}
+	for column, entry := range l.pending[key] {
+		versions[column] = entry.version
+	}
+	return versions, nil
+}
+
+// persist stores the versions made by the records till the offset, and moves the cursor to it.
+// The versions of the records after it are persisted once the mem table they are in is flushed,
+// a version must never be persisted before the change it's of.
+func (l *lwwIndex) persist(till *wal.Offset) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rowKeys := make([][]byte, 0, len(l.pending))
+	columnEntries := make([]map[string][]byte, 0, len(l.pending))
+	for key, entries := range l.pending {
+		columns := make(map[string][]byte, len(entries))
+		for column, entry := range entries {
+			if entry.offset != nil && (till == nil || isNewChunkPosition(entry.offset, till)) {
+				continue
+			}
+			columns[column] = entry.version.encode()
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		rowKeys = append(rowKeys, []byte(key))
+		columnEntries = append(columnEntries, columns)
+	}
+
+	if len(rowKeys) > 0 {
+		if err := l.store.SetManyRowColumns(rowKeys, columnEntries); err != nil {
+			return fmt.Errorf("persist lww versions failed: %w", err)
+		}
+	}
+	moved := till != nil && isNewChunkPosition(till, l.cursor)
+	if moved {
+		if err := l.store.StoreMetadata(sysKeyLWWCursor, till.Encode()); err != nil {
+			return fmt.Errorf("persist lww versions failed: %w", err)
+		}
+	}
+	if err := l.store.FSync(); err != nil {
+		return fmt.Errorf("persist lww versions failed: %w", err)
+	}
+
+	for i, key := range rowKeys {
+		entries := l.pending[string(key)]
+		for column := range columnEntries[i] {
+			delete(entries, column)
+		}
+		if len(entries) == 0 {
+			delete(l.pending, string(key))
+		}
+	}
+	if moved {
+		l.cursor = till
+	}
+	return nil
+}
+
+// savePeers stores the offsets the streams from the peers resume after.
+func (l *lwwIndex) savePeers() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for peerID, offset := range l.peers {
+		if err := l.store.StoreMetadata([]byte(sysKeyPeerOffsetPrefix+peerID), offset); err != nil {
+			return err
+		}
+	}
+	return l.store.FSync()
+}
+
+// peerOffset returns the offset the stream from the peer resumes after, nil if nothing is received from it yet.
+func (l *lwwIndex) peerOffset(peerID string) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if offset, ok := l.peers[peerID]; ok {
+		return offset, nil
+	}
+	offset, err := l.store.RetrieveMetadata([]byte(sysKeyPeerOffsetPrefix + peerID))
+	if errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.peers[peerID] = offset
+	return offset, nil
+}
+
+func (l *lwwIndex) advancePeer(peerID string, offset []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.peers[peerID] = bytes.Clone(offset)
+}
+
+// lwwResolver resolves the operations of a peer record against the versions of the index, and the
+// versions set by the earlier operations of the record.
+type lwwResolver struct {
+	index   *lwwIndex
+	version lwwVersion
+	rows    map[string]map[string]lwwVersion
+	// written are the version columns set by the earlier operations of the record, by the key.
+	// The later operations of the record win over them, as they are applied in order.
+	written map[string]map[string]bool
+}
+
+// resolve returns the operations, or part of the columns of them, that win over the versions,
+// and the deletes of the entire row converted to the deletes of their older columns, since the row has columns
+// newer than the delete. The tombstone of the row is set for them, so the older changes of the row still lose.
+func (l *lwwIndex) resolve(ops []batchOp, version lwwVersion) (*Batch, []batchOp, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r := &lwwResolver{
+		index:   l,
+		version: version,
+		rows:    make(map[string]map[string]lwwVersion),
+		written: make(map[string]map[string]bool),
+	}
+	batch := NewBatch()
+	var tombstones []batchOp
+	for _, op := range ops {
+		key := string(op.key)
+		row, err := r.row(key)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch {
+		case op.entryType != walrecord.EntryTypeRow:
+			if r.wins(key, lwwKeyColumn) {
+				r.set(key, lwwKeyColumn)
+				batch.ops = append(batch.ops, op)
+			}
+		case op.operation == walrecord.LogOperationDeleteRow:
+			if !r.wins(key, lwwKeyColumn) {
+				continue
+			}
+			tombstone := row[lwwKeyColumn]
+			newer := false
+			stale := make(map[string][]byte)
+			for column, current := range row {
+				name, ok := strings.CutPrefix(column, lwwColumnPrefix)
+				if !ok {
+					continue
+				}
+				if current.newer(version) {
+					newer = true
+					continue
+				}
+				if current.newer(tombstone) {
+					stale[name] = nil
+				}
+			}
+			r.set(key, lwwKeyColumn)
+			if !newer {
+				batch.ops = append(batch.ops, op)
+				continue
+			}
+			tombstones = append(tombstones, op)
+			if len(stale) > 0 {
+				batch.ops = append(batch.ops, batchOp{
+					key:       op.key,
+					columns:   stale,
+					operation: walrecord.LogOperationDelete,
+					entryType: walrecord.EntryTypeRow,
+				})
+			}
+		default:
+			columns := make(map[string][]byte, len(op.columns))
+			for name, value := range op.columns {
+				if r.wins(key, lwwColumnPrefix+name, lwwKeyColumn) {
+					r.set(key, lwwColumnPrefix+name)
+					columns[name] = value
+				}
+			}
+			if len(columns) > 0 {
+				op.columns = columns
+				batch.ops = append(batch.ops, op)
+			}
+		}
+	}
+	return batch, tombstones, nil
+}
+
+func (r *lwwResolver) row(key string) (map[string]lwwVersion, error) {
+	if row, ok := r.rows[key]; ok {
+		return row, nil
+	}
+	row, err := r.index.row(key)
+	if err != nil {
+		return nil, err
+	}
+	r.rows[key] = row
+	r.written[key] = make(map[string]bool)
+	return row, nil
+}
+
+// wins reports if the version wins over every version column of the key.
+func (r *lwwResolver) wins(key string, columns ...string) bool {
+	for _, column := range columns {
+		if r.written[key][column] {
+			continue
+		}
+		if !r.version.newer(r.rows[key][column]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *lwwResolver) set(key, column string) {
+	r.rows[key][column] = r.version
+	r.written[key][column] = true
+}
+
+// recordOps returns the operations of the single or batch record.
+func recordOps(record *logcodec.LogRecord) ([]batchOp, error) {
+	if !record.IsBatch() {
+		if !isDataOperation(record) {
+			return nil, nil
+		}
+		return []batchOp{recordOp(record)}, nil
+	}
+	records, err := record.BatchRecords()
+	if err != nil {
+		return nil, err
+	}
+	ops := make([]batchOp, len(records))
+	for i, bRecord := range records {
+		ops[i] = recordOp(bRecord)
+	}
+	return ops, nil
+}
+
+func recordOp(record *logcodec.LogRecord) batchOp {
+	op := batchOp{
+		key:       record.Key(),
+		operation: walrecord.LogOperation(record.OperationType),
+		entryType: walrecord.EntryType(record.EntryType),
+	}
+	if op.entryType == walrecord.EntryTypeRow {
+		op.columns = getColumnsValue(record)
+		return op
+	}
+	op.value = record.Value()
+	return op
+}
+
+// origin returns the origin the records written on the node are tagged with, empty unless it's multi leader.
+func (e *Engine) origin() string {
+	if e.lww == nil {
+		return ""
+	}
+	return e.config.NodeID
+}
+
+// noteVersions sets the versions of the operations written by the record at the offset, it's called with the e.mu held.
+// The local writes are always newer than every version, as the clock is ahead of every timestamp applied from the peers.
+func (e *Engine) noteVersions(ops []batchOp, hlc uint64, origin string, offset *wal.Offset) {
+	if e.lww == nil {
+		return
+	}
+	e.lww.note(ops, lwwVersion{hlc: hlc, origin: origin}, offset)
+}
+
+// NodeID returns the id of the node the records written on it are tagged with, empty unless it's multi leader.
+func (e *Engine) NodeID() string {
+	return e.origin()
+}
+
+// PeerOffset returns the offset in the wal of the peer the stream from it resumes after,
+// nil if no record has been received from it yet.
+func (e *Engine) PeerOffset(peerID string) ([]byte, error) {
+	if e.lww == nil {
+		return nil, ErrNotMultiLeader
+	}
+	offset, err := e.lww.peerOffset(peerID)
+	return bytes.Clone(offset), err
+}
+
+// SkipPeer moves the offset the stream from the peer resumes after past the records that were filtered
+// out of the stream, without applying any record.
+func (e *Engine) SkipPeer(peerID string, upstreamOffset []byte) error {
+	if e.shutdown.Load() {
+		return ErrInCloseProcess
+	}
+	if e.lww == nil {
+		return ErrNotMultiLeader
+	}
+	e.lww.advancePeer(peerID, upstreamOffset)
+	return nil
+}
+
+// ApplyPeer applies a record streamed from the wal of a peer of the multi leader namespace.
+// The record is verified against its checksum and every operation of it is resolved by the last writer wins:
+// the change of a key, or of a column of a row, is applied only if its version is newer than the last one
+// applied. A delete of the entire row deletes only its columns older than the delete.
+//
+// The operations that win are written as a batch record with the hlc and origin of the peer record,
+// so the record is resolved the same on every node it's streamed to. The records written on the node itself,
+// received back from a peer, are skipped, as are the records received again once the stream resumes.
+//
+// The changes made before the namespace was multi leader have no version, and lose to every change of a peer.
+// A record whose hlc is ahead of the wall clock by more than the MaxClockDrift is rejected with ErrClockDrift,
+// as it would win against every later write, the peer offset is not advanced past it.
+func (e *Engine) ApplyPeer(peerID string, upstreamOffset, data []byte, checksum uint32) error {
+	if e.shutdown.Load() {
+		return ErrInCloseProcess
+	}
+	if e.lww == nil {
+		return ErrNotMultiLeader
+	}
+	if crc32.ChecksumIEEE(data) != checksum {
+		return fmt.Errorf("%w: checksum mismatch of the peer record", ErrRecordCorrupted)
+	}
+	record, err := logcodec.DecodeRecord(data)
+	if err != nil {
+		return err
+	}
+
+	metrics.IncrCounterWithLabels(mKeyPeerApplyTotal, 1, e.metricsLabel)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mKeyPeerApplyDuration, startTime, e.metricsLabel)
+	}()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.hlc.Update(record.HLC); err != nil {
+		metrics.IncrCounterWithLabels(mKeyHLCDriftTotal, 1, e.metricsLabel)
+		return fmt.Errorf("peer %s record at lsn %d: %w", peerID, record.LSN, err)
+	}
+	// records of the txns are only written before the namespace was multi leader.
+	if record.Origin == e.config.NodeID || record.TxnState != logrecord.TransactionStateNone {
+		metrics.IncrCounterWithLabels(mKeyPeerSkipTotal, 1, e.metricsLabel)
+		e.lww.advancePeer(peerID, upstreamOffset)
+		return nil
+	}
+
+	ops, err := recordOps(record)
+	if err != nil {
+		return err
+	}
+	version := lwwVersion{hlc: record.HLC, origin: record.Origin}
+	batch, tombstones, err := e.lww.resolve(ops, version)
+	if err != nil {
+		return err
+	}
+	if lost := len(ops) - batch.Len(); lost > 0 {
+		metrics.IncrCounterWithLabels(mKeyPeerConflictTotal, float32(lost), e.metricsLabel)
+	}
+
+	offset := e.currentOffset.Load()
+	if batch.Len() > 0 {
+		_, offset, err = e.appendBatch(batch, record.HLC, record.Origin)
+		if err != nil {
+			return err
+		}
+	}
+	e.lww.note(tombstones, version, offset)
+	e.lww.advancePeer(peerID, upstreamOffset)
+	return nil
+}
@@ -0,0 +1,439 @@
+package dbkernel
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMultiLeaderEngine(t *testing.T, dir, nodeID string) *Engine {
+	t.Helper()
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+	config.MultiLeader = true
+	config.NodeID = nodeID
+	engine, err := NewStorageEngine(dir, "test_multi_leader", config)
+	require.NoError(t, err)
+	return engine
+}
+
+// exchange applies the records of the peer not yet received from it, and returns the number of them.
+func exchange(from, to *Engine) (int, error) {
+	offset, err := to.PeerOffset(from.NodeID())
+	if err != nil {
+		return 0, err
+	}
+	reader, err := from.NewReader()
+	if offset != nil {
+		reader, err = from.NewReaderWithStart(DecodeOffset(offset))
+	}
+	if err != nil {
+		return 0, err
+	}
+	if offset != nil {
+		if _, _, err := reader.Next(); err != nil {
+			return 0, err
+		}
+	}
+
+	for n := 0; ; n++ {
+		data, pos, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if err := to.ApplyPeer(from.NodeID(), pos.Encode(), data, crc32.ChecksumIEEE(data)); err != nil {
+			return n, err
+		}
+	}
+}
+
+// exchangeAll exchanges the records between every pair of the engines.
+func exchangeAll(t *testing.T, engines ...*Engine) {
+	t.Helper()
+	for _, from := range engines {
+		for _, to := range engines {
+			if from != to {
+				_, err := exchange(from, to)
+				require.NoError(t, err)
+			}
+		}
+	}
+}
+
+// fixedClock makes the clock of the engines return the physical time, so the writes made on them are concurrent.
+func fixedClock(physical *atomic.Uint64, engines ...*Engine) {
+	for _, engine := range engines {
+		engine.hlc = newHLC(physical.Load)
+	}
+}
+
+func rowColumns(t *testing.T, engine *Engine, rowKey string) map[string]string {
+	t.Helper()
+	columns, err := engine.GetRowColumns(rowKey, nil)
+	if errors.Is(err, ErrKeyNotFound) {
+		return map[string]string{}
+	}
+	require.NoError(t, err)
+	result := make(map[string]string, len(columns))
+	for name, value := range columns {
+		result[name] = string(value)
+	}
+	return result
+}
+
+func value(t *testing.T, engine *Engine, key string) string {
+	t.Helper()
+	v, err := engine.Get([]byte(key))
+	if errors.Is(err, ErrKeyNotFound) {
+		return "<deleted>"
+	}
+	require.NoError(t, err)
+	return string(v)
+}
+
+func TestEngine_MultiLeaderErrors(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+	config.MultiLeader = true
+	_, err := NewStorageEngine(t.TempDir(), "test_multi_leader", config)
+	assert.Error(t, err, "node id is required")
+
+	config.NodeID = "a"
+	config.Replica = true
+	_, err = NewStorageEngine(t.TempDir(), "test_multi_leader", config)
+	assert.Error(t, err, "multi leader namespace can't be a replica")
+
+	engine := newMultiLeaderEngine(t, t.TempDir(), "a")
+	t.Cleanup(func() {
+		closeEngine(t, engine)
+	})
+	_, err = engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+	assert.ErrorIs(t, err, ErrMultiLeaderTxn)
+
+	data := []byte("not a record")
+	err = engine.ApplyPeer("b", nil, data, crc32.ChecksumIEEE(data)+1)
+	assert.ErrorIs(t, err, ErrRecordCorrupted)
+
+	config = NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+	primary, err := NewStorageEngine(t.TempDir(), "test_multi_leader", config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		closeEngine(t, primary)
+	})
+	assert.ErrorIs(t, primary.ApplyPeer("b", nil, data, crc32.ChecksumIEEE(data)), ErrNotMultiLeader)
+	_, err = primary.PeerOffset("b")
+	assert.ErrorIs(t, err, ErrNotMultiLeader)
+	assert.Empty(t, primary.NodeID())
+}
+
+func TestEngine_ApplyPeerLastWriterWins(t *testing.T) {
+	a := newMultiLeaderEngine(t, t.TempDir(), "a")
+	b := newMultiLeaderEngine(t, t.TempDir(), "b")
+	t.Cleanup(func() {
+		closeEngine(t, a)
+		closeEngine(t, b)
+	})
+	var physical atomic.Uint64
+	physical.Store(1000)
+	fixedClock(&physical, a, b)
+
+	// same hlc on both, the greater origin wins.
+	require.NoError(t, a.Put([]byte("tie"), []byte("a")))
+	require.NoError(t, b.Put([]byte("tie"), []byte("b")))
+	// the later write wins, even if it's a delete.
+	require.NoError(t, b.Put([]byte("deleted"), []byte("b")))
+	physical.Add(1)
+	require.NoError(t, a.Delete([]byte("deleted")))
+	require.NoError(t, a.Put([]byte("later"), []byte("a")))
+	physical.Add(1)
+	require.NoError(t, b.Put([]byte("later"), []byte("b")))
+
+	exchangeAll(t, a, b)
+	for _, engine := range []*Engine{a, b} {
+		assert.Equal(t, "b", value(t, engine, "tie"), engine.NodeID())
+		assert.Equal(t, "<deleted>", value(t, engine, "deleted"), engine.NodeID())
+		assert.Equal(t, "b", value(t, engine, "later"), engine.NodeID())
+	}
+
+	// every record is received back from the peer, with its origin it's never applied again.
+	lastLSN := a.LastLSN()
+	exchangeAll(t, a, b)
+	assert.Equal(t, lastLSN, a.LastLSN())
+
+	// a local write is newer than every write applied from the peers, even with the clock behind.
+	physical.Store(10)
+	require.NoError(t, a.Put([]byte("later"), []byte("a again")))
+	exchangeAll(t, a, b)
+	assert.Equal(t, "a again", value(t, b, "later"))
+}
+
+func TestEngine_ApplyPeerClockDrift(t *testing.T) {
+	a := newMultiLeaderEngine(t, t.TempDir(), "a")
+	b := newMultiLeaderEngine(t, t.TempDir(), "b")
+	t.Cleanup(func() {
+		closeEngine(t, a)
+		closeEngine(t, b)
+	})
+	var physical atomic.Uint64
+	physical.Store(1_000_000)
+	a.hlc = newHLC(physical.Load)
+	// clock of b is an hour ahead.
+	b.hlc = newHLC(func() uint64 {
+		return physical.Load() + uint64(time.Hour.Milliseconds())
+	})
+
+	require.NoError(t, b.Put([]byte("key"), []byte("b")))
+	_, err := exchange(b, a)
+	assert.ErrorIs(t, err, ErrClockDrift)
+	assert.Equal(t, "<deleted>", value(t, a, "key"), "record ahead of the max drift should not be applied")
+	assert.Less(t, a.hlc.Last(), b.hlc.Last(), "clock should not move to the record")
+	offset, err := a.PeerOffset("b")
+	assert.NoError(t, err)
+	assert.Nil(t, offset, "peer offset should not move past the record")
+
+	a.hlc.SetMaxDrift(2 * time.Hour)
+	_, err = exchange(b, a)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", value(t, a, "key"))
+}
+
+func TestEngine_ApplyPeerRowColumns(t *testing.T) {
+	a := newMultiLeaderEngine(t, t.TempDir(), "a")
+	b := newMultiLeaderEngine(t, t.TempDir(), "b")
+	t.Cleanup(func() {
+		closeEngine(t, a)
+		closeEngine(t, b)
+	})
+	var physical atomic.Uint64
+	physical.Store(1000)
+	fixedClock(&physical, a, b)
+
+	require.NoError(t, a.SetColumnsInRow("user_row", map[string][]byte{"x": []byte("a"), "y": []byte("a")}))
+	physical.Add(1)
+	require.NoError(t, b.SetColumnsInRow("user_row", map[string][]byte{"y": []byte("b"), "z": []byte("b")}))
+	exchangeAll(t, a, b)
+	expected := map[string]string{"x": "a", "y": "b", "z": "b"}
+	assert.Equal(t, expected, rowColumns(t, a, "user_row"))
+	assert.Equal(t, expected, rowColumns(t, b, "user_row"))
+
+	// the row is deleted on a while b writes its columns before and after the delete,
+	// only the columns older than the delete are removed.
+	batch := NewBatch()
+	batch.SetColumnsInRow("user_row", map[string][]byte{"x": []byte("stale")})
+	batch.Put([]byte("key"), []byte("b"))
+	require.NoError(t, b.WriteBatch(batch))
+	physical.Add(1)
+	require.NoError(t, a.DeleteRow("user_row"))
+	physical.Add(1)
+	require.NoError(t, b.SetColumnsInRow("user_row", map[string][]byte{"w": []byte("b")}))
+	exchangeAll(t, a, b)
+	expected = map[string]string{"w": "b"}
+	assert.Equal(t, expected, rowColumns(t, a, "user_row"))
+	assert.Equal(t, expected, rowColumns(t, b, "user_row"))
+	assert.Equal(t, "b", value(t, a, "key"))
+
+	// the delete of a column wins over the older write of it.
+	require.NoError(t, b.SetColumnsInRow("user_row", map[string][]byte{"w": []byte("b again"), "v": []byte("b")}))
+	physical.Add(1)
+	require.NoError(t, a.DeleteColumnsFromRow("user_row", map[string][]byte{"w": nil}))
+	exchangeAll(t, a, b)
+	expected = map[string]string{"v": "b"}
+	assert.Equal(t, expected, rowColumns(t, a, "user_row"))
+	assert.Equal(t, expected, rowColumns(t, b, "user_row"))
+}
+
+func TestEngine_MultiLeaderRecovery(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	a := newMultiLeaderEngine(t, dirA, "a")
+	b := newMultiLeaderEngine(t, dirB, "b")
+	t.Cleanup(func() {
+		closeEngine(t, a)
+	})
+
+	require.NoError(t, a.Put([]byte("key"), []byte("a")))
+	require.NoError(t, a.SetColumnsInRow("user_row", map[string][]byte{"x": []byte("a")}))
+	n, err := exchange(a, b)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	offset, err := b.PeerOffset("a")
+	require.NoError(t, err)
+	require.NotNil(t, offset)
+	closeEngine(t, b)
+
+	b = newMultiLeaderEngine(t, dirB, "b")
+	t.Cleanup(func() {
+		closeEngine(t, b)
+	})
+	resumed, err := b.PeerOffset("a")
+	require.NoError(t, err)
+	assert.Equal(t, offset, resumed)
+	n, err = exchange(a, b)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// the records received again are skipped by their versions.
+	lastLSN := b.LastLSN()
+	require.NoError(t, b.SkipPeer("a", nil))
+	n, err = exchange(a, b)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, lastLSN, b.LastLSN())
+	assert.Equal(t, "a", value(t, b, "key"))
+	assert.Equal(t, map[string]string{"x": "a"}, rowColumns(t, b, "user_row"))
+
+	// versions of the records after the cursor are recovered from the wal.
+	store, err := kvdrivers.Open(kvdrivers.DriverMemory, kvdrivers.Options{Config: b.config.BtreeConfig})
+	require.NoError(t, err)
+	lww, err := openLWWIndex(store, b.walIO)
+	require.NoError(t, err)
+	versions, err := lww.row("user_row")
+	require.NoError(t, err)
+	assert.Equal(t, "a", versions[lwwColumnPrefix+"x"].origin)
+	versions, err = lww.row("key")
+	require.NoError(t, err)
+	assert.Equal(t, "a", versions[lwwKeyColumn].origin)
+}
+
+func TestEngine_MultiLeaderConvergence(t *testing.T) {
+	const (
+		writes = 300
+		keys   = 8
+		rows   = 4
+	)
+	columns := []string{"c0", "c1", "c2", "c3"}
+
+	for seed := range uint64(3) {
+		t.Run(fmt.Sprintf("seed_%d", seed), func(t *testing.T) {
+			var engines []*Engine
+			for _, nodeID := range []string{"a", "b", "c"} {
+				engine := newMultiLeaderEngine(t, t.TempDir(), nodeID)
+				t.Cleanup(func() {
+					closeEngine(t, engine)
+				})
+				engines = append(engines, engine)
+			}
+
+			randomColumns := func(rnd *rand.Rand, value string) map[string][]byte {
+				entries := make(map[string][]byte)
+				for _, name := range columns {
+					if rnd.IntN(2) == 0 {
+						entries[name] = []byte(value)
+					}
+				}
+				if len(entries) == 0 {
+					entries[columns[0]] = []byte(value)
+				}
+				return entries
+			}
+
+			write := func(engine *Engine, rnd *rand.Rand, i int) error {
+				key := []byte(fmt.Sprintf("key_%d", rnd.IntN(keys)))
+				rowKey := fmt.Sprintf("user_row_%d", rnd.IntN(rows))
+				value := fmt.Sprintf("%s_%d", engine.NodeID(), i)
+				switch rnd.IntN(7) {
+				case 0, 1:
+					return engine.Put(key, []byte(value))
+				case 2:
+					return engine.Delete(key)
+				case 3:
+					return engine.SetColumnsInRow(rowKey, randomColumns(rnd, value))
+				case 4:
+					return engine.DeleteColumnsFromRow(rowKey, randomColumns(rnd, value))
+				case 5:
+					return engine.DeleteRow(rowKey)
+				default:
+					batch := NewBatch()
+					batch.Put(key, []byte(value))
+					batch.SetColumnsInRow(rowKey, randomColumns(rnd, value))
+					batch.DeleteRow(fmt.Sprintf("user_row_%d", rnd.IntN(rows)))
+					batch.SetColumnsInRow(rowKey, randomColumns(rnd, value))
+					return engine.WriteBatch(batch)
+				}
+			}
+
+			var wg sync.WaitGroup
+			var done atomic.Bool
+			errs := make(chan error, len(engines)+1)
+			for i, engine := range engines {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					rnd := rand.New(rand.NewPCG(seed, uint64(i)))
+					for j := range writes {
+						if err := write(engine, rnd, j); err != nil {
+							errs <- err
+							return
+						}
+					}
+				}()
+			}
+			// the records are exchanged while they are written, in a random order of the peers.
+			exchanged := make(chan struct{})
+			go func() {
+				defer close(exchanged)
+				rnd := rand.New(rand.NewPCG(seed, 100))
+				for !done.Load() {
+					from, to := engines[rnd.IntN(len(engines))], engines[rnd.IntN(len(engines))]
+					if from == to {
+						continue
+					}
+					if _, err := exchange(from, to); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}()
+			wg.Wait()
+			done.Store(true)
+			<-exchanged
+			close(errs)
+			for err := range errs {
+				require.NoError(t, err)
+			}
+
+			exchangeAll(t, engines...)
+			for _, engine := range engines[1:] {
+				for i := range keys {
+					key := fmt.Sprintf("key_%d", i)
+					assert.Equal(t, value(t, engines[0], key), value(t, engine, key), key)
+				}
+				for i := range rows {
+					rowKey := fmt.Sprintf("user_row_%d", i)
+					assert.Equal(t, rowColumns(t, engines[0], rowKey), rowColumns(t, engine, rowKey), rowKey)
+				}
+			}
+		})
+	}
+}
+
+func TestLWWVersion(t *testing.T) {
+	older := lwwVersion{hlc: 10, origin: "b"}
+	newer := lwwVersion{hlc: 11, origin: "a"}
+	tie := lwwVersion{hlc: 11, origin: "b"}
+	assert.True(t, newer.newer(older))
+	assert.False(t, older.newer(newer))
+	assert.True(t, tie.newer(newer))
+	assert.False(t, newer.newer(newer))
+	assert.True(t, older.newer(lwwVersion{}))
+
+	decoded, err := decodeLWWVersion(tie.encode())
+	require.NoError(t, err)
+	assert.Equal(t, tie, decoded)
+	_, err = decodeLWWVersion([]byte{1})
+	assert.ErrorIs(t, err, ErrRecordCorrupted)
+}
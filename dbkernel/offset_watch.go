@@ -0,0 +1,113 @@
+package dbkernel
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal"
+)
+
+// closedCh is returned to the waiters whose offset has already been passed.
+var closedCh = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// offsetWatch notifies the waiters once the commit offset moves past the offset they have seen.
+// Waiters that have seen the same offset share a channel, which is closed by the write that moves
+// the offset past it, so no goroutine is needed to wait and a waiter that gives up is removed right away.
+type offsetWatch struct {
+	// current is the commit offset, nil if nothing is written yet.
+	current *atomic.Pointer[wal.Offset]
+	// count of the waiters, lets the writes skip the lock if no one is waiting.
+	count atomic.Int64
+	mu    sync.Mutex
+	// waiters are keyed by the offset they have seen, zero offset for the ones that have seen nothing.
+	waiters map[wal.Offset]*offsetWaiters
+}
+
+type offsetWaiters struct {
+	ch   chan struct{}
+	refs int
+}
+
+func newOffsetWatch(current *atomic.Pointer[wal.Offset]) *offsetWatch {
+	return &offsetWatch{
+		current: current,
+		waiters: make(map[wal.Offset]*offsetWaiters),
+	}
+}
+
+// watch returns a channel that is closed once the commit offset moves past the seen offset,
+// a nil seen offset is passed by any write. The returned cancel func should be called once the
+// caller stops waiting, to release the waiter.
+func (w *offsetWatch) watch(seen *wal.Offset) (<-chan struct{}, func()) {
+	var key wal.Offset
+	if seen != nil {
+		key = *seen
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// registered before checking the current offset, so a concurrent advance either sees the waiter
+	// or its offset is seen here.
+	w.count.Add(1)
+	if current := w.current.Load(); current != nil && isNewChunkPosition(current, seen) {
+		w.count.Add(-1)
+		return closedCh, func() {}
+	}
+
+	waiters, ok := w.waiters[key]
+	if !ok {
+		waiters = &offsetWaiters{ch: make(chan struct{})}
+		w.waiters[key] = waiters
+	}
+	waiters.refs++
+
+	cancelled := false
+	return waiters.ch, func() {
+		// closed channel is already removed by the advance.
+		select {
+		case <-waiters.ch:
+			return
+		default:
+		}
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if cancelled || w.waiters[key] != waiters {
+			return
+		}
+		cancelled = true
+		w.count.Add(-1)
+		waiters.refs--
+		if waiters.refs == 0 {
+			delete(w.waiters, key)
+		}
+	}
+}
+
+// advance notifies the waiters that have not seen the offset, it should be called after the commit offset
+// is moved to it.
+func (w *offsetWatch) advance(offset *wal.Offset) {
+	if w.count.Load() == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for seen, waiters := range w.waiters {
+		if isNewChunkPosition(offset, &seen) {
+			close(waiters.ch)
+			delete(w.waiters, seen)
+			w.count.Add(-int64(waiters.refs))
+		}
+	}
+}
+
+// len returns the number of the waiters.
+func (w *offsetWatch) len() int {
+	return int(w.count.Load())
+}
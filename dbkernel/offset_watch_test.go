@@ -0,0 +1,158 @@
+package dbkernel
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestOffsetWatch(t *testing.T) {
+	var current atomic.Pointer[wal.Offset]
+	w := newOffsetWatch(&current)
+
+	first := &wal.Offset{SegmentId: 1, BlockNumber: 0, ChunkOffset: 10}
+	second := &wal.Offset{SegmentId: 1, BlockNumber: 0, ChunkOffset: 20}
+	third := &wal.Offset{SegmentId: 1, BlockNumber: 1, ChunkOffset: 0}
+
+	anyAppend, cancelAny := w.watch(nil)
+	defer cancelAny()
+	afterFirst, cancelFirst := w.watch(first)
+	defer cancelFirst()
+	afterSecond, cancelSecond := w.watch(second)
+	defer cancelSecond()
+	assert.Equal(t, 3, w.len())
+
+	current.Store(first)
+	w.advance(first)
+	assert.True(t, isClosed(anyAppend))
+	assert.False(t, isClosed(afterFirst), "waiter has already seen the offset")
+	assert.False(t, isClosed(afterSecond))
+
+	current.Store(third)
+	w.advance(third)
+	assert.True(t, isClosed(afterFirst))
+	assert.True(t, isClosed(afterSecond))
+	assert.Equal(t, 0, w.len())
+
+	seen, cancel := w.watch(second)
+	defer cancel()
+	assert.True(t, isClosed(seen), "offset has already moved past")
+	assert.Equal(t, 0, w.len())
+}
+
+func TestOffsetWatch_Cancel(t *testing.T) {
+	var current atomic.Pointer[wal.Offset]
+	w := newOffsetWatch(&current)
+
+	ch, cancel := w.watch(nil)
+	assert.Equal(t, 1, w.len())
+	cancel()
+	assert.Equal(t, 0, w.len(), "cancelled waiter should be removed")
+	// cancel is safe to call again.
+	cancel()
+	assert.Equal(t, 0, w.len())
+
+	offset := &wal.Offset{SegmentId: 1}
+	current.Store(offset)
+	w.advance(offset)
+	assert.False(t, isClosed(ch), "cancelled waiter should not be notified")
+}
+
+func TestEngine_WaitForAppend_NoLeak(t *testing.T) {
+	engine, err := NewStorageEngine(t.TempDir(), "test_wait_no_leak", NewDefaultEngineConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range 100 {
+		assert.ErrorIs(t, engine.WaitForAppend(context.Background(), time.Millisecond, nil), ErrWaitTimeoutExceeded)
+		assert.ErrorIs(t, engine.WaitForAppend(ctx, time.Second, nil), context.Canceled)
+	}
+
+	assert.Equal(t, 0, engine.appendWatch.len(), "timed out and cancelled waiters should be removed")
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before, "waiters should not leave goroutines behind")
+}
+
+func BenchmarkOffsetWatch_AdvanceNoWaiters(b *testing.B) {
+	var current atomic.Pointer[wal.Offset]
+	w := newOffsetWatch(&current)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		offset := &wal.Offset{SegmentId: 1, ChunkOffset: int64(n)}
+		current.Store(offset)
+		w.advance(offset)
+	}
+}
+
+func BenchmarkEngine_WaitForAppend_Cancelled(b *testing.B) {
+	engine, err := NewStorageEngine(b.TempDir(), "bench_wait_cancelled", NewDefaultEngineConfig())
+	require.NoError(b, err)
+	b.Cleanup(func() {
+		_ = engine.Close(context.Background())
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	lastSeen := engine.CurrentOffset()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := engine.WaitForAppend(ctx, time.Second, lastSeen); err == nil {
+			b.Fatal("cancelled wait should fail")
+		}
+	}
+}
+
+func BenchmarkEngine_WatchOffset_Wake(b *testing.B) {
+	for _, waiters := range []int{100, 1000, 5000} {
+		b.Run(fmt.Sprintf("waiters_%d", waiters), func(b *testing.B) {
+			engine, err := NewStorageEngine(b.TempDir(), "bench_wait_for_append", NewDefaultEngineConfig())
+			require.NoError(b, err)
+			b.Cleanup(func() {
+				_ = engine.Close(context.Background())
+			})
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				b.StopTimer()
+				lastSeen := engine.CurrentOffset()
+				var ready, done sync.WaitGroup
+				ready.Add(waiters)
+				done.Add(waiters)
+				for range waiters {
+					go func() {
+						defer done.Done()
+						appended, cancel := engine.WatchOffset(lastSeen)
+						defer cancel()
+						ready.Done()
+						<-appended
+					}()
+				}
+				ready.Wait()
+				b.StartTimer()
+
+				// a single append wakes up every waiter.
+				require.NoError(b, engine.Put([]byte("key"), []byte("value")))
+				done.Wait()
+			}
+		})
+	}
+}
@@ -0,0 +1,447 @@
+package dbkernel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
+	"github.com/ankur-anand/unisondb/dbkernel/wal"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
+	"github.com/dgraph-io/badger/v4/y"
+	"github.com/hashicorp/go-metrics"
+)
+
+var (
+	// ErrReadOnlyReplica is returned for the writes to a replica engine, it's only written by the upstream records.
+	ErrReadOnlyReplica = errors.New("engine is a read only replica")
+	ErrNotReplica      = errors.New("engine is not a replica")
+	// ErrUnknownReplicatedTxn is returned when a record of a txn is received without the begin record of the txn.
+	ErrUnknownReplicatedTxn = errors.New("replicated txn record without its begin record")
+	// ErrReplicatedLSNGap is returned when a record is received that doesn't follow the last record of the replica,
+	// the records before it are missing from the stream.
+	ErrReplicatedLSNGap = errors.New("replicated record doesn't follow the last record of the replica")
+)
+
+var (
+	mKeyReplicaApplyTotal    = append(packageKey, "replica", "apply", "total")
+	mKeyReplicaSkipTotal     = append(packageKey, "replica", "apply", "skip", "total")
+	mKeyReplicaApplyDuration = append(packageKey, "replica", "apply", "durations", "seconds")
+)
+
+// replicaState tracks the upstream records applied to a replica engine, it's guarded by the e.mu.
+type replicaState struct {
+	// upstreamOffset is the offset of the last applied record in the upstream wal.
+	upstreamOffset []byte
+	// txns are the upstream txns whose commit record is yet to be applied, by the txn id.
+	txns map[string]*replicaTxn
+	// rootLSN is the lsn of the last record of the root primary, as last reported by the upstream.
+	// The upstream is the root itself, unless the replica streams from another replica.
+	rootLSN uint64
+	// rootPendingHLC is the HLC of the oldest record of the root the replica doesn't have, zero if none.
+	rootPendingHLC uint64
+}
+
+// replicaTxn is an open txn of the upstream.
+type replicaTxn struct {
+	// resumeOffset is the upstream offset of the record before the begin record of the txn.
+	resumeOffset []byte
+	beginLSN     uint64
+	// lastPos is the offset of the last record of the txn in the local wal, the next record of the txn is linked to it.
+	lastPos         *wal.Offset
+	memTableEntries []txMemTableEntry
+}
+
+// loadReplicaState returns the state of the replica with the upstream offset saved in the btree store.
+// The records of the snapshot the replica is restored from are only in the btree store, so the last lsn
+// is moved to the snapshot, till the wal has a record after it.
+func (e *Engine) loadReplicaState() (*replicaState, error) {
+	offset, err := e.dataStore.RetrieveMetadata(sysKeyUpstreamOffset)
+	if err != nil && !errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return nil, err
+	}
+	if len(offset) == 0 {
+		offset = nil
+	}
+	value, err := e.dataStore.RetrieveMetadata(sysKeySnapshotLSN)
+	if err != nil && !errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return nil, err
+	}
+	if len(value) == 8 {
+		if lsn := binary.LittleEndian.Uint64(value); lsn > e.lastLSN.Load() {
+			e.lastLSN.Store(lsn)
+		}
+	}
+	return &replicaState{
+		upstreamOffset: offset,
+		txns:           make(map[string]*replicaTxn),
+	}, nil
+}
+
+// resumeOffset returns the upstream offset the stream resumes from, every record till it is applied,
+// and none of them is part of a txn that is still open.
+func (r *replicaState) resumeOffset() []byte {
+	offset := r.upstreamOffset
+	beginLSN := uint64(math.MaxUint64)
+	for _, txn := range r.txns {
+		if txn.beginLSN < beginLSN {
+			beginLSN = txn.beginLSN
+			offset = txn.resumeOffset
+		}
+	}
+	return offset
+}
+
+// saveUpstreamOffset saves the upstream offset the stream resumes from, it's called once the
+// local wal has the records till it.
+func (e *Engine) saveUpstreamOffset() error {
+	e.mu.RLock()
+	var offset []byte
+	if e.replica != nil {
+		offset = e.replica.resumeOffset()
+	}
+	e.mu.RUnlock()
+	if len(offset) == 0 {
+		return nil
+	}
+	return e.dataStore.StoreMetadata(sysKeyUpstreamOffset, offset)
+}
+
+// writable returns the error for the writes to the engine, if they are not allowed.
+func (e *Engine) writable() error {
+	if e.shutdown.Load() {
+		return ErrInCloseProcess
+	}
+	if e.following.Load() {
+		return ErrReadOnlyReplica
+	}
+	if e.consensus != nil && !e.consensus.leading.Load() {
+		return ErrNotLeader
+	}
+	return nil
+}
+
+// UpstreamOffset returns the offset in the upstream wal the replica resumes the stream from,
+// nil if no record has been applied yet.
+// Every record after it that is already applied is skipped by ApplyReplicated.
+func (e *Engine) UpstreamOffset() []byte {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.replica == nil {
+		return nil
+	}
+	return bytes.Clone(e.replica.resumeOffset())
+}
+
+// ResumeLSN returns the lsn the replica resumes the stream after, every record till it is applied,
+// and none of them is part of a txn that is still open. Unlike the upstream offset, it's the same
+// on every node of the namespace.
+func (e *Engine) ResumeLSN() uint64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	lsn := e.lastLSN.Load()
+	if e.replica == nil {
+		return lsn
+	}
+	for _, txn := range e.replica.txns {
+		lsn = min(lsn, txn.beginLSN-1)
+	}
+	return lsn
+}
+
+// AppliedUpstream returns the offset in the upstream wal and the lsn of the last record applied to the replica.
+func (e *Engine) AppliedUpstream() ([]byte, uint64) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.replica == nil {
+		return nil, 0
+	}
+	return bytes.Clone(e.replica.upstreamOffset), e.lastLSN.Load()
+}
+
+// ObserveRoot records the progress of the root primary of the namespace reported by the upstream of the replica,
+// the lsn of its last record and the HLC of its oldest record after the ones the replica has, zero if none.
+// A replica that streams from another replica measures its lag to the root with it.
+func (e *Engine) ObserveRoot(lsn, pendingHLC uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.replica == nil {
+		return
+	}
+	e.replica.rootLSN = lsn
+	e.replica.rootPendingHLC = pendingHLC
+}
+
+// RootProgress returns the lsn of the last record of the root primary of the namespace, and the HLC of its
+// oldest record the engine doesn't have yet, zero if it has every record. A primary is its own root.
+func (e *Engine) RootProgress() (uint64, uint64) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	lastLSN := e.lastLSN.Load()
+	if e.replica == nil || e.replica.rootLSN <= lastLSN {
+		return lastLSN, 0
+	}
+	return e.replica.rootLSN, e.replica.rootPendingHLC
+}
+
+// ApplyReplicated applies a record streamed from the wal of the upstream engine to the replica.
+// The record is verified against its checksum, appended to the local wal as it was received
+// and written to the mem table, so it's served by the reads of the replica.
+//
+// Records of a txn after its begin record are linked to the previous record of the txn by its offset
+// in the wal, so the link is moved to the offset of the record in the local wal before it's appended.
+// Records already in the local wal, received again once the stream resumes after a restart, are skipped.
+// A record after them must follow the last record of the replica, else ErrReplicatedLSNGap is returned.
+func (e *Engine) ApplyReplicated(upstreamOffset, data []byte, checksum uint32) error {
+	return e.applyReplicated(upstreamOffset, data, checksum, false)
+}
+
+// ApplyFilteredReplicated applies a record of a filtered stream of the upstream wal like ApplyReplicated,
+// the records filtered out of the stream leave gaps in the lsn of the replica.
+func (e *Engine) ApplyFilteredReplicated(upstreamOffset, data []byte, checksum uint32) error {
+	return e.applyReplicated(upstreamOffset, data, checksum, true)
+}
+
+func (e *Engine) applyReplicated(upstreamOffset, data []byte, checksum uint32, filtered bool) error {
+	if e.shutdown.Load() {
+		return ErrInCloseProcess
+	}
+	if !e.following.Load() {
+		return ErrNotReplica
+	}
+	if crc32.ChecksumIEEE(data) != checksum {
+		return fmt.Errorf("%w: checksum mismatch of the replicated record", ErrRecordCorrupted)
+	}
+	record, err := logcodec.DecodeRecord(data)
+	if err != nil {
+		return err
+	}
+
+	metrics.IncrCounterWithLabels(mKeyReplicaApplyTotal, 1, e.metricsLabel)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mKeyReplicaApplyDuration, startTime, e.metricsLabel)
+	}()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// promoted while the record was decoded.
+	if e.replica == nil {
+		return ErrNotReplica
+	}
+	e.observeHLC(record.HLC)
+	if record.LSN <= e.lastLSN.Load() {
+		metrics.IncrCounterWithLabels(mKeyReplicaSkipTotal, 1, e.metricsLabel)
+		if err := e.trackReplicatedTxn(record); err != nil {
+			return err
+		}
+		e.replica.upstreamOffset = bytes.Clone(upstreamOffset)
+		return nil
+	}
+	if !filtered && record.LSN != e.lastLSN.Load()+1 {
+		return fmt.Errorf("%w: lsn %d after lsn %d", ErrReplicatedLSNGap, record.LSN, e.lastLSN.Load())
+	}
+	if err := e.replicatedEpoch(record); err != nil {
+		return err
+	}
+	return e.applyReplicatedRecord(record, data, bytes.Clone(upstreamOffset))
+}
+
+// SkipReplicated moves the upstream offset the replica resumes from past the upstream records
+// that were filtered out of the stream, without applying any record.
+func (e *Engine) SkipReplicated(upstreamOffset []byte) error {
+	if e.shutdown.Load() {
+		return ErrInCloseProcess
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.replica == nil {
+		return ErrNotReplica
+	}
+	e.replica.upstreamOffset = bytes.Clone(upstreamOffset)
+	e.activeMemTable.upstreamOffset = e.replica.resumeOffset()
+	return nil
+}
+
+// applyReplicatedRecord appends the record to the local wal and writes it to the mem table.
+func (e *Engine) applyReplicatedRecord(record *logcodec.LogRecord, data, upstreamOffset []byte) error {
+	var txn *replicaTxn
+	if record.TxnState == logrecord.TransactionStatePrepare || record.TxnState == logrecord.TransactionStateCommit {
+		txn = e.replica.txns[string(record.TxnID)]
+		if txn == nil {
+			return fmt.Errorf("%w: lsn %d", ErrUnknownReplicatedTxn, record.LSN)
+		}
+		record.PrevTxnWalIndex = txn.lastPos.Encode()
+		data = record.Encode()
+	}
+
+	e.writeSeenCounter.Add(uint64(max(1, record.BatchLen())))
+	offset, err := e.walIO.Append(data)
+	if err != nil {
+		return err
+	}
+	e.appendedLSN(record.LSN, offset)
+	// the records of an open txn aren't written to the mem table, they notify the waiters of the lsn here.
+	defer e.appendWatch.advance(offset)
+	previous := e.replica.upstreamOffset
+	e.replica.upstreamOffset = upstreamOffset
+
+	switch {
+	case record.IsBatch():
+		entries, err := e.replicatedBatchEntries(record, offset)
+		if err != nil {
+			return err
+		}
+		err = e.memTableWriteBatch(entries, offset)
+		if err != nil {
+			return err
+		}
+	case record.TxnState == logrecord.TransactionStateBegin:
+		e.replica.txns[string(record.TxnID)] = &replicaTxn{
+			resumeOffset: previous,
+			beginLSN:     record.LSN,
+			lastPos:      offset,
+		}
+		return nil
+	case record.TxnState == logrecord.TransactionStatePrepare:
+		txn.prepare(record, data, offset, e.config.ValueThreshold)
+		return nil
+	case record.TxnState == logrecord.TransactionStateCommit:
+		delete(e.replica.txns, string(record.TxnID))
+		txn.lastPos = offset
+		if err := e.commitReplicatedTxn(txn, record, data); err != nil {
+			return err
+		}
+	case record.TxnState == logrecord.TransactionStateNone:
+		if !isDataOperation(record) {
+			return nil
+		}
+		memValue := replicatedMemValue(record, data, offset.Encode(), e.config.ValueThreshold)
+		if err := e.memTableWrite(record.Key(), memValue, offset); err != nil {
+			return err
+		}
+	}
+
+	e.activeMemTable.upstreamOffset = e.replica.resumeOffset()
+	return nil
+}
+
+// trackReplicatedTxn moves the open txn to the record already present in the local wal, for the records
+// received again once the stream resumes from before the begin record of the txn.
+func (e *Engine) trackReplicatedTxn(record *logcodec.LogRecord) error {
+	switch record.TxnState {
+	case logrecord.TransactionStateBegin:
+		offset, err := e.lsnIndex.lookup(record.LSN)
+		if err != nil {
+			return err
+		}
+		e.replica.txns[string(record.TxnID)] = &replicaTxn{
+			resumeOffset: e.replica.upstreamOffset,
+			beginLSN:     record.LSN,
+			lastPos:      offset,
+		}
+	case logrecord.TransactionStatePrepare:
+		txn := e.replica.txns[string(record.TxnID)]
+		if txn == nil {
+			return fmt.Errorf("%w: lsn %d", ErrUnknownReplicatedTxn, record.LSN)
+		}
+		offset, err := e.lsnIndex.lookup(record.LSN)
+		if err != nil {
+			return err
+		}
+		data, err := e.walIO.Read(offset)
+		if err != nil {
+			return err
+		}
+		txn.prepare(record, data, offset, e.config.ValueThreshold)
+	case logrecord.TransactionStateCommit:
+		// the commit is recovered from the local wal.
+		delete(e.replica.txns, string(record.TxnID))
+	}
+	return nil
+}
+
+// prepare adds the prepared record at the offset of the local wal to the txn, its entries
+// are written to the mem table once the txn commits.
+func (txn *replicaTxn) prepare(record *logcodec.LogRecord, data []byte, offset *wal.Offset, threshold int64) {
+	txn.lastPos = offset
+	// for chunked type only the commit record is written to the mem table.
+	if record.EntryType == logrecord.LogEntryTypeChunked {
+		return
+	}
+	txn.memTableEntries = append(txn.memTableEntries, txMemTableEntry{
+		key:    record.Key(),
+		offset: offset,
+		value:  replicatedMemValue(record, data, offset.Encode(), threshold),
+	})
+}
+
+// commitReplicatedTxn writes the prepared entries of the txn to the mem table,
+// or the commit record for the chunked type.
+func (e *Engine) commitReplicatedTxn(txn *replicaTxn, record *logcodec.LogRecord, data []byte) error {
+	if record.EntryType == logrecord.LogEntryTypeChunked {
+		memValue := getValueStruct(logOperationInsert, true, data)
+		return e.memTableWrite(record.Key(), memValue, txn.lastPos)
+	}
+	for _, entry := range txn.memTableEntries {
+		if err := e.memTableWrite(entry.key, entry.value, entry.offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replicatedBatchEntries returns the mem table entries of the operations of the batch record at the offset.
+func (e *Engine) replicatedBatchEntries(record *logcodec.LogRecord, offset *wal.Offset) ([]txMemTableEntry, error) {
+	records, err := record.BatchRecords()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]txMemTableEntry, len(records))
+	for i, bRecord := range records {
+		reference := encodeBatchReference(offset, i)
+		entries[i] = txMemTableEntry{
+			key:    bRecord.Key(),
+			offset: offset,
+			value:  replicatedMemValue(bRecord, record.Payload.KeyValueBatchEntries.Entries[i].Value, reference, e.config.ValueThreshold),
+		}
+	}
+	return entries, nil
+}
+
+// replicatedMemValue returns the mem table value of the record, the encoded record if its value is under the
+// threshold, else the reference to it.
+func replicatedMemValue(record *logcodec.LogRecord, encoded, reference []byte, threshold int64) y.ValueStruct {
+	size := len(record.Value())
+	if record.EntryType == logrecord.LogEntryTypeRow {
+		size = 0
+		for _, column := range record.Columns() {
+			size += len(column.Name) + len(column.Value)
+		}
+	}
+
+	var memValue y.ValueStruct
+	if int64(size) <= threshold {
+		memValue = getValueStruct(byte(record.OperationType), true, encoded)
+	} else {
+		memValue = getValueStruct(byte(record.OperationType), false, reference)
+	}
+	if record.EntryType == logrecord.LogEntryTypeRow {
+		memValue.UserMeta = entryTypeRow
+	}
+	return memValue
+}
+
+// isDataOperation reports if the record changes a key or a row.
+func isDataOperation(record *logcodec.LogRecord) bool {
+	switch record.OperationType {
+	case logrecord.LogOperationTypeInsert, logrecord.LogOperationTypeDelete, logrecord.LogOperationTypeDeleteRowByKey:
+		return true
+	}
+	return false
+}
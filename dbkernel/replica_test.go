@@ -0,0 +1,246 @@
+package dbkernel
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replicate applies the records of the leader after the offset to the replica, and returns the offset of the last one.
+func replicate(t *testing.T, leader, replica *Engine, offset []byte) []byte {
+	t.Helper()
+	reader, err := leader.NewReader()
+	if offset != nil {
+		reader, err = leader.NewReaderWithStart(DecodeOffset(offset))
+	}
+	require.NoError(t, err)
+	if offset != nil {
+		_, _, err = reader.Next()
+		require.NoError(t, err)
+	}
+
+	for {
+		data, pos, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return offset
+		}
+		require.NoError(t, err)
+		offset = pos.Encode()
+		require.NoError(t, replica.ApplyReplicated(offset, data, crc32.ChecksumIEEE(data)))
+	}
+}
+
+func newReplicaEngine(t *testing.T, dir string) *Engine {
+	t.Helper()
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+	config.Replica = true
+	replica, err := NewStorageEngine(dir, "test_replica", config)
+	require.NoError(t, err)
+	return replica
+}
+
+func TestEngine_ApplyReplicated(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+	leader, err := NewStorageEngine(t.TempDir(), "test_replica", config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, leader.Close(context.Background()))
+	})
+
+	large := []byte(gofakeit.LetterN(uint(config.ValueThreshold) + 1))
+	require.NoError(t, leader.Put([]byte("key_1"), []byte("value_1")))
+	require.NoError(t, leader.Put([]byte("large"), large))
+	require.NoError(t, leader.Put([]byte("removed_key"), []byte("value")))
+	require.NoError(t, leader.Delete([]byte("removed_key")))
+	require.NoError(t, leader.SetColumnsInRow("user_row_key", map[string][]byte{"a": []byte("1"), "b": []byte("2")}))
+	batch := NewBatch()
+	batch.Put([]byte("batch_key"), []byte("batch_value"))
+	batch.Put([]byte("batch_large"), large)
+	require.NoError(t, leader.WriteBatch(batch))
+
+	txn, err := leader.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+	require.NoError(t, err)
+	require.NoError(t, txn.AppendKVTxn([]byte("txn_key"), []byte("txn_value")))
+	require.NoError(t, txn.AppendKVTxn([]byte("txn_large"), large))
+	require.NoError(t, txn.Commit())
+	committed, err := leader.OffsetForLSN(leader.LastLSN())
+	require.NoError(t, err)
+	beforeTxns := committed.Encode()
+
+	// txns still open when the replica restarts.
+	chunked, err := leader.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeChunked)
+	require.NoError(t, err)
+	require.NoError(t, chunked.AppendKVTxn([]byte("chunked_key"), []byte("chunk_1")))
+	rowTxn, err := leader.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeRow)
+	require.NoError(t, err)
+	require.NoError(t, rowTxn.AppendColumnTxn([]byte("txn_row_key"), map[string][]byte{"a": []byte("1")}))
+
+	dir := t.TempDir()
+	replica := newReplicaEngine(t, dir)
+	assert.Nil(t, replica.UpstreamOffset())
+	offset := replicate(t, leader, replica, nil)
+	assert.Equal(t, leader.LastLSN(), replica.LastLSN())
+
+	value, err := replica.Get([]byte("key_1"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value_1"), value)
+	value, err = replica.Get([]byte("large"))
+	require.NoError(t, err)
+	assert.Equal(t, large, value)
+	_, err = replica.Get([]byte("removed_key"))
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	columns, err := replica.GetRowColumns("user_row_key", nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, columns)
+	value, err = replica.Get([]byte("batch_large"))
+	require.NoError(t, err)
+	assert.Equal(t, large, value)
+	value, err = replica.Get([]byte("txn_large"))
+	require.NoError(t, err)
+	assert.Equal(t, large, value)
+	_, err = replica.Get([]byte("chunked_key"))
+	assert.ErrorIs(t, err, ErrKeyNotFound, "open txn should not be visible")
+
+	assert.ErrorIs(t, replica.Put([]byte("key"), []byte("value")), ErrReadOnlyReplica)
+	assert.ErrorIs(t, replica.WriteBatch(batch), ErrReadOnlyReplica)
+	_, err = replica.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+	assert.ErrorIs(t, err, ErrReadOnlyReplica)
+
+	// the stream resumes from before the open txns.
+	assert.Equal(t, beforeTxns, replica.UpstreamOffset())
+	require.NoError(t, replica.Close(context.Background()))
+	replica = newReplicaEngine(t, dir)
+	t.Cleanup(func() {
+		assert.NoError(t, replica.Close(context.Background()))
+	})
+	assert.Equal(t, beforeTxns, replica.UpstreamOffset())
+	lsn := replica.LastLSN()
+	assert.Equal(t, offset, replicate(t, leader, replica, replica.UpstreamOffset()))
+	assert.Equal(t, lsn, replica.LastLSN(), "records already applied should be skipped")
+
+	require.NoError(t, chunked.AppendKVTxn([]byte("chunked_key"), []byte("chunk_2")))
+	require.NoError(t, chunked.Commit())
+	require.NoError(t, rowTxn.AppendColumnTxn([]byte("txn_row_key"), map[string][]byte{"b": []byte("2")}))
+	require.NoError(t, rowTxn.Commit())
+	require.NoError(t, leader.Put([]byte("key_1"), []byte("value_2")))
+	offset = replicate(t, leader, replica, offset)
+	assert.Equal(t, offset, replica.UpstreamOffset())
+	assert.Equal(t, leader.LastLSN(), replica.LastLSN())
+
+	value, err = replica.Get([]byte("chunked_key"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("chunk_1chunk_2"), value)
+	columns, err = replica.GetRowColumns("txn_row_key", nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, columns)
+	value, err = replica.Get([]byte("key_1"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value_2"), value)
+
+	// flushed records are served from the btree store, and the upstream offset is saved with the checkpoint.
+	flushActiveMemTable(t, replica)
+	assert.Eventually(t, func() bool {
+		saved, err := replica.dataStore.RetrieveMetadata(sysKeyUpstreamOffset)
+		return err == nil && string(saved) == string(offset)
+	}, 5*time.Second, 10*time.Millisecond)
+	value, err = replica.dataStore.Get([]byte("chunked_key"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("chunk_1chunk_2"), value)
+}
+
+func TestEngine_ApplyReplicated_Errors(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+	leader, err := NewStorageEngine(t.TempDir(), "test_replica", config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, leader.Close(context.Background()))
+	})
+	replica := newReplicaEngine(t, t.TempDir())
+	t.Cleanup(func() {
+		assert.NoError(t, replica.Close(context.Background()))
+	})
+
+	txn, err := leader.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+	require.NoError(t, err)
+	require.NoError(t, txn.AppendKVTxn([]byte("txn_key"), []byte("txn_value")))
+	require.NoError(t, txn.Commit())
+
+	reader, err := leader.NewReader()
+	require.NoError(t, err)
+	begin, pos, err := reader.Next()
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, leader.ApplyReplicated(pos.Encode(), begin, crc32.ChecksumIEEE(begin)), ErrNotReplica)
+	err = replica.ApplyReplicated(pos.Encode(), begin, crc32.ChecksumIEEE(begin)+1)
+	assert.ErrorIs(t, err, ErrRecordCorrupted)
+	assert.Equal(t, uint64(0), replica.LastLSN(), "corrupted record should not be applied")
+
+	// a record after a gap in the stream.
+	prepare, pos, err := reader.Next()
+	require.NoError(t, err)
+	err = replica.ApplyReplicated(pos.Encode(), prepare, crc32.ChecksumIEEE(prepare))
+	assert.ErrorIs(t, err, ErrReplicatedLSNGap)
+	assert.Equal(t, uint64(0), replica.LastLSN(), "record after a gap should not be applied")
+
+	// a txn record without its begin record, the gap is expected in a filtered stream.
+	err = replica.ApplyFilteredReplicated(pos.Encode(), prepare, crc32.ChecksumIEEE(prepare))
+	assert.ErrorIs(t, err, ErrUnknownReplicatedTxn)
+	assert.Nil(t, replica.UpstreamOffset())
+}
+
+func TestEngine_WaitForLSN(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+	leader, err := NewStorageEngine(t.TempDir(), "test_replica", config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, leader.Close(context.Background()))
+	})
+	replica := newReplicaEngine(t, t.TempDir())
+	t.Cleanup(func() {
+		assert.NoError(t, replica.Close(context.Background()))
+	})
+
+	require.NoError(t, leader.Put([]byte("key"), []byte("value")))
+	err = replica.WaitForLSN(context.Background(), 20*time.Millisecond, leader.LastLSN())
+	assert.ErrorIs(t, err, ErrWaitTimeoutExceeded)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- replica.WaitForLSN(context.Background(), 5*time.Second, leader.LastLSN())
+	}()
+	offset := replicate(t, leader, replica, nil)
+	require.NoError(t, <-done)
+	value, err := replica.Get([]byte("key"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	// the begin record of an open txn isn't written to the mem table, it still wakes the waiters.
+	txn, err := leader.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+	require.NoError(t, err)
+	go func() {
+		done <- replica.WaitForLSN(context.Background(), 5*time.Second, leader.LastLSN())
+	}()
+	assert.Eventually(t, func() bool {
+		return replica.appendWatch.len() == 1
+	}, 5*time.Second, time.Millisecond)
+	replicate(t, leader, replica, offset)
+	require.NoError(t, <-done)
+	require.NoError(t, txn.AppendKVTxn([]byte("txn_key"), []byte("txn_value")))
+	require.NoError(t, txn.Commit())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, replica.WaitForLSN(ctx, time.Second, leader.LastLSN()), context.Canceled)
+}
@@ -0,0 +1,210 @@
+package dbkernel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
+	"github.com/ankur-anand/unisondb/dbkernel/wal"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
+)
+
+var (
+	// ErrRestoreNotSupported is returned when the btree store of the engine can't be restored from a snapshot.
+	ErrRestoreNotSupported = errors.New("btree store doesn't support restore")
+	// ErrReplicaNotEmpty is returned when a snapshot is restored to a replica that already has applied records.
+	ErrReplicaNotEmpty = errors.New("replica already has records")
+)
+
+// btreeRestorer is implemented by the btree stores that can load the output of their Snapshot.
+type btreeRestorer interface {
+	Restore(r io.Reader) error
+}
+
+// SnapshotOffset returns the offset in the WAL that a snapshot of the btree store, taken after the call, matches,
+// and the lsn of the record at it. The WAL records after it replayed on top of the snapshot gives the current
+// state of the engine.
+//
+// It's the checkpoint of the btree store, or before it if a txn that started before the checkpoint
+// isn't committed at it, so the replay has every record of the txn. Nil offset replays the complete WAL,
+// the lsn is then of the last record before the WAL, only in the btree store.
+func (e *Engine) SnapshotOffset() (*Offset, uint64, error) {
+	first, err := e.FirstLSN()
+	if err != nil {
+		return nil, 0, err
+	}
+	value, err := e.dataStore.RetrieveMetadata(sysKeyWalCheckPoint)
+	if errors.Is(err, kvdrivers.ErrKeyNotFound) {
+		return nil, first - 1, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	checkpoint := UnmarshalMetadata(value).Pos
+	if checkpoint.SegmentId == 0 {
+		return nil, first - 1, nil
+	}
+
+	data, err := e.walIO.Read(checkpoint)
+	if err != nil {
+		return nil, 0, err
+	}
+	lsn, err := logcodec.DecodeLSN(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// txns that started before the checkpoint and are still open.
+	e.mu.RLock()
+	for _, beginLSN := range e.openTxns {
+		lsn = min(lsn, beginLSN-1)
+	}
+	e.mu.RUnlock()
+
+	// txns that started before the checkpoint and have records after it.
+	beginLSN, err := e.txnBeginLSNAfter(checkpoint)
+	if err != nil {
+		return nil, 0, err
+	}
+	if beginLSN > 0 {
+		lsn = min(lsn, beginLSN-1)
+	}
+
+	if lsn < first {
+		return nil, first - 1, nil
+	}
+	offset, err := e.OffsetForLSN(lsn)
+	if err != nil {
+		return nil, 0, err
+	}
+	return offset, lsn, nil
+}
+
+// txnBeginLSNAfter returns the lsn of the first begin record of the txns that have records after the offset
+// and started before it, zero if there are none.
+func (e *Engine) txnBeginLSNAfter(offset *wal.Offset) (uint64, error) {
+	reader, err := e.walIO.NewReaderWithStart(offset)
+	if err != nil {
+		return 0, err
+	}
+	// the record at the offset is before the replay.
+	if _, _, err := reader.Next(); err != nil {
+		return 0, err
+	}
+
+	var beginLSN uint64
+	txns := make(map[string]struct{})
+	for {
+		data, _, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return beginLSN, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		record, err := logcodec.DecodeRecord(data)
+		if err != nil {
+			return 0, err
+		}
+		if record.TxnState == logrecord.TransactionStateNone {
+			continue
+		}
+		if _, ok := txns[string(record.TxnID)]; ok {
+			continue
+		}
+		txns[string(record.TxnID)] = struct{}{}
+		if record.TxnState == logrecord.TransactionStateBegin {
+			continue
+		}
+
+		offsets, err := e.walIO.GetTransactionOffsets(wal.DecodeOffset(record.PrevTxnWalIndex))
+		if err != nil {
+			return 0, err
+		}
+		begin, err := e.walIO.Read(offsets[0])
+		if err != nil {
+			return 0, err
+		}
+		lsn, err := logcodec.DecodeLSN(begin)
+		if err != nil {
+			return 0, err
+		}
+		if beginLSN == 0 || lsn < beginLSN {
+			beginLSN = lsn
+		}
+	}
+}
+
+// RestoreSnapshot replaces the btree store of the replica with the snapshot of the upstream btree store,
+// that matches the upstream offset and has every record till the lsn, see SnapshotOffset and SnapshotLSN.
+// ApplyReplicated continues with the records after it. It's only allowed before any record is applied to the replica.
+//
+// The lsn of the replica moves to the one of the snapshot, so the stream can resume after it on any upstream
+// of the namespace, unlike the offset that is only of the upstream the snapshot is taken on.
+func (e *Engine) RestoreSnapshot(r io.Reader, upstreamOffset []byte, lsn uint64) error {
+	if !e.following.Load() {
+		return ErrNotReplica
+	}
+	restorer, ok := e.dataStore.(btreeRestorer)
+	if !ok {
+		return ErrRestoreNotSupported
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.replica == nil {
+		return ErrNotReplica
+	}
+	if e.lastLSN.Load() != 0 {
+		return ErrReplicaNotEmpty
+	}
+	if err := restorer.Restore(r); err != nil {
+		return err
+	}
+
+	// checkpoint of the upstream is not of the local wal, every record of the local wal is yet to be recovered.
+	if err := SaveMetadata(e.dataStore, &wal.Offset{}, 0); err != nil {
+		return err
+	}
+	if err := e.loadHLC(); err != nil {
+		return err
+	}
+	if err := e.loadEpochs(); err != nil {
+		return err
+	}
+	if err := e.loadBloomFilter(); err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return err
+	}
+
+	// an upstream that is a replica itself has its own upstream offset in the snapshot, it's of another wal.
+	e.replica.upstreamOffset = bytes.Clone(upstreamOffset)
+	if err := e.dataStore.StoreMetadata(sysKeyUpstreamOffset, upstreamOffset); err != nil {
+		return err
+	}
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], lsn)
+	if err := e.dataStore.StoreMetadata(sysKeySnapshotLSN, buf[:]); err != nil {
+		return err
+	}
+	e.lastLSN.Store(lsn)
+	return e.dataStore.FSync()
+}
+
+// FirstLSN returns the lsn of the first record retained in the wal, the records before it are only in the btree
+// store, like the ones of the snapshot a replica is restored from. It's LastLSN()+1 if the wal has no record.
+func (e *Engine) FirstLSN() (uint64, error) {
+	for _, id := range e.walIO.SegmentIDs() {
+		first, err := e.lsnIndex.firstLSN(id)
+		if err != nil {
+			return 0, err
+		}
+		if first != math.MaxUint64 {
+			return first, nil
+		}
+	}
+	return e.lastLSN.Load() + 1, nil
+}
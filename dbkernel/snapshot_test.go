@@ -0,0 +1,90 @@
+package dbkernel
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_RestoreSnapshot(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+	leader, err := NewStorageEngine(t.TempDir(), "test_snapshot", config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, leader.Close(context.Background()))
+	})
+
+	offset, snapshotLSN, err := leader.SnapshotOffset()
+	require.NoError(t, err)
+	assert.Nil(t, offset, "without a checkpoint the complete wal is replayed")
+	assert.Equal(t, uint64(0), snapshotLSN)
+
+	require.NoError(t, leader.Put([]byte("key_1"), []byte("value_1")))
+	// txn open across the checkpoint.
+	txn, err := leader.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+	require.NoError(t, err)
+	beforeTxn, err := leader.OffsetForLSN(leader.LastLSN() - 1)
+	require.NoError(t, err)
+	require.NoError(t, txn.AppendKVTxn([]byte("txn_key"), []byte("txn_value")))
+	require.NoError(t, leader.Put([]byte("key_2"), []byte("value_2")))
+	flushActiveMemTable(t, leader)
+	// the checkpoint is saved once the btree store is synced.
+	assert.Eventually(t, func() bool {
+		checkpoint, err := leader.dataStore.RetrieveMetadata(sysKeyWalCheckPoint)
+		return err == nil && UnmarshalMetadata(checkpoint).Pos.SegmentId != 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	offset, snapshotLSN, err = leader.SnapshotOffset()
+	require.NoError(t, err)
+	assert.Equal(t, beforeTxn, offset, "open txn should be replayed from its begin record")
+	assert.Equal(t, uint64(1), snapshotLSN)
+
+	require.NoError(t, txn.Commit())
+	offset, snapshotLSN, err = leader.SnapshotOffset()
+	require.NoError(t, err)
+	assert.Equal(t, beforeTxn, offset, "committed txn with records after the checkpoint should be replayed")
+
+	var snapshot bytes.Buffer
+	_, err = leader.BtreeSnapshot(&snapshot)
+	require.NoError(t, err)
+	require.NoError(t, leader.Put([]byte("key_3"), []byte("value_3")))
+
+	assert.ErrorIs(t, leader.RestoreSnapshot(bytes.NewReader(snapshot.Bytes()), offset.Encode(), snapshotLSN), ErrNotReplica)
+
+	dir := t.TempDir()
+	replica := newReplicaEngine(t, dir)
+	require.NoError(t, replica.RestoreSnapshot(bytes.NewReader(snapshot.Bytes()), offset.Encode(), snapshotLSN))
+	assert.Equal(t, offset.Encode(), replica.UpstreamOffset())
+	assert.Equal(t, snapshotLSN, replica.LastLSN(), "replica should resume after the lsn of the snapshot")
+	value, err := replica.Get([]byte("key_2"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value_2"), value)
+
+	last := replicate(t, leader, replica, replica.UpstreamOffset())
+	assert.Equal(t, leader.LastLSN(), replica.LastLSN())
+	for key, want := range map[string]string{"key_1": "value_1", "txn_key": "txn_value", "key_3": "value_3"} {
+		value, err := replica.Get([]byte(key))
+		require.NoError(t, err)
+		assert.Equal(t, []byte(want), value)
+	}
+	assert.ErrorIs(t, replica.RestoreSnapshot(bytes.NewReader(snapshot.Bytes()), offset.Encode(), snapshotLSN), ErrReplicaNotEmpty)
+
+	// the restored replica recovers its own wal after a restart.
+	require.NoError(t, replica.Close(context.Background()))
+	replica = newReplicaEngine(t, dir)
+	t.Cleanup(func() {
+		assert.NoError(t, replica.Close(context.Background()))
+	})
+	assert.Equal(t, leader.LastLSN(), replica.LastLSN())
+	assert.Equal(t, last, replica.UpstreamOffset())
+	assert.Eventually(t, func() bool {
+		value, err := replica.Get([]byte("txn_key"))
+		return err == nil && string(value) == "txn_value"
+	}, 5*time.Second, 10*time.Millisecond)
+}
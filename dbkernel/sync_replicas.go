@@ -0,0 +1,201 @@
+package dbkernel
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-metrics"
+)
+
+// defaultSyncReplicasTimeout is the time a write waits for the sync replicas, if the config doesn't set it.
+const defaultSyncReplicasTimeout = 5 * time.Second
+
+// ErrSyncReplicasTimeout is returned by the writes that are not acknowledged as durable by the sync replicas
+// in time. The write is still applied to the engine, and is replicated once the followers catch up.
+var ErrSyncReplicasTimeout = errors.New("timed out waiting for the sync replicas")
+
+var (
+	mKeySyncReplicasWaitTotal     = append(packageKey, "sync", "replicas", "wait", "total")
+	mKeySyncReplicasTimeoutTotal  = append(packageKey, "sync", "replicas", "wait", "timeout", "total")
+	mKeySyncReplicasWaitDuration  = append(packageKey, "sync", "replicas", "wait", "durations", "seconds")
+	mKeySyncReplicasDegradedTotal = append(packageKey, "sync", "replicas", "degraded", "total")
+)
+
+// ReplicationConfig is the replication config of the namespace on the primary.
+type ReplicationConfig struct {
+	// SyncReplicas is the number of followers that must acknowledge a write as durable, before the write returns.
+	// Zero returns once the write is applied locally.
+	SyncReplicas int `toml:"sync_replicas"`
+	// SyncTimeout is the time a write waits for the sync replicas, 5s if zero.
+	SyncTimeout time.Duration `toml:"sync_timeout"`
+	// DegradeOnTimeout returns the writes that time out without an error, and the writes don't wait
+	// for the sync replicas till they acknowledge the write that timed out.
+	// Without it the writes that time out return ErrSyncReplicasTimeout.
+	DegradeOnTimeout bool `toml:"degrade_on_timeout"`
+}
+
+// syncReplicas tracks the lsn the followers have acknowledged as durable, the writes wait till the
+// configured number of followers have acknowledged them.
+type syncReplicas struct {
+	config ReplicationConfig
+	label  []metrics.Label
+
+	mu sync.Mutex
+	// durable is the durable lsn of the followers by their id.
+	durable map[string]uint64
+	// quorumLSN is the lsn acknowledged as durable by the configured number of followers.
+	quorumLSN uint64
+	// degradedLSN is the lsn of the write that timed out, the writes don't wait till the quorum reaches it.
+	degradedLSN uint64
+	waiters     map[uint64]chan struct{}
+}
+
+func newSyncReplicas(config ReplicationConfig, label []metrics.Label) *syncReplicas {
+	if config.SyncTimeout == 0 {
+		config.SyncTimeout = defaultSyncReplicasTimeout
+	}
+	return &syncReplicas{
+		config:  config,
+		label:   label,
+		durable: make(map[string]uint64),
+		waiters: make(map[uint64]chan struct{}),
+	}
+}
+
+// ack records the lsn the follower has made durable.
+func (s *syncReplicas) ack(replicaID string, lsn uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lsn <= s.durable[replicaID] {
+		return
+	}
+	s.durable[replicaID] = lsn
+	s.updateQuorum()
+}
+
+// remove stops tracking the follower, the lsn already acknowledged by the quorum stays acknowledged.
+func (s *syncReplicas) remove(replicaID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.durable, replicaID)
+}
+
+// updateQuorum moves the quorum lsn to the lsn durable on the configured number of followers,
+// and notifies the writes till it.
+func (s *syncReplicas) updateQuorum() {
+	if len(s.durable) < s.config.SyncReplicas {
+		return
+	}
+	lsns := make([]uint64, 0, len(s.durable))
+	for _, lsn := range s.durable {
+		lsns = append(lsns, lsn)
+	}
+	slices.Sort(lsns)
+	quorumLSN := lsns[len(lsns)-s.config.SyncReplicas]
+	if quorumLSN <= s.quorumLSN {
+		return
+	}
+	s.quorumLSN = quorumLSN
+
+	for lsn, ch := range s.waiters {
+		if lsn <= quorumLSN {
+			close(ch)
+			delete(s.waiters, lsn)
+		}
+	}
+	if s.degradedLSN != 0 && s.degradedLSN <= quorumLSN {
+		slog.Info("[kvalchemy.dbengine] sync replicas caught up, writes wait for them again", "lsn", quorumLSN)
+		s.degradedLSN = 0
+	}
+}
+
+// wait blocks till the write with the lsn is acknowledged as durable by the sync replicas, or the timeout.
+func (s *syncReplicas) wait(lsn uint64) error {
+	s.mu.Lock()
+	if lsn <= s.quorumLSN || s.degradedLSN != 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	s.waiters[lsn] = ch
+	s.mu.Unlock()
+
+	metrics.IncrCounterWithLabels(mKeySyncReplicasWaitTotal, 1, s.label)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mKeySyncReplicasWaitDuration, startTime, s.label)
+	}()
+
+	timer := time.NewTimer(s.config.SyncTimeout)
+	defer timer.Stop()
+	select {
+	case <-ch:
+		return nil
+	case <-timer.C:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// acknowledged while taking the lock.
+	if _, ok := s.waiters[lsn]; !ok {
+		return nil
+	}
+	delete(s.waiters, lsn)
+	metrics.IncrCounterWithLabels(mKeySyncReplicasTimeoutTotal, 1, s.label)
+	if !s.config.DegradeOnTimeout {
+		return ErrSyncReplicasTimeout
+	}
+
+	if s.degradedLSN == 0 {
+		slog.Warn("[kvalchemy.dbengine] sync replicas timed out, writes don't wait for them till they catch up",
+			"lsn", lsn, "sync_replicas", s.config.SyncReplicas, "timeout", s.config.SyncTimeout)
+		metrics.IncrCounterWithLabels(mKeySyncReplicasDegradedTotal, 1, s.label)
+		s.degradedLSN = lsn
+	}
+	// the writes waiting for the sync replicas return as well.
+	for waiting, ch := range s.waiters {
+		close(ch)
+		delete(s.waiters, waiting)
+	}
+	return nil
+}
+
+// waitForSyncReplicas blocks till the write with the lsn is acknowledged as durable by the sync replicas
+// of the namespace, if it has any. The writes to a consensus namespace wait till they are committed.
+func (e *Engine) waitForSyncReplicas(lsn uint64) error {
+	if e.consensus != nil {
+		return e.consensus.wait(lsn)
+	}
+	if e.syncReplicas == nil {
+		return nil
+	}
+	return e.syncReplicas.wait(lsn)
+}
+
+// SyncReplicas returns the number of followers the writes of the namespace wait for, zero if none.
+func (e *Engine) SyncReplicas() int {
+	if e.syncReplicas == nil {
+		return 0
+	}
+	return e.syncReplicas.config.SyncReplicas
+}
+
+// AckDurable records the lsn the follower has synced to its disk, the writes waiting for the sync replicas
+// return once the configured number of followers have acknowledged them.
+func (e *Engine) AckDurable(replicaID string, lsn uint64) {
+	if e.syncReplicas == nil {
+		return
+	}
+	e.syncReplicas.ack(replicaID, lsn)
+}
+
+// RemoveReplica stops counting the follower towards the sync replicas, once it's disconnected.
+func (e *Engine) RemoveReplica(replicaID string) {
+	if e.syncReplicas == nil {
+		return
+	}
+	e.syncReplicas.remove(replicaID)
+}
@@ -0,0 +1,148 @@
+package dbkernel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSyncReplicasEngine(t *testing.T, replication ReplicationConfig) *Engine {
+	t.Helper()
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+	config.Replication = replication
+	engine, err := NewStorageEngine(t.TempDir(), "test_sync_replicas", config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+	return engine
+}
+
+// writeAsync runs the write in a goroutine, and returns the channel its error is sent on.
+func writeAsync(write func() error) chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- write()
+	}()
+	return done
+}
+
+func TestEngine_SyncReplicas(t *testing.T) {
+	engine := newSyncReplicasEngine(t, ReplicationConfig{SyncReplicas: 2, SyncTimeout: 10 * time.Second})
+	assert.Equal(t, 2, engine.SyncReplicas())
+
+	done := writeAsync(func() error {
+		return engine.Put([]byte("key"), []byte("value"))
+	})
+	assert.Eventually(t, func() bool {
+		return engine.LastLSN() == 1
+	}, 5*time.Second, time.Millisecond)
+
+	engine.AckDurable("replica_1", 1)
+	select {
+	case <-done:
+		t.Fatal("write returned with a single sync replica ack")
+	case <-time.After(50 * time.Millisecond):
+	}
+	engine.AckDurable("replica_2", 1)
+	require.NoError(t, <-done)
+
+	value, err := engine.Get([]byte("key"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	// a removed follower doesn't count towards the sync replicas.
+	engine.RemoveReplica("replica_2")
+	done = writeAsync(func() error {
+		return engine.Delete([]byte("key"))
+	})
+	assert.Eventually(t, func() bool {
+		return engine.LastLSN() == 2
+	}, 5*time.Second, time.Millisecond)
+	engine.AckDurable("replica_1", 2)
+	select {
+	case <-done:
+		t.Fatal("write returned with the ack of a single connected follower")
+	case <-time.After(50 * time.Millisecond):
+	}
+	engine.AckDurable("replica_3", 2)
+	require.NoError(t, <-done)
+
+	t.Run("txn_commit", func(t *testing.T) {
+		txn, err := engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+		require.NoError(t, err)
+		require.NoError(t, txn.AppendKVTxn([]byte("txn_key"), []byte("txn_value")))
+		done := writeAsync(txn.Commit)
+		assert.Eventually(t, func() bool {
+			_, err := engine.Get([]byte("txn_key"))
+			return err == nil
+		}, 5*time.Second, time.Millisecond)
+
+		select {
+		case <-done:
+			t.Fatal("commit returned before the sync replicas ack")
+		case <-time.After(50 * time.Millisecond):
+		}
+		engine.AckDurable("replica_1", engine.LastLSN())
+		engine.AckDurable("replica_3", engine.LastLSN())
+		require.NoError(t, <-done)
+	})
+}
+
+func TestEngine_SyncReplicas_Timeout(t *testing.T) {
+	engine := newSyncReplicasEngine(t, ReplicationConfig{SyncReplicas: 1, SyncTimeout: 50 * time.Millisecond})
+
+	err := engine.Put([]byte("key"), []byte("value"))
+	assert.ErrorIs(t, err, ErrSyncReplicasTimeout)
+	// the write is still applied on the primary.
+	value, err := engine.Get([]byte("key"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	batch := NewBatch()
+	batch.Put([]byte("batch_key"), []byte("batch_value"))
+	assert.ErrorIs(t, engine.WriteBatch(batch), ErrSyncReplicasTimeout)
+
+	// acknowledged writes return without waiting.
+	engine.AckDurable("replica_1", engine.LastLSN()+1)
+	assert.NoError(t, engine.Put([]byte("key"), []byte("value_2")))
+}
+
+func TestEngine_SyncReplicas_Degrade(t *testing.T) {
+	timeout := 100 * time.Millisecond
+	engine := newSyncReplicasEngine(t, ReplicationConfig{SyncReplicas: 1, SyncTimeout: timeout, DegradeOnTimeout: true})
+
+	start := time.Now()
+	require.NoError(t, engine.Put([]byte("key_1"), []byte("value")))
+	assert.GreaterOrEqual(t, time.Since(start), timeout)
+	degradedLSN := engine.LastLSN()
+
+	// the writes don't wait till the follower acknowledges the write that timed out.
+	start = time.Now()
+	require.NoError(t, engine.Put([]byte("key_2"), []byte("value")))
+	assert.Less(t, time.Since(start), timeout)
+	engine.AckDurable("replica_1", degradedLSN-1)
+	start = time.Now()
+	require.NoError(t, engine.Put([]byte("key_3"), []byte("value")))
+	assert.Less(t, time.Since(start), timeout)
+
+	engine.AckDurable("replica_1", degradedLSN)
+	done := writeAsync(func() error {
+		return engine.Put([]byte("key_4"), []byte("value"))
+	})
+	assert.Eventually(t, func() bool {
+		return engine.LastLSN() == degradedLSN+3
+	}, 5*time.Second, time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("write didn't wait for the sync replica after it caught up")
+	case <-time.After(timeout / 4):
+	}
+	engine.AckDurable("replica_1", engine.LastLSN())
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,205 @@
+package dbkernel
+
+import (
+	"fmt"
+	"hash/crc32"
+	"maps"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/hashicorp/go-metrics"
+)
+
+var (
+	mKeyGetAtTotal    = append(packageKey, "get", "at", "total")
+	mKeyGetAtDuration = append(packageKey, "get", "at", "durations", "seconds")
+)
+
+// GetAt returns the value the key had at the hybrid logical clock timestamp, see HLCFromTime
+// to read the value at a wall clock time.
+// If the key hasn't changed since the hlc, the current value is read from the mem tables and the BtreeStore,
+// else the value is rebuilt from the WAL.
+// ErrBeforeRetentionHorizon is returned if the changes needed are no longer in the WAL,
+// or without the HistoryIndex, are older than the VersionRetention.
+func (e *Engine) GetAt(key []byte, hlc uint64) ([]byte, error) {
+	if e.shutdown.Load() {
+		return nil, ErrInCloseProcess
+	}
+
+	metrics.IncrCounterWithLabels(mKeyGetAtTotal, 1, e.metricsLabel)
+	startTime := time.Now()
+	defer func() {
+		metrics.MeasureSinceWithLabels(mKeyGetAtDuration, startTime, e.metricsLabel)
+	}()
+
+	for {
+		lookup, err := e.lookupVersions(key, hlc)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(lookup.versions) != 0 {
+			return e.readVersion(lookup.versions[len(lookup.versions)-1])
+		}
+
+		if lookup.changedAfter {
+			// every change of the key present is after the hlc.
+			if lookup.horizon == 0 {
+				return nil, ErrKeyNotFound
+			}
+			return nil, e.beforeHorizonError(hlc, lookup.horizon)
+		}
+
+		value, err := e.Get(key)
+		// the key could have been changed after the lookup, then the current value is not the one at the hlc.
+		if changed, cErr := e.changedAfter(key, hlc); cErr != nil || !changed {
+			if cErr != nil {
+				return nil, cErr
+			}
+			return value, err
+		}
+	}
+}
+
+// GetRowColumnsAt returns the columns the row had at the hybrid logical clock timestamp.
+// It's filters columns if predicate function is provided and only returns those columns for which predicate return true.
+// If the row hasn't changed since the hlc, the current columns are read from the mem tables and the BtreeStore,
+// else the columns are rebuilt by applying the changes of the row present in the WAL.
+// ErrBeforeRetentionHorizon is returned if the changes needed are no longer in the WAL,
+// or without the HistoryIndex, are older than the VersionRetention.
+func (e *Engine) GetRowColumnsAt(rowKey string, hlc uint64, predicate func(columnKey string) bool) (map[string][]byte, error) {
+	if e.shutdown.Load() {
+		return nil, ErrInCloseProcess
+	}
+
+	key := []byte(rowKey)
+	for {
+		lookup, err := e.lookupVersions(key, hlc)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(lookup.versions) != 0 || lookup.changedAfter {
+			columns, err := e.replayRowVersions(lookup, hlc)
+			if err != nil {
+				return nil, err
+			}
+			maps.DeleteFunc(columns, func(columnKey string, _ []byte) bool {
+				return predicate != nil && !predicate(columnKey)
+			})
+			return columns, nil
+		}
+
+		columns, err := e.GetRowColumns(rowKey, predicate)
+		if changed, cErr := e.changedAfter(key, hlc); cErr != nil || !changed {
+			if cErr != nil {
+				return nil, cErr
+			}
+			return columns, err
+		}
+	}
+}
+
+func (e *Engine) lookupVersions(key []byte, hlc uint64) (versionLookup, error) {
+	lookup, err := e.indexLookup(key, hlc)
+	if err != nil {
+		return lookup, err
+	}
+	if hlc < lookup.horizon {
+		return lookup, e.beforeHorizonError(hlc, lookup.horizon)
+	}
+	return lookup, nil
+}
+
+// emptyWalHorizon is the retention horizon of an empty WAL, a namespace that never had a write
+// has the complete history, else only the changes from now on will be in the WAL.
+func (e *Engine) emptyWalHorizon() uint64 {
+	if e.writeSeenCounter.Load() == 0 {
+		return 0
+	}
+	return e.hlc.Last()
+}
+
+func (e *Engine) changedAfter(key []byte, hlc uint64) (bool, error) {
+	lookup, err := e.indexLookup(key, hlc)
+	return lookup.changedAfter, err
+}
+
+// indexLookup looks up the changes of the key till the hlc in the on disk history index if kept,
+// else in the in memory version index.
+func (e *Engine) indexLookup(key []byte, hlc uint64) (versionLookup, error) {
+	if e.history == nil {
+		return e.versions.lookup(e.walIO, key, hlc, e.visibleOffset(), e.emptyWalHorizon)
+	}
+
+	lookup, err := e.history.lookup(key, hlc, e.visibleOffset())
+	if err != nil {
+		return lookup, err
+	}
+	lookup.horizon, err = e.walHorizon()
+	return lookup, err
+}
+
+func (e *Engine) beforeHorizonError(hlc, horizon uint64) error {
+	return fmt.Errorf("%w: requested %s, wal has changes since %s", ErrBeforeRetentionHorizon,
+		HLCTime(hlc).UTC().Format(time.RFC3339Nano), HLCTime(horizon).UTC().Format(time.RFC3339Nano))
+}
+
+// readVersion returns the value of the key as of the change.
+func (e *Engine) readVersion(version keyVersion) ([]byte, error) {
+	if version.operation == walrecord.LogOperationDelete {
+		return nil, ErrKeyNotFound
+	}
+
+	record, err := e.readVersionRecord(version)
+	if err != nil {
+		return nil, err
+	}
+
+	if version.entryType == walrecord.EntryTypeChunked {
+		return e.reconstructBatchValue(record)
+	}
+
+	if crc32.ChecksumIEEE(record.Value()) != record.CRC32Checksum {
+		return nil, ErrRecordCorrupted
+	}
+	return record.Value(), nil
+}
+
+// replayRowVersions builds the columns of the row by applying its changes till the hlc.
+// The changes are applied from the last time the row was deleted, or from the start of the WAL,
+// only if the WAL has the complete history.
+func (e *Engine) replayRowVersions(lookup versionLookup, hlc uint64) (map[string][]byte, error) {
+	start := -1
+	for i := len(lookup.versions) - 1; i >= 0; i-- {
+		if lookup.versions[i].operation == walrecord.LogOperationDeleteRow {
+			start = i
+			break
+		}
+	}
+	if start == -1 && lookup.horizon != 0 {
+		return nil, e.beforeHorizonError(hlc, lookup.horizon)
+	}
+
+	// row doesn't exist at the hlc.
+	if len(lookup.versions) == 0 || start == len(lookup.versions)-1 {
+		return nil, ErrKeyNotFound
+	}
+
+	columns := make(map[string][]byte)
+	for _, version := range lookup.versions[start+1:] {
+		record, err := e.readVersionRecord(version)
+		if err != nil {
+			return nil, err
+		}
+		switch version.operation {
+		case walrecord.LogOperationInsert:
+			maps.Copy(columns, getColumnsValue(record))
+		case walrecord.LogOperationDelete:
+			for columnKey := range getColumnsValue(record) {
+				delete(columns, columnKey)
+			}
+		}
+	}
+	return columns, nil
+}
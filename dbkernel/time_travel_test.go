@@ -0,0 +1,310 @@
+package dbkernel
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTimeTravelEngine(t *testing.T, config *EngineConfig) (*Engine, string) {
+	t.Helper()
+	baseDir := t.TempDir()
+	engine, err := NewStorageEngine(baseDir, "test_time_travel", config)
+	require.NoError(t, err)
+	return engine, baseDir
+}
+
+func TestEngine_GetAt(t *testing.T) {
+	engine, _ := newTimeTravelEngine(t, NewDefaultEngineConfig())
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+
+	key := []byte("key")
+	beforeWrite := engine.Now()
+	require.NoError(t, engine.Put(key, []byte("v1")))
+	afterV1 := engine.Now()
+	require.NoError(t, engine.Put(key, []byte("v2")))
+	afterV2 := engine.Now()
+	require.NoError(t, engine.Delete(key))
+	afterDelete := engine.Now()
+	require.NoError(t, engine.Put(key, []byte("v3")))
+
+	_, err := engine.GetAt(key, beforeWrite)
+	assert.ErrorIs(t, err, ErrKeyNotFound, "key didn't exist before the first write")
+
+	value, err := engine.GetAt(key, afterV1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), value)
+
+	value, err = engine.GetAt(key, afterV2)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v2"), value)
+
+	_, err = engine.GetAt(key, afterDelete)
+	assert.ErrorIs(t, err, ErrKeyNotFound, "key was deleted at the time")
+
+	value, err = engine.GetAt(key, engine.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v3"), value)
+
+	_, err = engine.GetAt([]byte("unknown"), engine.Now())
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestEngine_GetAt_Txn(t *testing.T) {
+	engine, _ := newTimeTravelEngine(t, NewDefaultEngineConfig())
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+
+	txn, err := engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+	require.NoError(t, err)
+	require.NoError(t, txn.AppendKVTxn([]byte("key_1"), []byte("txn_1")))
+	require.NoError(t, txn.AppendKVTxn([]byte("key_2"), []byte("txn_2")))
+	beforeCommit := engine.Now()
+	require.NoError(t, txn.Commit())
+	afterCommit := engine.Now()
+
+	_, err = engine.GetAt([]byte("key_1"), beforeCommit)
+	assert.ErrorIs(t, err, ErrKeyNotFound, "txn change should only be visible from the commit")
+
+	value, err := engine.GetAt([]byte("key_2"), afterCommit)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("txn_2"), value)
+
+	var chunks []string
+	var fullValue string
+	for range 5 {
+		chunk := gofakeit.LetterN(1024)
+		chunks = append(chunks, chunk)
+		fullValue += chunk
+	}
+	txn, err = engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeChunked)
+	require.NoError(t, err)
+	for _, chunk := range chunks {
+		require.NoError(t, txn.AppendKVTxn([]byte("key_1"), []byte(chunk)))
+	}
+	require.NoError(t, txn.Commit())
+	afterChunked := engine.Now()
+	require.NoError(t, engine.Put([]byte("key_1"), []byte("latest")))
+
+	value, err = engine.GetAt([]byte("key_1"), afterChunked)
+	assert.NoError(t, err)
+	assert.Equal(t, fullValue, string(value), "chunked value should be rebuilt from the wal")
+
+	value, err = engine.GetAt([]byte("key_1"), afterCommit)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("txn_1"), value)
+}
+
+func TestEngine_GetRowColumnsAt(t *testing.T) {
+	engine, _ := newTimeTravelEngine(t, NewDefaultEngineConfig())
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+
+	row := "row"
+	require.NoError(t, engine.SetColumnsInRow(row, map[string][]byte{"a": []byte("a1"), "b": []byte("b1")}))
+	afterInsert := engine.Now()
+	require.NoError(t, engine.SetColumnsInRow(row, map[string][]byte{"a": []byte("a2"), "c": []byte("c1")}))
+	afterUpdate := engine.Now()
+	require.NoError(t, engine.DeleteColumnsFromRow(row, map[string][]byte{"b": nil}))
+	afterColumnDelete := engine.Now()
+	require.NoError(t, engine.DeleteRow(row))
+	afterRowDelete := engine.Now()
+	require.NoError(t, engine.SetColumnsInRow(row, map[string][]byte{"d": []byte("d1")}))
+	afterReinsert := engine.Now()
+
+	tests := []struct {
+		name     string
+		hlc      uint64
+		expected map[string][]byte
+		err      error
+	}{
+		{
+			name:     "after_insert",
+			hlc:      afterInsert,
+			expected: map[string][]byte{"a": []byte("a1"), "b": []byte("b1")},
+		},
+		{
+			name:     "after_update",
+			hlc:      afterUpdate,
+			expected: map[string][]byte{"a": []byte("a2"), "b": []byte("b1"), "c": []byte("c1")},
+		},
+		{
+			name:     "after_column_delete",
+			hlc:      afterColumnDelete,
+			expected: map[string][]byte{"a": []byte("a2"), "c": []byte("c1")},
+		},
+		{
+			name: "after_row_delete",
+			hlc:  afterRowDelete,
+			err:  ErrKeyNotFound,
+		},
+		{
+			name:     "after_reinsert",
+			hlc:      afterReinsert,
+			expected: map[string][]byte{"d": []byte("d1")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, err := engine.GetRowColumnsAt(row, tt.hlc, nil)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, columns)
+		})
+	}
+
+	columns, err := engine.GetRowColumnsAt(row, afterUpdate, func(columnKey string) bool {
+		return columnKey == "c"
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"c": []byte("c1")}, columns)
+}
+
+func TestEngine_GetAt_AfterRestart(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	engine, baseDir := newTimeTravelEngine(t, config)
+
+	key := []byte("key")
+	require.NoError(t, engine.Put(key, []byte("v1")))
+	afterV1 := engine.Now()
+	require.NoError(t, engine.Put(key, []byte("v2")))
+	require.NoError(t, engine.Close(context.Background()))
+
+	engine, err := NewStorageEngine(baseDir, "test_time_travel", config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+
+	value, err := engine.GetAt(key, afterV1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), value, "wal still has the complete history")
+}
+
+func TestEngine_GetAt_BeforeRetentionHorizon(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	config.WalConfig.SegmentSize = 64 * 1024
+	engine, baseDir := newTimeTravelEngine(t, config)
+
+	key := []byte("key")
+	require.NoError(t, engine.Put(key, []byte("v1")))
+	afterV1 := engine.Now()
+	// fill enough segments, so the first one can be dropped.
+	for i := range 64 {
+		require.NoError(t, engine.Put([]byte(fmt.Sprintf("filler_%d", i)), []byte(gofakeit.LetterN(4096))))
+	}
+	require.NoError(t, engine.Put(key, []byte("v2")))
+	afterV2 := engine.Now()
+	require.NoError(t, engine.Close(context.Background()))
+
+	require.NoError(t, os.Remove(filepath.Join(baseDir, "test_time_travel", walDirName, "000000001.seg.wal")))
+
+	engine, err := NewStorageEngine(baseDir, "test_time_travel", config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+
+	_, err = engine.GetAt(key, afterV1)
+	assert.ErrorIs(t, err, ErrBeforeRetentionHorizon)
+	_, err = engine.GetRowColumnsAt("row", afterV1, nil)
+	assert.ErrorIs(t, err, ErrBeforeRetentionHorizon)
+
+	value, err := engine.GetAt(key, afterV2)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v2"), value)
+}
+
+func TestEngine_GetAt_VersionRetention(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	config.VersionRetention = 50 * time.Millisecond
+	engine, _ := newTimeTravelEngine(t, config)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+
+	key := []byte("key")
+	require.NoError(t, engine.Put(key, []byte("v1")))
+	require.NoError(t, engine.Put([]byte("other"), []byte("o1")))
+	afterV1 := engine.Now()
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, engine.Put(key, []byte("v2")))
+	afterV2 := engine.Now()
+
+	_, err := engine.GetAt(key, afterV1)
+	assert.ErrorIs(t, err, ErrBeforeRetentionHorizon)
+
+	horizon, err := engine.HistoryHorizon()
+	assert.NoError(t, err)
+	assert.Greater(t, horizon, afterV1)
+	assert.Less(t, horizon, afterV2)
+
+	// change visible at the start of the window is kept.
+	value, err := engine.GetAt(key, horizon)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), value)
+
+	value, err = engine.GetAt(key, afterV2)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v2"), value)
+
+	// key not changed in the window is read from its current value.
+	value, err = engine.GetAt([]byte("other"), afterV2)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("o1"), value)
+
+	engine.versions.mu.Lock()
+	defer engine.versions.mu.Unlock()
+	assert.NotContains(t, engine.versions.versions, "other", "keys not changed in the window should be pruned")
+	assert.Len(t, engine.versions.versions[string(key)], 2)
+}
+
+func TestEngine_GetAt_HistoryIndex(t *testing.T) {
+	config := NewDefaultEngineConfig()
+	config.HistoryIndex = true
+	engine, _ := newTimeTravelEngine(t, config)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+
+	key := []byte("key")
+	require.NoError(t, engine.Put(key, []byte("v1")))
+	afterV1 := engine.Now()
+	require.NoError(t, engine.SetColumnsInRow("row", map[string][]byte{"a": []byte("a1")}))
+	afterInsert := engine.Now()
+	flushActiveMemTable(t, engine)
+	require.NoError(t, engine.Put(key, []byte("v2")))
+	require.NoError(t, engine.SetColumnsInRow("row", map[string][]byte{"a": []byte("a2"), "b": []byte("b1")}))
+
+	value, err := engine.GetAt(key, afterV1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), value, "change should be read from the history index")
+
+	value, err = engine.GetAt(key, engine.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v2"), value, "change should be read from the wal after the cursor")
+
+	columns, err := engine.GetRowColumnsAt("row", afterInsert, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("a1")}, columns)
+
+	engine.versions.mu.Lock()
+	defer engine.versions.mu.Unlock()
+	assert.Empty(t, engine.versions.versions, "in memory version index should not be built")
+}
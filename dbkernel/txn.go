@@ -6,8 +6,11 @@ import (
 	"hash/crc32"
 	"time"
 
+	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
 	"github.com/ankur-anand/unisondb/dbkernel/wal"
 	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
 	"github.com/dgraph-io/badger/v4/y"
 	"github.com/hashicorp/go-metrics"
 	"github.com/segmentio/ksuid"
@@ -45,12 +48,26 @@ type Txn struct {
 	startTime       time.Time
 	valuesCount     int
 	checksum        uint32 // Rolling checksum
+	chunkLayout     kvdrivers.ChunkLayout
+	lastChunkSize   int
 	txnOperation    walrecord.LogOperation
 	txnEntryType    walrecord.EntryType
+	// commitLSN is the lsn of the commit record, zero till the Txn is committed.
+	commitLSN uint64
 }
 
 // NewTxn returns a new initialized batch Txn.
 func (e *Engine) NewTxn(txnType walrecord.LogOperation, valueType walrecord.EntryType) (*Txn, error) {
+	if e.following.Load() {
+		return nil, ErrReadOnlyReplica
+	}
+	if e.consensus != nil {
+		return nil, ErrConsensusTxn
+	}
+	if e.lww != nil {
+		return nil, ErrMultiLeaderTxn
+	}
+
 	if txnType == walrecord.LogOperationNoop {
 		return nil, ErrUnsupportedTxnType
 	}
@@ -67,28 +84,27 @@ func (e *Engine) NewTxn(txnType walrecord.LogOperation, valueType walrecord.Entr
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	// start the batch marker in wal
-	index := e.writeSeenCounter.Add(1)
-	record := walrecord.Record{
-		Index:         index,
-		Hlc:           HLCNow(index),
-		Key:           []byte("batch_tx_begin"),
-		Value:         nil,
-		LogOperation:  walrecord.LogOperationTxnMarker,
+	e.writeSeenCounter.Add(1)
+	lsn := e.nextLSN()
+	record := logcodec.LogRecord{
+		LSN:           lsn,
+		HLC:           e.hlc.Now(),
+		Epoch:         e.epoch.Load(),
+		OperationType: logrecord.LogOperationTypeTxnMarker,
 		TxnID:         uuid,
-		TxnStatus:     walrecord.TxnStatusBegin,
-		EntryType:     valueType,
-		PrevTxnOffset: nil,
+		TxnState:      logrecord.TransactionStateBegin,
+		EntryType:     logrecord.LogEntryType(valueType),
+		Payload:       kvPayload([]byte("batch_tx_begin"), nil),
 	}
 
-	encoded, err := record.FBEncode()
-	if err != nil {
-		return nil, err
-	}
+	encoded := record.Encode()
 
 	offset, err := e.walIO.Append(encoded)
 	if err != nil {
 		return nil, err
 	}
+	e.appendedLSN(lsn, offset)
+	e.openTxns[string(uuid)] = lsn
 
 	metrics.IncrCounterWithLabels(mTxnKeyBeginTotal, 1, e.metricsLabel)
 	return &Txn{
@@ -126,27 +142,24 @@ func (t *Txn) AppendKVTxn(key []byte, value []byte) error {
 
 	t.engine.mu.Lock()
 	defer t.engine.mu.Unlock()
-	index := t.engine.writeSeenCounter.Add(1)
-
-	record := &walrecord.Record{
-		Index:         index,
-		Hlc:           HLCNow(index),
-		Key:           key,
-		Value:         value,
-		LogOperation:  t.txnOperation,
-		TxnID:         t.txnID,
-		TxnStatus:     walrecord.TxnStatusPrepare,
-		EntryType:     t.txnEntryType,
-		PrevTxnOffset: t.lastPos,
+	t.engine.writeSeenCounter.Add(1)
+	lsn := t.engine.nextLSN()
+
+	record := &logcodec.LogRecord{
+		LSN:             lsn,
+		HLC:             t.engine.hlc.Now(),
+		Epoch:           t.engine.epoch.Load(),
+		CRC32Checksum:   crc32.ChecksumIEEE(value),
+		OperationType:   logrecord.LogOperationType(t.txnOperation),
+		TxnID:           t.txnID,
+		TxnState:        logrecord.TransactionStatePrepare,
+		EntryType:       logrecord.LogEntryType(t.txnEntryType),
+		PrevTxnWalIndex: t.lastPos.Encode(),
+		Payload:         kvPayload(key, value),
 	}
 
-	// Encode and compress WAL record
-	encoded, err := record.FBEncode()
-
-	if err != nil {
-		t.err = err
-		return err
-	}
+	// Encode WAL record
+	encoded := record.Encode()
 
 	// Write to WAL
 	offset, err := t.engine.walIO.Append(encoded)
@@ -155,9 +168,14 @@ func (t *Txn) AppendKVTxn(key []byte, value []byte) error {
 		t.err = err
 		return err
 	}
+	t.engine.appendedLSN(lsn, offset)
 
 	t.lastPos = offset
 
+	if t.txnEntryType == walrecord.EntryTypeChunked {
+		t.trackChunkLayout(value)
+	}
+
 	// for chunked type we just dataStore the last offset.
 	if t.txnEntryType != walrecord.EntryTypeChunked {
 		var memValue y.ValueStruct
@@ -179,6 +197,18 @@ func (t *Txn) AppendKVTxn(key []byte, value []byte) error {
 	return nil
 }
 
+// trackChunkLayout records the size of the appended chunk, chunk size is only kept
+// if every chunk except the last one is of the same size.
+func (t *Txn) trackChunkLayout(value []byte) {
+	t.chunkLayout.ValueSize += uint64(len(value))
+	if t.valuesCount == 0 {
+		t.chunkLayout.ChunkSize = uint32(len(value))
+	} else if t.lastChunkSize != int(t.chunkLayout.ChunkSize) {
+		t.chunkLayout.ChunkSize = 0
+	}
+	t.lastChunkSize = len(value)
+}
+
 // AppendColumnTxn appends the Columns update type Txn to wal for the provided rowKey.
 // Update/Delete Ops for column is decided by the Log Operation type.
 // Single Txn Cannot contain both update and delete ops.
@@ -197,28 +227,23 @@ func (t *Txn) AppendColumnTxn(rowKey []byte, columnEntries map[string][]byte) er
 
 	t.engine.mu.Lock()
 	defer t.engine.mu.Unlock()
-	index := t.engine.writeSeenCounter.Add(1)
-
-	record := &walrecord.Record{
-		Index:         index,
-		Hlc:           HLCNow(index),
-		Key:           rowKey,
-		Value:         nil,
-		LogOperation:  t.txnOperation,
-		TxnID:         t.txnID,
-		TxnStatus:     walrecord.TxnStatusPrepare,
-		EntryType:     t.txnEntryType,
-		PrevTxnOffset: t.lastPos,
-		ColumnEntries: columnEntries,
+	t.engine.writeSeenCounter.Add(1)
+	lsn := t.engine.nextLSN()
+
+	record := &logcodec.LogRecord{
+		LSN:             lsn,
+		HLC:             t.engine.hlc.Now(),
+		Epoch:           t.engine.epoch.Load(),
+		OperationType:   logrecord.LogOperationType(t.txnOperation),
+		TxnID:           t.txnID,
+		TxnState:        logrecord.TransactionStatePrepare,
+		EntryType:       logrecord.LogEntryType(t.txnEntryType),
+		PrevTxnWalIndex: t.lastPos.Encode(),
+		Payload:         rowPayload(rowKey, columnEntries),
 	}
 
-	// Encode and compress WAL record
-	encoded, err := record.FBEncode()
-
-	if err != nil {
-		t.err = err
-		return err
-	}
+	// Encode WAL record
+	encoded := record.Encode()
 
 	// Write to WAL
 	offset, err := t.engine.walIO.Append(encoded)
@@ -227,6 +252,7 @@ func (t *Txn) AppendColumnTxn(rowKey []byte, columnEntries map[string][]byte) er
 		t.err = err
 		return err
 	}
+	t.engine.appendedLSN(lsn, offset)
 
 	t.lastPos = offset
 
@@ -250,41 +276,65 @@ func (t *Txn) AppendColumnTxn(rowKey []byte, columnEntries map[string][]byte) er
 }
 
 // Commit the Txn.
+// With the sync replicas configured, it returns once they have acknowledged the commit as durable.
 func (t *Txn) Commit() error {
+	lsn, err := t.commit()
+	if err != nil {
+		return err
+	}
+	t.commitLSN = lsn
+	return t.engine.waitForSyncReplicas(lsn)
+}
+
+// CommitLSN returns the lsn of the commit record of the Txn, zero till it's committed.
+func (t *Txn) CommitLSN() uint64 {
+	return t.commitLSN
+}
+
+// commit writes the commit record of the Txn and its entries to the mem-table, and returns the lsn of the commit record.
+func (t *Txn) commit() (uint64, error) {
 	if t.err != nil {
-		return t.err
+		return 0, t.err
+	}
+
+	value := marshalChecksum(t.checksum)
+	if t.txnEntryType == walrecord.EntryTypeChunked {
+		t.chunkLayout.Checksum = t.checksum
+		if t.chunkLayout.ChunkSize == 0 {
+			t.chunkLayout.ValueSize = 0
+		}
+		value = marshalChunkedCommit(t.chunkLayout)
 	}
 
 	t.engine.mu.Lock()
 	defer t.engine.mu.Unlock()
-	index := t.engine.writeSeenCounter.Add(1)
-	record := &walrecord.Record{
-		Index:         index,
-		Hlc:           HLCNow(index),
-		Key:           t.rowKey,
-		Value:         marshalChecksum(t.checksum),
-		LogOperation:  t.txnOperation,
-		TxnID:         t.txnID,
-		TxnStatus:     walrecord.TxnStatusCommit,
-		EntryType:     t.txnEntryType,
-		PrevTxnOffset: t.lastPos,
-	}
-
-	// Encode and compress WAL record
-	encoded, err := record.FBEncode()
-
-	if err != nil {
-		t.err = err
-		return err
+	t.engine.writeSeenCounter.Add(1)
+	lsn := t.engine.nextLSN()
+	record := &logcodec.LogRecord{
+		LSN:             lsn,
+		HLC:             t.engine.hlc.Now(),
+		Epoch:           t.engine.epoch.Load(),
+		CRC32Checksum:   crc32.ChecksumIEEE(value),
+		OperationType:   logrecord.LogOperationType(t.txnOperation),
+		TxnID:           t.txnID,
+		TxnState:        logrecord.TransactionStateCommit,
+		EntryType:       logrecord.LogEntryType(t.txnEntryType),
+		PrevTxnWalIndex: t.lastPos.Encode(),
+		Payload:         kvPayload(t.rowKey, value),
 	}
 
+	// Encode WAL record
+	encoded := record.Encode()
+
 	// Write to WAL
 	offset, err := t.engine.walIO.Append(encoded)
 
 	if err != nil {
 		t.err = err
-		return err
+		return 0, err
 	}
+	t.engine.appendedLSN(lsn, offset)
+	delete(t.engine.openTxns, string(t.txnID))
 
 	defer func() {
 		metrics.IncrCounterWithLabels(mTxnKeyCommitedTotal, 1, t.engine.metricsLabel)
@@ -304,11 +354,11 @@ func (t *Txn) Commit() error {
 
 	if mErr != nil {
 		t.err = mErr
-		return mErr
+		return 0, mErr
 	}
 
 	t.err = ErrTxnAlreadyCommitted
-	return nil
+	return lsn, nil
 }
 
 func (t *Txn) memWriteChunk(encoded []byte) error {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"hash/crc32"
+	"io"
 	"testing"
 
 	"github.com/ankur-anand/unisondb/dbkernel"
@@ -196,3 +197,154 @@ func TestTxn_Batch_KV_Commit(t *testing.T) {
 	})
 
 }
+
+func TestTxn_Chunked_GetReader(t *testing.T) {
+	baseDir := t.TempDir()
+	namespace := "test__txn_get_reader"
+
+	conf := dbkernel.NewDefaultEngineConfig()
+	conf.BtreeConfig.Namespace = namespace
+
+	engine, err := dbkernel.NewStorageEngine(baseDir, namespace, conf)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	key := []byte(gofakeit.Name())
+	fullValue := new(bytes.Buffer)
+
+	txn, err := engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeChunked)
+	assert.NoError(t, err, "NewBatch operation should succeed")
+
+	var checksum uint32
+	for i := 0; i < 10; i++ {
+		chunk := []byte(gofakeit.Sentence(50))
+		fullValue.Write(chunk)
+		checksum = crc32.Update(checksum, crc32.IEEETable, chunk)
+		assert.NoError(t, txn.AppendKVTxn(key, chunk), "Append operation should succeed")
+	}
+
+	_, err = engine.GetReader(key)
+	assert.ErrorIs(t, err, dbkernel.ErrKeyNotFound, "uncommitted value should not be visible")
+	assert.NoError(t, txn.Commit(), "Commit operation should succeed")
+
+	smallKey := []byte(gofakeit.UUID())
+	smallValue := []byte(gofakeit.Sentence(10))
+	assert.NoError(t, engine.Put(smallKey, smallValue))
+
+	verify := func(t *testing.T, engine *dbkernel.Engine) {
+		reader, err := engine.GetReader(key)
+		assert.NoError(t, err, "GetReader operation should succeed")
+		assert.Equal(t, 10, reader.ChunkCount(), "reader should stream chunk by chunk")
+		assert.Equal(t, checksum, reader.Checksum())
+		got, err := io.ReadAll(reader)
+		assert.NoError(t, err, "streamed read should succeed")
+		assert.Equal(t, fullValue.Bytes(), got, "streamed value should match the inserted value")
+		assert.NoError(t, reader.Close())
+
+		reader, err = engine.GetReader(smallKey)
+		assert.NoError(t, err, "GetReader operation should succeed")
+		got, err = io.ReadAll(reader)
+		assert.NoError(t, err, "streamed read should succeed")
+		assert.Equal(t, smallValue, got, "streamed value should match the inserted value")
+		assert.NoError(t, reader.Close())
+	}
+
+	t.Run("wal_reader", func(t *testing.T) {
+		verify(t, engine)
+	})
+
+	// on reopen the value is recovered into the btree store.
+	assert.NoError(t, engine.Close(ctx))
+	engine, err = dbkernel.NewStorageEngine(baseDir, namespace, conf)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		err := engine.Close(ctx)
+		if err != nil {
+			t.Errorf("Failed to close engine: %v", err)
+		}
+	})
+
+	t.Run("btree_reader", func(t *testing.T) {
+		verify(t, engine)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		assert.NoError(t, engine.Delete(key))
+		_, err := engine.GetReader(key)
+		assert.ErrorIs(t, err, dbkernel.ErrKeyNotFound)
+	})
+}
+
+func TestTxn_Chunked_GetRange(t *testing.T) {
+	baseDir := t.TempDir()
+	namespace := "test__txn_get_range"
+
+	conf := dbkernel.NewDefaultEngineConfig()
+	conf.BtreeConfig.Namespace = namespace
+
+	engine, err := dbkernel.NewStorageEngine(baseDir, namespace, conf)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	key := []byte(gofakeit.Name())
+	fullValue := new(bytes.Buffer)
+
+	txn, err := engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeChunked)
+	assert.NoError(t, err, "NewBatch operation should succeed")
+
+	for i := 0; i < 10; i++ {
+		chunk := []byte(gofakeit.LetterN(64))
+		fullValue.Write(chunk)
+		assert.NoError(t, txn.AppendKVTxn(key, chunk), "Append operation should succeed")
+	}
+	// last chunk is larger than the others.
+	chunk := []byte(gofakeit.LetterN(200))
+	fullValue.Write(chunk)
+	assert.NoError(t, txn.AppendKVTxn(key, chunk), "Append operation should succeed")
+	assert.NoError(t, txn.Commit(), "Commit operation should succeed")
+
+	smallKey := []byte(gofakeit.UUID())
+	smallValue := []byte(gofakeit.LetterN(100))
+	assert.NoError(t, engine.Put(smallKey, smallValue))
+
+	verify := func(t *testing.T, engine *dbkernel.Engine) {
+		value := fullValue.Bytes()
+		got, err := engine.GetRange(key, 100, 200)
+		assert.NoError(t, err, "GetRange operation should succeed")
+		assert.Equal(t, value[100:300], got, "range should match the inserted value")
+
+		got, err = engine.GetRange(key, 600, 0)
+		assert.NoError(t, err, "GetRange operation should succeed")
+		assert.Equal(t, value[600:], got, "open range should read till the end")
+
+		got, err = engine.GetRange(key, 760, 50)
+		assert.NoError(t, err, "GetRange operation should succeed")
+		assert.Equal(t, value[760:810], got, "range within the last chunk should match the inserted value")
+
+		_, err = engine.GetRange(key, int64(len(value)), 1)
+		assert.ErrorIs(t, err, dbkernel.ErrInvalidRange)
+
+		got, err = engine.GetRange(smallKey, 10, 5)
+		assert.NoError(t, err, "GetRange operation should succeed")
+		assert.Equal(t, smallValue[10:15], got, "range should match the inserted value")
+	}
+
+	t.Run("wal_range", func(t *testing.T) {
+		verify(t, engine)
+	})
+
+	// on reopen the value is recovered into the btree store.
+	assert.NoError(t, engine.Close(ctx))
+	engine, err = dbkernel.NewStorageEngine(baseDir, namespace, conf)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		err := engine.Close(ctx)
+		if err != nil {
+			t.Errorf("Failed to close engine: %v", err)
+		}
+	})
+
+	t.Run("btree_range", func(t *testing.T) {
+		verify(t, engine)
+	})
+}
@@ -3,10 +3,13 @@ package dbkernel
 import (
 	"encoding/binary"
 	"fmt"
+	"maps"
+	"slices"
 	"time"
 
+	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
 	"github.com/ankur-anand/unisondb/dbkernel/wal"
-	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
 	"github.com/dgraph-io/badger/v4/y"
 	"github.com/prometheus/common/helpers/templates"
 )
@@ -14,9 +17,9 @@ import (
 // handleChunkedValuesTxn saves all the chunked value that is part of the current commit txn.
 // to the provided btree based dataStore.
 // extracted in util as both memTable and wal recovery instance uses it.
-func handleChunkedValuesTxn(record *walrecord.WalRecord, walIO *wal.WalIO, store BTreeStore) (int, error) {
-	checksum := unmarshalChecksum(record.ValueBytes())
-	records, err := walIO.GetTransactionRecords(wal.DecodeOffset(record.PrevTxnWalIndexBytes()))
+func handleChunkedValuesTxn(record *logcodec.LogRecord, walIO *wal.WalIO, store BTreeStore) (int, error) {
+	checksum := unmarshalChecksum(record.Value())
+	records, err := walIO.GetTransactionRecords(wal.DecodeOffset(record.PrevTxnWalIndex))
 	if err != nil {
 		return 0, fmt.Errorf("failed to reconstruct batch value: %w", err)
 	}
@@ -26,50 +29,32 @@ func handleChunkedValuesTxn(record *walrecord.WalRecord, walIO *wal.WalIO, store
 
 	values := make([][]byte, len(preparedRecords))
 	for i, record := range preparedRecords {
-		values[i] = record.ValueBytes()
+		values[i] = record.Value()
 	}
 
-	return len(records), store.SetChunks(record.KeyBytes(), values, checksum)
+	return len(records), store.SetChunks(record.Key(), values, checksum)
 }
 
-// handleColumnValuesTxn saves all the column value that is part of the current commit txn.
-// to the provided btree based dataStore.
-// extracted in util as both memTable and wal recovery instance uses it.
-func handleColumnValuesTxn(record *walrecord.WalRecord, walIO *wal.WalIO, store BTreeStore) (int, error) {
-	records, err := walIO.GetTransactionRecords(wal.DecodeOffset(record.PrevTxnWalIndexBytes()))
-	if err != nil {
-		return 0, fmt.Errorf("failed to reconstruct batch value: %w", err)
-	}
-
-	// remove the begins part from the
-	preparedRecords := records[1:]
-
-	rowKeys := make([][]byte, 0)
-	rowColumns := make([]map[string][]byte, 0)
-
-	// as we are iterating from the reverse, we will eventually have correct state
-	// even if common columns are modified.
-	for _, record := range preparedRecords {
-		rowKeys = append(rowKeys, record.KeyBytes())
-		columnLen := record.ColumnsLength()
-		columnEntries := make(map[string][]byte, columnLen)
-		for i := 0; i < columnLen; i++ {
-			var columnEntry walrecord.ColumnEntry
-			record.Columns(&columnEntry, i)
-			columnEntries[string(columnEntry.ColumnName())] = columnEntry.ColumnValueBytes()
-		}
-		rowColumns = append(rowColumns, columnEntries)
+// kvPayload returns the payload of a record with the single key value.
+func kvPayload(key, value []byte) logcodec.LogOperationData {
+	return logcodec.LogOperationData{
+		KeyValueBatchEntries: &logcodec.KeyValueBatchEntries{
+			Entries: []logcodec.KeyValueEntry{{Key: key, Value: value}},
+		},
 	}
+}
 
-	if record.Operation() == walrecord.LogOperationInsert {
-		return len(records), store.SetManyRowColumns(rowKeys, rowColumns)
+// rowPayload returns the payload of a record with the columns of the single row.
+func rowPayload(rowKey []byte, columnEntries map[string][]byte) logcodec.LogOperationData {
+	columns := make([]logcodec.ColumnData, 0, len(columnEntries))
+	for _, name := range slices.Sorted(maps.Keys(columnEntries)) {
+		columns = append(columns, logcodec.ColumnData{Name: name, Value: columnEntries[name]})
 	}
-
-	if record.Operation() == walrecord.LogOperationDelete {
-		return len(records), store.DeleteManyRowColumns(rowKeys, rowColumns)
+	return logcodec.LogOperationData{
+		RowUpdateEntries: &logcodec.RowUpdateEntries{
+			Entries: []logcodec.RowUpdateEntry{{Key: rowKey, Columns: columns}},
+		},
 	}
-
-	return 0, nil
 }
 
 func getValueStruct(ops byte, direct bool, value []byte) y.ValueStruct {
@@ -100,30 +85,20 @@ func buildColumnMap(columnEntries map[string][]byte, vs []y.ValueStruct, walIO *
 		}
 		switch v.Meta {
 		case logOperationInsert:
-			for _, ce := range extractColumns(record) {
-				columnEntries[string(ce.ColumnName())] = ce.ColumnValueBytes()
+			for _, column := range record.Columns() {
+				columnEntries[column.Name] = column.Value
 			}
 		case logOperationDelete:
-			for _, ce := range extractColumns(record) {
-				delete(columnEntries, string(ce.ColumnName()))
+			for _, column := range record.Columns() {
+				delete(columnEntries, column.Name)
 			}
+		case logOperationDeleteRow:
+			clear(columnEntries)
 		}
 	}
 	return nil
 }
 
-// extractColumns gets all the column entries from the walrecord.
-func extractColumns(record *walrecord.WalRecord) []walrecord.ColumnEntry {
-	var columnEntries []walrecord.ColumnEntry
-	columnLen := record.ColumnsLength()
-	for i := 0; i < columnLen; i++ {
-		var columnEntry walrecord.ColumnEntry
-		record.Columns(&columnEntry, i)
-		columnEntries = append(columnEntries, columnEntry)
-	}
-	return columnEntries
-}
-
 // decodeChunkPositionWithValue decodes a MemTable entry into either a ChunkPosition (WAL lookup) or a direct value.
 func decodeChunkPositionWithValue(data []byte) (*wal.Offset, []byte, error) {
 	if len(data) == 0 {
@@ -147,14 +122,14 @@ func decodeChunkPositionWithValue(data []byte) (*wal.Offset, []byte, error) {
 }
 
 // getWalRecord returns the underlying wal record.
-func getWalRecord(entry y.ValueStruct, wIO *wal.WalIO) (*walrecord.WalRecord, error) {
+func getWalRecord(entry y.ValueStruct, wIO *wal.WalIO) (*logcodec.LogRecord, error) {
 	chunkPos, value, err := decodeChunkPositionWithValue(entry.Value)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode chunk position: %w", err)
 	}
 
 	if chunkPos == nil {
-		return walrecord.GetRootAsWalRecord(value, 0), nil
+		return logcodec.DecodeRecord(value)
 	}
 
 	walValue, err := wIO.Read(chunkPos)
@@ -162,7 +137,26 @@ func getWalRecord(entry y.ValueStruct, wIO *wal.WalIO) (*walrecord.WalRecord, er
 		return nil, fmt.Errorf("failed to read WAL for chunk position: %w", err)
 	}
 
-	return walrecord.GetRootAsWalRecord(walValue, 0), nil
+	record, err := logcodec.DecodeRecord(walValue)
+	if err != nil || !record.IsBatch() {
+		return record, err
+	}
+	return record.BatchRecord(decodeBatchReference(entry.Value[1:]))
+}
+
+// encodeBatchReference encodes the reference to the i-th operation of the batch record at the offset.
+// The offset is encoded with the fixed size, so it can be filled in place once the record is appended.
+func encodeBatchReference(offset *wal.Offset, i int) []byte {
+	return binary.AppendUvarint(offset.EncodeFixedSize(), uint64(i))
+}
+
+// decodeBatchReference returns the position of the operation in the batch record from the reference.
+func decodeBatchReference(data []byte) int {
+	i, n := binary.Uvarint(data[len((&wal.Offset{}).EncodeFixedSize()):])
+	if n <= 0 {
+		return 0
+	}
+	return int(i)
 }
 
 func marshalChecksum(checksum uint32) []byte {
@@ -171,6 +165,31 @@ func marshalChecksum(checksum uint32) []byte {
 	return buf
 }
 
+// marshalChunkedCommit encodes the value of the commit record of a chunked txn.
+// Checksum is kept first, so the value remains readable by unmarshalChecksum.
+func marshalChunkedCommit(layout kvdrivers.ChunkLayout) []byte {
+	buf := make([]byte, 16)
+	binary.LittleEndian.PutUint32(buf, layout.Checksum)
+	binary.LittleEndian.PutUint32(buf[4:], layout.ChunkSize)
+	binary.LittleEndian.PutUint64(buf[8:], layout.ValueSize)
+	return buf
+}
+
+// unmarshalChunkedCommit decodes the layout of a chunked txn from its commit record value
+// and the number of chunks in the txn. Commit records that only carry the checksum
+// decode with zero ChunkSize.
+func unmarshalChunkedCommit(data []byte, chunkCount int) kvdrivers.ChunkLayout {
+	layout := kvdrivers.ChunkLayout{
+		ChunkCount: uint32(chunkCount),
+		Checksum:   unmarshalChecksum(data),
+	}
+	if len(data) >= 16 {
+		layout.ChunkSize = binary.LittleEndian.Uint32(data[4:8])
+		layout.ValueSize = binary.LittleEndian.Uint64(data[8:16])
+	}
+	return layout
+}
+
 func unmarshalChecksum(data []byte) uint32 {
 	if len(data) < 4 {
 		return 0
@@ -0,0 +1,307 @@
+package dbkernel
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal"
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
+)
+
+// keyVersion is a single change of a key, as present in the WAL.
+type keyVersion struct {
+	// hlc and index of the record, for txn it's of the commit record as the change is visible from there.
+	hlc   uint64
+	index uint64
+	// offset of the record holding the change, for the chunked txn it's the commit record.
+	offset *wal.Offset
+	// entry is the position of the change in the batch record, zero for the other records.
+	entry int
+	// prepared is the position of the prepared record holding the change in the txn, zero for the other records.
+	prepared  int
+	operation walrecord.LogOperation
+	entryType walrecord.EntryType
+	// merged are the earlier prepared records of the same row txn, whose columns
+	// are merged into the change, set only by dedupeVersions.
+	merged []keyVersion
+}
+
+// pendingTxnRecord is a prepared record of a txn that is not yet committed.
+type pendingTxnRecord struct {
+	key    string
+	offset *wal.Offset
+	hlc    uint64
+}
+
+// DefaultVersionRetention is how far back from the last change the in memory version index keeps the changes.
+const DefaultVersionRetention = 24 * time.Hour
+
+// versionPruneSteps is the number of steps the retention window is pruned in, as it moves forward.
+const versionPruneSteps = 16
+
+// versionIndex maps every key to its changes present in the WAL, in the order they were written.
+// It's built lazily, every lookup first indexes the records appended to the WAL since the last one.
+// Only the changes made in the retention window before the last change indexed are kept,
+// along with the one each key had at the start of the window.
+type versionIndex struct {
+	mu       sync.Mutex
+	versions map[string][]keyVersion
+	pending  map[string][]pendingTxnRecord
+	// lastOffset is the offset of the last record indexed, nil if nothing is indexed yet.
+	lastOffset *wal.Offset
+	scanned    bool
+	// horizon is the hlc from which the index has every change, zero if it has the complete history.
+	horizon uint64
+	// retention is in milliseconds, every change is kept if zero.
+	retention uint64
+	// cut is the hlc the changes before were last pruned at.
+	cut uint64
+	// pruned is the last cut that dropped any change, zero if none did.
+	pruned uint64
+}
+
+// newVersionIndex returns a version index that keeps the changes made in the retention window,
+// every change is kept if the retention is not positive.
+func newVersionIndex(retention time.Duration) *versionIndex {
+	vi := &versionIndex{
+		versions: make(map[string][]keyVersion),
+		pending:  make(map[string][]pendingTxnRecord),
+	}
+	if retention > 0 {
+		vi.retention = uint64(retention.Milliseconds())
+	}
+	return vi
+}
+
+// versionLookup is the result of a lookup of the key at an hlc.
+type versionLookup struct {
+	// versions of the key till the hlc, including it.
+	versions []keyVersion
+	// changedAfter is true if the key has any change after the hlc.
+	changedAfter bool
+	horizon      uint64
+}
+
+// lookup returns the changes of the key till the hlc, after indexing the WAL till its end,
+// or till the record at the offset, if provided.
+// emptyHorizon returns the horizon if the WAL is found empty on the first lookup.
+func (vi *versionIndex) lookup(walIO *wal.WalIO, key []byte, hlc uint64, till *wal.Offset, emptyHorizon func() uint64) (versionLookup, error) {
+	vi.mu.Lock()
+	defer vi.mu.Unlock()
+
+	if err := vi.catchUp(walIO, till, emptyHorizon); err != nil {
+		return versionLookup{}, err
+	}
+
+	versions := vi.versions[string(key)]
+	i := sort.Search(len(versions), func(i int) bool {
+		return versions[i].hlc > hlc
+	})
+	return versionLookup{
+		versions:     versions[:i:i],
+		changedAfter: i < len(versions),
+		horizon:      vi.horizon,
+	}, nil
+}
+
+// catchUp indexes the records appended to the WAL since the last catchUp, till the end of the WAL
+// or till the record at the offset, if provided.
+func (vi *versionIndex) catchUp(walIO *wal.WalIO, till *wal.Offset, emptyHorizon func() uint64) error {
+	var reader *wal.Reader
+	var err error
+	if vi.lastOffset == nil {
+		reader, err = walIO.NewReader()
+	} else {
+		reader, err = walIO.NewReaderWithStart(vi.lastOffset)
+	}
+	if err != nil {
+		return err
+	}
+
+	if vi.lastOffset != nil {
+		// first value is the last indexed record.
+		if _, _, err := reader.Next(); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
+
+	eof := false
+	for {
+		value, pos, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			eof = true
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("index wal versions failed: %w", err)
+		}
+		if till != nil && isNewChunkPosition(pos, till) {
+			break
+		}
+
+		record, err := logcodec.DecodeRecord(value)
+		if err != nil {
+			return fmt.Errorf("index wal versions failed: %w", err)
+		}
+		if !vi.scanned {
+			vi.scanned = true
+			// first write a namespace ever receives has the index 1, if the WAL starts with it
+			// it has every change, else the changes before its first record are lost.
+			if record.LSN != 1 {
+				vi.horizon = record.HLC
+			}
+		}
+		if err := vi.index(walIO, record, pos); err != nil {
+			return err
+		}
+		vi.lastOffset = pos
+		vi.advance(record.HLC)
+	}
+
+	if !vi.scanned && eof {
+		vi.scanned = true
+		vi.horizon = emptyHorizon()
+	}
+	return nil
+}
+
+func (vi *versionIndex) index(walIO *wal.WalIO, record *logcodec.LogRecord, pos *wal.Offset) error {
+	version := keyVersion{
+		hlc:       record.HLC,
+		index:     record.LSN,
+		offset:    pos,
+		operation: walrecord.LogOperation(record.OperationType),
+		entryType: walrecord.EntryType(record.EntryType),
+	}
+
+	if record.IsBatch() {
+		records, err := record.BatchRecords()
+		if err != nil {
+			return fmt.Errorf("index wal versions failed: %w", err)
+		}
+		for i, bRecord := range records {
+			version.entry = i
+			version.operation = walrecord.LogOperation(bRecord.OperationType)
+			version.entryType = walrecord.EntryType(bRecord.EntryType)
+			vi.add(string(bRecord.Key()), version)
+		}
+		return nil
+	}
+
+	switch record.TxnState {
+	case logrecord.TransactionStateNone:
+		if isDataOperation(record) {
+			vi.add(string(record.Key()), version)
+		}
+	case logrecord.TransactionStatePrepare:
+		txnID := string(record.TxnID)
+		vi.pending[txnID] = append(vi.pending[txnID], pendingTxnRecord{key: string(record.Key()), offset: pos, hlc: record.HLC})
+	case logrecord.TransactionStateCommit:
+		txnID := string(record.TxnID)
+		prepared, ok := vi.pending[txnID]
+		delete(vi.pending, txnID)
+		if record.EntryType == logrecord.LogEntryTypeChunked {
+			vi.add(string(record.Key()), version)
+			return nil
+		}
+		if !ok {
+			// txn began before the record the indexing started from, or was pruned.
+			var err error
+			prepared, err = preparedTxnRecords(walIO, record)
+			if err != nil {
+				return err
+			}
+		}
+		for i, p := range prepared {
+			version.offset = p.offset
+			version.prepared = i
+			vi.add(p.key, version)
+		}
+	}
+	return nil
+}
+
+// preparedTxnRecords returns the prepared records of the txn, by following the commit record back to its begin.
+func preparedTxnRecords(walIO *wal.WalIO, commit *logcodec.LogRecord) ([]pendingTxnRecord, error) {
+	offsets, err := walIO.GetTransactionOffsets(wal.DecodeOffset(commit.PrevTxnWalIndex))
+	if err != nil {
+		return nil, fmt.Errorf("index wal versions failed: %w", err)
+	}
+
+	var prepared []pendingTxnRecord
+	for _, offset := range offsets {
+		data, err := walIO.Read(offset)
+		if err != nil {
+			return nil, fmt.Errorf("index wal versions failed: %w", err)
+		}
+		record, err := logcodec.DecodeRecord(data)
+		if err != nil {
+			return nil, fmt.Errorf("index wal versions failed: %w", err)
+		}
+		if record.TxnState != logrecord.TransactionStatePrepare {
+			continue
+		}
+		prepared = append(prepared, pendingTxnRecord{key: string(record.Key()), offset: offset})
+	}
+	return prepared, nil
+}
+
+func (vi *versionIndex) add(key string, version keyVersion) {
+	vi.versions[key] = append(vi.versions[key], version)
+}
+
+// advance moves the retention window to end at the hlc, once it has moved by a step it's pruned.
+func (vi *versionIndex) advance(hlc uint64) {
+	physical := hlc >> hlcLogicalBits
+	if vi.retention == 0 || physical <= vi.retention {
+		return
+	}
+	cut := (physical - vi.retention) << hlcLogicalBits
+	if cut-min(cut, vi.cut) < (vi.retention/versionPruneSteps)<<hlcLogicalBits {
+		return
+	}
+	vi.prune(cut)
+}
+
+// prune drops the changes made before the cut, except the last one of each key that changed after it.
+// Keys that didn't change after the cut are dropped, their current value is the one they had at the cut.
+// Once any change is dropped, the index only has every change from the cut.
+func (vi *versionIndex) prune(cut uint64) {
+	vi.cut = cut
+	dropped := false
+	for key, versions := range vi.versions {
+		i := sortSearchVersions(versions, cut)
+		switch {
+		case i == len(versions):
+			delete(vi.versions, key)
+			dropped = true
+		case i > 1:
+			vi.versions[key] = slices.Clone(versions[i-1:])
+			dropped = true
+		}
+	}
+	if dropped {
+		vi.pruned = cut
+		vi.horizon = max(vi.horizon, cut)
+	}
+	// commit of a pruned txn reads its prepared records from the WAL.
+	for txnID, prepared := range vi.pending {
+		if prepared[0].hlc < cut {
+			delete(vi.pending, txnID)
+		}
+	}
+}
+
+// prunedHorizon returns the hlc the changes before were last dropped at, zero if none is dropped yet.
+func (vi *versionIndex) prunedHorizon() uint64 {
+	vi.mu.Lock()
+	defer vi.mu.Unlock()
+	return vi.pruned
+}
@@ -0,0 +1,50 @@
+package dbkernel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionIndex_Prune(t *testing.T) {
+	hlcAt := func(ms uint64) uint64 {
+		return ms << hlcLogicalBits
+	}
+
+	vi := newVersionIndex(100 * time.Millisecond)
+	vi.scanned = true
+	for _, ms := range []uint64{1000, 1010, 1200} {
+		vi.add("key", keyVersion{hlc: hlcAt(ms)})
+	}
+	vi.add("old", keyVersion{hlc: hlcAt(1000)})
+	vi.add("new", keyVersion{hlc: hlcAt(1150)})
+	vi.pending["old_txn"] = []pendingTxnRecord{{key: "key", offset: &wal.Offset{}, hlc: hlcAt(1000)}}
+	vi.pending["new_txn"] = []pendingTxnRecord{{key: "key", offset: &wal.Offset{}, hlc: hlcAt(1150)}}
+
+	// nothing is before the cut, so the index still has every change.
+	vi.advance(hlcAt(1100))
+	assert.Equal(t, hlcAt(1000), vi.cut)
+	assert.Zero(t, vi.prunedHorizon())
+	assert.Zero(t, vi.horizon)
+
+	// not moved by a step yet.
+	vi.advance(hlcAt(1103))
+	assert.Equal(t, hlcAt(1000), vi.cut)
+
+	vi.advance(hlcAt(1210))
+	assert.Equal(t, hlcAt(1110), vi.prunedHorizon())
+	assert.Equal(t, hlcAt(1110), vi.horizon)
+	assert.Equal(t, []keyVersion{{hlc: hlcAt(1010)}, {hlc: hlcAt(1200)}}, vi.versions["key"],
+		"last change before the cut should be kept")
+	assert.NotContains(t, vi.versions, "old")
+	assert.Contains(t, vi.versions, "new")
+	assert.NotContains(t, vi.pending, "old_txn")
+	assert.Contains(t, vi.pending, "new_txn")
+
+	vi = newVersionIndex(0)
+	vi.add("key", keyVersion{hlc: hlcAt(1000)})
+	vi.advance(hlcAt(1 << 40))
+	assert.Len(t, vi.versions["key"], 1, "every change should be kept without retention")
+}
@@ -0,0 +1,463 @@
+// Package faultfs provides an in memory vfs.FS that can simulate a machine crash,
+// it's meant for the crash consistency tests of the storage engine.
+//
+// Every file keeps the content that was made durable by the last Sync along with its current content.
+// A Crash drops everything that was not synced, or with CrashTorn keeps only a random part of it,
+// the way a write could have been torn by a power loss.
+// Directory operations, like creating, renaming and removing a file, are always durable.
+package faultfs
+
+import (
+	"errors"
+	"io"
+	iofs "io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/vfs"
+)
+
+var (
+	// ErrCrashed is returned by a file handle that was opened before the last crash.
+	ErrCrashed = errors.New("faultfs: file handle invalidated by crash")
+	// ErrInjected is a convenient error to be injected with FailSync.
+	ErrInjected = errors.New("faultfs: injected fault")
+)
+
+type node struct {
+	data    []byte
+	synced  []byte
+	modTime time.Time
+}
+
+// FS is an in memory vfs.FS with fault injection.
+type FS struct {
+	mu    sync.Mutex
+	files map[string]*node
+	dirs  map[string]time.Time
+	// generation is incremented on every crash, handles of an older generation are invalid.
+	generation uint64
+	syncErr    error
+}
+
+var _ vfs.FS = (*FS)(nil)
+
+// New returns an empty FS.
+func New() *FS {
+	return &FS{
+		files: make(map[string]*node),
+		dirs:  map[string]time.Time{"/": time.Now(), ".": time.Now()},
+	}
+}
+
+// FailSync makes every Sync and SyncDir return err, till it's called again with nil.
+// Data written before the failed Sync stays unsynced.
+func (f *FS) FailSync(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.syncErr = err
+}
+
+// Crash simulates a machine crash, all the unsynced data is lost.
+// Handles opened before the crash return ErrCrashed from there on.
+func (f *FS) Crash() {
+	f.crash(func(n *node) int { return 0 })
+}
+
+// CrashTorn simulates a machine crash in the middle of writing the unsynced data back to the disk.
+// For every file, unsynced content is kept till a random byte offset inside it, and the synced content after it.
+func (f *FS) CrashTorn(rnd *rand.Rand) {
+	f.crash(func(n *node) int {
+		common := n.syncedPrefix()
+		return common + rnd.IntN(len(n.data)-common+1)
+	})
+}
+
+func (f *FS) crash(tornAt func(n *node) int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.generation++
+	f.syncErr = nil
+
+	// iterate in a stable order, so a seeded crash is reproducible.
+	names := make([]string, 0, len(f.files))
+	for name := range f.files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		n := f.files[name]
+		at := tornAt(n)
+		content := append([]byte(nil), n.data[:at]...)
+		if len(n.synced) > at {
+			content = append(content, n.synced[at:]...)
+		}
+		n.data = content
+		n.synced = append([]byte(nil), content...)
+	}
+}
+
+// UnsyncedBytes returns the number of bytes of the named file that would be lost on a crash.
+func (f *FS) UnsyncedBytes(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, ok := f.files[filepath.Clean(name)]
+	if !ok {
+		return 0
+	}
+	return max(len(n.data), len(n.synced)) - n.syncedPrefix()
+}
+
+// syncedPrefix returns the length of the content that is same in the current and the synced data.
+func (n *node) syncedPrefix() int {
+	common := 0
+	for common < len(n.data) && common < len(n.synced) && n.data[common] == n.synced[common] {
+		common++
+	}
+	return common
+}
+
+func (f *FS) OpenFile(name string, flag int, perm os.FileMode) (vfs.File, error) {
+	name = filepath.Clean(name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.dirs[name]; ok {
+		if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+			return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrInvalid}
+		}
+		return &file{fs: f, name: name, dir: true, generation: f.generation}, nil
+	}
+
+	n, ok := f.files[name]
+	switch {
+	case ok && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrExist}
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrNotExist}
+	case !ok:
+		if _, ok := f.dirs[filepath.Dir(name)]; !ok {
+			return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrNotExist}
+		}
+		n = &node{modTime: time.Now()}
+		f.files[name] = n
+	}
+
+	if flag&os.O_TRUNC != 0 {
+		n.data = n.data[:0:0]
+	}
+
+	return &file{
+		fs:         f,
+		name:       name,
+		node:       n,
+		flag:       flag,
+		generation: f.generation,
+	}, nil
+}
+
+func (f *FS) Remove(name string) error {
+	name = filepath.Clean(name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.files[name]; ok {
+		delete(f.files, name)
+		return nil
+	}
+	if _, ok := f.dirs[name]; !ok {
+		return &iofs.PathError{Op: "remove", Path: name, Err: iofs.ErrNotExist}
+	}
+	if len(f.children(name)) > 0 {
+		return &iofs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+	}
+	delete(f.dirs, name)
+	return nil
+}
+
+func (f *FS) Rename(oldpath, newpath string) error {
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n, ok := f.files[oldpath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: iofs.ErrNotExist}
+	}
+	if _, ok := f.dirs[filepath.Dir(newpath)]; !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: iofs.ErrNotExist}
+	}
+	delete(f.files, oldpath)
+	f.files[newpath] = n
+	return nil
+}
+
+func (f *FS) MkdirAll(path string, perm os.FileMode) error {
+	path = filepath.Clean(path)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for p := path; ; p = filepath.Dir(p) {
+		if _, ok := f.files[p]; ok {
+			return &iofs.PathError{Op: "mkdir", Path: p, Err: iofs.ErrExist}
+		}
+		if _, ok := f.dirs[p]; ok {
+			break
+		}
+		f.dirs[p] = time.Now()
+	}
+	return nil
+}
+
+func (f *FS) ReadDir(name string) ([]os.DirEntry, error) {
+	name = filepath.Clean(name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.dirs[name]; !ok {
+		return nil, &iofs.PathError{Op: "readdir", Path: name, Err: iofs.ErrNotExist}
+	}
+	children := f.children(name)
+	entries := make([]os.DirEntry, 0, len(children))
+	for _, child := range children {
+		entries = append(entries, iofs.FileInfoToDirEntry(f.stat(child)))
+	}
+	return entries, nil
+}
+
+func (f *FS) Stat(name string) (os.FileInfo, error) {
+	name = filepath.Clean(name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info := f.stat(name)
+	if info == nil {
+		return nil, &iofs.PathError{Op: "stat", Path: name, Err: iofs.ErrNotExist}
+	}
+	return info, nil
+}
+
+func (f *FS) SyncDir(name string) error {
+	name = filepath.Clean(name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.dirs[name]; !ok {
+		return &iofs.PathError{Op: "sync", Path: name, Err: iofs.ErrNotExist}
+	}
+	return f.syncErr
+}
+
+// children returns the sorted paths of the direct children of the dir.
+func (f *FS) children(dir string) []string {
+	var children []string
+	add := func(p string) {
+		if p != dir && filepath.Dir(p) == dir {
+			children = append(children, p)
+		}
+	}
+	for p := range f.files {
+		add(p)
+	}
+	for p := range f.dirs {
+		add(p)
+	}
+	slices.Sort(children)
+	return children
+}
+
+func (f *FS) stat(name string) os.FileInfo {
+	if n, ok := f.files[name]; ok {
+		return &fileInfo{name: filepath.Base(name), size: int64(len(n.data)), mode: 0644, modTime: n.modTime}
+	}
+	if modTime, ok := f.dirs[name]; ok {
+		return &fileInfo{name: filepath.Base(name), mode: iofs.ModeDir | 0755, modTime: modTime}
+	}
+	return nil
+}
+
+type file struct {
+	fs         *FS
+	name       string
+	node       *node
+	dir        bool
+	flag       int
+	offset     int64
+	generation uint64
+	closed     bool
+}
+
+// check validates the handle, it must be called with the fs lock held.
+func (h *file) check(op string) error {
+	switch {
+	case h.closed:
+		return &iofs.PathError{Op: op, Path: h.name, Err: iofs.ErrClosed}
+	case h.generation != h.fs.generation:
+		return &iofs.PathError{Op: op, Path: h.name, Err: ErrCrashed}
+	}
+	return nil
+}
+
+func (h *file) Name() string {
+	return h.name
+}
+
+func (h *file) Read(p []byte) (int, error) {
+	h.fs.mu.Lock()
+	defer h.fs.mu.Unlock()
+	if err := h.check("read"); err != nil {
+		return 0, err
+	}
+	if h.dir {
+		return 0, &iofs.PathError{Op: "read", Path: h.name, Err: iofs.ErrInvalid}
+	}
+	if h.offset >= int64(len(h.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, h.node.data[h.offset:])
+	h.offset += int64(n)
+	return n, nil
+}
+
+func (h *file) ReadAt(p []byte, off int64) (int, error) {
+	h.fs.mu.Lock()
+	defer h.fs.mu.Unlock()
+	if err := h.check("read"); err != nil {
+		return 0, err
+	}
+	if h.dir {
+		return 0, &iofs.PathError{Op: "read", Path: h.name, Err: iofs.ErrInvalid}
+	}
+	if off >= int64(len(h.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, h.node.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (h *file) Write(p []byte) (int, error) {
+	h.fs.mu.Lock()
+	defer h.fs.mu.Unlock()
+	if err := h.check("write"); err != nil {
+		return 0, err
+	}
+	if h.dir || h.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, &iofs.PathError{Op: "write", Path: h.name, Err: iofs.ErrPermission}
+	}
+
+	if h.flag&os.O_APPEND != 0 {
+		h.offset = int64(len(h.node.data))
+	}
+	end := h.offset + int64(len(p))
+	if end > int64(len(h.node.data)) {
+		h.node.data = append(h.node.data, make([]byte, end-int64(len(h.node.data)))...)
+	}
+	copy(h.node.data[h.offset:], p)
+	h.offset = end
+	h.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (h *file) Seek(offset int64, whence int) (int64, error) {
+	h.fs.mu.Lock()
+	defer h.fs.mu.Unlock()
+	if err := h.check("seek"); err != nil {
+		return 0, err
+	}
+
+	var size int64
+	if !h.dir {
+		size = int64(len(h.node.data))
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += h.offset
+	case io.SeekEnd:
+		offset += size
+	default:
+		return 0, &iofs.PathError{Op: "seek", Path: h.name, Err: iofs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &iofs.PathError{Op: "seek", Path: h.name, Err: iofs.ErrInvalid}
+	}
+	h.offset = offset
+	return offset, nil
+}
+
+func (h *file) Stat() (os.FileInfo, error) {
+	h.fs.mu.Lock()
+	defer h.fs.mu.Unlock()
+	if err := h.check("stat"); err != nil {
+		return nil, err
+	}
+	if h.dir {
+		return &fileInfo{name: filepath.Base(h.name), mode: iofs.ModeDir | 0755, modTime: h.fs.dirs[h.name]}, nil
+	}
+	return &fileInfo{name: filepath.Base(h.name), size: int64(len(h.node.data)), mode: 0644, modTime: h.node.modTime}, nil
+}
+
+func (h *file) Truncate(size int64) error {
+	h.fs.mu.Lock()
+	defer h.fs.mu.Unlock()
+	if err := h.check("truncate"); err != nil {
+		return err
+	}
+	if h.dir || h.flag&(os.O_WRONLY|os.O_RDWR) == 0 || size < 0 {
+		return &iofs.PathError{Op: "truncate", Path: h.name, Err: iofs.ErrInvalid}
+	}
+	if size <= int64(len(h.node.data)) {
+		h.node.data = h.node.data[:size:size]
+	} else {
+		h.node.data = append(h.node.data, make([]byte, size-int64(len(h.node.data)))...)
+	}
+	h.node.modTime = time.Now()
+	return nil
+}
+
+func (h *file) Sync() error {
+	h.fs.mu.Lock()
+	defer h.fs.mu.Unlock()
+	if err := h.check("sync"); err != nil {
+		return err
+	}
+	if h.fs.syncErr != nil {
+		return &iofs.PathError{Op: "sync", Path: h.name, Err: h.fs.syncErr}
+	}
+	if !h.dir {
+		h.node.synced = append(h.node.synced[:0:0], h.node.data...)
+	}
+	return nil
+}
+
+func (h *file) Close() error {
+	h.fs.mu.Lock()
+	defer h.fs.mu.Unlock()
+	if h.closed {
+		return &iofs.PathError{Op: "close", Path: h.name, Err: iofs.ErrClosed}
+	}
+	h.closed = true
+	return nil
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) Mode() os.FileMode  { return i.mode }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *fileInfo) Sys() any           { return nil }
@@ -0,0 +1,126 @@
+package faultfs_test
+
+import (
+	"io"
+	"math/rand/v2"
+	"os"
+	"testing"
+
+	"github.com/ankur-anand/unisondb/dbkernel/vfs"
+	"github.com/ankur-anand/unisondb/dbkernel/vfs/faultfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, fs *faultfs.FS, name string, data []byte, sync bool) vfs.File {
+	t.Helper()
+	f, err := fs.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write(data)
+	require.NoError(t, err)
+	if sync {
+		require.NoError(t, f.Sync())
+	}
+	return f
+}
+
+func TestFS_Crash_DropsUnsynced(t *testing.T) {
+	fs := faultfs.New()
+	require.NoError(t, fs.MkdirAll("/data/wal", os.ModePerm))
+
+	f := writeFile(t, fs, "/data/wal/1.log", []byte("synced"), true)
+	_, err := f.Write([]byte("_unsynced"))
+	require.NoError(t, err)
+	assert.Equal(t, 9, fs.UnsyncedBytes("/data/wal/1.log"))
+
+	fs.Crash()
+
+	data, err := vfs.ReadFile(fs, "/data/wal/1.log")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("synced"), data)
+
+	_, err = f.Write([]byte("after"))
+	assert.ErrorIs(t, err, faultfs.ErrCrashed, "handles opened before the crash are invalid")
+
+	entries, err := fs.ReadDir("/data/wal")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "1.log", entries[0].Name())
+}
+
+func TestFS_CrashTorn(t *testing.T) {
+	for seed := range uint64(20) {
+		fs := faultfs.New()
+		f := writeFile(t, fs, "/1.log", []byte("synced"), true)
+		_, err := f.Write([]byte("_unsynced_data"))
+		require.NoError(t, err)
+
+		fs.CrashTorn(rand.New(rand.NewPCG(seed, seed)))
+
+		data, err := vfs.ReadFile(fs, "/1.log")
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, len(data), len("synced"), "synced data should never be lost")
+		assert.Equal(t, []byte("synced_unsynced_data")[:len(data)], data, "only a prefix of the write should survive")
+	}
+}
+
+func TestFS_FailSync(t *testing.T) {
+	fs := faultfs.New()
+	f := writeFile(t, fs, "/1.log", []byte("synced"), true)
+	_, err := f.Write([]byte("_lost"))
+	require.NoError(t, err)
+
+	fs.FailSync(faultfs.ErrInjected)
+	assert.ErrorIs(t, f.Sync(), faultfs.ErrInjected)
+	assert.ErrorIs(t, fs.SyncDir("/"), faultfs.ErrInjected)
+
+	fs.Crash()
+	data, err := vfs.ReadFile(fs, "/1.log")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("synced"), data)
+
+	// crash clears the injected fault.
+	f = writeFile(t, fs, "/1.log", []byte("_again"), true)
+	assert.NoError(t, f.Close())
+}
+
+func TestFS_FileOperations(t *testing.T) {
+	fs := faultfs.New()
+
+	_, err := fs.OpenFile("/missing/1.log", os.O_CREATE|os.O_RDWR, 0644)
+	assert.True(t, os.IsNotExist(err), "parent dir should exist")
+	_, err = fs.Stat("/missing")
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, fs.MkdirAll("/data", os.ModePerm))
+	f := writeFile(t, fs, "/data/1.log", []byte("hello world"), false)
+
+	buf := make([]byte, 5)
+	n, err := f.ReadAt(buf, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, "world", string(buf[:n]))
+	_, err = f.ReadAt(buf, 8)
+	assert.ErrorIs(t, err, io.EOF)
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), offset)
+
+	assert.NoError(t, f.Truncate(5))
+	info, err := f.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), info.Size())
+	assert.NoError(t, f.Close())
+	assert.Error(t, f.Close())
+
+	assert.NoError(t, fs.Rename("/data/1.log", "/data/2.log"))
+	_, err = fs.Stat("/data/1.log")
+	assert.True(t, os.IsNotExist(err))
+	data, err := vfs.ReadFile(fs, "/data/2.log")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello"), data)
+
+	assert.Error(t, fs.Remove("/data"), "dir is not empty")
+	assert.NoError(t, fs.Remove("/data/2.log"))
+	assert.NoError(t, fs.Remove("/data"))
+}
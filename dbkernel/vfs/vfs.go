@@ -0,0 +1,109 @@
+// Package vfs is the filesystem abstraction used by the storage engine for all of its file IO,
+// so the same engine can run on the disk or on a virtual filesystem in tests.
+package vfs
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrUnsupportedFS is returned by a component that can only work on the os filesystem.
+var ErrUnsupportedFS = errors.New("vfs: unsupported filesystem")
+
+// File is an open file of a FS.
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.Seeker
+	io.Closer
+
+	Name() string
+	Stat() (os.FileInfo, error)
+	Truncate(size int64) error
+	// Sync makes the written data of the file durable.
+	Sync() error
+}
+
+// FS is the set of filesystem operations used by the storage engine.
+type FS interface {
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Remove(name string) error
+	Rename(oldpath, newpath string) error
+	MkdirAll(path string, perm os.FileMode) error
+	ReadDir(name string) ([]os.DirEntry, error)
+	Stat(name string) (os.FileInfo, error)
+	// SyncDir makes the entries of the directory, like a newly created or renamed file, durable.
+	SyncDir(name string) error
+}
+
+// Default is the FS backed by the os filesystem.
+var Default FS = osFS{}
+
+// OrDefault returns fs, or Default if fs is nil.
+func OrDefault(fs FS) FS {
+	if fs == nil {
+		return Default
+	}
+	return fs
+}
+
+// IsDefault reports if fs is the os filesystem.
+func IsDefault(fs FS) bool {
+	return fs == nil || fs == Default
+}
+
+// Open opens the named file for reading.
+func Open(fs FS, name string) (File, error) {
+	return fs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// ReadFile reads the whole named file.
+func ReadFile(fs FS, name string) ([]byte, error) {
+	f, err := Open(fs, name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+type osFS struct{}
+
+func (osFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (osFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (osFS) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (osFS) ReadDir(name string) ([]os.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (osFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) SyncDir(name string) error {
+	d, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
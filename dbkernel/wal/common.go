@@ -3,7 +3,8 @@ package wal
 import (
 	"time"
 
-	"github.com/ankur-anand/wal"
+	"github.com/ankur-anand/unisondb/dbkernel/vfs"
+	"github.com/ankur-anand/unisondb/dbkernel/wal/internal/seglog"
 )
 
 var (
@@ -12,19 +13,24 @@ var (
 
 // Default permission values.
 const (
-	defaultBytesPerSync = 1 * wal.MB  // 1MB
-	defaultSegmentSize  = 16 * wal.MB // 16MB
+	defaultBytesPerSync = 1 * seglog.MB  // 1MB
+	defaultSegmentSize  = 16 * seglog.MB // 16MB
 	defaultSyncInterval = 1 * time.Second
 )
 
-func newWALOptions(dirPath string, c *Config) wal.Options {
-	return wal.Options{
+const (
+	segmentFileExt = ".seg.wal"
+)
+
+func newWALOptions(dirPath string, c *Config) seglog.Options {
+	return seglog.Options{
 		DirPath:        dirPath,
 		SegmentSize:    c.SegmentSize,
-		SegmentFileExt: ".seg.wal",
+		SegmentFileExt: segmentFileExt,
 		Sync:           c.FSync,
 		SyncInterval:   c.SyncInterval,
 		BytesPerSync:   c.BytesPerSync,
+		FS:             c.FS,
 	}
 }
 
@@ -40,6 +46,11 @@ type Config struct {
 	FSync bool `toml:"fsync"`
 	// call FSync with at this interval.
 	SyncInterval time.Duration `toml:"sync_interval"`
+	// RecoveryMode decides how corruption found in the wal on open is handled,
+	// torn tail of the last segment is always truncated. Default is RecoveryModeStrict.
+	RecoveryMode RecoveryMode `toml:"recovery_mode"`
+	// FS is the filesystem the segments are stored on, vfs.Default if nil.
+	FS vfs.FS `toml:"-"`
 }
 
 func NewDefaultConfig() *Config {
@@ -48,6 +59,7 @@ func NewDefaultConfig() *Config {
 		SegmentSize:  defaultSegmentSize,
 		SyncInterval: defaultSyncInterval,
 		FSync:        false,
+		RecoveryMode: RecoveryModeStrict,
 	}
 }
 
@@ -65,4 +77,8 @@ func (c *Config) applyDefaults() {
 	if c.SyncInterval == 0 {
 		c.SyncInterval = defaultSyncInterval
 	}
+	if c.RecoveryMode == "" {
+		c.RecoveryMode = RecoveryModeStrict
+	}
+	c.FS = vfs.OrDefault(c.FS)
 }
@@ -0,0 +1,625 @@
+package seglog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel/vfs"
+)
+
+const (
+	initialSegmentFileID = 1
+)
+
+var (
+	ErrValueTooLarge       = errors.New("the data size can't larger than segment size")
+	ErrPendingSizeTooLarge = errors.New("the upper bound of pendingWrites can't larger than segment size")
+)
+
+// WAL represents a Write-Ahead Log structure that provides durability
+// and fault-tolerance for incoming writes.
+// It consists of an activeSegment, which is the current segment file
+// used for new incoming writes, and olderSegments,
+// which is a map of segment files used for read operations.
+//
+// The options field stores various configuration options for the WAL.
+//
+// The mu sync.RWMutex is used for concurrent access to the WAL data structure,
+// ensuring safe access and modification.
+type WAL struct {
+	activeSegment     *segment               // active segment file, used for new incoming writes.
+	olderSegments     map[SegmentID]*segment // older segment files, only used for read.
+	options           Options
+	fs                vfs.FS
+	mu                sync.RWMutex
+	bytesWrite        uint32
+	renameIds         []SegmentID
+	pendingWrites     [][]byte
+	pendingSize       int64
+	pendingWritesLock sync.Mutex
+	closeC            chan struct{}
+	syncTicker        *time.Ticker
+}
+
+// Reader represents a reader for the WAL.
+// It consists of segmentReaders, which is a slice of segmentReader
+// structures sorted by segment id,
+// and currentReader, which is the index of the current segmentReader in the slice.
+//
+// The currentReader field is used to iterate over the segmentReaders slice.
+type Reader struct {
+	segmentReaders []*segmentReader
+	currentReader  int
+}
+
+// Open opens a WAL with the given options.
+// It will create the directory if not exists, and open all segment files in the directory.
+// If there is no segment file in the directory, it will create a new one.
+func Open(options Options) (*WAL, error) {
+	if !strings.HasPrefix(options.SegmentFileExt, ".") {
+		return nil, fmt.Errorf("segment file extension must start with '.'")
+	}
+	wal := &WAL{
+		options:       options,
+		fs:            vfs.OrDefault(options.FS),
+		olderSegments: make(map[SegmentID]*segment),
+		pendingWrites: make([][]byte, 0),
+		closeC:        make(chan struct{}),
+	}
+
+	// create the directory if not exists.
+	if err := wal.fs.MkdirAll(options.DirPath, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	// iterate the dir and open all segment files.
+	entries, err := wal.fs.ReadDir(options.DirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// get all segment file ids.
+	var segmentIDs []int
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		var id int
+		_, err := fmt.Sscanf(entry.Name(), "%d"+options.SegmentFileExt, &id)
+		if err != nil {
+			continue
+		}
+		segmentIDs = append(segmentIDs, id)
+	}
+
+	// empty directory, just initialize a new segment file.
+	if len(segmentIDs) == 0 {
+		segment, err := openSegmentFile(wal.fs, options.DirPath, options.SegmentFileExt,
+			initialSegmentFileID)
+		if err != nil {
+			return nil, err
+		}
+		wal.activeSegment = segment
+	} else {
+		// open the segment files in order, get the max one as the active segment file.
+		sort.Ints(segmentIDs)
+
+		for i, segId := range segmentIDs {
+			segment, err := openSegmentFile(wal.fs, options.DirPath, options.SegmentFileExt,
+				uint32(segId))
+			if err != nil {
+				return nil, err
+			}
+			if i == len(segmentIDs)-1 {
+				wal.activeSegment = segment
+			} else {
+				wal.olderSegments[segment.id] = segment
+			}
+		}
+	}
+
+	// only start the sync operation if the SyncInterval is greater than 0.
+	if wal.options.SyncInterval > 0 {
+		wal.syncTicker = time.NewTicker(wal.options.SyncInterval)
+		go func() {
+			for {
+				select {
+				case <-wal.syncTicker.C:
+					_ = wal.Sync()
+				case <-wal.closeC:
+					wal.syncTicker.Stop()
+					return
+				}
+			}
+		}()
+	}
+
+	return wal, nil
+}
+
+// SegmentFileName returns the file name of a segment file.
+func SegmentFileName(dirPath string, extName string, id SegmentID) string {
+	return filepath.Join(dirPath, fmt.Sprintf("%09d"+extName, id))
+}
+
+// OpenNewActiveSegment opens a new segment file
+// and sets it as the active segment file.
+// It is used when even the active segment file is not full,
+// but the records after it should go to a new segment file.
+func (wal *WAL) OpenNewActiveSegment() error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+	// sync the active segment file.
+	if err := wal.activeSegment.Sync(); err != nil {
+		return err
+	}
+	// create a new segment file and set it as the active one.
+	segment, err := openSegmentFile(wal.fs, wal.options.DirPath, wal.options.SegmentFileExt,
+		wal.activeSegment.id+1)
+	if err != nil {
+		return err
+	}
+	wal.olderSegments[wal.activeSegment.id] = wal.activeSegment
+	wal.activeSegment = segment
+	return nil
+}
+
+// ActiveSegmentID returns the id of the active segment file.
+func (wal *WAL) ActiveSegmentID() SegmentID {
+	wal.mu.RLock()
+	defer wal.mu.RUnlock()
+
+	return wal.activeSegment.id
+}
+
+// SegmentIDs returns the ids of the segment files in ascending order, the active one is the last.
+func (wal *WAL) SegmentIDs() []SegmentID {
+	wal.mu.RLock()
+	defer wal.mu.RUnlock()
+
+	ids := make([]SegmentID, 0, len(wal.olderSegments)+1)
+	for id := range wal.olderSegments {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return append(ids, wal.activeSegment.id)
+}
+
+// IsPastEnd returns whether the position is at or after the end of the WAL,
+// either in a segment after the active one or past the size of the active segment.
+func (wal *WAL) IsPastEnd(pos *ChunkPosition) bool {
+	wal.mu.RLock()
+	defer wal.mu.RUnlock()
+
+	if pos.SegmentId != wal.activeSegment.id {
+		return pos.SegmentId > wal.activeSegment.id
+	}
+	return int64(pos.BlockNumber)*blockSize+pos.ChunkOffset >= wal.activeSegment.Size()
+}
+
+// IsEmpty returns whether the WAL is empty.
+// Only there is only one empty active segment file, which means the WAL is empty.
+func (wal *WAL) IsEmpty() bool {
+	wal.mu.RLock()
+	defer wal.mu.RUnlock()
+
+	return len(wal.olderSegments) == 0 && wal.activeSegment.Size() == 0
+}
+
+// SetIsStartupTraversal should only be set while the WAL is read once at the startup.
+// Notice that if it's set to true, only one reader can read the data from the WAL
+// (Single Thread).
+func (wal *WAL) SetIsStartupTraversal(v bool) {
+	for _, seg := range wal.olderSegments {
+		seg.isStartupTraversal = v
+	}
+	wal.activeSegment.isStartupTraversal = v
+}
+
+// NewReaderWithMax returns a new reader for the WAL,
+// and the reader will only read the data from the segment file
+// whose id is less than or equal to the given segId.
+func (wal *WAL) NewReaderWithMax(segId SegmentID) *Reader {
+	wal.mu.RLock()
+	defer wal.mu.RUnlock()
+	return wal.newReaderWithMax(segId)
+}
+
+// newReaderWithMax is NewReaderWithMax for the callers already holding the lock,
+// a recursive read lock deadlocks if a writer is waiting on it.
+func (wal *WAL) newReaderWithMax(segId SegmentID) *Reader {
+	// get all segment readers.
+	var segmentReaders []*segmentReader
+	for _, segment := range wal.olderSegments {
+		if segId == 0 || segment.id <= segId {
+			reader := segment.NewReader()
+			segmentReaders = append(segmentReaders, reader)
+		}
+	}
+	if segId == 0 || wal.activeSegment.id <= segId {
+		reader := wal.activeSegment.NewReader()
+		segmentReaders = append(segmentReaders, reader)
+	}
+
+	// sort the segment readers by segment id.
+	sort.Slice(segmentReaders, func(i, j int) bool {
+		return segmentReaders[i].segment.id < segmentReaders[j].segment.id
+	})
+
+	return &Reader{
+		segmentReaders: segmentReaders,
+		currentReader:  0,
+	}
+}
+
+// NewReaderWithStart returns a new reader for the WAL,
+// and the reader will only read the data from the segment file
+// whose position is greater than or equal to the given position.
+func (wal *WAL) NewReaderWithStart(startPos *ChunkPosition) (*Reader, error) {
+	if startPos == nil {
+		return nil, errors.New("start position is nil")
+	}
+	wal.mu.RLock()
+	defer wal.mu.RUnlock()
+
+	reader := wal.newReaderWithMax(0)
+	for {
+		// skip the segment readers whose id is less than the given position's segment id.
+		if reader.CurrentSegmentId() < startPos.SegmentId {
+			reader.SkipCurrentSegment()
+			continue
+		}
+		// skip the chunk whose position is less than the given position.
+		currentPos := reader.CurrentChunkPosition()
+		if currentPos.SegmentId > startPos.SegmentId ||
+			currentPos.BlockNumber > startPos.BlockNumber ||
+			(currentPos.BlockNumber == startPos.BlockNumber && currentPos.ChunkOffset >= startPos.ChunkOffset) {
+			break
+		}
+		// call Next to find again.
+		if _, _, err := reader.Next(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+	}
+	return reader, nil
+}
+
+// NewReader returns a new reader for the WAL.
+// It will iterate all segment files and read all data from them.
+func (wal *WAL) NewReader() *Reader {
+	return wal.NewReaderWithMax(0)
+}
+
+// Next returns the next chunk data and its position in the WAL.
+// If there is no data, io.EOF will be returned.
+//
+// The position can be used to read the data from the segment file.
+func (r *Reader) Next() ([]byte, *ChunkPosition, error) {
+	if r.currentReader >= len(r.segmentReaders) {
+		return nil, nil, io.EOF
+	}
+
+	data, position, err := r.segmentReaders[r.currentReader].Next()
+	if err == io.EOF {
+		r.currentReader++
+		return r.Next()
+	}
+	return data, position, err
+}
+
+// SkipCurrentSegment skips the current segment file
+// when reading the WAL.
+func (r *Reader) SkipCurrentSegment() {
+	r.currentReader++
+}
+
+// CurrentSegmentId returns the id of the current segment file
+// when reading the WAL.
+func (r *Reader) CurrentSegmentId() SegmentID {
+	return r.segmentReaders[r.currentReader].segment.id
+}
+
+// CurrentChunkPosition returns the position of the current chunk data
+func (r *Reader) CurrentChunkPosition() *ChunkPosition {
+	reader := r.segmentReaders[r.currentReader]
+	return &ChunkPosition{
+		SegmentId:   reader.segment.id,
+		BlockNumber: reader.blockNumber,
+		ChunkOffset: reader.chunkOffset,
+	}
+}
+
+// ClearPendingWrites clear pendingWrite and reset pendingSize
+func (wal *WAL) ClearPendingWrites() {
+	wal.pendingWritesLock.Lock()
+	defer wal.pendingWritesLock.Unlock()
+
+	wal.pendingSize = 0
+	wal.pendingWrites = wal.pendingWrites[:0]
+}
+
+// PendingWrites add data to wal.pendingWrites and wait for batch write.
+// If the data in pendingWrites exceeds the size of one segment,
+// it will return a 'ErrPendingSizeTooLarge' error and clear the pendingWrites.
+func (wal *WAL) PendingWrites(data []byte) {
+	wal.pendingWritesLock.Lock()
+	defer wal.pendingWritesLock.Unlock()
+
+	size := wal.maxDataWriteSize(int64(len(data)))
+	wal.pendingSize += size
+	wal.pendingWrites = append(wal.pendingWrites, data)
+}
+
+// rotateActiveSegment create a new segment file and replace the activeSegment.
+func (wal *WAL) rotateActiveSegment() error {
+	if err := wal.activeSegment.Sync(); err != nil {
+		return err
+	}
+	wal.bytesWrite = 0
+	segment, err := openSegmentFile(wal.fs, wal.options.DirPath, wal.options.SegmentFileExt,
+		wal.activeSegment.id+1)
+	if err != nil {
+		return err
+	}
+	wal.olderSegments[wal.activeSegment.id] = wal.activeSegment
+	wal.activeSegment = segment
+	return nil
+}
+
+// WriteAll write wal.pendingWrites to WAL and then clear pendingWrites,
+// it will not sync the segment file based on wal.options, you should call Sync() manually.
+func (wal *WAL) WriteAll() ([]*ChunkPosition, error) {
+	if len(wal.pendingWrites) == 0 {
+		return make([]*ChunkPosition, 0), nil
+	}
+
+	wal.mu.Lock()
+	defer func() {
+		wal.ClearPendingWrites()
+		wal.mu.Unlock()
+	}()
+
+	// if the pending size is still larger than segment size, return error
+	if wal.pendingSize > wal.options.SegmentSize {
+		return nil, ErrPendingSizeTooLarge
+	}
+
+	// if the active segment file is full, sync it and create a new one.
+	if wal.activeSegment.Size()+wal.pendingSize > wal.options.SegmentSize {
+		if err := wal.rotateActiveSegment(); err != nil {
+			return nil, err
+		}
+	}
+
+	// write all data to the active segment file.
+	positions, err := wal.activeSegment.writeAll(wal.pendingWrites)
+	if err != nil {
+		return nil, err
+	}
+
+	return positions, nil
+}
+
+// Write writes the data to the WAL.
+// Actually, it writes the data to the active segment file.
+// It returns the position of the data in the WAL, and an error if any.
+func (wal *WAL) Write(data []byte) (*ChunkPosition, error) {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+	if int64(len(data))+chunkHeaderSize > wal.options.SegmentSize {
+		return nil, ErrValueTooLarge
+	}
+	// if the active segment file is full, sync it and create a new one.
+	if wal.isFull(int64(len(data))) {
+		if err := wal.rotateActiveSegment(); err != nil {
+			return nil, err
+		}
+	}
+
+	// write the data to the active segment file.
+	position, err := wal.activeSegment.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// update the bytesWrite field.
+	wal.bytesWrite += position.ChunkSize
+
+	// sync the active segment file if needed.
+	var needSync = wal.options.Sync
+	if !needSync && wal.options.BytesPerSync > 0 {
+		needSync = wal.bytesWrite >= wal.options.BytesPerSync
+	}
+	if needSync {
+		if err := wal.activeSegment.Sync(); err != nil {
+			return nil, err
+		}
+		wal.bytesWrite = 0
+	}
+
+	return position, nil
+}
+
+// Read reads the data from the WAL according to the given position.
+func (wal *WAL) Read(pos *ChunkPosition) ([]byte, error) {
+	wal.mu.RLock()
+	defer wal.mu.RUnlock()
+
+	// find the segment file according to the position.
+	var segment *segment
+	if pos.SegmentId == wal.activeSegment.id {
+		segment = wal.activeSegment
+	} else {
+		segment = wal.olderSegments[pos.SegmentId]
+	}
+
+	if segment == nil {
+		return nil, fmt.Errorf("segment file %d%s not found", pos.SegmentId, wal.options.SegmentFileExt)
+	}
+
+	// read the data from the segment file.
+	return segment.Read(pos.BlockNumber, pos.ChunkOffset)
+}
+
+// BytesAfter returns the bytes written to the segment files after the chunk at the given position,
+// every byte of the segment files if the position is nil.
+func (wal *WAL) BytesAfter(pos *ChunkPosition) int64 {
+	wal.mu.RLock()
+	defer wal.mu.RUnlock()
+
+	var size int64
+	segments := append(make([]*segment, 0, len(wal.olderSegments)+1), wal.activeSegment)
+	for _, segment := range wal.olderSegments {
+		segments = append(segments, segment)
+	}
+	for _, segment := range segments {
+		switch {
+		case pos == nil || segment.id > pos.SegmentId:
+			size += segment.Size()
+		case segment.id == pos.SegmentId:
+			end := int64(pos.BlockNumber)*blockSize + pos.ChunkOffset + int64(pos.ChunkSize)
+			size += max(0, segment.Size()-end)
+		}
+	}
+	return size
+}
+
+// Close closes the WAL.
+func (wal *WAL) Close() error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
+	select {
+	case <-wal.closeC:
+		// channel is already closed
+	default:
+		close(wal.closeC)
+	}
+
+	// close all segment files.
+	for _, segment := range wal.olderSegments {
+		if err := segment.Close(); err != nil {
+			return err
+		}
+		wal.renameIds = append(wal.renameIds, segment.id)
+	}
+	wal.olderSegments = nil
+
+	wal.renameIds = append(wal.renameIds, wal.activeSegment.id)
+	// close the active segment file.
+	return wal.activeSegment.Close()
+}
+
+// Delete deletes all segment files of the WAL.
+func (wal *WAL) Delete() error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
+	// delete all segment files.
+	for _, segment := range wal.olderSegments {
+		if err := segment.Remove(); err != nil {
+			return err
+		}
+	}
+	wal.olderSegments = nil
+
+	// delete the active segment file.
+	return wal.activeSegment.Remove()
+}
+
+// Sync syncs the active segment file to stable storage like disk.
+func (wal *WAL) Sync() error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
+	return wal.activeSegment.Sync()
+}
+
+// Truncate removes every chunk at or after the given position, deleting
+// the newer segment files and making the segment of the position the active one.
+func (wal *WAL) Truncate(pos *ChunkPosition) error {
+	if pos == nil {
+		return errors.New("truncate position is nil")
+	}
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
+	target := wal.activeSegment
+	if pos.SegmentId != target.id {
+		seg, ok := wal.olderSegments[pos.SegmentId]
+		if !ok {
+			return fmt.Errorf("truncate segment %d: %w", pos.SegmentId, os.ErrNotExist)
+		}
+		target = seg
+	}
+
+	if target != wal.activeSegment {
+		if err := wal.activeSegment.Remove(); err != nil {
+			return err
+		}
+		for id, seg := range wal.olderSegments {
+			if id <= pos.SegmentId {
+				continue
+			}
+			if err := seg.Remove(); err != nil {
+				return err
+			}
+			delete(wal.olderSegments, id)
+		}
+		delete(wal.olderSegments, target.id)
+		wal.activeSegment = target
+	}
+
+	size := int64(pos.BlockNumber)*int64(blockSize) + pos.ChunkOffset
+	if err := target.truncate(size); err != nil {
+		return err
+	}
+	return wal.fs.SyncDir(wal.options.DirPath)
+}
+
+// RenameFileExt renames all segment files' extension name.
+// It is now used by the Merge operation of loutsdb, not a common usage for most users.
+func (wal *WAL) RenameFileExt(ext string) error {
+	if !strings.HasPrefix(ext, ".") {
+		return fmt.Errorf("segment file extension must start with '.'")
+	}
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
+	renameFile := func(id SegmentID) error {
+		oldName := SegmentFileName(wal.options.DirPath, wal.options.SegmentFileExt, id)
+		newName := SegmentFileName(wal.options.DirPath, ext, id)
+		return wal.fs.Rename(oldName, newName)
+	}
+
+	for _, id := range wal.renameIds {
+		if err := renameFile(id); err != nil {
+			return err
+		}
+	}
+
+	wal.options.SegmentFileExt = ext
+	return nil
+}
+
+func (wal *WAL) isFull(delta int64) bool {
+	return wal.activeSegment.Size()+wal.maxDataWriteSize(delta) > wal.options.SegmentSize
+}
+
+// maxDataWriteSize calculate the possible maximum size.
+// the maximum size = max padding + (num_block + 1) * headerSize + dataSize
+func (wal *WAL) maxDataWriteSize(size int64) int64 {
+	return chunkHeaderSize + size + (size/blockSize+1)*chunkHeaderSize
+}
@@ -0,0 +1,312 @@
+package wal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/ankur-anand/unisondb/dbkernel/vfs"
+	"github.com/ankur-anand/unisondb/dbkernel/wal/internal/seglog"
+	"github.com/hashicorp/go-metrics"
+)
+
+// segment file layout of the underlying wal implementation.
+// segment is split into blocks, and every record is written as one or more chunks,
+// where each chunk has a header of Checksum(4) Length(2) Type(1).
+const (
+	segmentBlockSize = 32 * seglog.KB
+	chunkHeaderSize  = 7
+	quarantineDir    = "quarantine"
+)
+
+var (
+	// ErrCorrupted is returned when the wal is corrupted at a place other than the tail of the last segment.
+	ErrCorrupted = errors.New("wal corrupted")
+
+	errTornChunk = errors.New("incomplete chunk")
+)
+
+var (
+	walMetricsRepairTotal      = append(packageKey, "wal", "repair", "total")
+	walMetricsQuarantinedBytes = append(packageKey, "wal", "quarantined", "bytes", "total")
+)
+
+// RecoveryMode decides how the wal is repaired on open.
+type RecoveryMode string
+
+const (
+	// RecoveryModeStrict truncates a torn tail of the last segment, corruption at any other place fails the open.
+	RecoveryModeStrict RecoveryMode = "strict"
+	// RecoveryModeSalvage keeps every record till the first corruption, everything after it is quarantined.
+	RecoveryModeSalvage RecoveryMode = "salvage"
+)
+
+// segmentDamage describes the first damaged record found in a segment.
+type segmentDamage struct {
+	// offset of the start of the damaged record.
+	offset int64
+	// torn is true if the damaged record is the tail of the segment, a write that never completed.
+	torn bool
+	err  error
+}
+
+// repairSegments validates the segment files in the dir before they are opened.
+// A torn tail of the last segment is always quarantined and truncated.
+// In RecoveryModeStrict only the last segment is validated, older segments are validated by the readers.
+func repairSegments(fs vfs.FS, dirname string, mode RecoveryMode, m *metrics.Metrics, label []metrics.Label) error {
+	ids, err := segmentIDs(fs, dirname)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	toValidate := ids[len(ids)-1:]
+	if mode == RecoveryModeSalvage {
+		toValidate = ids
+	}
+
+	for i, id := range toValidate {
+		path := seglog.SegmentFileName(dirname, segmentFileExt, id)
+		last := id == ids[len(ids)-1]
+		damage, err := validateSegment(fs, path, last)
+		if err != nil {
+			return err
+		}
+		if damage == nil {
+			continue
+		}
+
+		m.IncrCounterWithLabels(walMetricsRepairTotal, 1, label)
+		if damage.torn {
+			slog.Warn("[kvalchemy.wal] torn tail found in the last segment, truncating",
+				"segment", id, "offset", damage.offset, "error", damage.err)
+			return quarantineTail(fs, dirname, id, damage.offset, "torn", m, label)
+		}
+
+		if mode != RecoveryModeSalvage {
+			return fmt.Errorf("%w: segment %d at offset %d: %w", ErrCorrupted, id, damage.offset, damage.err)
+		}
+
+		slog.Warn("[kvalchemy.wal] corruption found, salvaging the log till the corruption point",
+			"segment", id, "offset", damage.offset, "error", damage.err)
+		if err := quarantineTail(fs, dirname, id, damage.offset, "corrupt", m, label); err != nil {
+			return err
+		}
+		// every segment after the corruption point is quarantined as a whole.
+		for _, laterID := range toValidate[i+1:] {
+			if err := quarantineSegment(fs, dirname, laterID, "corrupt", m, label); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// segmentIDs returns the sorted ids of the segment files in the dir.
+func segmentIDs(fs vfs.FS, dirname string) ([]uint32, error) {
+	entries, err := fs.ReadDir(dirname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var ids []uint32
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		var id uint32
+		if _, err := fmt.Sscanf(entry.Name(), "%d"+segmentFileExt, &id); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// validateSegment walks every record of the segment and returns the first damaged record, if any.
+func validateSegment(fs vfs.FS, path string, last bool) (*segmentDamage, error) {
+	data, err := vfs.ReadFile(fs, path)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(len(data))
+	var pos int64
+	for pos < size {
+		recordStart := pos
+		next, err := walkRecord(data, pos)
+		if err != nil {
+			// only a write to the end of the last segment could have been interrupted,
+			// a damaged record followed by nothing but zeros is the same unfinished write.
+			torn := last && (errors.Is(err, errTornChunk) || onlyZeros(data[min(next, size):]))
+			return &segmentDamage{offset: recordStart, torn: torn, err: err}, nil
+		}
+		pos = next
+	}
+	return nil, nil
+}
+
+// walkRecord validates the record starting at pos and returns the position of the next record.
+// On error the returned position is where the damaged chunk ends, as far as it could be known.
+func walkRecord(data []byte, pos int64) (int64, error) {
+	size := int64(len(data))
+	first := true
+	for {
+		if size-pos < chunkHeaderSize {
+			return size, errTornChunk
+		}
+
+		header := data[pos : pos+chunkHeaderSize]
+		length := int64(binary.LittleEndian.Uint16(header[4:6]))
+		chunkType := header[6]
+		end := pos + chunkHeaderSize + length
+
+		if pos%segmentBlockSize+chunkHeaderSize+length > segmentBlockSize {
+			return pos + chunkHeaderSize, fmt.Errorf("chunk length %d overflows the block", length)
+		}
+		if end > size {
+			return size, errTornChunk
+		}
+		if crc32.ChecksumIEEE(data[pos+4:end]) != binary.LittleEndian.Uint32(header[:4]) {
+			return end, seglog.ErrInvalidCRC
+		}
+
+		validType := chunkType == seglog.ChunkTypeFull || chunkType == seglog.ChunkTypeFirst
+		if !first {
+			validType = chunkType == seglog.ChunkTypeMiddle || chunkType == seglog.ChunkTypeLast
+		}
+		if !validType {
+			return end, fmt.Errorf("unexpected chunk type %d", chunkType)
+		}
+		first = false
+
+		nextBlock := (pos/segmentBlockSize + 1) * segmentBlockSize
+		if chunkType == seglog.ChunkTypeFull || chunkType == seglog.ChunkTypeLast {
+			// left space of the block that can't hold a chunk header is padding.
+			if end%segmentBlockSize+chunkHeaderSize >= segmentBlockSize {
+				return nextBlock, nil
+			}
+			return end, nil
+		}
+
+		// rest of the record continues in the next block.
+		if nextBlock >= size {
+			return size, errTornChunk
+		}
+		pos = nextBlock
+	}
+}
+
+// wrapCorrupted marks the checksum failures of the underlying wal as ErrCorrupted.
+func wrapCorrupted(err error) error {
+	if errors.Is(err, seglog.ErrInvalidCRC) {
+		return fmt.Errorf("%w: %w", ErrCorrupted, err)
+	}
+	return err
+}
+
+func onlyZeros(b []byte) bool {
+	return len(bytes.Trim(b, "\x00")) == 0
+}
+
+// quarantineTail copies the segment bytes from the offset to a file in the quarantine dir,
+// and then truncates the segment at the offset.
+func quarantineTail(fs vfs.FS, dirname string, id uint32, offset int64, reason string, m *metrics.Metrics, label []metrics.Label) error {
+	path := seglog.SegmentFileName(dirname, segmentFileExt, id)
+	f, err := fs.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	qDir := filepath.Join(dirname, quarantineDir)
+	if err := fs.MkdirAll(qDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	qPath := filepath.Join(qDir, fmt.Sprintf("%s.%d.%s", filepath.Base(path), offset, reason))
+	qf, err := fs.OpenFile(qPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer qf.Close()
+
+	n, err := io.Copy(qf, io.NewSectionReader(f, offset, 1<<63-1-offset))
+	if err != nil {
+		return fmt.Errorf("quarantine segment %d tail: %w", id, err)
+	}
+	if err := qf.Sync(); err != nil {
+		return err
+	}
+
+	// bad bytes are safe in quarantine, now they can be removed from the segment.
+	if err := f.Truncate(offset); err != nil {
+		return fmt.Errorf("truncate segment %d: %w", id, err)
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	m.IncrCounterWithLabels(walMetricsQuarantinedBytes, float32(n), label)
+	slog.Warn("[kvalchemy.wal] quarantined wal bytes",
+		"segment", id, "offset", offset, "bytes", n, "file", qPath)
+	return fs.SyncDir(dirname)
+}
+
+// quarantineSegment moves the complete segment file to the quarantine dir.
+func quarantineSegment(fs vfs.FS, dirname string, id uint32, reason string, m *metrics.Metrics, label []metrics.Label) error {
+	path := seglog.SegmentFileName(dirname, segmentFileExt, id)
+	info, err := fs.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	qPath := filepath.Join(dirname, quarantineDir, filepath.Base(path)+".0."+reason)
+	if err := fs.Rename(path, qPath); err != nil {
+		return fmt.Errorf("quarantine segment %d: %w", id, err)
+	}
+
+	m.IncrCounterWithLabels(walMetricsQuarantinedBytes, float32(info.Size()), label)
+	slog.Warn("[kvalchemy.wal] quarantined wal segment", "segment", id, "bytes", info.Size(), "file", qPath)
+	return fs.SyncDir(dirname)
+}
+
+// TruncateFrom removes every record of the wal from the offset onwards, the removed records are
+// quarantined like a repair would. It's used on a closed wal, to drop the records a node wrote
+// as a primary that the new primary of the namespace never received.
+func TruncateFrom(dirname, namespace string, config *Config, offset *Offset, m *metrics.Metrics) error {
+	config.applyDefaults()
+	label := []metrics.Label{{Name: "namespace", Value: namespace}}
+	ids, err := segmentIDs(config.FS, dirname)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(ids, offset.SegmentId) {
+		return fmt.Errorf("truncate wal: segment %d not found", offset.SegmentId)
+	}
+
+	pos := int64(offset.BlockNumber)*segmentBlockSize + offset.ChunkOffset
+	if err := quarantineTail(config.FS, dirname, offset.SegmentId, pos, "divergent", m, label); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id <= offset.SegmentId {
+			continue
+		}
+		if err := quarantineSegment(config.FS, dirname, id, "divergent", m, label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
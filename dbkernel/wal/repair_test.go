@@ -0,0 +1,239 @@
+package wal_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/hashicorp/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func segmentPath(dir string, id int) string {
+	return filepath.Join(dir, fmt.Sprintf("%09d.seg.wal", id))
+}
+
+// writeRecords appends the records to a new wal in dir and closes it.
+func writeRecords(t *testing.T, dir string, config *wal.Config, records [][]byte) {
+	t.Helper()
+	walInstance, err := wal.NewWalIO(dir, "test_namespace", config, metrics.Default())
+	require.NoError(t, err)
+	for _, record := range records {
+		_, err := walInstance.Append(record)
+		require.NoError(t, err)
+	}
+	require.NoError(t, walInstance.Close())
+}
+
+// readRecords returns all the record present in the wal.
+func readRecords(t *testing.T, walInstance *wal.WalIO) [][]byte {
+	t.Helper()
+	reader, err := walInstance.NewReader()
+	require.NoError(t, err)
+	var records [][]byte
+	for {
+		value, _, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		require.NoError(t, err)
+		records = append(records, append([]byte(nil), value...))
+	}
+}
+
+func quarantinedFiles(t *testing.T, dir string) map[string]int64 {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(dir, "quarantine"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	require.NoError(t, err)
+	files := make(map[string]int64)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		require.NoError(t, err)
+		files[entry.Name()] = info.Size()
+	}
+	return files
+}
+
+func generateRecords(n int, size uint) [][]byte {
+	records := make([][]byte, n)
+	for i := range records {
+		records[i] = []byte(gofakeit.LetterN(size))
+	}
+	return records
+}
+
+func TestRepair_TornTail(t *testing.T) {
+	tests := []struct {
+		name    string
+		records [][]byte
+		// tear damages the tail of the segment and returns the number of bytes expected in quarantine.
+		tear func(t *testing.T, path string) int64
+	}{
+		{
+			name:    "partial_chunk",
+			records: generateRecords(10, 100),
+			tear: func(t *testing.T, path string) int64 {
+				info, err := os.Stat(path)
+				require.NoError(t, err)
+				// last record is 7 bytes of header and 100 bytes of data, keep half of it.
+				require.NoError(t, os.Truncate(path, info.Size()-50))
+				return 57
+			},
+		},
+		{
+			name:    "partial_header",
+			records: generateRecords(10, 100),
+			tear: func(t *testing.T, path string) int64 {
+				info, err := os.Stat(path)
+				require.NoError(t, err)
+				require.NoError(t, os.Truncate(path, info.Size()-104))
+				return 3
+			},
+		},
+		{
+			name:    "missing_continuation_block",
+			records: append(generateRecords(10, 100), generateRecords(1, 80*1024)...),
+			tear: func(t *testing.T, path string) int64 {
+				// large record spans three blocks, the last block never made it to disk.
+				require.NoError(t, os.Truncate(path, 64*1024))
+				return 64*1024 - 10*107
+			},
+		},
+		{
+			name:    "zero_filled",
+			records: generateRecords(10, 100),
+			tear: func(t *testing.T, path string) int64 {
+				f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+				require.NoError(t, err)
+				defer f.Close()
+				_, err = f.Write(make([]byte, 4096))
+				require.NoError(t, err)
+				return 4096
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeRecords(t, dir, wal.NewDefaultConfig(), tc.records)
+			quarantined := tc.tear(t, segmentPath(dir, 1))
+
+			walInstance, err := wal.NewWalIO(dir, "test_namespace", wal.NewDefaultConfig(), metrics.Default())
+			require.NoError(t, err, "torn tail should be repaired")
+			t.Cleanup(func() {
+				assert.NoError(t, walInstance.Close())
+			})
+
+			expected := tc.records
+			if tc.name != "zero_filled" {
+				expected = tc.records[:len(tc.records)-1]
+			}
+			assert.Equal(t, expected, readRecords(t, walInstance))
+
+			files := quarantinedFiles(t, dir)
+			assert.Len(t, files, 1)
+			for name, size := range files {
+				assert.Contains(t, name, ".torn")
+				assert.Equal(t, quarantined, size)
+			}
+
+			// new records should be appended after the last complete record.
+			record := []byte(gofakeit.LetterN(100))
+			pos, err := walInstance.Append(record)
+			assert.NoError(t, err)
+			value, err := walInstance.Read(pos)
+			assert.NoError(t, err)
+			assert.Equal(t, record, value)
+			assert.Equal(t, append(expected, record), readRecords(t, walInstance))
+		})
+	}
+}
+
+func TestRepair_MidLogCorruption(t *testing.T) {
+	records := generateRecords(10, 100)
+
+	corrupt := func(t *testing.T, dir string) {
+		f, err := os.OpenFile(segmentPath(dir, 1), os.O_RDWR, 0644)
+		require.NoError(t, err)
+		defer f.Close()
+		// flip a data byte of the 5th record.
+		_, err = f.WriteAt([]byte{'#'}, 4*107+10)
+		require.NoError(t, err)
+	}
+
+	t.Run("strict_fails", func(t *testing.T) {
+		dir := t.TempDir()
+		writeRecords(t, dir, wal.NewDefaultConfig(), records)
+		corrupt(t, dir)
+		before, err := os.ReadFile(segmentPath(dir, 1))
+		require.NoError(t, err)
+
+		walInstance, err := wal.NewWalIO(dir, "test_namespace", wal.NewDefaultConfig(), metrics.Default())
+		assert.ErrorIs(t, err, wal.ErrCorrupted)
+		assert.Nil(t, walInstance)
+
+		after, err := os.ReadFile(segmentPath(dir, 1))
+		require.NoError(t, err)
+		assert.Equal(t, before, after, "segment should not be modified")
+		assert.Empty(t, quarantinedFiles(t, dir))
+	})
+
+	t.Run("salvage", func(t *testing.T) {
+		dir := t.TempDir()
+		writeRecords(t, dir, wal.NewDefaultConfig(), records)
+		corrupt(t, dir)
+
+		config := wal.NewDefaultConfig()
+		config.RecoveryMode = wal.RecoveryModeSalvage
+		walInstance, err := wal.NewWalIO(dir, "test_namespace", config, metrics.Default())
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			assert.NoError(t, walInstance.Close())
+		})
+
+		assert.Equal(t, records[:4], readRecords(t, walInstance))
+		files := quarantinedFiles(t, dir)
+		assert.Equal(t, map[string]int64{"000000001.seg.wal.428.corrupt": 6 * 107}, files)
+	})
+
+	t.Run("salvage_quarantines_later_segments", func(t *testing.T) {
+		dir := t.TempDir()
+		config := wal.NewDefaultConfig()
+		config.SegmentSize = 64 * 1024
+		// every record fills up half a block, so the log spans multiple segments.
+		many := generateRecords(20, 16*1024)
+		writeRecords(t, dir, config, many)
+		_, err := os.Stat(segmentPath(dir, 3))
+		require.NoError(t, err, "log should have at least 3 segments")
+		corrupt(t, dir)
+
+		walInstance, err := wal.NewWalIO(dir, "test_namespace", config, metrics.Default())
+		require.NoError(t, err, "strict mode only validates the last segment")
+		require.NoError(t, walInstance.Close())
+
+		config.RecoveryMode = wal.RecoveryModeSalvage
+		walInstance, err = wal.NewWalIO(dir, "test_namespace", config, metrics.Default())
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			assert.NoError(t, walInstance.Close())
+		})
+
+		// corruption is in the first record.
+		assert.Empty(t, readRecords(t, walInstance))
+		_, err = os.Stat(segmentPath(dir, 2))
+		assert.True(t, os.IsNotExist(err), "segments after the corruption should be quarantined")
+		files := quarantinedFiles(t, dir)
+		assert.Contains(t, files, "000000002.seg.wal.0.corrupt")
+		assert.Contains(t, files, "000000001.seg.wal.0.corrupt")
+	})
+}
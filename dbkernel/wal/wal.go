@@ -7,8 +7,8 @@ import (
 	"slices"
 	"time"
 
-	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
-	"github.com/ankur-anand/wal"
+	"github.com/ankur-anand/unisondb/dbkernel/wal/internal/seglog"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
 	"github.com/hashicorp/go-metrics"
 	"github.com/pkg/errors"
 )
@@ -28,26 +28,30 @@ var (
 )
 
 // Offset is a type alias to underlying wal implementation.
-type Offset = wal.ChunkPosition
+type Offset = seglog.ChunkPosition
 
 func DecodeOffset(b []byte) *Offset {
-	return wal.DecodeChunkPosition(b)
+	return seglog.DecodeChunkPosition(b)
 }
 
 // WalIO provides a write and read to underlying file based wal store.
 type WalIO struct {
-	appendLog *wal.WAL
+	appendLog *seglog.WAL
 	label     []metrics.Label
 	metrics   *metrics.Metrics
 }
 
 func NewWalIO(dirname, namespace string, config *Config, m *metrics.Metrics) (*WalIO, error) {
 	config.applyDefaults()
-	w, err := wal.Open(newWALOptions(dirname, config))
+	l := []metrics.Label{{Name: "namespace", Value: namespace}}
+	if err := repairSegments(config.FS, dirname, config.RecoveryMode, m, l); err != nil {
+		return nil, err
+	}
+
+	w, err := seglog.Open(newWALOptions(dirname, config))
 	if err != nil {
 		return nil, err
 	}
-	l := []metrics.Label{{Name: "namespace", Value: namespace}}
 
 	return &WalIO{
 			appendLog: w,
@@ -94,6 +98,7 @@ func (w *WalIO) Read(pos *Offset) ([]byte, error) {
 	value, err := w.appendLog.Read(pos)
 	if err != nil {
 		w.metrics.IncrCounterWithLabels(walMetricsReadErrors, 1, w.label)
+		err = wrapCorrupted(err)
 	}
 	w.metrics.IncrCounterWithLabels(walMetricsReadBytes, float32(len(value)), w.label)
 	return value, err
@@ -114,7 +119,7 @@ func (w *WalIO) Append(data []byte) (*Offset, error) {
 }
 
 type Reader struct {
-	appendReader *wal.Reader
+	appendReader *seglog.Reader
 	label        []metrics.Label
 	metrics      *metrics.Metrics
 }
@@ -130,6 +135,7 @@ func (r *Reader) Next() ([]byte, *Offset, error) {
 	value, off, err := r.appendReader.Next()
 	if err != nil && !errors.Is(err, io.EOF) {
 		r.metrics.IncrCounterWithLabels(walMetricsReadErrors, 1, r.label)
+		err = wrapCorrupted(err)
 	}
 	r.metrics.IncrCounterWithLabels(walMetricsReadBytes, float32(len(value)), r.label)
 	return value, off, err
@@ -143,6 +149,26 @@ func (r *Reader) CurrentOffset() *Offset {
 	return r.appendReader.CurrentChunkPosition()
 }
 
+// SegmentIDs returns the ids of the segments of the wal in ascending order.
+func (w *WalIO) SegmentIDs() []uint32 {
+	return w.appendLog.SegmentIDs()
+}
+
+// BytesAfter returns the bytes appended to the wal after the record at the offset, every byte of the wal if nil.
+func (w *WalIO) BytesAfter(offset *Offset) int64 {
+	return w.appendLog.BytesAfter(offset)
+}
+
+// IsPastEnd returns whether the offset is at or after the end of the wal, as it was left by the repair on open.
+func (w *WalIO) IsPastEnd(offset *Offset) bool {
+	return w.appendLog.IsPastEnd(offset)
+}
+
+// Truncate removes the record at the given offset and everything appended after it.
+func (w *WalIO) Truncate(offset *Offset) error {
+	return w.appendLog.Truncate(offset)
+}
+
 // NewReader returns a new instance of WIOReader, allowing the caller to
 // access WAL logs for replication, recovery, or log processing.
 func (w *WalIO) NewReader() (*Reader, error) {
@@ -156,6 +182,9 @@ func (w *WalIO) NewReader() (*Reader, error) {
 // NewReaderWithStart returns a new instance of WIOReader from the provided Offset.
 func (w *WalIO) NewReaderWithStart(offset *Offset) (*Reader, error) {
 	reader, err := w.appendLog.NewReaderWithStart(offset)
+	if err != nil {
+		err = wrapCorrupted(err)
+	}
 	return &Reader{
 		appendReader: reader,
 		label:        w.label,
@@ -164,12 +193,12 @@ func (w *WalIO) NewReaderWithStart(offset *Offset) (*Reader, error) {
 }
 
 // GetTransactionRecords returns all the WalRecord that is part of the particular Txn.
-func (w *WalIO) GetTransactionRecords(startOffset *Offset) ([]*walrecord.WalRecord, error) {
+func (w *WalIO) GetTransactionRecords(startOffset *Offset) ([]*logcodec.LogRecord, error) {
 	if startOffset == nil {
 		return nil, nil
 	}
 
-	var records []*walrecord.WalRecord
+	var records []*logcodec.LogRecord
 	nextOffset := startOffset
 
 	for {
@@ -178,16 +207,52 @@ func (w *WalIO) GetTransactionRecords(startOffset *Offset) ([]*walrecord.WalReco
 			return nil, fmt.Errorf("failed to read WAL at offset %+v: %w", nextOffset, err)
 		}
 
-		record := walrecord.GetRootAsWalRecord(walEntry, 0)
+		record, err := logcodec.DecodeRecord(walEntry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode WAL at offset %+v: %w", nextOffset, err)
+		}
 		records = append(records, record)
 
-		if record.PrevTxnWalIndexLength() == 0 {
+		if len(record.PrevTxnWalIndex) == 0 {
 			break
 		}
 
-		nextOffset = DecodeOffset(record.PrevTxnWalIndexBytes())
+		nextOffset = DecodeOffset(record.PrevTxnWalIndex)
 	}
 
 	slices.Reverse(records)
 	return records, nil
 }
+
+// GetTransactionOffsets returns the offsets of all the WalRecord that is part of the particular Txn,
+// in the order they were appended. Unlike GetTransactionRecords it doesn't retain the records,
+// so the caller can read them back one at a time.
+func (w *WalIO) GetTransactionOffsets(startOffset *Offset) ([]*Offset, error) {
+	if startOffset == nil {
+		return nil, nil
+	}
+
+	var offsets []*Offset
+	nextOffset := startOffset
+
+	for {
+		walEntry, err := w.Read(nextOffset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read WAL at offset %+v: %w", nextOffset, err)
+		}
+
+		offsets = append(offsets, nextOffset)
+		record, err := logcodec.DecodeRecord(walEntry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode WAL at offset %+v: %w", nextOffset, err)
+		}
+		if len(record.PrevTxnWalIndex) == 0 {
+			break
+		}
+
+		nextOffset = DecodeOffset(record.PrevTxnWalIndex)
+	}
+
+	slices.Reverse(offsets)
+	return offsets, nil
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/ankur-anand/unisondb/dbkernel/wal"
 	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
 	"github.com/ankur-anand/unisondb/internal/etc"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/hashicorp/go-metrics"
 	"github.com/stretchr/testify/assert"
@@ -185,12 +187,12 @@ func TestWalIO_Suite(t *testing.T) {
 		records, err := walInstance.GetTransactionRecords(firstOffset)
 		assert.NoError(t, err)
 		for i, record := range records {
-			assert.Equal(t, record.Operation(), walrecord.LogOperationInsert, "expected operation didn't matched")
-			assert.Equal(t, uint64(i), record.Index(), "expected index didn't match")
-			assert.Equal(t, uint64(i), record.Hlc(), "expected hlc didn't match")
-			assert.Equal(t, record.EntryType(), walrecord.EntryTypeKV, "expected value type didn't match")
-			assert.Equal(t, string(record.KeyBytes()), keys[i], "expected key didn't match")
-			assert.Equal(t, string(record.ValueBytes()), values[i], "expected value didn't match")
+			assert.Equal(t, record.OperationType, logrecord.LogOperationTypeInsert, "expected operation didn't matched")
+			assert.Equal(t, uint64(i), record.LSN, "expected index didn't match")
+			assert.Equal(t, uint64(i), record.HLC, "expected hlc didn't match")
+			assert.Equal(t, record.EntryType, logrecord.LogEntryTypeKV, "expected value type didn't match")
+			assert.Equal(t, string(record.Key()), keys[i], "expected key didn't match")
+			assert.Equal(t, string(record.Value()), values[i], "expected value didn't match")
 		}
 	})
 
@@ -226,7 +228,46 @@ func TestWalIO_Suite(t *testing.T) {
 		records, err := walInstance.GetTransactionRecords(offset)
 		assert.NoError(t, err)
 		assert.Len(t, records, 1, "should return only 1 transaction record")
-		assert.Equal(t, key, string(records[0].KeyBytes()), "key should match")
-		assert.Equal(t, data, string(records[0].ValueBytes()), "value should match")
+		assert.Equal(t, key, string(records[0].Key()), "key should match")
+		assert.Equal(t, data, string(records[0].Value()), "value should match")
+	})
+
+	t.Run("get_transaction_records_log_record", func(t *testing.T) {
+		begin := logcodec.LogRecord{
+			LSN:           1,
+			OperationType: logrecord.LogOperationTypeTxnMarker,
+			TxnState:      logrecord.TransactionStateBegin,
+			TxnID:         []byte(gofakeit.UUID()),
+		}
+		beginOffset, err := walInstance.Append(begin.Encode())
+		assert.NoError(t, err)
+
+		key := gofakeit.Name()
+		data := gofakeit.LetterN(10)
+		prepare := logcodec.LogRecord{
+			LSN:             2,
+			OperationType:   logrecord.LogOperationTypeInsert,
+			TxnState:        logrecord.TransactionStatePrepare,
+			TxnID:           begin.TxnID,
+			PrevTxnWalIndex: beginOffset.Encode(),
+			Payload: logcodec.LogOperationData{
+				KeyValueBatchEntries: &logcodec.KeyValueBatchEntries{
+					Entries: []logcodec.KeyValueEntry{{Key: []byte(key), Value: []byte(data)}},
+				},
+			},
+		}
+		offset, err := walInstance.Append(prepare.Encode())
+		assert.NoError(t, err)
+
+		records, err := walInstance.GetTransactionRecords(offset)
+		assert.NoError(t, err)
+		assert.Len(t, records, 2)
+		assert.Equal(t, logrecord.TransactionStateBegin, records[0].TxnState)
+		assert.Equal(t, key, string(records[1].Key()), "key should match")
+		assert.Equal(t, data, string(records[1].Value()), "value should match")
+
+		offsets, err := walInstance.GetTransactionOffsets(offset)
+		assert.NoError(t, err)
+		assert.Equal(t, []*wal.Offset{beginOffset, offset}, offsets)
 	})
 }
@@ -3,7 +3,7 @@ package walrecord
 import (
 	"hash/crc32"
 
-	"github.com/ankur-anand/wal"
+	"github.com/ankur-anand/unisondb/dbkernel/wal/internal/seglog"
 	flatbuffers "github.com/google/flatbuffers/go"
 )
 
@@ -20,7 +20,7 @@ type Record struct {
 	EntryType     EntryType
 	TxnStatus     TxnStatus
 	TxnID         []byte
-	PrevTxnOffset *wal.ChunkPosition
+	PrevTxnOffset *seglog.ChunkPosition
 	// ColumnEntries is wide column.
 	ColumnEntries map[string][]byte
 }
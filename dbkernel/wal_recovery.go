@@ -4,10 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 
 	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
 	"github.com/ankur-anand/unisondb/dbkernel/wal"
-	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
 	"github.com/bits-and-blooms/bloom/v3"
 )
 
@@ -17,6 +19,21 @@ type walRecovery struct {
 	recoveredCount   int
 	lastRecoveredPos *wal.Offset
 	bloom            *bloom.BloomFilter
+	// lastHLC is the latest hybrid logical clock timestamp of the records read.
+	lastHLC uint64
+	// lastLSN is the log sequence number of the last record in the wal.
+	lastLSN uint64
+	// checkpointReset is true if the checkpoint pointed past the end of the wal and was moved back.
+	checkpointReset bool
+	// epochs are the epochs saved in the btree store, with the epochs of the records recovered after them.
+	epochs        []epochStart
+	epochsChanged bool
+	// lastPos is the offset of the last record in the wal, the records after the lastRecoveredPos
+	// of a consensus namespace are read but not applied.
+	lastPos *wal.Offset
+	// consensus is set for the consensus namespaces, only the records till the commitLSN are applied.
+	consensus bool
+	commitLSN uint64
 }
 
 // recoverWAL recover wal from last check point saved in btree store.
@@ -32,6 +49,20 @@ func (wr *walRecovery) recoverWAL() error {
 		offset = *metadata.Pos
 	}
 
+	// a checkpoint without segment id is saved when the wal was empty after a reset,
+	// every record of the wal is yet to be recovered.
+	if offset.SegmentId == 0 {
+		value = nil
+	}
+
+	// the wal got repaired below the checkpoint, the btree store is ahead of it.
+	// any other failure to read the checkpoint record, like a corruption, fails the recovery.
+	if len(value) != 0 && wr.walIO.IsPastEnd(&offset) {
+		slog.Warn("[kvalchemy.dbengine] checkpoint is past the end of the wal, moving it back",
+			"segment", offset.SegmentId, "block", offset.BlockNumber, "offset", offset.ChunkOffset)
+		return wr.resetCheckpoint()
+	}
+
 	reader, err := wr.walIO.NewReaderWithStart(&offset)
 	if err != nil {
 		return fmt.Errorf("recover WAL failed %w", err)
@@ -39,10 +70,18 @@ func (wr *walRecovery) recoverWAL() error {
 
 	if len(value) != 0 {
 		// first value will be duplicate, so we can ignore it.
-		_, _, err := reader.Next()
+		value, pos, err := reader.Next()
 		if wr.isFatalError(err) {
 			return fmt.Errorf("recover WAL failed %w", err)
 		}
+		// reader skips over a missing segment, so the checkpoint record must be the first one read.
+		if err != nil || pos.SegmentId != offset.SegmentId || pos.BlockNumber != offset.BlockNumber ||
+			pos.ChunkOffset != offset.ChunkOffset {
+			return fmt.Errorf("recover WAL failed: checkpoint record at %s not found: %w", offset.String(), ErrWalCorrupted)
+		}
+		if wr.lastLSN, err = logcodec.DecodeLSN(value); err != nil {
+			return fmt.Errorf("recover WAL failed %w", err)
+		}
 	}
 
 	for {
@@ -55,7 +94,17 @@ func (wr *walRecovery) recoverWAL() error {
 			return fmt.Errorf("recover WAL failed %w", err)
 		}
 
-		record := walrecord.GetRootAsWalRecord(value, 0)
+		record, err := logcodec.DecodeRecord(value)
+		if err != nil {
+			return fmt.Errorf("recover WAL failed %w", err)
+		}
+		wr.lastHLC = max(wr.lastHLC, record.HLC)
+		wr.lastLSN = record.LSN
+		wr.lastPos = pos
+		wr.trackEpoch(record)
+		if wr.consensus && record.LSN > wr.commitLSN {
+			continue
+		}
 		err = wr.handleRecord(record)
 		wr.lastRecoveredPos = pos
 		if err != nil {
@@ -65,39 +114,108 @@ func (wr *walRecovery) recoverWAL() error {
 	return nil
 }
 
-func (wr *walRecovery) handleRecord(record *walrecord.WalRecord) error {
-	// we only recover two cases.
-	// Individual Insert/Delete
-	// Txn Insert/Delete and Chunk. Uncommited Txn are ignored.
-	switch record.TxnStatus() {
-	case walrecord.TxnStatusTxnNone:
+// resetCheckpoint moves the checkpoint to the last record present in the wal.
+// records of the wal are already part of the btree store, so none of them are recovered.
+func (wr *walRecovery) resetCheckpoint() error {
+	reader, err := wr.walIO.NewReader()
+	if err != nil {
+		return fmt.Errorf("recover WAL failed %w", err)
+	}
+
+	wr.checkpointReset = true
+	wr.lastRecoveredPos = &wal.Offset{}
+	wr.lastPos = wr.lastRecoveredPos
+	for {
+		value, pos, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("recover WAL failed %w", err)
+		}
+		wr.lastRecoveredPos = pos
+		wr.lastPos = pos
+		if wr.lastLSN, err = logcodec.DecodeLSN(value); err != nil {
+			return fmt.Errorf("recover WAL failed %w", err)
+		}
+	}
+}
+
+func (wr *walRecovery) handleRecord(record *logcodec.LogRecord) error {
+	// we recover two cases.
+	// Individual Insert/Delete/DeleteRow of KV and Row.
+	// Txn Insert/Delete/DeleteRow of KV, Row and Chunk. Uncommited Txn are ignored.
+	// Batch of the individual operations is applied as a whole, as it's a single record.
+	if record.IsBatch() {
+		return wr.handleBatchRecord(record)
+	}
+
+	switch record.TxnState {
+	case logrecord.TransactionStateNone:
+		if !isDataOperation(record) {
+			return nil
+		}
 		wr.recoveredCount++
-		switch record.Operation() {
-		case walrecord.LogOperationInsert:
-			wr.bloom.Add(record.KeyBytes())
-			return wr.store.Set(record.KeyBytes(), record.ValueBytes())
-		case walrecord.LogOperationDelete:
-			return wr.store.Delete(record.KeyBytes())
+		wr.bloom.Add(record.Key())
+		if record.EntryType == logrecord.LogEntryTypeRow {
+			return wr.handleRowRecord(record)
 		}
-	case walrecord.TxnStatusCommit:
+		switch record.OperationType {
+		case logrecord.LogOperationTypeInsert:
+			return wr.store.Set(record.Key(), record.Value())
+		case logrecord.LogOperationTypeDelete:
+			return wr.store.Delete(record.Key())
+		}
+	case logrecord.TransactionStateCommit:
 		return wr.handleTxnCommited(record)
 	}
 
 	return nil
 }
 
+// handleBatchRecord applies every operation of the batch, in the order they were added to it.
+func (wr *walRecovery) handleBatchRecord(record *logcodec.LogRecord) error {
+	records, err := record.BatchRecords()
+	if err != nil {
+		return err
+	}
+	for _, bRecord := range records {
+		if err := wr.handleRecord(bRecord); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleRowRecord applies the individual row column upsert, column delete or the complete row delete.
+func (wr *walRecovery) handleRowRecord(record *logcodec.LogRecord) error {
+	rowKeys := [][]byte{record.Key()}
+	switch record.OperationType {
+	case logrecord.LogOperationTypeInsert:
+		return wr.store.SetManyRowColumns(rowKeys, []map[string][]byte{getColumnsValue(record)})
+	case logrecord.LogOperationTypeDelete:
+		return wr.store.DeleteManyRowColumns(rowKeys, []map[string][]byte{getColumnsValue(record)})
+	case logrecord.LogOperationTypeDeleteRowByKey:
+		_, err := wr.store.DeleteEntireRows(rowKeys)
+		return err
+	}
+	return nil
+}
+
 func (wr *walRecovery) isFatalError(err error) bool {
 	return err != nil && !errors.Is(err, io.EOF)
 }
 
-// handleTxnCommited handles the current commited txn, for chunked, insert and delete ops.
-func (wr *walRecovery) handleTxnCommited(record *walrecord.WalRecord) error {
+// handleTxnCommited handles the current commited txn, for chunked, row, insert and delete ops.
+func (wr *walRecovery) handleTxnCommited(record *logcodec.LogRecord) error {
 	wr.recoveredCount++
-	switch record.EntryType() {
-	case walrecord.EntryTypeKV:
+	switch record.EntryType {
+	case logrecord.LogEntryTypeKV:
 		return wr.handleFullValuesTxn(record)
-	case walrecord.EntryTypeChunked:
+	case logrecord.LogEntryTypeChunked:
 		return wr.handleChunkedValuesTxn(record)
+	case logrecord.LogEntryTypeRow:
+		return wr.handleRowColumnsTxn(record)
 	}
 
 	return nil
@@ -105,8 +223,8 @@ func (wr *walRecovery) handleTxnCommited(record *walrecord.WalRecord) error {
 
 // handleFullValuesTxn Handles the insert and delete operation of Txn and updates
 // the same to the underlying btree bases store.
-func (wr *walRecovery) handleFullValuesTxn(record *walrecord.WalRecord) error {
-	records, err := wr.walIO.GetTransactionRecords(wal.DecodeOffset(record.PrevTxnWalIndexBytes()))
+func (wr *walRecovery) handleFullValuesTxn(record *logcodec.LogRecord) error {
+	records, err := wr.walIO.GetTransactionRecords(wal.DecodeOffset(record.PrevTxnWalIndex))
 	if err != nil {
 		return err
 	}
@@ -117,16 +235,16 @@ func (wr *walRecovery) handleFullValuesTxn(record *walrecord.WalRecord) error {
 	values := make([][]byte, len(preparedRecords))
 	keys := make([][]byte, len(preparedRecords))
 	for i, pRecord := range preparedRecords {
-		wr.bloom.Add(pRecord.KeyBytes())
-		keys[i] = pRecord.KeyBytes()
-		values[i] = pRecord.ValueBytes()
+		wr.bloom.Add(pRecord.Key())
+		keys[i] = pRecord.Key()
+		values[i] = pRecord.Value()
 	}
 
 	wr.recoveredCount += len(records)
-	switch record.Operation() {
-	case walrecord.LogOperationInsert:
+	switch record.OperationType {
+	case logrecord.LogOperationTypeInsert:
 		return wr.store.SetMany(keys, values)
-	case walrecord.LogOperationDelete:
+	case logrecord.LogOperationTypeDelete:
 		return wr.store.DeleteMany(keys)
 	}
 	return nil
@@ -134,9 +252,41 @@ func (wr *walRecovery) handleFullValuesTxn(record *walrecord.WalRecord) error {
 
 // handleChunkedValuesTxn saves all the chunked value that is part of the current commit txn.
 // to the provided btree based store.
-func (wr *walRecovery) handleChunkedValuesTxn(record *walrecord.WalRecord) error {
+func (wr *walRecovery) handleChunkedValuesTxn(record *logcodec.LogRecord) error {
 	count, err := handleChunkedValuesTxn(record, wr.walIO, wr.store)
 	wr.recoveredCount += count
-	wr.bloom.Add(record.KeyBytes())
+	wr.bloom.Add(record.Key())
 	return err
 }
+
+// handleRowColumnsTxn applies all the row column operation that is part of the current commit txn,
+// in the order they were appended to the txn.
+func (wr *walRecovery) handleRowColumnsTxn(record *logcodec.LogRecord) error {
+	records, err := wr.walIO.GetTransactionRecords(wal.DecodeOffset(record.PrevTxnWalIndex))
+	if err != nil {
+		return err
+	}
+
+	// remove the begins part from the
+	preparedRecords := records[1:]
+
+	rowKeys := make([][]byte, len(preparedRecords))
+	rowColumns := make([]map[string][]byte, len(preparedRecords))
+	for i, pRecord := range preparedRecords {
+		wr.bloom.Add(pRecord.Key())
+		rowKeys[i] = pRecord.Key()
+		rowColumns[i] = getColumnsValue(pRecord)
+	}
+
+	wr.recoveredCount += len(records)
+	switch record.OperationType {
+	case logrecord.LogOperationTypeInsert:
+		return wr.store.SetManyRowColumns(rowKeys, rowColumns)
+	case logrecord.LogOperationTypeDelete:
+		return wr.store.DeleteManyRowColumns(rowKeys, rowColumns)
+	case logrecord.LogOperationTypeDeleteRowByKey:
+		_, err := wr.store.DeleteEntireRows(rowKeys)
+		return err
+	}
+	return nil
+}
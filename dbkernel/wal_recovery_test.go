@@ -1,17 +1,23 @@
 package dbkernel
 
 import (
+	"context"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/ankur-anand/unisondb/dbkernel/kvdrivers"
 	"github.com/ankur-anand/unisondb/dbkernel/wal"
 	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
 	"github.com/bits-and-blooms/bloom/v3"
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/hashicorp/go-metrics"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestWalRecovery(t *testing.T) {
@@ -381,3 +387,180 @@ func TestWalRecovery(t *testing.T) {
 	})
 
 }
+
+func TestWalRecovery_RowColumns(t *testing.T) {
+	tdir := t.TempDir()
+	walDir := filepath.Join(tdir, "wal_test")
+	err := os.MkdirAll(walDir, 0777)
+	assert.NoError(t, err)
+
+	walInstance, err := wal.NewWalIO(walDir, testNamespace, wal.NewDefaultConfig(), metrics.Default())
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		err := walInstance.Close()
+		assert.NoError(t, err, "failed to close wal")
+	})
+
+	db, err := kvdrivers.NewLmdb(filepath.Join(tdir, "test_rows.db"), kvdrivers.Config{
+		Namespace: testNamespace,
+		NoSync:    true,
+		MmapSize:  1 << 30,
+	})
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		err := db.Close()
+		assert.NoError(t, err, "failed to close db")
+	})
+
+	appendRecord := func(record walrecord.Record) *wal.Offset {
+		encoded, err := record.FBEncode()
+		assert.NoError(t, err)
+		offset, err := walInstance.Append(encoded)
+		assert.NoError(t, err)
+		return offset
+	}
+
+	rowRecord := func(op walrecord.LogOperation, rowKey string, columns map[string][]byte) walrecord.Record {
+		return walrecord.Record{
+			Key:           []byte(rowKey),
+			LogOperation:  op,
+			TxnStatus:     walrecord.TxnStatusTxnNone,
+			EntryType:     walrecord.EntryTypeRow,
+			ColumnEntries: columns,
+		}
+	}
+
+	appendTxn := func(op walrecord.LogOperation, commit bool, rows map[string]map[string][]byte) {
+		txnID := []byte(gofakeit.UUID())
+		lastOffset := appendRecord(walrecord.Record{
+			Key:          []byte("batch_tx_begin"),
+			LogOperation: walrecord.LogOperationTxnMarker,
+			TxnID:        txnID,
+			TxnStatus:    walrecord.TxnStatusBegin,
+			EntryType:    walrecord.EntryTypeRow,
+		})
+		for rowKey, columns := range rows {
+			record := rowRecord(op, rowKey, columns)
+			record.TxnID = txnID
+			record.TxnStatus = walrecord.TxnStatusPrepare
+			record.PrevTxnOffset = lastOffset
+			lastOffset = appendRecord(record)
+		}
+		if commit {
+			appendRecord(walrecord.Record{
+				LogOperation:  op,
+				TxnID:         txnID,
+				TxnStatus:     walrecord.TxnStatusCommit,
+				EntryType:     walrecord.EntryTypeRow,
+				PrevTxnOffset: lastOffset,
+			})
+		}
+	}
+
+	appendRecord(rowRecord(walrecord.LogOperationInsert, "row_1", map[string][]byte{"a": []byte("1"), "b": []byte("2")}))
+	appendRecord(rowRecord(walrecord.LogOperationDelete, "row_1", map[string][]byte{"b": nil}))
+	appendRecord(rowRecord(walrecord.LogOperationInsert, "row_2", map[string][]byte{"a": []byte("1")}))
+	appendRecord(rowRecord(walrecord.LogOperationDeleteRow, "row_2", nil))
+
+	appendTxn(walrecord.LogOperationInsert, true, map[string]map[string][]byte{
+		"row_3": {"x": []byte("x"), "y": []byte("y")},
+		"row_4": {"z": []byte("z")},
+	})
+	appendTxn(walrecord.LogOperationDelete, true, map[string]map[string][]byte{"row_3": {"y": nil}})
+	appendTxn(walrecord.LogOperationDeleteRow, true, map[string]map[string][]byte{"row_4": {"z": nil}})
+	appendTxn(walrecord.LogOperationInsert, false, map[string]map[string][]byte{"row_5": {"a": []byte("1")}})
+
+	recovery := &walRecovery{
+		store: db,
+		walIO: walInstance,
+		bloom: bloom.NewWithEstimates(1_000_000, 0.0001),
+	}
+	err = recovery.recoverWAL()
+	assert.NoError(t, err, "failed to recover wal")
+	// 4 individual records, 3 commited txn of 3, 2 and 2 records + commit, uncommited txn is skipped.
+	assert.Equal(t, 4+4+3+3, recovery.recoveredCount)
+
+	columns, err := db.GetRowColumns([]byte("row_1"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1")}, columns)
+
+	columns, err = db.GetRowColumns([]byte("row_3"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"x": []byte("x")}, columns)
+
+	for _, rowKey := range []string{"row_2", "row_4", "row_5"} {
+		_, err = db.GetRowColumns([]byte(rowKey), nil)
+		assert.ErrorIs(t, err, kvdrivers.ErrKeyNotFound, "row %s should not be present", rowKey)
+	}
+
+	assert.True(t, recovery.bloom.Test([]byte("row_3")))
+}
+
+func TestEngine_LegacyAndVersionedRecords(t *testing.T) {
+	baseDir := t.TempDir()
+	namespace := "test_legacy_records"
+	config := NewDefaultEngineConfig()
+	config.DBEngine = BoltDBEngine
+
+	engine, err := NewStorageEngine(baseDir, namespace, config)
+	require.NoError(t, err)
+	// records written by the older releases, their hlc is the nanoseconds shifted by 16 bits.
+	for i, key := range []string{"legacy_1", "legacy_2"} {
+		legacy := walrecord.Record{
+			Index:        uint64(i + 1),
+			Hlc:          uint64(time.Now().UnixNano()) << 16,
+			Key:          []byte(key),
+			Value:        []byte("legacy_value"),
+			LogOperation: walrecord.LogOperationInsert,
+			EntryType:    walrecord.EntryTypeKV,
+		}
+		data, err := legacy.FBEncode()
+		require.NoError(t, err)
+		_, err = engine.walIO.Append(data)
+		require.NoError(t, err)
+	}
+	crashEngine(t, engine)
+
+	engine, err = NewStorageEngine(baseDir, namespace, config)
+	require.NoError(t, err)
+	assert.Equal(t, 2, engine.RecoveredWALCount())
+	require.NoError(t, engine.Put([]byte("versioned"), []byte("versioned_value")))
+	require.NoError(t, engine.Close(context.Background()))
+
+	engine, err = NewStorageEngine(baseDir, namespace, config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+	assert.WithinDuration(t, time.Now(), HLCTime(engine.Now()), DefaultMaxClockDrift)
+
+	reader, err := engine.NewReader()
+	require.NoError(t, err)
+	var records []*logcodec.LogRecord
+	for {
+		data, _, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		record, err := logcodec.DecodeRecord(data)
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+	require.Len(t, records, 3)
+	for i, record := range records {
+		assert.Equal(t, uint64(i+1), record.LSN)
+	}
+	assert.Zero(t, records[0].HLC)
+	assert.Zero(t, records[1].HLC)
+	assert.WithinDuration(t, time.Now(), HLCTime(records[2].HLC), DefaultMaxClockDrift)
+
+	for key, value := range map[string]string{"legacy_1": "legacy_value", "legacy_2": "legacy_value", "versioned": "versioned_value"} {
+		got, err := engine.Get([]byte(key))
+		require.NoError(t, err)
+		assert.Equal(t, []byte(value), got)
+	}
+	got, err := engine.GetAt([]byte("legacy_1"), records[2].HLC)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("legacy_value"), got)
+}
@@ -0,0 +1,58 @@
+package logcodec
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+var ErrInvalidBatchEntry = errors.New("invalid batch entry")
+
+// BatchPayload returns the payload of a batch record, every entry has the key of the operation
+// and the encoded record of the operation as its value.
+func BatchPayload(records []LogRecord) LogOperationData {
+	entries := make([]KeyValueEntry, len(records))
+	builder := flatbuffers.NewBuilder(1024)
+	for i := range records {
+		entries[i] = KeyValueEntry{Key: records[i].Key(), Value: records[i].encode(builder)}
+	}
+	return LogOperationData{
+		KeyValueBatchEntries: &KeyValueBatchEntries{Entries: entries},
+	}
+}
+
+// IsBatch reports if the record holds the records of a batch of operations.
+func (r *LogRecord) IsBatch() bool {
+	return r.OperationType == logrecord.LogOperationTypeBatch
+}
+
+// BatchLen returns the number of operations in the batch record.
+func (r *LogRecord) BatchLen() int {
+	if !r.IsBatch() || r.Payload.KeyValueBatchEntries == nil {
+		return 0
+	}
+	return len(r.Payload.KeyValueBatchEntries.Entries)
+}
+
+// BatchRecord decodes the record of the i-th operation of the batch record.
+func (r *LogRecord) BatchRecord(i int) (*LogRecord, error) {
+	if i < 0 || i >= r.BatchLen() {
+		return nil, fmt.Errorf("%w: %d of %d", ErrInvalidBatchEntry, i, r.BatchLen())
+	}
+	return DecodeRecord(r.Payload.KeyValueBatchEntries.Entries[i].Value)
+}
+
+// BatchRecords decodes the records of every operation of the batch record, in the order they were added.
+func (r *LogRecord) BatchRecords() ([]*LogRecord, error) {
+	records := make([]*LogRecord, r.BatchLen())
+	for i := range records {
+		record, err := r.BatchRecord(i)
+		if err != nil {
+			return nil, err
+		}
+		records[i] = record
+	}
+	return records, nil
+}
@@ -0,0 +1,60 @@
+package logcodec
+
+import (
+	"testing"
+
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchRecord(t *testing.T) {
+	records := []LogRecord{
+		{
+			LSN:           9,
+			OperationType: logrecord.LogOperationTypeInsert,
+			EntryType:     logrecord.LogEntryTypeKV,
+			Payload: LogOperationData{
+				KeyValueBatchEntries: &KeyValueBatchEntries{
+					Entries: []KeyValueEntry{{Key: []byte("key"), Value: []byte("value")}},
+				},
+			},
+		},
+		{
+			LSN:           9,
+			OperationType: logrecord.LogOperationTypeDelete,
+			EntryType:     logrecord.LogEntryTypeRow,
+			Payload: LogOperationData{
+				RowUpdateEntries: &RowUpdateEntries{
+					Entries: []RowUpdateEntry{{Key: []byte("row"), Columns: []ColumnData{{Name: "column"}}}},
+				},
+			},
+		},
+	}
+
+	batch := &LogRecord{
+		LSN:           9,
+		OperationType: logrecord.LogOperationTypeBatch,
+		Payload:       BatchPayload(records),
+	}
+	decoded, err := DecodeRecord(batch.Encode())
+	require.NoError(t, err)
+	assert.True(t, decoded.IsBatch())
+	require.Equal(t, 2, decoded.BatchLen())
+	assert.Equal(t, []byte("key"), decoded.Payload.KeyValueBatchEntries.Entries[0].Key)
+	assert.Equal(t, []byte("row"), decoded.Payload.KeyValueBatchEntries.Entries[1].Key)
+
+	batchRecords, err := decoded.BatchRecords()
+	require.NoError(t, err)
+	assert.Equal(t, logrecord.LogOperationTypeInsert, batchRecords[0].OperationType)
+	assert.Equal(t, []byte("key"), batchRecords[0].Key())
+	assert.Equal(t, []byte("value"), batchRecords[0].Value())
+	assert.Equal(t, logrecord.LogOperationTypeDelete, batchRecords[1].OperationType)
+	assert.Equal(t, logrecord.LogEntryTypeRow, batchRecords[1].EntryType)
+	assert.Equal(t, "column", batchRecords[1].Columns()[0].Name)
+
+	_, err = decoded.BatchRecord(2)
+	assert.ErrorIs(t, err, ErrInvalidBatchEntry)
+	assert.False(t, batchRecords[0].IsBatch())
+	assert.Equal(t, 0, batchRecords[0].BatchLen())
+}
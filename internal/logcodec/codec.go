@@ -25,6 +25,11 @@ func serializeLogRecord(record *LogRecord, builder *flatbuffers.Builder) []byte
 		prevTxnWalIndexOffset = builder.CreateByteVector(record.PrevTxnWalIndex)
 	}
 
+	var originOffset flatbuffers.UOffsetT
+	if record.Origin != "" {
+		originOffset = builder.CreateString(record.Origin)
+	}
+
 	var payloadType byte
 	var payloadOffset flatbuffers.UOffsetT
 
@@ -44,6 +49,7 @@ func serializeLogRecord(record *LogRecord, builder *flatbuffers.Builder) []byte
 	logrecord.LogRecordAddOperationType(builder, record.OperationType)
 	logrecord.LogRecordAddTxnState(builder, record.TxnState)
 	logrecord.LogRecordAddEntryType(builder, record.EntryType)
+	logrecord.LogRecordAddEpoch(builder, record.Epoch)
 
 	if len(record.TxnID) > 0 {
 		logrecord.LogRecordAddTxnId(builder, txnIDOffset)
@@ -53,6 +59,10 @@ func serializeLogRecord(record *LogRecord, builder *flatbuffers.Builder) []byte
 		logrecord.LogRecordAddPrevTxnWalIndex(builder, prevTxnWalIndexOffset)
 	}
 
+	if record.Origin != "" {
+		logrecord.LogRecordAddOrigin(builder, originOffset)
+	}
+
 	if payloadOffset != 0 {
 		logrecord.LogRecordAddPayloadType(builder, logrecord.LogOperationData(payloadType))
 		logrecord.LogRecordAddPayload(builder, payloadOffset)
@@ -165,6 +175,8 @@ func deserializeScalarIntoLogRecord(record *LogRecord, fbRecord *logrecord.LogRe
 	record.OperationType = fbRecord.OperationType()
 	record.TxnState = fbRecord.TxnState()
 	record.EntryType = fbRecord.EntryType()
+	record.Epoch = fbRecord.Epoch()
+	record.Origin = string(fbRecord.Origin())
 
 	if txnID := fbRecord.TxnIdBytes(); txnID != nil {
 		record.TxnID = make([]byte, len(txnID))
@@ -19,6 +19,8 @@ func TestKeyValueSerialization(t *testing.T) {
 		EntryType:       logrecord.LogEntryTypeKV,
 		TxnID:           []byte("transaction-001"),
 		PrevTxnWalIndex: nil,
+		Epoch:           3,
+		Origin:          "node-a",
 		Payload: LogOperationData{
 			KeyValueBatchEntries: &KeyValueBatchEntries{
 				Entries: []KeyValueEntry{
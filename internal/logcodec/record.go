@@ -43,6 +43,10 @@ type LogRecord struct {
 	TxnID           []byte
 	PrevTxnWalIndex []byte
 	Payload         LogOperationData
+	// Epoch of the namespace the record was written in, zero for the records written before any failover.
+	Epoch uint64
+	// Origin is the node the record was written on, empty unless the namespace is multi-leader.
+	Origin string
 }
 
 // FBEncode encodes the provided record into flat-buffer format.
@@ -50,3 +54,30 @@ func (r *LogRecord) FBEncode() []byte {
 	builder := flatbuffers.NewBuilder(1024)
 	return serializeLogRecord(r, builder)
 }
+
+// Key returns the key of the first entry of the payload, the record of a single key operation has only the one.
+func (r *LogRecord) Key() []byte {
+	switch {
+	case r.Payload.KeyValueBatchEntries != nil && len(r.Payload.KeyValueBatchEntries.Entries) > 0:
+		return r.Payload.KeyValueBatchEntries.Entries[0].Key
+	case r.Payload.RowUpdateEntries != nil && len(r.Payload.RowUpdateEntries.Entries) > 0:
+		return r.Payload.RowUpdateEntries.Entries[0].Key
+	}
+	return nil
+}
+
+// Value returns the value of the first key value entry of the payload.
+func (r *LogRecord) Value() []byte {
+	if r.Payload.KeyValueBatchEntries != nil && len(r.Payload.KeyValueBatchEntries.Entries) > 0 {
+		return r.Payload.KeyValueBatchEntries.Entries[0].Value
+	}
+	return nil
+}
+
+// Columns returns the columns of the first row entry of the payload.
+func (r *LogRecord) Columns() []ColumnData {
+	if r.Payload.RowUpdateEntries != nil && len(r.Payload.RowUpdateEntries.Entries) > 0 {
+		return r.Payload.RowUpdateEntries.Entries[0].Columns
+	}
+	return nil
+}
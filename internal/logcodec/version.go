@@ -0,0 +1,140 @@
+package logcodec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// FormatVersionV1 is the format of the records encoded as the LogRecord flat-buffer.
+//
+// Every record is written with the record magic followed by the format version. The records written before
+// the format version are walrecord flat-buffers, they start with the little endian offset of the root table.
+// A flat-buffer is at most 2GB, so its root offset never has the high bit of its last byte set,
+// as the magic does, and the magic is never mistaken for one.
+const FormatVersionV1 byte = 1
+
+// recordMagic prefixes the format version of every record.
+var recordMagic = [4]byte{'U', 'D', 'B', 0xFF}
+
+// recordHeaderSize is the size of the record magic and the format version.
+const recordHeaderSize = len(recordMagic) + 1
+
+var (
+	ErrEmptyRecord              = errors.New("empty log record")
+	ErrUnsupportedFormatVersion = errors.New("unsupported log record format version")
+)
+
+// Encode encodes the record as it's written to the WAL, the flat-buffer prefixed with the record magic
+// and the format version.
+func (r *LogRecord) Encode() []byte {
+	return r.encode(flatbuffers.NewBuilder(1024))
+}
+
+// encode encodes the record with the builder, the returned bytes are not backed by the builder.
+func (r *LogRecord) encode(builder *flatbuffers.Builder) []byte {
+	fb := serializeLogRecord(r, builder)
+	data := make([]byte, len(fb)+recordHeaderSize)
+	copy(data, recordMagic[:])
+	data[len(recordMagic)] = FormatVersionV1
+	copy(data[recordHeaderSize:], fb)
+	return data
+}
+
+// DecodeRecord decodes the record read from the WAL, of any of the format versions.
+func DecodeRecord(data []byte) (*LogRecord, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyRecord
+	}
+	if isLegacyRecord(data) {
+		return decodeWalRecord(data), nil
+	}
+
+	switch version := formatVersion(data); version {
+	case FormatVersionV1:
+		return DeserializeLogRecord(data[recordHeaderSize:]), nil
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedFormatVersion, version)
+	}
+}
+
+// isLegacyRecord reports if the record was written as walrecord, before the format version.
+func isLegacyRecord(data []byte) bool {
+	return !bytes.HasPrefix(data, recordMagic[:])
+}
+
+// formatVersion returns the format version of the record with the record magic, zero if it has none.
+func formatVersion(data []byte) byte {
+	if len(data) < recordHeaderSize {
+		return 0
+	}
+	return data[len(recordMagic)]
+}
+
+// decodeWalRecord converts the walrecord into the LogRecord, with the key and value or the columns
+// as the only entry of the payload.
+func decodeWalRecord(data []byte) *LogRecord {
+	wr := walrecord.GetRootAsWalRecord(data, 0)
+	// the hlc of the walrecord was the nanoseconds shifted by 16 bits, that overflow, so it can't be converted.
+	// It's left zero, the records are taken as made before every other timestamp.
+	record := &LogRecord{
+		LSN:             wr.Index(),
+		CRC32Checksum:   wr.Crc32Checksum(),
+		OperationType:   logrecord.LogOperationType(wr.Operation()),
+		TxnState:        logrecord.TransactionState(wr.TxnStatus()),
+		EntryType:       logrecord.LogEntryType(wr.EntryType()),
+		TxnID:           cloneNonEmpty(wr.TxnIdBytes()),
+		PrevTxnWalIndex: cloneNonEmpty(wr.PrevTxnWalIndexBytes()),
+	}
+
+	key := slices.Clone(wr.KeyBytes())
+	if wr.EntryType() != walrecord.EntryTypeRow {
+		record.Payload.KeyValueBatchEntries = &KeyValueBatchEntries{
+			Entries: []KeyValueEntry{{Key: key, Value: slices.Clone(wr.ValueBytes())}},
+		}
+		return record
+	}
+
+	columns := make([]ColumnData, wr.ColumnsLength())
+	for i := range columns {
+		var column walrecord.ColumnEntry
+		wr.Columns(&column, i)
+		columns[i] = ColumnData{
+			Name:  string(column.ColumnName()),
+			Value: slices.Clone(column.ColumnValueBytes()),
+		}
+	}
+	record.Payload.RowUpdateEntries = &RowUpdateEntries{
+		Entries: []RowUpdateEntry{{Key: key, Columns: columns}},
+	}
+	return record
+}
+
+func cloneNonEmpty(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return slices.Clone(b)
+}
+
+// DecodeLSN returns the lsn of the record read from the WAL, without decoding the payload.
+func DecodeLSN(data []byte) (uint64, error) {
+	if len(data) == 0 {
+		return 0, ErrEmptyRecord
+	}
+	if isLegacyRecord(data) {
+		return walrecord.GetRootAsWalRecord(data, 0).Index(), nil
+	}
+
+	switch version := formatVersion(data); version {
+	case FormatVersionV1:
+		return logrecord.GetRootAsLogRecord(data[recordHeaderSize:], 0).Lsn(), nil
+	default:
+		return 0, fmt.Errorf("%w: %d", ErrUnsupportedFormatVersion, version)
+	}
+}
@@ -0,0 +1,136 @@
+package logcodec
+
+import (
+	"testing"
+
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecodeRecord(t *testing.T) {
+	original := &LogRecord{
+		LSN:           42,
+		HLC:           gofakeit.Uint64(),
+		CRC32Checksum: gofakeit.Uint32(),
+		OperationType: logrecord.LogOperationTypeInsert,
+		TxnState:      logrecord.TransactionStateNone,
+		EntryType:     logrecord.LogEntryTypeKV,
+		Payload: LogOperationData{
+			KeyValueBatchEntries: &KeyValueBatchEntries{
+				Entries: []KeyValueEntry{{Key: []byte("key"), Value: []byte("value")}},
+			},
+		},
+	}
+
+	data := original.Encode()
+	assert.Equal(t, recordMagic[:], data[:len(recordMagic)])
+	assert.Equal(t, FormatVersionV1, data[len(recordMagic)])
+
+	decoded, err := DecodeRecord(data)
+	require.NoError(t, err)
+	assert.Equal(t, original.LSN, decoded.LSN)
+	assert.Equal(t, original.HLC, decoded.HLC)
+	assert.Equal(t, original.CRC32Checksum, decoded.CRC32Checksum)
+	assert.Equal(t, original.OperationType, decoded.OperationType)
+	assert.Equal(t, original.EntryType, decoded.EntryType)
+	assert.Equal(t, []byte("key"), decoded.Key())
+	assert.Equal(t, []byte("value"), decoded.Value())
+
+	lsn, err := DecodeLSN(data)
+	require.NoError(t, err)
+	assert.Equal(t, original.LSN, lsn)
+}
+
+func TestDecodeRecord_Legacy(t *testing.T) {
+	t.Run("kv", func(t *testing.T) {
+		legacy := walrecord.Record{
+			Index:        7,
+			Hlc:          gofakeit.Uint64(),
+			Key:          []byte("key"),
+			Value:        []byte("value"),
+			LogOperation: walrecord.LogOperationDelete,
+			EntryType:    walrecord.EntryTypeKV,
+			TxnStatus:    walrecord.TxnStatusCommit,
+			TxnID:        []byte("txn"),
+		}
+		data, err := legacy.FBEncode()
+		require.NoError(t, err)
+
+		decoded, err := DecodeRecord(data)
+		require.NoError(t, err)
+		assert.Equal(t, legacy.Index, decoded.LSN)
+		assert.Zero(t, decoded.HLC, "legacy hlc can't be converted")
+		assert.Equal(t, logrecord.LogOperationTypeDelete, decoded.OperationType)
+		assert.Equal(t, logrecord.LogEntryTypeKV, decoded.EntryType)
+		assert.Equal(t, logrecord.TransactionStateCommit, decoded.TxnState)
+		assert.Equal(t, legacy.TxnID, decoded.TxnID)
+		assert.Equal(t, legacy.Key, decoded.Key())
+		assert.Equal(t, legacy.Value, decoded.Value())
+
+		lsn, err := DecodeLSN(data)
+		require.NoError(t, err)
+		assert.Equal(t, legacy.Index, lsn)
+	})
+
+	t.Run("row", func(t *testing.T) {
+		legacy := walrecord.Record{
+			Index:         8,
+			Key:           []byte("row"),
+			LogOperation:  walrecord.LogOperationInsert,
+			EntryType:     walrecord.EntryTypeRow,
+			ColumnEntries: map[string][]byte{"a": []byte("1"), "b": []byte("2")},
+		}
+		data, err := legacy.FBEncode()
+		require.NoError(t, err)
+
+		decoded, err := DecodeRecord(data)
+		require.NoError(t, err)
+		assert.Equal(t, logrecord.LogEntryTypeRow, decoded.EntryType)
+		assert.Equal(t, legacy.Key, decoded.Key())
+		assert.Nil(t, decoded.Value())
+
+		columns := make(map[string][]byte)
+		for _, column := range decoded.Columns() {
+			columns[column.Name] = column.Value
+		}
+		assert.Equal(t, legacy.ColumnEntries, columns)
+	})
+}
+
+func TestDecodeRecord_Errors(t *testing.T) {
+	_, err := DecodeRecord(nil)
+	assert.ErrorIs(t, err, ErrEmptyRecord)
+	_, err = DecodeLSN(nil)
+	assert.ErrorIs(t, err, ErrEmptyRecord)
+
+	unsupported := append(recordMagic[:], 3, 0, 0, 0)
+	_, err = DecodeRecord(unsupported)
+	assert.ErrorIs(t, err, ErrUnsupportedFormatVersion)
+	_, err = DecodeLSN(unsupported)
+	assert.ErrorIs(t, err, ErrUnsupportedFormatVersion)
+	_, err = DecodeRecord(recordMagic[:])
+	assert.ErrorIs(t, err, ErrUnsupportedFormatVersion)
+}
+
+func TestIsLegacyRecord(t *testing.T) {
+	// every walrecord is legacy, whatever its root offset.
+	for i := range 64 {
+		legacy := walrecord.Record{
+			Index: uint64(i),
+			Key:   []byte(gofakeit.LetterN(uint(i))),
+			Value: []byte(gofakeit.LetterN(uint(i * 3))),
+		}
+		data, err := legacy.FBEncode()
+		require.NoError(t, err)
+		assert.True(t, isLegacyRecord(data))
+		lsn, err := DecodeLSN(data)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(i), lsn)
+	}
+
+	record := &LogRecord{LSN: 1, EntryType: logrecord.LogEntryTypeKV}
+	assert.False(t, isLegacyRecord(record.Encode()))
+}
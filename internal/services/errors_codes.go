@@ -16,10 +16,36 @@ var (
 	ErrMissingNamespaceInMetadata = errors.New("missing required metadata: x-namespace")
 	ErrStreamTimeout              = errors.New("stream timeout: no records received within dynamic threshold seconds")
 	ErrKeyNotFound                = status.Error(codes.NotFound, "key not found")
-	ErrPutChunkPrecondition       = errors.New("invalid sequence: StartMarker must be sent before sending chunks or calling CommitMarker")
-	ErrPutChunkCheckSumMismatch   = errors.New("invalid checksum: checksum mismatch")
-	ErrPutChunkAlreadyCommited    = errors.New("put chunk stream already commited")
-	ErrClientMaxRetriesExceeded   = errors.New("max retries exceeded")
+	ErrInvalidRange               = status.Error(codes.OutOfRange, "requested range not satisfiable")
+	// ErrInvalidRangeBounds is returned when the offset or the length of the range doesn't fit in an int64.
+	ErrInvalidRangeBounds = status.Error(codes.InvalidArgument, "range offset and length must not exceed max int64")
+	// ErrValueChanged is returned when the value is overwritten while it's streamed, the read can be retried.
+	ErrValueChanged             = status.Error(codes.Aborted, "value changed while it was read")
+	ErrPutChunkPrecondition     = errors.New("invalid sequence: StartMarker must be sent before sending chunks or calling CommitMarker")
+	ErrPutChunkCheckSumMismatch = errors.New("invalid checksum: checksum mismatch")
+	ErrPutChunkAlreadyCommited  = errors.New("put chunk stream already commited")
+	ErrClientMaxRetriesExceeded = errors.New("max retries exceeded")
+	ErrLSNNotRetained           = errors.New("lsn is before the first record retained in the wal")
+	ErrSnapshotNotFound         = errors.New("snapshot is not found, it's replaced by a newer one")
+	ErrSnapshotChecksumMismatch = errors.New("invalid checksum: snapshot chunk checksum mismatch")
+	// ErrSnapshotCorrupted is returned when the downloaded snapshot doesn't match the checksum of the snapshot,
+	// it's downloaded again.
+	ErrSnapshotCorrupted       = errors.New("invalid checksum: downloaded snapshot checksum mismatch")
+	ErrInvalidConsistencyToken = errors.New("invalid consistency token")
+	// ErrStaleEpoch is returned when the namespace on the server is on an older epoch than the request,
+	// the server was the primary before another node got promoted.
+	ErrStaleEpoch = errors.New("namespace is on a stale epoch on the server")
+	// ErrReplicaBehind is returned by the reads whose consistency token is ahead of the server till the deadline,
+	// the read can be retried.
+	ErrReplicaBehind = status.Error(codes.Unavailable, "server has not applied the write of the consistency token yet")
+	// ErrWriteLost is returned by the reads whose consistency token is of a write after the end of its epoch,
+	// the write was lost when another node got promoted.
+	ErrWriteLost = status.Error(codes.DataLoss, "write of the consistency token was lost in a failover of the namespace")
+	// ErrNotLeader is returned for the writes to a consensus namespace on a server that doesn't lead it.
+	ErrNotLeader = errors.New("server is not the leader of the namespace")
+	// ErrMembershipChangePending is returned when the membership of a consensus namespace is changed
+	// before its last change is committed.
+	ErrMembershipChangePending = errors.New("membership change is pending")
 )
 
 // ToGRPCError Convert business error to gRPC error.
@@ -40,6 +66,18 @@ func ToGRPCError(namespace string, reqID middleware.RequestID, method middleware
 		return status.Error(codes.FailedPrecondition, ErrPutChunkPrecondition.Error())
 	case errors.Is(err, ErrPutChunkCheckSumMismatch):
 		return status.Error(codes.DataLoss, ErrPutChunkCheckSumMismatch.Error())
+	case errors.Is(err, ErrLSNNotRetained):
+		return status.Error(codes.OutOfRange, ErrLSNNotRetained.Error())
+	case errors.Is(err, ErrSnapshotNotFound):
+		return status.Error(codes.NotFound, ErrSnapshotNotFound.Error())
+	case errors.Is(err, ErrInvalidConsistencyToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrStaleEpoch):
+		return status.Error(codes.FailedPrecondition, ErrStaleEpoch.Error())
+	case errors.Is(err, ErrNotLeader):
+		return status.Error(codes.FailedPrecondition, ErrNotLeader.Error())
+	case errors.Is(err, ErrMembershipChangePending):
+		return status.Error(codes.FailedPrecondition, ErrMembershipChangePending.Error())
 	case errors.Is(err, ErrPutChunkAlreadyCommited):
 		return status.Error(codes.Aborted, ErrPutChunkAlreadyCommited.Error())
 	default:
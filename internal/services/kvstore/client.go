@@ -6,11 +6,14 @@ import (
 	"fmt"
 	"hash/crc32"
 	"io"
+	"strconv"
+	"sync"
 
 	"github.com/ankur-anand/unisondb/internal/services"
 	"github.com/ankur-anand/unisondb/pkg/splitter"
 	v2 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
@@ -22,13 +25,30 @@ const (
 var (
 	ErrValueSizeLimitExceeded = errors.New("value size limit exceeded 1 MB")
 	ErrKeyNotFound            = errors.New("key not found")
+	ErrInvalidRange           = errors.New("requested range not satisfiable")
 	ErrMissingParameters      = errors.New("missing mandatory parameters [namespace|key]")
+	// ErrReplicaBehind is returned by GetKVAfter if the server has not applied the write of the token in time,
+	// the read can be retried.
+	ErrReplicaBehind = errors.New("server has not applied the write of the consistency token yet")
+	// ErrStaleEpoch is returned when the server is on an older epoch of the namespace than the writes
+	// the client has seen, it was the primary before another node got promoted.
+	ErrStaleEpoch = errors.New("namespace is on a stale epoch on the server")
+	// ErrNotLeader is returned for the writes to a consensus namespace on a server that doesn't lead it,
+	// the write can be sent to its leader.
+	ErrNotLeader = errors.New("server is not the leader of the namespace")
+	// ErrWriteLost is returned by GetKVAfter if the write of the token was lost in a failover of the namespace,
+	// the primary it was made on was replaced before the write was replicated.
+	ErrWriteLost = errors.New("write of the consistency token was lost in a failover of the namespace")
 )
 
 type Client struct {
 	gcc          *grpc.ClientConn
 	writerClient v2.KVStoreWriteServiceClient
 	readerClient v2.KVStoreReadServiceClient
+
+	mu sync.Mutex
+	// tokens are the consistency tokens of the latest writes made by the client, by the namespace.
+	tokens map[string][]byte
 }
 
 func NewClient(gcc *grpc.ClientConn) *Client {
@@ -36,7 +56,51 @@ func NewClient(gcc *grpc.ClientConn) *Client {
 		gcc:          gcc,
 		writerClient: v2.NewKVStoreWriteServiceClient(gcc),
 		readerClient: v2.NewKVStoreReadServiceClient(gcc),
+		tokens:       make(map[string][]byte),
+	}
+}
+
+// ConsistencyToken returns the token of the latest write made by the client to the namespace, nil if none.
+// A GetKVAfter with it, to any server of the namespace, reads the writes made by the client.
+func (c *Client) ConsistencyToken(namespace string) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens[namespace]
+}
+
+func (c *Client) recordToken(namespace string, token []byte) {
+	if len(token) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[namespace] = newerToken(c.tokens[namespace], token)
+}
+
+// writeContext returns the context of a write to the namespace, with the epoch of the namespace the client
+// has seen, so a primary replaced by the promotion of another node rejects the write.
+func (c *Client) writeContext(ctx context.Context, namespace string) context.Context {
+	md := metadata.Pairs("x-namespace", namespace)
+	if epoch := tokenEpoch(c.ConsistencyToken(namespace)); epoch > 0 {
+		md.Set(epochHeader, strconv.FormatUint(epoch, 10))
 	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// writeError returns the error of the failed write.
+func writeError(err error) error {
+	if isStaleEpoch(err) {
+		return ErrStaleEpoch
+	}
+	if sErr := status.Convert(err); sErr.Code() == codes.FailedPrecondition && sErr.Message() == services.ErrNotLeader.Error() {
+		return ErrNotLeader
+	}
+	return errors.New(status.Convert(err).Message())
+}
+
+func isStaleEpoch(err error) bool {
+	sErr := status.Convert(err)
+	return sErr.Code() == codes.FailedPrecondition && sErr.Message() == services.ErrStaleEpoch.Error()
 }
 
 // PutKV stores the given key-value pair in the specified namespace via gRPC.
@@ -48,19 +112,17 @@ func (c *Client) PutKV(ctx context.Context, namespace, key string, value []byte)
 		return ErrMissingParameters
 	}
 
-	md := metadata.Pairs("x-namespace", namespace)
-	ctx = metadata.NewOutgoingContext(ctx, md)
-
-	_, err := c.writerClient.Put(ctx, &v2.PutRequest{
+	ctx = c.writeContext(ctx, namespace)
+	res, err := c.writerClient.Put(ctx, &v2.PutRequest{
 		Key:   []byte(key),
 		Value: value,
 	})
 
 	if err != nil {
-		cErr := status.Convert(err)
-		return errors.New(cErr.Message())
+		return writeError(err)
 	}
 
+	c.recordToken(namespace, res.GetConsistencyToken())
 	return nil
 }
 
@@ -68,18 +130,16 @@ func (c *Client) DeleteKV(ctx context.Context, namespace, key string) error {
 	if namespace == "" || key == "" {
 		return ErrMissingParameters
 	}
-	md := metadata.Pairs("x-namespace", namespace)
-	ctx = metadata.NewOutgoingContext(ctx, md)
-
-	_, err := c.writerClient.Delete(ctx, &v2.DeleteRequest{
+	ctx = c.writeContext(ctx, namespace)
+	res, err := c.writerClient.Delete(ctx, &v2.DeleteRequest{
 		Key: []byte(key),
 	})
 
 	if err != nil {
-		cErr := status.Convert(err)
-		return errors.New(cErr.Message())
+		return writeError(err)
 	}
 
+	c.recordToken(namespace, res.GetConsistencyToken())
 	return nil
 }
 
@@ -97,8 +157,7 @@ func (c *Client) PutStreamChunksForKey(ctx context.Context, namespace, key strin
 		}
 	}
 
-	md := metadata.Pairs("x-namespace", namespace)
-	ctx = metadata.NewOutgoingContext(ctx, md)
+	ctx = c.writeContext(ctx, namespace)
 	stream, err := c.writerClient.PutStreamChunksForKey(ctx)
 	if err != nil {
 		cErr := status.Convert(err)
@@ -138,19 +197,51 @@ func (c *Client) PutStreamChunksForKey(ctx context.Context, namespace, key strin
 	}
 
 	// close the stream and wait for it to finish,
-	_, err = stream.CloseAndRecv()
-	return err
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		return writeError(err)
+	}
+	c.recordToken(namespace, res.GetConsistencyToken())
+	return nil
 }
 
 func (c *Client) GetKV(ctx context.Context, namespace, key string) ([]byte, error) {
 	if namespace == "" || key == "" {
 		return nil, ErrMissingParameters
 	}
-	md := metadata.Pairs("x-namespace", namespace)
-	ctx = metadata.NewOutgoingContext(ctx, md)
-	response, err := c.readerClient.Get(ctx, &v2.GetRequest{
+	return c.get(ctx, namespace, &v2.GetRequest{
 		Key: []byte(key),
 	})
+}
+
+// GetKVAfter returns the value associated with the key, once the server has applied the write of the consistency token.
+// It returns ErrReplicaBehind if the server doesn't catch up with the write before the deadline of the ctx.
+func (c *Client) GetKVAfter(ctx context.Context, namespace, key string, token []byte) ([]byte, error) {
+	if namespace == "" || key == "" {
+		return nil, ErrMissingParameters
+	}
+	return c.get(ctx, namespace, &v2.GetRequest{
+		Key:              []byte(key),
+		ConsistencyToken: token,
+	})
+}
+
+// GetKVRange returns length bytes of the value associated with the key, starting at offset.
+// Zero length reads till the end of the value.
+func (c *Client) GetKVRange(ctx context.Context, namespace, key string, offset, length uint64) ([]byte, error) {
+	if namespace == "" || key == "" {
+		return nil, ErrMissingParameters
+	}
+	return c.get(ctx, namespace, &v2.GetRequest{
+		Key:   []byte(key),
+		Range: &v2.ByteRange{Offset: offset, Length: length},
+	})
+}
+
+func (c *Client) get(ctx context.Context, namespace string, request *v2.GetRequest) ([]byte, error) {
+	md := metadata.Pairs("x-namespace", namespace)
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	response, err := c.readerClient.Get(ctx, request)
 
 	if err != nil {
 		cErr := status.Convert(err)
@@ -170,6 +261,18 @@ func (c *Client) GetKV(ctx context.Context, namespace, key string) ([]byte, erro
 			if errors.Is(err, services.ErrKeyNotFound) {
 				return nil, ErrKeyNotFound
 			}
+			if errors.Is(err, services.ErrInvalidRange) {
+				return nil, ErrInvalidRange
+			}
+			if errors.Is(err, services.ErrReplicaBehind) {
+				return nil, ErrReplicaBehind
+			}
+			if errors.Is(err, services.ErrWriteLost) {
+				return nil, ErrWriteLost
+			}
+			if isStaleEpoch(err) {
+				return nil, ErrStaleEpoch
+			}
 			cErr := status.Convert(err)
 			return nil, errors.New(cErr.Message())
 		}
@@ -181,3 +284,52 @@ func (c *Client) GetKV(ctx context.Context, namespace, key string) ([]byte, erro
 
 	return splitter.AssembleChunks(chunks), nil
 }
+
+// History returns the changes of the key made between the from and to hybrid logical clock timestamp.
+// Zero to returns till the latest change, and zero limit returns every change.
+func (c *Client) History(ctx context.Context, namespace, key string, from, to uint64, limit uint32) ([]*v2.ChangeEvent, error) {
+	if namespace == "" || key == "" {
+		return nil, ErrMissingParameters
+	}
+
+	md := metadata.Pairs("x-namespace", namespace)
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	response, err := c.readerClient.History(ctx, &v2.HistoryRequest{
+		Key:     []byte(key),
+		FromHlc: from,
+		ToHlc:   to,
+		Limit:   limit,
+	})
+	if err != nil {
+		cErr := status.Convert(err)
+		return nil, errors.New(cErr.Message())
+	}
+
+	var events []*v2.ChangeEvent
+	// partial is the event whose value is still being received.
+	var partial *v2.ChangeEvent
+	for {
+		msg, err := response.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return events, nil
+			}
+			cErr := status.Convert(err)
+			return nil, errors.New(cErr.Message())
+		}
+
+		event := msg.GetEvent()
+		hasMore := event.GetHasMore()
+		if partial != nil {
+			partial.Value = append(partial.Value, event.GetValue()...)
+			event = partial
+		}
+		partial = nil
+		if hasMore {
+			partial = event
+			continue
+		}
+		event.HasMore = false
+		events = append(events, event)
+	}
+}
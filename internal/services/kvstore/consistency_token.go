@@ -0,0 +1,159 @@
+package kvstore
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	storage "github.com/ankur-anand/unisondb/dbkernel"
+	"github.com/ankur-anand/unisondb/internal/services"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	consistencyTokenVersion = 1
+	// maxConsistencyWait is the time a Get waits for the write of its consistency token,
+	// if the request has no earlier deadline.
+	maxConsistencyWait = 5 * time.Second
+	// consistencyWaitMargin is left of the deadline of the request, so the Get answers with
+	// services.ErrReplicaBehind before the deadline expires.
+	consistencyWaitMargin = 50 * time.Millisecond
+	// epochHeader is the metadata of the writes with the epoch of the namespace the client has seen.
+	epochHeader = "x-epoch"
+)
+
+// consistencyToken is the position of a write in the wal of its namespace, the client gets it as opaque bytes.
+type consistencyToken struct {
+	namespace string
+	// epoch of the namespace the write was made in, zero till the namespace is failed over.
+	epoch uint64
+	lsn   uint64
+}
+
+// newConsistencyToken returns the token of the write with the lsn made to the engine.
+func newConsistencyToken(engine *storage.Engine, lsn uint64) []byte {
+	token := consistencyToken{namespace: engine.Namespace(), epoch: engine.EpochAt(lsn), lsn: lsn}
+	return token.encode()
+}
+
+func (t consistencyToken) encode() []byte {
+	buf := make([]byte, 0, 1+2*binary.MaxVarintLen64+len(t.namespace))
+	buf = append(buf, consistencyTokenVersion)
+	buf = binary.AppendUvarint(buf, t.epoch)
+	buf = binary.AppendUvarint(buf, t.lsn)
+	return append(buf, t.namespace...)
+}
+
+func decodeConsistencyToken(data []byte) (consistencyToken, error) {
+	var token consistencyToken
+	if len(data) == 0 || data[0] != consistencyTokenVersion {
+		return token, services.ErrInvalidConsistencyToken
+	}
+	data = data[1:]
+	for _, v := range []*uint64{&token.epoch, &token.lsn} {
+		n := 0
+		*v, n = binary.Uvarint(data)
+		if n <= 0 {
+			return token, services.ErrInvalidConsistencyToken
+		}
+		data = data[n:]
+	}
+	token.namespace = string(data)
+	return token, nil
+}
+
+// newerToken returns the token of the later write, the tokens of another namespace are not compared.
+func newerToken(a, b []byte) []byte {
+	ta, errA := decodeConsistencyToken(a)
+	tb, errB := decodeConsistencyToken(b)
+	switch {
+	case errA != nil:
+		return b
+	case errB != nil:
+		return a
+	case tb.epoch > ta.epoch || (tb.epoch == ta.epoch && tb.lsn > ta.lsn):
+		return b
+	default:
+		return a
+	}
+}
+
+// waitForConsistencyToken blocks till the engine has applied the write of the token, empty token returns right away.
+func waitForConsistencyToken(ctx context.Context, engine *storage.Engine, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	token, err := decodeConsistencyToken(data)
+	if err != nil {
+		return err
+	}
+	if token.namespace != engine.Namespace() {
+		return fmt.Errorf("%w: token of namespace %q", services.ErrInvalidConsistencyToken, token.namespace)
+	}
+	if err := checkWriteLost(engine, token); err != nil {
+		return err
+	}
+
+	wait := maxConsistencyWait
+	if deadline, ok := ctx.Deadline(); ok {
+		wait = min(wait, time.Until(deadline)-consistencyWaitMargin)
+	}
+	err = engine.WaitForLSN(ctx, wait, token.lsn)
+	switch {
+	case errors.Is(err, storage.ErrWaitTimeoutExceeded):
+		return services.ErrReplicaBehind
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+	if err != nil {
+		return err
+	}
+	// the server has the lsn of the write from an older primary, it's not following the new one yet.
+	if engine.Epoch() < token.epoch {
+		return services.ErrStaleEpoch
+	}
+	return checkWriteLost(engine, token)
+}
+
+// checkWriteLost returns services.ErrWriteLost if the engine is on a newer epoch than the token, and the epoch
+// of the token ended before its write. The records the engine has at the lsn of the token are of the new epoch.
+func checkWriteLost(engine *storage.Engine, token consistencyToken) error {
+	if engine.Epoch() > token.epoch && engine.EpochEnd(token.epoch) < token.lsn {
+		return services.ErrWriteLost
+	}
+	return nil
+}
+
+// checkEpoch rejects the writes of the clients that have seen a newer epoch of the namespace than the engine,
+// the engine is on a primary that was replaced by the promotion of another node.
+func checkEpoch(ctx context.Context, engine *storage.Engine) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	values := md.Get(epochHeader)
+	if len(values) == 0 {
+		return nil
+	}
+	epoch, err := strconv.ParseUint(values[0], 10, 64)
+	if err != nil {
+		return services.ErrInvalidMetadata
+	}
+	if epoch > engine.Epoch() {
+		return services.ErrStaleEpoch
+	}
+	return nil
+}
+
+// tokenEpoch returns the epoch of the token, zero if it's not a valid token.
+func tokenEpoch(data []byte) uint64 {
+	token, err := decodeConsistencyToken(data)
+	if err != nil {
+		return 0
+	}
+	return token.epoch
+}
@@ -3,15 +3,26 @@ package kvstore
 import (
 	"errors"
 	"hash/crc32"
+	"io"
+	"math"
+	"sync"
 
 	storage "github.com/ankur-anand/unisondb/dbkernel"
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
 	"github.com/ankur-anand/unisondb/internal/middleware"
 	"github.com/ankur-anand/unisondb/internal/services"
-	"github.com/ankur-anand/unisondb/pkg/splitter"
 	v2 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 )
 
+var readBufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, capValueSize)
+		return &buf
+	},
+}
+
 type KVReaderService struct {
 	storageEngines map[string]*storage.Engine
 	v2.UnimplementedKVStoreReadServiceServer
@@ -35,7 +46,19 @@ func (k *KVReaderService) Get(request *v2.GetRequest, g grpc.ServerStreamingServ
 		return services.ToGRPCError(namespace, reqID, method, services.ErrNamespaceNotExists)
 	}
 
-	value, err := engine.Get(request.GetKey())
+	if err := waitForConsistencyToken(g.Context(), engine, request.GetConsistencyToken()); err != nil {
+		// already a status, the replica being behind is retried by the client.
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return services.ToGRPCError(namespace, reqID, method, err)
+	}
+
+	if request.GetRange() != nil {
+		return k.getRange(engine, request, g)
+	}
+
+	reader, err := engine.GetReader(request.GetKey())
 	if errors.Is(err, storage.ErrKeyNotFound) {
 		return services.ErrKeyNotFound
 	}
@@ -43,32 +66,151 @@ func (k *KVReaderService) Get(request *v2.GetRequest, g grpc.ServerStreamingServ
 	if err != nil {
 		return services.ToGRPCError(namespace, reqID, method, err)
 	}
+	defer reader.Close()
 
-	// if the value is too large then the
-	if len(value) < capValueSize {
-		err := g.SendMsg(&v2.GetResponse{
-			Data:               value,
-			FinalCrc32Checksum: crc32.ChecksumIEEE(value),
-			Chunked:            false,
+	// read at most capValueSize at a time, so the value is never fully buffered.
+	bufPtr := readBufferPool.Get().(*[]byte)
+	defer readBufferPool.Put(bufPtr)
+	buf := *bufPtr
+
+	chunked := false
+	for {
+		n, err := io.ReadFull(reader, buf)
+		// an empty value is still sent once.
+		if errors.Is(err, io.EOF) && chunked {
+			return nil
+		}
+
+		last := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if errors.Is(err, storage.ErrValueChanged) {
+			return services.ErrValueChanged
+		}
+		if err != nil && !last {
+			return services.ToGRPCError(namespace, reqID, method, err)
+		}
+
+		// if the value is small enough it's sent as is.
+		chunked = chunked || !last
+		err = g.SendMsg(&v2.GetResponse{
+			Data:               buf[:n],
+			FinalCrc32Checksum: reader.Checksum(),
+			Chunked:            chunked,
 		})
 		if err != nil {
 			return services.ToGRPCError(namespace, reqID, method, err)
 		}
-		return nil
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// getRange sends the requested range of the value, in messages of at most capValueSize.
+func (k *KVReaderService) getRange(engine *storage.Engine, request *v2.GetRequest, g grpc.ServerStreamingServer[v2.GetResponse]) error {
+	namespace, reqID, method := middleware.GetRequestInfo(g.Context())
+
+	// larger values would turn negative as int64.
+	offset, length := request.GetRange().GetOffset(), request.GetRange().GetLength()
+	if offset > math.MaxInt64 || length > math.MaxInt64 {
+		return services.ErrInvalidRangeBounds
 	}
 
-	checksum := crc32.ChecksumIEEE(value)
-	chunks := splitter.SplitIntoChunks(value)
-	for _, chunk := range chunks {
-		err := g.SendMsg(&v2.GetResponse{
-			Data:               chunk.Data,
+	data, err := engine.GetRange(request.GetKey(), int64(offset), int64(length))
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return services.ErrKeyNotFound
+	}
+
+	if errors.Is(err, storage.ErrInvalidRange) {
+		return services.ErrInvalidRange
+	}
+
+	if errors.Is(err, storage.ErrValueChanged) {
+		return services.ErrValueChanged
+	}
+
+	if err != nil {
+		return services.ToGRPCError(namespace, reqID, method, err)
+	}
+
+	checksum := crc32.ChecksumIEEE(data)
+	chunked := len(data) > capValueSize
+	for {
+		n := min(len(data), capValueSize)
+		err = g.SendMsg(&v2.GetResponse{
+			Data:               data[:n],
 			FinalCrc32Checksum: checksum,
-			Chunked:            true,
+			Chunked:            chunked,
 		})
 		if err != nil {
 			return services.ToGRPCError(namespace, reqID, method, err)
 		}
+
+		data = data[n:]
+		if len(data) == 0 {
+			return nil
+		}
+	}
+}
+
+var changeOperations = map[walrecord.LogOperation]v2.ChangeOperation{
+	walrecord.LogOperationInsert:    v2.ChangeOperation_CHANGE_OPERATION_INSERT,
+	walrecord.LogOperationDelete:    v2.ChangeOperation_CHANGE_OPERATION_DELETE,
+	walrecord.LogOperationDeleteRow: v2.ChangeOperation_CHANGE_OPERATION_DELETE_ROW,
+}
+
+var changeEntryTypes = map[walrecord.EntryType]v2.ChangeEntryType{
+	walrecord.EntryTypeKV:      v2.ChangeEntryType_CHANGE_ENTRY_TYPE_KV,
+	walrecord.EntryTypeChunked: v2.ChangeEntryType_CHANGE_ENTRY_TYPE_CHUNKED,
+	walrecord.EntryTypeRow:     v2.ChangeEntryType_CHANGE_ENTRY_TYPE_ROW,
+}
+
+// History sends the changes of the key, a value larger than capValueSize is sent in multiple messages.
+func (k *KVReaderService) History(request *v2.HistoryRequest, g grpc.ServerStreamingServer[v2.HistoryResponse]) error {
+	namespace, reqID, method := middleware.GetRequestInfo(g.Context())
+
+	if namespace == "" {
+		return services.ToGRPCError(namespace, reqID, method, services.ErrMissingNamespaceInMetadata)
+	}
+
+	engine, ok := k.storageEngines[namespace]
+	if !ok {
+		return services.ToGRPCError(namespace, reqID, method, services.ErrNamespaceNotExists)
+	}
+
+	to := request.GetToHlc()
+	if to == 0 {
+		to = math.MaxUint64
 	}
 
+	events, err := engine.History(request.GetKey(), request.GetFromHlc(), to, int(request.GetLimit()))
+	if err != nil {
+		return services.ToGRPCError(namespace, reqID, method, err)
+	}
+
+	for _, event := range events {
+		value := event.Value
+		for {
+			n := min(len(value), capValueSize)
+			err := g.Send(&v2.HistoryResponse{Event: &v2.ChangeEvent{
+				Operation: changeOperations[event.Operation],
+				EntryType: changeEntryTypes[event.EntryType],
+				Value:     value[:n],
+				Columns:   event.Columns,
+				Hlc:       event.HLC,
+				Index:     event.Index,
+				TxnId:     event.TxnID,
+				HasMore:   n < len(value),
+			}})
+			if err != nil {
+				return services.ToGRPCError(namespace, reqID, method, err)
+			}
+
+			value = value[n:]
+			if len(value) == 0 {
+				break
+			}
+		}
+	}
 	return nil
 }
@@ -38,12 +38,16 @@ func (k *KVWriterService) Put(ctx context.Context, request *v2.PutRequest) (*v2.
 	if !ok {
 		return nil, services.ToGRPCError(namespace, reqID, method, services.ErrNamespaceNotExists)
 	}
-
-	if err := engine.Put(request.Key, request.Value); err != nil {
+	if err := checkEpoch(ctx, engine); err != nil {
 		return nil, services.ToGRPCError(namespace, reqID, method, err)
 	}
 
-	return &v2.PutResponse{}, nil
+	lsn, err := engine.PutLSN(request.Key, request.Value)
+	if err != nil {
+		return nil, services.ToGRPCError(namespace, reqID, method, engineError(err))
+	}
+
+	return &v2.PutResponse{ConsistencyToken: newConsistencyToken(engine, lsn)}, nil
 }
 
 func (k *KVWriterService) PutStream(g grpc.ClientStreamingServer[v2.PutStreamRequest, v2.PutStreamResponse]) error {
@@ -56,10 +60,18 @@ func (k *KVWriterService) PutStream(g grpc.ClientStreamingServer[v2.PutStreamReq
 	if !ok {
 		return services.ToGRPCError(namespace, reqID, method, services.ErrNamespaceNotExists)
 	}
+	if err := checkEpoch(g.Context(), engine); err != nil {
+		return services.ToGRPCError(namespace, reqID, method, err)
+	}
 
+	// lsn of the last write of the stream.
+	var lsn uint64
 	for {
 		msg, err := g.Recv()
-		if errors.Is(err, context.Canceled) || errors.Is(err, io.EOF) {
+		if errors.Is(err, io.EOF) {
+			return g.SendAndClose(&v2.PutStreamResponse{ConsistencyToken: newConsistencyToken(engine, lsn)})
+		}
+		if errors.Is(err, context.Canceled) {
 			return nil
 		}
 
@@ -68,8 +80,9 @@ func (k *KVWriterService) PutStream(g grpc.ClientStreamingServer[v2.PutStreamReq
 		}
 
 		for _, putReq := range msg.KvPairs {
-			if err := engine.Put(putReq.Key, putReq.Value); err != nil {
-				return services.ToGRPCError(namespace, reqID, method, err)
+			lsn, err = engine.PutLSN(putReq.Key, putReq.Value)
+			if err != nil {
+				return services.ToGRPCError(namespace, reqID, method, engineError(err))
 			}
 		}
 	}
@@ -88,6 +101,9 @@ func (k *KVWriterService) PutStreamChunksForKey(g grpc.ClientStreamingServer[v2.
 	if !ok {
 		return services.ToGRPCError(namespace, reqID, method, services.ErrNamespaceNotExists)
 	}
+	if err := checkEpoch(g.Context(), engine); err != nil {
+		return services.ToGRPCError(namespace, reqID, method, err)
+	}
 
 	var txn *storage.Txn
 	var committed bool
@@ -112,11 +128,11 @@ func (k *KVWriterService) PutStreamChunksForKey(g grpc.ClientStreamingServer[v2.
 			switch req := msg.GetRequestType().(type) {
 			case *v2.PutStreamChunksForKeyRequest_StartMarker:
 				key = req.StartMarker.GetKey()
-				txn, err = k.handleStartMarker(engine, g, req)
+				txn, err = k.handleStartMarker(engine)
 			case *v2.PutStreamChunksForKeyRequest_Chunk:
 				err = k.handleChunk(txn, key, req)
 			case *v2.PutStreamChunksForKeyRequest_CommitMarker:
-				err = k.handleCommitMarker(txn, req)
+				err = k.handleCommitMarker(engine, g, txn, req)
 				committed = err == nil
 			}
 
@@ -127,19 +143,8 @@ func (k *KVWriterService) PutStreamChunksForKey(g grpc.ClientStreamingServer[v2.
 	}
 }
 
-func (k *KVWriterService) handleStartMarker(engine *storage.Engine,
-	g grpc.ClientStreamingServer[v2.PutStreamChunksForKeyRequest, v2.PutStreamChunksForKeyResponse],
-	req *v2.PutStreamChunksForKeyRequest_StartMarker) (*storage.Txn, error) {
-	batch, err := engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeChunked)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := g.SendMsg(&v2.PutStreamChunksForKeyResponse{}); err != nil {
-		return nil, err
-	}
-
-	return batch, nil
+func (k *KVWriterService) handleStartMarker(engine *storage.Engine) (*storage.Txn, error) {
+	return engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeChunked)
 }
 
 func (k *KVWriterService) handleChunk(txn *storage.Txn, key []byte, req *v2.PutStreamChunksForKeyRequest_Chunk) error {
@@ -151,8 +156,10 @@ func (k *KVWriterService) handleChunk(txn *storage.Txn, key []byte, req *v2.PutS
 	return txn.AppendKVTxn(key, value)
 }
 
-func (k *KVWriterService) handleCommitMarker(txn *storage.Txn,
-	req *v2.PutStreamChunksForKeyRequest_CommitMarker) error {
+// handleCommitMarker commits the txn, the response with the consistency token of the value is sent once it's committed.
+func (k *KVWriterService) handleCommitMarker(engine *storage.Engine,
+	g grpc.ClientStreamingServer[v2.PutStreamChunksForKeyRequest, v2.PutStreamChunksForKeyResponse],
+	txn *storage.Txn, req *v2.PutStreamChunksForKeyRequest_CommitMarker) error {
 	if txn == nil {
 		return services.ErrPutChunkPrecondition
 	}
@@ -162,7 +169,10 @@ func (k *KVWriterService) handleCommitMarker(txn *storage.Txn,
 		return services.ErrPutChunkCheckSumMismatch
 	}
 
-	return txn.Commit()
+	if err := txn.Commit(); err != nil {
+		return err
+	}
+	return g.SendMsg(&v2.PutStreamChunksForKeyResponse{ConsistencyToken: newConsistencyToken(engine, txn.CommitLSN())})
 }
 
 func (k *KVWriterService) Delete(ctx context.Context, request *v2.DeleteRequest) (*v2.DeleteResponse, error) {
@@ -175,11 +185,15 @@ func (k *KVWriterService) Delete(ctx context.Context, request *v2.DeleteRequest)
 	if !ok {
 		return nil, services.ToGRPCError(namespace, reqID, method, services.ErrNamespaceNotExists)
 	}
-	if err := engine.Delete(request.Key); err != nil {
+	if err := checkEpoch(ctx, engine); err != nil {
 		return nil, services.ToGRPCError(namespace, reqID, method, err)
 	}
+	lsn, err := engine.DeleteLSN(request.Key)
+	if err != nil {
+		return nil, services.ToGRPCError(namespace, reqID, method, engineError(err))
+	}
 
-	return &v2.DeleteResponse{}, nil
+	return &v2.DeleteResponse{ConsistencyToken: newConsistencyToken(engine, lsn)}, nil
 }
 
 func (k *KVWriterService) DeleteStream(g grpc.ClientStreamingServer[v2.DeleteStreamRequest, v2.DeleteStreamResponse]) error {
@@ -192,10 +206,18 @@ func (k *KVWriterService) DeleteStream(g grpc.ClientStreamingServer[v2.DeleteStr
 	if !ok {
 		return services.ToGRPCError(namespace, reqID, method, services.ErrNamespaceNotExists)
 	}
+	if err := checkEpoch(g.Context(), engine); err != nil {
+		return services.ToGRPCError(namespace, reqID, method, err)
+	}
 
+	// lsn of the last write of the stream.
+	var lsn uint64
 	for {
 		msg, err := g.Recv()
-		if errors.Is(err, context.Canceled) || errors.Is(err, io.EOF) {
+		if errors.Is(err, io.EOF) {
+			return g.SendAndClose(&v2.DeleteStreamResponse{ConsistencyToken: newConsistencyToken(engine, lsn)})
+		}
+		if errors.Is(err, context.Canceled) {
 			return nil
 		}
 
@@ -204,9 +226,18 @@ func (k *KVWriterService) DeleteStream(g grpc.ClientStreamingServer[v2.DeleteStr
 		}
 
 		for _, delReq := range msg.Deletes {
-			if err := engine.Delete(delReq.Key); err != nil {
-				return services.ToGRPCError(namespace, reqID, method, err)
+			lsn, err = engine.DeleteLSN(delReq.Key)
+			if err != nil {
+				return services.ToGRPCError(namespace, reqID, method, engineError(err))
 			}
 		}
 	}
 }
+
+// engineError returns the service error of the failed write of the engine.
+func engineError(err error) error {
+	if errors.Is(err, storage.ErrNotLeader) {
+		return services.ErrNotLeader
+	}
+	return err
+}
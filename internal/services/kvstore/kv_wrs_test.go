@@ -3,11 +3,16 @@ package kvstore_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"hash/crc32"
+	"io"
+	"math"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	storage "github.com/ankur-anand/unisondb/dbkernel"
 	"github.com/ankur-anand/unisondb/internal/middleware"
@@ -16,8 +21,12 @@ import (
 	"github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 )
 
@@ -127,6 +136,20 @@ func TestClient_PutKV_GetKV_DeleteKV(t *testing.T) {
 			value, err := client.GetKV(ctx, nameSpaces[0], k)
 			assert.NoError(t, err, "failed to get kv")
 			assert.Equal(t, v, string(value), "value mismatch")
+
+			value, err = client.GetKVRange(ctx, nameSpaces[0], k, 1, 3)
+			assert.NoError(t, err, "failed to get kv range")
+			assert.Equal(t, v[1:4], string(value), "range value mismatch")
+
+			// the length past the end of the value must not overflow.
+			value, err = client.GetKVRange(ctx, nameSpaces[0], k, 1, math.MaxInt64)
+			assert.NoError(t, err, "failed to get kv range")
+			assert.Equal(t, v[1:], string(value), "range value mismatch")
+
+			_, err = client.GetKVRange(ctx, nameSpaces[0], k, 1, math.MaxUint64)
+			assert.ErrorContains(t, err, "range offset and length must not exceed max int64")
+			_, err = client.GetKVRange(ctx, nameSpaces[0], k, math.MaxUint64, 1)
+			assert.ErrorContains(t, err, "range offset and length must not exceed max int64")
 		}
 
 	})
@@ -145,6 +168,22 @@ func TestClient_PutKV_GetKV_DeleteKV(t *testing.T) {
 		}
 	})
 
+	t.Run("history", func(t *testing.T) {
+		for k, v := range keyValue {
+			events, err := client.History(ctx, nameSpaces[0], k, 0, 0, 0)
+			assert.NoError(t, err, "failed to get history")
+			if assert.Len(t, events, 2) {
+				assert.Equal(t, v1.ChangeOperation_CHANGE_OPERATION_INSERT, events[0].GetOperation())
+				assert.Equal(t, v, string(events[0].GetValue()), "value mismatch")
+				assert.Equal(t, v1.ChangeOperation_CHANGE_OPERATION_DELETE, events[1].GetOperation())
+			}
+
+			events, err = client.History(ctx, nameSpaces[0], k, 0, 0, 1)
+			assert.NoError(t, err, "failed to get history")
+			assert.Len(t, events, 1)
+		}
+	})
+
 	t.Run("value_greater_than_1MB", func(t *testing.T) {
 		data := GenerateTestData(5 * chunkSizeMB)
 		err = client.PutKV(ctx, "random", "key", data)
@@ -299,6 +338,27 @@ func TestClient_PutStreamChunksForKey(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, assembledValue, value, "value mismatch")
 		assert.Equal(t, checksum, splitter.ComputeChecksum(value), "checksum mismatch")
+
+		// chunked value is a single event, sent in multiple messages.
+		events, err := client.History(ctx, nameSpaces[0], key, 0, 0, 0)
+		assert.NoError(t, err)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, v1.ChangeEntryType_CHANGE_ENTRY_TYPE_CHUNKED, events[0].GetEntryType())
+			assert.Equal(t, assembledValue, events[0].GetValue(), "history value mismatch")
+			assert.NotEmpty(t, events[0].GetTxnId())
+		}
+
+		// range spanning over the chunk boundary, larger than a single message.
+		value, err = client.GetKVRange(ctx, nameSpaces[0], key, chunkSizeMB/2, 2*chunkSizeMB)
+		assert.NoError(t, err)
+		assert.Equal(t, assembledValue[chunkSizeMB/2:chunkSizeMB/2+2*chunkSizeMB], value, "range value mismatch")
+
+		value, err = client.GetKVRange(ctx, nameSpaces[0], key, uint64(len(assembledValue)-10), 0)
+		assert.NoError(t, err)
+		assert.Equal(t, assembledValue[len(assembledValue)-10:], value, "range value mismatch")
+
+		_, err = client.GetKVRange(ctx, nameSpaces[0], key, uint64(len(assembledValue)), 0)
+		assert.ErrorIs(t, err, kvstore.ErrInvalidRange)
 	})
 
 	t.Run("large_chunk_exceed_cap", func(t *testing.T) {
@@ -317,3 +377,137 @@ func TestClient_PutStreamChunksForKey(t *testing.T) {
 
 	})
 }
+
+// serveKV serves the engines over bufconn, and returns the client connected to them.
+func serveKV(t *testing.T, engines map[string]*storage.Engine) *kvstore.Client {
+	t.Helper()
+	return kvstore.NewClient(serveKVConn(t, engines))
+}
+
+// serveKVConn serves the kv store of the engines over bufconn, and returns the client connection to it.
+func serveKVConn(t *testing.T, engines map[string]*storage.Engine) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(listenerBuffSize)
+	gS := grpc.NewServer(grpc.ChainStreamInterceptor(middleware.RequireNamespaceInterceptor,
+		middleware.RequestIDStreamInterceptor,
+		middleware.MethodInterceptor),
+		grpc.ChainUnaryInterceptor(middleware.RequestIDUnaryInterceptor,
+			middleware.MethodUnaryInterceptor,
+		))
+	v1.RegisterKVStoreReadServiceServer(gS, kvstore.NewKVReaderService(engines))
+	v1.RegisterKVStoreWriteServiceServer(gS, kvstore.NewKVWriterService(engines))
+	go func() {
+		_ = gS.Serve(listener)
+	}()
+
+	conn, err := grpc.NewClient("passthrough://bufnet", grpc.WithContextDialer(bufDialer(listener)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		gS.Stop()
+		_ = listener.Close()
+	})
+	return conn
+}
+
+func TestClient_ConsistencyToken(t *testing.T) {
+	namespace := "consistency"
+	config := storage.NewDefaultEngineConfig()
+	primary, err := storage.NewStorageEngine(t.TempDir(), namespace, config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, primary.Close(context.Background()))
+	})
+	config.Replica = true
+	replica, err := storage.NewStorageEngine(t.TempDir(), namespace, config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, replica.Close(context.Background()))
+	})
+
+	primaryConn := serveKVConn(t, map[string]*storage.Engine{namespace: primary})
+	primaryClient := kvstore.NewClient(primaryConn)
+	replicaClient := serveKV(t, map[string]*storage.Engine{namespace: replica})
+	ctx := context.Background()
+
+	assert.Nil(t, primaryClient.ConsistencyToken(namespace))
+	require.NoError(t, primaryClient.PutKV(ctx, namespace, "key", []byte("value")))
+	token := primaryClient.ConsistencyToken(namespace)
+	require.NotEmpty(t, token)
+
+	value, err := primaryClient.GetKVAfter(ctx, namespace, "key", token)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	t.Run("replica_behind", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer cancel()
+		_, err := replicaClient.GetKVAfter(ctx, namespace, "key", token)
+		assert.ErrorIs(t, err, kvstore.ErrReplicaBehind)
+	})
+
+	t.Run("replica_catches_up", func(t *testing.T) {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			reader, err := primary.NewReader()
+			if !assert.NoError(t, err) {
+				return
+			}
+			for {
+				data, pos, err := reader.Next()
+				if errors.Is(err, io.EOF) {
+					return
+				}
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.NoError(t, replica.ApplyReplicated(pos.Encode(), data, crc32.ChecksumIEEE(data)))
+			}
+		}()
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		value, err := replicaClient.GetKVAfter(ctx, namespace, "key", token)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("value"), value)
+	})
+
+	t.Run("later_write", func(t *testing.T) {
+		require.NoError(t, primaryClient.DeleteKV(ctx, namespace, "key"))
+		deleted := primaryClient.ConsistencyToken(namespace)
+		assert.NotEqual(t, token, deleted)
+		require.NoError(t, primaryClient.PutStreamChunksForKey(ctx, namespace, "chunked", [][]byte{[]byte("chunk")}))
+		assert.NotEqual(t, deleted, primaryClient.ConsistencyToken(namespace))
+	})
+
+	t.Run("invalid_token", func(t *testing.T) {
+		_, err := primaryClient.GetKVAfter(ctx, namespace, "key", []byte("invalid"))
+		assert.ErrorContains(t, err, "invalid consistency token")
+	})
+	t.Run("stale_epoch", func(t *testing.T) {
+		_, err := replica.Promote()
+		require.NoError(t, err)
+		require.NoError(t, replicaClient.PutKV(ctx, namespace, "promoted", []byte("value")))
+		token := replicaClient.ConsistencyToken(namespace)
+		require.NoError(t, replicaClient.PutKV(ctx, namespace, "promoted", []byte("value_2")))
+
+		// the old primary has the lsn of the token, from its own writes.
+		_, err = primaryClient.GetKVAfter(ctx, namespace, "promoted", token)
+		assert.ErrorIs(t, err, kvstore.ErrStaleEpoch)
+
+		md := metadata.Pairs("x-namespace", namespace, "x-epoch", strconv.FormatUint(replica.Epoch(), 10))
+		_, err = v1.NewKVStoreWriteServiceClient(primaryConn).Put(metadata.NewOutgoingContext(ctx, md),
+			&v1.PutRequest{Key: []byte("promoted"), Value: []byte("value")})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("write_lost", func(t *testing.T) {
+		// the later writes of the old primary were never replicated, the new primary has other records at their lsn.
+		token := primaryClient.ConsistencyToken(namespace)
+		for replica.LastLSN() < primary.LastLSN() {
+			require.NoError(t, replica.Put([]byte("promoted"), []byte("value")))
+		}
+		_, err := replicaClient.GetKVAfter(ctx, namespace, "chunked", token)
+		assert.ErrorIs(t, err, kvstore.ErrWriteLost)
+	})
+}
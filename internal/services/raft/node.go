@@ -0,0 +1,588 @@
+// Package raft replicates the consensus namespaces among their nodes with Raft.
+// The WAL of the namespace is the Raft log, the records are appended by the leader, replicated to
+// the followers over the RaftService and applied to the mem table once a majority of the nodes have them.
+//
+// The membership is changed one node at a time, a change is appended to the log as a membership record
+// and used by the nodes as soon as they have it. A follower without any record in its log is sent the
+// snapshot of the btree store of the leader, instead of the records it has already flushed.
+//
+// The reads are served from the committed records of the node, they are not linearizable on the followers,
+// and the leader doesn't hold a lease for them.
+package raft
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel"
+	v1 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var (
+	// ErrMembershipChangePending is returned when the membership is changed before the last change is committed.
+	ErrMembershipChangePending = errors.New("membership change is pending")
+	// ErrInvalidConfig is returned by NewNode for a config without the id or the dialer of the node.
+	ErrInvalidConfig = errors.New("invalid raft config")
+)
+
+const (
+	defaultElectionTimeout   = time.Second
+	defaultHeartbeatInterval = 100 * time.Millisecond
+	defaultMaxAppendBytes    = 512 << 10
+
+	// snapshotChunkSize is the size of the snapshot chunk sent in a message.
+	snapshotChunkSize = 512 << 10
+)
+
+// Dialer returns the client connection to the node with the address.
+type Dialer func(address string) (*grpc.ClientConn, error)
+
+// Config is the configuration of a node of a consensus namespace.
+type Config struct {
+	// ID of the node, unique among the nodes of the namespace.
+	ID string
+	// Members is the membership every node of a new namespace is bootstrapped with on its first start.
+	// A node that joins a running namespace starts without it, it gets the log from the leader once it's added.
+	Members dbkernel.Membership
+	// ElectionTimeout is the time a follower waits without hearing from the leader before it campaigns,
+	// randomized between it and twice of it. Default is 1s.
+	ElectionTimeout time.Duration
+	// HeartbeatInterval is the interval of the AppendEntries sent by the leader when there is no new record.
+	// Default is 100ms.
+	HeartbeatInterval time.Duration
+	// MaxAppendBytes is the size of the records sent in an AppendEntries, a record larger than it is sent alone.
+	// Default is 512KB.
+	MaxAppendBytes int
+	// Dial returns the connection to the other nodes, the connections are reused by the node.
+	Dial Dialer
+}
+
+// Status is the state of the node of a consensus namespace.
+type Status struct {
+	ID        string `json:"id"`
+	Term      uint64 `json:"term"`
+	Leader    string `json:"leader"`
+	Leading   bool   `json:"leading"`
+	CommitLSN uint64 `json:"commit_lsn"`
+	LastLSN   uint64 `json:"last_lsn"`
+}
+
+// leaderState is the state of the node while it leads the term.
+type leaderState struct {
+	term   uint64
+	ctx    context.Context
+	cancel context.CancelFunc
+	since  time.Time
+	peers  map[string]*peer
+}
+
+// Node is the Raft node of a consensus namespace, it elects the leader of the namespace with the other nodes
+// and replicates the log of the leader to them.
+type Node struct {
+	id        string
+	namespace string
+	engine    *dbkernel.Engine
+	config    Config
+
+	mu       sync.Mutex
+	term     uint64
+	votedFor string
+	leaderID string
+	leader   *leaderState
+
+	// lastContact is the time the node last heard from the leader or granted its vote, in unix nanos.
+	lastContact atomic.Int64
+	// electionDeadline is the time the node campaigns if it doesn't hear from the leader till it, in unix nanos.
+	electionDeadline atomic.Int64
+
+	// logMu serializes the writes of the leader to the log of the follower.
+	logMu sync.Mutex
+	// commitMu serializes the commits of the leader.
+	commitMu sync.Mutex
+
+	connMu sync.Mutex
+	conns  map[string]*grpc.ClientConn
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewNode starts the Raft node of the consensus namespace of the engine. The namespace is bootstrapped
+// with the config members if its log is empty.
+func NewNode(engine *dbkernel.Engine, config Config) (*Node, error) {
+	if config.ID == "" || config.Dial == nil {
+		return nil, fmt.Errorf("%w: id and dial are required", ErrInvalidConfig)
+	}
+	if config.ElectionTimeout == 0 {
+		config.ElectionTimeout = defaultElectionTimeout
+	}
+	if config.HeartbeatInterval == 0 {
+		config.HeartbeatInterval = defaultHeartbeatInterval
+	}
+	if config.MaxAppendBytes == 0 {
+		config.MaxAppendBytes = defaultMaxAppendBytes
+	}
+
+	n := &Node{
+		id:        config.ID,
+		namespace: engine.Namespace(),
+		engine:    engine,
+		config:    config,
+		conns:     make(map[string]*grpc.ClientConn),
+	}
+	if len(config.Members) > 0 && engine.LastLSN() == 0 {
+		if err := engine.Bootstrap(config.Members); err != nil {
+			return nil, err
+		}
+	}
+	if err := n.loadState(); err != nil {
+		return nil, err
+	}
+
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	n.touch()
+	n.wg.Add(1)
+	go n.run()
+	return n, nil
+}
+
+// loadState loads the term and the vote of the node, the term is never behind the epoch of the log.
+func (n *Node) loadState() error {
+	state, err := n.engine.ConsensusState()
+	if err != nil {
+		return err
+	}
+	if len(state) >= 8 {
+		n.term = binary.BigEndian.Uint64(state)
+		n.votedFor = string(state[8:])
+	}
+	n.term = max(n.term, n.engine.Epoch())
+	return n.saveState()
+}
+
+// saveState durably saves the term and the vote of the node, it's called with the n.mu held.
+func (n *Node) saveState() error {
+	state := binary.BigEndian.AppendUint64(nil, n.term)
+	return n.engine.SaveConsensusState(append(state, n.votedFor...))
+}
+
+// touch records that the node heard from the leader, and moves the election deadline.
+func (n *Node) touch() {
+	now := time.Now()
+	timeout := n.config.ElectionTimeout + rand.N(n.config.ElectionTimeout)
+	n.lastContact.Store(now.UnixNano())
+	n.electionDeadline.Store(now.Add(timeout).UnixNano())
+}
+
+// heardFromLeader reports if the node leads the namespace or has heard from the leader within the election timeout,
+// it's called with the n.mu held.
+func (n *Node) heardFromLeader() bool {
+	if n.leader != nil {
+		return true
+	}
+	return n.leaderID != "" && time.Since(time.Unix(0, n.lastContact.Load())) < n.config.ElectionTimeout
+}
+
+// Status returns the current state of the node.
+func (n *Node) Status() Status {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return Status{
+		ID:        n.id,
+		Term:      n.term,
+		Leader:    n.leaderID,
+		Leading:   n.leader != nil,
+		CommitLSN: n.engine.CommitLSN(),
+		LastLSN:   n.engine.LastLSN(),
+	}
+}
+
+// Close stops the node, it stops leading the namespace if it does. The engine is closed by the caller after it.
+func (n *Node) Close() error {
+	n.cancel()
+	n.mu.Lock()
+	if n.leader != nil {
+		n.stopLeading()
+	}
+	n.mu.Unlock()
+	n.wg.Wait()
+
+	n.connMu.Lock()
+	defer n.connMu.Unlock()
+	var errs []error
+	for address, conn := range n.conns {
+		errs = append(errs, conn.Close())
+		delete(n.conns, address)
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Node) run() {
+	defer n.wg.Done()
+	ticker := time.NewTicker(n.config.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n.mu.Lock()
+		leading := n.leader != nil
+		n.mu.Unlock()
+		switch {
+		case leading:
+			n.checkLeader()
+		case time.Now().UnixNano() > n.electionDeadline.Load():
+			n.campaign()
+		}
+	}
+}
+
+// client returns the RaftService client of the node with the address.
+func (n *Node) client(address string) (v1.RaftServiceClient, error) {
+	n.connMu.Lock()
+	defer n.connMu.Unlock()
+	conn, ok := n.conns[address]
+	if !ok {
+		var err error
+		if conn, err = n.config.Dial(address); err != nil {
+			return nil, err
+		}
+		n.conns[address] = conn
+	}
+	return v1.NewRaftServiceClient(conn), nil
+}
+
+func (n *Node) outgoing(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "x-namespace", n.namespace)
+}
+
+// campaign asks the other nodes to vote for the node as the leader of the next term. A pre-vote is held first,
+// so a node that can't win the election, like one cut off from the leader, doesn't disrupt it by moving the term.
+func (n *Node) campaign() {
+	members, _ := n.engine.Membership()
+	if _, ok := members[n.id]; !ok {
+		n.touch()
+		return
+	}
+	n.mu.Lock()
+	term := n.term
+	n.mu.Unlock()
+	lastLSN := n.engine.LastLSN()
+	request := &v1.RequestVoteRequest{
+		Term:        term + 1,
+		CandidateId: n.id,
+		LastLsn:     lastLSN,
+		LastEpoch:   n.engine.EpochAt(lastLSN),
+		PreVote:     true,
+	}
+	if !n.poll(members, request) {
+		n.touch()
+		return
+	}
+
+	n.mu.Lock()
+	if n.term != term || n.leader != nil {
+		n.mu.Unlock()
+		return
+	}
+	n.term++
+	n.votedFor = n.id
+	n.leaderID = ""
+	err := n.saveState()
+	term = n.term
+	n.mu.Unlock()
+	n.touch()
+	if err != nil {
+		slog.Error("[kvalchemy.raft] save consensus state failed", "namespace", n.namespace, "error", err)
+		return
+	}
+
+	slog.Info("[kvalchemy.raft] campaigning", "namespace", n.namespace, "id", n.id, "term", term)
+	request.Term = term
+	request.PreVote = false
+	if n.poll(members, request) {
+		n.becomeLeader(term)
+	}
+}
+
+// poll sends the vote request to the other members, and reports if a majority of them granted it.
+func (n *Node) poll(members dbkernel.Membership, request *v1.RequestVoteRequest) bool {
+	ctx, cancel := context.WithTimeout(n.outgoing(n.ctx), n.config.ElectionTimeout)
+	defer cancel()
+
+	votes := make(chan *v1.RequestVoteResponse, len(members))
+	for id, address := range members {
+		if id == n.id {
+			continue
+		}
+		go func() {
+			client, err := n.client(address)
+			if err != nil {
+				votes <- nil
+				return
+			}
+			response, err := client.RequestVote(ctx, request)
+			if err != nil {
+				slog.Debug("[kvalchemy.raft] request vote failed", "namespace", n.namespace, "to", id, "error", err)
+			}
+			votes <- response
+		}()
+	}
+
+	granted, quorum := 1, len(members)/2+1
+	for range len(members) - 1 {
+		response := <-votes
+		if response == nil {
+			continue
+		}
+		if response.GetVoteGranted() {
+			granted++
+			continue
+		}
+		if response.GetTerm() >= request.GetTerm() {
+			n.observeTerm(response.GetTerm())
+		}
+	}
+	return granted >= quorum
+}
+
+// observeTerm moves the node to the newer term seen from another node, it stops leading if it does.
+func (n *Node) observeTerm(term uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if term <= n.term {
+		return
+	}
+	if err := n.moveTerm(term); err != nil {
+		slog.Error("[kvalchemy.raft] save consensus state failed", "namespace", n.namespace, "error", err)
+	}
+}
+
+// moveTerm moves the node to the newer term as a follower, it's called with the n.mu held.
+func (n *Node) moveTerm(term uint64) error {
+	n.term = term
+	n.votedFor = ""
+	n.leaderID = ""
+	if n.leader != nil {
+		n.stopLeading()
+	}
+	return n.saveState()
+}
+
+// follow makes the node follow the leader of the term, it's called with the n.mu held.
+func (n *Node) follow(term uint64, leaderID string) error {
+	if term > n.term {
+		if err := n.moveTerm(term); err != nil {
+			return err
+		}
+	}
+	if n.leader != nil {
+		n.stopLeading()
+	}
+	if n.leaderID != leaderID {
+		slog.Info("[kvalchemy.raft] following the leader", "namespace", n.namespace, "id", n.id,
+			"leader", leaderID, "term", term)
+	}
+	n.leaderID = leaderID
+	n.touch()
+	return nil
+}
+
+// becomeLeader makes the node the leader of the term it won, and starts replicating the log to the other nodes.
+func (n *Node) becomeLeader(term uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.term != term || n.leader != nil {
+		return
+	}
+	if err := n.engine.Lead(term); err != nil {
+		slog.Error("[kvalchemy.raft] lead the namespace failed", "namespace", n.namespace, "term", term, "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(n.ctx)
+	n.leader = &leaderState{
+		term:   term,
+		ctx:    ctx,
+		cancel: cancel,
+		since:  time.Now(),
+		peers:  make(map[string]*peer),
+	}
+	n.leaderID = n.id
+	members, _ := n.engine.Membership()
+	n.reconcilePeers(members)
+	n.wg.Add(1)
+	go n.commitLoop(ctx, term)
+}
+
+// stopLeading stops the replication of the term, it's called with the n.mu held.
+func (n *Node) stopLeading() {
+	slog.Info("[kvalchemy.raft] stopped leading", "namespace", n.namespace, "id", n.id, "term", n.leader.term)
+	n.leader.cancel()
+	n.leader = nil
+	n.leaderID = ""
+	n.engine.StepDown()
+	n.touch()
+}
+
+// reconcilePeers starts replicating to the new members and stops replicating to the removed ones,
+// it's called with the n.mu held.
+func (n *Node) reconcilePeers(members dbkernel.Membership) {
+	peers := n.leader.peers
+	for id, p := range peers {
+		if address, ok := members[id]; !ok || address != p.address {
+			p.cancel()
+			delete(peers, id)
+		}
+	}
+	for id, address := range members {
+		if _, ok := peers[id]; ok || id == n.id {
+			continue
+		}
+		ctx, cancel := context.WithCancel(n.leader.ctx)
+		p := &peer{id: id, address: address, cancel: cancel}
+		peers[id] = p
+		n.wg.Add(1)
+		go n.replicate(ctx, n.leader.term, p)
+	}
+}
+
+// checkLeader is run by the leader every heartbeat. The leader steps down once its removal is committed,
+// or if it has not heard from a majority of the nodes within the election timeout.
+func (n *Node) checkLeader() {
+	members, membershipLSN := n.engine.Membership()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.leader == nil {
+		return
+	}
+	n.reconcilePeers(members)
+
+	_, member := members[n.id]
+	if !member && membershipLSN <= n.engine.CommitLSN() {
+		slog.Info("[kvalchemy.raft] removed from the membership", "namespace", n.namespace, "id", n.id)
+		n.stopLeading()
+		return
+	}
+	if time.Since(n.leader.since) < n.config.ElectionTimeout {
+		return
+	}
+	active := 0
+	if member {
+		active++
+	}
+	for id := range members {
+		if p, ok := n.leader.peers[id]; ok && p.active(n.config.ElectionTimeout) {
+			active++
+		}
+	}
+	if active < len(members)/2+1 {
+		slog.Warn("[kvalchemy.raft] lost the quorum", "namespace", n.namespace, "id", n.id,
+			"active", active, "members", len(members))
+		n.stopLeading()
+	}
+}
+
+// commitLoop commits the records appended by the leader, it's what commits them when the leader is the only member.
+func (n *Node) commitLoop(ctx context.Context, term uint64) {
+	defer n.wg.Done()
+	for {
+		watch, cancel := n.engine.WatchOffset(n.engine.CurrentOffset())
+		n.advanceCommit(term)
+		select {
+		case <-ctx.Done():
+			cancel()
+			return
+		case <-watch:
+		}
+		cancel()
+	}
+}
+
+// advanceCommit commits the records a majority of the members have. Only the records of the current term are
+// committed by counting the members that have them, the records of the earlier terms are committed with them.
+func (n *Node) advanceCommit(term uint64) {
+	n.commitMu.Lock()
+	defer n.commitMu.Unlock()
+
+	last := n.engine.LastLSN()
+	if err := n.engine.SyncWAL(); err != nil {
+		slog.Error("[kvalchemy.raft] sync wal failed", "namespace", n.namespace, "error", err)
+		return
+	}
+	members, _ := n.engine.Membership()
+	n.mu.Lock()
+	if n.leader == nil || n.leader.term != term {
+		n.mu.Unlock()
+		return
+	}
+	matches := make([]uint64, 0, len(members))
+	for id := range members {
+		if id == n.id {
+			matches = append(matches, last)
+			continue
+		}
+		var match uint64
+		if p, ok := n.leader.peers[id]; ok {
+			match = p.match.Load()
+		}
+		matches = append(matches, match)
+	}
+	n.mu.Unlock()
+	if len(matches) == 0 {
+		return
+	}
+
+	commit := quorumMatch(matches)
+	if commit <= n.engine.CommitLSN() || n.engine.EpochAt(commit) != term {
+		return
+	}
+	if err := n.engine.CommitTo(commit); err != nil {
+		slog.Error("[kvalchemy.raft] commit failed", "namespace", n.namespace, "lsn", commit, "error", err)
+	}
+}
+
+// quorumMatch returns the highest lsn a majority of the matches are at or after.
+func quorumMatch(matches []uint64) uint64 {
+	sorted := slices.Clone(matches)
+	slices.Sort(sorted)
+	return sorted[(len(sorted)-1)/2]
+}
+
+// ChangeMembership adds the node with the address to the namespace, or updates its address.
+// The node is removed if the address is empty. It's only allowed on the leader, once its last change is committed.
+func (n *Node) ChangeMembership(id, address string) (uint64, error) {
+	if !n.engine.Leading() {
+		return 0, dbkernel.ErrNotLeader
+	}
+	members, lsn := n.engine.Membership()
+	if lsn > n.engine.CommitLSN() {
+		return 0, ErrMembershipChangePending
+	}
+	if address == "" {
+		if _, ok := members[id]; !ok {
+			return lsn, nil
+		}
+		delete(members, id)
+	} else {
+		if members[id] == address {
+			return lsn, nil
+		}
+		members[id] = address
+	}
+	return n.engine.ChangeMembership(members)
+}
@@ -0,0 +1,385 @@
+package raft_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel"
+	"github.com/ankur-anand/unisondb/internal/services/raft"
+	v1 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	testNamespace    = "raft_test"
+	listenerBuffSize = 1 << 20
+	electionTimeout  = 300 * time.Millisecond
+)
+
+// cluster runs the nodes of a consensus namespace in the process, every node is served over its own bufconn.
+// A partition is simulated by failing the requests between the nodes it cuts off.
+type cluster struct {
+	t       *testing.T
+	members dbkernel.Membership
+	dirs    map[string]string
+	engines map[string]*dbkernel.Engine
+	nodes   map[string]*raft.Node
+	servers map[string]*grpc.Server
+
+	mu        sync.Mutex
+	listeners map[string]*bufconn.Listener
+	cut       map[[2]string]bool
+}
+
+func newCluster(t *testing.T, ids ...string) *cluster {
+	t.Helper()
+	c := &cluster{
+		t:         t,
+		members:   make(dbkernel.Membership),
+		dirs:      make(map[string]string),
+		engines:   make(map[string]*dbkernel.Engine),
+		nodes:     make(map[string]*raft.Node),
+		servers:   make(map[string]*grpc.Server),
+		listeners: make(map[string]*bufconn.Listener),
+		cut:       make(map[[2]string]bool),
+	}
+	for _, id := range ids {
+		c.members[id] = id
+	}
+	for _, id := range ids {
+		c.start(id, c.members)
+	}
+	t.Cleanup(func() {
+		for id := range c.nodes {
+			c.stop(id)
+		}
+	})
+	return c
+}
+
+// start starts the node with the id, it's bootstrapped with the members on its first start.
+func (c *cluster) start(id string, members dbkernel.Membership) {
+	c.t.Helper()
+	if _, ok := c.dirs[id]; !ok {
+		c.dirs[id] = c.t.TempDir()
+	}
+	config := dbkernel.NewDefaultEngineConfig()
+	config.Consensus = true
+	config.ArenaSize = 200 << 10
+	config.Replication.SyncTimeout = 2 * time.Second
+	engine, err := dbkernel.NewStorageEngine(c.dirs[id], testNamespace, config)
+	require.NoError(c.t, err)
+
+	node, err := raft.NewNode(engine, raft.Config{
+		ID:                id,
+		Members:           members,
+		ElectionTimeout:   electionTimeout,
+		HeartbeatInterval: 50 * time.Millisecond,
+		Dial:              c.dialer(id),
+	})
+	require.NoError(c.t, err)
+
+	listener := bufconn.Listen(listenerBuffSize)
+	server := grpc.NewServer()
+	v1.RegisterRaftServiceServer(server, raft.NewService(map[string]*raft.Node{testNamespace: node}))
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	c.mu.Lock()
+	c.listeners[id] = listener
+	c.mu.Unlock()
+	c.engines[id] = engine
+	c.nodes[id] = node
+	c.servers[id] = server
+}
+
+// stop stops the node with the id, its data is kept for the restart.
+func (c *cluster) stop(id string) {
+	c.t.Helper()
+	c.servers[id].Stop()
+	assert.NoError(c.t, c.nodes[id].Close())
+	assert.NoError(c.t, c.engines[id].Close(context.Background()))
+	c.mu.Lock()
+	delete(c.listeners, id)
+	c.mu.Unlock()
+	delete(c.servers, id)
+	delete(c.nodes, id)
+	delete(c.engines, id)
+}
+
+// dialer returns the dialer of the node, its requests fail while it's cut off from the other node.
+func (c *cluster) dialer(from string) raft.Dialer {
+	return func(address string) (*grpc.ClientConn, error) {
+		dial := func(ctx context.Context, _ string) (net.Conn, error) {
+			c.mu.Lock()
+			listener, ok := c.listeners[address]
+			c.mu.Unlock()
+			if !ok {
+				return nil, fmt.Errorf("node %s is down", address)
+			}
+			return listener.DialContext(ctx)
+		}
+		unary := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+			invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			if c.partitioned(from, address) {
+				return status.Error(codes.Unavailable, "partitioned")
+			}
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		stream := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+			streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			if c.partitioned(from, address) {
+				return nil, status.Error(codes.Unavailable, "partitioned")
+			}
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		return grpc.NewClient("passthrough:///"+address,
+			grpc.WithContextDialer(dial),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithUnaryInterceptor(unary),
+			grpc.WithStreamInterceptor(stream))
+	}
+}
+
+func (c *cluster) partitioned(from, to string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cut[[2]string{from, to}]
+}
+
+// isolate cuts the node off from every other node.
+func (c *cluster) isolate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for other := range c.dirs {
+		c.cut[[2]string{id, other}] = true
+		c.cut[[2]string{other, id}] = true
+	}
+}
+
+func (c *cluster) heal() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.cut)
+}
+
+// waitLeader waits till one of the nodes, other than the excluded ones, leads the namespace and returns its id.
+func (c *cluster) waitLeader(exclude ...string) string {
+	c.t.Helper()
+	var leader string
+	require.Eventually(c.t, func() bool {
+		for id, node := range c.nodes {
+			if node.Status().Leading && !contains(exclude, id) {
+				leader = id
+				return true
+			}
+		}
+		return false
+	}, 10*time.Second, 10*time.Millisecond)
+	return leader
+}
+
+// waitValue waits till the key has the value on every node.
+func (c *cluster) waitValue(key, value string) {
+	c.t.Helper()
+	for id, engine := range c.engines {
+		assert.Eventually(c.t, func() bool {
+			got, err := engine.Get([]byte(key))
+			return err == nil && string(got) == value
+		}, 5*time.Second, 10*time.Millisecond, "node %s", id)
+	}
+}
+
+func contains(ids []string, id string) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRaft_ElectionAndReplication(t *testing.T) {
+	c := newCluster(t, "a", "b", "c")
+	leader := c.waitLeader()
+
+	require.NoError(t, c.engines[leader].Put([]byte("key"), []byte("value")))
+	c.waitValue("key", "value")
+
+	for id, engine := range c.engines {
+		if id == leader {
+			continue
+		}
+		assert.ErrorIs(t, engine.Put([]byte("key"), []byte("other")), dbkernel.ErrNotLeader)
+		assert.Equal(t, leader, c.nodes[id].Status().Leader)
+	}
+
+	batch := dbkernel.NewBatch()
+	for i := range 10 {
+		batch.Put([]byte(fmt.Sprintf("batch_%d", i)), []byte("value"))
+	}
+	require.NoError(t, c.engines[leader].WriteBatch(batch))
+	c.waitValue("batch_9", "value")
+
+	leaderStatus := c.nodes[leader].Status()
+	for _, node := range c.nodes {
+		assert.Eventually(t, func() bool {
+			return node.Status().CommitLSN == leaderStatus.LastLSN
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+}
+
+func TestRaft_LeaderPartition(t *testing.T) {
+	c := newCluster(t, "a", "b", "c")
+	old := c.waitLeader()
+	require.NoError(t, c.engines[old].Put([]byte("key"), []byte("v1")))
+	c.waitValue("key", "v1")
+	oldTerm := c.nodes[old].Status().Term
+
+	c.isolate(old)
+	// the write of the old leader is never committed, a new leader is elected by the majority.
+	assert.Error(t, c.engines[old].Put([]byte("key"), []byte("lost")))
+	leader := c.waitLeader(old)
+	assert.Greater(t, c.nodes[leader].Status().Term, oldTerm)
+	require.NoError(t, c.engines[leader].Put([]byte("key"), []byte("v2")))
+	assert.Eventually(t, func() bool {
+		return !c.nodes[old].Status().Leading
+	}, 5*time.Second, 10*time.Millisecond)
+
+	c.heal()
+	// the old leader drops its uncommitted write and follows the new leader.
+	c.waitValue("key", "v2")
+	assert.Eventually(t, func() bool {
+		return c.nodes[old].Status().Leader == leader
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, c.engines[leader].Put([]byte("after"), []byte("heal")))
+	c.waitValue("after", "heal")
+}
+
+func TestRaft_FollowerPartition(t *testing.T) {
+	c := newCluster(t, "a", "b", "c")
+	leader := c.waitLeader()
+	var follower string
+	for id := range c.nodes {
+		if id != leader {
+			follower = id
+			break
+		}
+	}
+	term := c.nodes[leader].Status().Term
+
+	c.isolate(follower)
+	require.NoError(t, c.engines[leader].Put([]byte("key"), []byte("value")))
+	// the follower cut off from the leader can't win a pre-vote, so it doesn't move the term.
+	time.Sleep(3 * electionTimeout)
+	assert.Equal(t, term, c.nodes[follower].Status().Term)
+
+	c.heal()
+	c.waitValue("key", "value")
+	assert.Equal(t, leader, c.waitLeader())
+	assert.Equal(t, term, c.nodes[leader].Status().Term)
+}
+
+func TestRaft_Restart(t *testing.T) {
+	c := newCluster(t, "a", "b", "c")
+	leader := c.waitLeader()
+	for i := range 20 {
+		require.NoError(t, c.engines[leader].Put([]byte(fmt.Sprintf("key_%d", i)), []byte("value")))
+	}
+	c.waitValue("key_19", "value")
+	term := c.nodes[leader].Status().Term
+
+	for _, id := range []string{"a", "b", "c"} {
+		c.stop(id)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		c.start(id, c.members)
+	}
+
+	leader = c.waitLeader()
+	assert.Greater(t, c.nodes[leader].Status().Term, term)
+	c.waitValue("key_0", "value")
+	c.waitValue("key_19", "value")
+	require.NoError(t, c.engines[leader].Put([]byte("key_20"), []byte("value")))
+	c.waitValue("key_20", "value")
+}
+
+func TestRaft_ChangeMembership(t *testing.T) {
+	c := newCluster(t, "a", "b", "c")
+	leader := c.waitLeader()
+
+	value := make([]byte, 1024)
+	// the btree store of the leader has the records once the mem table is flushed.
+	for i := 0; ; i++ {
+		require.NoError(t, c.engines[leader].Put([]byte(fmt.Sprintf("key_%d", i)), value))
+		lsn, err := c.engines[leader].SnapshotLSN()
+		require.NoError(t, err)
+		if lsn > 0 {
+			break
+		}
+		require.Less(t, i, 2000)
+	}
+	require.NoError(t, c.engines[leader].Put([]byte("last"), []byte("value")))
+
+	// the new node without any record is sent the snapshot of the leader.
+	c.start("d", nil)
+	conn, err := c.dialer("client")(leader)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	client := v1.NewRaftServiceClient(conn)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-namespace", testNamespace)
+
+	_, err = client.ChangeMembership(ctx, &v1.ChangeMembershipRequest{NodeId: "d", Address: "d"})
+	require.NoError(t, err)
+	c.waitValue("key_0", string(value))
+	c.waitValue("last", "value")
+	members, _ := c.engines["d"].Membership()
+	assert.Len(t, members, 4)
+
+	require.NoError(t, c.engines[leader].Put([]byte("four"), []byte("value")))
+	c.waitValue("four", "value")
+
+	for id := range c.nodes {
+		if id == leader {
+			continue
+		}
+		// only the leader changes the membership.
+		conn, err := c.dialer("client")(id)
+		require.NoError(t, err)
+		_, err = v1.NewRaftServiceClient(conn).ChangeMembership(ctx, &v1.ChangeMembershipRequest{NodeId: "d"})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+		_ = conn.Close()
+	}
+
+	// the removed leader steps down once its removal is committed, the others elect a new leader.
+	_, err = client.ChangeMembership(ctx, &v1.ChangeMembershipRequest{NodeId: leader})
+	require.NoError(t, err)
+	newLeader := c.waitLeader(leader)
+	members, _ = c.engines[newLeader].Membership()
+	assert.Len(t, members, 3)
+	assert.NotContains(t, members, leader)
+	require.NoError(t, c.engines[newLeader].Put([]byte("three"), []byte("value")))
+	for id, engine := range c.engines {
+		if id == leader {
+			continue
+		}
+		assert.Eventually(t, func() bool {
+			got, err := engine.Get([]byte("three"))
+			return err == nil && string(got) == "value"
+		}, 5*time.Second, 10*time.Millisecond, "node %s", id)
+	}
+}
@@ -0,0 +1,210 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel"
+	v1 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
+)
+
+// peer is a member of the namespace the leader replicates its log to.
+type peer struct {
+	id      string
+	address string
+	cancel  context.CancelFunc
+	// match is the lsn of the last record the peer has in common with the leader.
+	match atomic.Uint64
+	// lastAck is the time the peer last responded to the leader, in unix nanos.
+	lastAck atomic.Int64
+}
+
+// active reports if the peer has responded to the leader within the timeout.
+func (p *peer) active(timeout time.Duration) bool {
+	return time.Since(time.Unix(0, p.lastAck.Load())) < timeout
+}
+
+// replicate sends the log of the leader to the peer for the term, till the context is canceled.
+// The records are sent as they are appended, and a heartbeat is sent every heartbeat interval without them.
+func (n *Node) replicate(ctx context.Context, term uint64, p *peer) {
+	defer n.wg.Done()
+	heartbeat := time.NewTicker(n.config.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	// the no-op record of the term is the last record of the leader when it starts.
+	next := max(n.engine.LastLSN(), 1)
+	for ctx.Err() == nil {
+		offset := n.engine.CurrentOffset()
+		more, err := n.sendAppend(ctx, term, p, &next)
+		if err != nil && ctx.Err() == nil {
+			slog.Debug("[kvalchemy.raft] append entries failed", "namespace", n.namespace, "to", p.id, "error", err)
+		}
+		if more && err == nil {
+			continue
+		}
+
+		watch, cancel := n.engine.WatchOffset(offset)
+		select {
+		case <-ctx.Done():
+		case <-watch:
+		case <-heartbeat.C:
+		}
+		cancel()
+	}
+}
+
+// sendAppend sends the records from the next lsn to the peer, and moves the next lsn by its response.
+// It reports if there are more records to send right away.
+func (n *Node) sendAppend(ctx context.Context, term uint64, p *peer, next *uint64) (bool, error) {
+	entries, err := n.entriesFrom(*next)
+	if err != nil {
+		return false, err
+	}
+	prev := *next - 1
+	request := &v1.AppendEntriesRequest{
+		Term:      term,
+		LeaderId:  n.id,
+		PrevLsn:   prev,
+		PrevEpoch: n.engine.EpochAt(prev),
+		Entries:   entries,
+		CommitLsn: n.engine.CommitLSN(),
+	}
+	client, err := n.client(p.address)
+	if err != nil {
+		return false, err
+	}
+	rctx, cancel := context.WithTimeout(n.outgoing(ctx), n.config.ElectionTimeout)
+	defer cancel()
+	response, err := client.AppendEntries(rctx, request)
+	if err != nil {
+		return false, err
+	}
+	p.lastAck.Store(time.Now().UnixNano())
+	if response.GetTerm() > term {
+		n.observeTerm(response.GetTerm())
+		return false, nil
+	}
+
+	if response.GetSuccess() {
+		match := prev + uint64(len(entries))
+		p.match.Store(max(p.match.Load(), match))
+		*next = match + 1
+		if len(entries) > 0 {
+			n.advanceCommit(term)
+		}
+		return *next <= n.engine.LastLSN(), nil
+	}
+
+	// the peer without any record gets the snapshot of the leader, instead of the records it has flushed.
+	if response.GetLastLsn() == 0 {
+		lsn, err := n.engine.SnapshotLSN()
+		if err != nil {
+			return false, err
+		}
+		if lsn > 0 {
+			if err := n.sendSnapshot(ctx, term, p, lsn); err != nil {
+				return false, err
+			}
+			*next = lsn + 1
+			return true, nil
+		}
+	}
+	switch retry := response.GetRetryLsn(); {
+	case retry > 0 && retry < *next:
+		*next = retry
+	case *next > 1:
+		*next--
+	}
+	return true, nil
+}
+
+// entriesFrom returns the records of the log from the lsn, up to the max append bytes.
+func (n *Node) entriesFrom(lsn uint64) ([][]byte, error) {
+	if lsn > n.engine.LastLSN() {
+		return nil, nil
+	}
+	reader, err := n.engine.NewReaderFromLSN(lsn)
+	if errors.Is(err, dbkernel.ErrInvalidLSN) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries [][]byte
+	size := 0
+	for size < n.config.MaxAppendBytes {
+		data, _, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, data)
+		size += len(data)
+	}
+	return entries, nil
+}
+
+// sendSnapshot streams the snapshot of the btree store of the leader to the peer, the snapshot has every record
+// till the lsn.
+func (n *Node) sendSnapshot(ctx context.Context, term uint64, p *peer, lsn uint64) error {
+	client, err := n.client(p.address)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(n.outgoing(ctx))
+	defer cancel()
+	stream, err := client.InstallSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	header := &v1.SnapshotHeader{Term: term, LeaderId: n.id, Lsn: lsn, Epoch: n.engine.EpochAt(lsn)}
+	if err := stream.Send(&v1.InstallSnapshotRequest{
+		RequestType: &v1.InstallSnapshotRequest_Header{Header: header},
+	}); err != nil {
+		return err
+	}
+
+	slog.Info("[kvalchemy.raft] sending snapshot", "namespace", n.namespace, "to", p.id, "lsn", lsn)
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		_, err := n.engine.BtreeSnapshot(pw)
+		pw.CloseWithError(err)
+	}()
+
+	buf := make([]byte, snapshotChunkSize)
+	for {
+		k, err := io.ReadFull(pr, buf)
+		if k > 0 {
+			chunk := &v1.InstallSnapshotRequest_Chunk{Chunk: buf[:k]}
+			if err := stream.Send(&v1.InstallSnapshotRequest{RequestType: chunk}); err != nil {
+				return err
+			}
+			p.lastAck.Store(time.Now().UnixNano())
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	response, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	if response.GetTerm() > term {
+		n.observeTerm(response.GetTerm())
+		return nil
+	}
+	p.match.Store(max(p.match.Load(), lsn))
+	return nil
+}
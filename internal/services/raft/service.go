@@ -0,0 +1,227 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/ankur-anand/unisondb/dbkernel"
+	"github.com/ankur-anand/unisondb/internal/middleware"
+	"github.com/ankur-anand/unisondb/internal/services"
+	v1 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
+	"google.golang.org/grpc"
+)
+
+// Service implements the gRPC RaftService for the nodes of the consensus namespaces of the server.
+type Service struct {
+	// namespace mapped node
+	nodes map[string]*Node
+	v1.UnimplementedRaftServiceServer
+}
+
+func NewService(nodes map[string]*Node) *Service {
+	return &Service{nodes: nodes}
+}
+
+// node returns the node of the namespace of the request.
+func (s *Service) node(ctx context.Context) (*Node, error) {
+	namespace, reqID, method := middleware.GetRequestInfo(ctx)
+	if namespace == "" {
+		return nil, services.ToGRPCError(namespace, reqID, method, services.ErrMissingNamespaceInMetadata)
+	}
+	node, ok := s.nodes[namespace]
+	if !ok {
+		return nil, services.ToGRPCError(namespace, reqID, method, services.ErrNamespaceNotExists)
+	}
+	return node, nil
+}
+
+func toGRPCError(ctx context.Context, err error) error {
+	namespace, reqID, method := middleware.GetRequestInfo(ctx)
+	switch {
+	case errors.Is(err, dbkernel.ErrNotLeader):
+		err = services.ErrNotLeader
+	case errors.Is(err, ErrMembershipChangePending):
+		err = services.ErrMembershipChangePending
+	}
+	return services.ToGRPCError(namespace, reqID, method, err)
+}
+
+// RequestVote votes for the candidate, if its log is at least as up-to-date as the log of the node.
+func (s *Service) RequestVote(ctx context.Context, request *v1.RequestVoteRequest) (*v1.RequestVoteResponse, error) {
+	node, err := s.node(ctx)
+	if err != nil {
+		return nil, err
+	}
+	response, err := node.requestVote(request)
+	if err != nil {
+		return nil, toGRPCError(ctx, err)
+	}
+	return response, nil
+}
+
+// AppendEntries appends the records of the leader to the log of the node.
+func (s *Service) AppendEntries(ctx context.Context, request *v1.AppendEntriesRequest) (*v1.AppendEntriesResponse, error) {
+	node, err := s.node(ctx)
+	if err != nil {
+		return nil, err
+	}
+	response, err := node.appendEntries(request)
+	if err != nil {
+		return nil, toGRPCError(ctx, err)
+	}
+	return response, nil
+}
+
+// InstallSnapshot installs the snapshot of the btree store of the leader on the node.
+func (s *Service) InstallSnapshot(g grpc.ClientStreamingServer[v1.InstallSnapshotRequest, v1.InstallSnapshotResponse]) error {
+	node, err := s.node(g.Context())
+	if err != nil {
+		return err
+	}
+	if err := node.installSnapshot(g); err != nil {
+		return toGRPCError(g.Context(), err)
+	}
+	return nil
+}
+
+// ChangeMembership adds, updates or removes the node of the namespace, on its leader.
+func (s *Service) ChangeMembership(ctx context.Context, request *v1.ChangeMembershipRequest) (*v1.ChangeMembershipResponse, error) {
+	node, err := s.node(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if request.GetNodeId() == "" {
+		return nil, toGRPCError(ctx, services.ErrInvalidMetadata)
+	}
+	lsn, err := node.ChangeMembership(request.GetNodeId(), request.GetAddress())
+	if err != nil {
+		return nil, toGRPCError(ctx, err)
+	}
+	return &v1.ChangeMembershipResponse{Lsn: lsn}, nil
+}
+
+// requestVote grants the vote of the node for the term to the first candidate whose log is at least
+// as up-to-date as its own. A pre-vote is granted without changing the term, if the node hasn't heard
+// from the leader within the election timeout.
+func (n *Node) requestVote(request *v1.RequestVoteRequest) (*v1.RequestVoteResponse, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	lastLSN := n.engine.LastLSN()
+	lastEpoch := n.engine.EpochAt(lastLSN)
+	upToDate := request.GetLastEpoch() > lastEpoch ||
+		(request.GetLastEpoch() == lastEpoch && request.GetLastLsn() >= lastLSN)
+
+	if request.GetPreVote() {
+		granted := request.GetTerm() > n.term && upToDate && !n.heardFromLeader()
+		return &v1.RequestVoteResponse{Term: n.term, VoteGranted: granted}, nil
+	}
+	if request.GetTerm() < n.term {
+		return &v1.RequestVoteResponse{Term: n.term}, nil
+	}
+	if request.GetTerm() > n.term {
+		if err := n.moveTerm(request.GetTerm()); err != nil {
+			return nil, err
+		}
+	}
+	if !upToDate || (n.votedFor != "" && n.votedFor != request.GetCandidateId()) {
+		return &v1.RequestVoteResponse{Term: n.term}, nil
+	}
+	n.votedFor = request.GetCandidateId()
+	if err := n.saveState(); err != nil {
+		return nil, err
+	}
+	n.touch()
+	return &v1.RequestVoteResponse{Term: n.term, VoteGranted: true}, nil
+}
+
+// appendEntries appends the records of the leader of the term to the log, and commits the records
+// the leader has committed.
+func (n *Node) appendEntries(request *v1.AppendEntriesRequest) (*v1.AppendEntriesResponse, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if request.GetTerm() < n.term {
+		return &v1.AppendEntriesResponse{Term: n.term, LastLsn: n.engine.LastLSN()}, nil
+	}
+	if err := n.follow(request.GetTerm(), request.GetLeaderId()); err != nil {
+		return nil, err
+	}
+
+	n.logMu.Lock()
+	defer n.logMu.Unlock()
+	entries := request.GetEntries()
+	matched, retry, err := n.engine.AppendEntries(request.GetPrevLsn(), request.GetPrevEpoch(), entries)
+	if err != nil {
+		return nil, err
+	}
+	if matched {
+		// only the records matched with the leader are committed.
+		commit := min(request.GetCommitLsn(), request.GetPrevLsn()+uint64(len(entries)))
+		if err := n.engine.CommitTo(commit); err != nil {
+			return nil, err
+		}
+	}
+	return &v1.AppendEntriesResponse{
+		Term:     n.term,
+		Success:  matched,
+		LastLsn:  n.engine.LastLSN(),
+		RetryLsn: retry,
+	}, nil
+}
+
+// installSnapshot installs the snapshot streamed by the leader of the term. The node keeps hearing from
+// the leader while the snapshot is streamed, so it doesn't campaign.
+func (n *Node) installSnapshot(g grpc.ClientStreamingServer[v1.InstallSnapshotRequest, v1.InstallSnapshotResponse]) error {
+	request, err := g.Recv()
+	if err != nil {
+		return err
+	}
+	header := request.GetHeader()
+	if header == nil {
+		return services.ErrInvalidMetadata
+	}
+
+	n.mu.Lock()
+	if header.GetTerm() < n.term {
+		term := n.term
+		n.mu.Unlock()
+		return g.SendAndClose(&v1.InstallSnapshotResponse{Term: term})
+	}
+	err = n.follow(header.GetTerm(), header.GetLeaderId())
+	term := n.term
+	n.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	n.logMu.Lock()
+	defer n.logMu.Unlock()
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			request, err := g.Recv()
+			if errors.Is(err, io.EOF) {
+				pw.Close()
+				return
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			n.touch()
+			if _, err := pw.Write(request.GetChunk()); err != nil {
+				return
+			}
+		}
+	}()
+	err = n.engine.InstallSnapshot(pr, header.GetLsn(), header.GetEpoch())
+	pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+	if err != nil {
+		return err
+	}
+	return g.SendAndClose(&v1.InstallSnapshotResponse{Term: term})
+}
@@ -0,0 +1,74 @@
+package streamer
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+// Compressions of the WAL stream messages, the client requests one with SetCompression
+// and the server compresses the messages of the stream with it.
+const (
+	CompressionGzip = gzip.Name
+	CompressionZstd = "zstd"
+)
+
+// zstdMaxDecodedSize bounds the memory a decoded message can take, it's well above grpcMaxMsgSize.
+const zstdMaxDecodedSize = 64 << 20
+
+func init() {
+	encoding.RegisterCompressor(newZstdCompressor())
+}
+
+// zstdCompressor is the grpc compressor of the messages with zstd, every message is encoded
+// and decoded at once, which the shared encoder and decoder allow concurrently.
+type zstdCompressor struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func newZstdCompressor() *zstdCompressor {
+	// the options are valid, so no error is returned.
+	encoder, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	decoder, _ := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(zstdMaxDecodedSize))
+	return &zstdCompressor{encoder: encoder, decoder: decoder}
+}
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return &zstdWriter{w: w, encoder: c.encoder}, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	compressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := c.decoder.DecodeAll(compressed, nil)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(decoded), nil
+}
+
+func (c *zstdCompressor) Name() string {
+	return CompressionZstd
+}
+
+// zstdWriter buffers the message, and writes it encoded once it's closed.
+type zstdWriter struct {
+	w       io.Writer
+	encoder *zstd.Encoder
+	buf     bytes.Buffer
+}
+
+func (z *zstdWriter) Write(p []byte) (int, error) {
+	return z.buf.Write(p)
+}
+
+func (z *zstdWriter) Close() error {
+	_, err := z.w.Write(z.encoder.EncodeAll(z.buf.Bytes(), nil))
+	return err
+}
@@ -0,0 +1,324 @@
+package streamer
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel"
+	"github.com/ankur-anand/unisondb/internal/middleware"
+	"github.com/ankur-anand/unisondb/internal/services"
+	v2 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
+	"github.com/segmentio/ksuid"
+	"google.golang.org/grpc"
+)
+
+var (
+	// snapshotChunkSize is the size of the snapshot chunk sent in a message.
+	snapshotChunkSize = 512 << 10
+
+	// snapshotReuseAge is the age till which the latest snapshot of a namespace is streamed
+	// to the new requests, instead of taking a new one.
+	snapshotReuseAge = time.Hour
+
+	// snapshotLeaseTime is the time a replaced snapshot is kept after its last stream started,
+	// so its downloads still resume.
+	snapshotLeaseTime = 10 * time.Minute
+
+	errSnapshotStoreClosed = errors.New("snapshot store is closed")
+)
+
+// snapshotFile is a snapshot of the btree store of a namespace written to a file,
+// so a dropped stream resumes from the same bytes.
+type snapshotFile struct {
+	id        string
+	namespace string
+	path      string
+	offset    []byte
+	// lsn of the record at the offset, the last record in the snapshot.
+	lsn  uint64
+	size uint64
+	// checksum is the crc32 of the complete snapshot.
+	checksum  uint32
+	createdAt time.Time
+
+	// streams is the count of the streams of the snapshot in progress, and leaseUntil is the time till which
+	// it's kept once replaced, renewed by every stream of it. Both are guarded by the mu of the store.
+	streams    int
+	leaseUntil time.Time
+	replaced   bool
+}
+
+// snapshotTake is a snapshot of a namespace being taken, the requests for the namespace wait for it.
+type snapshotTake struct {
+	done chan struct{}
+	err  error
+}
+
+// snapshotStore keeps the latest snapshot of every namespace, and the replaced ones still streamed or leased.
+type snapshotStore struct {
+	mu     sync.Mutex
+	dir    string
+	closed bool
+	// latest snapshot of the namespace, streamed to the new requests.
+	latest map[string]*snapshotFile
+	// snapshots by their id, the latest ones and the replaced ones not removed yet.
+	snapshots map[string]*snapshotFile
+	taking    map[string]*snapshotTake
+}
+
+func newSnapshotStore() *snapshotStore {
+	return &snapshotStore{
+		latest:    make(map[string]*snapshotFile),
+		snapshots: make(map[string]*snapshotFile),
+		taking:    make(map[string]*snapshotTake),
+	}
+}
+
+// get returns the snapshot with the id, or a recent snapshot of the namespace if the id is empty.
+// The snapshot is kept till it's released.
+func (s *snapshotStore) get(engine *dbkernel.Engine, id string) (*snapshotFile, error) {
+	namespace := engine.Namespace()
+	s.mu.Lock()
+	s.removeExpired()
+	if id != "" {
+		defer s.mu.Unlock()
+		snapshot, ok := s.snapshots[id]
+		if !ok || snapshot.namespace != namespace {
+			return nil, services.ErrSnapshotNotFound
+		}
+		s.acquire(snapshot)
+		return snapshot, nil
+	}
+
+	for {
+		if s.closed {
+			s.mu.Unlock()
+			return nil, errSnapshotStoreClosed
+		}
+		if latest := s.latest[namespace]; latest != nil && time.Since(latest.createdAt) < snapshotReuseAge {
+			s.acquire(latest)
+			s.mu.Unlock()
+			return latest, nil
+		}
+		take, ok := s.taking[namespace]
+		if !ok {
+			break
+		}
+		// a snapshot of the namespace is already being taken, it's streamed once taken.
+		s.mu.Unlock()
+		<-take.done
+		if take.err != nil {
+			return nil, take.err
+		}
+		s.mu.Lock()
+	}
+
+	if s.dir == "" {
+		dir, err := os.MkdirTemp("", "unisondb-snapshot-")
+		if err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+		s.dir = dir
+	}
+	dir := s.dir
+	take := &snapshotTake{done: make(chan struct{})}
+	s.taking[namespace] = take
+	s.mu.Unlock()
+
+	// the snapshot is taken without the lock, the streams of the other snapshots go on.
+	snapshot, err := takeSnapshot(engine, dir)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.taking, namespace)
+	if err == nil && s.closed {
+		err = errSnapshotStoreClosed
+	}
+	take.err = err
+	close(take.done)
+	if err != nil {
+		return nil, err
+	}
+
+	if latest := s.latest[namespace]; latest != nil {
+		latest.replaced = true
+	}
+	s.latest[namespace] = snapshot
+	s.snapshots[snapshot.id] = snapshot
+	s.acquire(snapshot)
+	s.removeExpired()
+	return snapshot, nil
+}
+
+// acquire marks the snapshot as streamed, and renews its lease.
+func (s *snapshotStore) acquire(snapshot *snapshotFile) {
+	snapshot.streams++
+	snapshot.leaseUntil = time.Now().Add(snapshotLeaseTime)
+}
+
+// release marks the stream of the snapshot as done.
+func (s *snapshotStore) release(snapshot *snapshotFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot.streams--
+	s.removeExpired()
+}
+
+// removeExpired removes the replaced snapshots without any stream in progress, once their lease has expired.
+func (s *snapshotStore) removeExpired() {
+	now := time.Now()
+	for id, snapshot := range s.snapshots {
+		if !snapshot.replaced || snapshot.streams > 0 || now.Before(snapshot.leaseUntil) {
+			continue
+		}
+		if err := os.Remove(snapshot.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Error("[kvalchemy.streamer.grpc] failed to remove the replaced snapshot",
+				"namespace", snapshot.namespace, "snapshot_id", id, "error", err)
+		}
+		delete(s.snapshots, id)
+	}
+}
+
+// close removes every snapshot, the streams still in progress have their file open.
+func (s *snapshotStore) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	clear(s.latest)
+	clear(s.snapshots)
+	if s.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(s.dir)
+}
+
+// takeSnapshot writes the snapshot of the btree store of the engine to a file in the dir.
+func takeSnapshot(engine *dbkernel.Engine, dir string) (*snapshotFile, error) {
+	// offset is taken before the snapshot, the snapshot has every change till it.
+	offset, lsn, err := engine.SnapshotOffset()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(dir, engine.Namespace()+"-*.snapshot")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	checksum := crc32.NewIEEE()
+	size, err := engine.BtreeSnapshot(io.MultiWriter(f, checksum))
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+
+	snapshot := &snapshotFile{
+		id:        ksuid.New().String(),
+		namespace: engine.Namespace(),
+		path:      f.Name(),
+		lsn:       lsn,
+		size:      uint64(size),
+		checksum:  checksum.Sum32(),
+		createdAt: time.Now(),
+	}
+	if offset != nil {
+		snapshot.offset = offset.Encode()
+	}
+	return snapshot, nil
+}
+
+// StreamSnapshot streams the snapshot of the btree store in checksummed chunks, along with the WAL offset
+// and the lsn the snapshot matches. The stream of a snapshot resumes from the bytes already received.
+func (s *GrpcStreamer) StreamSnapshot(request *v2.StreamSnapshotRequest, g grpc.ServerStreamingServer[v2.StreamSnapshotResponse]) error {
+	namespace, reqID, method := middleware.GetRequestInfo(g.Context())
+
+	if namespace == "" {
+		return services.ToGRPCError(namespace, reqID, method, services.ErrMissingNamespaceInMetadata)
+	}
+
+	engine, ok := s.storageEngines[namespace]
+	if !ok {
+		return services.ToGRPCError(namespace, reqID, method, services.ErrNamespaceNotExists)
+	}
+
+	snapshot, err := s.snapshots.get(engine, request.GetSnapshotId())
+	if err != nil {
+		return services.ToGRPCError(namespace, reqID, method, err)
+	}
+	defer s.snapshots.release(snapshot)
+	if request.GetResumeFrom() > snapshot.size {
+		return services.ToGRPCError(namespace, reqID, method, services.ErrInvalidMetadata)
+	}
+
+	slog.Debug("[kvalchemy.streamer.grpc] streaming snapshot",
+		"method", method,
+		reqIDKey, reqID,
+		"namespace", namespace,
+		"snapshot_id", snapshot.id,
+		"resume_from", request.GetResumeFrom(),
+	)
+
+	metricsActiveStreamTotal.WithLabelValues(namespace, string(method), "grpc").Inc()
+	defer metricsActiveStreamTotal.WithLabelValues(namespace, string(method), "grpc").Dec()
+
+	if err := s.streamSnapshotFile(g, snapshot, request.GetResumeFrom()); err != nil {
+		return services.ToGRPCError(namespace, reqID, method, err)
+	}
+	return nil
+}
+
+// streamSnapshotFile sends the chunks of the snapshot file after the position.
+func (s *GrpcStreamer) streamSnapshotFile(g grpc.ServerStreamingServer[v2.StreamSnapshotResponse],
+	snapshot *snapshotFile, position uint64) error {
+	namespace, _, method := middleware.GetRequestInfo(g.Context())
+
+	f, err := os.Open(snapshot.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(int64(position), io.SeekStart); err != nil {
+		return err
+	}
+
+	buf := make([]byte, snapshotChunkSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return err
+		}
+		// the last message is sent even if empty, so an empty snapshot still has its offset.
+		if n == 0 && position != snapshot.size {
+			return io.ErrUnexpectedEOF
+		}
+
+		response := &v2.StreamSnapshotResponse{
+			SnapshotId:       snapshot.id,
+			Offset:           snapshot.offset,
+			Lsn:              snapshot.lsn,
+			Size:             snapshot.size,
+			Position:         position,
+			Chunk:            buf[:n],
+			Crc32Checksum:    crc32.ChecksumIEEE(buf[:n]),
+			SnapshotChecksum: snapshot.checksum,
+		}
+		if err := g.Send(response); err != nil {
+			metricsStreamSendErrors.WithLabelValues(namespace, string(method), "grpc").Inc()
+			return fmt.Errorf("failed to send snapshot chunk: %w", err)
+		}
+		metricsStreamSendTotal.WithLabelValues(namespace, string(method), "grpc").Inc()
+
+		position += uint64(n)
+		if position == snapshot.size {
+			return nil
+		}
+	}
+}
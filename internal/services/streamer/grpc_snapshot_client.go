@@ -0,0 +1,275 @@
+package streamer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel"
+	"github.com/ankur-anand/unisondb/internal/services"
+	v2 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// snapshotProgress is the snapshot being downloaded, it's kept next to the snapshot file,
+// so the download resumes even after a restart.
+type snapshotProgress struct {
+	ID       string `json:"id"`
+	Offset   []byte `json:"offset"`
+	LSN      uint64 `json:"lsn"`
+	Size     uint64 `json:"size"`
+	Checksum uint32 `json:"checksum"`
+}
+
+// GrpcSnapshotClient downloads the snapshot of the namespace to a file, a dropped stream resumes
+// after the bytes already written to the file.
+type GrpcSnapshotClient struct {
+	gcc       *grpc.ClientConn
+	namespace string
+	path      string
+}
+
+func NewGrpcSnapshotClient(gcc *grpc.ClientConn, namespace string, path string) *GrpcSnapshotClient {
+	return &GrpcSnapshotClient{
+		gcc:       gcc,
+		namespace: namespace,
+		path:      path,
+	}
+}
+
+func (c *GrpcSnapshotClient) progressPath() string {
+	return c.path + ".progress"
+}
+
+// Download downloads the snapshot to the path of the client, and returns the WAL offset and the lsn the snapshot matches.
+func (c *GrpcSnapshotClient) Download(ctx context.Context) ([]byte, uint64, error) {
+	md := metadata.Pairs("x-namespace", c.namespace)
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	var retryCount int
+	backoff := initialBackoff
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		default:
+		}
+		if retryCount > maxRetries {
+			slog.Error("[kvalchemy.streamer.grpc.client] Max retries reached, aborting snapshot download",
+				"namespace", c.namespace, "retries", retryCount)
+			clientWalStreamErrTotal.WithLabelValues(c.namespace, "grpc", "max_retries_reached").Inc()
+			return nil, 0, fmt.Errorf("%w [%d]", services.ErrClientMaxRetriesExceeded, maxRetries)
+		}
+
+		progress, written, err := c.loadProgress()
+		if err != nil {
+			return nil, 0, err
+		}
+		if progress != nil && written == progress.Size {
+			return progress.Offset, progress.LSN, nil
+		}
+
+		request := &v2.StreamSnapshotRequest{}
+		if progress != nil {
+			request.SnapshotId = progress.ID
+			request.ResumeFrom = written
+		}
+		client, err := v2.NewWALReplicationServiceClient(c.gcc).StreamSnapshot(ctx, request)
+		if err == nil {
+			err = c.receiveSnapshot(client, progress, written)
+		}
+
+		switch {
+		case err == nil:
+			continue
+		case status.Code(err) == codes.NotFound && progress != nil:
+			// snapshot got replaced on the server, the download starts again with the new one.
+			slog.Warn("[kvalchemy.streamer.grpc.client] snapshot not found, restarting download",
+				"namespace", c.namespace, "snapshot_id", progress.ID)
+			if err := c.Remove(); err != nil {
+				return nil, 0, err
+			}
+			retryCount++
+		case shouldRetry(err) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, services.ErrSnapshotChecksumMismatch):
+			retryCount++
+			slog.Warn("[kvalchemy.streamer.grpc.client] StreamSnapshot failed, retrying",
+				"namespace", c.namespace, "error", err,
+				"retry_count", retryCount)
+			time.Sleep(getJitteredBackoff(&backoff))
+		default:
+			return nil, 0, handleStreamError(c.namespace, err)
+		}
+	}
+}
+
+// receiveSnapshot appends the received chunks to the snapshot file.
+func (c *GrpcSnapshotClient) receiveSnapshot(client v2.WALReplicationService_StreamSnapshotClient,
+	progress *snapshotProgress, written uint64) error {
+	f, err := os.OpenFile(c.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for {
+		res, err := client.Recv()
+		if errors.Is(err, io.EOF) {
+			if progress == nil || written != progress.Size {
+				return io.ErrUnexpectedEOF
+			}
+			return f.Sync()
+		}
+		if err != nil {
+			return err
+		}
+
+		if progress == nil {
+			progress = &snapshotProgress{
+				ID:       res.GetSnapshotId(),
+				Offset:   res.GetOffset(),
+				LSN:      res.GetLsn(),
+				Size:     res.GetSize(),
+				Checksum: res.GetSnapshotChecksum(),
+			}
+			if err := c.saveProgress(progress); err != nil {
+				return err
+			}
+		}
+		if res.GetSnapshotId() != progress.ID || res.GetPosition() != written {
+			return fmt.Errorf("%w: snapshot %s chunk at %d, expected %s at %d", services.ErrInvalidMetadata,
+				res.GetSnapshotId(), res.GetPosition(), progress.ID, written)
+		}
+		if crc32.ChecksumIEEE(res.GetChunk()) != res.GetCrc32Checksum() {
+			return services.ErrSnapshotChecksumMismatch
+		}
+
+		if _, err := f.Write(res.GetChunk()); err != nil {
+			return err
+		}
+		written += uint64(len(res.GetChunk()))
+	}
+}
+
+// loadProgress returns the snapshot being downloaded and the bytes of it already written, nil if none.
+func (c *GrpcSnapshotClient) loadProgress() (*snapshotProgress, uint64, error) {
+	data, err := os.ReadFile(c.progressPath())
+	if errors.Is(err, os.ErrNotExist) {
+		// the bytes without the progress are of no snapshot.
+		if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, 0, err
+		}
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var progress snapshotProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return nil, 0, err
+	}
+	info, err := os.Stat(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &progress, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return &progress, uint64(info.Size()), nil
+}
+
+func (c *GrpcSnapshotClient) saveProgress(progress *snapshotProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	tmp := c.progressPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.progressPath())
+}
+
+// Verify verifies the downloaded snapshot against the checksum of the complete snapshot.
+func (c *GrpcSnapshotClient) Verify() error {
+	progress, written, err := c.loadProgress()
+	if err != nil {
+		return err
+	}
+	if progress == nil || written != progress.Size {
+		return io.ErrUnexpectedEOF
+	}
+
+	f, err := os.Open(c.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	checksum := crc32.NewIEEE()
+	if _, err := io.Copy(checksum, f); err != nil {
+		return err
+	}
+	if checksum.Sum32() != progress.Checksum {
+		return fmt.Errorf("%w: snapshot %s", services.ErrSnapshotCorrupted, progress.ID)
+	}
+	return nil
+}
+
+// Remove removes the downloaded snapshot and its progress.
+func (c *GrpcSnapshotClient) Remove() error {
+	if err := os.Remove(c.progressPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// BootstrapReplica restores the snapshot of the upstream to the replica, if no record is applied to it yet.
+// The snapshot is downloaded to the dir first, so a dropped stream doesn't download it again,
+// and is verified against the checksum of the complete snapshot before it's restored.
+// It returns the client that streams the upstream WAL to the replica after the snapshot.
+func BootstrapReplica(ctx context.Context, gcc *grpc.ClientConn, replica *dbkernel.Engine, dir string) (*GrpcStreamerClient, error) {
+	if replica.LastLSN() == 0 && replica.UpstreamOffset() == nil {
+		client := NewGrpcSnapshotClient(gcc, replica.Namespace(), filepath.Join(dir, replica.Namespace()+".snapshot"))
+		offset, lsn, err := client.Download(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.Verify(); err != nil {
+			// the corrupted snapshot is downloaded again on the next bootstrap.
+			if rerr := client.Remove(); rerr != nil {
+				return nil, errors.Join(err, rerr)
+			}
+			return nil, err
+		}
+		if err := restoreSnapshot(replica, client.path, offset, lsn); err != nil {
+			return nil, err
+		}
+		if err := client.Remove(); err != nil {
+			return nil, err
+		}
+	}
+	return NewReplicaStreamerClient(gcc, replica), nil
+}
+
+func restoreSnapshot(replica *dbkernel.Engine, path string, offset []byte, lsn uint64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return replica.RestoreSnapshot(f, offset, lsn)
+}
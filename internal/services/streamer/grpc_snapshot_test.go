@@ -0,0 +1,102 @@
+package streamer
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel"
+	"github.com/ankur-anand/unisondb/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSnapshotEngine(t *testing.T) *dbkernel.Engine {
+	t.Helper()
+	config := dbkernel.NewDefaultEngineConfig()
+	config.DBEngine = dbkernel.BoltDBEngine
+	engine, err := dbkernel.NewStorageEngine(t.TempDir(), "test_snapshot", config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+	require.NoError(t, engine.Put([]byte("key"), []byte("value")))
+	return engine
+}
+
+func TestSnapshotStore_SingleFlight(t *testing.T) {
+	engine := newSnapshotEngine(t)
+	store := newSnapshotStore()
+	t.Cleanup(func() {
+		assert.NoError(t, store.close())
+	})
+
+	var wg sync.WaitGroup
+	snapshots := make([]*snapshotFile, 10)
+	for i := range snapshots {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			snapshot, err := store.get(engine, "")
+			assert.NoError(t, err)
+			snapshots[i] = snapshot
+		}()
+	}
+	wg.Wait()
+
+	for _, snapshot := range snapshots {
+		assert.Same(t, snapshots[0], snapshot, "concurrent requests should stream the same snapshot")
+	}
+	assert.Equal(t, len(snapshots), snapshots[0].streams)
+	files, err := os.ReadDir(store.dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestSnapshotStore_ReplacedSnapshot(t *testing.T) {
+	engine := newSnapshotEngine(t)
+	store := newSnapshotStore()
+	reuseAge := snapshotReuseAge
+	snapshotReuseAge = 0
+	t.Cleanup(func() {
+		snapshotReuseAge = reuseAge
+	})
+
+	replaced, err := store.get(engine, "")
+	require.NoError(t, err)
+	latest, err := store.get(engine, "")
+	require.NoError(t, err)
+	assert.NotEqual(t, replaced.id, latest.id)
+	store.release(latest)
+
+	t.Run("leased", func(t *testing.T) {
+		store.release(replaced)
+		assert.FileExists(t, replaced.path)
+		resumed, err := store.get(engine, replaced.id)
+		require.NoError(t, err)
+		assert.Same(t, replaced, resumed, "replaced snapshot should resume till its lease expires")
+	})
+
+	t.Run("streamed", func(t *testing.T) {
+		replaced.leaseUntil = time.Now()
+		store.removeExpired()
+		assert.FileExists(t, replaced.path, "replaced snapshot should be kept while it's streamed")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		store.release(replaced)
+		assert.NoFileExists(t, replaced.path)
+		_, err := store.get(engine, replaced.id)
+		assert.ErrorIs(t, err, services.ErrSnapshotNotFound)
+		assert.FileExists(t, latest.path, "latest snapshot should be kept")
+	})
+
+	t.Run("close", func(t *testing.T) {
+		assert.NoError(t, store.close())
+		assert.NoDirExists(t, store.dir)
+		_, err := store.get(engine, "")
+		assert.ErrorIs(t, err, errSnapshotStoreClosed)
+	})
+}
@@ -8,12 +8,16 @@ import (
 	"time"
 
 	"github.com/ankur-anand/unisondb/dbkernel"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
 	"github.com/ankur-anand/unisondb/internal/middleware"
 	"github.com/ankur-anand/unisondb/internal/services"
 	"github.com/ankur-anand/unisondb/pkg/replicator"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
 	v2 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -30,6 +34,17 @@ var (
 	replicatorBatchSize = 20
 
 	grpcMaxMsgSize = 1 << 20
+
+	// streamBatchBytes is the size of the WAL records sent in a message, capped to grpcMaxMsgSize.
+	// A record larger than it is sent in a message of its own.
+	streamBatchBytes = 256 << 10
+
+	// replicaLagInterval is the interval the lag of the followers connected over ReplicateWAL is refreshed in.
+	replicaLagInterval = time.Second
+
+	// streamLingerTime is the time the records wait for more records to fill the message,
+	// before it's sent. The records of the streams with the acks requested don't wait.
+	streamLingerTime = 5 * time.Millisecond
 )
 
 // GrpcStreamer implements gRPC-based WALReplicationService.
@@ -38,7 +53,9 @@ type GrpcStreamer struct {
 	storageEngines map[string]*dbkernel.Engine
 	dynamicTimeout time.Duration
 	v2.UnimplementedWALReplicationServiceServer
-	errGrp *errgroup.Group
+	errGrp    *errgroup.Group
+	snapshots *snapshotStore
+	replicas  *replicaRegistry
 }
 
 func NewGrpcStreamer(errGrp *errgroup.Group, storageEngines map[string]*dbkernel.Engine, dynamicTimeout time.Duration) *GrpcStreamer {
@@ -46,9 +63,16 @@ func NewGrpcStreamer(errGrp *errgroup.Group, storageEngines map[string]*dbkernel
 		storageEngines: storageEngines,
 		dynamicTimeout: dynamicTimeout,
 		errGrp:         errGrp,
+		snapshots:      newSnapshotStore(),
+		replicas:       newReplicaRegistry(),
 	}
 }
 
+// Close removes the snapshots taken for StreamSnapshot, it should be called once the server is stopped.
+func (s *GrpcStreamer) Close() error {
+	return s.snapshots.close()
+}
+
 // StreamWAL stream the underlying WAL record on the connection stream.
 func (s *GrpcStreamer) StreamWAL(request *v2.StreamWALRequest, g grpc.ServerStreamingServer[v2.StreamWALResponse]) error {
 	namespace, reqID, method := middleware.GetRequestInfo(g.Context())
@@ -62,10 +86,155 @@ func (s *GrpcStreamer) StreamWAL(request *v2.StreamWALRequest, g grpc.ServerStre
 		return services.ToGRPCError(namespace, reqID, method, services.ErrNamespaceNotExists)
 	}
 
+	return s.streamWAL(g.Context(), g, engine, request, false)
+}
+
+// ReplicateWAL streams the underlying WAL record on the connection stream like StreamWAL, and records
+// the progress acknowledged by the follower on the same stream.
+func (s *GrpcStreamer) ReplicateWAL(g grpc.BidiStreamingServer[v2.ReplicateWALRequest, v2.StreamWALResponse]) error {
+	namespace, reqID, method := middleware.GetRequestInfo(g.Context())
+
+	if namespace == "" {
+		return services.ToGRPCError(namespace, reqID, method, services.ErrMissingNamespaceInMetadata)
+	}
+
+	engine, ok := s.storageEngines[namespace]
+	if !ok {
+		return services.ToGRPCError(namespace, reqID, method, services.ErrNamespaceNotExists)
+	}
+
+	request, err := g.Recv()
+	if err != nil {
+		return err
+	}
+	start := request.GetStart()
+	if start == nil || start.GetReplicaId() == "" {
+		return services.ToGRPCError(namespace, reqID, method, services.ErrInvalidMetadata)
+	}
+	var addr string
+	if p, ok := peer.FromContext(g.Context()); ok {
+		addr = p.Addr.String()
+	}
+	// a filtered follower doesn't have every write, it doesn't count towards the sync replicas.
+	key := s.replicas.register(engine, start.GetReplicaId(), addr, start.GetRequest().GetFilter() != nil)
+	defer s.replicas.unregister(key)
+
+	ctx, cancel := context.WithCancel(g.Context())
+	defer cancel()
+	go s.replicas.refreshEvery(ctx, key, replicaLagInterval)
+	// the stream ends once the follower stops sending.
+	go func() {
+		defer cancel()
+		for {
+			request, err := g.Recv()
+			if err != nil {
+				return
+			}
+			if ack := request.GetAck(); ack != nil {
+				s.replicas.ack(key, ack)
+			}
+		}
+	}()
+	// the writes waiting for the sync replicas return only once the follower acknowledges them.
+	return s.streamWAL(ctx, g, engine, start.GetRequest(), engine.SyncReplicas() > 0)
+}
+
+// EpochEnd returns the current epoch of the namespace, with the last lsn of the requested epoch.
+func (s *GrpcStreamer) EpochEnd(ctx context.Context, request *v2.EpochEndRequest) (*v2.EpochEndResponse, error) {
+	namespace, reqID, method := middleware.GetRequestInfo(ctx)
+
+	if namespace == "" {
+		return nil, services.ToGRPCError(namespace, reqID, method, services.ErrMissingNamespaceInMetadata)
+	}
+
+	engine, ok := s.storageEngines[namespace]
+	if !ok {
+		return nil, services.ToGRPCError(namespace, reqID, method, services.ErrNamespaceNotExists)
+	}
+
+	return &v2.EpochEndResponse{
+		CurrentEpoch: engine.Epoch(),
+		EndLsn:       engine.EpochEnd(request.GetEpoch()),
+	}, nil
+}
+
+// Replicas returns the progress and the lag of the followers connected over ReplicateWAL.
+func (s *GrpcStreamer) Replicas() []ReplicaStatus {
+	return s.replicas.list()
+}
+
+// startOffset returns the offset of the last record the stream of the request starts after.
+//
+// A replica restored from a snapshot serves the records after the snapshot from its own WAL, the records
+// before it are only in its btree store. The stream of a follower that is behind the first record of the WAL
+// fails with ErrLSNNotRetained, the follower needs a snapshot first.
+func startOffset(engine *dbkernel.Engine, request *v2.StreamWALRequest) (*dbkernel.Offset, error) {
 	// it can contain terrible data
 	meta, err := decodeMetadata(request.GetOffset())
 	if err != nil {
-		return services.ToGRPCError(namespace, reqID, method, services.ErrInvalidMetadata)
+		return nil, services.ErrInvalidMetadata
+	}
+
+	// lsn of a record is the same on every node, unlike the offset.
+	lsn := request.GetLsn()
+	if (request == nil || request.Lsn == nil) && meta != nil {
+		return meta, nil
+	}
+	first, err := engine.FirstLSN()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case lsn+1 < first:
+		return nil, services.ErrLSNNotRetained
+	case lsn+1 == first:
+		// the stream starts with the first record of the WAL.
+		return nil, nil
+	}
+	return offsetForLSN(engine, lsn)
+}
+
+var filterEntryTypes = map[v2.ChangeEntryType]logrecord.LogEntryType{
+	v2.ChangeEntryType_CHANGE_ENTRY_TYPE_KV:      logrecord.LogEntryTypeKV,
+	v2.ChangeEntryType_CHANGE_ENTRY_TYPE_CHUNKED: logrecord.LogEntryTypeChunked,
+	v2.ChangeEntryType_CHANGE_ENTRY_TYPE_ROW:     logrecord.LogEntryTypeRow,
+}
+
+// walFilter returns the replicator filter of the request filter, nil if the request streams every record.
+func walFilter(filter *v2.WALFilter) (*replicator.Filter, error) {
+	if filter == nil {
+		return nil, nil
+	}
+	entryTypes := make([]logrecord.LogEntryType, 0, len(filter.GetEntryTypes()))
+	for _, entryType := range filter.GetEntryTypes() {
+		logEntryType, ok := filterEntryTypes[entryType]
+		if !ok {
+			return nil, services.ErrInvalidMetadata
+		}
+		entryTypes = append(entryTypes, logEntryType)
+	}
+	return &replicator.Filter{
+		KeyPrefixes:    filter.GetKeyPrefixes(),
+		EntryTypes:     entryTypes,
+		ExcludeOrigins: filter.GetExcludeOrigins(),
+	}, nil
+}
+
+// streamWAL streams the WAL records of the engine requested by the request on the connection stream.
+func (s *GrpcStreamer) streamWAL(ctx context.Context, g grpc.ServerStream, engine *dbkernel.Engine,
+	request *v2.StreamWALRequest, ackRequested bool) error {
+	namespace, reqID, method := middleware.GetRequestInfo(g.Context())
+	// the follower has seen a newer primary than this one.
+	if request.GetEpoch() > engine.Epoch() {
+		return services.ToGRPCError(namespace, reqID, method, services.ErrStaleEpoch)
+	}
+	meta, err := startOffset(engine, request)
+	if err != nil {
+		return services.ToGRPCError(namespace, reqID, method, err)
+	}
+	filter, err := walFilter(request.GetFilter())
+	if err != nil {
+		return services.ToGRPCError(namespace, reqID, method, err)
 	}
 	// create a new replicator instance.
 	slog.Debug("[kvalchemy.streamer.grpc] streaming WAL",
@@ -79,12 +248,15 @@ func (s *GrpcStreamer) StreamWAL(request *v2.StreamWALRequest, g grpc.ServerStre
 	replicatorErr := make(chan error, 1)
 	defer close(walReceiver)
 
-	ctx, cancel := context.WithCancel(g.Context())
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	rpInstance := replicator.NewReplicator(engine,
 		replicatorBatchSize,
 		replicatorBatchWaitTime, meta, "grpc")
+	if filter != nil {
+		rpInstance.SetFilter(*filter)
+	}
 
 	s.errGrp.Go(func() error {
 		defer close(replicatorErr)
@@ -100,7 +272,7 @@ func (s *GrpcStreamer) StreamWAL(request *v2.StreamWALRequest, g grpc.ServerStre
 
 	metricsActiveStreamTotal.WithLabelValues(namespace, string(method), "grpc").Inc()
 	defer metricsActiveStreamTotal.WithLabelValues(namespace, string(method), "grpc").Dec()
-	return s.streamWalRecords(ctx, g, walReceiver, replicatorErr)
+	return s.streamWalRecords(ctx, g, engine, walReceiver, replicatorErr, ackRequested)
 }
 
 // streamWalRecords streams namespaced Write-Ahead Log (WAL) records to the client in batches
@@ -116,23 +288,32 @@ func (s *GrpcStreamer) StreamWAL(request *v2.StreamWALRequest, g grpc.ServerStre
 //
 //nolint:gocognit
 func (s *GrpcStreamer) streamWalRecords(ctx context.Context,
-	g grpc.ServerStreamingServer[v2.StreamWALResponse],
+	g grpc.ServerStream,
+	engine *dbkernel.Engine,
 	walReceiver chan []*v2.WALRecord,
-	replicatorErr chan error) error {
+	replicatorErr chan error,
+	ackRequested bool) error {
 	namespace, reqID, method := middleware.GetRequestInfo(g.Context())
 
 	var (
-		batch                  []*v2.WALRecord
-		totalBatchSize         int
+		batch                  = newWALBatch(min(streamBatchBytes, grpcMaxMsgSize))
 		lastReceivedRecordTime = time.Now()
+		// linger is running while the batch waits for more records.
+		linger    = time.NewTimer(streamLingerTime)
+		lingering bool
 	)
+	linger.Stop()
+	defer linger.Stop()
 
 	flusher := func() error {
-		if err := s.flushBatch(batch, g); err != nil {
+		if lingering {
+			linger.Stop()
+			lingering = false
+		}
+		if err := s.flushBatch(engine, batch.records, g, ackRequested); err != nil {
 			return err
 		}
-		batch = []*v2.WALRecord{}
-		totalBatchSize = 0
+		batch.reset()
 		return nil
 	}
 
@@ -156,21 +337,40 @@ func (s *GrpcStreamer) streamWalRecords(ctx context.Context,
 			}
 			return ctx.Err()
 		case walRecords := <-walReceiver:
+			lastReceivedRecordTime = time.Now()
 			for _, walRecord := range walRecords {
-				lastReceivedRecordTime = time.Now()
-				totalBatchSize = +len(walRecord.Record)
-				batch = append(batch, walRecord)
-
-				if totalBatchSize >= grpcMaxMsgSize {
+				// the batch is sent before the record that doesn't fit in it.
+				if !batch.fits(walRecord) {
 					if err := flusher(); err != nil {
 						return services.ToGRPCError(namespace, reqID, method, err)
 					}
 				}
+				batch.add(walRecord)
+				if batch.full() {
+					if err := flusher(); err != nil {
+						return services.ToGRPCError(namespace, reqID, method, err)
+					}
+				}
+			}
 
-				// flush remaining
+			if len(batch.records) == 0 {
+				continue
+			}
+			// the writes waiting for the ack of the follower don't wait for the batch to fill.
+			if ackRequested || streamLingerTime <= 0 {
 				if err := flusher(); err != nil {
 					return services.ToGRPCError(namespace, reqID, method, err)
 				}
+				continue
+			}
+			if !lingering {
+				linger.Reset(streamLingerTime)
+				lingering = true
+			}
+		case <-linger.C:
+			lingering = false
+			if err := flusher(); err != nil {
+				return services.ToGRPCError(namespace, reqID, method, err)
 			}
 		case err := <-replicatorErr:
 			if errors.Is(err, dbkernel.ErrInvalidOffset) {
@@ -181,14 +381,22 @@ func (s *GrpcStreamer) streamWalRecords(ctx context.Context,
 	}
 }
 
-func (s *GrpcStreamer) flushBatch(batch []*v2.WALRecord, g grpc.ServerStream) error {
+func (s *GrpcStreamer) flushBatch(engine *dbkernel.Engine, batch []*v2.WALRecord, g grpc.ServerStream, ackRequested bool) error {
 	namespace, reqID, method := middleware.GetRequestInfo(g.Context())
 	metricsStreamSendTotal.WithLabelValues(namespace, string(method), "grpc").Add(float64(len(batch)))
 	if len(batch) == 0 {
 		return nil
 	}
 	slog.Debug("[kvalchemy.streamer.grpc] Batch flushing", "size", len(batch))
-	response := &v2.StreamWALResponse{WalRecords: batch, SentAt: timestamppb.Now()}
+	rootLSN, rootPendingHLC := rootProgress(engine, batch)
+	response := &v2.StreamWALResponse{
+		WalRecords:     batch,
+		SentAt:         timestamppb.Now(),
+		AckRequested:   ackRequested,
+		RootLsn:        rootLSN,
+		RootPendingHlc: rootPendingHLC,
+	}
+	metricsStreamBatchBytes.WithLabelValues(namespace, string(method), "grpc").Observe(float64(proto.Size(response)))
 
 	start := time.Now()
 	defer func() {
@@ -204,6 +412,54 @@ func (s *GrpcStreamer) flushBatch(batch []*v2.WALRecord, g grpc.ServerStream) er
 	return nil
 }
 
+// rootProgress returns the lsn of the last record of the root primary of the namespace, and the HLC of its
+// oldest record after the batch, zero if the batch has every record. The engine is the root, unless it's
+// a replica, so a follower down a chain of replicas measures its lag to the root and not to its upstream.
+func rootProgress(engine *dbkernel.Engine, batch []*v2.WALRecord) (uint64, uint64) {
+	rootLSN, pendingHLC := engine.RootProgress()
+	if rootLSN == 0 {
+		return 0, 0
+	}
+	// records filtered out of the stream don't have the lsn, the lag of a filtered stream is only in records.
+	var lsn uint64
+	for i := len(batch) - 1; i >= 0 && lsn == 0; i-- {
+		if len(batch[i].GetRecord()) == 0 {
+			continue
+		}
+		decoded, err := logcodec.DecodeLSN(batch[i].GetRecord())
+		if err != nil {
+			return rootLSN, 0
+		}
+		lsn = decoded
+	}
+	if lsn == 0 || lsn >= rootLSN {
+		return rootLSN, 0
+	}
+	if lsn < engine.LastLSN() {
+		hlc, err := engine.RecordHLC(lsn + 1)
+		if err != nil {
+			return rootLSN, 0
+		}
+		return rootLSN, hlc
+	}
+	return rootLSN, pendingHLC
+}
+
+// offsetForLSN returns the offset of the last applied lsn, nil if nothing is applied.
+func offsetForLSN(engine *dbkernel.Engine, lsn uint64) (*dbkernel.Offset, error) {
+	if lsn == 0 {
+		return nil, nil
+	}
+	offset, err := engine.OffsetForLSN(lsn)
+	switch {
+	case errors.Is(err, dbkernel.ErrInvalidLSN):
+		return nil, services.ErrInvalidMetadata
+	case errors.Is(err, dbkernel.ErrLSNNotRetained):
+		return nil, services.ErrLSNNotRetained
+	}
+	return offset, err
+}
+
 // decodeMetadata protects from panic and decodes.
 func decodeMetadata(data []byte) (o *dbkernel.Offset, err error) {
 	defer func() {
@@ -7,8 +7,12 @@ import (
 	"io"
 	"log/slog"
 	"math/rand/v2"
+	"os"
+	"sync"
 	"time"
 
+	"github.com/ankur-anand/unisondb/dbkernel"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
 	"github.com/ankur-anand/unisondb/internal/services"
 	v2 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
 	"github.com/prometheus/common/helpers/templates"
@@ -24,29 +28,229 @@ const (
 	maxRetries     = 5
 )
 
+// ErrStaleUpstream is returned when the upstream is on an older epoch of the namespace than the follower,
+// it was the primary before another node got promoted.
+var ErrStaleUpstream = errors.New("upstream is on a stale epoch of the namespace")
+
+// errUpstreamBehind is returned when the upstream is a replica on the same epoch that hasn't applied the records
+// the follower already has, the stream is retried till it catches up.
+var errUpstreamBehind = status.Error(codes.Unavailable, "upstream is behind the follower")
+
+// DivergedError is returned when the follower has records after the LSN that the upstream doesn't have,
+// the follower was the primary of the namespace before the upstream got promoted. The records must be removed
+// with dbkernel.TruncateWAL, on the closed namespace, before the follower streams from the upstream again.
+type DivergedError struct {
+	Namespace string
+	LSN       uint64
+}
+
+func (e *DivergedError) Error() string {
+	return fmt.Sprintf("namespace %s has diverged from the upstream after lsn %d", e.Namespace, e.LSN)
+}
+
+// replicaAckInterval is the interval at which the follower acknowledges its progress on ReplicateWAL.
+var replicaAckInterval = time.Second
+
 // WalIO provide.
 type WalIO interface {
 	Write(data *v2.WALRecord) error
 }
 
+// ReplicaProgress is the progress of the follower acknowledged to the upstream.
+type ReplicaProgress struct {
+	AppliedLSN    uint64
+	AppliedOffset []byte
+	DurableLSN    uint64
+	DurableOffset []byte
+}
+
+// ProgressWalIO is a WalIO that reports the applied and durable progress of the follower,
+// the acks of a WalIO without it report the last written record as applied, and nothing as durable.
+type ProgressWalIO interface {
+	WalIO
+	Progress() (ReplicaProgress, error)
+}
+
+// RootWalIO is a WalIO that tracks the progress of the root primary of the namespace, as reported by the upstream
+// with every message. The upstream is the root, unless the follower streams from another follower.
+type RootWalIO interface {
+	WalIO
+	ObserveRoot(lsn, pendingHLC uint64)
+}
+
+// FilteredWalIO is a WalIO that records the offset a filtered stream has read till, so it resumes
+// after the records the upstream has filtered out.
+type FilteredWalIO interface {
+	WalIO
+	Skip(offset []byte) error
+}
+
 type GrpcStreamerClient struct {
 	gcc       *grpc.ClientConn
 	namespace string
+	replicaID string
 	wIO       WalIO
-	// offset of the record that was last received.
+	filter    *v2.WALFilter
+	// compression of the stream messages, none if empty.
+	compression string
+
+	mu sync.Mutex
+	// offset and lsn of the record that was last received.
 	offset []byte
+	lsn    uint64
+	// ackNow asks sendAcks for an ack before the next tick, for the batches the upstream requested an ack of.
+	ackNow chan struct{}
 }
 
 func NewGrpcStreamerClient(gcc *grpc.ClientConn, namespace string, wIO WalIO, offset []byte) *GrpcStreamerClient {
+	replicaID, _ := os.Hostname()
 	return &GrpcStreamerClient{
 		gcc:       gcc,
 		namespace: namespace,
+		replicaID: replicaID,
 		wIO:       wIO,
 		offset:    offset,
+		ackNow:    make(chan struct{}, 1),
 	}
 }
 
+// SetReplicaID sets the id the follower is tracked with by the upstream on ReplicateWAL, it's the hostname by default.
+// It must be unique among the followers of the namespace.
+func (c *GrpcStreamerClient) SetReplicaID(id string) {
+	c.replicaID = id
+}
+
+// SetFilter streams only the records that match the filter.
+func (c *GrpcStreamerClient) SetFilter(filter *v2.WALFilter) {
+	// a filtered replica only has some of the records of the upstream.
+	if wIO, ok := c.wIO.(*replicaWalIO); ok {
+		wIO.filtered = filter != nil
+	}
+	c.filter = filter
+}
+
+// SetCompression requests the messages of the WAL stream compressed with the compression,
+// CompressionZstd or CompressionGzip.
+func (c *GrpcStreamerClient) SetCompression(compression string) {
+	c.compression = compression
+}
+
+// callOptions returns the options of the WAL stream call.
+func (c *GrpcStreamerClient) callOptions() []grpc.CallOption {
+	if c.compression == "" {
+		return nil
+	}
+	// the server compresses the responses with the compressor of the request.
+	return []grpc.CallOption{grpc.UseCompressor(c.compression)}
+}
+
+// NewReplicaStreamerClient returns a client that applies the streamed WAL of the namespace of the replica engine
+// to it, the stream resumes from the upstream offset of the replica.
+func NewReplicaStreamerClient(gcc *grpc.ClientConn, replica *dbkernel.Engine) *GrpcStreamerClient {
+	return NewGrpcStreamerClient(gcc, replica.Namespace(), &replicaWalIO{engine: replica}, replica.UpstreamOffset())
+}
+
+// NewPeerStreamerClient returns a client that applies the streamed WAL of the peer to the engine of the multi leader
+// namespace, the stream resumes from the offset of the last record received from the peer.
+// The records written by the engine itself are filtered out by the peer, they're never streamed back.
+func NewPeerStreamerClient(gcc *grpc.ClientConn, engine *dbkernel.Engine, peerID string) (*GrpcStreamerClient, error) {
+	offset, err := engine.PeerOffset(peerID)
+	if err != nil {
+		return nil, err
+	}
+	client := NewGrpcStreamerClient(gcc, engine.Namespace(), &peerWalIO{engine: engine, peerID: peerID}, offset)
+	client.SetReplicaID(engine.NodeID())
+	client.SetFilter(&v2.WALFilter{ExcludeOrigins: []string{engine.NodeID()}})
+	return client, nil
+}
+
+// peerWalIO applies the received WAL records of the peer to the engine of the multi leader namespace.
+type peerWalIO struct {
+	engine *dbkernel.Engine
+	peerID string
+}
+
+func (p *peerWalIO) Write(record *v2.WALRecord) error {
+	return p.engine.ApplyPeer(p.peerID, record.Offset, record.Record, record.Crc32Checksum)
+}
+
+func (p *peerWalIO) Skip(offset []byte) error {
+	return p.engine.SkipPeer(p.peerID, offset)
+}
+
+// EpochWalIO is a WalIO that tracks the epochs of the namespace. Before the stream starts the follower is checked
+// against the epoch of the upstream, and the stream resumes by the lsn, as the offsets of the records differ
+// on the new upstream after a failover.
+type EpochWalIO interface {
+	WalIO
+	Epoch() uint64
+	LastLSN() uint64
+	// ResumeLSN returns the lsn the stream resumes after.
+	ResumeLSN() uint64
+}
+
+// replicaWalIO applies the received WAL records to the replica engine.
+type replicaWalIO struct {
+	engine   *dbkernel.Engine
+	filtered bool
+}
+
+func (r *replicaWalIO) Write(record *v2.WALRecord) error {
+	if r.filtered {
+		return r.engine.ApplyFilteredReplicated(record.Offset, record.Record, record.Crc32Checksum)
+	}
+	return r.engine.ApplyReplicated(record.Offset, record.Record, record.Crc32Checksum)
+}
+
+func (r *replicaWalIO) Epoch() uint64 {
+	return r.engine.Epoch()
+}
+
+func (r *replicaWalIO) LastLSN() uint64 {
+	return r.engine.LastLSN()
+}
+
+func (r *replicaWalIO) ResumeLSN() uint64 {
+	return r.engine.ResumeLSN()
+}
+
+func (r *replicaWalIO) ObserveRoot(lsn, pendingHLC uint64) {
+	r.engine.ObserveRoot(lsn, pendingHLC)
+}
+
+func (r *replicaWalIO) Skip(offset []byte) error {
+	return r.engine.SkipReplicated(offset)
+}
+
+// Progress syncs the wal of the replica, the records applied before the sync are durable.
+func (r *replicaWalIO) Progress() (ReplicaProgress, error) {
+	durableOffset, durableLSN := r.engine.AppliedUpstream()
+	if err := r.engine.SyncWAL(); err != nil {
+		return ReplicaProgress{}, err
+	}
+	appliedOffset, appliedLSN := r.engine.AppliedUpstream()
+	return ReplicaProgress{
+		AppliedLSN:    appliedLSN,
+		AppliedOffset: appliedOffset,
+		DurableLSN:    durableLSN,
+		DurableOffset: durableOffset,
+	}, nil
+}
+
 func (c *GrpcStreamerClient) StreamWAL(ctx context.Context) error {
+	return c.stream(ctx, "StreamWAL", c.streamWAL)
+}
+
+// ReplicateWAL streams the WAL like StreamWAL over the ReplicateWAL rpc, and acknowledges the progress
+// of the follower to the upstream every replicaAckInterval, and as soon as a batch the upstream
+// requested an ack of is applied.
+func (c *GrpcStreamerClient) ReplicateWAL(ctx context.Context) error {
+	return c.stream(ctx, "ReplicateWAL", c.replicateWAL)
+}
+
+// stream runs the stream till the context is done, a stream that fails with a retryable error
+// is started again after a backoff.
+func (c *GrpcStreamerClient) stream(ctx context.Context, method string, stream func(ctx context.Context, startTime time.Time) error) error {
 	md := metadata.Pairs("x-namespace", c.namespace)
 	ctx = metadata.NewOutgoingContext(ctx, md)
 
@@ -67,14 +271,10 @@ func (c *GrpcStreamerClient) StreamWAL(ctx context.Context) error {
 			return fmt.Errorf("%w [%d]", services.ErrClientMaxRetriesExceeded, maxRetries)
 		}
 
-		client, err := v2.NewWALReplicationServiceClient(c.gcc).StreamWAL(ctx, &v2.StreamWALRequest{
-			Offset: c.offset,
-		})
-
-		if err != nil {
+		if err := stream(ctx, streamStartTime); err != nil {
 			if shouldRetry(err) {
 				retryCount++
-				slog.Warn("[kvalchemy.streamer.grpc.client] StreamWAL failed, retrying",
+				slog.Warn("[kvalchemy.streamer.grpc.client] "+method+" failed, retrying",
 					"namespace", c.namespace, "error", err,
 					"retry_count", retryCount)
 				time.Sleep(getJitteredBackoff(&backoff))
@@ -83,24 +283,153 @@ func (c *GrpcStreamerClient) StreamWAL(ctx context.Context) error {
 			return handleStreamError(c.namespace, err)
 		}
 
-		if err := c.receiveWALRecords(client, streamStartTime); err != nil {
-			if shouldRetry(err) {
-				retryCount++
-				slog.Warn("[kvalchemy.streamer.grpc.client] StreamWAL failed, retrying",
-					"namespace", c.namespace, "error", err,
-					"retry_count", retryCount)
-				time.Sleep(getJitteredBackoff(&backoff))
-				continue
-			}
+		retryCount = 0
+		backoff = initialBackoff
+	}
+}
+
+func (c *GrpcStreamerClient) streamWAL(ctx context.Context, startTime time.Time) error {
+	request, err := c.startRequest(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := v2.NewWALReplicationServiceClient(c.gcc).StreamWAL(ctx, request, c.callOptions()...)
+	if err != nil {
+		return err
+	}
+	return c.receiveWALRecords(client, startTime)
+}
+
+func (c *GrpcStreamerClient) replicateWAL(ctx context.Context, startTime time.Time) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	request, err := c.startRequest(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := v2.NewWALReplicationServiceClient(c.gcc).ReplicateWAL(ctx, c.callOptions()...)
+	if err != nil {
+		return err
+	}
+	err = client.Send(&v2.ReplicateWALRequest{
+		RequestType: &v2.ReplicateWALRequest_Start{Start: &v2.ReplicateWALStart{
+			ReplicaId: c.replicaID,
+			Request:   request,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	ackErr := make(chan error, 1)
+	go func() {
+		ackErr <- c.sendAcks(ctx, client)
+	}()
+	err = c.receiveWALRecords(client, startTime)
+	cancel()
+	if aErr := <-ackErr; aErr != nil {
+		return aErr
+	}
+	return err
+}
+
+// startRequest returns the request the stream starts with. The follower that tracks the epochs is checked
+// against the epoch of the upstream first, it must not have any record the upstream doesn't have.
+func (c *GrpcStreamerClient) startRequest(ctx context.Context) (*v2.StreamWALRequest, error) {
+	request := &v2.StreamWALRequest{Offset: c.lastOffset(), Filter: c.filter}
+	wIO, ok := c.wIO.(EpochWalIO)
+	if !ok {
+		return request, nil
+	}
+
+	epoch := wIO.Epoch()
+	res, err := v2.NewWALReplicationServiceClient(c.gcc).EpochEnd(ctx, &v2.EpochEndRequest{Epoch: epoch})
+	// upstream from before the epochs, it's never failed over.
+	if status.Code(err) == codes.Unimplemented {
+		return request, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res.GetCurrentEpoch() < epoch {
+		return nil, fmt.Errorf("%w: upstream epoch %d, local epoch %d", ErrStaleUpstream, res.GetCurrentEpoch(), epoch)
+	}
+	if lastLSN := wIO.LastLSN(); lastLSN > res.GetEndLsn() {
+		// records of the same epoch are of the same primary, the upstream is a replica yet to apply them.
+		if res.GetCurrentEpoch() == epoch {
+			return nil, errUpstreamBehind
+		}
+		return nil, &DivergedError{Namespace: c.namespace, LSN: res.GetEndLsn()}
+	}
+
+	request.Epoch = epoch
+	// a replica without any record resumes by the offset.
+	if lsn := wIO.ResumeLSN(); lsn > 0 {
+		request.Lsn = &lsn
+	}
+	return request, nil
+}
+
+// sendAcks acknowledges the progress of the follower every replicaAckInterval, or when asked over ackNow,
+// till the context is done.
+func (c *GrpcStreamerClient) sendAcks(ctx context.Context, client v2.WALReplicationService_ReplicateWALClient) error {
+	ticker := time.NewTicker(replicaAckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-c.ackNow:
+		}
+
+		progress, err := c.progress()
+		if err != nil {
 			return err
 		}
+		err = client.Send(&v2.ReplicateWALRequest{
+			RequestType: &v2.ReplicateWALRequest_Ack{Ack: &v2.ReplicaAck{
+				AppliedLsn:    progress.AppliedLSN,
+				AppliedOffset: progress.AppliedOffset,
+				DurableLsn:    progress.DurableLSN,
+				DurableOffset: progress.DurableOffset,
+			}},
+		})
+		// the error of the broken stream is returned by the receive.
+		if err != nil {
+			return nil
+		}
+	}
+}
 
-		retryCount = 0
-		backoff = initialBackoff
+// progress returns the progress of the follower to acknowledge.
+func (c *GrpcStreamerClient) progress() (ReplicaProgress, error) {
+	if wIO, ok := c.wIO.(ProgressWalIO); ok {
+		return wIO.Progress()
 	}
+	// the written record isn't known to be synced, it's never acknowledged as durable.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ReplicaProgress{
+		AppliedLSN:    c.lsn,
+		AppliedOffset: c.offset,
+	}, nil
+}
+
+func (c *GrpcStreamerClient) lastOffset() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.offset
 }
 
-func (c *GrpcStreamerClient) receiveWALRecords(client v2.WALReplicationService_StreamWALClient, startTime time.Time) error {
+// walRecordsReceiver is the receiving side of the StreamWAL and the ReplicateWAL stream.
+type walRecordsReceiver interface {
+	Recv() (*v2.StreamWALResponse, error)
+}
+
+func (c *GrpcStreamerClient) receiveWALRecords(client walRecordsReceiver, startTime time.Time) error {
 	for {
 		res, err := client.Recv()
 		if err != nil {
@@ -117,17 +446,74 @@ func (c *GrpcStreamerClient) receiveWALRecords(client v2.WALReplicationService_S
 		clientWalRecvTotal.WithLabelValues(c.namespace, "grpc").Add(float64(len(res.WalRecords)))
 
 		for _, record := range res.WalRecords {
-			c.offset = record.Offset
+			// the upstream has filtered out the records till the offset.
+			if len(record.Record) == 0 {
+				if err := c.skip(record.Offset); err != nil {
+					return err
+				}
+				continue
+			}
 			if err := c.wIO.Write(record); err != nil {
 				return err
 			}
+			lsn, err := logcodec.DecodeLSN(record.Record)
+			if err != nil {
+				return err
+			}
+			c.mu.Lock()
+			c.offset = record.Offset
+			c.lsn = lsn
+			c.mu.Unlock()
+		}
+		if res.GetRootLsn() > 0 {
+			c.observeRoot(res.GetRootLsn(), res.GetRootPendingHlc())
+		}
+		if res.GetAckRequested() {
+			select {
+			case c.ackNow <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// observeRoot records the progress of the root primary reported with the received records,
+// and the lag of the follower behind it.
+func (c *GrpcStreamerClient) observeRoot(rootLSN, pendingHLC uint64) {
+	if wIO, ok := c.wIO.(RootWalIO); ok {
+		wIO.ObserveRoot(rootLSN, pendingHLC)
+	}
+	c.mu.Lock()
+	lsn := c.lsn
+	c.mu.Unlock()
+
+	var lagRecords, lagSeconds float64
+	if rootLSN > lsn {
+		lagRecords = float64(rootLSN - lsn)
+	}
+	if pendingHLC > 0 {
+		lagSeconds = max(0, time.Since(dbkernel.HLCTime(pendingHLC)).Seconds())
+	}
+	clientRootLagRecords.WithLabelValues(c.namespace).Set(lagRecords)
+	clientRootLagSeconds.WithLabelValues(c.namespace).Set(lagSeconds)
+}
+
+// skip moves the offset the stream resumes from to the offset of the last filtered out record.
+func (c *GrpcStreamerClient) skip(offset []byte) error {
+	if wIO, ok := c.wIO.(FilteredWalIO); ok {
+		if err := wIO.Skip(offset); err != nil {
+			return err
 		}
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset = offset
+	return nil
 }
 
 func handleStreamError(namespace string, err error) error {
 	sErr := status.Convert(err)
-	clientWalStreamErrTotal.WithLabelValues(namespace, sErr.Code().String()).Inc()
+	clientWalStreamErrTotal.WithLabelValues(namespace, "grpc", sErr.Code().String()).Inc()
 	slog.Error("[kvalchemy.streamer.grpc.client] Stream error", "namespace", namespace, "error", err)
 	return err
 }
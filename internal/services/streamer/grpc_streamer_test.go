@@ -3,15 +3,19 @@ package streamer_test
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"math/rand/v2"
 	"net"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/ankur-anand/unisondb/dbkernel"
 	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
 	"github.com/ankur-anand/unisondb/internal/middleware"
 	"github.com/ankur-anand/unisondb/internal/services"
 	"github.com/ankur-anand/unisondb/internal/services/streamer"
@@ -25,6 +29,7 @@ import (
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
@@ -157,6 +162,20 @@ func TestServer_Invalid_Request(t *testing.T) {
 			statusErr.Message(), "expected error message didn't match")
 	})
 
+	t.Run("InvalidLSN", func(t *testing.T) {
+		md := metadata.Pairs("x-namespace", nameSpaces[0])
+		ctx := metadata.NewOutgoingContext(context.Background(), md)
+		wal, err := client.StreamWAL(ctx, &v2.StreamWALRequest{
+			Lsn: proto.Uint64(100), // nothing is written yet.
+		})
+		assert.NoError(t, err, "failed to stream WAL")
+		_, err = wal.Recv()
+		assert.Error(t, err, "expected error on invalid lsn")
+
+		statusErr := status.Convert(err)
+		assert.Equal(t, codes.InvalidArgument, statusErr.Code(),
+			"expected error code InvalidArgument")
+	})
 }
 
 func TestServer_StreamWAL_StreamTimeoutErr(t *testing.T) {
@@ -272,9 +291,9 @@ func TestServer_StreamWAL_StreamTimeoutErr(t *testing.T) {
 			valuesCount = +len(val.WalRecords)
 			for _, record := range val.WalRecords {
 				lastRecvIndex++
-				wr := walrecord.GetRootAsWalRecord(record.Record, 0)
-				assert.NotNil(t, wr, "error converting to wal record")
-				assert.Equal(t, lastRecvIndex, wr.Index(), "last recv index does not match")
+				wr, err := logcodec.DecodeRecord(record.Record)
+				assert.NoError(t, err, "error converting to wal record")
+				assert.Equal(t, lastRecvIndex, wr.LSN, "last recv index does not match")
 			}
 
 		}
@@ -282,6 +301,31 @@ func TestServer_StreamWAL_StreamTimeoutErr(t *testing.T) {
 		assert.Greater(t, valuesCount, 0, "total 20 logs should have streamed")
 	})
 
+	t.Run("wal-replication-from-lsn", func(t *testing.T) {
+		md := metadata.Pairs("x-namespace", nameSpaces[0])
+		ctx := metadata.NewOutgoingContext(context.Background(), md)
+		wal, err := client.StreamWAL(ctx, &v2.StreamWALRequest{Lsn: proto.Uint64(5)})
+		assert.NoError(t, err, "failed to stream WAL")
+
+		lastRecvIndex := uint64(5)
+		for {
+			val, err := wal.Recv()
+			if err != nil {
+				statusErr := status.Convert(err)
+				assert.Equal(t, codes.Unavailable, statusErr.Code(), "expected error code Unavailable")
+				break
+			}
+			for _, record := range val.WalRecords {
+				lastRecvIndex++
+				wr, err := logcodec.DecodeRecord(record.Record)
+				assert.NoError(t, err)
+				assert.Equal(t, lastRecvIndex, wr.LSN, "stream should resume after the lsn")
+			}
+		}
+
+		assert.Equal(t, engines[nameSpaces[0]].LastLSN(), lastRecvIndex, "every record after the lsn should have streamed")
+	})
+
 	errN := errGroup.Wait()
 	assert.NoError(t, errN)
 }
@@ -457,3 +501,868 @@ func TestServer_StreamWAL_MaxRetry(t *testing.T) {
 		assert.ErrorIs(t, err, services.ErrClientMaxRetriesExceeded, "expected Error didn't happen")
 	})
 }
+
+func TestServer_StreamWAL_Replica(t *testing.T) {
+	nameSpace := strings.ToLower(gofakeit.Noun())
+	leader, err := dbkernel.NewStorageEngine(t.TempDir(), nameSpace, dbkernel.NewDefaultEngineConfig())
+	assert.NoError(t, err)
+	defer leader.Close(context.Background())
+
+	keys := make(map[string][]byte)
+	put := func() {
+		for i := 0; i < 10; i++ {
+			key := gofakeit.UUID()
+			keys[key] = bytes.Repeat([]byte(gofakeit.LetterN(5)), largeValue)
+			assert.NoError(t, leader.Put([]byte(key), keys[key]))
+		}
+	}
+	put()
+	txn, err := leader.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+	assert.NoError(t, err)
+	assert.NoError(t, txn.AppendKVTxn([]byte("txn_key"), []byte("txn_value")))
+	assert.NoError(t, txn.Commit())
+	keys["txn_key"] = []byte("txn_value")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errGroup, _ := errgroup.WithContext(ctx)
+	server := streamer.NewGrpcStreamer(errGroup, map[string]*dbkernel.Engine{nameSpace: leader}, 2*time.Second)
+
+	listener := bufconn.Listen(listenerBuffSize)
+	defer listener.Close()
+	gS := grpc.NewServer(grpc.ChainStreamInterceptor(middleware.RequireNamespaceInterceptor,
+		middleware.RequestIDStreamInterceptor,
+		middleware.CorrelationIDStreamInterceptor,
+		middleware.TelemetryInterceptor))
+	defer gS.Stop()
+
+	go func() {
+		v2.RegisterWALReplicationServiceServer(gS, server)
+		if err := gS.Serve(listener); err != nil {
+			assert.NoError(t, err, "failed to grpc start server")
+		}
+	}()
+
+	conn, err := grpc.NewClient("passthrough://bufnet", grpc.WithContextDialer(bufDialer(listener)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err, "failed to create grpc client")
+
+	config := dbkernel.NewDefaultEngineConfig()
+	config.Replica = true
+	replicaDir := t.TempDir()
+
+	// streams into the replica till it has every record of the leader.
+	streamReplica := func() {
+		replica, err := dbkernel.NewStorageEngine(replicaDir, nameSpace, config)
+		assert.NoError(t, err)
+		defer func() {
+			assert.NoError(t, replica.Close(context.Background()))
+		}()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = streamer.NewReplicaStreamerClient(conn, replica).StreamWAL(ctx)
+		}()
+		assert.Eventually(t, func() bool {
+			return replica.LastLSN() == leader.LastLSN()
+		}, 10*time.Second, 10*time.Millisecond)
+		cancel()
+		<-done
+
+		for key, value := range keys {
+			got, err := replica.Get([]byte(key))
+			assert.NoError(t, err)
+			assert.Equal(t, value, got)
+		}
+	}
+
+	streamReplica()
+	// the restarted replica resumes from the last applied record.
+	put()
+	streamReplica()
+}
+
+func TestServer_StreamSnapshot(t *testing.T) {
+	nameSpace := strings.ToLower(gofakeit.Noun())
+	config := dbkernel.NewDefaultEngineConfig()
+	config.DBEngine = dbkernel.BoltDBEngine
+	config.ArenaSize = 200 * 1024
+	leader, err := dbkernel.NewStorageEngine(t.TempDir(), nameSpace, config)
+	assert.NoError(t, err)
+	defer leader.Close(context.Background())
+
+	keys := make(map[string][]byte)
+	put := func(n int) {
+		for i := 0; i < n; i++ {
+			key := gofakeit.UUID()
+			keys[key] = []byte(gofakeit.LetterN(largeValue))
+			assert.NoError(t, leader.Put([]byte(key), keys[key]))
+		}
+	}
+	put(600)
+	// the snapshot has the flushed records.
+	assert.Eventually(t, func() bool {
+		offset, _, err := leader.SnapshotOffset()
+		return err == nil && offset != nil
+	}, 10*time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errGroup, _ := errgroup.WithContext(ctx)
+	server := streamer.NewGrpcStreamer(errGroup, map[string]*dbkernel.Engine{nameSpace: leader}, 2*time.Second)
+
+	listener := bufconn.Listen(listenerBuffSize)
+	defer listener.Close()
+	gS := grpc.NewServer(grpc.ChainStreamInterceptor(middleware.RequireNamespaceInterceptor,
+		middleware.RequestIDStreamInterceptor,
+		middleware.CorrelationIDStreamInterceptor,
+		middleware.TelemetryInterceptor))
+	defer gS.Stop()
+
+	go func() {
+		v2.RegisterWALReplicationServiceServer(gS, server)
+		if err := gS.Serve(listener); err != nil {
+			assert.NoError(t, err, "failed to grpc start server")
+		}
+	}()
+
+	conn, err := grpc.NewClient("passthrough://bufnet", grpc.WithContextDialer(bufDialer(listener)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err, "failed to create grpc client")
+
+	t.Run("resume", func(t *testing.T) {
+		md := metadata.Pairs("x-namespace", nameSpace)
+		ctx := metadata.NewOutgoingContext(context.Background(), md)
+		client := v2.NewWALReplicationServiceClient(conn)
+
+		stream, err := client.StreamSnapshot(ctx, &v2.StreamSnapshotRequest{})
+		assert.NoError(t, err)
+		first, err := stream.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(0), first.GetPosition())
+		assert.Less(t, uint64(len(first.GetChunk())), first.GetSize(), "snapshot should be sent in chunks")
+		assert.NotZero(t, first.GetLsn())
+
+		stream, err = client.StreamSnapshot(ctx, &v2.StreamSnapshotRequest{
+			SnapshotId: first.GetSnapshotId(),
+			ResumeFrom: uint64(len(first.GetChunk())),
+		})
+		assert.NoError(t, err)
+		resumed, err := stream.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, first.GetSnapshotId(), resumed.GetSnapshotId())
+		assert.Equal(t, first.GetOffset(), resumed.GetOffset())
+		assert.Equal(t, uint64(len(first.GetChunk())), resumed.GetPosition())
+
+		stream, err = client.StreamSnapshot(ctx, &v2.StreamSnapshotRequest{SnapshotId: "unknown"})
+		assert.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.NotFound, status.Code(err))
+
+		stream, err = client.StreamSnapshot(ctx, &v2.StreamSnapshotRequest{
+			SnapshotId: first.GetSnapshotId(),
+			ResumeFrom: first.GetSize() + 1,
+		})
+		assert.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("corrupted_download", func(t *testing.T) {
+		replicaConfig := dbkernel.NewDefaultEngineConfig()
+		replicaConfig.DBEngine = dbkernel.BoltDBEngine
+		replicaConfig.Replica = true
+		replica, err := dbkernel.NewStorageEngine(t.TempDir(), nameSpace, replicaConfig)
+		assert.NoError(t, err)
+		defer func() {
+			assert.NoError(t, replica.Close(context.Background()))
+		}()
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, nameSpace+".snapshot")
+		_, _, err = streamer.NewGrpcSnapshotClient(conn, nameSpace, path).Download(context.Background())
+		assert.NoError(t, err)
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		assert.NoError(t, err)
+		_, err = f.WriteAt([]byte("corrupted"), 0)
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+
+		_, err = streamer.BootstrapReplica(context.Background(), conn, replica, dir)
+		assert.ErrorIs(t, err, services.ErrSnapshotCorrupted)
+		assert.Zero(t, replica.LastLSN(), "corrupted snapshot should not be restored")
+		files, err := os.ReadDir(dir)
+		assert.NoError(t, err)
+		assert.Empty(t, files, "corrupted snapshot should be removed")
+
+		_, err = streamer.BootstrapReplica(context.Background(), conn, replica, dir)
+		assert.NoError(t, err)
+		assert.NotZero(t, replica.LastLSN())
+	})
+
+	t.Run("bootstrap", func(t *testing.T) {
+		replicaConfig := dbkernel.NewDefaultEngineConfig()
+		replicaConfig.DBEngine = dbkernel.BoltDBEngine
+		replicaConfig.Replica = true
+		replica, err := dbkernel.NewStorageEngine(t.TempDir(), nameSpace, replicaConfig)
+		assert.NoError(t, err)
+		defer func() {
+			assert.NoError(t, replica.Close(context.Background()))
+		}()
+
+		dir := t.TempDir()
+		client, err := streamer.BootstrapReplica(context.Background(), conn, replica, dir)
+		assert.NoError(t, err)
+		assert.NotNil(t, replica.UpstreamOffset())
+		files, err := os.ReadDir(dir)
+		assert.NoError(t, err)
+		assert.Empty(t, files, "downloaded snapshot should be removed once restored")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = client.StreamWAL(ctx)
+		}()
+		put(10)
+		assert.Eventually(t, func() bool {
+			return replica.LastLSN() == leader.LastLSN()
+		}, 10*time.Second, 10*time.Millisecond)
+		cancel()
+		<-done
+
+		for key, value := range keys {
+			got, err := replica.Get([]byte(key))
+			assert.NoError(t, err)
+			assert.Equal(t, value, got)
+		}
+	})
+}
+
+func TestServer_ReplicateWAL(t *testing.T) {
+	nameSpace := strings.ToLower(gofakeit.Noun())
+	leader, err := dbkernel.NewStorageEngine(t.TempDir(), nameSpace, dbkernel.NewDefaultEngineConfig())
+	assert.NoError(t, err)
+	defer leader.Close(context.Background())
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, leader.Put([]byte(gofakeit.UUID()), []byte(gofakeit.Sentence(5))))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errGroup, _ := errgroup.WithContext(ctx)
+	server := streamer.NewGrpcStreamer(errGroup, map[string]*dbkernel.Engine{nameSpace: leader}, 2*time.Second)
+
+	listener := bufconn.Listen(listenerBuffSize)
+	defer listener.Close()
+	gS := grpc.NewServer(grpc.ChainStreamInterceptor(middleware.RequireNamespaceInterceptor,
+		middleware.RequestIDStreamInterceptor,
+		middleware.CorrelationIDStreamInterceptor,
+		middleware.TelemetryInterceptor))
+	defer gS.Stop()
+
+	go func() {
+		v2.RegisterWALReplicationServiceServer(gS, server)
+		if err := gS.Serve(listener); err != nil {
+			assert.NoError(t, err, "failed to grpc start server")
+		}
+	}()
+
+	conn, err := grpc.NewClient("passthrough://bufnet", grpc.WithContextDialer(bufDialer(listener)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err, "failed to create grpc client")
+
+	t.Run("missing_replica_id", func(t *testing.T) {
+		md := metadata.Pairs("x-namespace", nameSpace)
+		stream, err := v2.NewWALReplicationServiceClient(conn).ReplicateWAL(metadata.NewOutgoingContext(ctx, md))
+		assert.NoError(t, err)
+		assert.NoError(t, stream.Send(&v2.ReplicateWALRequest{
+			RequestType: &v2.ReplicateWALRequest_Start{Start: &v2.ReplicateWALStart{}},
+		}))
+		_, err = stream.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("lag", func(t *testing.T) {
+		md := metadata.Pairs("x-namespace", nameSpace)
+		streamCtx, streamCancel := context.WithCancel(metadata.NewOutgoingContext(ctx, md))
+		stream, err := v2.NewWALReplicationServiceClient(conn).ReplicateWAL(streamCtx)
+		assert.NoError(t, err)
+		assert.NoError(t, stream.Send(&v2.ReplicateWALRequest{
+			RequestType: &v2.ReplicateWALRequest_Start{Start: &v2.ReplicateWALStart{ReplicaId: "stuck"}},
+		}))
+		applied, err := leader.OffsetForLSN(2)
+		assert.NoError(t, err)
+		assert.NoError(t, stream.Send(&v2.ReplicateWALRequest{
+			RequestType: &v2.ReplicateWALRequest_Ack{Ack: &v2.ReplicaAck{
+				AppliedLsn:    2,
+				AppliedOffset: applied.Encode(),
+				DurableLsn:    1,
+			}},
+		}))
+
+		assert.Eventually(t, func() bool {
+			replicas := server.Replicas()
+			return len(replicas) == 1 && replicas[0].AppliedLSN == 2
+		}, 5*time.Second, 10*time.Millisecond)
+		status := server.Replicas()[0]
+		assert.Equal(t, nameSpace, status.Namespace)
+		assert.Equal(t, "stuck", status.ReplicaID)
+		assert.Equal(t, uint64(1), status.DurableLSN)
+		assert.Equal(t, leader.LastLSN()-2, status.LagRecords)
+		assert.Equal(t, leader.WALBytesAfter(applied), status.LagBytes)
+		assert.Positive(t, status.LagBytes)
+		assert.GreaterOrEqual(t, status.LagSeconds, float64(0))
+
+		streamCancel()
+		assert.Eventually(t, func() bool {
+			return len(server.Replicas()) == 0
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("replica", func(t *testing.T) {
+		config := dbkernel.NewDefaultEngineConfig()
+		config.Replica = true
+		replica, err := dbkernel.NewStorageEngine(t.TempDir(), nameSpace, config)
+		assert.NoError(t, err)
+		defer func() {
+			assert.NoError(t, replica.Close(context.Background()))
+		}()
+
+		client := streamer.NewReplicaStreamerClient(conn, replica)
+		client.SetReplicaID("replica-1")
+		clientCtx, clientCancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = client.ReplicateWAL(clientCtx)
+		}()
+
+		caughtUp := func() bool {
+			replicas := server.Replicas()
+			return len(replicas) == 1 && replicas[0].DurableLSN == leader.LastLSN() && replicas[0].LagRecords == 0
+		}
+		assert.Eventually(t, caughtUp, 10*time.Second, 10*time.Millisecond)
+		status := server.Replicas()[0]
+		assert.Equal(t, "replica-1", status.ReplicaID)
+		assert.Equal(t, leader.LastLSN(), status.AppliedLSN)
+		assert.Equal(t, int64(0), status.LagBytes)
+		assert.False(t, status.LastAckAt.IsZero())
+
+		assert.NoError(t, leader.Put([]byte("key"), []byte("value")))
+		assert.Eventually(t, caughtUp, 10*time.Second, 10*time.Millisecond)
+
+		clientCancel()
+		<-done
+		assert.Eventually(t, func() bool {
+			return len(server.Replicas()) == 0
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("writer_without_progress", func(t *testing.T) {
+		client := streamer.NewGrpcStreamerClient(conn, nameSpace, &noopWalIO{}, nil)
+		client.SetReplicaID("noop-1")
+		clientCtx, clientCancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = client.ReplicateWAL(clientCtx)
+		}()
+
+		assert.Eventually(t, func() bool {
+			replicas := server.Replicas()
+			return len(replicas) == 1 && replicas[0].AppliedLSN == leader.LastLSN()
+		}, 10*time.Second, 10*time.Millisecond)
+		assert.Zero(t, server.Replicas()[0].DurableLSN, "records not synced by the writer should not be acked as durable")
+
+		clientCancel()
+		<-done
+		assert.Eventually(t, func() bool {
+			return len(server.Replicas()) == 0
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestServer_ReplicateWAL_SyncReplicas(t *testing.T) {
+	nameSpace := strings.ToLower(gofakeit.Noun())
+	config := dbkernel.NewDefaultEngineConfig()
+	config.Replication = dbkernel.ReplicationConfig{SyncReplicas: 1, SyncTimeout: 3 * time.Second}
+	leader, err := dbkernel.NewStorageEngine(t.TempDir(), nameSpace, config)
+	assert.NoError(t, err)
+	defer leader.Close(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errGroup, _ := errgroup.WithContext(ctx)
+	server := streamer.NewGrpcStreamer(errGroup, map[string]*dbkernel.Engine{nameSpace: leader}, 2*time.Second)
+
+	listener := bufconn.Listen(listenerBuffSize)
+	defer listener.Close()
+	gS := grpc.NewServer(grpc.ChainStreamInterceptor(middleware.RequireNamespaceInterceptor,
+		middleware.RequestIDStreamInterceptor,
+		middleware.CorrelationIDStreamInterceptor,
+		middleware.TelemetryInterceptor))
+	defer gS.Stop()
+
+	go func() {
+		v2.RegisterWALReplicationServiceServer(gS, server)
+		if err := gS.Serve(listener); err != nil {
+			assert.NoError(t, err, "failed to grpc start server")
+		}
+	}()
+
+	conn, err := grpc.NewClient("passthrough://bufnet", grpc.WithContextDialer(bufDialer(listener)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err, "failed to create grpc client")
+
+	replicaConfig := dbkernel.NewDefaultEngineConfig()
+	replicaConfig.Replica = true
+	replica, err := dbkernel.NewStorageEngine(t.TempDir(), nameSpace, replicaConfig)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, replica.Close(context.Background()))
+	}()
+
+	client := streamer.NewReplicaStreamerClient(conn, replica)
+	client.SetReplicaID("replica-1")
+	clientCtx, clientCancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = client.ReplicateWAL(clientCtx)
+	}()
+	assert.Eventually(t, func() bool {
+		return len(server.Replicas()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		key := []byte(gofakeit.UUID())
+		assert.NoError(t, leader.Put(key, []byte("value")))
+		// the write returns once the follower has it durable.
+		assert.GreaterOrEqual(t, replica.LastLSN(), leader.LastLSN())
+		value, err := replica.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("value"), value)
+	}
+
+	clientCancel()
+	<-done
+	assert.Eventually(t, func() bool {
+		return len(server.Replicas()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.ErrorIs(t, leader.Put([]byte("key"), []byte("value")), dbkernel.ErrSyncReplicasTimeout)
+}
+
+func TestServer_StreamWAL_Filter(t *testing.T) {
+	nameSpace := strings.ToLower(gofakeit.Noun())
+	leader, err := dbkernel.NewStorageEngine(t.TempDir(), nameSpace, dbkernel.NewDefaultEngineConfig())
+	assert.NoError(t, err)
+	defer leader.Close(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errGroup, _ := errgroup.WithContext(ctx)
+	server := streamer.NewGrpcStreamer(errGroup, map[string]*dbkernel.Engine{nameSpace: leader}, 2*time.Second)
+
+	listener := bufconn.Listen(listenerBuffSize)
+	defer listener.Close()
+	gS := grpc.NewServer(grpc.ChainStreamInterceptor(middleware.RequireNamespaceInterceptor,
+		middleware.RequestIDStreamInterceptor,
+		middleware.CorrelationIDStreamInterceptor,
+		middleware.TelemetryInterceptor))
+	defer gS.Stop()
+
+	go func() {
+		v2.RegisterWALReplicationServiceServer(gS, server)
+		if err := gS.Serve(listener); err != nil {
+			assert.NoError(t, err, "failed to grpc start server")
+		}
+	}()
+
+	conn, err := grpc.NewClient("passthrough://bufnet", grpc.WithContextDialer(bufDialer(listener)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err, "failed to create grpc client")
+
+	t.Run("invalid_entry_type", func(t *testing.T) {
+		md := metadata.Pairs("x-namespace", nameSpace)
+		stream, err := v2.NewWALReplicationServiceClient(conn).StreamWAL(metadata.NewOutgoingContext(ctx, md),
+			&v2.StreamWALRequest{Filter: &v2.WALFilter{
+				EntryTypes: []v2.ChangeEntryType{v2.ChangeEntryType_CHANGE_ENTRY_TYPE_UNSPECIFIED},
+			}})
+		assert.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("replica", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, leader.Put([]byte(fmt.Sprintf("tenant_a/%d", i)), []byte("value")))
+			assert.NoError(t, leader.Put([]byte(fmt.Sprintf("tenant_b/%d", i)), []byte("value")))
+		}
+
+		config := dbkernel.NewDefaultEngineConfig()
+		config.Replica = true
+		replica, err := dbkernel.NewStorageEngine(t.TempDir(), nameSpace, config)
+		assert.NoError(t, err)
+		defer func() {
+			assert.NoError(t, replica.Close(context.Background()))
+		}()
+
+		client := streamer.NewReplicaStreamerClient(conn, replica)
+		client.SetFilter(&v2.WALFilter{KeyPrefixes: [][]byte{[]byte("tenant_a/")}})
+		clientCtx, clientCancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = client.StreamWAL(clientCtx)
+		}()
+
+		// the last record is filtered out, the replica still resumes after it.
+		assert.Eventually(t, func() bool {
+			return bytes.Equal(replica.UpstreamOffset(), leader.CurrentOffset().Encode())
+		}, 10*time.Second, 10*time.Millisecond)
+		for i := 0; i < 10; i++ {
+			value, err := replica.Get([]byte(fmt.Sprintf("tenant_a/%d", i)))
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("value"), value)
+			_, err = replica.Get([]byte(fmt.Sprintf("tenant_b/%d", i)))
+			assert.ErrorIs(t, err, dbkernel.ErrKeyNotFound)
+		}
+
+		clientCancel()
+		<-done
+	})
+}
+
+// serveEngine streams the wal of the engine, and returns the client connection to it with the server.
+func serveEngine(t *testing.T, engine *dbkernel.Engine) (*grpc.ClientConn, *streamer.GrpcStreamer) {
+	errGroup, _ := errgroup.WithContext(context.Background())
+	server := streamer.NewGrpcStreamer(errGroup, map[string]*dbkernel.Engine{engine.Namespace(): engine}, 2*time.Second)
+	listener := bufconn.Listen(listenerBuffSize)
+	gS := grpc.NewServer(grpc.ChainStreamInterceptor(middleware.RequireNamespaceInterceptor,
+		middleware.RequestIDStreamInterceptor))
+	v2.RegisterWALReplicationServiceServer(gS, server)
+	go func() {
+		_ = gS.Serve(listener)
+	}()
+	conn, err := grpc.NewClient("passthrough://bufnet", grpc.WithContextDialer(bufDialer(listener)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		gS.Stop()
+		_ = listener.Close()
+	})
+	return conn, server
+}
+
+func TestServer_StreamWAL_Failover(t *testing.T) {
+	nameSpace := strings.ToLower(gofakeit.Noun())
+	config := dbkernel.NewDefaultEngineConfig()
+	config.DBEngine = dbkernel.BoltDBEngine
+	replicaConfig := *config
+	replicaConfig.Replica = true
+
+	serve := func(engine *dbkernel.Engine) *grpc.ClientConn {
+		conn, _ := serveEngine(t, engine)
+		return conn
+	}
+	// follow streams into the replica till it has every record of the upstream, and returns the stream error.
+	follow := func(conn *grpc.ClientConn, replica, upstream *dbkernel.Engine) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- streamer.NewReplicaStreamerClient(conn, replica).StreamWAL(ctx)
+		}()
+		var err error
+		assert.Eventually(t, func() bool {
+			select {
+			case err = <-done:
+				return true
+			default:
+				return replica.LastLSN() == upstream.LastLSN()
+			}
+		}, 10*time.Second, 10*time.Millisecond)
+		cancel()
+		if err != nil {
+			return err
+		}
+		<-done
+		return nil
+	}
+
+	primaryDir := t.TempDir()
+	primary, err := dbkernel.NewStorageEngine(primaryDir, nameSpace, config)
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, primary.Put([]byte(fmt.Sprintf("key_%d", i)), []byte("value")))
+	}
+	replica, err := dbkernel.NewStorageEngine(t.TempDir(), nameSpace, &replicaConfig)
+	assert.NoError(t, err)
+	defer replica.Close(context.Background())
+	assert.NoError(t, follow(serve(primary), replica, primary))
+
+	// the replica is promoted while the old primary still takes a write.
+	epoch, err := replica.Promote()
+	assert.NoError(t, err)
+	assert.NoError(t, replica.Put([]byte("promoted"), []byte("value")))
+	assert.NoError(t, primary.Put([]byte("divergent"), []byte("value")))
+	promotedConn := serve(replica)
+
+	t.Run("stale_server", func(t *testing.T) {
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-namespace", nameSpace))
+		stream, err := v2.NewWALReplicationServiceClient(promotedConn).StreamWAL(ctx, &v2.StreamWALRequest{Epoch: epoch + 1})
+		assert.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	// the old primary rejoins as a replica of the promoted one.
+	assert.NoError(t, primary.Close(context.Background()))
+	demoted, err := dbkernel.NewStorageEngine(primaryDir, nameSpace, &replicaConfig)
+	assert.NoError(t, err)
+	err = follow(promotedConn, demoted, replica)
+	var diverged *streamer.DivergedError
+	assert.ErrorAs(t, err, &diverged)
+	assert.Equal(t, uint64(10), diverged.LSN)
+	assert.NoError(t, demoted.Close(context.Background()))
+
+	assert.NoError(t, dbkernel.TruncateWAL(primaryDir, nameSpace, config, diverged.LSN))
+	demoted, err = dbkernel.NewStorageEngine(primaryDir, nameSpace, &replicaConfig)
+	assert.NoError(t, err)
+	defer demoted.Close(context.Background())
+	assert.NoError(t, follow(promotedConn, demoted, replica))
+	assert.Equal(t, epoch, demoted.Epoch())
+	_, err = demoted.Get([]byte("divergent"))
+	assert.ErrorIs(t, err, dbkernel.ErrKeyNotFound)
+	value, err := demoted.Get([]byte("promoted"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+}
+
+func TestServer_StreamWAL_Cascade(t *testing.T) {
+	nameSpace := strings.ToLower(gofakeit.Noun())
+	config := dbkernel.NewDefaultEngineConfig()
+	config.DBEngine = dbkernel.BoltDBEngine
+	config.ArenaSize = 200 * 1024
+	replicaConfig := *config
+	replicaConfig.Replica = true
+
+	root, err := dbkernel.NewStorageEngine(t.TempDir(), nameSpace, config)
+	assert.NoError(t, err)
+	defer root.Close(context.Background())
+	keys := make(map[string][]byte)
+	put := func(n int) {
+		for i := 0; i < n; i++ {
+			key := gofakeit.UUID()
+			keys[key] = []byte(gofakeit.LetterN(largeValue))
+			assert.NoError(t, root.Put([]byte(key), keys[key]))
+		}
+	}
+	put(600)
+	// the snapshot has the flushed records.
+	assert.Eventually(t, func() bool {
+		offset, _, err := root.SnapshotOffset()
+		return err == nil && offset != nil
+	}, 10*time.Second, 10*time.Millisecond)
+	rootConn, _ := serveEngine(t, root)
+
+	// replicate streams the upstream to the replica over ReplicateWAL till it's stopped.
+	replicate := func(client *streamer.GrpcStreamerClient, id string) func() {
+		client.SetReplicaID(id)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = client.ReplicateWAL(ctx)
+		}()
+		return func() {
+			cancel()
+			<-done
+		}
+	}
+	caughtUp := func(replica *dbkernel.Engine) func() bool {
+		return func() bool {
+			return replica.LastLSN() == root.LastLSN()
+		}
+	}
+
+	// the intermediate is restored from the snapshot of the root, its wal starts after the snapshot.
+	intermediate, err := dbkernel.NewStorageEngine(t.TempDir(), nameSpace, &replicaConfig)
+	assert.NoError(t, err)
+	defer intermediate.Close(context.Background())
+	client, err := streamer.BootstrapReplica(context.Background(), rootConn, intermediate, t.TempDir())
+	assert.NoError(t, err)
+	first, err := intermediate.FirstLSN()
+	assert.NoError(t, err)
+	assert.Greater(t, first, uint64(1))
+	stopIntermediate := replicate(client, "intermediate")
+	assert.Eventually(t, caughtUp(intermediate), 10*time.Second, 10*time.Millisecond)
+	intermediateConn, intermediateServer := serveEngine(t, intermediate)
+
+	t.Run("snapshot_required", func(t *testing.T) {
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-namespace", nameSpace))
+		stream, err := v2.NewWALReplicationServiceClient(intermediateConn).StreamWAL(ctx, &v2.StreamWALRequest{})
+		assert.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.OutOfRange, status.Code(err), "records before the wal are only in the snapshot")
+	})
+
+	// the leaf is restored from the snapshot of the intermediate, and streams the wal of the intermediate.
+	leaf, err := dbkernel.NewStorageEngine(t.TempDir(), nameSpace, &replicaConfig)
+	assert.NoError(t, err)
+	defer leaf.Close(context.Background())
+	client, err = streamer.BootstrapReplica(context.Background(), intermediateConn, leaf, t.TempDir())
+	assert.NoError(t, err)
+	assert.Positive(t, leaf.LastLSN(), "leaf should resume after the lsn of the snapshot")
+	stopLeaf := replicate(client, "leaf")
+
+	put(10)
+	assert.Eventually(t, caughtUp(leaf), 10*time.Second, 10*time.Millisecond)
+	for key, value := range keys {
+		got, err := leaf.Get([]byte(key))
+		assert.NoError(t, err)
+		assert.Equal(t, value, got)
+	}
+	lsn, pendingHLC := leaf.RootProgress()
+	assert.Equal(t, root.LastLSN(), lsn)
+	assert.Zero(t, pendingHLC)
+
+	t.Run("root_lag", func(t *testing.T) {
+		stopIntermediate()
+		assert.Eventually(t, func() bool {
+			replicas := intermediateServer.Replicas()
+			return len(replicas) == 1 && replicas[0].AppliedLSN == leaf.LastLSN()
+		}, 10*time.Second, 10*time.Millisecond)
+
+		// the root is ahead of the intermediate, by the records it's yet to stream.
+		hlc, err := root.RecordHLC(root.LastLSN())
+		assert.NoError(t, err)
+		intermediate.ObserveRoot(intermediate.LastLSN()+5, hlc)
+		status := intermediateServer.Replicas()[0]
+		assert.Equal(t, "leaf", status.ReplicaID)
+		assert.Equal(t, uint64(5), status.LagRecords)
+		assert.Equal(t, int64(0), status.LagBytes, "leaf has every record of the intermediate wal")
+		assert.Positive(t, status.LagSeconds)
+	})
+
+	// the leaf resumes on the root by the lsn, the offsets of the intermediate are not of the root wal.
+	stopLeaf()
+	put(10)
+	stopLeaf = replicate(streamer.NewReplicaStreamerClient(rootConn, leaf), "leaf")
+	defer stopLeaf()
+	assert.Eventually(t, caughtUp(leaf), 10*time.Second, 10*time.Millisecond)
+	for key, value := range keys {
+		got, err := leaf.Get([]byte(key))
+		assert.NoError(t, err)
+		assert.Equal(t, value, got)
+	}
+}
+
+func TestServer_StreamWAL_MultiLeader(t *testing.T) {
+	nameSpace := strings.ToLower(gofakeit.Noun())
+	nodeIDs := []string{"region_a", "region_b", "region_c"}
+	engines := make(map[string]*dbkernel.Engine)
+	conns := make(map[string]*grpc.ClientConn)
+	for _, nodeID := range nodeIDs {
+		config := dbkernel.NewDefaultEngineConfig()
+		config.DBEngine = dbkernel.BoltDBEngine
+		config.MultiLeader = true
+		config.NodeID = nodeID
+		engine, err := dbkernel.NewStorageEngine(t.TempDir(), nameSpace, config)
+		assert.NoError(t, err)
+		t.Cleanup(func() {
+			_ = engine.Close(context.Background())
+		})
+		engines[nodeID] = engine
+		conns[nodeID], _ = serveEngine(t, engine)
+	}
+
+	// every node streams from every other node.
+	ctx, cancel := context.WithCancel(context.Background())
+	var streams sync.WaitGroup
+	for _, nodeID := range nodeIDs {
+		for _, peerID := range nodeIDs {
+			if peerID == nodeID {
+				continue
+			}
+			client, err := streamer.NewPeerStreamerClient(conns[peerID], engines[nodeID], peerID)
+			assert.NoError(t, err)
+			streams.Add(1)
+			go func() {
+				defer streams.Done()
+				_ = client.StreamWAL(ctx)
+			}()
+		}
+	}
+
+	const (
+		keys = 10
+		rows = 3
+	)
+	// randomized concurrent writers on every node, on the same keys, rows and columns.
+	var writers sync.WaitGroup
+	for i, nodeID := range nodeIDs {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			rnd := rand.New(rand.NewPCG(uint64(i), 0))
+			engine := engines[nodeID]
+			for j := range 200 {
+				key := []byte(fmt.Sprintf("key_%d", rnd.IntN(keys)))
+				rowKey := fmt.Sprintf("user_row_%d", rnd.IntN(rows))
+				columns := map[string][]byte{fmt.Sprintf("column_%d", rnd.IntN(4)): []byte(fmt.Sprintf("%s_%d", nodeID, j))}
+				var err error
+				switch rnd.IntN(5) {
+				case 0:
+					err = engine.Put(key, []byte(fmt.Sprintf("%s_%d", nodeID, j)))
+				case 1:
+					err = engine.Delete(key)
+				case 2:
+					err = engine.SetColumnsInRow(rowKey, columns)
+				case 3:
+					err = engine.DeleteColumnsFromRow(rowKey, columns)
+				default:
+					err = engine.DeleteRow(rowKey)
+				}
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	writers.Wait()
+
+	// every node has received every record of its peers.
+	assert.Eventually(t, func() bool {
+		for _, nodeID := range nodeIDs {
+			for _, peerID := range nodeIDs {
+				offset, err := engines[nodeID].PeerOffset(peerID)
+				if peerID != nodeID && (err != nil || !bytes.Equal(offset, engines[peerID].CurrentOffset().Encode())) {
+					return false
+				}
+			}
+		}
+		return true
+	}, 20*time.Second, 50*time.Millisecond)
+	cancel()
+	streams.Wait()
+
+	state := func(engine *dbkernel.Engine) string {
+		var state strings.Builder
+		for i := range keys {
+			value, err := engine.Get([]byte(fmt.Sprintf("key_%d", i)))
+			fmt.Fprintf(&state, "key_%d=%s %v\n", i, value, err)
+		}
+		for i := range rows {
+			columns, _ := engine.GetRowColumns(fmt.Sprintf("user_row_%d", i), nil)
+			fmt.Fprintf(&state, "user_row_%d=%q\n", i, columns)
+		}
+		return state.String()
+	}
+	expected := state(engines[nodeIDs[0]])
+	for _, nodeID := range nodeIDs[1:] {
+		assert.Equal(t, expected, state(engines[nodeID]), nodeID)
+	}
+}
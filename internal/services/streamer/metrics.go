@@ -14,6 +14,14 @@ var (
 		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 10),
 	}, []string{"namespace", "method", "streamer"})
 
+	metricsStreamBatchBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "kvalchemy",
+		Subsystem: "streamer",
+		Name:      "server_stream_message_bytes",
+		Help:      "Size of the WAL record messages sent via streaming, before the compression.",
+		Buckets:   prometheus.ExponentialBuckets(256, 4, 8),
+	}, []string{"namespace", "method", "streamer"})
+
 	metricsStreamSendTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "kvalchemy",
 		Subsystem: "streamer",
@@ -35,6 +43,34 @@ var (
 		Help:      "Total number of errors during sending WAL records.",
 	}, []string{"namespace", "method", "streamer"})
 
+	metricsReplicaAckTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kvalchemy",
+		Subsystem: "streamer",
+		Name:      "server_replica_ack_total",
+		Help:      "Total number of acknowledgements received from the followers.",
+	}, []string{"namespace", "replica"})
+
+	metricsReplicaLagRecords = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kvalchemy",
+		Subsystem: "streamer",
+		Name:      "server_replica_lag_records",
+		Help:      "Number of WAL records the follower is yet to apply.",
+	}, []string{"namespace", "replica"})
+
+	metricsReplicaLagBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kvalchemy",
+		Subsystem: "streamer",
+		Name:      "server_replica_lag_bytes",
+		Help:      "Bytes of the WAL the follower is yet to apply.",
+	}, []string{"namespace", "replica"})
+
+	metricsReplicaLagSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kvalchemy",
+		Subsystem: "streamer",
+		Name:      "server_replica_lag_seconds",
+		Help:      "Age of the oldest WAL record the follower is yet to apply, from the hybrid logical clock of the record.",
+	}, []string{"namespace", "replica"})
+
 	clientWalRecvTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "kvalchemy",
@@ -54,12 +90,33 @@ var (
 		},
 		[]string{"namespace", "streamer", "error"},
 	)
+
+	clientRootLagRecords = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kvalchemy",
+		Subsystem: "streamer",
+		Name:      "client_root_lag_records",
+		Help:      "Number of WAL records of the root primary the follower is yet to receive, through every upstream in between.",
+	}, []string{"namespace"})
+
+	clientRootLagSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kvalchemy",
+		Subsystem: "streamer",
+		Name:      "client_root_lag_seconds",
+		Help:      "Age of the oldest WAL record of the root primary the follower is yet to receive, from the hybrid logical clock of the record.",
+	}, []string{"namespace"})
 )
 
 // RegisterMetrics registers the Prometheus metrics.
 func RegisterMetrics() {
 	prometheus.MustRegister(metricsStreamSendLatency, metricsStreamSendErrors,
+		metricsStreamBatchBytes,
 		metricsActiveStreamTotal,
+		metricsReplicaAckTotal,
+		metricsReplicaLagRecords,
+		metricsReplicaLagBytes,
+		metricsReplicaLagSeconds,
 		clientWalRecvTotal,
-		clientWalStreamErrTotal)
+		clientWalStreamErrTotal,
+		clientRootLagRecords,
+		clientRootLagSeconds)
 }
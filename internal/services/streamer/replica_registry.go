@@ -0,0 +1,223 @@
+package streamer
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel"
+	v2 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
+	"github.com/segmentio/ksuid"
+)
+
+// ReplicaStatus is the progress of a follower connected over ReplicateWAL, as last acknowledged by it.
+type ReplicaStatus struct {
+	Namespace   string    `json:"namespace"`
+	ReplicaID   string    `json:"replica_id"`
+	Addr        string    `json:"addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+	// LastAckAt is zero till the first ack of the follower.
+	LastAckAt     time.Time `json:"last_ack_at"`
+	AppliedLSN    uint64    `json:"applied_lsn"`
+	AppliedOffset []byte    `json:"applied_offset"`
+	DurableLSN    uint64    `json:"durable_lsn"`
+	DurableOffset []byte    `json:"durable_offset"`
+	// lag of the applied records behind the root primary of the namespace.
+	LagRecords uint64  `json:"lag_records"`
+	LagBytes   int64   `json:"lag_bytes"`
+	LagSeconds float64 `json:"lag_seconds"`
+}
+
+type replicaEntry struct {
+	engine *dbkernel.Engine
+	status ReplicaStatus
+	// filtered followers don't count towards the sync replicas of the namespace.
+	filtered bool
+}
+
+// replicaRegistry tracks the followers connected over ReplicateWAL by their stream.
+type replicaRegistry struct {
+	mu       sync.Mutex
+	replicas map[string]*replicaEntry
+}
+
+func newReplicaRegistry() *replicaRegistry {
+	return &replicaRegistry{replicas: make(map[string]*replicaEntry)}
+}
+
+// register adds the follower, it returns the key of the stream the acks of the follower are recorded with.
+func (r *replicaRegistry) register(engine *dbkernel.Engine, replicaID, addr string, filtered bool) string {
+	key := ksuid.New().String()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replicas[key] = &replicaEntry{
+		engine:   engine,
+		filtered: filtered,
+		status: ReplicaStatus{
+			Namespace:   engine.Namespace(),
+			ReplicaID:   replicaID,
+			Addr:        addr,
+			ConnectedAt: time.Now(),
+		},
+	}
+	return key
+}
+
+// unregister removes the follower once its stream ends.
+func (r *replicaRegistry) unregister(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.replicas[key]
+	if !ok {
+		return
+	}
+	delete(r.replicas, key)
+
+	// another stream of the same follower still reports it.
+	for _, other := range r.replicas {
+		if other.status.Namespace == entry.status.Namespace && other.status.ReplicaID == entry.status.ReplicaID {
+			return
+		}
+	}
+	entry.engine.RemoveReplica(entry.status.ReplicaID)
+	metricsReplicaLagRecords.DeleteLabelValues(entry.status.Namespace, entry.status.ReplicaID)
+	metricsReplicaLagBytes.DeleteLabelValues(entry.status.Namespace, entry.status.ReplicaID)
+	metricsReplicaLagSeconds.DeleteLabelValues(entry.status.Namespace, entry.status.ReplicaID)
+}
+
+// ack records the progress acknowledged by the follower and updates its lag.
+func (r *replicaRegistry) ack(key string, ack *v2.ReplicaAck) {
+	r.mu.Lock()
+	entry, ok := r.replicas[key]
+	if !ok {
+		r.mu.Unlock()
+		return
+	}
+
+	status := &entry.status
+	status.LastAckAt = time.Now()
+	status.AppliedLSN = ack.GetAppliedLsn()
+	status.AppliedOffset = ack.GetAppliedOffset()
+	status.DurableLSN = ack.GetDurableLsn()
+	status.DurableOffset = ack.GetDurableOffset()
+	metricsReplicaAckTotal.WithLabelValues(status.Namespace, status.ReplicaID).Inc()
+	if !entry.filtered {
+		entry.engine.AckDurable(status.ReplicaID, status.DurableLSN)
+	}
+	r.mu.Unlock()
+
+	r.refresh(key)
+}
+
+// refreshEvery refreshes the lag of the follower every interval till the context is done, the lag keeps
+// growing with the writes to the namespace while the follower doesn't acknowledge any.
+func (r *replicaRegistry) refreshEvery(ctx context.Context, key string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.refresh(key)
+		}
+	}
+}
+
+// refresh updates the lag of the follower and its gauges. The lag is computed from the WAL outside
+// the lock, so the acks of the other followers don't wait for it.
+func (r *replicaRegistry) refresh(key string) {
+	r.mu.Lock()
+	entry, ok := r.replicas[key]
+	if !ok {
+		r.mu.Unlock()
+		return
+	}
+	engine, status := entry.engine, entry.status
+	r.mu.Unlock()
+
+	status = replicaLag(engine, status)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok = r.replicas[key]
+	// the follower is gone, or a newer ack refreshes the lag itself.
+	if !ok || entry.status.AppliedLSN != status.AppliedLSN {
+		return
+	}
+	entry.status.LagRecords, entry.status.LagBytes, entry.status.LagSeconds =
+		status.LagRecords, status.LagBytes, status.LagSeconds
+	metricsReplicaLagRecords.WithLabelValues(status.Namespace, status.ReplicaID).Set(float64(status.LagRecords))
+	metricsReplicaLagBytes.WithLabelValues(status.Namespace, status.ReplicaID).Set(float64(status.LagBytes))
+	metricsReplicaLagSeconds.WithLabelValues(status.Namespace, status.ReplicaID).Set(status.LagSeconds)
+}
+
+// list returns the status of the connected followers, with their lag behind the root primary.
+func (r *replicaRegistry) list() []ReplicaStatus {
+	r.mu.Lock()
+	keys := make([]string, 0, len(r.replicas))
+	for key := range r.replicas {
+		keys = append(keys, key)
+	}
+	r.mu.Unlock()
+	for _, key := range keys {
+		r.refresh(key)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	replicas := make([]ReplicaStatus, 0, len(r.replicas))
+	for _, entry := range r.replicas {
+		replicas = append(replicas, entry.status)
+	}
+	slices.SortFunc(replicas, func(a, b ReplicaStatus) int {
+		return cmp.Or(cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.ReplicaID, b.ReplicaID),
+			a.ConnectedAt.Compare(b.ConnectedAt))
+	})
+	return replicas
+}
+
+// replicaLag returns the status with the lag of the applied records of the follower behind the root primary
+// of its namespace, through this server if it's a replica itself. The lag in seconds is the age of the oldest
+// record the follower is yet to apply, and the lag in bytes is of the records in the WAL of this server.
+func replicaLag(engine *dbkernel.Engine, status ReplicaStatus) ReplicaStatus {
+	rootLSN, pendingHLC := engine.RootProgress()
+	if status.AppliedLSN >= rootLSN {
+		status.LagRecords, status.LagBytes, status.LagSeconds = 0, 0, 0
+		return status
+	}
+
+	status.LagRecords = rootLSN - status.AppliedLSN
+	var applied *dbkernel.Offset
+	if len(status.AppliedOffset) > 0 {
+		offset, err := decodeMetadata(status.AppliedOffset)
+		if err != nil {
+			slog.Warn("[kvalchemy.streamer.grpc] invalid replica applied offset",
+				"namespace", status.Namespace, "replica_id", status.ReplicaID, "error", err)
+			return status
+		}
+		applied = offset
+	}
+	status.LagBytes = engine.WALBytesAfter(applied)
+
+	// the records this server is yet to receive itself are only known by the hlc of the oldest one.
+	hlc := pendingHLC
+	if status.AppliedLSN < engine.LastLSN() {
+		var err error
+		hlc, err = engine.RecordHLC(status.AppliedLSN + 1)
+		if err != nil {
+			slog.Warn("[kvalchemy.streamer.grpc] replica lag: read record hlc failed",
+				"namespace", status.Namespace, "replica_id", status.ReplicaID, "error", err)
+			return status
+		}
+	}
+	if hlc == 0 {
+		status.LagSeconds = 0
+		return status
+	}
+	status.LagSeconds = max(0, time.Since(dbkernel.HLCTime(hlc)).Seconds())
+	return status
+}
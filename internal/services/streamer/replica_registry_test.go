@@ -0,0 +1,45 @@
+package streamer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel"
+	v2 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicaRegistry_RefreshLag(t *testing.T) {
+	engine, err := dbkernel.NewStorageEngine(t.TempDir(), "test_replica_lag", dbkernel.NewDefaultEngineConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+	require.NoError(t, engine.Put([]byte("key"), []byte("value")))
+
+	registry := newReplicaRegistry()
+	key := registry.register(engine, "lagging", "", false)
+	defer registry.unregister(key)
+	registry.ack(key, &v2.ReplicaAck{AppliedLsn: engine.LastLSN()})
+	lagRecords := metricsReplicaLagRecords.WithLabelValues(engine.Namespace(), "lagging")
+	assert.Equal(t, float64(0), testutil.ToFloat64(lagRecords))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go registry.refreshEvery(ctx, key, 10*time.Millisecond)
+
+	// the follower doesn't acknowledge the new writes, its lag still grows.
+	for i := 0; i < 3; i++ {
+		require.NoError(t, engine.Put([]byte("key"), []byte("value")))
+	}
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(lagRecords) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	replicas := registry.list()
+	require.Len(t, replicas, 1)
+	assert.Equal(t, uint64(3), replicas[0].LagRecords)
+	assert.Positive(t, replicas[0].LagBytes)
+}
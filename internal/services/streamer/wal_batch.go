@@ -0,0 +1,46 @@
+package streamer
+
+import (
+	v2 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// walBatch is the WAL records of the next message of the stream, sized by their encoded size.
+type walBatch struct {
+	maxBytes int
+	records  []*v2.WALRecord
+	size     int
+}
+
+func newWALBatch(maxBytes int) *walBatch {
+	return &walBatch{maxBytes: maxBytes}
+}
+
+// recordSize is the size the record adds to the encoded StreamWALResponse.
+func recordSize(record *v2.WALRecord) int {
+	size := proto.Size(record)
+	return protowire.SizeTag(1) + protowire.SizeBytes(size)
+}
+
+// fits reports if the record can be added without the batch going over its max bytes.
+// Every record fits an empty batch, even the one larger than the max bytes.
+func (b *walBatch) fits(record *v2.WALRecord) bool {
+	return len(b.records) == 0 || b.size+recordSize(record) <= b.maxBytes
+}
+
+func (b *walBatch) add(record *v2.WALRecord) {
+	b.records = append(b.records, record)
+	b.size += recordSize(record)
+}
+
+// full reports if no more record should be added to the batch.
+func (b *walBatch) full() bool {
+	return b.size >= b.maxBytes
+}
+
+// reset starts a new batch, the records of the previous one are still owned by the sent message.
+func (b *walBatch) reset() {
+	b.records = nil
+	b.size = 0
+}
@@ -0,0 +1,298 @@
+package streamer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ankur-anand/unisondb/dbkernel"
+	"github.com/ankur-anand/unisondb/internal/middleware"
+	v2 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+func testWALRecord(size int) *v2.WALRecord {
+	return &v2.WALRecord{Offset: []byte("offset"), Record: make([]byte, size)}
+}
+
+func TestWALBatch(t *testing.T) {
+	batch := newWALBatch(4 << 10)
+	record := testWALRecord(1000)
+	for i := 0; i < 4; i++ {
+		assert.True(t, batch.fits(record))
+		batch.add(record)
+	}
+	assert.False(t, batch.fits(record), "fifth record goes over the max bytes")
+	assert.False(t, batch.full())
+	assert.Equal(t, proto.Size(&v2.StreamWALResponse{WalRecords: batch.records}), batch.size)
+
+	batch.reset()
+	large := testWALRecord(8 << 10)
+	assert.True(t, batch.fits(large), "record larger than the max bytes fits an empty batch")
+	batch.add(large)
+	assert.True(t, batch.full())
+}
+
+// recordingStream is a grpc.ServerStream that records the sent messages.
+type recordingStream struct {
+	grpc.ServerStream
+	mu       sync.Mutex
+	messages []*v2.StreamWALResponse
+}
+
+func (r *recordingStream) Context() context.Context {
+	return context.Background()
+}
+
+func (r *recordingStream) SendMsg(m any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, m.(*v2.StreamWALResponse))
+	return nil
+}
+
+func (r *recordingStream) sent() []*v2.StreamWALResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*v2.StreamWALResponse(nil), r.messages...)
+}
+
+func TestStreamWalRecords_Batching(t *testing.T) {
+	run := func(t *testing.T, ackRequested bool) (*recordingStream, chan []*v2.WALRecord) {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := &recordingStream{}
+		walReceiver := make(chan []*v2.WALRecord)
+		s := NewGrpcStreamer(nil, nil, time.Minute)
+		// an engine without records, the test records are not decoded.
+		engine := newStreamEngine(t, 0)
+		done := make(chan error, 1)
+		go func() {
+			done <- s.streamWalRecords(ctx, stream, engine, walReceiver, make(chan error), ackRequested)
+		}()
+		t.Cleanup(func() {
+			cancel()
+			assert.NoError(t, <-done)
+		})
+		return stream, walReceiver
+	}
+
+	t.Run("byte_budget", func(t *testing.T) {
+		stream, walReceiver := run(t, false)
+		records := make([]*v2.WALRecord, 0, 1000)
+		for i := 0; i < 1000; i++ {
+			records = append(records, testWALRecord(1000))
+		}
+		walReceiver <- records
+
+		var sent []*v2.StreamWALResponse
+		assert.Eventually(t, func() bool {
+			sent = stream.sent()
+			count := 0
+			for _, message := range sent {
+				count += len(message.WalRecords)
+			}
+			return count == len(records)
+		}, 5*time.Second, time.Millisecond)
+		// every message but the last one is filled to the byte budget.
+		perMessage := streamBatchBytes / recordSize(records[0])
+		assert.Len(t, sent, (len(records)+perMessage-1)/perMessage)
+		for _, message := range sent {
+			assert.LessOrEqual(t, proto.Size(&v2.StreamWALResponse{WalRecords: message.WalRecords}), streamBatchBytes)
+			assert.False(t, message.AckRequested)
+		}
+	})
+
+	t.Run("linger", func(t *testing.T) {
+		stream, walReceiver := run(t, false)
+		walReceiver <- []*v2.WALRecord{testWALRecord(10)}
+		walReceiver <- []*v2.WALRecord{testWALRecord(10), testWALRecord(10)}
+		assert.Eventually(t, func() bool {
+			return len(stream.sent()) == 1
+		}, 5*time.Second, time.Millisecond)
+		assert.Len(t, stream.sent()[0].WalRecords, 3, "records received within the linger time are sent together")
+	})
+
+	t.Run("ack_requested", func(t *testing.T) {
+		stream, walReceiver := run(t, true)
+		walReceiver <- []*v2.WALRecord{testWALRecord(10)}
+		walReceiver <- []*v2.WALRecord{testWALRecord(10), testWALRecord(10)}
+		assert.Eventually(t, func() bool {
+			return len(stream.sent()) == 2
+		}, 5*time.Second, time.Millisecond)
+		assert.Len(t, stream.sent()[0].WalRecords, 1, "records of the writes waiting for the ack don't linger")
+		assert.True(t, stream.sent()[0].AckRequested)
+	})
+}
+
+func TestZstdCompressor(t *testing.T) {
+	compressor := newZstdCompressor()
+	data := []byte(strings.Repeat(gofakeit.Sentence(10), 100))
+
+	var compressed strings.Builder
+	w, err := compressor.Compress(&compressed)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Less(t, compressed.Len(), len(data))
+
+	r, err := compressor.Decompress(strings.NewReader(compressed.String()))
+	require.NoError(t, err)
+	decompressed := make([]byte, len(data)+1)
+	n, _ := r.Read(decompressed)
+	assert.Equal(t, data, decompressed[:n])
+}
+
+// countingConn counts the bytes read by the client.
+type countingConn struct {
+	net.Conn
+	read *atomic.Int64
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+// walStreamServer serves the WAL of the engine over bufconn, and returns the client connection
+// along with the bytes read by it.
+func walStreamServer(tb testing.TB, engine *dbkernel.Engine) (*grpc.ClientConn, *atomic.Int64) {
+	tb.Helper()
+	errGroup, _ := errgroup.WithContext(context.Background())
+	server := NewGrpcStreamer(errGroup, map[string]*dbkernel.Engine{engine.Namespace(): engine}, time.Minute)
+
+	listener := bufconn.Listen(1 << 20)
+	read := &atomic.Int64{}
+	gS := grpc.NewServer(grpc.ChainStreamInterceptor(middleware.RequireNamespaceInterceptor,
+		middleware.RequestIDStreamInterceptor,
+		middleware.MethodInterceptor))
+	v2.RegisterWALReplicationServiceServer(gS, server)
+	go func() {
+		_ = gS.Serve(listener)
+	}()
+
+	conn, err := grpc.NewClient("passthrough://bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			conn, err := listener.Dial()
+			if err != nil {
+				return nil, err
+			}
+			return &countingConn{Conn: conn, read: read}, nil
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(tb, err)
+	tb.Cleanup(func() {
+		_ = conn.Close()
+		gS.Stop()
+		_ = listener.Close()
+	})
+	return conn, read
+}
+
+// receiveRecords streams the WAL of the namespace from the beginning till count records are received.
+func receiveRecords(ctx context.Context, conn *grpc.ClientConn, namespace string, count int, opts ...grpc.CallOption) error {
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(ctx, metadata.Pairs("x-namespace", namespace)))
+	defer cancel()
+	stream, err := v2.NewWALReplicationServiceClient(conn).StreamWAL(ctx, &v2.StreamWALRequest{}, opts...)
+	if err != nil {
+		return err
+	}
+	received := 0
+	for received < count {
+		res, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		received += len(res.WalRecords)
+	}
+	return nil
+}
+
+func newStreamEngine(tb testing.TB, records int) *dbkernel.Engine {
+	tb.Helper()
+	engine, err := dbkernel.NewStorageEngine(tb.TempDir(), "stream_bench", dbkernel.NewDefaultEngineConfig())
+	require.NoError(tb, err)
+	tb.Cleanup(func() {
+		_ = engine.Close(context.Background())
+	})
+	for i := 0; i < records; i++ {
+		require.NoError(tb, engine.Put([]byte(fmt.Sprintf("key_%d", i)), []byte(gofakeit.Sentence(20))))
+	}
+	return engine
+}
+
+func TestStreamWAL_Compression(t *testing.T) {
+	engine := newStreamEngine(t, 500)
+	conn, read := walStreamServer(t, engine)
+
+	received := func(t *testing.T, opts ...grpc.CallOption) int64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		start := read.Load()
+		assert.NoError(t, receiveRecords(ctx, conn, engine.Namespace(), 500, opts...))
+		return read.Load() - start
+	}
+	uncompressed := received(t)
+	for _, compression := range []string{CompressionGzip, CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			assert.Less(t, received(t, grpc.UseCompressor(compression)), uncompressed/2)
+		})
+	}
+}
+
+// BenchmarkStreamWAL measures the records streamed per second over bufconn. The per_record case
+// sends every record in a message of its own, like the sender did before the byte budget.
+func BenchmarkStreamWAL(b *testing.B) {
+	const records = 20000
+	engine := newStreamEngine(b, records)
+	conn, read := walStreamServer(b, engine)
+
+	cases := []struct {
+		name        string
+		batchBytes  int
+		linger      time.Duration
+		compression string
+	}{
+		{name: "per_record", batchBytes: 1},
+		{name: "batched", batchBytes: streamBatchBytes, linger: streamLingerTime},
+		{name: "batched_gzip", batchBytes: streamBatchBytes, linger: streamLingerTime, compression: CompressionGzip},
+		{name: "batched_zstd", batchBytes: streamBatchBytes, linger: streamLingerTime, compression: CompressionZstd},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			batchBytes, linger := streamBatchBytes, streamLingerTime
+			streamBatchBytes, streamLingerTime = c.batchBytes, c.linger
+			defer func() {
+				streamBatchBytes, streamLingerTime = batchBytes, linger
+			}()
+			var opts []grpc.CallOption
+			if c.compression != "" {
+				opts = append(opts, grpc.UseCompressor(c.compression))
+			}
+
+			start := read.Load()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := receiveRecords(context.Background(), conn, engine.Namespace(), records, opts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(records*b.N)/b.Elapsed().Seconds(), "records/s")
+			b.ReportMetric(float64(read.Load()-start)/float64(b.N), "wire_bytes/op")
+		})
+	}
+}
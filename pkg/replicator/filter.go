@@ -0,0 +1,177 @@
+package replicator
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/ankur-anand/unisondb/dbkernel"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
+	v1 "github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
+)
+
+// Filter selects the records the Replicator sends, the records of a txn or a batch are sent or dropped together.
+// A txn is sent if any of its records match, a batch if any of its operations match.
+type Filter struct {
+	// KeyPrefixes of the keys to send, every key if empty.
+	KeyPrefixes [][]byte
+	// EntryTypes to send, every entry type if empty.
+	EntryTypes []logrecord.LogEntryType
+	// ExcludeOrigins are the nodes of a multi leader namespace whose records are not sent,
+	// the records a peer wrote are never streamed back to it.
+	ExcludeOrigins []string
+}
+
+// matchRecord reports if the record, or any operation of the batch record, matches the filter.
+func (f *Filter) matchRecord(record *logcodec.LogRecord) (bool, error) {
+	if slices.Contains(f.ExcludeOrigins, record.Origin) {
+		return false, nil
+	}
+	if !record.IsBatch() {
+		return f.match(record), nil
+	}
+	records, err := record.BatchRecords()
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(records, f.match), nil
+}
+
+func (f *Filter) match(record *logcodec.LogRecord) bool {
+	if len(f.EntryTypes) > 0 && !slices.Contains(f.EntryTypes, record.EntryType) {
+		return false
+	}
+	if len(f.KeyPrefixes) == 0 {
+		return true
+	}
+	key := record.Key()
+	return slices.ContainsFunc(f.KeyPrefixes, func(prefix []byte) bool {
+		return bytes.HasPrefix(key, prefix)
+	})
+}
+
+// filteredRecord is a record held back till the txn it is part of commits.
+type filteredRecord struct {
+	walRecord *v1.WALRecord
+	// txn is nil for the records that are not part of a txn.
+	txn  *filteredTxn
+	keep bool
+}
+
+type filteredTxn struct {
+	id   string
+	keep bool
+	done bool
+	// abandoned is set once the txn is no longer open in the engine, it's dropped if no commit
+	// record is read till the end of the wal after it.
+	abandoned bool
+}
+
+// recordFilter applies the filter to the records in the order they are read from the wal.
+// The records after the begin record of a txn are held back till the txn commits, so the records
+// are still sent in the order of the wal.
+type recordFilter struct {
+	filter  Filter
+	pending []filteredRecord
+	// txns are the txns with their begin record in pending, by the txn id.
+	txns map[string]*filteredTxn
+	// skippedTo is the offset of the last dropped record, if no record was sent after it.
+	skippedTo []byte
+}
+
+func newRecordFilter(filter Filter) *recordFilter {
+	return &recordFilter{
+		filter: filter,
+		txns:   make(map[string]*filteredTxn),
+	}
+}
+
+// add filters the wal record, and returns the records that are ready to be sent.
+func (f *recordFilter) add(walRecord *v1.WALRecord) ([]*v1.WALRecord, error) {
+	record, err := logcodec.DecodeRecord(walRecord.Record)
+	if err != nil {
+		return nil, err
+	}
+
+	switch record.TxnState {
+	case logrecord.TransactionStateBegin:
+		txn := &filteredTxn{id: string(record.TxnID)}
+		f.txns[txn.id] = txn
+		f.pending = append(f.pending, filteredRecord{walRecord: walRecord, txn: txn})
+	case logrecord.TransactionStatePrepare, logrecord.TransactionStateCommit:
+		txn := f.txns[string(record.TxnID)]
+		// the begin record of the txn is before the start of the stream, the receiver
+		// can't apply the record without it.
+		if txn == nil {
+			f.pending = append(f.pending, filteredRecord{walRecord: walRecord})
+			break
+		}
+		if !txn.keep {
+			if txn.keep, err = f.filter.matchRecord(record); err != nil {
+				return nil, err
+			}
+		}
+		if record.TxnState == logrecord.TransactionStateCommit {
+			txn.done = true
+			delete(f.txns, txn.id)
+		}
+		f.pending = append(f.pending, filteredRecord{walRecord: walRecord, txn: txn})
+	default:
+		keep, err := f.filter.matchRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		f.pending = append(f.pending, filteredRecord{walRecord: walRecord, keep: keep})
+	}
+	return f.release(), nil
+}
+
+// caughtUp is called once every record of the wal is read, the txns that are no longer open
+// in the engine since the previous call are never committed, and are dropped.
+func (f *recordFilter) caughtUp(engine *dbkernel.Engine) []*v1.WALRecord {
+	for id, txn := range f.txns {
+		if txn.abandoned {
+			txn.done, txn.keep = true, false
+			delete(f.txns, id)
+			continue
+		}
+		// the commit of a txn closed after this is read before the next call.
+		txn.abandoned = !engine.TxnOpen([]byte(id))
+	}
+	return f.release()
+}
+
+// release returns the records in front of pending that are kept, till the first record of an open txn.
+func (f *recordFilter) release() []*v1.WALRecord {
+	var released []*v1.WALRecord
+	i := 0
+	for ; i < len(f.pending); i++ {
+		pending := f.pending[i]
+		keep := pending.keep
+		if pending.txn != nil {
+			if !pending.txn.done {
+				break
+			}
+			keep = pending.txn.keep
+		}
+		if keep {
+			released = append(released, pending.walRecord)
+			f.skippedTo = nil
+		} else {
+			f.skippedTo = pending.walRecord.Offset
+		}
+	}
+	f.pending = slices.Delete(f.pending, 0, i)
+	return released
+}
+
+// progress returns the record that only has the offset of the last dropped record, so the receiver
+// resumes after the dropped records. It's nil if a record was sent after the last dropped one.
+func (f *recordFilter) progress() *v1.WALRecord {
+	if f.skippedTo == nil {
+		return nil
+	}
+	marker := &v1.WALRecord{Offset: f.skippedTo}
+	f.skippedTo = nil
+	return marker
+}
@@ -44,6 +44,8 @@ type Replicator struct {
 	batchDuration    time.Duration
 	lastOffset       *dbkernel.Offset
 	replicatorEngine string
+	// filter is nil if every record is sent.
+	filter *recordFilter
 }
 
 // NewReplicator returns an initialized Replicator that could be used for replicating
@@ -60,6 +62,12 @@ func NewReplicator(e *dbkernel.Engine, batchSize int,
 	}
 }
 
+// SetFilter sends only the records that match the filter. The dropped records are reported with a record
+// that only has the offset of the last dropped one, so the receiver resumes after them.
+func (r *Replicator) SetFilter(filter Filter) {
+	r.filter = newRecordFilter(filter)
+}
+
 // Replicate reads wal record from the underlying engine,
 // and sends the WalRecords when batchSize/maxBatchDuration is reached.
 func (r *Replicator) Replicate(ctx context.Context, recordsChan chan<- []*v1.WALRecord) error {
@@ -99,6 +107,11 @@ func (r *Replicator) replicateFromReader(ctx context.Context, recordsChan chan<-
 	var batch []*v1.WALRecord
 
 	sendFunc := func() {
+		if r.filter != nil {
+			if marker := r.filter.progress(); marker != nil {
+				batch = append(batch, marker)
+			}
+		}
 		if len(batch) > 0 {
 			mKeyReplicatorRecordsTotal.WithLabelValues(namespace, r.replicatorEngine).Add(float64(len(batch)))
 			mKeyReplicatorBatchesTotal.WithLabelValues(namespace, r.replicatorEngine).Add(1)
@@ -135,6 +148,9 @@ func (r *Replicator) replicateFromReader(ctx context.Context, recordsChan chan<-
 		value, pos, err := reader.Next()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
+				if r.filter != nil {
+					batch = append(batch, r.filter.caughtUp(r.engine)...)
+				}
 				sendFunc()
 				break
 			}
@@ -148,8 +164,16 @@ func (r *Replicator) replicateFromReader(ctx context.Context, recordsChan chan<-
 			Crc32Checksum: crc32.ChecksumIEEE(value),
 		}
 
-		batch = append(batch, walRecord)
 		r.lastOffset = pos
+		if r.filter == nil {
+			batch = append(batch, walRecord)
+		} else {
+			released, err := r.filter.add(walRecord)
+			if err != nil {
+				return err
+			}
+			batch = append(batch, released...)
+		}
 		if len(batch) >= r.batchSize {
 			sendFunc()
 		}
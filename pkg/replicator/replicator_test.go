@@ -1,12 +1,18 @@
 package replicator
 
 import (
+	"bytes"
 	"context"
+	"hash/crc32"
+	"slices"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/ankur-anand/unisondb/dbkernel"
+	"github.com/ankur-anand/unisondb/dbkernel/wal/walrecord"
+	"github.com/ankur-anand/unisondb/internal/logcodec"
+	"github.com/ankur-anand/unisondb/schemas/logrecord"
 	"github.com/ankur-anand/unisondb/schemas/proto/gen/go/unisondb/replicator/v1"
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/stretchr/testify/assert"
@@ -141,3 +147,175 @@ func TestReplicator_ReplicateReaderTimer(t *testing.T) {
 	cancel()
 	wg.Wait()
 }
+
+// filteredReplication runs the replicator with the filter, and returns the records received so far.
+func filteredReplication(t *testing.T, engine *dbkernel.Engine, filter Filter) func() []*v1.WALRecord {
+	t.Helper()
+	replicatorInstance := NewReplicator(engine, 10, 20*time.Millisecond, nil, "testing")
+	replicatorInstance.SetFilter(filter)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	recvChan := make(chan []*v1.WALRecord)
+	var mu sync.Mutex
+	var received []*v1.WALRecord
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_ = replicatorInstance.Replicate(ctx, recvChan)
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case records := <-recvChan:
+				mu.Lock()
+				received = append(received, records...)
+				mu.Unlock()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+
+	return func() []*v1.WALRecord {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(received)
+	}
+}
+
+// describeRecords returns the key of the received records, or the kind of the records without one.
+// The records that only report the offset of the filtered out records are skipped.
+func describeRecords(t *testing.T, records []*v1.WALRecord) []string {
+	t.Helper()
+	var described []string
+	for _, walRecord := range records {
+		if len(walRecord.Record) == 0 {
+			continue
+		}
+		record, err := logcodec.DecodeRecord(walRecord.Record)
+		assert.NoError(t, err)
+		switch {
+		case record.IsBatch():
+			described = append(described, "batch")
+		case record.TxnState == logrecord.TransactionStateBegin:
+			described = append(described, "begin")
+		case record.TxnState == logrecord.TransactionStateCommit:
+			described = append(described, "commit")
+		default:
+			described = append(described, string(record.Key()))
+		}
+	}
+	return described
+}
+
+func TestReplicator_Filter(t *testing.T) {
+	engine, err := dbkernel.NewStorageEngine(t.TempDir(), "test_filter", dbkernel.NewDefaultEngineConfig())
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+
+	assert.NoError(t, engine.Put([]byte("tenant_a/1"), []byte("value")))
+	assert.NoError(t, engine.Put([]byte("tenant_b/1"), []byte("value")))
+	dropped, err := engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+	assert.NoError(t, err)
+	assert.NoError(t, dropped.AppendKVTxn([]byte("tenant_b/txn"), []byte("value")))
+	assert.NoError(t, dropped.Commit())
+	droppedOffset := engine.CurrentOffset()
+
+	kept, err := engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+	assert.NoError(t, err)
+	assert.NoError(t, kept.AppendKVTxn([]byte("tenant_a/txn"), []byte("value")))
+	assert.NoError(t, engine.Put([]byte("tenant_a/2"), []byte("value")))
+
+	received := filteredReplication(t, engine, Filter{KeyPrefixes: [][]byte{[]byte("tenant_a/")}})
+	assert.Eventually(t, func() bool {
+		records := received()
+		return len(records) == 2 && bytes.Equal(records[1].Offset, droppedOffset.Encode())
+	}, 5*time.Second, 10*time.Millisecond, "records after the begin of the open txn should be held back")
+	assert.Equal(t, []string{"tenant_a/1"}, describeRecords(t, received()))
+
+	assert.NoError(t, kept.Commit())
+	batch := dbkernel.NewBatch()
+	batch.Put([]byte("tenant_b/3"), []byte("value"))
+	batch.Put([]byte("tenant_a/3"), []byte("value"))
+	assert.NoError(t, engine.WriteBatch(batch))
+	assert.NoError(t, engine.SetColumnsInRow("tenant_b/row", map[string][]byte{"column": []byte("value")}))
+
+	assert.Eventually(t, func() bool {
+		records := received()
+		return bytes.Equal(records[len(records)-1].Offset, engine.CurrentOffset().Encode())
+	}, 5*time.Second, 10*time.Millisecond)
+	records := received()
+	assert.Equal(t, []string{"tenant_a/1", "begin", "tenant_a/txn", "tenant_a/2", "commit", "batch"},
+		describeRecords(t, records))
+	assert.Empty(t, records[len(records)-1].Record, "dropped row should be reported by its offset")
+
+	t.Run("entry_types", func(t *testing.T) {
+		received := filteredReplication(t, engine, Filter{EntryTypes: []logrecord.LogEntryType{logrecord.LogEntryTypeRow}})
+		assert.Eventually(t, func() bool {
+			records := received()
+			return len(records) > 0 && bytes.Equal(records[len(records)-1].Offset, engine.CurrentOffset().Encode())
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, []string{"tenant_b/row"}, describeRecords(t, received()))
+	})
+}
+
+func TestReplicator_FilterExcludeOrigins(t *testing.T) {
+	newEngine := func(nodeID string) *dbkernel.Engine {
+		config := dbkernel.NewDefaultEngineConfig()
+		config.MultiLeader = true
+		config.NodeID = nodeID
+		engine, err := dbkernel.NewStorageEngine(t.TempDir(), "test_filter", config)
+		assert.NoError(t, err)
+		t.Cleanup(func() {
+			assert.NoError(t, engine.Close(context.Background()))
+		})
+		return engine
+	}
+	engine, peer := newEngine("a"), newEngine("b")
+
+	assert.NoError(t, peer.Put([]byte("written_on_b"), []byte("value")))
+	reader, err := peer.NewReader()
+	assert.NoError(t, err)
+	data, pos, err := reader.Next()
+	assert.NoError(t, err)
+	assert.NoError(t, engine.ApplyPeer("b", pos.Encode(), data, crc32.ChecksumIEEE(data)))
+	assert.NoError(t, engine.Put([]byte("written_on_a"), []byte("value")))
+
+	received := filteredReplication(t, engine, Filter{ExcludeOrigins: []string{"b"}})
+	assert.Eventually(t, func() bool {
+		records := received()
+		return len(records) > 0 && bytes.Equal(records[len(records)-1].Offset, engine.CurrentOffset().Encode())
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"written_on_a"}, describeRecords(t, received()))
+}
+
+func TestReplicator_FilterAbandonedTxn(t *testing.T) {
+	dir := t.TempDir()
+	engine, err := dbkernel.NewStorageEngine(dir, "test_filter", dbkernel.NewDefaultEngineConfig())
+	assert.NoError(t, err)
+	txn, err := engine.NewTxn(walrecord.LogOperationInsert, walrecord.EntryTypeKV)
+	assert.NoError(t, err)
+	assert.NoError(t, txn.AppendKVTxn([]byte("tenant_a/txn"), []byte("value")))
+	// the txn is never committed after the restart.
+	assert.NoError(t, engine.Close(context.Background()))
+
+	engine, err = dbkernel.NewStorageEngine(dir, "test_filter", dbkernel.NewDefaultEngineConfig())
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, engine.Close(context.Background()))
+	})
+	assert.NoError(t, engine.Put([]byte("tenant_a/1"), []byte("value")))
+
+	received := filteredReplication(t, engine, Filter{KeyPrefixes: [][]byte{[]byte("tenant_a/")}})
+	assert.Eventually(t, func() bool {
+		return slices.Equal([]string{"tenant_a/1"}, describeRecords(t, received()))
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -12,6 +12,8 @@ const (
 	LogOperationTypeDelete         LogOperationType = 2
 	LogOperationTypeTxnMarker      LogOperationType = 3
 	LogOperationTypeDeleteRowByKey LogOperationType = 4
+	LogOperationTypeBatch          LogOperationType = 5
+	LogOperationTypeMembership     LogOperationType = 6
 )
 
 var EnumNamesLogOperationType = map[LogOperationType]string{
@@ -20,6 +22,8 @@ var EnumNamesLogOperationType = map[LogOperationType]string{
 	LogOperationTypeDelete:         "Delete",
 	LogOperationTypeTxnMarker:      "TxnMarker",
 	LogOperationTypeDeleteRowByKey: "DeleteRowByKey",
+	LogOperationTypeBatch:          "Batch",
+	LogOperationTypeMembership:     "Membership",
 }
 
 var EnumValuesLogOperationType = map[string]LogOperationType{
@@ -28,6 +32,8 @@ var EnumValuesLogOperationType = map[string]LogOperationType{
 	"Delete":         LogOperationTypeDelete,
 	"TxnMarker":      LogOperationTypeTxnMarker,
 	"DeleteRowByKey": LogOperationTypeDeleteRowByKey,
+	"Batch":          LogOperationTypeBatch,
+	"Membership":     LogOperationTypeMembership,
 }
 
 func (v LogOperationType) String() string {
@@ -202,8 +202,28 @@ func (rcv *LogRecord) Payload(obj *flatbuffers.Table) bool {
 	return false
 }
 
+func (rcv *LogRecord) Epoch() uint64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(24))
+	if o != 0 {
+		return rcv._tab.GetUint64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *LogRecord) MutateEpoch(n uint64) bool {
+	return rcv._tab.MutateUint64Slot(24, n)
+}
+
+func (rcv *LogRecord) Origin() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(26))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
 func LogRecordStart(builder *flatbuffers.Builder) {
-	builder.StartObject(10)
+	builder.StartObject(12)
 }
 func LogRecordAddLsn(builder *flatbuffers.Builder, lsn uint64) {
 	builder.PrependUint64Slot(0, lsn, 0)
@@ -241,6 +261,12 @@ func LogRecordAddPayloadType(builder *flatbuffers.Builder, payloadType LogOperat
 func LogRecordAddPayload(builder *flatbuffers.Builder, payload flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(9, flatbuffers.UOffsetT(payload), 0)
 }
+func LogRecordAddEpoch(builder *flatbuffers.Builder, epoch uint64) {
+	builder.PrependUint64Slot(10, epoch, 0)
+}
+func LogRecordAddOrigin(builder *flatbuffers.Builder, origin flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(11, flatbuffers.UOffsetT(origin), 0)
+}
 func LogRecordEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
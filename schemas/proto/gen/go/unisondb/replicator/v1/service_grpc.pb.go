@@ -19,15 +19,27 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	WALReplicationService_StreamWAL_FullMethodName = "/kvalchemy.replicator.v1.WALReplicationService/StreamWAL"
+	WALReplicationService_StreamWAL_FullMethodName      = "/kvalchemy.replicator.v1.WALReplicationService/StreamWAL"
+	WALReplicationService_StreamSnapshot_FullMethodName = "/kvalchemy.replicator.v1.WALReplicationService/StreamSnapshot"
+	WALReplicationService_ReplicateWAL_FullMethodName   = "/kvalchemy.replicator.v1.WALReplicationService/ReplicateWAL"
+	WALReplicationService_EpochEnd_FullMethodName       = "/kvalchemy.replicator.v1.WALReplicationService/EpochEnd"
 )
 
 // WALReplicationServiceClient is the client API for WALReplicationService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type WALReplicationServiceClient interface {
-	// / Stream WAL Logs
+	/// Stream WAL Logs
 	StreamWAL(ctx context.Context, in *StreamWALRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamWALResponse], error)
+	// StreamSnapshot streams the snapshot of the btree store in chunks, a new replica restores it
+	// and streams the WAL after the offset the snapshot matches.
+	StreamSnapshot(ctx context.Context, in *StreamSnapshotRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamSnapshotResponse], error)
+	// ReplicateWAL streams the WAL like StreamWAL, and the follower acknowledges the records it has applied
+	// and made durable on the same stream, the upstream tracks the lag of every follower with them.
+	ReplicateWAL(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ReplicateWALRequest, StreamWALResponse], error)
+	// EpochEnd returns the last lsn of the epoch on the upstream, a follower that rejoins from the epoch
+	// has diverged from the upstream if it has records after it.
+	EpochEnd(ctx context.Context, in *EpochEndRequest, opts ...grpc.CallOption) (*EpochEndResponse, error)
 }
 
 type wALReplicationServiceClient struct {
@@ -57,12 +69,63 @@ func (c *wALReplicationServiceClient) StreamWAL(ctx context.Context, in *StreamW
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type WALReplicationService_StreamWALClient = grpc.ServerStreamingClient[StreamWALResponse]
 
+func (c *wALReplicationServiceClient) StreamSnapshot(ctx context.Context, in *StreamSnapshotRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamSnapshotResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &WALReplicationService_ServiceDesc.Streams[1], WALReplicationService_StreamSnapshot_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamSnapshotRequest, StreamSnapshotResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WALReplicationService_StreamSnapshotClient = grpc.ServerStreamingClient[StreamSnapshotResponse]
+
+func (c *wALReplicationServiceClient) ReplicateWAL(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ReplicateWALRequest, StreamWALResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &WALReplicationService_ServiceDesc.Streams[2], WALReplicationService_ReplicateWAL_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ReplicateWALRequest, StreamWALResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WALReplicationService_ReplicateWALClient = grpc.BidiStreamingClient[ReplicateWALRequest, StreamWALResponse]
+
+func (c *wALReplicationServiceClient) EpochEnd(ctx context.Context, in *EpochEndRequest, opts ...grpc.CallOption) (*EpochEndResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EpochEndResponse)
+	err := c.cc.Invoke(ctx, WALReplicationService_EpochEnd_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // WALReplicationServiceServer is the server API for WALReplicationService service.
 // All implementations must embed UnimplementedWALReplicationServiceServer
 // for forward compatibility.
 type WALReplicationServiceServer interface {
-	// / Stream WAL Logs
+	/// Stream WAL Logs
 	StreamWAL(*StreamWALRequest, grpc.ServerStreamingServer[StreamWALResponse]) error
+	// StreamSnapshot streams the snapshot of the btree store in chunks, a new replica restores it
+	// and streams the WAL after the offset the snapshot matches.
+	StreamSnapshot(*StreamSnapshotRequest, grpc.ServerStreamingServer[StreamSnapshotResponse]) error
+	// ReplicateWAL streams the WAL like StreamWAL, and the follower acknowledges the records it has applied
+	// and made durable on the same stream, the upstream tracks the lag of every follower with them.
+	ReplicateWAL(grpc.BidiStreamingServer[ReplicateWALRequest, StreamWALResponse]) error
+	// EpochEnd returns the last lsn of the epoch on the upstream, a follower that rejoins from the epoch
+	// has diverged from the upstream if it has records after it.
+	EpochEnd(context.Context, *EpochEndRequest) (*EpochEndResponse, error)
 	mustEmbedUnimplementedWALReplicationServiceServer()
 }
 
@@ -76,6 +139,15 @@ type UnimplementedWALReplicationServiceServer struct{}
 func (UnimplementedWALReplicationServiceServer) StreamWAL(*StreamWALRequest, grpc.ServerStreamingServer[StreamWALResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method StreamWAL not implemented")
 }
+func (UnimplementedWALReplicationServiceServer) StreamSnapshot(*StreamSnapshotRequest, grpc.ServerStreamingServer[StreamSnapshotResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamSnapshot not implemented")
+}
+func (UnimplementedWALReplicationServiceServer) ReplicateWAL(grpc.BidiStreamingServer[ReplicateWALRequest, StreamWALResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ReplicateWAL not implemented")
+}
+func (UnimplementedWALReplicationServiceServer) EpochEnd(context.Context, *EpochEndRequest) (*EpochEndResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EpochEnd not implemented")
+}
 func (UnimplementedWALReplicationServiceServer) mustEmbedUnimplementedWALReplicationServiceServer() {}
 func (UnimplementedWALReplicationServiceServer) testEmbeddedByValue()                               {}
 
@@ -108,19 +180,293 @@ func _WALReplicationService_StreamWAL_Handler(srv interface{}, stream grpc.Serve
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type WALReplicationService_StreamWALServer = grpc.ServerStreamingServer[StreamWALResponse]
 
+func _WALReplicationService_StreamSnapshot_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamSnapshotRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WALReplicationServiceServer).StreamSnapshot(m, &grpc.GenericServerStream[StreamSnapshotRequest, StreamSnapshotResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WALReplicationService_StreamSnapshotServer = grpc.ServerStreamingServer[StreamSnapshotResponse]
+
+func _WALReplicationService_ReplicateWAL_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(WALReplicationServiceServer).ReplicateWAL(&grpc.GenericServerStream[ReplicateWALRequest, StreamWALResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WALReplicationService_ReplicateWALServer = grpc.BidiStreamingServer[ReplicateWALRequest, StreamWALResponse]
+
+func _WALReplicationService_EpochEnd_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EpochEndRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WALReplicationServiceServer).EpochEnd(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WALReplicationService_EpochEnd_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WALReplicationServiceServer).EpochEnd(ctx, req.(*EpochEndRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // WALReplicationService_ServiceDesc is the grpc.ServiceDesc for WALReplicationService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var WALReplicationService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "kvalchemy.replicator.v1.WALReplicationService",
 	HandlerType: (*WALReplicationServiceServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "EpochEnd",
+			Handler:    _WALReplicationService_EpochEnd_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "StreamWAL",
 			Handler:       _WALReplicationService_StreamWAL_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "StreamSnapshot",
+			Handler:       _WALReplicationService_StreamSnapshot_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ReplicateWAL",
+			Handler:       _WALReplicationService_ReplicateWAL_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "unisondb/replicator/v1/service.proto",
+}
+
+const (
+	RaftService_RequestVote_FullMethodName      = "/kvalchemy.replicator.v1.RaftService/RequestVote"
+	RaftService_AppendEntries_FullMethodName    = "/kvalchemy.replicator.v1.RaftService/AppendEntries"
+	RaftService_InstallSnapshot_FullMethodName  = "/kvalchemy.replicator.v1.RaftService/InstallSnapshot"
+	RaftService_ChangeMembership_FullMethodName = "/kvalchemy.replicator.v1.RaftService/ChangeMembership"
+)
+
+// RaftServiceClient is the client API for RaftService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RaftService replicates the log of the consensus namespaces among their nodes, the WAL of a namespace is its log.
+type RaftServiceClient interface {
+	// RequestVote is sent by a candidate to the other nodes of the namespace to become its leader for the term.
+	RequestVote(ctx context.Context, in *RequestVoteRequest, opts ...grpc.CallOption) (*RequestVoteResponse, error)
+	// AppendEntries is sent by the leader with the records of the log after the prev record, and as the heartbeat.
+	AppendEntries(ctx context.Context, in *AppendEntriesRequest, opts ...grpc.CallOption) (*AppendEntriesResponse, error)
+	// InstallSnapshot streams the snapshot of the btree store of the leader to a node without any record in its log.
+	InstallSnapshot(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[InstallSnapshotRequest, InstallSnapshotResponse], error)
+	// ChangeMembership adds, updates or removes a node of the namespace on its leader.
+	ChangeMembership(ctx context.Context, in *ChangeMembershipRequest, opts ...grpc.CallOption) (*ChangeMembershipResponse, error)
+}
+
+type raftServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRaftServiceClient(cc grpc.ClientConnInterface) RaftServiceClient {
+	return &raftServiceClient{cc}
+}
+
+func (c *raftServiceClient) RequestVote(ctx context.Context, in *RequestVoteRequest, opts ...grpc.CallOption) (*RequestVoteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RequestVoteResponse)
+	err := c.cc.Invoke(ctx, RaftService_RequestVote_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftServiceClient) AppendEntries(ctx context.Context, in *AppendEntriesRequest, opts ...grpc.CallOption) (*AppendEntriesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AppendEntriesResponse)
+	err := c.cc.Invoke(ctx, RaftService_AppendEntries_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftServiceClient) InstallSnapshot(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[InstallSnapshotRequest, InstallSnapshotResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RaftService_ServiceDesc.Streams[0], RaftService_InstallSnapshot_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[InstallSnapshotRequest, InstallSnapshotResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RaftService_InstallSnapshotClient = grpc.ClientStreamingClient[InstallSnapshotRequest, InstallSnapshotResponse]
+
+func (c *raftServiceClient) ChangeMembership(ctx context.Context, in *ChangeMembershipRequest, opts ...grpc.CallOption) (*ChangeMembershipResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChangeMembershipResponse)
+	err := c.cc.Invoke(ctx, RaftService_ChangeMembership_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RaftServiceServer is the server API for RaftService service.
+// All implementations must embed UnimplementedRaftServiceServer
+// for forward compatibility.
+//
+// RaftService replicates the log of the consensus namespaces among their nodes, the WAL of a namespace is its log.
+type RaftServiceServer interface {
+	// RequestVote is sent by a candidate to the other nodes of the namespace to become its leader for the term.
+	RequestVote(context.Context, *RequestVoteRequest) (*RequestVoteResponse, error)
+	// AppendEntries is sent by the leader with the records of the log after the prev record, and as the heartbeat.
+	AppendEntries(context.Context, *AppendEntriesRequest) (*AppendEntriesResponse, error)
+	// InstallSnapshot streams the snapshot of the btree store of the leader to a node without any record in its log.
+	InstallSnapshot(grpc.ClientStreamingServer[InstallSnapshotRequest, InstallSnapshotResponse]) error
+	// ChangeMembership adds, updates or removes a node of the namespace on its leader.
+	ChangeMembership(context.Context, *ChangeMembershipRequest) (*ChangeMembershipResponse, error)
+	mustEmbedUnimplementedRaftServiceServer()
+}
+
+// UnimplementedRaftServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRaftServiceServer struct{}
+
+func (UnimplementedRaftServiceServer) RequestVote(context.Context, *RequestVoteRequest) (*RequestVoteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequestVote not implemented")
+}
+func (UnimplementedRaftServiceServer) AppendEntries(context.Context, *AppendEntriesRequest) (*AppendEntriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AppendEntries not implemented")
+}
+func (UnimplementedRaftServiceServer) InstallSnapshot(grpc.ClientStreamingServer[InstallSnapshotRequest, InstallSnapshotResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method InstallSnapshot not implemented")
+}
+func (UnimplementedRaftServiceServer) ChangeMembership(context.Context, *ChangeMembershipRequest) (*ChangeMembershipResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChangeMembership not implemented")
+}
+func (UnimplementedRaftServiceServer) mustEmbedUnimplementedRaftServiceServer() {}
+func (UnimplementedRaftServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeRaftServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RaftServiceServer will
+// result in compilation errors.
+type UnsafeRaftServiceServer interface {
+	mustEmbedUnimplementedRaftServiceServer()
+}
+
+func RegisterRaftServiceServer(s grpc.ServiceRegistrar, srv RaftServiceServer) {
+	// If the following call pancis, it indicates UnimplementedRaftServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RaftService_ServiceDesc, srv)
+}
+
+func _RaftService_RequestVote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestVoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftServiceServer).RequestVote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RaftService_RequestVote_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftServiceServer).RequestVote(ctx, req.(*RequestVoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RaftService_AppendEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppendEntriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftServiceServer).AppendEntries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RaftService_AppendEntries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftServiceServer).AppendEntries(ctx, req.(*AppendEntriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RaftService_InstallSnapshot_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RaftServiceServer).InstallSnapshot(&grpc.GenericServerStream[InstallSnapshotRequest, InstallSnapshotResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RaftService_InstallSnapshotServer = grpc.ClientStreamingServer[InstallSnapshotRequest, InstallSnapshotResponse]
+
+func _RaftService_ChangeMembership_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChangeMembershipRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftServiceServer).ChangeMembership(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RaftService_ChangeMembership_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftServiceServer).ChangeMembership(ctx, req.(*ChangeMembershipRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RaftService_ServiceDesc is the grpc.ServiceDesc for RaftService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RaftService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kvalchemy.replicator.v1.RaftService",
+	HandlerType: (*RaftServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RequestVote",
+			Handler:    _RaftService_RequestVote_Handler,
+		},
+		{
+			MethodName: "AppendEntries",
+			Handler:    _RaftService_AppendEntries_Handler,
+		},
+		{
+			MethodName: "ChangeMembership",
+			Handler:    _RaftService_ChangeMembership_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "InstallSnapshot",
+			Handler:       _RaftService_InstallSnapshot_Handler,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "unisondb/replicator/v1/service.proto",
 }
@@ -376,7 +722,8 @@ var KVStoreWriteService_ServiceDesc = grpc.ServiceDesc{
 }
 
 const (
-	KVStoreReadService_Get_FullMethodName = "/kvalchemy.replicator.v1.KVStoreReadService/Get"
+	KVStoreReadService_Get_FullMethodName     = "/kvalchemy.replicator.v1.KVStoreReadService/Get"
+	KVStoreReadService_History_FullMethodName = "/kvalchemy.replicator.v1.KVStoreReadService/History"
 )
 
 // KVStoreReadServiceClient is the client API for KVStoreReadService service.
@@ -384,6 +731,8 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type KVStoreReadServiceClient interface {
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GetResponse], error)
+	// History streams the changes of the key still present in the WAL, in the order they were made.
+	History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[HistoryResponse], error)
 }
 
 type kVStoreReadServiceClient struct {
@@ -413,11 +762,32 @@ func (c *kVStoreReadServiceClient) Get(ctx context.Context, in *GetRequest, opts
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type KVStoreReadService_GetClient = grpc.ServerStreamingClient[GetResponse]
 
+func (c *kVStoreReadServiceClient) History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[HistoryResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &KVStoreReadService_ServiceDesc.Streams[1], KVStoreReadService_History_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[HistoryRequest, HistoryResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type KVStoreReadService_HistoryClient = grpc.ServerStreamingClient[HistoryResponse]
+
 // KVStoreReadServiceServer is the server API for KVStoreReadService service.
 // All implementations must embed UnimplementedKVStoreReadServiceServer
 // for forward compatibility.
 type KVStoreReadServiceServer interface {
 	Get(*GetRequest, grpc.ServerStreamingServer[GetResponse]) error
+	// History streams the changes of the key still present in the WAL, in the order they were made.
+	History(*HistoryRequest, grpc.ServerStreamingServer[HistoryResponse]) error
 	mustEmbedUnimplementedKVStoreReadServiceServer()
 }
 
@@ -431,6 +801,9 @@ type UnimplementedKVStoreReadServiceServer struct{}
 func (UnimplementedKVStoreReadServiceServer) Get(*GetRequest, grpc.ServerStreamingServer[GetResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method Get not implemented")
 }
+func (UnimplementedKVStoreReadServiceServer) History(*HistoryRequest, grpc.ServerStreamingServer[HistoryResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method History not implemented")
+}
 func (UnimplementedKVStoreReadServiceServer) mustEmbedUnimplementedKVStoreReadServiceServer() {}
 func (UnimplementedKVStoreReadServiceServer) testEmbeddedByValue()                            {}
 
@@ -463,6 +836,17 @@ func _KVStoreReadService_Get_Handler(srv interface{}, stream grpc.ServerStream)
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type KVStoreReadService_GetServer = grpc.ServerStreamingServer[GetResponse]
 
+func _KVStoreReadService_History_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(HistoryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KVStoreReadServiceServer).History(m, &grpc.GenericServerStream[HistoryRequest, HistoryResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type KVStoreReadService_HistoryServer = grpc.ServerStreamingServer[HistoryResponse]
+
 // KVStoreReadService_ServiceDesc is the grpc.ServiceDesc for KVStoreReadService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -476,6 +860,11 @@ var KVStoreReadService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _KVStoreReadService_Get_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "History",
+			Handler:       _KVStoreReadService_History_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "unisondb/replicator/v1/service.proto",
 }